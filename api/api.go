@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"io"
 	"time"
 
 	"github.com/weaveworks/flux"
@@ -9,26 +11,82 @@ import (
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
 	"github.com/weaveworks/flux/ssh"
 	"github.com/weaveworks/flux/update"
 )
 
 // API for clients connecting to the service.
 type ClientService interface {
-	Status(inst service.InstanceID) (service.Status, error)
-	ListServices(inst service.InstanceID, namespace string) ([]flux.ServiceStatus, error)
-	ListImages(service.InstanceID, update.ServiceSpec) ([]flux.ImageStatus, error)
-	UpdateImages(service.InstanceID, update.ReleaseSpec, update.Cause) (job.ID, error)
-	SyncNotify(service.InstanceID) error
-	JobStatus(service.InstanceID, job.ID) (job.Status, error)
-	SyncStatus(service.InstanceID, string) ([]string, error)
-	UpdatePolicies(service.InstanceID, policy.Updates, update.Cause) (job.ID, error)
-	History(service.InstanceID, update.ServiceSpec, time.Time, int64, time.Time) ([]history.Entry, error)
-	GetConfig(_ service.InstanceID, fingerprint string) (service.InstanceConfig, error)
-	SetConfig(service.InstanceID, service.InstanceConfig) error
-	PatchConfig(service.InstanceID, service.ConfigPatch) error
-	Export(inst service.InstanceID) ([]byte, error)
-	PublicSSHKey(inst service.InstanceID, regenerate bool) (ssh.PublicKey, error)
+	Status(ctx context.Context, inst service.InstanceID) (service.Status, error)
+	ListServices(ctx context.Context, inst service.InstanceID, namespace string) ([]flux.ServiceStatus, error)
+	ListImages(context.Context, service.InstanceID, update.ServiceSpec) ([]flux.ImageStatus, error)
+	EvaluateImage(context.Context, service.InstanceID, flux.ImageID) (update.Result, error)
+	ServiceTopology(context.Context, service.InstanceID) ([]flux.ServiceTopology, error)
+	UpdateImages(context.Context, service.InstanceID, update.ReleaseSpec, update.Cause) (job.ID, error)
+	PlanRelease(context.Context, service.InstanceID, update.ReleaseSpec) (update.Result, error)
+	SyncNotify(context.Context, service.InstanceID) error
+	// ResetGitToRemote gives up any local commits the daemon has
+	// that are no longer on the upstream branch (e.g., because it was
+	// force-pushed), returning the revision it was reset to.
+	ResetGitToRemote(context.Context, service.InstanceID) (string, error)
+	JobStatus(context.Context, service.InstanceID, job.ID) (job.Status, error)
+	JobLog(context.Context, service.InstanceID, job.ID) (job.Log, error)
+	WatchJob(ctx context.Context, _ service.InstanceID, _ job.ID, updates chan<- job.Status) error
+	SyncStatus(context.Context, service.InstanceID, string) ([]string, error)
+	// SyncHealth reports how well the daemon has kept the cluster in
+	// sync with the repo lately.
+	SyncHealth(context.Context, service.InstanceID) (remote.SyncHealth, error)
+	// ListResources reports all the resources the daemon syncs, not
+	// only workloads, and how each fared in the most recent sync.
+	ListResources(context.Context, service.InstanceID) ([]flux.ResourceStatus, error)
+	// SyncWait waits for a full sync to finish, and reports how it
+	// went; see remote.Platform.
+	SyncWait(context.Context, service.InstanceID, remote.SyncWaitRequest) (remote.SyncReport, error)
+	UpdatePolicies(ctx context.Context, _ service.InstanceID, _ policy.Updates, _ update.Cause, dryRun bool) (job.ID, error)
+	// ValidatePolicies checks policy updates without making them,
+	// returning the error UpdatePolicies would, if they're not valid.
+	ValidatePolicies(context.Context, service.InstanceID, policy.Updates) error
+	UpdateBatch(context.Context, service.InstanceID, update.BatchSpec, update.Cause) (job.ID, error)
+	// AddManifests writes the manifests for new resources to the
+	// repo, where its layout says they go, and commits them.
+	AddManifests(context.Context, service.InstanceID, update.AddSpec, update.Cause) (job.ID, error)
+	// Rollback puts services back as they were before their last
+	// release, or at an earlier revision, in a new commit.
+	Rollback(context.Context, service.InstanceID, update.RollbackSpec, update.Cause) (job.ID, error)
+	History(context.Context, service.InstanceID, update.ServiceSpec, time.Time, int64, time.Time) ([]history.Entry, error)
+	DeployedImages(context.Context, service.InstanceID, flux.ServiceID, time.Time) ([]history.DeployedImage, error)
+	// ServiceSummaries gives the most recent release and sync of
+	// every service, along with its automation state.
+	ServiceSummaries(context.Context, service.InstanceID) ([]history.ServiceSummary, error)
+	ReleaseNotes(context.Context, service.InstanceID, job.ID) (update.ReleaseNotes, error)
+	WatchEvents(ctx context.Context, _ service.InstanceID, events chan<- history.Event) error
+	GetConfig(ctx context.Context, _ service.InstanceID, fingerprint string) (service.InstanceConfig, error)
+	SetConfig(context.Context, service.InstanceID, service.InstanceConfig) error
+	PatchConfig(context.Context, service.InstanceID, service.ConfigPatch) error
+	ListRecycledConfigs(context.Context, service.InstanceID) ([]service.RecycledConfig, error)
+	RestoreConfig(ctx context.Context, _ service.InstanceID, index int) error
+	Export(ctx context.Context, inst service.InstanceID) ([]byte, error)
+	// ExportTo is like Export, but writes the config out as it
+	// arrives, rather than holding it all in memory.
+	ExportTo(ctx context.Context, inst service.InstanceID, w io.Writer) error
+	// ExportAt gives the manifests in the git repo as they were at a
+	// commit or tag, rather than what's running in the cluster.
+	ExportAt(ctx context.Context, inst service.InstanceID, ref string) ([]byte, error)
+	PublicSSHKey(ctx context.Context, inst service.InstanceID, regenerate bool) (ssh.PublicKey, error)
+	Check(ctx context.Context, inst service.InstanceID) (service.CheckReport, error)
+	ListWebhookSecrets(ctx context.Context, inst service.InstanceID) ([]service.WebhookSecret, error)
+	CreateWebhookSecret(ctx context.Context, inst service.InstanceID, hook string) (service.WebhookSecret, error)
+	DeleteWebhookSecret(ctx context.Context, inst service.InstanceID, hook string) error
+	// IssueDaemonToken makes a new token for the daemon to connect
+	// with, which replaces the oldest of those it has.
+	IssueDaemonToken(ctx context.Context, inst service.InstanceID) (service.DaemonToken, error)
+	// Promote starts releasing to each instance in turn, moving on
+	// once the release has rolled out and soaked; it returns the ID
+	// of the promotion.
+	Promote(ctx context.Context, inst service.InstanceID, spec service.PromotionSpec, cause update.Cause) (string, error)
+	ListPromotions(ctx context.Context, inst service.InstanceID) ([]service.Promotion, error)
+	CancelPromotion(ctx context.Context, inst service.InstanceID, id string) error
 }
 
 // API for daemons connecting to the service
@@ -36,9 +94,22 @@ type DaemonService interface {
 	RegisterDaemon(service.InstanceID, remote.Platform) error
 	IsDaemonConnected(service.InstanceID) error
 	LogEvent(service.InstanceID, history.Event) error
+	// MigratedTo gives the base URL of the service the instance has
+	// been migrated to, or the empty string if it hasn't been.
+	MigratedTo(service.InstanceID) (string, error)
+}
+
+// API for operating the service: moving instances from one service
+// to another, and testing the alerting for them
+type AdminService interface {
+	ExportInstance(context.Context, service.InstanceID) (instance.Migration, error)
+	ImportInstance(context.Context, service.InstanceID, instance.Migration) error
+	MigrateInstance(context.Context, service.InstanceID, service.MigrationTarget) error
+	InjectFault(context.Context, service.InstanceID, remote.FaultSpec) error
 }
 
 type FluxService interface {
 	ClientService
 	DaemonService
+	AdminService
 }
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+)
+
+// Authenticator puts a client's credentials into each request it
+// makes to the API.
+type Authenticator interface {
+	Authenticate(*http.Request)
+}
+
+// Validator is the server side of an authentication scheme: it
+// checks that a request carries acceptable credentials.
+type Validator interface {
+	Validate(*http.Request) error
+}
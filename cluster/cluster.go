@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"errors"
+	"io"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/ssh"
@@ -21,10 +22,16 @@ type Cluster interface {
 	SomeServices([]flux.ServiceID) ([]Service, error)
 	Ping() error
 	Export() ([]byte, error)
+	// ExportTo is like Export, but writes the config out as it goes
+	ExportTo(io.Writer) error
 	Sync(SyncDef) error
 	PublicSSHKey(regenerate bool) (ssh.PublicKey, error)
 }
 
+// StatusReady is the status of a service that is running what it's
+// been given to run; i.e., it's not part way through a rollout.
+const StatusReady = "ready"
+
 // Service describes a platform service, generally a floating IP with one or
 // more exposed ports that map to a load-balanced pool of instances. Eventually
 // this type will generalize to something of a lowest-common-denominator for
@@ -37,6 +44,17 @@ type Service struct {
 	Status   string            // A status summary for display
 
 	Containers ContainersOrExcuse
+	Rollout    Rollout
+}
+
+// Rollout describes what happens to a service's pods when it's
+// released: how many there are to replace, the strategy by which
+// they're replaced (e.g., "RollingUpdate"), and whether a disruption
+// budget limits how many can be down at once.
+type Rollout struct {
+	Pods             int
+	Strategy         string
+	DisruptionBudget bool
 }
 
 // A Container represents a container specification in a pod. The Name
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"strings"
+	"sync"
+)
+
+// KindFilter names kinds of resource that flux should leave alone;
+// for example, Secrets or CustomResourceDefinitions that are looked
+// after by some other tool. Excluded kinds are neither applied nor
+// deleted when syncing, and don't appear in exports; so they are not
+// compared with what's in the repo, e.g., when looking for drift.
+type KindFilter []string
+
+// ParseKindFilter makes a filter from a comma-separated list of
+// kinds, as given on the command line (or in the instance config).
+func ParseKindFilter(s string) KindFilter {
+	var f KindFilter
+	for _, kind := range strings.Split(s, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			f = append(f, kind)
+		}
+	}
+	return f
+}
+
+// Excludes says whether resources of the given kind should be left
+// alone. Kinds are compared without regard to case, so that
+// `secret` will do for `Secret`.
+func (f KindFilter) Excludes(kind string) bool {
+	for _, k := range f {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// SharedKindFilter holds a filter that may be replaced while it is in
+// use, e.g., when it is given anew in the instance config. A nil
+// *SharedKindFilter excludes nothing.
+type SharedKindFilter struct {
+	mu     sync.RWMutex
+	filter KindFilter
+}
+
+func NewSharedKindFilter(f KindFilter) *SharedKindFilter {
+	return &SharedKindFilter{filter: f}
+}
+
+// Get gives the filter currently held. It must not be modified.
+func (s *SharedKindFilter) Get() KindFilter {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter
+}
+
+// Set replaces the filter held.
+func (s *SharedKindFilter) Set(f KindFilter) {
+	s.mu.Lock()
+	s.filter = f
+	s.mu.Unlock()
+}
+
+// Excludes says whether the filter currently held excludes the kind
+// given.
+func (s *SharedKindFilter) Excludes(kind string) bool {
+	return s.Get().Excludes(kind)
+}
@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKindFilter(t *testing.T) {
+	for input, expected := range map[string]KindFilter{
+		"":                                  nil,
+		"Secret":                            KindFilter{"Secret"},
+		" Secret, CustomResourceDefinition": KindFilter{"Secret", "CustomResourceDefinition"},
+		"Secret,,":                          KindFilter{"Secret"},
+	} {
+		if got := ParseKindFilter(input); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: expected %#v, got %#v", input, expected, got)
+		}
+	}
+}
+
+func TestKindFilterExcludes(t *testing.T) {
+	f := KindFilter{"Secret", "customresourcedefinition"}
+	for kind, expected := range map[string]bool{
+		"Secret":                   true,
+		"secret":                   true,
+		"CustomResourceDefinition": true,
+		"Deployment":               false,
+		"":                         false,
+	} {
+		if got := f.Excludes(kind); got != expected {
+			t.Errorf("%q: expected %v, got %v", kind, expected, got)
+		}
+	}
+	if (KindFilter(nil)).Excludes("Secret") {
+		t.Error("expected empty filter to exclude nothing")
+	}
+}
+
+func TestSharedKindFilter(t *testing.T) {
+	if (*SharedKindFilter)(nil).Excludes("Secret") {
+		t.Error("expected nil shared filter to exclude nothing")
+	}
+	f := NewSharedKindFilter(KindFilter{"Secret"})
+	if !f.Excludes("Secret") {
+		t.Error("expected shared filter to exclude Secret")
+	}
+	f.Set(KindFilter{"ConfigMap"})
+	if f.Excludes("Secret") || !f.Excludes("configmap") {
+		t.Errorf("expected replaced filter to exclude only ConfigMap, got %v", f.Get())
+	}
+}
@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/resource"
+)
+
+// AppliedLabel is put on each resource flux applies when syncing.
+// Pruning only ever deletes resources with it, so that anything
+// created some other way (by hand, by another flux looking at other
+// files, ...) is left alone. It's a label rather than an annotation,
+// so it isn't taken for a policy, and so the resources flux looks
+// after can be selected, e.g., with kubectl.
+const AppliedLabel = kresource.PolicyPrefix + "applied"
+
+func (m *Manifests) MarkApplied(def []byte) ([]byte, error) {
+	var obj yaml.MapSlice
+	if err := yaml.Unmarshal(def, &obj); err != nil {
+		return nil, errors.Wrap(err, "decoding definition")
+	}
+	obj, err := setIn(obj, []string{"metadata", "labels", AppliedLabel}, "true")
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+func (m *Manifests) IsMarkedApplied(res resource.Resource) bool {
+	var obj struct {
+		Meta struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal(res.Bytes(), &obj); err != nil {
+		return false
+	}
+	return obj.Meta.Labels[AppliedLabel] == "true"
+}
@@ -0,0 +1,37 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+)
+
+func TestMarkApplied(t *testing.T) {
+	m := &Manifests{}
+	res, err := kresource.ParseMultidoc([]byte(configDeployment), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dep := res["Deployment demo/helloworld"]
+	if m.IsMarkedApplied(dep) {
+		t.Error("expected deployment not to be marked before applying")
+	}
+
+	def, err := m.MarkApplied(dep.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = kresource.ParseMultidoc(def, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	marked := res["Deployment demo/helloworld"]
+	if !m.IsMarkedApplied(marked) {
+		t.Errorf("expected deployment to be marked, got:\n%s", def)
+	}
+	// The mark isn't taken for a policy
+	if !reflect.DeepEqual(marked.Policy(), dep.Policy()) {
+		t.Errorf("expected policies %v to be unchanged, got %v", dep.Policy(), marked.Policy())
+	}
+}
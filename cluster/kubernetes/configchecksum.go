@@ -0,0 +1,217 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/resource"
+)
+
+// ConfigChecksumAnnotation is put in the pod template of resources
+// with the rollout-on-config-change policy. Since it changes when the
+// ConfigMaps and Secrets the pods refer to change, so does the pod
+// template, and Kubernetes replaces the pods.
+const ConfigChecksumAnnotation = kresource.PolicyPrefix + "config-checksum"
+
+// The parts of a pod controller (Deployment, DaemonSet, ...) that
+// refer to ConfigMaps and Secrets.
+type configReferences struct {
+	Meta struct {
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Template struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+			Spec struct {
+				Volumes []struct {
+					ConfigMap *struct {
+						Name string `yaml:"name"`
+					} `yaml:"configMap"`
+					Secret *struct {
+						SecretName string `yaml:"secretName"`
+					} `yaml:"secret"`
+				} `yaml:"volumes"`
+				Containers     []configContainer `yaml:"containers"`
+				InitContainers []configContainer `yaml:"initContainers"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+type configContainer struct {
+	Env []struct {
+		ValueFrom *struct {
+			ConfigMapKeyRef *struct {
+				Name string `yaml:"name"`
+			} `yaml:"configMapKeyRef"`
+			SecretKeyRef *struct {
+				Name string `yaml:"name"`
+			} `yaml:"secretKeyRef"`
+		} `yaml:"valueFrom"`
+	} `yaml:"env"`
+	EnvFrom []struct {
+		ConfigMapRef *struct {
+			Name string `yaml:"name"`
+		} `yaml:"configMapRef"`
+		SecretRef *struct {
+			Name string `yaml:"name"`
+		} `yaml:"secretRef"`
+	} `yaml:"envFrom"`
+}
+
+// resourceIDs gives the IDs of the ConfigMaps and Secrets referred to,
+// sorted so they can be hashed in a stable order. They're in the same
+// namespace as the resource, or the default namespace given, if it
+// doesn't say.
+func (r configReferences) resourceIDs(defaultNamespace string) []string {
+	ns := r.Meta.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	ids := map[string]struct{}{}
+	add := func(kind, name string) {
+		ids[fmt.Sprintf("%s %s/%s", kind, ns, name)] = struct{}{}
+	}
+
+	podSpec := r.Spec.Template.Spec
+	for _, v := range podSpec.Volumes {
+		if v.ConfigMap != nil {
+			add("ConfigMap", v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			add("Secret", v.Secret.SecretName)
+		}
+	}
+	for _, c := range append(podSpec.Containers, podSpec.InitContainers...) {
+		for _, e := range c.Env {
+			if e.ValueFrom == nil {
+				continue
+			}
+			if e.ValueFrom.ConfigMapKeyRef != nil {
+				add("ConfigMap", e.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if e.ValueFrom.SecretKeyRef != nil {
+				add("Secret", e.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, e := range c.EnvFrom {
+			if e.ConfigMapRef != nil {
+				add("ConfigMap", e.ConfigMapRef.Name)
+			}
+			if e.SecretRef != nil {
+				add("Secret", e.SecretRef.Name)
+			}
+		}
+	}
+
+	var sorted []string
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (m *Manifests) WithConfigChecksum(res resource.Resource, all map[string]resource.Resource) ([]byte, string, error) {
+	var refs configReferences
+	if err := yaml.Unmarshal(res.Bytes(), &refs); err != nil {
+		return nil, "", errors.Wrap(err, "decoding config references")
+	}
+
+	// Only the ConfigMaps and Secrets defined alongside the resource
+	// can be hashed; any that are created some other way (as
+	// Secrets often are) are passed over.
+	h := sha256.New()
+	var found bool
+	for _, id := range refs.resourceIDs(m.defaultNamespace()) {
+		config, ok := all[id]
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Fprintf(h, "%s\n", id)
+		h.Write(config.Bytes())
+		h.Write([]byte("\n"))
+	}
+	if !found {
+		return res.Bytes(), "", nil
+	}
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	def, err := setPodTemplateAnnotation(res.Bytes(), ConfigChecksumAnnotation, checksum)
+	if err != nil {
+		return nil, "", err
+	}
+	return def, checksum, nil
+}
+
+func (m *Manifests) AppliedConfigChecksum(res resource.Resource) string {
+	var refs configReferences
+	if err := yaml.Unmarshal(res.Bytes(), &refs); err != nil {
+		return ""
+	}
+	return refs.Spec.Template.Metadata.Annotations[ConfigChecksumAnnotation]
+}
+
+// setPodTemplateAnnotation puts an annotation in the pod template of
+// the definition given. The result is only for applying to the
+// cluster, not for writing back to files, so it's fine to re-encode
+// the whole thing rather than preserving its layout.
+func setPodTemplateAnnotation(def []byte, key, value string) ([]byte, error) {
+	var obj yaml.MapSlice
+	if err := yaml.Unmarshal(def, &obj); err != nil {
+		return nil, errors.Wrap(err, "decoding definition")
+	}
+	obj, err := setIn(obj, []string{"spec", "template", "metadata", "annotations", key}, value)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+// setIn sets the value at the path given, making any mappings along
+// the way that don't exist yet.
+func setIn(m yaml.MapSlice, path []string, value string) (yaml.MapSlice, error) {
+	key := path[0]
+	for i, item := range m {
+		if item.Key != key {
+			continue
+		}
+		if len(path) == 1 {
+			m[i].Value = value
+			return m, nil
+		}
+		var sub yaml.MapSlice
+		switch v := item.Value.(type) {
+		case yaml.MapSlice:
+			sub = v
+		case nil:
+			// e.g., `annotations:` with nothing after it
+		default:
+			return nil, errors.Errorf("expected %s to be a mapping", key)
+		}
+		sub, err := setIn(sub, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[i].Value = sub
+		return m, nil
+	}
+
+	if len(path) == 1 {
+		return append(m, yaml.MapItem{Key: key, Value: value}), nil
+	}
+	sub, err := setIn(nil, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return append(m, yaml.MapItem{Key: key, Value: sub}), nil
+}
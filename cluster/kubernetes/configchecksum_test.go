@@ -0,0 +1,112 @@
+package kubernetes
+
+import (
+	"testing"
+
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/resource"
+)
+
+const configDeployment = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld
+  namespace: demo
+  annotations:
+    flux.weave.works/rollout-on-config-change: "true"
+spec:
+  template:
+    metadata:
+      labels:
+        name: helloworld
+    spec:
+      volumes:
+      - name: config
+        configMap:
+          name: hello-config
+      - name: creds
+        secret:
+          secretName: not-in-repo
+      containers:
+      - name: greeter
+        image: quay.io/weaveworks/helloworld:master-a000001
+        envFrom:
+        - configMapRef:
+            name: hello-env
+`
+
+const configMaps = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hello-config
+  namespace: demo
+data:
+  greeting: hello
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hello-env
+  namespace: demo
+data:
+  LANG: en
+`
+
+func TestWithConfigChecksum(t *testing.T) {
+	m := &Manifests{}
+	all, err := kresource.ParseMultidoc([]byte(configDeployment+configMaps), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dep := all["Deployment demo/helloworld"]
+
+	def, checksum, err := m.WithConfigChecksum(dep, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum == "" {
+		t.Fatal("expected a checksum for a deployment that refers to config")
+	}
+
+	// What's applied carries the checksum
+	applied, err := kresource.ParseMultidoc(def, "applied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	appliedDep, ok := applied["Deployment demo/helloworld"]
+	if !ok {
+		t.Fatalf("expected definition with checksum to be the same resource, got %s", string(def))
+	}
+	if got := m.AppliedConfigChecksum(appliedDep); got != checksum {
+		t.Errorf("expected checksum %q in pod template, got %q", checksum, got)
+	}
+	// Not otherwise changed
+	if _, again, _ := m.WithConfigChecksum(appliedDep, all); again != checksum {
+		t.Errorf("expected the same checksum with it applied, got %q", again)
+	}
+
+	// A change to the config changes the checksum
+	changed, err := kresource.ParseMultidoc([]byte(configDeployment+configMaps+"  LC_ALL: en\n"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, newChecksum, err := m.WithConfigChecksum(changed["Deployment demo/helloworld"], changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newChecksum == checksum {
+		t.Error("expected checksum to change along with config")
+	}
+
+	// With no config in the repo, there's nothing to hash
+	alone := map[string]resource.Resource{"Deployment demo/helloworld": dep}
+	def, checksum, err = m.WithConfigChecksum(dep, alone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != "" || string(def) != string(dep.Bytes()) {
+		t.Errorf("expected definition to be left alone, got checksum %q", checksum)
+	}
+}
@@ -0,0 +1,256 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	apierrors "k8s.io/client-go/1.5/pkg/api/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+	"github.com/weaveworks/flux/resource"
+)
+
+// Custom resources (of kinds defined with a CustomResourceDefinition,
+// or a ThirdPartyResource) can run containers just as Deployments do,
+// but where the containers are given is up to whoever defined the
+// kind. A CustomKind says where that is, so that such resources can
+// be released. Each custom resource is a service in its own right,
+// identified by its namespace and name.
+
+// CustomKind describes a kind of custom resource that runs
+// containers. Containers may be given as a list of entries with a
+// name and an image, like those in a pod spec, or as images by
+// themselves, each standing for a container of the name given. Paths
+// are the fields from the top of the resource, separated by dots;
+// e.g., "spec.template.spec.containers".
+type CustomKind struct {
+	Kind string `yaml:"kind"`
+	// APIVersion and Resource are used to list resources of the
+	// kind; e.g., "example.com/v1" and "appdeployments".
+	APIVersion string `yaml:"apiVersion"`
+	Resource   string `yaml:"resource"`
+	// Containers is the path to a list of containers
+	Containers string `yaml:"containers,omitempty"`
+	// Images gives the path to the image for each container, by
+	// name
+	Images map[string]string `yaml:"images,omitempty"`
+}
+
+// CustomKinds are the custom kinds known, by kind.
+type CustomKinds map[string]CustomKind
+
+// ParseCustomKinds parses a YAML list of custom kinds.
+func ParseCustomKinds(def []byte) (CustomKinds, error) {
+	var list []CustomKind
+	if err := yaml.Unmarshal(def, &list); err != nil {
+		return nil, errors.Wrap(err, "decoding custom kinds")
+	}
+	kinds := CustomKinds{}
+	for i, k := range list {
+		switch {
+		case k.Kind == "":
+			return nil, fmt.Errorf("custom kind %d: no kind given", i+1)
+		case k.APIVersion == "" || k.Resource == "":
+			return nil, fmt.Errorf("custom kind %s: both apiVersion and resource must be given", k.Kind)
+		case k.Containers == "" && len(k.Images) == 0:
+			return nil, fmt.Errorf("custom kind %s: no containers or images given", k.Kind)
+		case podControllerKinds[k.Kind]:
+			return nil, fmt.Errorf("custom kind %s: already a kind known to flux", k.Kind)
+		}
+		if _, ok := kinds[k.Kind]; ok {
+			return nil, fmt.Errorf("custom kind %s given more than once", k.Kind)
+		}
+		kinds[k.Kind] = k
+	}
+	return kinds, nil
+}
+
+// LoadCustomKinds reads custom kinds from the file given.
+func LoadCustomKinds(path string) (CustomKinds, error) {
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCustomKinds(def)
+}
+
+func splitFieldPath(p string) []string {
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "{"), "}")
+	return strings.Split(strings.TrimPrefix(p, "."), ".")
+}
+
+// customContainer is a container found in a custom resource, along
+// with the path to its image.
+type customContainer struct {
+	cluster.Container
+	path []string
+}
+
+// containers finds the containers in a custom resource, as decoded
+// from YAML or JSON.
+func (k CustomKind) containers(obj interface{}) []customContainer {
+	var res []customContainer
+	if k.Containers != "" {
+		path := splitFieldPath(k.Containers)
+		list, _ := lookupField(obj, path).([]interface{})
+		for i, item := range list {
+			name, _ := lookupField(item, []string{"name"}).(string)
+			image, _ := lookupField(item, []string{"image"}).(string)
+			if name == "" || image == "" {
+				continue
+			}
+			res = append(res, customContainer{
+				Container: cluster.Container{Name: name, Image: image},
+				path:      append(append([]string{}, path...), strconv.Itoa(i), "image"),
+			})
+		}
+	}
+	var names []string
+	for name := range k.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := splitFieldPath(k.Images[name])
+		if image, ok := lookupField(obj, path).(string); ok && image != "" {
+			res = append(res, customContainer{
+				Container: cluster.Container{Name: name, Image: image},
+				path:      path,
+			})
+		}
+	}
+	return res
+}
+
+// lookupField follows the path through maps (as decoded from YAML or
+// from JSON) and lists, returning nil if there's nothing there.
+func lookupField(v interface{}, path []string) interface{} {
+	for _, field := range path {
+		switch value := v.(type) {
+		case map[interface{}]interface{}:
+			v = value[field]
+		case map[string]interface{}:
+			v = value[field]
+		case []interface{}:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil
+			}
+			v = value[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// updateImage looks for a custom resource with the container given,
+// using an image from the same repository as the new one, and if
+// there is one, updates its image. It reports whether it found one.
+func (kinds CustomKinds) updateImage(def []byte, container string, newImage flux.ImageID) ([]byte, bool, error) {
+	docs, err := splitDocuments(def)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, doc := range docs {
+		kind, ok := kinds[doc.manifest.Kind]
+		if !ok {
+			continue
+		}
+		var obj interface{}
+		if err := yaml.Unmarshal(def[doc.start:doc.end], &obj); err != nil {
+			return nil, false, errors.Wrap(err, "decoding custom resource")
+		}
+		if doc.item != nil {
+			obj = lookupField(obj, doc.item)
+		}
+		for _, c := range kind.containers(obj) {
+			current, err := flux.ParseImageID(c.Image)
+			if err != nil || c.Name != container || current.Repository() != newImage.Repository() {
+				continue
+			}
+			out, err := editDocument(def, doc, func(def []byte) ([]byte, error) {
+				edit, err := yamledit.Parse(def)
+				if err != nil {
+					return nil, err
+				}
+				if err := edit.Set(newImage.String(), c.path...); err != nil {
+					return nil, errors.Wrap(err, "updating image")
+				}
+				return edit.Bytes(), nil
+			})
+			return out, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// serviceID gives the service for a resource loaded from the
+// manifests, if it's of a custom kind.
+func (kinds CustomKinds) serviceID(res resource.Resource) (flux.ServiceID, bool) {
+	// The resource package doesn't know about custom kinds, so all
+	// there is to go on is the ID, which is "<kind> <namespace>/<name>"
+	id := res.ResourceID()
+	i := strings.Index(id, " ")
+	if i < 0 {
+		return "", false
+	}
+	if _, ok := kinds[id[:i]]; !ok {
+		return "", false
+	}
+	sid, err := flux.ParseServiceID(id[i+1:])
+	return sid, err == nil
+}
+
+// --- cluster
+
+type customResourceList struct {
+	Items []map[string]interface{} `json:"items"`
+}
+
+// customServices lists the resources of each custom kind in a
+// namespace, as services. A kind that the API server doesn't know
+// about, or that we're not allowed to see, has no resources.
+func (c *Cluster) customServices(namespace string) ([]cluster.Service, error) {
+	var res []cluster.Service
+	for _, kind := range c.customKinds {
+		body, err := c.client.CoreInterface.GetRESTClient().Get().
+			AbsPath("/apis", kind.APIVersion, "namespaces", namespace, kind.Resource).
+			DoRaw()
+		if err != nil {
+			if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "listing %s resources", kind.Kind)
+		}
+		var list customResourceList
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, errors.Wrapf(err, "decoding %s resources", kind.Kind)
+		}
+		for _, item := range list.Items {
+			name, _ := lookupField(item, []string{"metadata", "name"}).(string)
+			if name == "" {
+				continue
+			}
+			var containers []cluster.Container
+			for _, c := range kind.containers(item) {
+				containers = append(containers, c.Container)
+			}
+			res = append(res, cluster.Service{
+				ID:         flux.MakeServiceID(namespace, name),
+				Metadata:   map[string]string{"kind": kind.Kind},
+				Status:     StatusUnknown,
+				Containers: cluster.ContainersOrExcuse{Containers: containers},
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
+)
+
+const customKinds = `
+- kind: AppDeployment
+  apiVersion: example.com/v1
+  resource: appdeployments
+  containers: spec.template.spec.containers
+  images:
+    migrations: spec.migrations.image
+`
+
+const appDeployment = `apiVersion: example.com/v1
+kind: AppDeployment
+metadata:
+  name: helloworld
+  namespace: apps
+spec:
+  migrations:
+    image: quay.io/weaveworks/helloworld-migrations:master-a000001
+  template:
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+`
+
+func TestParseCustomKinds(t *testing.T) {
+	kinds, err := ParseCustomKinds([]byte(customKinds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CustomKinds{
+		"AppDeployment": {
+			Kind:       "AppDeployment",
+			APIVersion: "example.com/v1",
+			Resource:   "appdeployments",
+			Containers: "spec.template.spec.containers",
+			Images:     map[string]string{"migrations": "spec.migrations.image"},
+		},
+	}
+	if !reflect.DeepEqual(expected, kinds) {
+		t.Errorf("Expected:\n%#v\ngot:\n%#v", expected, kinds)
+	}
+
+	for _, def := range []string{
+		"- kind: AppDeployment\n  containers: spec.containers\n",
+		"- kind: AppDeployment\n  apiVersion: example.com/v1\n  resource: appdeployments\n",
+		"- kind: Deployment\n  apiVersion: extensions/v1beta1\n  resource: deployments\n  containers: spec.containers\n",
+	} {
+		if _, err := ParseCustomKinds([]byte(def)); err == nil {
+			t.Errorf("expected error parsing %q", def)
+		}
+	}
+}
+
+func TestCustomKindDefinedServices(t *testing.T) {
+	kinds, _ := ParseCustomKinds([]byte(customKinds))
+	dir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "helloworld.yaml")
+	if err := ioutil.WriteFile(path, []byte(appDeployment), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := (&Manifests{CustomKinds: kinds}).FindDefinedServices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[flux.ServiceID][]string{
+		flux.MakeServiceID("apps", "helloworld"): {path},
+	}
+	if !reflect.DeepEqual(expected, services) {
+		t.Errorf("Expected:\n%#v\ngot:\n%#v", expected, services)
+	}
+}
+
+func TestCustomKindUpdateDefinition(t *testing.T) {
+	kinds, _ := ParseCustomKinds([]byte(customKinds))
+	m := &Manifests{CustomKinds: kinds}
+
+	for container, image := range map[string]string{
+		"helloworld": "quay.io/weaveworks/helloworld:master-a000002",
+		"migrations": "quay.io/weaveworks/helloworld-migrations:master-a000002",
+	} {
+		id, _ := flux.ParseImageID(image)
+		out, err := m.UpdateDefinition([]byte(appDeployment), container, id)
+		if err != nil {
+			t.Errorf("%s: %v", container, err)
+			continue
+		}
+		old := strings.Replace(image, "master-a000002", "master-a000001", 1)
+		expected := strings.Replace(appDeployment, old, image, 1)
+		if string(out) != expected {
+			t.Errorf("%s: did not get expected result:\n\n%s\n\nInstead got:\n\n%s", container, expected, out)
+		}
+	}
+
+	other, _ := flux.ParseImageID("quay.io/weaveworks/other:1")
+	if _, err := m.UpdateDefinition([]byte(appDeployment), "helloworld", other); err == nil {
+		t.Error("expected an error when no container uses the image")
+	}
+}
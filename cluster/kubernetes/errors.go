@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/resource"
 )
 
 var ErrReplicationControllersDeprecated = flux.UserConfigProblem{
@@ -41,3 +42,20 @@ using kubectl).
 		},
 	}
 }
+
+func ImageStreamTriggerError(container string, from resource.ObjectReference) error {
+	return flux.UserConfigProblem{
+		&flux.BaseError{
+			Err: fmt.Errorf("container %q gets its image from %s %q", container, from.Kind, from.Name),
+			Help: `The container ` + container + ` is in a DeploymentConfig with an
+automatic ImageChange trigger. Openshift will replace its image with
+whatever ` + from.Kind + ` ` + from.Name + ` refers to, so there's no point
+in Flux changing it.
+
+To release a new image to this container, either update the image
+stream (e.g., with 'oc tag'), or remove the trigger (or set it to be
+not automatic) so that Flux can update the image itself.
+`,
+		},
+	}
+}
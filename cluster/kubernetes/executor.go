@@ -0,0 +1,241 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	v1batch "k8s.io/client-go/1.5/kubernetes/typed/batch/v1"
+	v1core "k8s.io/client-go/1.5/kubernetes/typed/core/v1"
+	api "k8s.io/client-go/1.5/pkg/api"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	batchv1 "k8s.io/client-go/1.5/pkg/apis/batch/v1"
+
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	fluxupdate "github.com/weaveworks/flux/update"
+)
+
+const (
+	// The secret for each worker holds these, and is mounted at
+	// workerJobMountPath
+	workerSpecKey      = "spec.json"
+	workerTokenKey     = "token"
+	workerJobMountPath = "/etc/fluxd/job"
+
+	workerLabel          = "flux-worker"
+	workerJobLabel       = "flux-job-id"
+	workerCheckInterval  = 5 * time.Second
+	defaultWorkerTimeout = 10 * time.Minute
+)
+
+// JobExecutorConfig says how to run the workers for jobs.
+type JobExecutorConfig struct {
+	Namespace      string
+	Image          string // an image with fluxd as its entrypoint
+	ServiceAccount string // may be empty, for the namespace's default
+	// Args are given to fluxd in the worker, along with the
+	// arguments saying which job to run; they need to include
+	// whatever fluxd needs to clone the repo and look in the
+	// registry.
+	Args         []string
+	Volumes      []v1.Volume
+	VolumeMounts []v1.VolumeMount
+	// ReportURL is the base URL of the daemon's API, as the workers
+	// can reach it (e.g., via a service).
+	ReportURL string
+	// Timeout is how long to wait for a worker to report, before
+	// giving up on it; zero means ten minutes.
+	Timeout time.Duration
+}
+
+// JobExecutor is a job.Executor that runs each job in a worker pod,
+// using a Kubernetes Job. The update spec (and a token to report
+// back with) is put in a secret for the worker to mount; the worker
+// runs the job, then reports its status to the daemon's API, which
+// passes it to Reports.
+type JobExecutor struct {
+	jobs    v1batch.JobInterface
+	secrets v1core.SecretInterface
+	config  JobExecutorConfig
+	reports *job.Reports
+}
+
+var _ job.Executor = &JobExecutor{}
+
+func NewJobExecutor(clientset workerClientset, reports *job.Reports, config JobExecutorConfig) (*JobExecutor, error) {
+	if config.Image == "" {
+		return nil, errors.New("no image given for job workers")
+	}
+	if config.ReportURL == "" {
+		return nil, errors.New("no URL given for job workers to report to")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultWorkerTimeout
+	}
+	return &JobExecutor{
+		jobs:    clientset.Batch().Jobs(config.Namespace),
+		secrets: clientset.Core().Secrets(config.Namespace),
+		config:  config,
+		reports: reports,
+	}, nil
+}
+
+// The parts of *k8sclient.Clientset the executor uses
+type workerClientset interface {
+	Batch() v1batch.BatchInterface
+	Core() v1core.CoreInterface
+}
+
+func (e *JobExecutor) Execute(ctx context.Context, id job.ID, spec fluxupdate.Spec, logger log.Logger) (history.CommitEventMetadata, error) {
+	var none history.CommitEventMetadata
+
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return none, errors.Wrap(err, "encoding update spec for worker")
+	}
+	token, err := newReportToken()
+	if err != nil {
+		return none, err
+	}
+
+	reported := e.reports.Expect(id, token)
+	defer e.reports.Forget(id)
+
+	name := workerName(id)
+	secret := &v1.Secret{
+		ObjectMeta: e.workerMeta(name, id),
+		Data: map[string][]byte{
+			workerSpecKey:  specBytes,
+			workerTokenKey: []byte(token),
+		},
+	}
+	if _, err := e.secrets.Create(secret); err != nil {
+		return none, errors.Wrap(err, "creating secret for worker")
+	}
+	defer e.secrets.Delete(name, &api.DeleteOptions{})
+
+	if _, err := e.jobs.Create(e.workerJob(name, id)); err != nil {
+		return none, errors.Wrap(err, "creating worker")
+	}
+	defer e.deleteWorker(name, logger)
+	logger.Log("worker", name, "namespace", e.config.Namespace)
+
+	timeout := time.NewTimer(e.config.Timeout)
+	defer timeout.Stop()
+	check := time.NewTicker(workerCheckInterval)
+	defer check.Stop()
+	for {
+		select {
+		case status := <-reported:
+			if status.StatusString != job.StatusSucceeded {
+				return none, errors.New(status.Err)
+			}
+			return status.Result, nil
+		case <-check.C:
+			// A worker that fails before it can report (e.g.,
+			// because its image can't be pulled) won't ever report;
+			// so, keep an eye on it.
+			w, err := e.jobs.Get(name)
+			if err != nil {
+				logger.Log("worker", name, "err", errors.Wrap(err, "checking worker"))
+				continue
+			}
+			if w.Status.Failed > 0 {
+				return none, errors.Errorf("worker %s failed without reporting; see the logs of its pods for why", name)
+			}
+		case <-timeout.C:
+			return none, errors.Errorf("worker %s did not report within %s", name, e.config.Timeout)
+		case <-ctx.Done():
+			return none, ctx.Err()
+		}
+	}
+}
+
+func (e *JobExecutor) deleteWorker(name string, logger log.Logger) {
+	// Delete the pods along with the job
+	orphan := false
+	if err := e.jobs.Delete(name, &api.DeleteOptions{OrphanDependents: &orphan}); err != nil {
+		logger.Log("worker", name, "err", errors.Wrap(err, "deleting worker"))
+	}
+}
+
+func (e *JobExecutor) workerMeta(name string, id job.ID) v1.ObjectMeta {
+	return v1.ObjectMeta{
+		Name:      name,
+		Namespace: e.config.Namespace,
+		Labels: map[string]string{
+			workerLabel:    "true",
+			workerJobLabel: string(id),
+		},
+	}
+}
+
+// workerJob makes the Kubernetes Job that runs a flux job. The pods
+// aren't restarted; if the worker fails, the job fails.
+func (e *JobExecutor) workerJob(name string, id job.ID) *batchv1.Job {
+	meta := e.workerMeta(name, id)
+	parallelism, completions := int32(1), int32(1)
+	deadline := int64(e.config.Timeout / time.Second)
+
+	args := append([]string{
+		"--run-job=" + string(id),
+		"--run-job-spec=" + filepath.Join(workerJobMountPath, workerSpecKey),
+		"--run-job-token-file=" + filepath.Join(workerJobMountPath, workerTokenKey),
+		"--run-job-report-url=" + e.config.ReportURL,
+	}, e.config.Args...)
+
+	volumes := append([]v1.Volume{{
+		Name: "job",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: name},
+		},
+	}}, e.config.Volumes...)
+	mounts := append([]v1.VolumeMount{{
+		Name:      "job",
+		MountPath: workerJobMountPath,
+		ReadOnly:  true,
+	}}, e.config.VolumeMounts...)
+
+	return &batchv1.Job{
+		ObjectMeta: meta,
+		Spec: batchv1.JobSpec{
+			Parallelism:           &parallelism,
+			Completions:           &completions,
+			ActiveDeadlineSeconds: &deadline,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{Labels: meta.Labels},
+				Spec: v1.PodSpec{
+					RestartPolicy:      v1.RestartPolicyNever,
+					ServiceAccountName: e.config.ServiceAccount,
+					Volumes:            volumes,
+					Containers: []v1.Container{{
+						Name:         "worker",
+						Image:        e.config.Image,
+						Args:         args,
+						VolumeMounts: mounts,
+					}},
+				},
+			},
+		},
+	}
+}
+
+// Job IDs are GUIDs, which make acceptable (if not pretty) names.
+func workerName(id job.ID) string {
+	return fmt.Sprintf("flux-job-%s", id)
+}
+
+func newReportToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating worker token")
+	}
+	return hex.EncodeToString(b), nil
+}
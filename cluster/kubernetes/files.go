@@ -1,6 +1,9 @@
 package kubernetes
 
 import (
+	"path/filepath"
+	"sort"
+
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
@@ -13,7 +16,7 @@ import (
 // specified namespace and name) to the paths of resource definition
 // files.
 func (c *Manifests) FindDefinedServices(path string) (map[flux.ServiceID][]string, error) {
-	objects, err := resource.Load(path)
+	objects, err := c.load(path)
 	if err != nil {
 		return nil, errors.Wrap(err, "loading resources")
 	}
@@ -30,6 +33,16 @@ func (c *Manifests) FindDefinedServices(path string) (map[flux.ServiceID][]strin
 		templates []template
 	)
 
+	addTemplate := func(source, namespace string, t *resource.PodTemplate) {
+		templates = append(templates, template{source, namespace, t})
+		for _, service := range services {
+			if namespace == service.Meta.Namespace && matches(service, t) {
+				sid := service.ServiceID()
+				result[sid] = append(result[sid], source)
+			}
+		}
+	}
+
 	for _, obj := range objects {
 		switch res := obj.(type) {
 		case *resource.Service:
@@ -41,13 +54,19 @@ func (c *Manifests) FindDefinedServices(path string) (map[flux.ServiceID][]strin
 				}
 			}
 		case *resource.Deployment:
-			source := res.Source()
-			templates = append(templates, template{source, res.Meta.Namespace, &res.Spec.Template})
-			for _, service := range services {
-				if res.Meta.Namespace == service.Meta.Namespace && matches(service, &res.Spec.Template) {
-					sid := service.ServiceID()
-					result[sid] = append(result[sid], source)
-				}
+			addTemplate(res.Source(), res.Meta.Namespace, &res.Spec.Template)
+		case *resource.DeploymentConfig:
+			addTemplate(res.Source(), res.Meta.Namespace, &res.Spec.Template)
+		case *resource.DaemonSet:
+			addTemplate(res.Source(), res.Meta.Namespace, &res.Spec.Template)
+		case *resource.StatefulSet:
+			addTemplate(res.Source(), res.Meta.Namespace, &res.Spec.Template)
+		case *resource.CronJob:
+			addTemplate(res.Source(), res.Meta.Namespace, &res.Spec.JobTemplate.Spec.Template)
+		default:
+			// A custom resource is a service by itself
+			if sid, ok := c.CustomKinds.serviceID(obj); ok {
+				result[sid] = append(result[sid], obj.Source())
 			}
 		}
 	}
@@ -71,3 +90,82 @@ func matches(s *resource.Service, t *resource.PodTemplate) bool {
 	}
 	return true
 }
+
+// ServiceTopology finds all the services defined under the directory
+// given, and the workloads (deployments, deployment configs,
+// daemonsets, statefulsets and cronjobs) selected by each. Files are
+// given relative to the directory.
+func (c *Manifests) ServiceTopology(path string) ([]flux.ServiceTopology, error) {
+	objects, err := c.load(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading resources")
+	}
+
+	type workload struct {
+		flux.Workload
+		namespace string // as given, for matching
+		*resource.PodTemplate
+	}
+
+	var (
+		services  []*resource.Service
+		workloads []workload
+	)
+	relative := func(source string) string {
+		if rel, err := filepath.Rel(path, source); err == nil {
+			return rel
+		}
+		return source
+	}
+	addWorkload := func(kind, namespace, name, source string, t *resource.PodTemplate) {
+		ns := namespace
+		if ns == "" {
+			ns = "default"
+		}
+		workloads = append(workloads, workload{flux.Workload{
+			Kind:      kind,
+			Namespace: ns,
+			Name:      name,
+			File:      relative(source),
+		}, namespace, t})
+	}
+
+	for _, obj := range objects {
+		switch res := obj.(type) {
+		case *resource.Service:
+			services = append(services, res)
+		case *resource.Deployment:
+			addWorkload(res.Kind, res.Meta.Namespace, res.Meta.Name, res.Source(), &res.Spec.Template)
+		case *resource.DeploymentConfig:
+			addWorkload(res.Kind, res.Meta.Namespace, res.Meta.Name, res.Source(), &res.Spec.Template)
+		case *resource.DaemonSet:
+			addWorkload(res.Kind, res.Meta.Namespace, res.Meta.Name, res.Source(), &res.Spec.Template)
+		case *resource.StatefulSet:
+			addWorkload(res.Kind, res.Meta.Namespace, res.Meta.Name, res.Source(), &res.Spec.Template)
+		case *resource.CronJob:
+			addWorkload(res.Kind, res.Meta.Namespace, res.Meta.Name, res.Source(), &res.Spec.JobTemplate.Spec.Template)
+		}
+	}
+
+	var result []flux.ServiceTopology
+	for _, service := range services {
+		topo := flux.ServiceTopology{
+			ID:   service.ServiceID(),
+			File: relative(service.Source()),
+		}
+		for _, w := range workloads {
+			if w.namespace == service.Meta.Namespace && matches(service, w.PodTemplate) {
+				topo.Workloads = append(topo.Workloads, w.Workload)
+			}
+		}
+		result = append(result, topo)
+	}
+	sort.Sort(topologyByID(result))
+	return result, nil
+}
+
+type topologyByID []flux.ServiceTopology
+
+func (t topologyByID) Len() int           { return len(t) }
+func (t topologyByID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t topologyByID) Less(i, j int) bool { return t[i].ID < t[j].ID }
@@ -1,9 +1,12 @@
 package kubernetes
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
 )
 
@@ -24,3 +27,74 @@ func TestDefinedServices(t *testing.T) {
 		t.Errorf("Expected:\n%#v\ngot:\n%#v\n", testfiles.ServiceMap(dir), services)
 	}
 }
+
+func TestServiceTopology(t *testing.T) {
+	dir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+
+	if err := testfiles.WriteTestFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	// A daemonset, and a service that selects it
+	extra := map[string]string{
+		"logging-ds.yaml": `apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: logging
+spec:
+  template:
+    metadata:
+      labels:
+        app: logging
+    spec:
+      containers:
+      - name: fluentd
+        image: fluent/fluentd:v0.12
+`,
+		"logging-svc.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: logging
+spec:
+  ports:
+    - port: 24224
+  selector:
+    app: logging
+`,
+	}
+	for name, content := range extra {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topology, err := (&Manifests{}).ServiceTopology(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := func(name string) flux.ServiceTopology {
+		return flux.ServiceTopology{
+			ID:   flux.MakeServiceID("default", name),
+			File: name + "-svc.yaml",
+			Workloads: []flux.Workload{
+				{Kind: "Deployment", Namespace: "default", Name: name, File: name + "-deploy.yaml"},
+			},
+		}
+	}
+	expected := []flux.ServiceTopology{
+		deployment("helloworld"),
+		deployment("locked-service"),
+		{
+			ID:   flux.MakeServiceID("default", "logging"),
+			File: "logging-svc.yaml",
+			Workloads: []flux.Workload{
+				{Kind: "DaemonSet", Namespace: "default", Name: "logging", File: "logging-ds.yaml"},
+			},
+		},
+		deployment("test-service"),
+	}
+	if !reflect.DeepEqual(expected, topology) {
+		t.Errorf("Expected:\n%#v\ngot:\n%#v\n", expected, topology)
+	}
+}
@@ -0,0 +1,253 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux"
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+	"github.com/weaveworks/flux/policy"
+)
+
+// ServiceAnnotation, in the metadata of a chart, gives the service
+// (as namespace/name) that the chart is deployed as. Without it, the
+// service is named after the chart, in the namespace configured.
+const ServiceAnnotation = "helm.flux.weave.works/service"
+
+// chart is a chart found in the repo, along with the service it's
+// deployed as.
+type chart struct {
+	dir      string
+	service  flux.ServiceID
+	metadata metadata
+}
+
+// metadata is the part of a chart's Chart.yaml that's of interest.
+type metadata struct {
+	Name        string            `yaml:"name"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+func parseMetadata(def []byte) (metadata, error) {
+	var md metadata
+	if err := yaml.Unmarshal(def, &md); err != nil {
+		return md, errors.Wrap(err, "decoding chart metadata")
+	}
+	return md, nil
+}
+
+// findCharts finds the charts under the directory given. A chart is
+// any directory with a Chart.yaml in it; the charts that it depends
+// on, in its own directory, are part of it, so aren't looked for.
+func (m *Manifests) findCharts(root string) ([]chart, error) {
+	var charts []chart
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		def, err := ioutil.ReadFile(filepath.Join(path, chartFile))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		md, err := parseMetadata(def)
+		if err != nil {
+			return errors.Wrapf(err, "chart in %s", path)
+		}
+		service, err := m.serviceFor(md)
+		if err != nil {
+			return errors.Wrapf(err, "chart in %s", path)
+		}
+		charts = append(charts, chart{dir: path, service: service, metadata: md})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding charts")
+	}
+	return charts, nil
+}
+
+func (m *Manifests) serviceFor(md metadata) (flux.ServiceID, error) {
+	if s, ok := md.Annotations[ServiceAnnotation]; ok {
+		return flux.ParseServiceID(s)
+	}
+	if md.Name == "" {
+		return "", errors.New("chart has no name")
+	}
+	return flux.MakeServiceID(m.namespace(), md.Name), nil
+}
+
+// FindDefinedServices finds the charts under the directory given, and
+// returns a map of the services they're deployed as to the values
+// files in which their images are given.
+func (m *Manifests) FindDefinedServices(path string) (map[flux.ServiceID][]string, error) {
+	return m.chartFiles(path, valuesFile)
+}
+
+// FindPolicyFiles finds the charts under the directory given, and
+// returns a map of the services they're deployed as to the metadata
+// files in which their policies are kept.
+func (m *Manifests) FindPolicyFiles(path string) (map[flux.ServiceID][]string, error) {
+	return m.chartFiles(path, chartFile)
+}
+
+func (m *Manifests) chartFiles(path, file string) (map[flux.ServiceID][]string, error) {
+	charts, err := m.findCharts(path)
+	if err != nil {
+		return nil, err
+	}
+	result := map[flux.ServiceID][]string{}
+	for _, c := range charts {
+		p := filepath.Join(c.dir, file)
+		if _, err := os.Stat(p); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		result[c.service] = append(result[c.service], p)
+	}
+	return result, nil
+}
+
+// ServiceTopology reports the service for each chart. What workloads
+// a chart has is only known once Helm renders it, so none are given.
+func (m *Manifests) ServiceTopology(path string) ([]flux.ServiceTopology, error) {
+	charts, err := m.findCharts(path)
+	if err != nil {
+		return nil, err
+	}
+	var result []flux.ServiceTopology
+	for _, c := range charts {
+		file := filepath.Join(c.dir, chartFile)
+		if rel, err := filepath.Rel(path, file); err == nil {
+			file = rel
+		}
+		result = append(result, flux.ServiceTopology{ID: c.service, File: file})
+	}
+	sort.Sort(topologyByID(result))
+	return result, nil
+}
+
+type topologyByID []flux.ServiceTopology
+
+func (t topologyByID) Len() int           { return len(t) }
+func (t topologyByID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t topologyByID) Less(i, j int) bool { return t[i].ID < t[j].ID }
+
+func (m *Manifests) ServicesWithPolicy(root string, p policy.Policy) (policy.ServiceMap, error) {
+	charts, err := m.findCharts(root)
+	if err != nil {
+		return nil, err
+	}
+	// As with a service defined in more than one file, a service
+	// deployed from more than one chart is passed over
+	count := map[flux.ServiceID]int{}
+	for _, c := range charts {
+		count[c.service]++
+	}
+	result := map[flux.ServiceID]policy.Set{}
+	defaults := m.PolicyDefaults.Get()
+	for _, c := range charts {
+		if count[c.service] != 1 {
+			continue
+		}
+		namespace, _ := c.service.Components()
+		if ps := defaults.Apply(namespace, annotatedPolicies(c.metadata)); ps.Contains(p) {
+			result[c.service] = ps
+		}
+	}
+	return result, nil
+}
+
+// annotatedPolicies gives the policies annotated in a chart's
+// metadata, as they are written.
+func annotatedPolicies(md metadata) map[policy.Policy]string {
+	policies := map[policy.Policy]string{}
+	for k, v := range md.Annotations {
+		if strings.HasPrefix(k, kresource.PolicyPrefix) {
+			policies[policy.Policy(strings.TrimPrefix(k, kresource.PolicyPrefix))] = v
+		}
+	}
+	return policies
+}
+
+// PoliciesIn gives the policies annotated in a chart's metadata.
+func (m *Manifests) PoliciesIn(def []byte) (policy.Set, error) {
+	md, err := parseMetadata(def)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Set(annotatedPolicies(md)), nil
+}
+
+// UpdatePolicies changes the annotations in a chart's metadata (i.e.,
+// its Chart.yaml) to apply the policy update given, leaving the rest
+// of the file as it was.
+func (m *Manifests) UpdatePolicies(def []byte, update policy.Update) ([]byte, error) {
+	md, err := parseMetadata(def)
+	if err != nil {
+		return nil, err
+	}
+	newAnnotations := map[string]string{}
+	for k, v := range md.Annotations {
+		newAnnotations[k] = v
+	}
+	for p, v := range update.Add {
+		newAnnotations[kresource.PolicyPrefix+string(p)] = v
+	}
+	for p := range update.Remove {
+		delete(newAnnotations, kresource.PolicyPrefix+string(p))
+	}
+
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	if len(newAnnotations) == 0 {
+		if err := doc.Remove("annotations"); err != nil {
+			return nil, errors.Wrap(err, "removing annotations")
+		}
+		return doc.Bytes(), nil
+	}
+	for k := range md.Annotations {
+		if _, ok := newAnnotations[k]; ok {
+			continue
+		}
+		if err := doc.Remove("annotations", k); err != nil {
+			return nil, errors.Wrapf(err, "removing annotation %s", k)
+		}
+	}
+	// New annotations are added in order, so it doesn't depend on
+	// how the map is iterated
+	var keys []string
+	for k := range newAnnotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := newAnnotations[k]
+		if old, ok := md.Annotations[k]; ok && old == v {
+			continue
+		}
+		if err := doc.Set(v, "annotations", k); err != nil {
+			return nil, errors.Wrapf(err, "setting annotation %s", k)
+		}
+	}
+	return doc.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+// Package helm interprets a repo of Helm charts as manifests. Rather
+// than Kubernetes resources, the repo holds charts, each of which is
+// deployed (by Helm) as a service; releasing an image means changing
+// the image given in the chart's values, and policies are kept as
+// annotations in the chart's metadata.
+package helm
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux"
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/resource"
+)
+
+const (
+	chartFile  = "Chart.yaml"
+	valuesFile = "values.yaml"
+)
+
+type Manifests struct {
+	// Namespace is where services deployed from charts are taken to
+	// be, unless a chart says otherwise. If empty, it's "default".
+	Namespace string
+	// ImagePaths says where in a chart's values the image for each
+	// container (by name) is given. A container not mentioned is
+	// taken to use DefaultImagePath.
+	ImagePaths map[string]ImagePath
+	// PolicyDefaults are policies that apply to all the services in
+	// a namespace, unless a chart's annotations say otherwise.
+	PolicyDefaults *policy.SharedDefaults
+}
+
+// FindDefinedServices, FindPolicyFiles, ServiceTopology,
+// UpdatePolicies and ServicesWithPolicy in charts.go
+
+// UpdateDefinition in values.go
+
+// LoadManifests finds no resources to apply, since it's Helm rather
+// than fluxd that applies charts.
+func (m *Manifests) LoadManifests(paths ...string) (map[string]resource.Resource, error) {
+	return map[string]resource.Resource{}, nil
+}
+
+// ParseManifests parses resources as exported from the cluster, which
+// are Kubernetes resources whatever's in the repo.
+func (m *Manifests) ParseManifests(allDefs []byte) (map[string]resource.Resource, error) {
+	return kresource.ParseMultidoc(allDefs, "exported")
+}
+
+// CheckPullPolicy finds no problem, since how (or whether) the pull
+// policy is given in a chart's values is up to the chart.
+func (m *Manifests) CheckPullPolicy(def []byte, container string, image flux.ImageID) ([]byte, string, error) {
+	return def, "", nil
+}
+
+// ValidateUpdate checks that a chart's metadata or values still parse
+// after being changed, and that the metadata still names the same
+// chart. What the values mean is up to the chart, so there's no more
+// to check without rendering it.
+func (m *Manifests) ValidateUpdate(before, after []byte) error {
+	var was, is map[string]interface{}
+	if err := yaml.Unmarshal(before, &was); err != nil {
+		return errors.Wrap(err, "parsing file before update")
+	}
+	if err := yaml.Unmarshal(after, &is); err != nil {
+		return errors.Wrap(err, "updated file does not parse")
+	}
+	if name, ok := was["name"].(string); ok && is["name"] != name {
+		return fmt.Errorf("updated file no longer has name %q", name)
+	}
+	return nil
+}
+
+// SwitchSelector is not supported, since which pods a chart's
+// services select is up to the chart.
+func (m *Manifests) SwitchSelector(serviceDef, workloadDef []byte) ([]byte, error) {
+	return nil, errors.New("switching a service between workloads is not supported for Helm charts")
+}
+
+func (m *Manifests) WithConfigChecksum(res resource.Resource, all map[string]resource.Resource) ([]byte, string, error) {
+	return res.Bytes(), "", nil
+}
+
+func (m *Manifests) AppliedConfigChecksum(res resource.Resource) string {
+	return ""
+}
+
+// MarkApplied leaves the definition as it is, since what a chart
+// creates is up to the chart; so nothing is ever pruned.
+func (m *Manifests) MarkApplied(def []byte) ([]byte, error) {
+	return def, nil
+}
+
+func (m *Manifests) IsMarkedApplied(res resource.Resource) bool {
+	return false
+}
+
+func (m *Manifests) namespace() string {
+	if m.Namespace == "" {
+		return "default"
+	}
+	return m.Namespace
+}
@@ -0,0 +1,215 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
+	"github.com/weaveworks/flux/policy"
+)
+
+const chartYAML = `name: helloworld
+version: 0.1.0
+annotations:
+  flux.weave.works/automated: "true"
+`
+
+const valuesYAML = `# Default values for helloworld
+replicaCount: 1
+image:
+  repository: quay.io/weaveworks/helloworld
+  tag: master-a000001 # the tag
+  pullPolicy: IfNotPresent
+sidecar:
+  image: quay.io/weaveworks/sidecar:1
+`
+
+func writeCharts(t *testing.T, dir string) {
+	for path, content := range map[string]string{
+		"helloworld/Chart.yaml":                chartYAML,
+		"helloworld/values.yaml":               valuesYAML,
+		"helloworld/templates/deployment.yaml": "kind: Deployment\n",
+		"helloworld/charts/redis/Chart.yaml":   "name: redis\n",
+		"helloworld/charts/redis/values.yaml":  "image: redis:3\n",
+		"locked/Chart.yaml":                    "name: locked\nannotations:\n  helm.flux.weave.works/service: apps/other\n  flux.weave.works/locked: \"true\"\n",
+		"locked/values.yaml":                   "image:\n  repository: other\n  tag: \"1\"\n",
+		"no-values/Chart.yaml":                 "name: no-values\n",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindDefinedServices(t *testing.T) {
+	dir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	writeCharts(t, dir)
+
+	m := &Manifests{}
+	services, err := m.FindDefinedServices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The chart helloworld depends on is part of it, and a chart
+	// without values has no images to release
+	expected := map[flux.ServiceID][]string{
+		flux.MakeServiceID("default", "helloworld"): {filepath.Join(dir, "helloworld/values.yaml")},
+		flux.MakeServiceID("apps", "other"):         {filepath.Join(dir, "locked/values.yaml")},
+	}
+	if !reflect.DeepEqual(expected, services) {
+		t.Errorf("Expected:\n%#v\ngot:\n%#v", expected, services)
+	}
+
+	policyFiles, err := m.FindPolicyFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := policyFiles[flux.MakeServiceID("default", "helloworld")]; len(files) != 1 || files[0] != filepath.Join(dir, "helloworld/Chart.yaml") {
+		t.Errorf("expected policies for helloworld in its Chart.yaml, got %v", files)
+	}
+
+	automated, err := m.ServicesWithPolicy(dir, policy.Automated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := automated[flux.MakeServiceID("default", "helloworld")]; !ok || len(automated) != 1 {
+		t.Errorf("expected only helloworld to be automated, got %v", automated)
+	}
+
+	m.PolicyDefaults = policy.NewSharedDefaults(policy.NamespaceDefaults{"apps": policy.Set{policy.Automated: "true"}})
+	automated, err = m.ServicesWithPolicy(dir, policy.Automated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := automated[flux.MakeServiceID("apps", "other")]
+	if len(automated) != 2 || !other.Contains(policy.Automated) || !other.Contains(policy.Locked) {
+		t.Errorf("expected apps/other to be automated by default, and keep its own policies, got %v", automated)
+	}
+}
+
+func TestUpdateDefinition(t *testing.T) {
+	m := &Manifests{
+		ImagePaths: map[string]ImagePath{
+			"sidecar": {Image: "sidecar.image"},
+		},
+	}
+
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	out, err := m.UpdateDefinition([]byte(valuesYAML), "helloworld", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Default values for helloworld
+replicaCount: 1
+image:
+  repository: quay.io/weaveworks/helloworld
+  tag: master-a000002 # the tag
+  pullPolicy: IfNotPresent
+sidecar:
+  image: quay.io/weaveworks/sidecar:1
+`
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	image, _ = flux.ParseImageID("quay.io/weaveworks/sidecar:2")
+	out, err = m.UpdateDefinition([]byte(valuesYAML), "sidecar", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `# Default values for helloworld
+replicaCount: 1
+image:
+  repository: quay.io/weaveworks/helloworld
+  tag: master-a000001 # the tag
+  pullPolicy: IfNotPresent
+sidecar:
+  image: quay.io/weaveworks/sidecar:2
+`
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	// A container without a mapping is taken to use the default
+	// path, which here has the image of another container
+	other, _ := flux.ParseImageID("quay.io/weaveworks/other:2")
+	if _, err := m.UpdateDefinition([]byte(valuesYAML), "other", other); err == nil {
+		t.Error("expected an error updating to an image for another repository")
+	}
+}
+
+func TestUpdatePolicies(t *testing.T) {
+	m := &Manifests{}
+	out, err := m.UpdatePolicies([]byte(chartYAML), policy.Update{
+		Add:    policy.Set{policy.Locked: "true"},
+		Remove: policy.Set{policy.Automated: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `name: helloworld
+version: 0.1.0
+annotations:
+  flux.weave.works/locked: "true"
+`
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	out, err = m.UpdatePolicies(out, policy.Update{
+		Remove: policy.Set{policy.Locked: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `name: helloworld
+version: 0.1.0
+`
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+}
+
+func TestParseImagePath(t *testing.T) {
+	for s, expected := range map[string]ImagePath{
+		"helloworld=image.repository:image.tag": {Repository: "image.repository", Tag: "image.tag"},
+		"sidecar=sidecar.image":                 {Image: "sidecar.image"},
+	} {
+		_, p, err := ParseImagePath(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if p != expected {
+			t.Errorf("%s: expected %+v, got %+v", s, expected, p)
+		}
+	}
+	for _, s := range []string{"image.tag", "=image", "helloworld=", "helloworld=image.repository:"} {
+		if _, _, err := ParseImagePath(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	m := &Manifests{}
+	before := []byte("name: helloworld\nversion: 0.1.0\n")
+	if err := m.ValidateUpdate(before, []byte("name: helloworld\nversion: 0.1.0\nannotations:\n  flux.weave.works/automated: \"true\"\n")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := m.ValidateUpdate(before, []byte("name: goodbyeworld\nversion: 0.1.0\n")); err == nil {
+		t.Error("expected error for renamed chart")
+	}
+	if err := m.ValidateUpdate(before, []byte("name: helloworld\n version: 0.1.0\n")); err == nil {
+		t.Error("expected error for broken YAML")
+	}
+}
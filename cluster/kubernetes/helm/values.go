@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+)
+
+// ImagePath says where in a chart's values a container's image is
+// given: either the whole image reference in one value, or the image
+// name and tag in two. Each is a path through the values, with the
+// keys separated by dots; e.g., "image.tag".
+type ImagePath struct {
+	Image      string
+	Repository string
+	Tag        string
+}
+
+// DefaultImagePath is where charts made with `helm create` give their
+// image.
+var DefaultImagePath = ImagePath{Repository: "image.repository", Tag: "image.tag"}
+
+// ParseImagePath parses a mapping of container to image path, given
+// as "container=path.to.repository:path.to.tag", or
+// "container=path.to.image".
+func ParseImagePath(s string) (string, ImagePath, error) {
+	eq := strings.Index(s, "=")
+	if eq <= 0 {
+		return "", ImagePath{}, fmt.Errorf("expected container=path, got %q", s)
+	}
+	container, paths := s[:eq], s[eq+1:]
+	var p ImagePath
+	if i := strings.Index(paths, ":"); i >= 0 {
+		p.Repository, p.Tag = paths[:i], paths[i+1:]
+		if p.Repository == "" || p.Tag == "" {
+			return "", ImagePath{}, fmt.Errorf("expected repository and tag paths, got %q", paths)
+		}
+	} else {
+		if paths == "" {
+			return "", ImagePath{}, fmt.Errorf("expected a path for the image of %s", container)
+		}
+		p.Image = paths
+	}
+	return container, p, nil
+}
+
+func (p ImagePath) String() string {
+	if p.Image != "" {
+		return p.Image
+	}
+	return p.Repository + ":" + p.Tag
+}
+
+func (m *Manifests) imagePath(container string) ImagePath {
+	if p, ok := m.ImagePaths[container]; ok {
+		return p
+	}
+	return DefaultImagePath
+}
+
+func splitPath(p string) []string {
+	return strings.Split(p, ".")
+}
+
+// UpdateDefinition changes the image for the container given in a
+// chart's values. So that a value that happens to be at the path
+// isn't taken for an image, the image already there must be for the
+// same repository as the new one.
+func (m *Manifests) UpdateDefinition(def []byte, container string, newImageID flux.ImageID) ([]byte, error) {
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	path := m.imagePath(container)
+
+	if path.Image != "" {
+		current, ok := doc.Get(splitPath(path.Image)...)
+		if !ok {
+			return nil, fmt.Errorf("no image found at %s in values, for container %s", path.Image, container)
+		}
+		if err := checkRepository(current, newImageID); err != nil {
+			return nil, err
+		}
+		if err := doc.Set(newImageID.String(), splitPath(path.Image)...); err != nil {
+			return nil, errors.Wrap(err, "updating image")
+		}
+		return doc.Bytes(), nil
+	}
+
+	current, ok := doc.Get(splitPath(path.Repository)...)
+	if !ok {
+		return nil, fmt.Errorf("no image repository found at %s in values, for container %s", path.Repository, container)
+	}
+	if err := checkRepository(current, newImageID); err != nil {
+		return nil, err
+	}
+	if newImageID.Tag == "" || newImageID.Digest != "" {
+		return nil, fmt.Errorf("image %s cannot be given by its tag alone", newImageID)
+	}
+	if err := doc.Set(newImageID.Tag, splitPath(path.Tag)...); err != nil {
+		return nil, errors.Wrap(err, "updating image tag")
+	}
+	return doc.Bytes(), nil
+}
+
+func checkRepository(current string, newImageID flux.ImageID) error {
+	currentImage, err := flux.ParseImageID(current)
+	if err != nil {
+		return fmt.Errorf("could not parse image %s", current)
+	}
+	if currentImage.Repository() != newImageID.Repository() {
+		return fmt.Errorf("could not find image in values using repository: %s", newImageID.Repository())
+	}
+	return nil
+}
@@ -7,6 +7,10 @@ package kubernetes
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
 
 	k8syaml "github.com/ghodss/yaml"
 	"github.com/go-kit/kit/log"
@@ -27,10 +31,13 @@ import (
 
 const (
 	StatusUnknown  = "unknown"
-	StatusReady    = "ready"
+	StatusReady    = cluster.StatusReady
 	StatusUpdating = "updating"
 )
 
+// How many namespaces are listed at once, when listing all services
+const maxConcurrentNamespaces = 8
+
 type extendedClient struct {
 	discovery.DiscoveryInterface
 	v1core.CoreInterface
@@ -93,27 +100,40 @@ type Applier interface {
 // Cluster is a handle to a Kubernetes API server.
 // (Typically, this code is deployed into the same cluster.)
 type Cluster struct {
-	client     extendedClient
-	applier    Applier
-	actionc    chan func()
-	version    string // string response for the version command.
-	logger     log.Logger
-	sshKeyRing ssh.KeyRing
+	client      extendedClient
+	applier     Applier
+	actionc     chan func()
+	version     string // string response for the version command.
+	logger      log.Logger
+	sshKeyRing  ssh.KeyRing
+	exclude     *cluster.SharedKindFilter
+	customKinds CustomKinds
+	// the namespace resources are applied to when their manifests
+	// don't say; if empty, it's "default"
+	defaultNamespace string
 }
 
 // NewCluster returns a usable cluster. Host should be of the form
-// "http://hostname:8080".
+// "http://hostname:8080". Resources whose manifests don't say which
+// namespace they're in are applied to the default namespace given
+// (or "default", if that's empty).
 func NewCluster(clientset k8sclient.Interface,
 	applier Applier,
 	sshKeyRing ssh.KeyRing,
+	exclude *cluster.SharedKindFilter,
+	customKinds CustomKinds,
+	defaultNamespace string,
 	logger log.Logger) (*Cluster, error) {
 
 	c := &Cluster{
-		client:     extendedClient{clientset.Discovery(), clientset.Core(), clientset.Extensions()},
-		applier:    applier,
-		actionc:    make(chan func()),
-		logger:     logger,
-		sshKeyRing: sshKeyRing,
+		client:           extendedClient{clientset.Discovery(), clientset.Core(), clientset.Extensions()},
+		applier:          applier,
+		actionc:          make(chan func()),
+		logger:           logger,
+		sshKeyRing:       sshKeyRing,
+		exclude:          exclude,
+		customKinds:      customKinds,
+		defaultNamespace: defaultNamespace,
 	}
 
 	go c.loop()
@@ -150,15 +170,32 @@ func (c *Cluster) SomeServices(ids []flux.ServiceID) (res []cluster.Service, err
 		if err != nil {
 			return nil, errors.Wrapf(err, "finding pod controllers for namespace %s", ns)
 		}
+		budgets, err := c.podDisruptionBudgets(ns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "finding pod disruption budgets for namespace %s", ns)
+		}
+		// A name that isn't a Service may be a custom resource
+		var customs []cluster.Service
+		if len(c.customKinds) > 0 {
+			if customs, err = c.customServices(ns); err != nil {
+				return nil, errors.Wrapf(err, "finding custom resources for namespace %s", ns)
+			}
+		}
 		for _, name := range names {
 			service, err := services.Get(name)
 			if err != nil {
+				for _, custom := range customs {
+					if custom.ID == flux.MakeServiceID(ns, name) {
+						res = append(res, custom)
+						break
+					}
+				}
 				continue
 			}
 			if isAddon(service) {
 				continue
 			}
-			res = append(res, c.makeService(ns, service, controllers))
+			res = append(res, c.makeService(ns, service, controllers, budgets))
 		}
 	}
 	return res, nil
@@ -183,29 +220,112 @@ func (c *Cluster) AllServices(namespace string) (res []cluster.Service, err erro
 		}
 		namespaces = []string{namespace}
 	}
+	return servicesInNamespaces(namespaces, c.servicesInNamespace)
+}
 
-	for _, ns := range namespaces {
-		controllers, err := c.podControllersInNamespace(ns)
+// servicesInNamespaces lists the services in each of the namespaces
+// with servicesIn, and gives them back in the order of the
+// namespaces. Listing a namespace takes a few requests, so with many
+// namespaces it's worth doing a few at once. If listing any of the
+// namespaces fails, the error says which, and why.
+func servicesInNamespaces(namespaces []string, servicesIn func(string) ([]cluster.Service, error)) (res []cluster.Service, err error) {
+	results := make([][]cluster.Service, len(namespaces))
+	errs := make([]error, len(namespaces))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < maxConcurrentNamespaces && w < len(namespaces); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i], errs[i] = servicesIn(namespaces[i])
+			}
+		}()
+	}
+	for i := range namespaces {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	failed := namespaceErrors{}
+	for i, err := range errs {
 		if err != nil {
-			return nil, errors.Wrapf(err, "getting controllers for namespace %s", ns)
+			failed[namespaces[i]] = err
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed
+	}
+	for _, services := range results {
+		res = append(res, services...)
+	}
+	return res, nil
+}
+
+func (c *Cluster) servicesInNamespace(ns string) (res []cluster.Service, err error) {
+	controllers, err := c.podControllersInNamespace(ns)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting controllers")
+	}
+	budgets, err := c.podDisruptionBudgets(ns)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pod disruption budgets")
+	}
+
+	list, err := c.client.Services(ns).List(api.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "getting services")
+	}
+
+	for _, service := range list.Items {
+		if isAddon(&service) {
+			continue
 		}
+		res = append(res, c.makeService(ns, &service, controllers, budgets))
+	}
 
-		list, err := c.client.Services(ns).List(api.ListOptions{})
+	if len(c.customKinds) > 0 {
+		customs, err := c.customServices(ns)
 		if err != nil {
-			return nil, errors.Wrapf(err, "getting services for namespace %s", ns)
+			return nil, errors.Wrap(err, "getting custom resources")
 		}
-
-		for _, service := range list.Items {
-			if isAddon(&service) {
-				continue
+		// A Service of the same name comes first, as it does when
+		// asking for services by name
+		for _, custom := range customs {
+			taken := false
+			for _, s := range res {
+				if s.ID == custom.ID {
+					taken = true
+					break
+				}
+			}
+			if !taken {
+				res = append(res, custom)
 			}
-			res = append(res, c.makeService(ns, &service, controllers))
 		}
 	}
 	return res, nil
 }
 
-func (c *Cluster) makeService(ns string, service *v1.Service, controllers []podController) cluster.Service {
+// namespaceErrors collects the errors from listing each of several
+// namespaces, so that one failing doesn't hide the others.
+type namespaceErrors map[string]error
+
+func (errs namespaceErrors) Error() string {
+	var namespaces []string
+	for ns := range errs {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	var msgs []string
+	for _, ns := range namespaces {
+		msgs = append(msgs, fmt.Sprintf("namespace %s: %s", ns, errs[ns]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (c *Cluster) makeService(ns string, service *v1.Service, controllers []podController, budgets []podDisruptionBudget) cluster.Service {
 	id := flux.MakeServiceID(ns, service.Name)
 	svc := cluster.Service{
 		ID:       id,
@@ -220,6 +340,7 @@ func (c *Cluster) makeService(ns string, service *v1.Service, controllers []podC
 	} else {
 		svc.Containers = cluster.ContainersOrExcuse{Containers: pc.templateContainers()}
 		svc.Status = pc.status()
+		svc.Rollout = pc.rollout(budgets)
 	}
 
 	return svc
@@ -245,20 +366,35 @@ func (c *Cluster) podControllersInNamespace(namespace string) (res []podControll
 		}
 	}
 
+	dcs, err := c.deploymentConfigs(namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "collecting deployment configs")
+	}
+	for i := range dcs {
+		if !isAddon(&dcs[i]) {
+			res = append(res, podController{DeploymentConfig: &dcs[i]})
+		}
+	}
+
 	rclist, err := c.client.ReplicationControllers(namespace).List(api.ListOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "collecting replication controllers")
 	}
 	for i := range rclist.Items {
-		if !isAddon(&rclist.Items[i]) {
-			res = append(res, podController{ReplicationController: &rclist.Items[i]})
+		rc := &rclist.Items[i]
+		// Openshift makes a replication controller for each rollout
+		// of a deployment config; these are accounted for by the
+		// deployment config itself.
+		if isAddon(rc) || rc.Annotations[deploymentConfigAnnotation] != "" {
+			continue
 		}
+		res = append(res, podController{ReplicationController: rc})
 	}
 
 	return res, nil
 }
 
-// Find the pod controller (deployment or replication controller) that matches the service
+// Find the pod controller (deployment, deployment config or replication controller) that matches the service
 func matchController(service *v1.Service, controllers []podController) (podController, error) {
 	selector := service.Spec.Selector
 	if len(selector) == 0 {
@@ -281,16 +417,20 @@ func matchController(service *v1.Service, controllers []podController) (podContr
 	}
 }
 
-// Either a replication controller, a deployment, or neither (both nils).
+// Either a replication controller, a deployment, a deployment config,
+// or none of these (all nils).
 type podController struct {
 	ReplicationController *v1.ReplicationController
 	Deployment            *apiext.Deployment
+	DeploymentConfig      *deploymentConfig
 }
 
 func (p podController) templateContainers() (res []cluster.Container) {
 	var apiContainers []v1.Container
 	if p.Deployment != nil {
 		apiContainers = p.Deployment.Spec.Template.Spec.Containers
+	} else if p.DeploymentConfig != nil && p.DeploymentConfig.Spec.Template != nil {
+		apiContainers = p.DeploymentConfig.Spec.Template.Spec.Containers
 	} else if p.ReplicationController != nil {
 		apiContainers = p.ReplicationController.Spec.Template.Spec.Containers
 	}
@@ -304,6 +444,8 @@ func (p podController) templateContainers() (res []cluster.Container) {
 func (p podController) templateLabels() map[string]string {
 	if p.Deployment != nil {
 		return p.Deployment.Spec.Template.Labels
+	} else if p.DeploymentConfig != nil && p.DeploymentConfig.Spec.Template != nil {
+		return p.DeploymentConfig.Spec.Template.Labels
 	} else if p.ReplicationController != nil {
 		return p.ReplicationController.Spec.Template.Labels
 	}
@@ -324,7 +466,7 @@ func (p podController) matchedBy(selector map[string]string) bool {
 }
 
 // Determine a status for the service by looking at the rollout status
-// for the deployment or replication controller.
+// for the deployment, deployment config or replication controller.
 func (p podController) status() string {
 	switch {
 	case p.Deployment != nil:
@@ -338,6 +480,16 @@ func (p podController) status() string {
 			return fmt.Sprintf("%d out of %d updated", updated, wanted)
 		}
 		return StatusUpdating
+	case p.DeploymentConfig != nil:
+		meta, status := p.DeploymentConfig.ObjectMeta, p.DeploymentConfig.Status
+		if status.ObservedGeneration >= meta.Generation {
+			updated, wanted := status.UpdatedReplicas, p.DeploymentConfig.Spec.Replicas
+			if updated == wanted {
+				return StatusReady
+			}
+			return fmt.Sprintf("%d out of %d updated", updated, wanted)
+		}
+		return StatusUpdating
 	case p.ReplicationController != nil:
 		meta, status := p.ReplicationController.ObjectMeta, p.ReplicationController.Status
 		// This is more difficult, simply because updating a
@@ -366,7 +518,11 @@ func (c *Cluster) Sync(spec cluster.SyncDef) error {
 		for _, action := range spec.Actions {
 			logger := log.NewContext(logger).With("resource", action.ResourceID)
 			if len(action.Delete) > 0 {
-				obj, err := definitionObj(action.Delete)
+				obj, err := c.definitionObj(action.Delete)
+				if err == nil && c.exclude.Excludes(obj.Kind) {
+					logger.Log("excluded", obj.Kind, "skip", "delete")
+					continue
+				}
 				if err == nil {
 					err = c.applier.Delete(logger, obj)
 				}
@@ -376,7 +532,11 @@ func (c *Cluster) Sync(spec cluster.SyncDef) error {
 				}
 			}
 			if len(action.Apply) > 0 {
-				obj, err := definitionObj(action.Apply)
+				obj, err := c.definitionObj(action.Apply)
+				if err == nil && c.exclude.Excludes(obj.Kind) {
+					logger.Log("excluded", obj.Kind, "skip", "apply")
+					continue
+				}
 				if err == nil {
 					err = c.applier.Apply(logger, obj)
 				}
@@ -402,75 +562,100 @@ func (c *Cluster) Ping() error {
 
 func (c *Cluster) Export() ([]byte, error) {
 	var config bytes.Buffer
+	if err := c.ExportTo(&config); err != nil {
+		return nil, err
+	}
+	return config.Bytes(), nil
+}
+
+// ExportTo writes each resource to the writer as it's fetched, so
+// the whole export needn't be held in memory. Resources are written
+// in order of namespace, then kind, then name, so that the same
+// cluster state always gives the same export.
+func (c *Cluster) ExportTo(w io.Writer) error {
+	// Anything of an excluded kind is left out, so that it is not
+	// compared with (or deleted for want of) what's in the repo. The
+	// filter is taken once, so a whole export uses the same one.
+	exclude := c.exclude.Get()
+	appendKind := func(apiVersion, kind string, object interface{}) error {
+		if exclude.Excludes(kind) {
+			return nil
+		}
+		return appendYAML(w, apiVersion, kind, object)
+	}
 	list, err := c.client.Namespaces().List(api.ListOptions{})
 	if err != nil {
-		return nil, errors.Wrap(err, "getting namespaces")
+		return errors.Wrap(err, "getting namespaces")
 	}
+	sort.Sort(namespacesByName(list.Items))
 	for _, ns := range list.Items {
-		err := appendYAML(&config, "v1", "Namespace", ns)
+		err := appendKind("v1", "Namespace", ns)
 		if err != nil {
-			return nil, errors.Wrap(err, "marshalling namespace to YAML")
+			return errors.Wrap(err, "marshalling namespace to YAML")
 		}
 
 		deployments, err := c.client.Deployments(ns.Name).List(api.ListOptions{})
 		if err != nil {
-			return nil, errors.Wrap(err, "getting deployments")
+			return errors.Wrap(err, "getting deployments")
 		}
+		sort.Sort(deploymentsByName(deployments.Items))
 		for _, deployment := range deployments.Items {
 			if isAddon(&deployment) {
 				continue
 			}
-			err := appendYAML(&config, "extensions/v1beta1", "Deployment", deployment)
+			err := appendKind("extensions/v1beta1", "Deployment", deployment)
 			if err != nil {
-				return nil, errors.Wrap(err, "marshalling deployment to YAML")
+				return errors.Wrap(err, "marshalling deployment to YAML")
 			}
 		}
 
 		rcs, err := c.client.ReplicationControllers(ns.Name).List(api.ListOptions{})
 		if err != nil {
-			return nil, errors.Wrap(err, "getting replication controllers")
+			return errors.Wrap(err, "getting replication controllers")
 		}
+		sort.Sort(replicationControllersByName(rcs.Items))
 		for _, rc := range rcs.Items {
 			if isAddon(&rc) {
 				continue
 			}
-			err := appendYAML(&config, "v1", "ReplicationController", rc)
+			err := appendKind("v1", "ReplicationController", rc)
 			if err != nil {
-				return nil, errors.Wrap(err, "marshalling replication controller to YAML")
+				return errors.Wrap(err, "marshalling replication controller to YAML")
 			}
 		}
 
 		services, err := c.client.Services(ns.Name).List(api.ListOptions{})
 		if err != nil {
-			return nil, errors.Wrap(err, "getting services")
+			return errors.Wrap(err, "getting services")
 		}
+		sort.Sort(servicesByName(services.Items))
 		for _, service := range services.Items {
 			if isAddon(&service) {
 				continue
 			}
-			err := appendYAML(&config, "v1", "Service", service)
+			err := appendKind("v1", "Service", service)
 			if err != nil {
-				return nil, errors.Wrap(err, "marshalling service to YAML")
+				return errors.Wrap(err, "marshalling service to YAML")
 			}
 		}
 	}
-	return config.Bytes(), nil
+	return nil
 }
 
-// kind & apiVersion must be passed separately as the object's TypeMeta is not populated
-func appendYAML(buffer *bytes.Buffer, apiVersion, kind string, object interface{}) error {
+// kind & apiVersion must be passed separately as the object's TypeMeta is not populated.
+// The object goes via JSON, then a generic YAML value, and both of
+// those put map keys in sorted order; so fields and labels, etc., come
+// out in the same order every time.
+func appendYAML(w io.Writer, apiVersion, kind string, object interface{}) error {
 	yamlBytes, err := k8syaml.Marshal(object)
 	if err != nil {
 		return err
 	}
-	buffer.WriteString("---\n")
-	buffer.WriteString("apiVersion: ")
-	buffer.WriteString(apiVersion)
-	buffer.WriteString("\nkind: ")
-	buffer.WriteString(kind)
-	buffer.WriteString("\n")
-	buffer.Write(yamlBytes)
-	return nil
+	if _, err := fmt.Fprintf(w, "---\napiVersion: %s\nkind: %s\n", apiVersion, kind); err != nil {
+		return err
+	}
+	_, err = w.Write(yamlBytes)
+	return err
 }
 
 func (c *Cluster) PublicSSHKey(regenerate bool) (ssh.PublicKey, error) {
@@ -490,3 +675,15 @@ func definitionObj(bytes []byte) (*apiObject, error) {
 	obj := apiObject{bytes: bytes}
 	return &obj, yaml.Unmarshal(bytes, &obj)
 }
+
+// definitionObj parses a definition to be applied or deleted, putting
+// it in the default namespace if it doesn't say which it's in. It's
+// only the object in memory that's given the namespace; it goes to
+// kubectl as the namespace to use.
+func (c *Cluster) definitionObj(bytes []byte) (*apiObject, error) {
+	obj, err := definitionObj(bytes)
+	if err == nil && obj.Metadata.Namespace == "" && c.defaultNamespace != "" {
+		obj.Metadata.Namespace = c.defaultNamespace
+	}
+	return obj, err
+}
@@ -5,7 +5,9 @@ package kubernetes
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/go-kit/kit/log"
@@ -22,6 +24,7 @@ import (
 	v1alpha1rbac "k8s.io/client-go/1.5/kubernetes/typed/rbac/v1alpha1"
 	v1beta1storage "k8s.io/client-go/1.5/kubernetes/typed/storage/v1beta1"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 )
 
@@ -112,7 +115,7 @@ metadata:
 func setup(t *testing.T) (*Cluster, *mockApplier) {
 	clientset := &mockClientset{}
 	applier := &mockApplier{}
-	kube, err := NewCluster(clientset, applier, nil, log.NewNopLogger())
+	kube, err := NewCluster(clientset, applier, nil, nil, nil, "", log.NewNopLogger())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,3 +212,144 @@ func TestSkipOnError(t *testing.T) {
 		t.Errorf("expected commands:\n%#v\ngot:\n%#v", expected, mock.commands)
 	}
 }
+
+// Test that resources of an excluded kind are neither deleted nor
+// applied.
+func TestSyncExcludedKind(t *testing.T) {
+	kube, mock := setup(t)
+	kube.exclude = cluster.NewSharedKindFilter(cluster.KindFilter{"secret"})
+
+	secret := []byte(`---
+kind: Secret
+metadata:
+  name: leave-alone
+  namespace: test-ns
+`)
+	if err := kube.Sync(cluster.SyncDef{
+		Actions: []cluster.SyncAction{
+			cluster.SyncAction{
+				ResourceID: "secret",
+				Delete:     secret,
+				Apply:      secret,
+			},
+			cluster.SyncAction{
+				ResourceID: "deployment",
+				Apply:      deploymentDef("apply works"),
+			},
+		},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	expected := []command{
+		command{"apply", "apply works"},
+	}
+	if !reflect.DeepEqual(expected, mock.commands) {
+		t.Errorf("expected commands:\n%#v\ngot:\n%#v", expected, mock.commands)
+	}
+}
+
+// Test that services are listed from every namespace, at most a few
+// namespaces at a time, and that a namespace that can't be listed
+// fails the whole listing, saying which namespace it was.
+func TestServicesInNamespaces(t *testing.T) {
+	var namespaces []string
+	for i := 0; i < 3*maxConcurrentNamespaces; i++ {
+		namespaces = append(namespaces, fmt.Sprintf("ns%d", i))
+	}
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	servicesIn := func(failing string) func(string) ([]cluster.Service, error) {
+		return func(ns string) ([]cluster.Service, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+			if ns == failing {
+				return nil, errors.New("forbidden")
+			}
+			return []cluster.Service{
+				{ID: flux.MakeServiceID(ns, "a")},
+				{ID: flux.MakeServiceID(ns, "b")},
+			}, nil
+		}
+	}
+
+	services, err := servicesInNamespaces(namespaces, servicesIn(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []flux.ServiceID
+	for _, ns := range namespaces {
+		expected = append(expected, flux.MakeServiceID(ns, "a"), flux.MakeServiceID(ns, "b"))
+	}
+	var got []flux.ServiceID
+	for _, s := range services {
+		got = append(got, s.ID)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected services:\n%v\ngot:\n%v", expected, got)
+	}
+	if maxRunning > maxConcurrentNamespaces {
+		t.Errorf("expected at most %d namespaces listed at once, got %d", maxConcurrentNamespaces, maxRunning)
+	}
+
+	services, err = servicesInNamespaces(namespaces, servicesIn("ns5"))
+	if err == nil {
+		t.Fatalf("expected an error, got services %v", services)
+	}
+	errs, ok := err.(namespaceErrors)
+	if !ok {
+		t.Fatalf("expected namespace errors, got %T: %v", err, err)
+	}
+	if len(errs) != 1 || errs["ns5"] == nil {
+		t.Errorf("expected an error for ns5 only, got %v", errs)
+	}
+	if services != nil {
+		t.Errorf("expected no services with an error, got %v", services)
+	}
+}
+
+type namespaceApplier struct {
+	mockApplier
+	namespaces []string
+}
+
+func (m *namespaceApplier) Apply(logger log.Logger, obj *apiObject) error {
+	m.namespaces = append(m.namespaces, obj.namespaceOrDefault())
+	return nil
+}
+
+func TestSyncDefaultNamespace(t *testing.T) {
+	applier := &namespaceApplier{}
+	kube, err := NewCluster(&mockClientset{}, applier, nil, nil, nil, "team-a", log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kube.Sync(cluster.SyncDef{
+		Actions: []cluster.SyncAction{
+			cluster.SyncAction{
+				ResourceID: "Deployment team-a/namespaceless",
+				Apply:      []byte("kind: Deployment\nmetadata:\n  name: namespaceless\n"),
+			},
+			cluster.SyncAction{
+				ResourceID: "Deployment test-ns/namespaced",
+				Apply:      deploymentDef("namespaced"),
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"team-a", "test-ns"}
+	if !reflect.DeepEqual(expected, applier.namespaces) {
+		t.Errorf("expected to apply to namespaces %v, got %v", expected, applier.namespaces)
+	}
+}
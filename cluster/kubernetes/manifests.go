@@ -3,16 +3,43 @@ package kubernetes
 import (
 	"github.com/weaveworks/flux"
 	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 )
 
 type Manifests struct {
+	// CustomKinds are kinds of custom resource that can be released,
+	// along with where their containers are.
+	CustomKinds CustomKinds
+	// DefaultNamespace is the namespace resources are taken to be in
+	// when their manifests don't say; if empty, it's "default"
+	DefaultNamespace string
+	// PolicyDefaults are policies that apply to all the services in
+	// a namespace, unless a service's annotations say otherwise
+	PolicyDefaults *policy.SharedDefaults
 }
 
 // FindDefinedServices implementation in files.go
 
 func (c *Manifests) LoadManifests(paths ...string) (map[string]resource.Resource, error) {
-	return kresource.Load(paths...)
+	return c.load(paths...)
+}
+
+// load loads the resources under the paths given, putting those
+// that don't say which namespace they're in in the default namespace.
+func (c *Manifests) load(paths ...string) (map[string]resource.Resource, error) {
+	objs, err := kresource.Load(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return kresource.WithDefaultNamespace(objs, c.defaultNamespace()), nil
+}
+
+func (c *Manifests) defaultNamespace() string {
+	if c.DefaultNamespace == "" {
+		return "default"
+	}
+	return c.DefaultNamespace
 }
 
 func (c *Manifests) ParseManifests(allDefs []byte) (map[string]resource.Resource, error) {
@@ -20,7 +47,21 @@ func (c *Manifests) ParseManifests(allDefs []byte) (map[string]resource.Resource
 }
 
 func (c *Manifests) UpdateDefinition(def []byte, container string, image flux.ImageID) ([]byte, error) {
+	if len(c.CustomKinds) > 0 {
+		newDef, ok, err := c.CustomKinds.updateImage(def, container, image)
+		if err != nil || ok {
+			return newDef, err
+		}
+	}
 	return updatePodController(def, container, image)
 }
 
+// CheckPullPolicy in pullpolicy.go
+
 // UpdatePolicies and ServicesWithPolicy in policies.go
+
+// ValidateUpdate in validate.go
+
+// WithConfigChecksum and AppliedConfigChecksum in configchecksum.go
+
+// MarkApplied and IsMarkedApplied in applied.go
@@ -0,0 +1,192 @@
+package kubernetes
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+)
+
+// A manifest file may hold several resources -- a Service, a
+// Deployment and a ConfigMap, say -- as YAML documents separated by
+// `---`. When such a file is changed, only the document for the
+// resource in question is edited; the rest of the file, separators
+// and all, is left as it was. The same goes for the items of a
+// `kind: List` resource.
+
+// The kinds of resource that run pods, and so are what policies and
+// image updates apply to.
+var podControllerKinds = map[string]bool{
+	"Deployment":            true,
+	"DeploymentConfig":      true,
+	"ReplicationController": true,
+	"DaemonSet":             true,
+	"StatefulSet":           true,
+	"CronJob":               true,
+}
+
+// document is one of the YAML documents in a manifest file, given as
+// where it starts and ends in the file, along with what it says. If
+// the document is a List, each item is a document of its own, with
+// the path to the item in the List.
+type document struct {
+	start, end int
+	item       []string
+	manifest   Manifest
+}
+
+// splitDocuments finds the documents in a manifest file, passing over
+// any with nothing in them (like the one before a leading `---`).
+func splitDocuments(def []byte) ([]document, error) {
+	var docs []document
+	add := func(start, end int) error {
+		if !hasContent(def[start:end]) {
+			return nil
+		}
+		manifest, err := parseManifest(def[start:end])
+		if err != nil {
+			return err
+		}
+		if manifest.Kind != "List" {
+			docs = append(docs, document{start: start, end: end, manifest: manifest})
+			return nil
+		}
+		var list struct {
+			Items []Manifest `yaml:"items"`
+		}
+		if err := yaml.Unmarshal(def[start:end], &list); err != nil {
+			return errors.Wrap(err, "decoding list items")
+		}
+		for i, item := range list.Items {
+			docs = append(docs, document{
+				start:    start,
+				end:      end,
+				item:     []string{"items", strconv.Itoa(i)},
+				manifest: item,
+			})
+		}
+		return nil
+	}
+
+	start := 0
+	for offset := 0; offset < len(def); {
+		next := len(def)
+		if i := bytes.IndexByte(def[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		if isDocumentSeparator(def[offset:next]) {
+			if err := add(start, offset); err != nil {
+				return nil, err
+			}
+			start = next
+		}
+		offset = next
+	}
+	if err := add(start, len(def)); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func isDocumentSeparator(line []byte) bool {
+	line = bytes.TrimRight(line, "\r\n")
+	return bytes.HasPrefix(line, []byte("---")) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t')
+}
+
+// hasContent says whether there's anything other than blank lines
+// and comments in the text given.
+func hasContent(def []byte) bool {
+	for _, line := range bytes.Split(def, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return true
+		}
+	}
+	return false
+}
+
+// podControllerDocument picks out the document that policies apply
+// to, which is the one pod controller in the file. A file with only
+// one document in it is taken as it is, whatever its kind.
+func podControllerDocument(docs []document) (document, error) {
+	if len(docs) == 1 {
+		return docs[0], nil
+	}
+	var found []document
+	for _, doc := range docs {
+		if podControllerKinds[doc.manifest.Kind] {
+			found = append(found, doc)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return document{}, errors.New("no pod controller (e.g., Deployment) found among the resources in the file")
+	case 1:
+		return found[0], nil
+	}
+	return document{}, errors.Errorf("%d pod controllers found in the same file, so cannot tell which one to use", len(found))
+}
+
+// containerDocument picks out the document defining the (first) pod
+// controller with a container for which the function given returns
+// true. As with podControllerDocument, a file with only one document
+// is taken as it is.
+func containerDocument(docs []document, match func(Container) bool) (document, bool) {
+	if len(docs) == 1 {
+		return docs[0], true
+	}
+	for _, doc := range docs {
+		if !podControllerKinds[doc.manifest.Kind] {
+			continue
+		}
+		for _, c := range doc.manifest.Containers() {
+			if match(c) {
+				return doc, true
+			}
+		}
+	}
+	return document{}, false
+}
+
+// editDocument replaces the document given with the result of
+// applying the function to it, leaving the rest of the file alone.
+func editDocument(def []byte, doc document, f func([]byte) ([]byte, error)) ([]byte, error) {
+	edit := f
+	if doc.item != nil {
+		edit = func(def []byte) ([]byte, error) {
+			return editItem(def, doc.item, f)
+		}
+	}
+	edited, err := edit(def[doc.start:doc.end])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(def)-(doc.end-doc.start)+len(edited))
+	out = append(out, def[:doc.start]...)
+	out = append(out, edited...)
+	return append(out, def[doc.end:]...), nil
+}
+
+// editItem applies the function to the item at the path given in a
+// List, as though the item were a document by itself.
+func editItem(def []byte, path []string, f func([]byte) ([]byte, error)) ([]byte, error) {
+	list, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	item, err := list.Extract(path...)
+	if err != nil {
+		return nil, err
+	}
+	edited, err := f(item)
+	if err != nil {
+		return nil, err
+	}
+	if err := list.Replace(edited, path...); err != nil {
+		return nil, err
+	}
+	return list.Bytes(), nil
+}
@@ -0,0 +1,174 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+)
+
+const multidoc = `# everything for helloworld
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: helloworld
+spec:
+  selector:
+    name: helloworld
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld
+spec:
+  template:
+    metadata:
+      labels:
+        name: helloworld
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: helloworld
+data:
+  image: quay.io/weaveworks/helloworld:master-a000001
+`
+
+// Another pod controller, with a container of the same name as that
+// above, but a different image
+const multidocSidecar = `---
+kind: Deployment
+metadata:
+  name: sidecar
+spec:
+  template:
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/sidecar:1
+`
+
+func TestSplitDocuments(t *testing.T) {
+	docs, err := splitDocuments([]byte(multidoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, doc := range docs {
+		kinds = append(kinds, doc.manifest.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "Service,Deployment,ConfigMap" {
+		t.Errorf("expected Service, Deployment and ConfigMap, got %s", got)
+	}
+}
+
+func TestUpdatePoliciesMultidoc(t *testing.T) {
+	out, err := (&Manifests{}).UpdatePolicies([]byte(multidoc), policy.Update{
+		Add: policy.Set{policy.Automated: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(multidoc, `kind: Deployment
+metadata:
+  name: helloworld
+`, `kind: Deployment
+metadata:
+  annotations:
+    flux.weave.works/automated: "true"
+  name: helloworld
+`, 1)
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	// With two pod controllers, there's no telling which to annotate
+	if _, err := (&Manifests{}).UpdatePolicies([]byte(multidoc+multidocSidecar), policy.Update{
+		Add: policy.Set{policy.Automated: "true"},
+	}); err == nil {
+		t.Error("expected an error with two pod controllers in the file")
+	}
+}
+
+func TestUpdateDefinitionMultidoc(t *testing.T) {
+	in := multidoc + multidocSidecar
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	out, err := updatePodController([]byte(in), "helloworld", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the Deployment using the image is changed; the ConfigMap
+	// mentioning it, and the other Deployment with a container of
+	// the same name, are left alone
+	expected := strings.Replace(in, `        image: quay.io/weaveworks/helloworld:master-a000001`, `        image: quay.io/weaveworks/helloworld:master-a000002`, 1)
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	other, _ := flux.ParseImageID("quay.io/weaveworks/other:1")
+	if _, err := updatePodController([]byte(in), "helloworld", other); err == nil {
+		t.Error("expected an error when no container uses the image")
+	}
+}
+
+const list = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: helloworld
+  spec:
+    selector:
+      name: helloworld
+- apiVersion: extensions/v1beta1
+  kind: Deployment
+  metadata:
+    name: helloworld
+  spec:
+    template:
+      metadata:
+        labels:
+          name: helloworld
+      spec:
+        containers:
+        - name: helloworld
+          image: quay.io/weaveworks/helloworld:master-a000001
+`
+
+func TestUpdateList(t *testing.T) {
+	out, err := (&Manifests{}).UpdatePolicies([]byte(list), policy.Update{
+		Add: policy.Set{policy.Automated: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(list, `  kind: Deployment
+  metadata:
+    name: helloworld
+`, `  kind: Deployment
+  metadata:
+    annotations:
+      flux.weave.works/automated: "true"
+    name: helloworld
+`, 1)
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	out, err = updatePodController([]byte(list), "helloworld", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = strings.Replace(list, "master-a000001", "master-a000002", 1)
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+}
@@ -0,0 +1,56 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/client-go/1.5/pkg/api/errors"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+)
+
+// The client library doesn't know about Openshift's resources, so we
+// ask for DeploymentConfigs with a plain REST request, and decode
+// only the fields we're interested in.
+
+// Openshift marks the replication controllers it creates for a
+// deployment config with this annotation.
+const deploymentConfigAnnotation = "openshift.io/deployment-config.name"
+
+type deploymentConfig struct {
+	v1.ObjectMeta `json:"metadata"`
+	Spec          struct {
+		Replicas int32               `json:"replicas"`
+		Template *v1.PodTemplateSpec `json:"template"`
+		Strategy struct {
+			Type string `json:"type"`
+		} `json:"strategy"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration int64 `json:"observedGeneration"`
+		UpdatedReplicas    int32 `json:"updatedReplicas"`
+	} `json:"status"`
+}
+
+type deploymentConfigList struct {
+	Items []deploymentConfig `json:"items"`
+}
+
+// deploymentConfigs lists the DeploymentConfigs in a namespace. If
+// the cluster isn't Openshift, or we're not allowed to look, there
+// are none.
+func (c *Cluster) deploymentConfigs(namespace string) ([]deploymentConfig, error) {
+	body, err := c.client.CoreInterface.GetRESTClient().Get().
+		AbsPath("/oapi/v1/namespaces", namespace, "deploymentconfigs").
+		DoRaw()
+	if err != nil {
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list deploymentConfigList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, errors.Wrap(err, "decoding deployment configs")
+	}
+	return list.Items, nil
+}
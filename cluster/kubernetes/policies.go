@@ -2,7 +2,8 @@ package kubernetes
 
 import (
 	"io/ioutil"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
 	"github.com/weaveworks/flux/policy"
 )
 
@@ -25,79 +27,125 @@ func (m *Manifests) UpdatePolicies(in []byte, update policy.Update) ([]byte, err
 	})
 }
 
+// updateAnnotations applies the function given to the annotations of
+// the resource, then makes whatever changes are needed to the
+// definition so it has the annotations returned, leaving the rest of
+// it as it was. If the file has more than one resource in it, it's
+// the pod controller that's changed.
 func updateAnnotations(def []byte, f func(map[string]string) map[string]string) ([]byte, error) {
-	manifest, err := parseManifest(def)
+	docs, err := splitDocuments(def)
 	if err != nil {
 		return nil, err
 	}
-	newAnnotations := f(manifest.Metadata.AnnotationsOrNil())
-
-	// Write the new annotations back into the manifest
-	// Generate a fragment of the new annotations.
-	var fragment string
-	if len(newAnnotations) > 0 {
-		fragmentB, err := yaml.Marshal(map[string]map[string]string{
-			"annotations": newAnnotations,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		fragment = string(fragmentB)
-
-		// Remove the last newline, so it fits in better
-		fragment = strings.TrimSuffix(fragment, "\n")
+	target, err := podControllerDocument(docs)
+	if err != nil {
+		return nil, err
+	}
+	return editDocument(def, target, func(def []byte) ([]byte, error) {
+		return updateDocumentAnnotations(def, target.manifest, f)
+	})
+}
 
-		// indent the fragment 2 spaces
-		fragment = regexp.MustCompile(`(.+)`).ReplaceAllString(fragment, "  $1")
+func updateDocumentAnnotations(def []byte, manifest Manifest, f func(map[string]string) map[string]string) ([]byte, error) {
+	oldAnnotations := map[string]string{}
+	for k, v := range manifest.Metadata.Annotations {
+		oldAnnotations[k] = v
+	}
+	newAnnotations := f(manifest.Metadata.AnnotationsOrNil())
 
-		// Add a newline if it's not blank
-		if len(fragment) > 0 {
-			fragment = "\n" + fragment
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	if len(newAnnotations) == 0 {
+		if err := doc.Remove("metadata", "annotations"); err != nil {
+			return nil, errors.Wrap(err, "removing annotations")
 		}
+		return doc.Bytes(), nil
 	}
-
-	// Find where to insert the fragment.
-	// TODO: This should handle potentially different indentation.
-	// TODO: There's probably a more elegant regex-ey way to do this in one pass.
-	replaced := false
-	annotationsRE := regexp.MustCompile(`(?m:\n  annotations:\s*(?:#.*)*(?:\n    .*)*$)`)
-	newDef := annotationsRE.ReplaceAllStringFunc(string(def), func(found string) string {
-		if !replaced {
-			replaced = true
-			return fragment
+	for k := range oldAnnotations {
+		if _, ok := newAnnotations[k]; ok {
+			continue
+		}
+		if err := doc.Remove("metadata", "annotations", k); err != nil {
+			return nil, errors.Wrapf(err, "removing annotation %s", k)
 		}
-		return found
-	})
-	if !replaced {
-		metadataRE := multilineRE(`(metadata:\s*(?:#.*)*)`)
-		newDef = metadataRE.ReplaceAllStringFunc(string(def), func(found string) string {
-			if !replaced {
-				replaced = true
-				f := found + fragment
-				return f
-			}
-			return found
-		})
 	}
-	if !replaced {
-		return nil, errors.New("Could not update resource annotations")
+	// New annotations are added in order, so it doesn't depend on
+	// how the map is iterated
+	var keys []string
+	for k := range newAnnotations {
+		keys = append(keys, k)
 	}
-
-	return []byte(newDef), err
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := newAnnotations[k]
+		if old, ok := oldAnnotations[k]; ok && old == v {
+			continue
+		}
+		if err := doc.Set(v, "metadata", "annotations", k); err != nil {
+			return nil, errors.Wrapf(err, "setting annotation %s", k)
+		}
+	}
+	return doc.Bytes(), nil
 }
 
 type Manifest struct {
+	Kind     string   `yaml:"kind"`
 	Metadata Metadata `yaml:"metadata"`
 	Spec     struct {
-		Template struct {
+		Template podTemplate `yaml:"template"`
+		// A CronJob has its pod template in a template for the Job
+		JobTemplate struct {
 			Spec struct {
-				Containers []Container `yaml:"containers"`
+				Template podTemplate `yaml:"template"`
 			} `yaml:"spec"`
-		} `yaml:"template"`
+		} `yaml:"jobTemplate"`
 	} `yaml:"spec"`
 }
 
+type podTemplate struct {
+	Metadata struct {
+		Labels map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Containers []Container `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+// Containers returns the containers in the pod template of the
+// resource, wherever that is for its kind.
+func (m Manifest) Containers() []Container {
+	if m.Kind == "CronJob" {
+		return m.Spec.JobTemplate.Spec.Template.Spec.Containers
+	}
+	return m.Spec.Template.Spec.Containers
+}
+
+// PodLabels returns the labels given to pods in the pod template of
+// the resource.
+func (m Manifest) PodLabels() map[string]string {
+	if m.Kind == "CronJob" {
+		return m.Spec.JobTemplate.Spec.Template.Metadata.Labels
+	}
+	return m.Spec.Template.Metadata.Labels
+}
+
+// podTemplatePath gives the path to the pod template in the
+// definition of the resource.
+func (m Manifest) podTemplatePath() []string {
+	if m.Kind == "CronJob" {
+		return []string{"spec", "jobTemplate", "spec", "template"}
+	}
+	return []string{"spec", "template"}
+}
+
+// containerPath gives the path in the definition to the field given
+// of the container at the index given.
+func (m Manifest) containerPath(index int, field string) []string {
+	return append(m.podTemplatePath(), "spec", "containers", strconv.Itoa(index), field)
+}
+
 func (m Metadata) AnnotationsOrNil() map[string]string {
 	if m.Annotations == nil {
 		return map[string]string{}
@@ -111,8 +159,9 @@ type Metadata struct {
 }
 
 type Container struct {
-	Name  string `yaml:"name"`
-	Image string `yaml:"image"`
+	Name            string `yaml:"name"`
+	Image           string `yaml:"image"`
+	ImagePullPolicy string `yaml:"imagePullPolicy"`
 }
 
 func parseManifest(def []byte) (Manifest, error) {
@@ -130,11 +179,10 @@ func (m *Manifests) ServicesWithPolicy(root string, p policy.Policy) (policy.Ser
 	}
 	result := map[flux.ServiceID]policy.Set{}
 
+	defaults := m.PolicyDefaults.Get()
 	err = iterateManifests(all, func(s flux.ServiceID, m Manifest) error {
-		ps, err := policiesFrom(m)
-		if err != nil {
-			return err
-		}
+		namespace, _ := s.Components()
+		ps := defaults.Apply(namespace, annotatedPolicies(m))
 		if ps.Contains(p) {
 			result[s] = ps
 		}
@@ -156,33 +204,52 @@ func iterateManifests(services map[flux.ServiceID][]string, f func(flux.ServiceI
 		if err != nil {
 			return err
 		}
-		manifest, err := parseManifest(def)
+		docs, err := splitDocuments(def)
 		if err != nil {
 			return err
 		}
+		// Like a service defined in more than one file, one whose
+		// file has no single pod controller in it is passed over
+		target, err := podControllerDocument(docs)
+		if err != nil {
+			continue
+		}
 
-		if err = f(serviceID, manifest); err != nil {
+		if err = f(serviceID, target.manifest); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// PoliciesIn gives the policies annotated on the pod controller in
+// the definition given.
+func (m *Manifests) PoliciesIn(def []byte) (policy.Set, error) {
+	docs, err := splitDocuments(def)
+	if err != nil {
+		return nil, err
+	}
+	target, err := podControllerDocument(docs)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Set(annotatedPolicies(target.manifest)), nil
+}
+
+// policiesFrom gives the policies annotated on a manifest, without
+// any namespace defaults.
 func policiesFrom(m Manifest) (policy.Set, error) {
-	var policies policy.Set
+	return policy.NamespaceDefaults(nil).Apply("", annotatedPolicies(m)), nil
+}
+
+// annotatedPolicies gives the policies annotated on a manifest, as
+// they are written.
+func annotatedPolicies(m Manifest) map[policy.Policy]string {
+	policies := map[policy.Policy]string{}
 	for k, v := range m.Metadata.AnnotationsOrNil() {
-		if !strings.HasPrefix(k, resource.PolicyPrefix) {
-			continue
-		}
-		p := policy.Policy(strings.TrimPrefix(k, resource.PolicyPrefix))
-		if policy.Boolean(p) {
-			if v != "true" {
-				continue
-			}
-			policies = policies.Add(p)
-		} else {
-			policies = policies.Set(p, v)
+		if strings.HasPrefix(k, resource.PolicyPrefix) {
+			policies[policy.Policy(strings.TrimPrefix(k, resource.PolicyPrefix))] = v
 		}
 	}
-	return policies, nil
+	return policies
 }
@@ -108,6 +108,45 @@ func TestUpdatePolicies(t *testing.T) {
 	}
 }
 
+func TestUpdatePoliciesKeepsLayout(t *testing.T) {
+	// Four-space indentation, annotations out of order, and comments
+	// throughout
+	in := `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+    name: nginx # the name
+    annotations:
+        # scraping is turned off for now
+        prometheus.io.scrape: 'false'
+        flux.weave.works/locked: "true" # don't touch
+    namespace: default
+spec:
+    replicas: 1
+`
+	out := `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+    name: nginx # the name
+    annotations:
+        # scraping is turned off for now
+        prometheus.io.scrape: 'false'
+        flux.weave.works/locked: "true" # don't touch
+        flux.weave.works/automated: "true"
+    namespace: default
+spec:
+    replicas: 1
+`
+	got, err := (&Manifests{}).UpdatePolicies([]byte(in), policy.Update{
+		Add: policy.Set{policy.Automated: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != out {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", out, got)
+	}
+}
+
 var annotationsTemplate = template.Must(template.New("").Parse(`---
 apiVersion: extensions/v1beta1
 kind: Deployment
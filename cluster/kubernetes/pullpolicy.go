@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+	"github.com/weaveworks/flux/policy"
+)
+
+const (
+	pullAlways       = "Always"
+	pullIfNotPresent = "IfNotPresent"
+)
+
+// CheckPullPolicy looks at the image pull policy of the container, in
+// light of the image it's just been given. If the resource has the
+// pull-policy policy set to "fix", an unsuitable pull policy is
+// corrected; otherwise, if the policy is set at all, the problem is
+// described so it can be reported. In a file with several resources,
+// it's the pod controller with the container that's looked at.
+func (m *Manifests) CheckPullPolicy(def []byte, container string, image flux.ImageID) ([]byte, string, error) {
+	docs, err := splitDocuments(def)
+	if err != nil {
+		return nil, "", err
+	}
+	target, ok := containerDocument(docs, func(c Container) bool {
+		return c.Name == container
+	})
+	if !ok {
+		return def, "", nil
+	}
+	manifest := target.manifest
+	policies, err := policiesFrom(manifest)
+	if err != nil {
+		return nil, "", err
+	}
+	mode, ok := policies.Get(policy.PullPolicy)
+	if !ok {
+		return def, "", nil
+	}
+
+	for i, c := range manifest.Containers() {
+		if c.Name != container {
+			continue
+		}
+		want, problem := pullPolicyProblem(c.ImagePullPolicy, image)
+		if problem == "" || mode != policy.PullPolicyFix {
+			return def, problem, nil
+		}
+		newDef, err := editDocument(def, target, func(def []byte) ([]byte, error) {
+			return setPullPolicy(def, i, want)
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return newDef, fmt.Sprintf("%s; changed to %s", problem, want), nil
+	}
+	return def, "", nil
+}
+
+// pullPolicyProblem decides whether an image pull policy is a poor fit
+// for an image. A tag that's been pushed once and not since (which
+// is what we expect from anything that's been released) need only
+// be pulled if it's not present; but a tag that moves, like
+// `latest`, must be pulled every time, or an old image may be run.
+// It returns the pull policy that would suit, and a description of
+// the problem, or empty strings if there's no problem.
+func pullPolicyProblem(current string, image flux.ImageID) (string, string) {
+	mutable := image.Tag == "" || image.Tag == "latest"
+	switch {
+	case !mutable && current == pullAlways:
+		return pullIfNotPresent, fmt.Sprintf("imagePullPolicy %s is unnecessary for the unique tag %q", current, image.Tag)
+	case mutable && current == pullIfNotPresent:
+		return pullAlways, fmt.Sprintf("imagePullPolicy %s may run a stale image, since the tag %q can change", current, image.Tag)
+	}
+	return "", ""
+}
+
+// setPullPolicy sets the image pull policy of the container at the
+// index given, replacing the existing value or adding one.
+func setPullPolicy(def []byte, index int, pullPolicy string) ([]byte, error) {
+	manifest, err := parseManifest(def)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Set(pullPolicy, manifest.containerPath(index, "imagePullPolicy")...); err != nil {
+		return nil, errors.Wrap(err, "setting image pull policy")
+	}
+	return doc.Bytes(), nil
+}
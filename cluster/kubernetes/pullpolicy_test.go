@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+const pullPolicyDef = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld
+  annotations:
+    flux.weave.works/pull-policy: %s
+spec:
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: quay.io/weaveworks/sidecar:latest
+        imagePullPolicy: IfNotPresent
+      - image: quay.io/weaveworks/helloworld:master-a000002
+        name: helloworld
+        imagePullPolicy: Always
+        args:
+        - -msg=Ahoy
+`
+
+func TestCheckPullPolicy(t *testing.T) {
+	m := &Manifests{}
+	unique, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	mutable, _ := flux.ParseImageID("quay.io/weaveworks/sidecar:latest")
+
+	for _, c := range []struct {
+		mode, container string
+		image           flux.ImageID
+		expectLine      string
+	}{
+		{"fix", "helloworld", unique, "        imagePullPolicy: IfNotPresent\n        args:"},
+		{"fix", "sidecar", mutable, "        image: quay.io/weaveworks/sidecar:latest\n        imagePullPolicy: Always"},
+		{"warn", "helloworld", unique, "        name: helloworld\n        imagePullPolicy: Always"},
+	} {
+		def := strings.Replace(pullPolicyDef, "%s", c.mode, 1)
+		out, warning, err := m.CheckPullPolicy([]byte(def), c.container, c.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if warning == "" {
+			t.Errorf("%s %s: expected a warning", c.mode, c.container)
+		}
+		if !strings.Contains(string(out), c.expectLine) {
+			t.Errorf("%s %s: expected to find %q in:\n%s", c.mode, c.container, c.expectLine, out)
+		}
+	}
+}
+
+func TestCheckPullPolicyNotSet(t *testing.T) {
+	def := strings.Replace(pullPolicyDef, "    flux.weave.works/pull-policy: %s\n", "", 1)
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	out, warning, err := (&Manifests{}).CheckPullPolicy([]byte(def), "helloworld", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warning != "" || string(out) != def {
+		t.Errorf("expected no change and no warning without the policy, got %q", warning)
+	}
+}
+
+func TestSetPullPolicyAdds(t *testing.T) {
+	def := `---
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: quay.io/weaveworks/helloworld:latest
+        name: helloworld
+`
+	out, err := setPullPolicy([]byte(def), 0, "Always")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `      - image: quay.io/weaveworks/helloworld:latest
+        imagePullPolicy: Always
+        name: helloworld
+`
+	if !strings.HasSuffix(string(out), expected) {
+		t.Errorf("expected pull policy to be added after image, got:\n%s", out)
+	}
+}
@@ -0,0 +1,36 @@
+package resource
+
+import (
+	"k8s.io/client-go/1.5/pkg/labels"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/resource"
+)
+
+// CronJob runs a Job on a schedule. Unlike the other workloads, its
+// pod template is inside a template for the Job.
+type CronJob struct {
+	baseObject
+	Spec CronJobSpec
+}
+
+func (o CronJob) ServiceIDs(all map[string]resource.Resource) []flux.ServiceID {
+	found := flux.ServiceIDSet{}
+	for _, r := range all {
+		s, ok := r.(*Service)
+		if ok && s.Meta.Namespace == o.Meta.Namespace && s.Matches(labels.Set(o.Spec.JobTemplate.Spec.Template.Metadata.Labels)) {
+			found.Add(s.ServiceIDs(all))
+		}
+	}
+
+	return found.ToSlice()
+}
+
+type CronJobSpec struct {
+	Schedule    string
+	JobTemplate struct {
+		Spec struct {
+			Template PodTemplate
+		}
+	} `yaml:"jobTemplate"`
+}
@@ -0,0 +1,72 @@
+package resource
+
+import (
+	"k8s.io/client-go/1.5/pkg/labels"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/resource"
+)
+
+// DeploymentConfig is the Openshift equivalent of a Deployment. As
+// far as we're concerned, it's the same thing, apart from its
+// triggers.
+type DeploymentConfig struct {
+	baseObject
+	Spec DeploymentConfigSpec
+}
+
+func (o DeploymentConfig) ServiceIDs(all map[string]resource.Resource) []flux.ServiceID {
+	found := flux.ServiceIDSet{}
+	for _, r := range all {
+		s, ok := r.(*Service)
+		if ok && s.Meta.Namespace == o.Meta.Namespace && s.Matches(labels.Set(o.Spec.Template.Metadata.Labels)) {
+			found.Add(s.ServiceIDs(all))
+		}
+	}
+
+	return found.ToSlice()
+}
+
+type DeploymentConfigSpec struct {
+	Replicas int
+	Template PodTemplate
+	Triggers []DeploymentTrigger
+}
+
+type DeploymentTrigger struct {
+	Type              string
+	ImageChangeParams *ImageChangeParams `yaml:"imageChangeParams"`
+}
+
+// ImageChangeParams say which containers get their image from an
+// image stream, rather than from the pod template.
+type ImageChangeParams struct {
+	Automatic      bool
+	ContainerNames []string `yaml:"containerNames"`
+	From           ObjectReference
+}
+
+type ObjectReference struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// ImageTrigger returns the parameters of the trigger that sets the
+// image for the container named, if there is one. Openshift replaces
+// the image of such a container whenever the image stream it refers
+// to changes, so changing the image in the pod template is futile.
+func (o DeploymentConfig) ImageTrigger(container string) *ImageChangeParams {
+	for _, trigger := range o.Spec.Triggers {
+		params := trigger.ImageChangeParams
+		if trigger.Type != "ImageChange" || params == nil || !params.Automatic {
+			continue
+		}
+		for _, name := range params.ContainerNames {
+			if name == container {
+				return params
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package resource
+
+import (
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux/resource"
+)
+
+// List is a resource that holds other resources as its `items`. It's
+// the items that matter, so when a List is loaded, they're taken out
+// and treated like any other resource.
+type List struct {
+	baseObject
+	Items []resource.Resource
+}
+
+func unmarshalList(base baseObject, bytes []byte) (*List, error) {
+	var raw struct {
+		// MapSlice keeps the fields of each item in order
+		Items []yaml.MapSlice `yaml:"items"`
+	}
+	if err := yaml.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+	list := List{baseObject: base}
+	for _, item := range raw.Items {
+		itemBytes, err := yaml.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		res, err := unmarshalObject(base.source, itemBytes)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, res)
+	}
+	return &list, nil
+}
+
+// flatten gives the resource, or if it's a List, the resources in it.
+func flatten(res resource.Resource) []resource.Resource {
+	list, ok := res.(*List)
+	if !ok {
+		return []resource.Resource{res}
+	}
+	var all []resource.Resource
+	for _, item := range list.Items {
+		all = append(all, flatten(item)...)
+	}
+	return all
+}
@@ -46,6 +46,25 @@ func Load(roots ...string) (map[string]resource.Resource, error) {
 	return objs, nil
 }
 
+// WithDefaultNamespace gives the resources given, with those that
+// belong in a namespace but don't say which put in the namespace
+// given, and identified accordingly. Only the resources as loaded are
+// changed, not their manifests; they're put in the namespace when
+// they're applied.
+func WithDefaultNamespace(objs map[string]resource.Resource, namespace string) map[string]resource.Resource {
+	result := map[string]resource.Resource{}
+	for id, obj := range objs {
+		if o, ok := obj.(interface {
+			setDefaultNamespace(string)
+		}); ok {
+			o.setDefaultNamespace(namespace)
+			id = obj.ResourceID()
+		}
+		result[id] = obj
+	}
+	return result
+}
+
 // ParseManifests takes a dump of config (a multidoc YAML) and
 // constructs an object set from the resources represented therein.
 func ParseMultidoc(multidoc []byte, source string) (map[string]resource.Resource, error) {
@@ -57,7 +76,9 @@ func ParseMultidoc(multidoc []byte, source string) (map[string]resource.Resource
 		if obj, err := unmarshalObject(source, chunks.Bytes()); err != nil {
 			return nil, fmt.Errorf(`parsing YAML doc from "%s": %s`, source, err.Error())
 		} else {
-			objs[obj.ResourceID()] = obj
+			for _, res := range flatten(obj) {
+				objs[res.ResourceID()] = res
+			}
 		}
 	}
 	if err := chunks.Err(); err != nil {
@@ -59,6 +59,52 @@ metadata:
 	}
 }
 
+func TestParseDeploymentConfig(t *testing.T) {
+	doc := `---
+kind: DeploymentConfig
+metadata:
+  name: a-deploymentconfig
+spec:
+  template:
+    spec:
+      containers:
+      - name: foo
+        image: " "
+      - name: bar
+        image: bar:v1
+  triggers:
+  - type: ImageChange
+    imageChangeParams:
+      automatic: true
+      containerNames:
+      - foo
+      from:
+        kind: ImageStreamTag
+        name: foo:latest
+`
+	objs, err := ParseMultidoc([]byte(doc), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, ok := objs["DeploymentConfig default/a-deploymentconfig"]
+	if !ok {
+		t.Fatalf("expected deployment config, got %#v", objs)
+	}
+	dc, ok := obj.(*DeploymentConfig)
+	if !ok {
+		t.Fatalf("expected *DeploymentConfig, got %T", obj)
+	}
+	if len(dc.Spec.Template.Spec.Containers) != 2 {
+		t.Errorf("expected two containers, got %#v", dc.Spec.Template.Spec.Containers)
+	}
+	if trigger := dc.ImageTrigger("foo"); trigger == nil || trigger.From.Name != "foo:latest" {
+		t.Errorf("expected image trigger for container foo, got %#v", trigger)
+	}
+	if trigger := dc.ImageTrigger("bar"); trigger != nil {
+		t.Errorf("expected no image trigger for container bar, got %#v", trigger)
+	}
+}
+
 func debyte(r resource.Resource) resource.Resource {
 	if res, ok := r.(interface {
 		debyte()
@@ -83,3 +129,152 @@ func TestLoadSome(t *testing.T) {
 		t.Errorf("expected %d objects from %d files, got result:\n%#v", len(testfiles.Files), len(testfiles.Files), objs)
 	}
 }
+
+func TestParseList(t *testing.T) {
+	doc := `---
+apiVersion: v1
+kind: List
+items:
+- kind: Service
+  metadata:
+    name: b-service
+    namespace: b-namespace
+- kind: Deployment
+  metadata:
+    name: a-deployment
+`
+	objs, err := ParseMultidoc([]byte(doc), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected the two items of the list, got %#v", objs)
+	}
+	if _, ok := objs["Deployment default/a-deployment"].(*Deployment); !ok {
+		t.Errorf("expected *Deployment, got %#v", objs["Deployment default/a-deployment"])
+	}
+	svc, ok := objs["Service b-namespace/b-service"].(*Service)
+	if !ok {
+		t.Fatalf("expected *Service, got %#v", objs["Service b-namespace/b-service"])
+	}
+	if svc.Source() != "test" {
+		t.Errorf("expected item to have the source of the list, got %q", svc.Source())
+	}
+}
+
+func TestParseCronJob(t *testing.T) {
+	doc := `---
+kind: CronJob
+metadata:
+  name: a-cronjob
+spec:
+  schedule: "*/5 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            name: a-cronjob
+        spec:
+          containers:
+          - name: foo
+            image: foo:v1
+`
+	objs, err := ParseMultidoc([]byte(doc), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cj, ok := objs["CronJob default/a-cronjob"].(*CronJob)
+	if !ok {
+		t.Fatalf("expected *CronJob, got %#v", objs)
+	}
+	template := cj.Spec.JobTemplate.Spec.Template
+	if len(template.Spec.Containers) != 1 || template.Spec.Containers[0].Image != "foo:v1" {
+		t.Errorf("expected the container in the job template, got %#v", template.Spec.Containers)
+	}
+	if template.Metadata.Labels["name"] != "a-cronjob" {
+		t.Errorf("expected the labels of the pod template, got %#v", template.Metadata.Labels)
+	}
+}
+
+func TestClusterScopedIDs(t *testing.T) {
+	doc := `---
+kind: Namespace
+metadata:
+  name: monitoring
+---
+kind: ClusterRole
+metadata:
+  name: prometheus
+---
+kind: CustomResourceDefinition
+metadata:
+  name: alertmanagers.monitoring.coreos.com
+  namespace: monitoring
+---
+kind: Role
+metadata:
+  name: prometheus
+  namespace: monitoring
+`
+	objs, err := ParseMultidoc([]byte(doc), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{
+		"Namespace monitoring",
+		"ClusterRole prometheus",
+		"CustomResourceDefinition alertmanagers.monitoring.coreos.com",
+		"Role monitoring/prometheus",
+	} {
+		if _, ok := objs[id]; !ok {
+			t.Errorf("expected resource with ID %q, got %v", id, objs)
+		}
+	}
+}
+
+func TestWithDefaultNamespace(t *testing.T) {
+	doc := `---
+kind: Namespace
+metadata:
+  name: team-a
+---
+kind: Deployment
+metadata:
+  name: helloworld
+---
+kind: Service
+metadata:
+  name: helloworld
+---
+kind: Deployment
+metadata:
+  name: helloworld
+  namespace: other
+`
+	objs, err := ParseMultidoc([]byte(doc), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs = WithDefaultNamespace(objs, "team-a")
+	if len(objs) != 4 {
+		t.Errorf("expected four resources, got %v", objs)
+	}
+	for _, id := range []string{
+		"Namespace team-a",
+		"Deployment team-a/helloworld",
+		"Service team-a/helloworld",
+		"Deployment other/helloworld",
+	} {
+		if _, ok := objs[id]; !ok {
+			t.Errorf("expected resource with ID %q, got %v", id, objs)
+		}
+	}
+	svc, ok := objs["Service team-a/helloworld"].(*Service)
+	if !ok {
+		t.Fatalf("expected a Service, got %#v", objs["Service team-a/helloworld"])
+	}
+	if ids := svc.ServiceIDs(objs); len(ids) != 1 || ids[0] != "team-a/helloworld" {
+		t.Errorf("expected service ID team-a/helloworld, got %v", ids)
+	}
+}
@@ -29,7 +29,30 @@ type baseObject struct {
 	} `yaml:"metadata"`
 }
 
+// clusterScoped are the kinds of resource that don't belong to a
+// namespace, so are identified by name alone.
+var clusterScoped = map[string]bool{
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"StorageClass":                   true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"PodSecurityPolicy":              true,
+	"APIService":                     true,
+	"ThirdPartyResource":             true,
+	"ValidatingWebhookConfiguration": true,
+	"MutatingWebhookConfiguration":   true,
+}
+
+// ResourceID gives the kind, namespace and name of the resource; or
+// just the kind and name, if it's of a kind that doesn't belong to a
+// namespace.
 func (o baseObject) ResourceID() string {
+	if clusterScoped[o.Kind] {
+		return fmt.Sprintf("%s %s", o.Kind, o.Meta.Name)
+	}
 	ns := o.Meta.Namespace
 	if ns == "" {
 		ns = "default"
@@ -37,6 +60,14 @@ func (o baseObject) ResourceID() string {
 	return fmt.Sprintf("%s %s/%s", o.Kind, ns, o.Meta.Name)
 }
 
+// setDefaultNamespace puts the resource in the namespace given, if it
+// belongs in a namespace but its manifest doesn't say which.
+func (o *baseObject) setDefaultNamespace(namespace string) {
+	if o.Meta.Namespace == "" && !clusterScoped[o.Kind] {
+		o.Meta.Namespace = namespace
+	}
+}
+
 // It's useful for comparisons in tests to be able to remove the
 // record of bytes
 func (o *baseObject) debyte() {
@@ -79,12 +110,42 @@ func unmarshalObject(source string, bytes []byte) (resource.Resource, error) {
 			return nil, err
 		}
 		return &dep, nil
+	case "DeploymentConfig":
+		var dc = DeploymentConfig{baseObject: base}
+		if err := yaml.Unmarshal(bytes, &dc); err != nil {
+			return nil, err
+		}
+		return &dc, nil
+	case "DaemonSet":
+		var ds = DaemonSet{baseObject: base}
+		if err := yaml.Unmarshal(bytes, &ds); err != nil {
+			return nil, err
+		}
+		return &ds, nil
+	case "StatefulSet":
+		var ss = StatefulSet{baseObject: base}
+		if err := yaml.Unmarshal(bytes, &ss); err != nil {
+			return nil, err
+		}
+		return &ss, nil
+	case "CronJob":
+		var cj = CronJob{baseObject: base}
+		if err := yaml.Unmarshal(bytes, &cj); err != nil {
+			return nil, err
+		}
+		return &cj, nil
 	case "Service":
 		var svc = Service{baseObject: base}
 		if err := yaml.Unmarshal(bytes, &svc); err != nil {
 			return nil, err
 		}
 		return &svc, nil
+	case "List":
+		list, err := unmarshalList(base, bytes)
+		if err != nil {
+			return nil, err
+		}
+		return list, nil
 	case "Namespace":
 		var ns = Namespace{baseObject: base}
 		if err := yaml.Unmarshal(bytes, &ns); err != nil {
@@ -0,0 +1,30 @@
+package resource
+
+import (
+	"k8s.io/client-go/1.5/pkg/labels"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/resource"
+)
+
+type StatefulSet struct {
+	baseObject
+	Spec StatefulSetSpec
+}
+
+func (o StatefulSet) ServiceIDs(all map[string]resource.Resource) []flux.ServiceID {
+	found := flux.ServiceIDSet{}
+	for _, r := range all {
+		s, ok := r.(*Service)
+		if ok && s.Meta.Namespace == o.Meta.Namespace && s.Matches(labels.Set(o.Spec.Template.Metadata.Labels)) {
+			found.Add(s.ServiceIDs(all))
+		}
+	}
+
+	return found.ToSlice()
+}
+
+type StatefulSetSpec struct {
+	Replicas int
+	Template PodTemplate
+}
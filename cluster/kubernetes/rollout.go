@@ -0,0 +1,128 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/client-go/1.5/pkg/api/errors"
+
+	"github.com/weaveworks/flux/cluster"
+)
+
+// What a release of a service will disturb depends on how many pods
+// it has, how they are replaced, and whether a PodDisruptionBudget
+// covers them. The client library we use predates PodDisruptionBudgets
+// being in policy/v1beta1, so like DeploymentConfigs they are asked
+// for with a plain REST request.
+
+type podDisruptionBudget struct {
+	Spec struct {
+		Selector *labelSelector `json:"selector"`
+	} `json:"spec"`
+}
+
+type podDisruptionBudgetList struct {
+	Items []podDisruptionBudget `json:"items"`
+}
+
+type labelSelector struct {
+	MatchLabels      map[string]string `json:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `json:"key"`
+		Operator string   `json:"operator"`
+		Values   []string `json:"values"`
+	} `json:"matchExpressions"`
+}
+
+// matches says whether the selector selects pods with the labels
+// given. As with a Service, a nil selector matches nothing; but an
+// empty one matches everything.
+func (s *labelSelector) matches(labels map[string]string) bool {
+	if s == nil {
+		return false
+	}
+	for k, v := range s.MatchLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	for _, expr := range s.MatchExpressions {
+		value, ok := labels[expr.Key]
+		in := false
+		for _, v := range expr.Values {
+			if ok && v == value {
+				in = true
+				break
+			}
+		}
+		switch expr.Operator {
+		case "In":
+			if !in {
+				return false
+			}
+		case "NotIn":
+			if in {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// podDisruptionBudgets lists the PodDisruptionBudgets in a namespace.
+// If the cluster is too old to have them, or we're not allowed to
+// look, there are none.
+func (c *Cluster) podDisruptionBudgets(namespace string) ([]podDisruptionBudget, error) {
+	body, err := c.client.CoreInterface.GetRESTClient().Get().
+		AbsPath("/apis/policy/v1beta1/namespaces", namespace, "poddisruptionbudgets").
+		DoRaw()
+	if err != nil {
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list podDisruptionBudgetList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, errors.Wrap(err, "decoding pod disruption budgets")
+	}
+	return list.Items, nil
+}
+
+// rollout describes what releasing the pod controller would do to its
+// pods.
+func (p podController) rollout(budgets []podDisruptionBudget) cluster.Rollout {
+	var r cluster.Rollout
+	switch {
+	case p.Deployment != nil:
+		if p.Deployment.Spec.Replicas != nil {
+			r.Pods = int(*p.Deployment.Spec.Replicas)
+		}
+		r.Strategy = string(p.Deployment.Spec.Strategy.Type)
+	case p.DeploymentConfig != nil:
+		r.Pods = int(p.DeploymentConfig.Spec.Replicas)
+		r.Strategy = p.DeploymentConfig.Spec.Strategy.Type
+	case p.ReplicationController != nil:
+		if p.ReplicationController.Spec.Replicas != nil {
+			r.Pods = int(*p.ReplicationController.Spec.Replicas)
+		}
+	}
+	labels := p.templateLabels()
+	for _, budget := range budgets {
+		if budget.Spec.Selector.matches(labels) {
+			r.DisruptionBudget = true
+			break
+		}
+	}
+	return r
+}
@@ -0,0 +1,32 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"name": "helloworld", "tier": "frontend"}
+	for selector, expected := range map[string]bool{
+		`{}`: true,
+		`{"matchLabels": {"name": "helloworld"}}`:                                                      true,
+		`{"matchLabels": {"name": "sidecar"}}`:                                                         false,
+		`{"matchExpressions": [{"key": "tier", "operator": "In", "values": ["frontend", "backend"]}]}`: true,
+		`{"matchExpressions": [{"key": "tier", "operator": "NotIn", "values": ["frontend"]}]}`:         false,
+		`{"matchExpressions": [{"key": "canary", "operator": "DoesNotExist"}]}`:                        true,
+		`{"matchExpressions": [{"key": "canary", "operator": "Exists"}]}`:                              false,
+	} {
+		var s labelSelector
+		if err := json.Unmarshal([]byte(selector), &s); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.matches(labels); got != expected {
+			t.Errorf("%s: expected %v, got %v", selector, expected, got)
+		}
+	}
+
+	var none *labelSelector
+	if none.matches(labels) {
+		t.Error("expected a nil selector to match nothing")
+	}
+}
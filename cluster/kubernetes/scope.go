@@ -0,0 +1,244 @@
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/resource"
+)
+
+// Scope limits which manifests in a repo flux looks at, so that a
+// repo can be shared with others (e.g., other teams, each with their
+// own flux) without flux syncing, releasing or automating what isn't
+// its own.
+type Scope struct {
+	// Include gives the paths to look in, as globs relative to the
+	// top of the manifests; a glob matching a directory includes
+	// everything under it. If none are given, everything is
+	// included.
+	Include []string
+	// Exclude gives paths not to look in, even if they are included.
+	Exclude []string
+	// Namespaces are those to look at resources in. If none are
+	// given, all namespaces are looked at.
+	Namespaces []string
+}
+
+// IsEmpty says whether the scope lets everything through.
+func (s Scope) IsEmpty() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0 && len(s.Namespaces) == 0
+}
+
+// Validate checks that the path globs are well-formed.
+func (s Scope) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad path glob %q: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchPath says whether the path, or one of the directories it's
+// in, matches the glob.
+func matchPath(pattern, path string) bool {
+	for p := filepath.Clean(path); p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		if ok, _ := filepath.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// includesPath says whether a file, given relative to the top of the
+// manifests, is in scope.
+func (s Scope) includesPath(rel string) bool {
+	for _, pattern := range s.Exclude {
+		if matchPath(pattern, rel) {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, pattern := range s.Include {
+		if matchPath(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Scope) includesNamespace(namespace string) bool {
+	if len(s.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range s.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Scope) includesService(id flux.ServiceID) bool {
+	namespace, _ := id.Components()
+	return s.includesNamespace(namespace)
+}
+
+// includesResource looks at the namespace of a resource, which is in
+// its ID, "<kind> <namespace>/<name>".
+func (s Scope) includesResource(id string) bool {
+	if len(s.Namespaces) == 0 {
+		return true
+	}
+	i, j := strings.Index(id, " "), strings.Index(id, "/")
+	if i < 0 || j < i {
+		return false
+	}
+	return s.includesNamespace(id[i+1 : j])
+}
+
+type scopedManifests struct {
+	cluster.Manifests
+	scope Scope
+}
+
+// ScopeManifests wraps the manifests given so that only what is in
+// scope is found in the repo, or among the resources parsed from an
+// export of the cluster. Manifests loaded by naming the file, rather
+// than a directory containing it, can't be placed relative to the
+// top of the manifests, so are only limited by namespace.
+func ScopeManifests(m cluster.Manifests, scope Scope) cluster.Manifests {
+	if scope.IsEmpty() {
+		return m
+	}
+	return &scopedManifests{Manifests: m, scope: scope}
+}
+
+func (m *scopedManifests) filterFiles(root string, services map[flux.ServiceID][]string) map[flux.ServiceID][]string {
+	result := map[flux.ServiceID][]string{}
+	for id, paths := range services {
+		if !m.scope.includesService(id) {
+			continue
+		}
+		var inScope []string
+		for _, path := range paths {
+			if rel, err := filepath.Rel(root, path); err == nil && m.scope.includesPath(rel) {
+				inScope = append(inScope, path)
+			}
+		}
+		if len(inScope) > 0 {
+			result[id] = inScope
+		}
+	}
+	return result
+}
+
+func (m *scopedManifests) FindDefinedServices(root string) (map[flux.ServiceID][]string, error) {
+	services, err := m.Manifests.FindDefinedServices(root)
+	if err != nil {
+		return nil, err
+	}
+	return m.filterFiles(root, services), nil
+}
+
+func (m *scopedManifests) FindPolicyFiles(root string) (map[flux.ServiceID][]string, error) {
+	find := m.Manifests.FindDefinedServices
+	if pm, ok := m.Manifests.(cluster.PolicyManifests); ok {
+		find = pm.FindPolicyFiles
+	}
+	services, err := find(root)
+	if err != nil {
+		return nil, err
+	}
+	return m.filterFiles(root, services), nil
+}
+
+func (m *scopedManifests) ServiceTopology(root string) ([]flux.ServiceTopology, error) {
+	topology, err := m.Manifests.ServiceTopology(root)
+	if err != nil {
+		return nil, err
+	}
+	var result []flux.ServiceTopology
+	for _, t := range topology {
+		if m.scope.includesService(t.ID) && m.scope.includesPath(t.File) {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// ServicesWithPolicy only gives services that are defined in scope.
+func (m *scopedManifests) ServicesWithPolicy(root string, p policy.Policy) (policy.ServiceMap, error) {
+	services, err := m.Manifests.ServicesWithPolicy(root, p)
+	if err != nil {
+		return nil, err
+	}
+	defined, err := m.FindPolicyFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	result := policy.ServiceMap{}
+	for id, policies := range services {
+		if _, ok := defined[id]; ok {
+			result[id] = policies
+		}
+	}
+	return result, nil
+}
+
+func (m *scopedManifests) LoadManifests(paths ...string) (map[string]resource.Resource, error) {
+	resources, err := m.Manifests.LoadManifests(paths...)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs = append(dirs, path)
+		}
+	}
+	result := map[string]resource.Resource{}
+	for id, res := range resources {
+		if !m.scope.includesResource(id) {
+			continue
+		}
+		if rel, ok := relativeTo(dirs, res.Source()); ok && !m.scope.includesPath(rel) {
+			continue
+		}
+		result[id] = res
+	}
+	return result, nil
+}
+
+// relativeTo gives the path relative to the first of the directories
+// that it's within.
+func relativeTo(dirs []string, path string) (string, bool) {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+func (m *scopedManifests) ParseManifests(def []byte) (map[string]resource.Resource, error) {
+	resources, err := m.Manifests.ParseManifests(def)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]resource.Resource{}
+	for id, res := range resources {
+		if m.scope.includesResource(id) {
+			result[id] = res
+		}
+	}
+	return result, nil
+}
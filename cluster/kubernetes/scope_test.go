@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
+	"github.com/weaveworks/flux/policy"
+)
+
+const scopedDeployment = `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: %s
+  namespace: %s
+  annotations:
+    flux.weave.works/automated: "true"
+spec:
+  template:
+    metadata:
+      labels:
+        name: %s
+    spec:
+      containers:
+      - name: %s
+        image: quay.io/weaveworks/%s:master-a000001
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: %s
+  namespace: %s
+spec:
+  selector:
+    name: %s
+`
+
+func writeScopedFiles(t *testing.T, dir string) {
+	for path, nsName := range map[string][2]string{
+		"team-a/hello.yaml":         {"team-a", "hello"},
+		"team-a/vendored/lib.yaml":  {"team-a", "lib"},
+		"team-b/goodbye.yaml":       {"team-b", "goodbye"},
+		"misplaced/elsewhere.yaml":  {"team-a", "elsewhere"},
+		"shared/team-b-shared.yaml": {"team-b", "shared"},
+	} {
+		ns, name := nsName[0], nsName[1]
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		def := []byte(fmt.Sprintf(scopedDeployment, name, ns, name, name, name, name, ns, name))
+		if err := ioutil.WriteFile(path, def, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScopeManifests(t *testing.T) {
+	dir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	writeScopedFiles(t, dir)
+
+	scope := Scope{
+		Include:    []string{"team-*", "shared"},
+		Exclude:    []string{"*/vendored"},
+		Namespaces: []string{"team-a"},
+	}
+	if err := scope.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	m := ScopeManifests(&Manifests{}, scope)
+
+	services, err := m.FindDefinedServices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[flux.ServiceID][]string{
+		flux.MakeServiceID("team-a", "hello"): {filepath.Join(dir, "team-a/hello.yaml")},
+	}
+	if !reflect.DeepEqual(expected, services) {
+		t.Errorf("Expected:\n%#v\ngot:\n%#v", expected, services)
+	}
+
+	automated, err := m.ServicesWithPolicy(dir, policy.Automated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := automated[flux.MakeServiceID("team-a", "hello")]; !ok || len(automated) != 1 {
+		t.Errorf("expected only team-a/hello to be automated, got %v", automated)
+	}
+
+	resources, err := m.LoadManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if expected := []string{"Deployment team-a/hello", "Service team-a/hello"}; !reflect.DeepEqual(expected, ids) {
+		t.Errorf("expected to load %v, got %v", expected, ids)
+	}
+
+	// Files named directly can only be limited by namespace
+	resources, err = m.LoadManifests(filepath.Join(dir, "misplaced/elsewhere.yaml"), filepath.Join(dir, "team-b/goodbye.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resources["Deployment team-a/elsewhere"]; !ok || len(resources) != 2 {
+		t.Errorf("expected to load only team-a/elsewhere, got %v", resources)
+	}
+
+	if err := (Scope{Include: []string{"team-["}}).Validate(); err == nil {
+		t.Error("expected error for bad glob")
+	}
+	if unscoped := (&Manifests{}); ScopeManifests(unscoped, Scope{}) != unscoped {
+		t.Error("expected an empty scope to leave the manifests as they are")
+	}
+}
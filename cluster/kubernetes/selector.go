@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
+)
+
+// SwitchSelector changes the selector of the Service defined in
+// serviceDef to select the pods of the pod controller defined in
+// workloadDef. The selector keeps the same keys, and takes their
+// values from the workload's pod labels; so, for a blue/green pair
+// labelled `color: blue` and `color: green`, a Service selecting
+// `{app: web, color: blue}` is switched to `{app: web, color:
+// green}`. The rest of the file is left as it was.
+func (m *Manifests) SwitchSelector(serviceDef, workloadDef []byte) ([]byte, error) {
+	workloads, err := splitDocuments(workloadDef)
+	if err != nil {
+		return nil, err
+	}
+	workload, err := podControllerDocument(workloads)
+	if err != nil {
+		return nil, err
+	}
+	labels := workload.manifest.PodLabels()
+
+	docs, err := splitDocuments(serviceDef)
+	if err != nil {
+		return nil, err
+	}
+	var services []document
+	for _, doc := range docs {
+		if doc.manifest.Kind == "Service" {
+			services = append(services, doc)
+		}
+	}
+	if len(services) != 1 {
+		return nil, errors.Errorf("expected one Service in the file, found %d", len(services))
+	}
+	return editDocument(serviceDef, services[0], func(def []byte) ([]byte, error) {
+		return switchDocumentSelector(def, workload.manifest.Metadata.Name, labels)
+	})
+}
+
+func switchDocumentSelector(def []byte, workload string, labels map[string]string) ([]byte, error) {
+	var service struct {
+		Spec struct {
+			Selector map[string]string `yaml:"selector"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal(def, &service); err != nil {
+		return nil, errors.Wrap(err, "decoding selector")
+	}
+	if len(service.Spec.Selector) == 0 {
+		return nil, errors.New("service has no selector to switch")
+	}
+
+	var keys []string
+	changed := false
+	for k, v := range service.Spec.Selector {
+		to, ok := labels[k]
+		if !ok {
+			return nil, errors.Errorf("pods of %s have no label %q, so cannot be selected", workload, k)
+		}
+		if to != v {
+			changed = true
+		}
+		keys = append(keys, k)
+	}
+	if !changed {
+		return nil, errors.Errorf("service already selects the pods of %s", workload)
+	}
+	sort.Strings(keys)
+
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if labels[k] == service.Spec.Selector[k] {
+			continue
+		}
+		if err := doc.Set(labels[k], "spec", "selector", k); err != nil {
+			return nil, errors.Wrapf(err, "setting selector %s", k)
+		}
+	}
+	return doc.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package kubernetes
+
+import (
+	"testing"
+)
+
+const routerDef = `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+  selector:
+    app: web
+    color: blue # the live one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  color: blue
+`
+
+const greenDef = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web-green
+  annotations:
+    flux.weave.works/bluegreen: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        color: green
+        version: two
+    spec:
+      containers:
+      - name: web
+        image: quay.io/weaveworks/web:2
+`
+
+func TestSwitchSelector(t *testing.T) {
+	m := &Manifests{}
+	out, err := m.SwitchSelector([]byte(routerDef), []byte(greenDef))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+  selector:
+    app: web
+    color: green # the live one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  color: blue
+`
+	if string(out) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	// Switching again to the same workload is a mistake
+	if _, err := m.SwitchSelector(out, []byte(greenDef)); err == nil {
+		t.Error("expected an error switching to the workload already selected")
+	}
+
+	// The workload has to have a label for each key of the selector
+	unlabelled := []byte(`apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web-green
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: quay.io/weaveworks/web:2
+`)
+	if _, err := m.SwitchSelector([]byte(routerDef), unlabelled); err == nil {
+		t.Error("expected an error switching to a workload without the selector's labels")
+	}
+}
@@ -0,0 +1,34 @@
+package kubernetes
+
+import (
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
+	apiext "k8s.io/client-go/1.5/pkg/apis/extensions/v1beta1"
+)
+
+// The API server doesn't promise to list things in any particular
+// order, so the export sorts them by name; otherwise, the same
+// cluster could give a different export each time.
+
+type namespacesByName []v1.Namespace
+
+func (s namespacesByName) Len() int           { return len(s) }
+func (s namespacesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s namespacesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type deploymentsByName []apiext.Deployment
+
+func (s deploymentsByName) Len() int           { return len(s) }
+func (s deploymentsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s deploymentsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type replicationControllersByName []v1.ReplicationController
+
+func (s replicationControllersByName) Len() int           { return len(s) }
+func (s replicationControllersByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s replicationControllersByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type servicesByName []v1.Service
+
+func (s servicesByName) Len() int           { return len(s) }
+func (s servicesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s servicesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
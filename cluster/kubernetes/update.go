@@ -4,21 +4,47 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"regexp"
-	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/cluster/kubernetes/yamledit"
 )
 
-// updatePodController takes the body of a ReplicationController or Deployment
-// resource definition (specified in YAML) and the name of the new image that
-// should be put in the definition (in the format "repo.org/group/name:tag"). It
-// returns a new resource definition body where all references to the old image
-// have been replaced with the new one.
+// updatePodController takes the body of a pod controller (e.g., a Deployment,
+// DaemonSet or CronJob) resource definition (specified in YAML) and the name of
+// the new image that should be put in the definition (in the format
+// "repo.org/group/name:tag"). It returns a new resource definition body where
+// all references to the old image have been replaced with the new one.
+//
+// If the file has several resources in it, only the pod controller with
+// the container is changed.
 //
 // This function has many additional requirements that are likely in flux. Read
 // the source to learn about them.
 func updatePodController(def []byte, container string, newImageID flux.ImageID) ([]byte, error) {
+	docs, err := splitDocuments(def)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := containerDocument(docs, func(c Container) bool {
+		if c.Name != container {
+			return false
+		}
+		currentImage, err := flux.ParseImageID(c.Image)
+		return err == nil && currentImage.Repository() == newImageID.Repository()
+	})
+	if !ok {
+		return nil, fmt.Errorf("could not find container using image: %s", newImageID.Repository())
+	}
+	return editDocument(def, target, func(def []byte) ([]byte, error) {
+		return updateDocument(def, container, newImageID)
+	})
+}
+
+func updateDocument(def []byte, container string, newImageID flux.ImageID) ([]byte, error) {
 	// Sanity check
 	obj, err := definitionObj(def)
 	if err != nil {
@@ -27,8 +53,16 @@ func updatePodController(def []byte, container string, newImageID flux.ImageID)
 	switch obj.Kind {
 	case "ReplicationController":
 		return nil, ErrReplicationControllersDeprecated
-	case "Deployment":
+	case "Deployment", "DaemonSet", "StatefulSet", "CronJob":
 		break
+	case "DeploymentConfig":
+		var dc resource.DeploymentConfig
+		if err := yaml.Unmarshal(def, &dc); err != nil {
+			return nil, err
+		}
+		if trigger := dc.ImageTrigger(container); trigger != nil {
+			return nil, ImageStreamTriggerError(container, trigger.From)
+		}
 	default:
 		return nil, UpdateNotSupportedError(obj.Kind)
 	}
@@ -38,9 +72,11 @@ func updatePodController(def []byte, container string, newImageID flux.ImageID)
 	return buf.Bytes(), err
 }
 
-// Attempt to update an RC or Deployment config. This makes several assumptions
-// that are justified only with the phrase "because that's how we do it",
-// including:
+// Attempt to update an RC or Deployment config. The changes are made
+// to the YAML in place, so the rest of the definition -- comments,
+// the order of keys, quoting -- stays as it was. This makes a few
+// assumptions that are justified only with the phrase "because that's
+// how we do it", including:
 //
 //  * the file is a replication controller or deployment
 //  * the update is from one tag of an image to another tag of the
@@ -48,34 +84,31 @@ func updatePodController(def []byte, container string, newImageID flux.ImageID)
 //    "weaveworks/helloworld:a00002"
 //  * the container spec to update is the (first) one that uses the
 //    same image name (e.g., weaveworks/helloworld)
-//  * the name of the controller is updated to reflect the new tag
-//  * there's a label which must be updated in both the pod spec and the selector
-//  * the file uses canonical YAML syntax, that is, one line per item
-//  * ... other assumptions as encoded in the regular expressions used
+//  * a `version` label, if there is one in the pod template or the
+//    selector, is the image tag and must be updated along with it
 //
 // Here's an example of the assumed structure:
 //
 // ```
 // apiVersion: v1
 // kind: ReplicationController # not presently checked
-// metadata:                         # )
-//   ...                             # ) any number of equally-indented lines
-//   name: helloworld-master-a000001 # ) can precede the name
+// metadata:
+//   name: helloworld-master
 // spec:
 //   replicas: 2
-//   selector:                 # )
-//     name: helloworld        # ) this use of labels is assumed
-//     version: master-a000001 # )
+//   selector:
+//     name: helloworld
+//     version: master-a000001 # optional; updated if present
 //   template:
 //     metadata:
-//       labels:                   # )
-//         name: helloworld        # ) this structure is assumed, as for the selector
-//         version: master-a000001 # )
+//       labels:
+//         name: helloworld
+//         version: master-a000001 # optional; updated if present
 //     spec:
 //       containers:
 //       # extra container specs are allowed here ...
-//       - name: helloworld                                    # )
-//         image: quay.io/weaveworks/helloworld:master-a000001 # ) these must be together
+//       - name: helloworld
+//         image: quay.io/weaveworks/helloworld:master-a000001
 //         args:
 //         - -msg=Ahoy
 //         ports:
@@ -90,12 +123,13 @@ func tryUpdate(def []byte, container string, newImage flux.ImageID, out io.Write
 		return fmt.Errorf("could not find resource name")
 	}
 
-	// Check if any containers need updating. As we go through, we calculate the
-	// new manifest name, in case it includes the image tag (as in replication
-	// controllers).
-	newDefName := manifest.Metadata.Name
-	matchingContainers := map[int]Container{}
-	for i, c := range manifest.Spec.Template.Spec.Containers {
+	doc, err := yamledit.Parse(def)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for i, c := range manifest.Containers() {
 		if c.Name != container {
 			continue
 		}
@@ -103,95 +137,32 @@ func tryUpdate(def []byte, container string, newImage flux.ImageID, out io.Write
 		if err != nil {
 			return fmt.Errorf("could not parse image %s", c.Image)
 		}
-		if currentImage.Repository() == newImage.Repository() {
-			matchingContainers[i] = c
+		if currentImage.Repository() != newImage.Repository() {
+			continue
 		}
-		_, _, oldImageTag := currentImage.Components()
-		if strings.HasSuffix(manifest.Metadata.Name, oldImageTag) {
-			newDefName = manifest.Metadata.Name[:len(manifest.Metadata.Name)-len(oldImageTag)] + newImage.Tag
+		if err := doc.Set(newImage.String(), manifest.containerPath(i, "image")...); err != nil {
+			return errors.Wrap(err, "updating container image")
 		}
+		found = true
 	}
-
-	// Some values (most likely the version) will be interpreted as a
-	// number if unquoted; while, on the other hand, it is apparently
-	// not OK to quote things that don't look like numbers. So: we
-	// extract values *without* quotes, and add them if necessary.
-	newDefName = maybeQuote(newDefName)
-
-	if len(matchingContainers) == 0 {
+	if !found {
 		return fmt.Errorf("could not find container using image: %s", newImage.Repository())
 	}
 
-	// Detect how indented the "containers" block is.
-	newDef := string(def)
-	matches := regexp.MustCompile(`( +)containers:.*`).FindStringSubmatch(newDef)
-	if len(matches) != 2 {
-		return fmt.Errorf("could not find container specs")
-	}
-	indent := matches[1]
-
-	// Replace the container images
-	// Parse out all the container blocks
-	containersRE := regexp.MustCompile(`(?m:^` + indent + `containers:\s*(?:#.*)*$(?:\n(?:` + indent + `[-\s].*)?)*)`)
-	// Parse out an individual container blog
-	containerRE := regexp.MustCompile(`(?m:` + indent + `-.*(?:\n(?:` + indent + `\s+.*)?)*)`)
-	// Parse out the image ID
-	imageRE := regexp.MustCompile(`(` + indent + `[-\s]\s*"?image"?:\s*)"?(?:[\w\.\-/:]+\s*?)*"?([\t\f #]+.*)?`)
-	imageReplacement := fmt.Sprintf("${1}%s${2}", maybeQuote(newImage.String()))
-	// Find the block of container specs
-	newDef = containersRE.ReplaceAllStringFunc(newDef, func(containers string) string {
-		i := 0
-		// Find each container spec
-		return containerRE.ReplaceAllStringFunc(containers, func(spec string) string {
-			if _, ok := matchingContainers[i]; ok {
-				// container matches, let's replace the image
-				spec = imageRE.ReplaceAllString(spec, imageReplacement)
-			}
-			i++
-			return spec
-		})
-	})
-
-	// The name we want is that under `metadata:`, which will *probably* be the first one
-	replacedName := false
-	replaceRCNameRE := regexp.MustCompile(`(\s+"?name"?:\s*)"?(?:[\w\.\-/:]+\s*?)"?([\t\f #]+.*)`)
-	replaceRCNameRE.ReplaceAllStringFunc(newDef, func(found string) string {
-		if replacedName {
-			return found
+	// Replacing labels: these are in two places, the pod template and
+	// the selector
+	for _, path := range [][]string{
+		{"spec", "selector", "version"},
+		append(manifest.podTemplatePath(), "metadata", "labels", "version"),
+	} {
+		if _, ok := doc.Get(path...); !ok {
+			continue
+		}
+		if err := doc.Set(newImage.Tag, path...); err != nil {
+			return errors.Wrap(err, "updating version label")
 		}
-		replacedName = true
-		return replaceRCNameRE.ReplaceAllString(found, fmt.Sprintf(`${1}%s${2}`, newDefName))
-	})
-
-	// Replacing labels: these are in two places, the container template and the selector
-	// TODO: This doesn't handle # comments
-	// TODO: This encodes an expectation of map keys being ordered (i.e. name *then* version)
-	// TODO: This assumes that these are indented by exactly 2 spaces (which may not be true)
-	replaceLabelsRE := multilineRE(
-		`((?:  selector|      labels):.*)`,
-		`((?:  ){2,4}name:.*)`,
-		`((?:  ){2,4}version:\s*) (?:"?[-\w]+"?)(\s.*)`,
-	)
-	replaceLabels := fmt.Sprintf("$1\n$2\n$3 %s$4", maybeQuote(newImage.Tag))
-	newDef = replaceLabelsRE.ReplaceAllString(newDef, replaceLabels)
-
-	fmt.Fprint(out, newDef)
-	return nil
-}
-
-func multilineRE(lines ...string) *regexp.Regexp {
-	return regexp.MustCompile(`(?m:^` + strings.Join(lines, "\n") + `$)`)
-}
-
-var looksLikeNumber *regexp.Regexp = regexp.MustCompile("^(" + strings.Join([]string{
-	`(-?[1-9](\.[0-9]*[1-9])?(e[-+][1-9][0-9]*)?)`,
-	`(-?(0|[1-9][0-9]*))`,
-	`(0|(\.inf)|(-\.inf)|(\.nan))`},
-	"|") + ")$")
-
-func maybeQuote(scalar string) string {
-	if looksLikeNumber.MatchString(scalar) {
-		return `"` + scalar + `"`
 	}
-	return scalar
+
+	_, err = out.Write(doc.Bytes())
+	return err
 }
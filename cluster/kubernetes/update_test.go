@@ -6,6 +6,7 @@ import (
 
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/weaveworks/flux"
 )
@@ -437,3 +438,90 @@ spec:
       - image: nginx:1.10-alpine # testing comments, and this image is on the first line.
         name: nginx2
 `
+
+func TestUpdateDeploymentConfig(t *testing.T) {
+	id, err := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := updatePodController([]byte(caseDC), "helloworld", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "image: quay.io/weaveworks/helloworld:master-a000002") {
+		t.Errorf("expected image to be updated, got:\n%s", out)
+	}
+
+	id, err = flux.ParseImageID("quay.io/weaveworks/sidecar:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = updatePodController([]byte(caseDC), "sidecar", id); err == nil {
+		t.Error("expected error updating container with an image change trigger")
+	}
+}
+
+const caseDC = `---
+apiVersion: v1
+kind: DeploymentConfig
+metadata:
+  name: helloworld
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        name: helloworld
+    spec:
+      containers:
+      - name: helloworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+      - name: sidecar
+        image: quay.io/weaveworks/sidecar:1
+  triggers:
+  - type: ConfigChange
+  - type: ImageChange
+    imageChangeParams:
+      automatic: true
+      containerNames:
+      - sidecar
+      from:
+        kind: ImageStreamTag
+        name: sidecar:latest
+`
+
+func TestUpdateCronJob(t *testing.T) {
+	image, _ := flux.ParseImageID("quay.io/weaveworks/reporter:master-a000002")
+	out, err := updatePodController([]byte(cronJob), "reporter", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(cronJob, "master-a000001", "master-a000002", -1)
+	if string(out) != expected {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", expected, out)
+	}
+}
+
+// The pod template of a CronJob is in its job template
+const cronJob = `---
+apiVersion: batch/v2alpha1
+kind: CronJob
+metadata:
+  name: reporter
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            name: reporter
+            version: master-a000001
+        spec:
+          containers:
+          - name: reporter
+            image: quay.io/weaveworks/reporter:master-a000001
+          restartPolicy: OnFailure
+`
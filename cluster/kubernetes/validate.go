@@ -0,0 +1,205 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux"
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/resource"
+)
+
+// ValidateUpdate checks a manifest file as it is after being changed
+// by flux: it must still parse, define exactly the resources it did
+// before, and each of those must have the fields Kubernetes insists
+// on. This is a check on the edit, not on the file as a whole, so a
+// problem that was already there before is not reported.
+func (m *Manifests) ValidateUpdate(before, after []byte) error {
+	was, err := kresource.ParseMultidoc(before, "before")
+	if err != nil {
+		return errors.Wrap(err, "parsing manifest before update")
+	}
+	is, err := kresource.ParseMultidoc(after, "after")
+	if err != nil {
+		return errors.Wrap(err, "updated manifest does not parse")
+	}
+	if err := sameResources(was, is); err != nil {
+		return err
+	}
+
+	wasObjs, err := objectsIn(before)
+	if err != nil {
+		return errors.Wrap(err, "parsing manifest before update")
+	}
+	isObjs, err := objectsIn(after)
+	if err != nil {
+		return errors.Wrap(err, "updated manifest does not parse")
+	}
+	problemsBefore := map[string]string{}
+	for _, obj := range wasObjs {
+		problemsBefore[objectID(obj)] = validateObject(obj)
+	}
+	for _, obj := range isObjs {
+		id := objectID(obj)
+		if problem := validateObject(obj); problem != "" && problem != problemsBefore[id] {
+			return fmt.Errorf("updated manifest is not valid: %s: %s", id, problem)
+		}
+	}
+	return nil
+}
+
+func sameResources(before, after map[string]resource.Resource) error {
+	var missing, extra []string
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	for id := range after {
+		if _, ok := before[id]; !ok {
+			extra = append(extra, id)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	switch {
+	case len(missing) > 0 && len(extra) > 0:
+		return fmt.Errorf("updated manifest defines %s in place of %s", strings.Join(extra, ", "), strings.Join(missing, ", "))
+	case len(missing) > 0:
+		return fmt.Errorf("updated manifest no longer defines %s", strings.Join(missing, ", "))
+	case len(extra) > 0:
+		return fmt.Errorf("updated manifest now also defines %s", strings.Join(extra, ", "))
+	}
+	return nil
+}
+
+type object map[interface{}]interface{}
+
+// objectsIn decodes each resource in a manifest file, with the items
+// of any List taken out, in the order they appear.
+func objectsIn(def []byte) ([]object, error) {
+	docs, err := splitDocuments(def)
+	if err != nil {
+		return nil, err
+	}
+	var objs []object
+	for i, doc := range docs {
+		// The items of a List share a document; decode it just once
+		if i > 0 && doc.item != nil && docs[i-1].start == doc.start {
+			continue
+		}
+		// Decoded as a plain map, so that nested objects are too
+		var raw map[interface{}]interface{}
+		if err := yaml.Unmarshal(def[doc.start:doc.end], &raw); err != nil {
+			return nil, err
+		}
+		obj := object(raw)
+		if obj["kind"] != "List" {
+			objs = append(objs, obj)
+			continue
+		}
+		items, _ := obj["items"].([]interface{})
+		for _, item := range items {
+			itemObj, ok := item.(map[interface{}]interface{})
+			if !ok {
+				return nil, errors.New("item in List is not an object")
+			}
+			objs = append(objs, object(itemObj))
+		}
+	}
+	return objs, nil
+}
+
+// lookup follows the path of fields given into the object, giving
+// nil if any along the way are missing or aren't objects.
+func (o object) lookup(path ...string) interface{} {
+	var val interface{} = map[interface{}]interface{}(o)
+	for _, field := range path {
+		obj, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		val = obj[field]
+	}
+	return val
+}
+
+func objectID(obj object) string {
+	kind, _ := obj.lookup("kind").(string)
+	name, _ := obj.lookup("metadata", "name").(string)
+	namespace, _ := obj.lookup("metadata", "namespace").(string)
+	if namespace == "" {
+		namespace = "default"
+	}
+	return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+}
+
+// validateObject describes the first thing found wrong with the
+// resource, or gives "" if there's nothing wrong. It checks only the
+// fields that flux (or the API server, for what flux edits) depends
+// on, rather than the full schema for the kind.
+func validateObject(obj object) string {
+	for _, field := range []string{"apiVersion", "kind"} {
+		if s, _ := obj.lookup(field).(string); s == "" {
+			return fmt.Sprintf("%s is missing or not a string", field)
+		}
+	}
+	if s, _ := obj.lookup("metadata", "name").(string); s == "" {
+		return "metadata.name is missing or not a string"
+	}
+	for _, field := range []string{"labels", "annotations"} {
+		val := obj.lookup("metadata", field)
+		if val == nil {
+			continue
+		}
+		m, ok := val.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Sprintf("metadata.%s is not a map", field)
+		}
+		for k, v := range m {
+			if _, ok := v.(string); !ok {
+				return fmt.Sprintf("value of metadata.%s.%v is not a string", field, k)
+			}
+		}
+	}
+
+	kind := obj.lookup("kind").(string)
+	if !podControllerKinds[kind] {
+		return ""
+	}
+	templatePath := []string{"spec", "template"}
+	if kind == "CronJob" {
+		templatePath = []string{"spec", "jobTemplate", "spec", "template"}
+	}
+	containers, _ := obj.lookup(append(templatePath, "spec", "containers")...).([]interface{})
+	if len(containers) == 0 {
+		return "pod template has no containers"
+	}
+	names := map[string]bool{}
+	for i, c := range containers {
+		container := object{}
+		if m, ok := c.(map[interface{}]interface{}); ok {
+			container = object(m)
+		}
+		name, _ := container.lookup("name").(string)
+		if name == "" {
+			return fmt.Sprintf("container %d has no name", i)
+		}
+		if names[name] {
+			return fmt.Sprintf("more than one container is named %q", name)
+		}
+		names[name] = true
+		image, _ := container.lookup("image").(string)
+		if image == "" {
+			return fmt.Sprintf("container %q has no image", name)
+		}
+		if _, err := flux.ParseImageID(image); err != nil {
+			return fmt.Sprintf("container %q has a bad image: %s", name, err)
+		}
+	}
+	return ""
+}
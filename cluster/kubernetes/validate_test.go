@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+)
+
+const validateBefore = `apiVersion: v1
+kind: Service
+metadata:
+  name: helloworld
+spec:
+  selector:
+    name: helloworld
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: helloworld
+  annotations:
+    flux.weave.works/automated: "true"
+spec:
+  template:
+    metadata:
+      labels:
+        name: helloworld
+    spec:
+      containers:
+      - name: greeter
+        image: quay.io/weaveworks/helloworld:master-a000001
+      - name: sidecar
+        image: quay.io/weaveworks/sidecar:master-a000001
+`
+
+func TestValidateUpdate(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		old, new   string
+		before     string // if not validateBefore
+		errPattern string // "" means no error expected
+	}{
+		{
+			name: "image changed",
+			old:  "helloworld:master-a000001",
+			new:  "helloworld:master-a000002",
+		},
+		{
+			name: "annotation added",
+			old:  `    flux.weave.works/automated: "true"`,
+			new:  `    flux.weave.works/automated: "true"` + "\n" + `    flux.weave.works/locked: "true"`,
+		},
+		{
+			name:       "YAML broken",
+			old:        "  name: helloworld\n  annotations:",
+			new:        "  name: helloworld\n annotations:",
+			errPattern: "does not parse",
+		},
+		{
+			name:       "resource renamed",
+			old:        "  name: helloworld\n  annotations:",
+			new:        "  name: goodbyeworld\n  annotations:",
+			errPattern: "defines Deployment default/goodbyeworld in place of Deployment default/helloworld",
+		},
+		{
+			name:       "resource dropped",
+			old:        "kind: Service",
+			new:        "kind: Deployment",
+			errPattern: "no longer defines Service default/helloworld",
+		},
+		{
+			name:       "annotation not a string",
+			old:        `automated: "true"`,
+			new:        `automated: true`,
+			errPattern: "value of metadata.annotations.flux.weave.works/automated is not a string",
+		},
+		{
+			name:       "image gone",
+			old:        "        image: quay.io/weaveworks/sidecar:master-a000001\n",
+			new:        "",
+			errPattern: `container "sidecar" has no image`,
+		},
+		{
+			name:       "image mangled",
+			old:        "helloworld:master-a000001",
+			new:        "helloworld:master:a000002",
+			errPattern: `container "greeter" has a bad image`,
+		},
+		{
+			name:       "container names clash",
+			old:        "name: sidecar",
+			new:        "name: greeter",
+			errPattern: `more than one container is named "greeter"`,
+		},
+		{
+			name:   "problem already there",
+			before: strings.Replace(validateBefore, "name: sidecar", "name: greeter", 1),
+			old:    "helloworld:master-a000001",
+			new:    "helloworld:master-a000002",
+		},
+	} {
+		before := c.before
+		if before == "" {
+			before = validateBefore
+		}
+		after := strings.Replace(before, c.old, c.new, 1)
+		if after == before {
+			t.Fatalf("%s: test case changes nothing", c.name)
+		}
+		err := (&Manifests{}).ValidateUpdate([]byte(before), []byte(after))
+		switch {
+		case c.errPattern == "" && err != nil:
+			t.Errorf("%s: expected no error, got %v", c.name, err)
+		case c.errPattern != "" && err == nil:
+			t.Errorf("%s: expected error containing %q, got none", c.name, c.errPattern)
+		case c.errPattern != "" && !strings.Contains(err.Error(), c.errPattern):
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.errPattern, err)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package yamledit
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Document is a YAML document that can be edited in place. Paths
+// into the document are given as a list of mapping keys, and indices
+// (in decimal) into sequences; e.g., `"spec", "containers", "0",
+// "image"`.
+type Document struct {
+	lines []string
+	// The document proper ends before this line; anything after it,
+	// like another document in the same stream, is left as it is
+	limit int
+	// How far nested mappings are indented relative to their key
+	step int
+	root *node
+}
+
+// Parse reads a YAML document so that it can be edited.
+func Parse(def []byte) (*Document, error) {
+	d := &Document{lines: strings.Split(string(def), "\n")}
+	if err := d.parse(); err != nil {
+		return nil, errors.Wrap(err, "parsing YAML")
+	}
+	return d, nil
+}
+
+// Bytes returns the text of the document, with any edits made.
+func (d *Document) Bytes() []byte {
+	return []byte(strings.Join(d.lines, "\n"))
+}
+
+// Get returns the value of the scalar at the path given, and whether
+// there is one.
+func (d *Document) Get(path ...string) (string, bool) {
+	n, ok := d.lookup(path)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case n.kind == null:
+		return "", true
+	case n.kind == scalar && n.end == n.line:
+		value, _ := decodeScalar(d.lines[n.line][n.from:n.to])
+		return value, true
+	}
+	return "", false
+}
+
+// Set sets the value at the path given, replacing a scalar that's
+// there, or adding an entry for it along with any mappings missing
+// along the way. The value is quoted only if it would otherwise be
+// read as something other than a string, or it was quoted before
+// for no reason other than taste.
+//
+// A new entry is added at the end of its mapping, unless the keys of
+// the mapping are in order, in which case it's added in its place
+// among them.
+func (d *Document) Set(value string, path ...string) error {
+	if len(path) == 0 {
+		return errors.New("no path given")
+	}
+	before := d.lines
+	d.lines = append([]string(nil), before...)
+	if err := d.set(value, path); err != nil {
+		d.lines = before
+		return err
+	}
+	return d.reparse(before)
+}
+
+func (d *Document) set(value string, path []string) error {
+	n := d.root
+	for i, key := range path {
+		switch n.kind {
+		case mapping:
+			index := n.index(key)
+			if index < 0 {
+				d.splice(d.insertionPoint(n, key), 0, d.block(n.indent, path[i:], value)...)
+				return nil
+			}
+			e := n.entries[index]
+			if i < len(path)-1 && d.isEmpty(e.value) {
+				d.fill(e, path[i+1:], value)
+				return nil
+			}
+			n = e.value
+		case sequence:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n.items) {
+				return errors.Errorf("there is no item %q in the sequence at %s", key, pathString(path[:i]))
+			}
+			n = n.items[index]
+		default:
+			return errors.Errorf("%s is not a mapping or a sequence", pathString(path[:i]))
+		}
+	}
+
+	switch {
+	case n.kind == null:
+		s := d.lines[n.line]
+		line := strings.TrimRight(s[:n.from], " \t") + " " + formatScalar(value, "")
+		if comment := s[n.from:]; comment != "" {
+			line += " " + comment
+		}
+		d.lines[n.line] = line
+	case n.kind == scalar && n.end == n.line:
+		s := d.lines[n.line]
+		d.lines[n.line] = s[:n.from] + formatScalar(value, s[n.from:n.to]) + s[n.to:]
+	case n.kind == scalar:
+		return errors.Errorf("cannot change %s, since its value spans more than one line", pathString(path))
+	default:
+		return errors.Errorf("%s is not a scalar", pathString(path))
+	}
+	return nil
+}
+
+// Remove removes the mapping entry at the path given, along with its
+// value. It's not an error if there is no such entry.
+func (d *Document) Remove(path ...string) error {
+	if len(path) == 0 {
+		return errors.New("no path given")
+	}
+	parent, ok := d.lookup(path[:len(path)-1])
+	if !ok || parent.kind != mapping {
+		return nil
+	}
+	index := parent.index(path[len(path)-1])
+	if index < 0 {
+		return nil
+	}
+	e := parent.entries[index]
+
+	before := d.lines
+	d.lines = append([]string(nil), before...)
+	var replacement []string
+	// If the entry shares a line with the dash of a sequence item,
+	// the dash has to stay, so it goes to the next entry (or is
+	// left on its own, if there isn't one)
+	if d.onDash(e) {
+		dash := d.lines[e.line][:e.col]
+		if index+1 < len(parent.entries) {
+			next := parent.entries[index+1]
+			d.lines[next.line] = dash + d.lines[next.line][next.col:]
+		} else {
+			replacement = []string{strings.TrimRight(dash, " \t")}
+		}
+	}
+	d.splice(e.line, e.value.end-e.line+1, replacement...)
+	return d.reparse(before)
+}
+
+// Extract returns the mapping or sequence at the path given as a
+// document in its own right, without the indentation it has here; for
+// example, an item in a list of resources, as a resource. Once
+// edited, it can be put back with Replace.
+func (d *Document) Extract(path ...string) ([]byte, error) {
+	n, err := d.extractable(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, n.end-n.start+1)
+	for i := n.start; i <= n.end; i++ {
+		line := d.lines[i]
+		switch {
+		case i == n.start:
+			line = line[n.indent:]
+		case indentOf(line) >= n.indent:
+			line = line[n.indent:]
+		default:
+			// a blank line, or a comment indented less than the
+			// value
+			line = strings.TrimLeft(line, " ")
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// Replace puts the document given in place of the mapping or sequence
+// at the path given, indenting it to suit.
+func (d *Document) Replace(def []byte, path ...string) error {
+	n, err := d.extractable(path)
+	if err != nil {
+		return err
+	}
+	// The first line keeps whatever came before the value, which may
+	// be the dash of a sequence item
+	first := d.lines[n.start][:n.indent]
+	indent := strings.Repeat(" ", n.indent)
+	lines := strings.Split(strings.TrimRight(string(def), "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line != "":
+			lines[i] = indent + line
+		}
+	}
+
+	before := d.lines
+	d.lines = append([]string(nil), before...)
+	d.splice(n.start, n.end-n.start+1, lines...)
+	return d.reparse(before)
+}
+
+// extractable looks up a mapping or sequence that can be extracted,
+// i.e., one that starts on a line of its own or after a dash.
+func (d *Document) extractable(path []string) (*node, error) {
+	n, ok := d.lookup(path)
+	if !ok {
+		return nil, errors.Errorf("there is nothing at %s", pathString(path))
+	}
+	if n.kind != mapping && n.kind != sequence {
+		return nil, errors.Errorf("%s is not a mapping or a sequence", pathString(path))
+	}
+	if prefix := d.lines[n.start][:n.indent]; strings.Trim(prefix, " -") != "" {
+		return nil, errors.Errorf("%s does not start on a line of its own", pathString(path))
+	}
+	return n, nil
+}
+
+func (d *Document) lookup(path []string) (*node, bool) {
+	n := d.root
+	for _, key := range path {
+		switch n.kind {
+		case mapping:
+			index := n.index(key)
+			if index < 0 {
+				return nil, false
+			}
+			n = n.entries[index].value
+		case sequence:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n.items) {
+				return nil, false
+			}
+			n = n.items[index]
+		default:
+			return nil, false
+		}
+	}
+	return n, true
+}
+
+// reparse brings the nodes up to date after an edit, putting the
+// lines back as they were if the edit has spoiled the document.
+func (d *Document) reparse(before []string) error {
+	if err := d.parse(); err != nil {
+		d.lines = before
+		d.parse()
+		return errors.Wrap(err, "edit would result in invalid YAML")
+	}
+	return nil
+}
+
+func (d *Document) splice(at, remove int, insert ...string) {
+	lines := append([]string(nil), d.lines[:at]...)
+	lines = append(lines, insert...)
+	d.lines = append(lines, d.lines[at+remove:]...)
+}
+
+// insertionPoint returns the line at which a new entry for the key
+// given should go in the mapping.
+func (d *Document) insertionPoint(m *node, key string) int {
+	keys := make([]string, len(m.entries))
+	for i, e := range m.entries {
+		keys[i] = e.key
+	}
+	k := len(keys)
+	if sort.StringsAreSorted(keys) {
+		k = sort.SearchStrings(keys, key)
+	}
+	// An entry on the same line as a sequence dash has to stay first
+	if k == 0 && d.onDash(m.entries[0]) {
+		k = 1
+	}
+	if k == len(keys) {
+		return m.end + 1
+	}
+	// Comments just above an entry are taken to be about it, so the
+	// new entry goes above them
+	at := m.entries[k].line
+	for at > 0 {
+		above := d.lines[at-1]
+		if indentOf(above) != m.indent || !strings.HasPrefix(above[m.indent:], "#") {
+			break
+		}
+		at--
+	}
+	return at
+}
+
+// block returns the lines for a new entry at the indentation given,
+// nesting a mapping for each key in the path but the last.
+func (d *Document) block(indent int, path []string, value string) []string {
+	var lines []string
+	for i, key := range path {
+		line := strings.Repeat(" ", indent+i*d.step) + formatKey(key) + ":"
+		if i == len(path)-1 {
+			line += " " + formatScalar(value, "")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// isEmpty says whether the value given can be replaced with a
+// mapping without losing anything.
+func (d *Document) isEmpty(n *node) bool {
+	if n.kind == null {
+		return true
+	}
+	return n.kind == scalar && n.end == n.line && d.lines[n.line][n.from:n.to] == "{}"
+}
+
+// fill replaces the empty value of an entry with a mapping, nesting
+// as needed for the path given.
+func (d *Document) fill(e *entry, path []string, value string) {
+	n := e.value
+	s := d.lines[n.line]
+	line := strings.TrimRight(s[:n.from], " \t")
+	if comment := strings.TrimLeft(s[n.to:], " \t"); comment != "" {
+		line += " " + comment
+	}
+	d.lines[n.line] = line
+	d.splice(n.line+1, 0, d.block(e.col+d.step, path, value)...)
+}
+
+func (d *Document) onDash(e *entry) bool {
+	return indentOf(d.lines[e.line]) != e.col
+}
+
+func pathString(path []string) string {
+	if len(path) == 0 {
+		return "the top level"
+	}
+	return strings.Join(path, ".")
+}
+
+// decodeScalar returns the value of a scalar as it appears in the
+// text, and the quote character used, if any.
+func decodeScalar(raw string) (string, byte) {
+	if len(raw) >= 2 && raw[0] == raw[len(raw)-1] {
+		switch raw[0] {
+		case '"':
+			if value, err := strconv.Unquote(raw); err == nil {
+				return value, '"'
+			}
+			return raw[1 : len(raw)-1], '"'
+		case '\'':
+			return strings.Replace(raw[1:len(raw)-1], "''", "'", -1), '\''
+		}
+	}
+	return raw, 0
+}
+
+// formatScalar returns the text for a value going in place of the
+// text given (which may be empty, for a new value).
+func formatScalar(value, old string) string {
+	oldValue, quote := decodeScalar(old)
+	switch {
+	case quote != 0 && !needsQuotes(oldValue):
+		// The old value was quoted as a matter of style, so keep
+		// to that
+		return quoteScalar(value, quote)
+	case needsQuotes(value):
+		if quote == 0 {
+			quote = '"'
+		}
+		return quoteScalar(value, quote)
+	}
+	return value
+}
+
+func formatKey(key string) string {
+	if needsQuotes(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func quoteScalar(value string, quote byte) string {
+	if quote == '\'' && isPrintable(value) {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return strconv.Quote(value)
+}
+
+var nonStrings = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true,
+	"on": true, "off": true, "y": true, "n": true,
+	"null": true, "~": true,
+	".inf": true, "-.inf": true, "+.inf": true, ".nan": true,
+}
+
+// needsQuotes says whether a value would be read as something other
+// than the same string, if it were not quoted.
+func needsQuotes(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || !isPrintable(s) {
+		return true
+	}
+	if nonStrings[strings.ToLower(s)] || looksLikeNumber(s) || looksLikeTimestamp.MatchString(s) {
+		return true
+	}
+	if strings.ContainsAny(s[:1], ",[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if (s[0] == '-' || s[0] == '?' || s[0] == ':') && isSeparator(s, 1) {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":")
+}
+
+// looksLikeNumber says whether a value would be read as an integer
+// or a float, going by the rules used by the YAML decoder.
+func looksLikeNumber(s string) bool {
+	plain := strings.Replace(s, "_", "", -1)
+	if _, err := strconv.ParseInt(plain, 0, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseUint(plain, 0, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseFloat(plain, 64); err == nil {
+		return true
+	}
+	return false
+}
+
+// Some decoders read these as times rather than strings
+var looksLikeTimestamp = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ]|$)`)
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,396 @@
+package yamledit
+
+import (
+	"testing"
+)
+
+const deployment = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`
+
+func TestSet(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		path  []string
+		value string
+		out   string
+	}{
+		{
+			name:  "replace quoted scalar, keeping the quotes",
+			path:  []string{"spec", "template", "spec", "containers", "0", "image"},
+			value: "quay.io/weaveworks/helloworld:master-a000002",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000002'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name:  "replace scalar on a dash line, keeping the comment",
+			path:  []string{"spec", "template", "spec", "containers", "1", "image"},
+			value: "quay.io/weaveworks/sidecar:2",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:2 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name:  "quotes are dropped when no longer needed",
+			path:  []string{"spec", "template", "metadata", "labels", "version"},
+			value: "master-a000002",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: master-a000002
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name:  "add to empty flow mapping, with unusual indentation",
+			path:  []string{"metadata", "labels", "name"},
+			value: "helloworld",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels:
+      name: helloworld
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name:  "add nested mapping in order, quoting the value",
+			path:  []string{"metadata", "annotations", "flux.weave.works/automated"},
+			value: "true",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+   annotations:
+      flux.weave.works/automated: "true"
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name:  "add to a sequence item, keeping the dash line first",
+			path:  []string{"spec", "template", "spec", "containers", "1", "imagePullPolicy"},
+			value: "IfNotPresent",
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          imagePullPolicy: IfNotPresent
+          name: sidecar
+`,
+		},
+	} {
+		doc, err := Parse([]byte(deployment))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Set(c.value, c.path...); err != nil {
+			t.Errorf("[%s] %v", c.name, err)
+			continue
+		}
+		if got := string(doc.Bytes()); got != c.out {
+			t.Errorf("[%s] Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", c.name, c.out, got)
+		}
+		if got, ok := doc.Get(c.path...); !ok || got != c.value {
+			t.Errorf("[%s] expected to get back %q, got %q", c.name, c.value, got)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		path []string
+		out  string
+	}{
+		{
+			name: "remove entry with nested value",
+			path: []string{"spec", "template", "metadata"},
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - image: quay.io/weaveworks/sidecar:1 # sidecar
+          name: sidecar
+`,
+		},
+		{
+			name: "remove entry on a dash line",
+			path: []string{"spec", "template", "spec", "containers", "1", "image"},
+			out: `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata: # the metadata
+   name: helloworld   # three spaces, and a comment
+   labels: {}
+spec:
+  template:
+    metadata:
+      labels:
+        version: "1.0"
+    spec:
+      containers:
+        - name: helloworld
+          image: 'quay.io/weaveworks/helloworld:master-a000001'
+          args:
+          - -msg=Ahoy
+        - name: sidecar
+`,
+		},
+		{
+			name: "remove entry that isn't there",
+			path: []string{"metadata", "annotations"},
+			out:  deployment,
+		},
+	} {
+		doc, err := Parse([]byte(deployment))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.Remove(c.path...); err != nil {
+			t.Errorf("[%s] %v", c.name, err)
+			continue
+		}
+		if got := string(doc.Bytes()); got != c.out {
+			t.Errorf("[%s] Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", c.name, c.out, got)
+		}
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	doc, err := Parse([]byte(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range [][]string{
+		{"spec", "template", "spec", "containers", "2", "image"},
+		{"spec", "template", "spec", "containers", "0", "args"},
+		{"kind", "name"},
+	} {
+		if err := doc.Set("x", path...); err == nil {
+			t.Errorf("expected error setting %v", path)
+		}
+	}
+	if string(doc.Bytes()) != deployment {
+		t.Error("expected document to be unchanged after failed edits")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, def := range []string{
+		"a:\n  b: c\n   d: e\n",
+		"a:\n\tb: c\n",
+		"a: b\n  c: d\n",
+	} {
+		if _, err := Parse([]byte(def)); err == nil {
+			t.Errorf("expected error parsing %q", def)
+		}
+	}
+}
+
+func TestNeedsQuotes(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"master-a000001":       false,
+		"1.10-alpine":          false,
+		"nginx:1.10":           false,
+		"--flag":               false,
+		"1234567":              true,
+		"1.10":                 true,
+		"0x1F":                 true,
+		"1e3":                  true,
+		"true":                 true,
+		"No":                   true,
+		"":                     true,
+		"- item":               true,
+		"key: value":           true,
+		"value # text":         true,
+		"*alias":               true,
+		"2017-06-01T12:00:00Z": true,
+	} {
+		if got := needsQuotes(s); got != expected {
+			t.Errorf("needsQuotes(%q): expected %v, got %v", s, expected, got)
+		}
+	}
+}
+
+func TestExtractReplace(t *testing.T) {
+	const list = `apiVersion: v1
+kind: List
+items:
+  - kind: Service
+    metadata:
+      name: helloworld
+  # the deployment
+  - kind: Deployment
+    metadata:
+      name: helloworld
+
+      labels: {}
+`
+	doc, err := Parse([]byte(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := doc.Extract("items", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `kind: Deployment
+metadata:
+  name: helloworld
+
+  labels: {}`
+	if string(item) != expected {
+		t.Fatalf("Did not get expected item:\n\n%s\n\nInstead got:\n\n%s", expected, item)
+	}
+
+	itemDoc, err := Parse(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := itemDoc.Set("true", "metadata", "annotations", "flux.weave.works/automated"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Replace(itemDoc.Bytes(), "items", "1"); err != nil {
+		t.Fatal(err)
+	}
+	out := `apiVersion: v1
+kind: List
+items:
+  - kind: Service
+    metadata:
+      name: helloworld
+  # the deployment
+  - kind: Deployment
+    metadata:
+      name: helloworld
+
+      labels: {}
+      annotations:
+        flux.weave.works/automated: "true"
+`
+	if got := string(doc.Bytes()); got != out {
+		t.Errorf("Did not get expected result:\n\n%s\n\nInstead got:\n\n%s", out, got)
+	}
+
+	if _, err := doc.Extract("items", "0", "kind"); err == nil {
+		t.Error("expected an error extracting a scalar")
+	}
+}
@@ -0,0 +1,397 @@
+// Package yamledit makes changes to YAML documents without disturbing
+// the rest of the text. Decoding a document and encoding it again
+// loses its comments, the order of its keys, and how its values are
+// quoted; here instead, the document is parsed into nodes that
+// remember where they are in the text, and an edit changes only the
+// lines it has to.
+//
+// Only the block style of YAML found in Kubernetes manifests is
+// understood: mappings and sequences, indented however much, with
+// scalars and flow collections given inline. Anchors, tags and
+// complex keys are not supported.
+package yamledit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type kind int
+
+const (
+	// an absent value, as in `key:` with nothing after it
+	null kind = iota
+	scalar
+	mapping
+	sequence
+)
+
+// node is a value in the document, along with where it is in the
+// text.
+type node struct {
+	kind kind
+	// The first line of the node, and the last line of it that has
+	// any content (i.e., is not blank or a comment)
+	start, end int
+	// The column of the keys of a mapping, or of the dashes of a
+	// sequence
+	indent  int
+	entries []*entry
+	items   []*node
+	// Where the text of a scalar is, or where a null value would go,
+	// on the line it starts on
+	line, from, to int
+}
+
+type entry struct {
+	key   string
+	line  int
+	col   int
+	value *node
+}
+
+func (n *node) index(key string) int {
+	for i, e := range n.entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parse reads the lines of the document into nodes. Only the first
+// document is considered, and anything after it is left alone.
+func (d *Document) parse() error {
+	d.root, d.step = nil, 0
+	d.limit = len(d.lines)
+	start := -1
+	for i, line := range d.lines {
+		if isMarker(line) {
+			if start >= 0 {
+				d.limit = i
+				break
+			}
+			continue
+		}
+		if !d.isContent(i) {
+			continue
+		}
+		if strings.IndexByte(line[:indentOf(line)+1], '\t') >= 0 {
+			return d.errorf(i, "tabs cannot be used for indentation")
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start < 0 {
+		d.root = &node{kind: null}
+		return nil
+	}
+
+	root, err := d.parseNode(start, indentOf(d.lines[start]), -1)
+	if err != nil {
+		return err
+	}
+	if next := d.nextContent(root.end + 1); next < d.limit {
+		return d.errorf(next, "unexpected indentation")
+	}
+	d.root = root
+	if d.step == 0 {
+		d.step = 2
+	}
+	return nil
+}
+
+// parseNode parses the value starting at the line and column given;
+// owner is the column of the key or dash it belongs to.
+func (d *Document) parseNode(line, col, owner int) (*node, error) {
+	text := d.lines[line][col:]
+	if isDash(text) {
+		return d.parseSequence(line, col)
+	}
+	if _, _, ok := splitKey(text); ok {
+		return d.parseMapping(line, col)
+	}
+	return d.parseScalar(line, col, owner)
+}
+
+func (d *Document) parseMapping(line, col int) (*node, error) {
+	n := &node{kind: mapping, indent: col, start: line}
+	for {
+		key, off, ok := splitKey(d.lines[line][col:])
+		if !ok {
+			return nil, d.errorf(line, "expected a key")
+		}
+		value, err := d.parseValue(line, col+off, col, false)
+		if err != nil {
+			return nil, err
+		}
+		// Remember how nested mappings are indented, so that new
+		// ones can be indented the same way
+		if d.step == 0 && value.kind == mapping && value.start > line {
+			d.step = value.indent - col
+		}
+		n.entries = append(n.entries, &entry{key: key, line: line, col: col, value: value})
+		n.end = value.end
+
+		next := d.nextContent(n.end + 1)
+		if next == d.limit {
+			break
+		}
+		ind := indentOf(d.lines[next])
+		if ind < col || ind == col && isDash(d.lines[next][ind:]) {
+			break
+		}
+		if ind > col {
+			return nil, d.errorf(next, "unexpected indentation")
+		}
+		line = next
+	}
+	return n, nil
+}
+
+func (d *Document) parseSequence(line, col int) (*node, error) {
+	n := &node{kind: sequence, indent: col, start: line}
+	for {
+		item, err := d.parseValue(line, col+1, col, true)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+		n.end = item.end
+
+		next := d.nextContent(n.end + 1)
+		if next == d.limit {
+			break
+		}
+		ind := indentOf(d.lines[next])
+		if ind < col || ind == col && !isDash(d.lines[next][ind:]) {
+			break
+		}
+		if ind > col {
+			return nil, d.errorf(next, "unexpected indentation")
+		}
+		line = next
+	}
+	return n, nil
+}
+
+// parseValue parses the value following a key or a dash, which
+// starts at the column given, or on the lines after.
+func (d *Document) parseValue(line, from, owner int, inSequence bool) (*node, error) {
+	s := d.lines[line]
+	v := from
+	for v < len(s) && (s[v] == ' ' || s[v] == '\t') {
+		v++
+	}
+	if v < len(s) && s[v] != '#' {
+		text := s[v:]
+		// A sequence item can start a mapping or another sequence
+		// on the same line as its dash
+		if inSequence {
+			if isDash(text) {
+				return d.parseSequence(line, v)
+			}
+			if _, _, ok := splitKey(text); ok {
+				return d.parseMapping(line, v)
+			}
+		}
+		return d.parseScalar(line, v, owner)
+	}
+
+	// Nothing inline, so the value is whatever is indented below, if
+	// anything. A sequence may have the same indentation as the key
+	// it belongs to.
+	if next := d.nextContent(line + 1); next < d.limit {
+		ind := indentOf(d.lines[next])
+		if ind > owner {
+			return d.parseNode(next, ind, owner)
+		}
+		if ind == owner && !inSequence && isDash(d.lines[next][ind:]) {
+			return d.parseSequence(next, ind)
+		}
+	}
+	return &node{kind: null, start: line, end: line, line: line, from: v, to: v}, nil
+}
+
+// parseScalar parses a scalar, which may carry on over lines
+// indented further than its owner.
+func (d *Document) parseScalar(line, v, owner int) (*node, error) {
+	s := d.lines[line]
+	plain := !strings.ContainsAny(s[v:v+1], "\"'{[|>")
+	n := &node{
+		kind:  scalar,
+		start: line,
+		end:   line,
+		line:  line,
+		from:  v,
+		to:    scalarEnd(s, v),
+	}
+	for i := line + 1; i < d.limit; i++ {
+		if !d.isContent(i) {
+			continue
+		}
+		ind := indentOf(d.lines[i])
+		if ind <= owner {
+			break
+		}
+		// A key here is much more likely to be misindented than
+		// meant as part of the value
+		if _, _, ok := splitKey(d.lines[i][ind:]); plain && ok {
+			return nil, d.errorf(i, "unexpected indentation")
+		}
+		n.end = i
+	}
+	return n, nil
+}
+
+func (d *Document) isContent(i int) bool {
+	t := strings.TrimLeft(d.lines[i], " \t")
+	return t != "" && t[0] != '#'
+}
+
+// nextContent returns the first line from i on with any content, or
+// the limit of the document if there is none.
+func (d *Document) nextContent(i int) int {
+	for ; i < d.limit; i++ {
+		if d.isContent(i) {
+			return i
+		}
+	}
+	return d.limit
+}
+
+func (d *Document) errorf(line int, format string, args ...interface{}) error {
+	return errors.Errorf("line %d: %s", line+1, fmt.Sprintf(format, args...))
+}
+
+func indentOf(line string) int {
+	i := 0
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+func isMarker(line string) bool {
+	for _, m := range []string{"---", "..."} {
+		if strings.HasPrefix(line, m) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+func isDash(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// splitKey looks for a mapping key at the start of the text given,
+// returning the key and the offset just past its colon.
+func splitKey(s string) (string, int, bool) {
+	if s == "" {
+		return "", 0, false
+	}
+	switch s[0] {
+	case '"', '\'':
+		end := closingQuote(s)
+		if end < 0 {
+			return "", 0, false
+		}
+		i := end + 1
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) && s[i] == ':' && isSeparator(s, i+1) {
+			key, _ := decodeScalar(s[:end+1])
+			return key, i + 1, true
+		}
+		return "", 0, false
+	case '[', '{', '&', '*', '!', '|', '>', '?', '%', '@', '`', '#':
+		return "", 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t'):
+			return "", 0, false
+		case s[i] == ':' && isSeparator(s, i+1):
+			return strings.TrimRight(s[:i], " \t"), i + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+func isSeparator(s string, i int) bool {
+	return i == len(s) || s[i] == ' ' || s[i] == '\t'
+}
+
+// scalarEnd returns the column just past the scalar starting at v,
+// leaving out any comment.
+func scalarEnd(s string, v int) int {
+	switch s[v] {
+	case '"', '\'':
+		if end := closingQuote(s[v:]); end >= 0 {
+			return v + end + 1
+		}
+		return len(s)
+	case '{', '[':
+		if end := closingBracket(s[v:]); end >= 0 {
+			return v + end + 1
+		}
+		return len(s)
+	}
+	end := len(s)
+	for i := v + 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			end = i
+			break
+		}
+	}
+	return v + len(strings.TrimRight(s[v:end], " \t"))
+}
+
+// closingQuote returns the index of the quote closing the string at
+// the start of s, or -1 if it's not closed on this line.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q:
+			if q == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// closingBracket returns the index of the bracket closing the flow
+// collection at the start of s, or -1 if it's not closed on this
+// line.
+func closingBracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := closingQuote(s[i:])
+			if end < 0 {
+				return -1
+			}
+			i += end
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
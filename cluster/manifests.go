@@ -16,24 +16,86 @@ type Manifests interface {
 	// Given a directory with manifest files, find which files define
 	// which services.
 	FindDefinedServices(path string) (map[flux.ServiceID][]string, error)
+	// Given a directory with manifest files, report the services
+	// defined there along with the workloads each selects.
+	ServiceTopology(path string) ([]flux.ServiceTopology, error)
 	// Update the definitions in a manifests bytes according to the
 	// spec given.
 	UpdateDefinition(def []byte, container string, newImageID flux.ImageID) ([]byte, error)
+	// CheckPullPolicy looks at whether the container's image pull
+	// policy suits the image, and depending on the pull-policy
+	// policy, fixes it or describes the problem.
+	CheckPullPolicy(def []byte, container string, image flux.ImageID) ([]byte, string, error)
 	// Load all the resource manifests under the path given
 	LoadManifests(paths ...string) (map[string]resource.Resource, error)
 	// Parse the manifests given in an exported blob
 	ParseManifests([]byte) (map[string]resource.Resource, error)
 	// UpdatePolicies modifies a manifest to apply the policy update specified
 	UpdatePolicies([]byte, policy.Update) ([]byte, error)
+	// ValidateUpdate checks that a manifest, as changed by one of the
+	// above, is still valid and defines the same things as before,
+	// so that a broken manifest is never committed.
+	ValidateUpdate(before, after []byte) error
+	// PoliciesIn gives the policies set in a manifest (or for
+	// PolicyManifests, a policy file), as written there; i.e.,
+	// without any defaults for the namespace.
+	PoliciesIn(def []byte) (policy.Set, error)
+	// SwitchSelector changes the definition of a service (given in
+	// serviceDef) so that it selects the pods of the workload
+	// defined in workloadDef, rather than whatever it selected
+	// before; this is how a blue/green pair is switched over.
+	SwitchSelector(serviceDef, workloadDef []byte) ([]byte, error)
 	// ServicesWithPolicy finds the services which have a particular policy set on them.
 	ServicesWithPolicy(path string, p policy.Policy) (policy.ServiceMap, error)
+	// WithConfigChecksum gives the definition of the resource with a
+	// checksum of the config it refers to (among the resources given)
+	// added, so that applying it after the config changes replaces
+	// its pods; and the checksum, or "" if it refers to no config,
+	// in which case the definition is as it was.
+	WithConfigChecksum(res resource.Resource, all map[string]resource.Resource) ([]byte, string, error)
+	// AppliedConfigChecksum gives the checksum that was added by
+	// WithConfigChecksum to a resource, e.g., as exported from the
+	// cluster, or "" if there isn't one.
+	AppliedConfigChecksum(res resource.Resource) string
+	// MarkApplied gives the definition with a mark saying flux
+	// applied it, so that it can be told apart, in the cluster, from
+	// what was created some other way.
+	MarkApplied(def []byte) ([]byte, error)
+	// IsMarkedApplied says whether the resource (e.g., as exported
+	// from the cluster) has the mark added by MarkApplied. Only
+	// resources with the mark are ever pruned.
+	IsMarkedApplied(res resource.Resource) bool
+}
+
+// PolicyManifests is implemented by Manifests that keep the policies
+// for a service somewhere other than in its definition; e.g., in the
+// metadata of a Helm chart, rather than in its values.
+type PolicyManifests interface {
+	// Given a directory with manifest files, find which files hold
+	// the policies for which services.
+	FindPolicyFiles(path string) (map[flux.ServiceID][]string, error)
 }
 
 // UpdateManifest looks for the manifest for a given service, reads
 // its contents, applies f(contents), and writes the results back to
 // the file.
 func UpdateManifest(m Manifests, root string, serviceID string, f func(manifest []byte) ([]byte, error)) error {
-	services, err := m.FindDefinedServices(root)
+	return updateFile(m.FindDefinedServices, root, serviceID, f)
+}
+
+// UpdatePolicyManifest is like UpdateManifest, but looks for the file
+// holding the service's policies, which is its manifest unless the
+// Manifests say otherwise.
+func UpdatePolicyManifest(m Manifests, root string, serviceID string, f func(manifest []byte) ([]byte, error)) error {
+	find := m.FindDefinedServices
+	if pm, ok := m.(PolicyManifests); ok {
+		find = pm.FindPolicyFiles
+	}
+	return updateFile(find, root, serviceID, f)
+}
+
+func updateFile(find func(string) (map[flux.ServiceID][]string, error), root string, serviceID string, f func(manifest []byte) ([]byte, error)) error {
+	services, err := find(root)
 	if err != nil {
 		return err
 	}
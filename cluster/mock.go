@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"io"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
@@ -9,19 +11,28 @@ import (
 
 // Doubles as a cluster.Cluster and cluster.Manifests implementation
 type Mock struct {
-	AllServicesFunc         func(maybeNamespace string) ([]Service, error)
-	SomeServicesFunc        func([]flux.ServiceID) ([]Service, error)
-	PingFunc                func() error
-	ExportFunc              func() ([]byte, error)
-	SyncFunc                func(SyncDef) error
-	PublicSSHKeyFunc        func(regenerate bool) (ssh.PublicKey, error)
-	FindDefinedServicesFunc func(path string) (map[flux.ServiceID][]string, error)
-	UpdateDefinitionFunc    func(def []byte, container string, newImageID flux.ImageID) ([]byte, error)
-	LoadManifestsFunc       func(paths ...string) (map[string]resource.Resource, error)
-	ParseManifestsFunc      func([]byte) (map[string]resource.Resource, error)
-	UpdateManifestFunc      func(path, resourceID string, f func(def []byte) ([]byte, error)) error
-	UpdatePoliciesFunc      func([]byte, policy.Update) ([]byte, error)
-	ServicesWithPolicyFunc  func(path string, p policy.Policy) (policy.ServiceMap, error)
+	AllServicesFunc           func(maybeNamespace string) ([]Service, error)
+	SomeServicesFunc          func([]flux.ServiceID) ([]Service, error)
+	PingFunc                  func() error
+	ExportFunc                func() ([]byte, error)
+	SyncFunc                  func(SyncDef) error
+	PublicSSHKeyFunc          func(regenerate bool) (ssh.PublicKey, error)
+	FindDefinedServicesFunc   func(path string) (map[flux.ServiceID][]string, error)
+	ServiceTopologyFunc       func(path string) ([]flux.ServiceTopology, error)
+	UpdateDefinitionFunc      func(def []byte, container string, newImageID flux.ImageID) ([]byte, error)
+	CheckPullPolicyFunc       func(def []byte, container string, image flux.ImageID) ([]byte, string, error)
+	LoadManifestsFunc         func(paths ...string) (map[string]resource.Resource, error)
+	ParseManifestsFunc        func([]byte) (map[string]resource.Resource, error)
+	UpdateManifestFunc        func(path, resourceID string, f func(def []byte) ([]byte, error)) error
+	UpdatePoliciesFunc        func([]byte, policy.Update) ([]byte, error)
+	PoliciesInFunc            func(def []byte) (policy.Set, error)
+	ValidateUpdateFunc        func(before, after []byte) error
+	SwitchSelectorFunc        func(serviceDef, workloadDef []byte) ([]byte, error)
+	ServicesWithPolicyFunc    func(path string, p policy.Policy) (policy.ServiceMap, error)
+	WithConfigChecksumFunc    func(res resource.Resource, all map[string]resource.Resource) ([]byte, string, error)
+	AppliedConfigChecksumFunc func(res resource.Resource) string
+	MarkAppliedFunc           func(def []byte) ([]byte, error)
+	IsMarkedAppliedFunc       func(res resource.Resource) bool
 }
 
 func (m *Mock) AllServices(maybeNamespace string) ([]Service, error) {
@@ -40,6 +51,15 @@ func (m *Mock) Export() ([]byte, error) {
 	return m.ExportFunc()
 }
 
+func (m *Mock) ExportTo(w io.Writer) error {
+	config, err := m.ExportFunc()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(config)
+	return err
+}
+
 func (m *Mock) Sync(c SyncDef) error {
 	return m.SyncFunc(c)
 }
@@ -52,10 +72,18 @@ func (m *Mock) FindDefinedServices(path string) (map[flux.ServiceID][]string, er
 	return m.FindDefinedServicesFunc(path)
 }
 
+func (m *Mock) ServiceTopology(path string) ([]flux.ServiceTopology, error) {
+	return m.ServiceTopologyFunc(path)
+}
+
 func (m *Mock) UpdateDefinition(def []byte, container string, newImageID flux.ImageID) ([]byte, error) {
 	return m.UpdateDefinitionFunc(def, container, newImageID)
 }
 
+func (m *Mock) CheckPullPolicy(def []byte, container string, image flux.ImageID) ([]byte, string, error) {
+	return m.CheckPullPolicyFunc(def, container, image)
+}
+
 func (m *Mock) LoadManifests(paths ...string) (map[string]resource.Resource, error) {
 	return m.LoadManifestsFunc(paths...)
 }
@@ -72,6 +100,34 @@ func (m *Mock) UpdatePolicies(def []byte, p policy.Update) ([]byte, error) {
 	return m.UpdatePoliciesFunc(def, p)
 }
 
+func (m *Mock) PoliciesIn(def []byte) (policy.Set, error) {
+	return m.PoliciesInFunc(def)
+}
+
+func (m *Mock) ValidateUpdate(before, after []byte) error {
+	return m.ValidateUpdateFunc(before, after)
+}
+
+func (m *Mock) SwitchSelector(serviceDef, workloadDef []byte) ([]byte, error) {
+	return m.SwitchSelectorFunc(serviceDef, workloadDef)
+}
+
 func (m *Mock) ServicesWithPolicy(path string, p policy.Policy) (policy.ServiceMap, error) {
 	return m.ServicesWithPolicyFunc(path, p)
 }
+
+func (m *Mock) WithConfigChecksum(res resource.Resource, all map[string]resource.Resource) ([]byte, string, error) {
+	return m.WithConfigChecksumFunc(res, all)
+}
+
+func (m *Mock) AppliedConfigChecksum(res resource.Resource) string {
+	return m.AppliedConfigChecksumFunc(res)
+}
+
+func (m *Mock) MarkApplied(def []byte) ([]byte, error) {
+	return m.MarkAppliedFunc(def)
+}
+
+func (m *Mock) IsMarkedApplied(res resource.Resource) bool {
+	return m.IsMarkedAppliedFunc(res)
+}
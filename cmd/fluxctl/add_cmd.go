@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/update"
+)
+
+type addOpts struct {
+	*rootOpts
+	file string
+	outputOpts
+	cause update.Cause
+}
+
+func newAdd(parent *rootOpts) *addOpts {
+	return &addOpts{rootOpts: parent}
+}
+
+func (opts *addOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add --file=helloworld.yaml",
+		Short: "Add the manifests for new resources to the git repo, where the repo layout says they go.",
+		Example: makeExample(
+			"fluxctl add --file=helloworld.yaml",
+			"kubectl create deployment helloworld --image=quay.io/weaveworks/helloworld:v1 --dry-run -o yaml | fluxctl add -f -",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", "file containing the manifests to add; '-' means read from stdin")
+	return cmd
+}
+
+func (opts *addOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.file == "" {
+		return newUsageError("-f, --file is required")
+	}
+
+	var def []byte
+	var err error
+	if opts.file == "-" {
+		def, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		def, err = ioutil.ReadFile(opts.file)
+	}
+	if err != nil {
+		return err
+	}
+
+	jobID, err := opts.API.AddManifests(ctx, noInstanceID, update.AddSpec{Definition: string(def)}, opts.cause)
+	if err != nil {
+		return err
+	}
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+}
@@ -24,3 +24,7 @@ func AddCauseFlags(cmd *cobra.Command, opts *update.Cause) {
 	cmd.Flags().StringVarP(&opts.Message, "message", "m", "", "attach a message to the update")
 	cmd.Flags().StringVar(&opts.User, "user", username, "override the user reported as initating the update")
 }
+
+func AddDryRunFlag(cmd *cobra.Command, dryRun *bool) {
+	cmd.Flags().BoolVar(dryRun, "dry-run", false, "do not commit anything; just report back the changes that would have been made")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -10,9 +11,11 @@ import (
 
 type serviceAutomateOpts struct {
 	*serviceOpts
-	service string
+	service   string
+	container string
 	outputOpts
-	cause update.Cause
+	cause  update.Cause
+	dryRun bool
 }
 
 func newServiceAutomate(parent *serviceOpts) *serviceAutomateOpts {
@@ -25,16 +28,21 @@ func (opts *serviceAutomateOpts) Command() *cobra.Command {
 		Short: "Turn on automatic deployment for a service.",
 		Example: makeExample(
 			"fluxctl automate --service=helloworld",
+			"fluxctl automate --service=helloworld --container=sidecar",
 		),
 		RunE: opts.RunE,
 	}
 	AddOutputFlags(cmd, &opts.outputOpts)
 	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to automate")
+	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "Container to put back under automation, having been left out of it; the service must be automated too")
 	return cmd
 }
 
 func (opts *serviceAutomateOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
@@ -47,11 +55,15 @@ func (opts *serviceAutomateOpts) RunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	jobID, err := opts.API.UpdatePolicies(noInstanceID, policy.Updates{
-		serviceID: policy.Update{Add: policy.Set{policy.Automated: "true"}},
-	}, opts.cause)
+	u := policy.Update{Add: policy.Set{policy.Automated: "true"}}
+	if opts.container != "" {
+		u = policy.Update{Remove: policy.Set{policy.Policy(policy.AutomatedPrefix + opts.container): "false"}}
+	}
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: u,
+	}, opts.cause, opts.dryRun)
 	if err != nil {
 		return err
 	}
-	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
 }
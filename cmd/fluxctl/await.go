@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/weaveworks/flux/api"
@@ -17,11 +19,14 @@ var ErrTimeout = errors.New("timeout")
 
 // await polls for a job to complete, then for the resulting commit to
 // be applied
-func await(stdout, stderr io.Writer, client api.ClientService, jobID job.ID, apply, verbose bool) error {
-	metadata, err := awaitJob(client, jobID)
+func await(ctx context.Context, stdout, stderr io.Writer, client api.ClientService, jobID job.ID, apply, verbose bool) error {
+	metadata, err := awaitJob(ctx, client, jobID)
 	if err != nil && err.Error() != git.ErrNoChanges.Error() {
 		return err
 	}
+	if c := metadata.Conflict; c != nil {
+		fmt.Fprintf(stderr, "Commit conflicted with changes upstream to %s; %s\n", strings.Join(c.Files, ", "), c.Outcome())
+	}
 	if metadata.Revision != "" {
 		fmt.Fprintf(stderr, "Commit pushed: %s\n", metadata.ShortRevision())
 	}
@@ -31,7 +36,7 @@ func await(stdout, stderr io.Writer, client api.ClientService, jobID job.ID, app
 	}
 
 	if apply && metadata.Revision != "" {
-		if err := awaitSync(client, metadata.Revision); err != nil {
+		if err := awaitSync(ctx, client, metadata.Revision); err != nil {
 			return err
 		}
 
@@ -45,10 +50,10 @@ func await(stdout, stderr io.Writer, client api.ClientService, jobID job.ID, app
 }
 
 // await polls for a job to have been completed, with exponential backoff.
-func awaitJob(client api.ClientService, jobID job.ID) (history.CommitEventMetadata, error) {
+func awaitJob(ctx context.Context, client api.ClientService, jobID job.ID) (history.CommitEventMetadata, error) {
 	var result history.CommitEventMetadata
 	err := backoff(100*time.Millisecond, 2, 50, 1*time.Minute, func() (bool, error) {
-		j, err := client.JobStatus(noInstanceID, jobID)
+		j, err := client.JobStatus(ctx, noInstanceID, jobID)
 		if err != nil {
 			return false, err
 		}
@@ -69,9 +74,9 @@ func awaitJob(client api.ClientService, jobID job.ID) (history.CommitEventMetada
 }
 
 // await polls for a commit to have been applied, with exponential backoff.
-func awaitSync(client api.ClientService, revision string) error {
+func awaitSync(ctx context.Context, client api.ClientService, revision string) error {
 	return backoff(1*time.Second, 2, 10, 1*time.Minute, func() (bool, error) {
-		refs, err := client.SyncStatus(noInstanceID, revision)
+		refs, err := client.SyncStatus(ctx, noInstanceID, revision)
 		return err == nil && len(refs) == 0, err
 	})
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/service"
+)
+
+type checkOpts struct {
+	*rootOpts
+}
+
+func newCheck(parent *rootOpts) *checkOpts {
+	return &checkOpts{rootOpts: parent}
+}
+
+func (opts *checkOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "check",
+		Short:   "Check that flux is set up and working, from token to image registry.",
+		Example: makeExample("fluxctl check"),
+		RunE:    opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *checkOpts) RunE(_ *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+
+	var report service.CheckReport
+	remote, err := opts.API.Check(ctx, noInstanceID)
+	switch {
+	case err == nil:
+		// Getting any answer means the token was accepted
+		report.Run(service.CheckToken, func() (string, error) { return "", nil })
+		report.Checks = append(report.Checks, remote.Checks...)
+	case errors.Cause(err) == transport.ErrorUnauthorized:
+		report.Run(service.CheckToken, func() (string, error) { return "", err })
+		for _, name := range []string{service.CheckConnected, service.CheckVersion, service.CheckGit, service.CheckRegistry} {
+			report.Run(name, nil)
+		}
+	default:
+		return err
+	}
+
+	w := newTabwriter()
+	fmt.Fprintf(w, "CHECK\tSTATUS\tDETAIL\n")
+	for _, c := range report.Checks {
+		detail := c.Detail
+		if c.Error != "" {
+			detail = c.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.Status, detail)
+	}
+	w.Flush()
+
+	if !report.OK() {
+		return errorCheckFailed
+	}
+	return nil
+}
+
+var errorCheckFailed = &flux.BaseError{
+	Help: `One or more checks failed
+
+The first failing check above is the most likely cause of any
+problems; the checks after it depend on it, so were skipped. For help
+with setting flux up, please consult the installation instructions:
+
+    https://github.com/weaveworks/flux/blob/master/site/installing.md
+
+`,
+	Err: errors.New("one or more checks failed"),
+}
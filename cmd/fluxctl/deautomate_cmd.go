@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -10,9 +11,11 @@ import (
 
 type serviceDeautomateOpts struct {
 	*serviceOpts
-	service string
+	service   string
+	container string
 	outputOpts
-	cause update.Cause
+	cause  update.Cause
+	dryRun bool
 }
 
 func newServiceDeautomate(parent *serviceOpts) *serviceDeautomateOpts {
@@ -25,16 +28,21 @@ func (opts *serviceDeautomateOpts) Command() *cobra.Command {
 		Short: "Turn off automatic deployment for a service.",
 		Example: makeExample(
 			"fluxctl deautomate --service=helloworld",
+			"fluxctl deautomate --service=helloworld --container=sidecar",
 		),
 		RunE: opts.RunE,
 	}
 	AddOutputFlags(cmd, &opts.outputOpts)
 	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to deautomate")
+	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "Container to leave out of automation, while the rest of the service stays automated")
 	return cmd
 }
 
 func (opts *serviceDeautomateOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
@@ -47,11 +55,15 @@ func (opts *serviceDeautomateOpts) RunE(cmd *cobra.Command, args []string) error
 		return err
 	}
 
-	jobID, err := opts.API.UpdatePolicies(noInstanceID, policy.Updates{
-		serviceID: policy.Update{Remove: policy.Set{policy.Automated: "true"}},
-	}, opts.cause)
+	u := policy.Update{Remove: policy.Set{policy.Automated: "true"}}
+	if opts.container != "" {
+		u = policy.Update{Add: policy.Set{policy.Policy(policy.AutomatedPrefix + opts.container): "false"}}
+	}
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: u,
+	}, opts.cause, opts.dryRun)
 	if err != nil {
 		return err
 	}
-	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/update"
+)
+
+type evaluateImageOpts struct {
+	*rootOpts
+	image string
+	outputOpts
+}
+
+func newEvaluateImage(parent *rootOpts) *evaluateImageOpts {
+	return &evaluateImageOpts{rootOpts: parent}
+}
+
+func (opts *evaluateImageOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "evaluate-image",
+		Short: "Show which services automation would release an image to, if it were pushed.",
+		Example: makeExample(
+			"fluxctl evaluate-image --image=quay.io/weaveworks/helloworld:master-a000003",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	cmd.Flags().StringVarP(&opts.image, "image", "i", "", "image, with a tag, that might be pushed")
+	return cmd
+}
+
+func (opts *evaluateImageOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.image == "" {
+		return newUsageError("-i, --image is required")
+	}
+	image, err := flux.ParseImageID(opts.image)
+	if err != nil {
+		return err
+	}
+	if image.Tag == "" {
+		return newUsageError("please give the image with a tag, e.g., " + image.String() + ":v1")
+	}
+
+	result, err := opts.API.EvaluateImage(ctx, noInstanceID, image)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		fmt.Fprintf(cmd.OutOrStderr(), "No services use %s\n", image.Repository())
+		return nil
+	}
+	update.PrintResults(cmd.OutOrStdout(), result, opts.verbose)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -26,11 +27,13 @@ func (opts *identityOpts) Command() *cobra.Command {
 }
 
 func (opts *identityOpts) RunE(_ *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
 
-	publicSSHKey, err := opts.API.PublicSSHKey(noInstanceID, opts.regenerate)
+	publicSSHKey, err := opts.API.PublicSSHKey(ctx, noInstanceID, opts.regenerate)
 	if err != nil {
 		return err
 	}
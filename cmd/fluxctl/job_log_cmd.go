@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/job"
+)
+
+type jobLogOpts struct {
+	*rootOpts
+	id string
+}
+
+func newJobLog(parent *rootOpts) *jobLogOpts {
+	return &jobLogOpts{rootOpts: parent}
+}
+
+func (opts *jobLogOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "job-log",
+		Short:   "Show the output from running a job, e.g., a release.",
+		Example: makeExample("fluxctl job-log --id=4a6a2ee4-9bae-4f2c-8e6b-2c4e7ba2bd02"),
+		RunE:    opts.RunE,
+	}
+	cmd.Flags().StringVar(&opts.id, "id", "", "ID of the job, as given when it was started")
+	return cmd
+}
+
+func (opts *jobLogOpts) RunE(_ *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.id == "" {
+		return newUsageError("please supply the ID of a job with --id")
+	}
+
+	log, err := opts.API.JobLog(ctx, noInstanceID, job.ID(opts.id))
+	if err != nil {
+		return err
+	}
+	fmt.Print(log.Output)
+	if log.Truncated {
+		fmt.Fprintln(os.Stderr, "(output was truncated)")
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -33,6 +34,8 @@ func (opts *serviceShowOpts) Command() *cobra.Command {
 }
 
 func (opts *serviceShowOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) != 0 {
 		return errorWantedNoArgs
 	}
@@ -42,7 +45,7 @@ func (opts *serviceShowOpts) RunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	services, err := opts.API.ListImages(noInstanceID, service)
+	services, err := opts.API.ListImages(ctx, noInstanceID, service)
 	if err != nil {
 		return err
 	}
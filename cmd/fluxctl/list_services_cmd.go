@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -32,11 +34,13 @@ func (opts *serviceListOpts) Command() *cobra.Command {
 }
 
 func (opts *serviceListOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) != 0 {
 		return errorWantedNoArgs
 	}
 
-	services, err := opts.API.ListServices(noInstanceID, opts.namespace)
+	services, err := opts.API.ListServices(ctx, noInstanceID, opts.namespace)
 	if err != nil {
 		return err
 	}
@@ -44,16 +48,16 @@ func (opts *serviceListOpts) RunE(cmd *cobra.Command, args []string) error {
 	sort.Sort(serviceStatusByName(services))
 
 	w := newTabwriter()
-	fmt.Fprintf(w, "SERVICE\tCONTAINER\tIMAGE\tRELEASE\tPOLICY\n")
+	fmt.Fprintf(w, "SERVICE\tCONTAINER\tIMAGE\tRELEASE\tPOLICY\tSYNC\n")
 	for _, s := range services {
 		if len(s.Containers) > 0 {
 			c := s.Containers[0]
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, c.Name, c.Current.ID, s.Status, policies(s))
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, c.Name, c.Current.ID, s.Status, policies(s), syncError(s))
 			for _, c := range s.Containers[1:] {
-				fmt.Fprintf(w, "\t%s\t%s\t\t\n", c.Name, c.Current.ID)
+				fmt.Fprintf(w, "\t%s\t%s\t\t\t\n", c.Name, c.Current.ID)
 			}
 		} else {
-			fmt.Fprintf(w, "%s\t\t\t\t\n", s.ID)
+			fmt.Fprintf(w, "%s\t\t\t\t\t%s\n", s.ID, syncError(s))
 		}
 	}
 	w.Flush()
@@ -80,11 +84,43 @@ func policies(s flux.ServiceStatus) string {
 		ps = append(ps, string(policy.Automated))
 	}
 	if s.Locked {
-		ps = append(ps, string(policy.Locked))
+		ps = append(ps, lockDescription(s))
 	}
 	if s.Ignore {
 		ps = append(ps, string(policy.Ignore))
 	}
+	if left := s.PausedUntil.Sub(time.Now()); !s.PausedUntil.IsZero() && left > 0 {
+		ps = append(ps, fmt.Sprintf("%s (%s left)", policy.Paused, left.Truncate(time.Minute)))
+	}
 	sort.Strings(ps)
 	return strings.Join(ps, ",")
 }
+
+// lockDescription says who locked a service, and for how long, as far
+// as that's known.
+func lockDescription(s flux.ServiceStatus) string {
+	var details []string
+	if s.LockedUser != "" {
+		details = append(details, "by "+s.LockedUser)
+	}
+	if left := s.LockedUntil.Sub(time.Now()); !s.LockedUntil.IsZero() && left > 0 {
+		details = append(details, fmt.Sprintf("%s left", left.Truncate(time.Minute)))
+	}
+	if len(details) == 0 {
+		return string(policy.Locked)
+	}
+	return fmt.Sprintf("%s (%s)", policy.Locked, strings.Join(details, ", "))
+}
+
+// syncError says why the service's manifests couldn't be applied, if
+// they failed in the most recent sync.
+func syncError(s flux.ServiceStatus) string {
+	if s.SyncError == "" {
+		return ""
+	}
+	rev := s.SyncErrorRevision
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	return fmt.Sprintf("failed at %s: %s", rev, s.SyncError)
+}
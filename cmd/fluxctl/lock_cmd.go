@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -10,9 +13,12 @@ import (
 
 type serviceLockOpts struct {
 	*serviceOpts
-	service string
+	service   string
+	container string
+	duration  time.Duration
 	outputOpts
-	cause update.Cause
+	cause  update.Cause
+	dryRun bool
 }
 
 func newServiceLock(parent *serviceOpts) *serviceLockOpts {
@@ -25,16 +31,23 @@ func (opts *serviceLockOpts) Command() *cobra.Command {
 		Short: "Lock a service, so it cannot be deployed.",
 		Example: makeExample(
 			"fluxctl lock --service=helloworld",
+			"fluxctl lock --service=helloworld --message='Waiting on the schema migration' --for=24h",
+			"fluxctl lock --service=helloworld --container=sidecar",
 		),
 		RunE: opts.RunE,
 	}
 	AddOutputFlags(cmd, &opts.outputOpts)
 	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to lock")
+	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "Container to lock, leaving the rest of the service unlocked")
+	cmd.Flags().DurationVar(&opts.duration, "for", 0, "How long to lock the service for; it's unlocked by itself after this. If not given, it stays locked until unlocked")
 	return cmd
 }
 
 func (opts *serviceLockOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
@@ -42,16 +55,31 @@ func (opts *serviceLockOpts) RunE(cmd *cobra.Command, args []string) error {
 		return newUsageError("-s, --service is required")
 	}
 
+	if opts.duration < 0 {
+		return newUsageError("--for must be a positive duration, e.g., 24h")
+	}
+	if opts.container != "" && opts.duration > 0 {
+		return newUsageError("--for can only be given when locking the whole service")
+	}
+
 	serviceID, err := flux.ParseServiceID(opts.service)
 	if err != nil {
 		return err
 	}
 
-	jobID, err := opts.API.UpdatePolicies(noInstanceID, policy.Updates{
-		serviceID: policy.Update{Add: policy.Set{policy.Locked: "true"}},
-	}, opts.cause)
+	// Who locked the service, and why, are recorded from the cause
+	add := policy.Set{policy.Locked: "true"}
+	if opts.container != "" {
+		add = policy.Set{policy.Policy(policy.LockedPrefix + opts.container): "true"}
+	}
+	if opts.duration > 0 {
+		add[policy.LockedUntil] = time.Now().Add(opts.duration).UTC().Format(time.RFC3339)
+	}
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: policy.Update{Add: add},
+	}, opts.cause, opts.dryRun)
 	if err != nil {
 		return err
 	}
-	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
 }
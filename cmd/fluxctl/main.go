@@ -14,8 +14,8 @@ func run(args []string) int {
 	if cmd, err := rootCmd.ExecuteC(); err != nil {
 		err = errors.Cause(err)
 		switch err := err.(type) {
-		case *flux.BaseError:
-			cmd.Println("== Error ==\n\n" + err.Help)
+		case flux.HelpfulError:
+			cmd.Println("== Error ==\n\n" + err.Base().Help)
 		default:
 			cmd.Println("Error: " + err.Error())
 			cmd.Printf("Run '%v --help' for usage.\n", cmd.CommandPath())
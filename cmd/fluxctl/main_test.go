@@ -15,6 +15,7 @@ import (
 
 	transport "github.com/weaveworks/flux/http"
 	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
 )
 
 func mockServiceOpts(trip *genericMockRoundTripper) *serviceOpts {
@@ -110,6 +111,7 @@ func newMockService() *genericMockRoundTripper {
 	return &genericMockRoundTripper{
 		mockResponses: map[*mux.Route]interface{}{
 			transport.NewAPIRouter().Get("UpdateImages"): job.ID("here-is-a-job-id"),
+			transport.NewAPIRouter().Get("PlanRelease"):  update.Result{},
 			transport.NewAPIRouter().Get("JobStatus"): job.Status{
 				StatusString: job.StatusSucceeded,
 			},
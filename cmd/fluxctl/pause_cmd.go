@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+type servicePauseOpts struct {
+	*serviceOpts
+	service  string
+	duration time.Duration
+	outputOpts
+	cause  update.Cause
+	dryRun bool
+}
+
+func newServicePause(parent *serviceOpts) *servicePauseOpts {
+	return &servicePauseOpts{serviceOpts: parent}
+}
+
+func (opts *servicePauseOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Pause automation of a service for a while. Unlike lock, this still lets the service be released by hand.",
+		Example: makeExample(
+			"fluxctl pause --service=helloworld --for=2h",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to pause")
+	cmd.Flags().DurationVar(&opts.duration, "for", time.Hour, "How long to pause automation for; it resumes by itself after this")
+	return cmd
+}
+
+func (opts *servicePauseOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+	if opts.duration <= 0 {
+		return newUsageError("--for must be a positive duration, e.g., 2h")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(opts.duration).UTC().Format(time.RFC3339)
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: policy.Update{Add: policy.Set{policy.Paused: until}},
+	}, opts.cause, opts.dryRun)
+	if err != nil {
+		return err
+	}
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+type servicePolicyOpts struct {
+	*serviceOpts
+	service          string
+	ignoreContainers string
+	outputOpts
+	cause  update.Cause
+	dryRun bool
+}
+
+func newServicePolicy(parent *serviceOpts) *servicePolicyOpts {
+	return &servicePolicyOpts{serviceOpts: parent}
+}
+
+func (opts *servicePolicyOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Set policies for a service.",
+		Long: `Set policies for a service, other than those with commands of their
+own (automate, lock, pause and so on).
+
+Containers given with --ignore-containers (e.g., sidecars like
+istio-proxy) are left out of list-images and never released; give an
+empty list to stop ignoring any.`,
+		Example: makeExample(
+			"fluxctl policy --service=helloworld --ignore-containers=istio-proxy,linkerd-proxy",
+			"fluxctl policy --service=helloworld --ignore-containers=",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to set policies for")
+	cmd.Flags().StringVar(&opts.ignoreContainers, "ignore-containers", "", "Comma-separated list of containers to ignore")
+	return cmd
+}
+
+func (opts *servicePolicyOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+
+	var u policy.Update
+	switch {
+	case !cmd.Flags().Changed("ignore-containers"):
+		return newUsageError("no policies given to change")
+	case opts.ignoreContainers == "":
+		u.Remove = policy.Set{}.Add(policy.IgnoreContainers)
+	default:
+		u.Add = policy.Set{policy.IgnoreContainers: opts.ignoreContainers}
+	}
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: u,
+	}, opts.cause, opts.dryRun)
+	if err != nil {
+		return err
+	}
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+}
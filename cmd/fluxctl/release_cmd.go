@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -16,7 +22,9 @@ type serviceReleaseOpts struct {
 	image       string
 	allImages   bool
 	exclude     []string
+	containers  []string
 	dryRun      bool
+	confirm     bool
 	outputOpts
 	cause update.Cause
 }
@@ -33,6 +41,8 @@ func (opts *serviceReleaseOpts) Command() *cobra.Command {
 			"fluxctl release --service=default/foo --update-image=library/hello:v2",
 			"fluxctl release --all --update-image=library/hello:v2",
 			"fluxctl release --service=default/foo --update-all-images",
+			"fluxctl release --service=default/foo --container=app --update-image=library/hello:v2",
+			"fluxctl release --all --update-all-images --confirm",
 		),
 		RunE: opts.RunE,
 	}
@@ -44,11 +54,15 @@ func (opts *serviceReleaseOpts) Command() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.image, "update-image", "i", "", "update a specific image")
 	cmd.Flags().BoolVar(&opts.allImages, "update-all-images", false, "update all images to latest versions")
 	cmd.Flags().StringSliceVar(&opts.exclude, "exclude", []string{}, "exclude a service")
+	cmd.Flags().StringSliceVar(&opts.containers, "container", []string{}, "release only this container of the services given (default all containers)")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not release anything; just report back what would have been done")
+	cmd.Flags().BoolVar(&opts.confirm, "confirm", false, "report what would be done, including which pods would be replaced, and ask before going ahead")
 	return cmd
 }
 
 func (opts *serviceReleaseOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) != 0 {
 		return errorWantedNoArgs
 	}
@@ -60,6 +74,9 @@ func (opts *serviceReleaseOpts) RunE(cmd *cobra.Command, args []string) error {
 	if len(opts.services) <= 0 && !opts.allServices {
 		return newUsageError("please supply either --all, or at least one --service=<service>")
 	}
+	if len(opts.containers) > 0 && opts.allServices {
+		return newUsageError("--container can only be used with --service")
+	}
 
 	var services []update.ServiceSpec
 	if opts.allServices {
@@ -101,21 +118,114 @@ func (opts *serviceReleaseOpts) RunE(cmd *cobra.Command, args []string) error {
 		excludes = append(excludes, s)
 	}
 
-	if opts.dryRun {
-		fmt.Fprintf(cmd.OutOrStderr(), "Submitting dry-run release...\n")
-	} else {
-		fmt.Fprintf(cmd.OutOrStderr(), "Submitting release ...\n")
+	// Each container given is picked out of each service given
+	var containers []update.ContainerSpec
+	for _, service := range opts.services {
+		for _, container := range opts.containers {
+			c, err := update.ParseContainerSpec(service + ":" + container)
+			if err != nil {
+				return err
+			}
+			containers = append(containers, c)
+		}
 	}
 
-	jobID, err := opts.API.UpdateImages(noInstanceID, update.ReleaseSpec{
+	spec := update.ReleaseSpec{
 		ServiceSpecs: services,
 		ImageSpec:    image,
 		Kind:         kind,
 		Excludes:     excludes,
-	}, opts.cause)
+		Containers:   containers,
+	}
+
+	if opts.confirm && !opts.dryRun {
+		ok, err := opts.confirmRelease(ctx, cmd, spec)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	// A dry run is answered straight away, without a job
+	if opts.dryRun {
+		fmt.Fprintf(cmd.OutOrStderr(), "Planning release...\n")
+		plan, err := opts.planRelease(ctx, spec)
+		if err != nil {
+			return err
+		}
+		update.PrintResults(cmd.OutOrStdout(), plan, opts.verbose)
+		return nil
+	}
+
+	fmt.Fprintf(cmd.OutOrStderr(), "Submitting release ...\n")
+	jobID, err := opts.API.UpdateImages(ctx, noInstanceID, spec, opts.cause)
 	if err != nil {
 		return err
 	}
 
-	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, !opts.dryRun, opts.verbose)
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, true, opts.verbose)
+}
+
+// confirmRelease does a dry run of the release, shows what it would
+// do, including how many pods would be replaced, and asks whether to
+// go ahead.
+func (opts *serviceReleaseOpts) confirmRelease(ctx context.Context, cmd *cobra.Command, spec update.ReleaseSpec) (bool, error) {
+	stderr := cmd.OutOrStderr()
+	fmt.Fprintf(stderr, "Planning release...\n")
+	plan, err := opts.planRelease(ctx, spec)
+	if err != nil {
+		return false, err
+	}
+
+	var services, pods, unbudgeted int
+	for _, result := range plan {
+		if result.Impact == nil {
+			continue
+		}
+		services++
+		pods += result.Impact.Pods
+		if !result.Impact.DisruptionBudget {
+			unbudgeted++
+		}
+	}
+	if services == 0 {
+		fmt.Fprintf(stderr, "Nothing to do\n")
+		return false, nil
+	}
+	update.PrintResults(cmd.OutOrStdout(), plan, opts.verbose)
+
+	fmt.Fprintf(stderr, "\nThis will replace %d pod(s) across %d service(s)", pods, services)
+	if unbudgeted > 0 {
+		fmt.Fprintf(stderr, "; %d service(s) have no disruption budget", unbudgeted)
+	}
+	fmt.Fprintf(stderr, ".\nGo ahead? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	fmt.Fprintf(stderr, "Release cancelled\n")
+	return false, nil
+}
+
+// planRelease asks for the plan of a release straight away. A service
+// too old to know about PlanRelease answers with "not found", in which
+// case the plan is got the old way, by running a job of kind plan.
+func (opts *serviceReleaseOpts) planRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	plan, err := opts.API.PlanRelease(ctx, noInstanceID, spec)
+	if _, ok := errors.Cause(err).(flux.Missing); !ok {
+		return plan, err
+	}
+	spec.Kind = update.ReleaseKindPlan
+	jobID, err := opts.API.UpdateImages(ctx, noInstanceID, spec, opts.cause)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := awaitJob(ctx, opts.API, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.Result, nil
 }
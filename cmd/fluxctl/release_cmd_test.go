@@ -1,6 +1,7 @@
 package main //+integration
 
 import (
+	"io/ioutil"
 	"testing"
 
 	"github.com/weaveworks/flux/update"
@@ -10,38 +11,42 @@ func TestReleaseCommand_CLIConversion(t *testing.T) {
 	for _, v := range []struct {
 		args           []string
 		expectedParams map[string]string
+		dryRun         bool
 	}{
 		{[]string{"--update-all-images", "--all"}, map[string]string{
 			"service": string(update.ServiceSpecAll),
 			"image":   string(update.ImageSpecLatest),
 			"kind":    string(update.ReleaseKindExecute),
-		}},
+		}, false},
 		{[]string{"--update-all-images", "--all", "--dry-run"}, map[string]string{
 			"service": string(update.ServiceSpecAll),
 			"image":   string(update.ImageSpecLatest),
-			"kind":    string(update.ReleaseKindPlan),
-		}},
+		}, true},
 		{[]string{"--update-image=alpine:latest", "--all"}, map[string]string{
 			"service": string(update.ServiceSpecAll),
 			"image":   "alpine:latest",
 			"kind":    string(update.ReleaseKindExecute),
-		}},
+		}, false},
 		{[]string{"--update-all-images", "--service=default/flux"}, map[string]string{
 			"service": "default/flux",
 			"image":   string(update.ImageSpecLatest),
 			"kind":    string(update.ReleaseKindExecute),
-		}},
+		}, false},
 		{[]string{"--update-all-images", "--all", "--exclude=default/test,default/yeah"}, map[string]string{
 			"service": string(update.ServiceSpecAll),
 			"image":   string(update.ImageSpecLatest),
 			"kind":    string(update.ReleaseKindExecute),
 			"exclude": "default/test,default/yeah",
-		}},
+		}, false},
 	} {
 		svc := testArgs(t, v.args, false, "")
 
-		// Check that PostRelease was called with correct args
+		// Check that the release, or for a dry run the plan, was
+		// asked for with the correct args
 		method := "UpdateImages"
+		if v.dryRun {
+			method = "PlanRelease"
+		}
 		if calledURL(method, svc.requestHistory) == nil {
 			t.Fatalf("Expecting fluxctl to request %q, but did not.", method)
 		}
@@ -50,12 +55,40 @@ func TestReleaseCommand_CLIConversion(t *testing.T) {
 			assertString(t, vv, vars[kk])
 		}
 
-		// Check that GetRelease was polled for status
+		// Check that GetRelease was polled for status, unless it's
+		// a dry run, which is answered straight away
 		method = "JobStatus"
-		if calledURL(method, svc.requestHistory) == nil {
-			t.Fatalf("Expecting fluxctl to request %q, but did not.", method)
+		if polled := calledURL(method, svc.requestHistory) != nil; polled == v.dryRun {
+			t.Fatalf("Expecting fluxctl to request %q: %v, but it did: %v.", method, !v.dryRun, polled)
+		}
+	}
+}
+
+// A service that doesn't know about PlanRelease still gets asked for
+// a plan, as a job of kind plan.
+func TestReleaseCommand_PlanFallback(t *testing.T) {
+	svc := newMockService()
+	for route := range svc.mockResponses {
+		if route.GetName() == "PlanRelease" {
+			delete(svc.mockResponses, route)
 		}
 	}
+	releaseClient := newServiceRelease(mockServiceOpts(svc))
+
+	cmd := releaseClient.Command()
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--update-all-images", "--all", "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calledURL("UpdateImages", svc.requestHistory) == nil {
+		t.Fatal("Expecting fluxctl to fall back to UpdateImages, but it did not.")
+	}
+	assertString(t, string(update.ReleaseKindPlan), calledRequest("UpdateImages", svc.requestHistory).Vars["kind"])
+	if calledURL("JobStatus", svc.requestHistory) == nil {
+		t.Fatal("Expecting fluxctl to poll JobStatus for the plan, but it did not.")
+	}
 }
 
 func TestReleaseCommand_InputFailures(t *testing.T) {
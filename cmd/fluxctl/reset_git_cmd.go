@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type resetGitOpts struct {
+	*rootOpts
+}
+
+func newResetGit(parent *rootOpts) *resetGitOpts {
+	return &resetGitOpts{rootOpts: parent}
+}
+
+func (opts *resetGitOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reset-git",
+		Short: "Carry on from the git branch as it is now, after it has been rewritten",
+		Long: `If the branch in the git repo has been force-pushed, flux won't make
+commits to it or sync from it until told to carry on. This discards
+any commits flux made that are no longer on the branch.`,
+		RunE: opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *resetGitOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+
+	rev, err := opts.API.ResetGitToRemote(context.Background(), noInstanceID)
+	if err != nil {
+		return err
+	}
+	fmt.Println(rev)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/update"
+)
+
+type serviceRollbackOpts struct {
+	*serviceOpts
+	services    []string
+	allServices bool
+	revision    string
+	dryRun      bool
+	outputOpts
+	cause update.Cause
+}
+
+func newServiceRollback(parent *serviceOpts) *serviceRollbackOpts {
+	return &serviceRollbackOpts{serviceOpts: parent}
+}
+
+func (opts *serviceRollbackOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Put services back to the images they had before their last release, or to how they were at an earlier commit.",
+		Example: makeExample(
+			"fluxctl rollback --service=default/foo",
+			"fluxctl rollback --service=default/foo --to-revision=1a2b3c4",
+			"fluxctl rollback --all --to-revision=1a2b3c4 --dry-run",
+		),
+		RunE: opts.RunE,
+	}
+
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	cmd.Flags().StringSliceVarP(&opts.services, "service", "s", []string{}, "service to roll back")
+	cmd.Flags().BoolVar(&opts.allServices, "all", false, "roll back all services; only with --to-revision")
+	cmd.Flags().StringVar(&opts.revision, "to-revision", "", "put the services' manifests back to how they were at this commit, rather than rolling back their images")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not roll back anything; just report back what would have been done")
+	return cmd
+}
+
+func (opts *serviceRollbackOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+
+	if len(opts.services) <= 0 && !opts.allServices {
+		return newUsageError("please supply either --all, or at least one --service=<service>")
+	}
+	if opts.allServices && opts.revision == "" {
+		return newUsageError("--all can only be used with --to-revision")
+	}
+
+	var services []update.ServiceSpec
+	if opts.allServices {
+		services = []update.ServiceSpec{update.ServiceSpecAll}
+	} else {
+		for _, service := range opts.services {
+			if _, err := flux.ParseServiceID(service); err != nil {
+				return err
+			}
+			services = append(services, update.ServiceSpec(service))
+		}
+	}
+
+	var kind update.ReleaseKind = update.ReleaseKindExecute
+	if opts.dryRun {
+		kind = update.ReleaseKindPlan
+	}
+
+	spec := update.RollbackSpec{
+		ServiceSpecs: services,
+		Revision:     opts.revision,
+		Kind:         kind,
+	}
+
+	fmt.Fprintf(cmd.OutOrStderr(), "Submitting rollback ...\n")
+	jobID, err := opts.API.Rollback(ctx, noInstanceID, spec, opts.cause)
+	if err != nil {
+		return err
+	}
+
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, true, opts.verbose)
+}
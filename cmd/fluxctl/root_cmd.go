@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -14,14 +15,22 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/api"
 	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/http/auth"
 	"github.com/weaveworks/flux/http/client"
 	"github.com/weaveworks/flux/service"
 )
 
 type rootOpts struct {
-	URL   string
-	Token string
-	API   api.ClientService
+	URL         string
+	Token       string
+	AuthScheme  string
+	Retries     int
+	TLSCACert   string
+	TLSCert     string
+	TLSKey      string
+	Proxy       string
+	DialTimeout time.Duration
+	API         api.ClientService
 }
 
 // fluxctl never sends an instance ID directly; it's always blank, and
@@ -67,6 +76,20 @@ func (opts *rootOpts) Command() *cobra.Command {
 		fmt.Sprintf("base URL of the flux service; you can also set the environment variable %s", envVariableURL))
 	cmd.PersistentFlags().StringVarP(&opts.Token, "token", "t", "",
 		fmt.Sprintf("Weave Cloud service token; you can also set the environment variable %s or %s", envVariableCloudToken, envVariableToken))
+	cmd.PersistentFlags().StringVar(&opts.AuthScheme, "auth-scheme", auth.SchemeScopeProbe,
+		`how to send the token: one of "scope-probe", "bearer", "basic" (with the token as "username:password"), or "header:<name>"`)
+	cmd.PersistentFlags().IntVar(&opts.Retries, "retries", client.DefaultRetryPolicy.MaxAttempts-1,
+		"number of times to retry a request that fails because of network problems or a server error; only requests that are safe to repeat are retried")
+	cmd.PersistentFlags().StringVar(&opts.TLSCACert, "tls-ca-cert", "",
+		"file of PEM-encoded CA certificates to trust for the flux service, in place of the system's")
+	cmd.PersistentFlags().StringVar(&opts.TLSCert, "tls-cert", "",
+		"file with a PEM-encoded client certificate to present to the flux service; needs --tls-key")
+	cmd.PersistentFlags().StringVar(&opts.TLSKey, "tls-key", "",
+		"file with the PEM-encoded private key for --tls-cert")
+	cmd.PersistentFlags().StringVar(&opts.Proxy, "proxy", "",
+		`URL of a proxy to use; by default, the proxy is taken from the environment (HTTPS_PROXY etc.), and "none" means don't use one`)
+	cmd.PersistentFlags().DurationVar(&opts.DialTimeout, "dial-timeout", 30*time.Second,
+		"how long to wait for a connection to the flux service")
 
 	svcopts := newService(opts)
 
@@ -75,12 +98,22 @@ func (opts *rootOpts) Command() *cobra.Command {
 		newServiceShow(svcopts).Command(),
 		newServiceList(svcopts).Command(),
 		newServiceRelease(svcopts).Command(),
+		newServiceRollback(svcopts).Command(),
 		newServiceAutomate(svcopts).Command(),
 		newServiceDeautomate(svcopts).Command(),
 		newServiceLock(svcopts).Command(),
 		newServiceUnlock(svcopts).Command(),
+		newServicePause(svcopts).Command(),
+		newServiceUnpause(svcopts).Command(),
+		newServicePolicy(svcopts).Command(),
 		newSave(opts).Command(),
 		newIdentity(opts).Command(),
+		newResetGit(opts).Command(),
+		newSync(opts).Command(),
+		newCheck(opts).Command(),
+		newJobLog(opts).Command(),
+		newEvaluateImage(opts).Command(),
+		newAdd(opts).Command(),
 	)
 
 	return cmd
@@ -92,10 +125,49 @@ func (opts *rootOpts) PersistentPreRunE(cmd *cobra.Command, _ []string) error {
 		return errors.Wrapf(err, "parsing URL")
 	}
 	opts.Token = getFromEnvIfNotSet(cmd.Flags(), "token", opts.Token, envVariableToken, envVariableCloudToken)
-	opts.API = client.New(http.DefaultClient, transport.NewAPIRouter(), opts.URL, flux.Token(opts.Token))
+	retry := client.DefaultRetryPolicy
+	retry.MaxAttempts = opts.Retries + 1
+	clientOpts, err := opts.clientOptions()
+	if err != nil {
+		return err
+	}
+	apiClient := client.New(http.DefaultClient, transport.NewAPIRouter(), opts.URL, flux.Token(opts.Token), clientOpts...).WithRetryPolicy(retry)
+	if opts.AuthScheme != auth.SchemeScopeProbe {
+		scheme, err := auth.New(opts.AuthScheme, opts.Token)
+		if err != nil {
+			return err
+		}
+		apiClient = apiClient.WithAuthenticator(scheme)
+	}
+	opts.API = apiClient
 	return nil
 }
 
+// clientOptions gives the options for making connections, from the
+// flags.
+func (opts *rootOpts) clientOptions() ([]client.Option, error) {
+	clientOpts := []client.Option{client.WithDialTimeout(opts.DialTimeout)}
+	if opts.TLSCACert != "" || opts.TLSCert != "" || opts.TLSKey != "" {
+		tlsConfig, err := client.LoadTLSConfig(opts.TLSCACert, opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, client.WithTLSConfig(tlsConfig))
+	}
+	switch opts.Proxy {
+	case "":
+	case "none":
+		clientOpts = append(clientOpts, client.WithProxy(nil))
+	default:
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing proxy URL")
+		}
+		clientOpts = append(clientOpts, client.WithProxy(proxyURL))
+	}
+	return clientOpts, nil
+}
+
 func getFromEnvIfNotSet(flags *pflag.FlagSet, flagName, value string, envNames ...string) string {
 	if flags.Changed(flagName) {
 		return value
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -16,6 +17,7 @@ import (
 type saveOpts struct {
 	*rootOpts
 	path string
+	ref  string
 }
 
 func newSave(parent *rootOpts) *saveOpts {
@@ -28,10 +30,12 @@ func (opts *saveOpts) Command() *cobra.Command {
 		Short: "save service definitions to local files in platform-native format",
 		Example: makeExample(
 			"fluxctl save",
+			"fluxctl save --ref=v1.2.0 --out=deployed-at-v1.2.0/",
 		),
 		RunE: opts.RunE,
 	}
 	cmd.Flags().StringVarP(&opts.path, "out", "o", "-", "output path for exported config; the default. '-' indicates stdout; if a directory is given, each item will be saved in a file under the directory")
+	cmd.Flags().StringVar(&opts.ref, "ref", "", "save the manifests as they were in the git repo at this commit or tag, rather than what's running in the cluster")
 	return cmd
 }
 
@@ -51,18 +55,12 @@ type saveObject struct {
 }
 
 func (opts *saveOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
 
-	config, err := opts.API.Export(noInstanceID)
-	if err != nil {
-		return errors.Wrap(err, "exporting config")
-	}
-
-	yamls := bufio.NewScanner(bytes.NewReader(config))
-	yamls.Split(splitYAMLDocument)
-
 	if opts.path != "-" {
 		// check supplied path is a directory
 		if info, err := os.Stat(opts.path); err != nil {
@@ -72,6 +70,25 @@ func (opts *saveOpts) RunE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// The export is split into objects as it arrives, so it's never
+	// all in memory at once.
+	config, export := io.Pipe()
+	defer config.Close()
+	go func() {
+		if opts.ref != "" {
+			manifests, err := opts.API.ExportAt(ctx, noInstanceID, opts.ref)
+			if err == nil {
+				_, err = export.Write(manifests)
+			}
+			export.CloseWithError(err)
+			return
+		}
+		export.CloseWithError(opts.API.ExportTo(ctx, noInstanceID, export))
+	}()
+
+	yamls := bufio.NewScanner(config)
+	yamls.Split(splitYAMLDocument)
+
 	for yamls.Scan() {
 		var object saveObject
 		// Most unwanted fields are ignored at this point
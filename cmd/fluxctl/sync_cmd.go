@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/remote"
+)
+
+type syncOpts struct {
+	*rootOpts
+	wait    bool
+	timeout time.Duration
+	verbose bool
+}
+
+func newSync(parent *rootOpts) *syncOpts {
+	return &syncOpts{rootOpts: parent}
+}
+
+func (opts *syncOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync the cluster with the git repo now",
+		Long: `Ask the daemon to sync the cluster with the git repo straight away.
+With --wait, wait for the sync to finish, and report which resources
+were applied, which failed, and so on; this exits with an error if
+anything failed to apply, so can be used to check that the cluster has
+converged.`,
+		Example: makeExample(
+			"fluxctl sync",
+			"fluxctl sync --wait --timeout=10m",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().BoolVar(&opts.wait, "wait", false, "wait for the sync to finish, and report on it")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 5*time.Minute, "how long to wait for the sync to finish, with --wait")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "with --wait, list every resource, not only those applied or failed")
+	return cmd
+}
+
+func (opts *syncOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+
+	ctx := context.Background()
+	if !opts.wait {
+		return opts.API.SyncNotify(ctx, noInstanceID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+	var req remote.SyncWaitRequest
+	for {
+		report, err := opts.API.SyncWait(ctx, noInstanceID, req)
+		if errors.Cause(err) == context.DeadlineExceeded {
+			return ErrTimeout
+		}
+		if err != nil {
+			return err
+		}
+		if report.Done {
+			printSyncReport(report, opts.verbose)
+			if !report.Converged() {
+				return errors.New("not all resources were applied")
+			}
+			return nil
+		}
+		req.Since = report.Requested
+	}
+}
+
+func printSyncReport(report remote.SyncReport, verbose bool) {
+	revision := report.Revision
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	duration := report.Duration - report.Duration%time.Millisecond
+	fmt.Printf("Synced %s in %s: %d applied, %d unchanged, %d ignored, %d failed\n",
+		revision, duration, len(report.Applied), len(report.Unchanged), len(report.Ignored), len(report.Failed))
+	if report.Error != "" {
+		fmt.Printf("Error: %s\n", report.Error)
+	}
+	w := newTabwriter()
+	defer w.Flush()
+	for _, id := range report.Applied {
+		fmt.Fprintf(w, "applied\t%s\n", id)
+	}
+	pruned := "pruned"
+	if report.PruneDryRun {
+		pruned = "would prune"
+	}
+	for _, id := range report.Pruned {
+		fmt.Fprintf(w, "%s\t%s\n", pruned, id)
+	}
+	for _, id := range report.Drifted {
+		fmt.Fprintf(w, "drifted\t%s\n", id)
+	}
+	for _, f := range report.Failed {
+		fmt.Fprintf(w, "failed\t%s\t%s\n", f.ID, f.Error)
+	}
+	if !verbose {
+		return
+	}
+	for _, id := range report.Unchanged {
+		fmt.Fprintf(w, "unchanged\t%s\n", id)
+	}
+	for _, id := range report.Ignored {
+		fmt.Fprintf(w, "ignored\t%s\n", id)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/spf13/cobra"
 
 	"github.com/weaveworks/flux"
@@ -10,9 +11,11 @@ import (
 
 type serviceUnlockOpts struct {
 	*serviceOpts
-	service string
+	service   string
+	container string
 	outputOpts
-	cause update.Cause
+	cause  update.Cause
+	dryRun bool
 }
 
 func newServiceUnlock(parent *serviceOpts) *serviceUnlockOpts {
@@ -25,16 +28,21 @@ func (opts *serviceUnlockOpts) Command() *cobra.Command {
 		Short: "Unlock a service, so it can be deployed.",
 		Example: makeExample(
 			"fluxctl unlock --service=helloworld",
+			"fluxctl unlock --service=helloworld --container=sidecar",
 		),
 		RunE: opts.RunE,
 	}
 	AddOutputFlags(cmd, &opts.outputOpts)
 	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
 	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to unlock")
+	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "Container to unlock, having been locked by itself")
 	return cmd
 }
 
 func (opts *serviceUnlockOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
 	if len(args) > 0 {
 		return errorWantedNoArgs
 	}
@@ -47,11 +55,15 @@ func (opts *serviceUnlockOpts) RunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	jobID, err := opts.API.UpdatePolicies(noInstanceID, policy.Updates{
-		serviceID: policy.Update{Remove: policy.Set{policy.Locked: "true"}},
-	}, opts.cause)
+	u := policy.Update{Remove: policy.Set{policy.Locked: "true"}}
+	if opts.container != "" {
+		u = policy.Update{Remove: policy.Set{policy.Policy(policy.LockedPrefix + opts.container): "true"}}
+	}
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: u,
+	}, opts.cause, opts.dryRun)
 	if err != nil {
 		return err
 	}
-	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+type serviceUnpauseOpts struct {
+	*serviceOpts
+	service string
+	outputOpts
+	cause  update.Cause
+	dryRun bool
+}
+
+func newServiceUnpause(parent *serviceOpts) *serviceUnpauseOpts {
+	return &serviceUnpauseOpts{serviceOpts: parent}
+}
+
+func (opts *serviceUnpauseOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unpause",
+		Short: "Resume automation of a paused service, without waiting for the pause to run out.",
+		Example: makeExample(
+			"fluxctl unpause --service=helloworld",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	AddDryRunFlag(cmd, &opts.dryRun)
+	cmd.Flags().StringVarP(&opts.service, "service", "s", "", "Service to unpause")
+	return cmd
+}
+
+func (opts *serviceUnpauseOpts) RunE(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.service == "" {
+		return newUsageError("-s, --service is required")
+	}
+
+	serviceID, err := flux.ParseServiceID(opts.service)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := opts.API.UpdatePolicies(ctx, noInstanceID, policy.Updates{
+		serviceID: policy.Update{Remove: policy.Set{policy.Paused: "true"}},
+	}, opts.cause, opts.dryRun)
+	if err != nil {
+		return err
+	}
+	return await(ctx, cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+}
@@ -8,32 +8,40 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 	"github.com/weaveworks/go-checkpoint"
 	k8sclient "k8s.io/client-go/1.5/kubernetes"
+	v1 "k8s.io/client-go/1.5/pkg/api/v1"
 	"k8s.io/client-go/1.5/rest"
 
 	//	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/cluster/kubernetes"
+	"github.com/weaveworks/flux/cluster/kubernetes/helm"
 	"github.com/weaveworks/flux/daemon"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/history"
 	transport "github.com/weaveworks/flux/http"
+	fluxclient "github.com/weaveworks/flux/http/client"
 	daemonhttp "github.com/weaveworks/flux/http/daemon"
 	"github.com/weaveworks/flux/job"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/registry"
 	registryMemcache "github.com/weaveworks/flux/registry/cache"
 	registryMiddleware "github.com/weaveworks/flux/registry/middleware"
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/ssh"
+	fluxsync "github.com/weaveworks/flux/sync"
 )
 
 var version string
@@ -46,6 +54,11 @@ const (
 	defaultMemcacheConnections = 10
 )
 
+const (
+	manifestsKubernetes = "kubernetes"
+	manifestsHelmValues = "helm-values"
+)
+
 func optionalVar(fs *pflag.FlagSet, value ssh.OptionalValue, name, usage string) ssh.OptionalValue {
 	fs.Var(value, name, usage)
 	return value
@@ -64,7 +77,16 @@ func main() {
 	// This mirrors how kubectl extracts information from the environment.
 	var (
 		listenAddr        = fs.StringP("listen", "l", ":3030", "Listen address where /metrics and API will be served")
+		metricsBackend    = fs.String("metrics-backend", fluxmetrics.BackendPrometheus, `where metrics go: "prometheus" to serve them at /metrics, or "dogstatsd" to send them to a DogStatsD agent (e.g., Datadog's)`)
+		dogstatsdAddr     = fs.String("dogstatsd-address", "localhost:8125", "UDP address of the DogStatsD agent, with --metrics-backend=dogstatsd")
+		dogstatsdInterval = fs.Duration("dogstatsd-flush-interval", 10*time.Second, "how often to send metrics to the DogStatsD agent")
 		kubernetesKubectl = fs.String("kubernetes-kubectl", "", "Optional, explicit path to kubectl tool")
+		excludeKinds      = fs.String("k8s-exclude-kinds", "", "comma-separated list of resource kinds (e.g., Secret) that flux should not sync or export; kinds excluded in the instance config replace them")
+		namespaces        = fs.StringSlice("k8s-namespace", nil, "namespaces flux looks after; resources in other namespaces are left alone, as though their manifests weren't in the git repo (default all namespaces)")
+		customKindsFile   = fs.String("k8s-custom-kinds", "", "file listing kinds of custom resource that run containers, and where in each the containers are given, so that they can be released")
+		defaultNamespace  = fs.String("k8s-default-namespace", "default", "namespace that resources are put in when their manifests don't say which, rather than whatever kubectl would pick")
+		namespacePolicies = fs.String("namespace-policies", "", "file giving, as YAML, default policies for the services in each namespace (e.g., automated in staging); a service's own annotations override them, and namespace policies given in the instance config replace them")
+		featureFlags      = fs.StringSlice("feature", nil, `experimental features to switch on, by name; "name=false" switches a feature off`)
 		versionFlag       = fs.Bool("version", false, "Get version number")
 		// Git repo & key etc.
 		gitURL          = fs.String("git-url", "", "URL of git repo with Kubernetes manifests; e.g., git@github.com:weaveworks/flux-example")
@@ -74,9 +96,39 @@ func main() {
 		gitEmail        = fs.String("git-email", "support@weave.works", "email to use as git committer")
 		gitSyncTag      = fs.String("git-sync-tag", "flux-sync", "tag to use to mark sync progress for this cluster")
 		gitNotesRef     = fs.String("git-notes-ref", "flux", "ref to use for keeping commit annotations in git notes")
+		gitLFSInclude   = fs.StringSlice("git-lfs-include", nil, `patterns of files stored with Git LFS to fetch into the checkout, e.g., "charts/**"; other LFS files are left as pointers`)
 		gitPollInterval = fs.Duration("git-poll-interval", 5*time.Minute, "period at which to poll git repo for new commits")
+		gitLayoutDir    = fs.String("git-layout-dir", "", `template for the directory, within --git-path, that manifests for new resources are put in; e.g., "{{.Namespace}}" for a directory per namespace`)
+		gitLayoutFile   = fs.String("git-layout-filename", flux.DefaultLayoutFilename, "template for the filename manifests for new resources are given")
+		gitInclude      = fs.StringSlice("git-include", nil, `globs for the paths, within --git-path, that manifests are looked for in, e.g., "team-a/*"; a glob matching a directory includes everything under it (default everywhere)`)
+		gitExclude      = fs.StringSlice("git-exclude", nil, "globs for paths, within --git-path, that manifests are not looked for in, even if included")
+		gitConflict     = fs.String("git-conflict-policy", string(flux.ConflictAbort), `what to do when a commit touches the same files as changes pushed upstream in the meantime: "prefer-human" drops the commit, "prefer-automation" pushes it anyway, "abort" fails the job`)
+		// sync health
+		syncHealthWindow    = fs.Duration("sync-health-window", daemon.DefaultSyncHealthWindow, "rolling window over which sync health is reported")
+		syncFreshness       = fs.Duration("sync-objective-freshness", 0, "objective for how out of date the cluster may get, i.e., the time since the last successful sync; a failure is reported when the error budget for it runs out (default no objective)")
+		syncFreshnessTarget = fs.Float64("sync-objective-target", 0.99, "fraction of the sync health window for which the freshness objective should be met")
+		syncPruneDryRun     = fs.Bool("sync-prune-dry-run", false, "report the resources that would be pruned, in namespaces with the prune policy, rather than deleting them")
+		// how the files in the repo are interpreted
+		manifestsFormat = fs.String("manifests", manifestsKubernetes, `what the files in the git repo are: "kubernetes" for Kubernetes manifests, or "helm-values" for Helm charts, the values of which give the images to release`)
+		helmNamespace   = fs.String("helm-namespace", "default", "namespace of the services deployed from Helm charts, for charts that don't say")
+		helmImagePaths  = fs.StringSlice("helm-image-path", nil, `where a container's image is given in chart values, as "container=image.repository:image.tag", or "container=image" for the whole image in one value; containers not mentioned use image.repository and image.tag`)
+		// jobs
+		jobLogLimit     = fs.Int("job-log-limit", 64*1024, "maximum number of bytes of output to keep for each job; 0 means keep none")
+		jobLogRetention = fs.Duration("job-log-retention", time.Hour, "how long to keep the output from each job after it finishes")
+		jobExecutor     = fs.String("job-executor", jobExecutorInProcess, `where to run jobs: "in-process", or "kubernetes" to run each in a worker pod`)
+		// for the kubernetes job executor
+		jobWorkerImage          = fs.String("job-worker-image", "", "image to run job workers with; it must have fluxd as its entrypoint, and is usually the same image as this daemon")
+		jobWorkerServiceAccount = fs.String("job-worker-service-account", "", "service account to run job workers as (default is that of the namespace)")
+		jobWorkerTimeout        = fs.Duration("job-worker-timeout", 10*time.Minute, "how long to wait for a job worker to report, before giving up on it")
+		jobReportURL            = fs.String("job-report-url", "", "base URL of this daemon's API, as job workers can reach it; e.g., http://flux.default.svc.cluster.local/api/flux")
+		// when running as a job worker
+		runJob          = fs.String("run-job", "", "run the job with this ID, report its status, and exit; this is how the kubernetes job executor runs workers")
+		runJobSpec      = fs.String("run-job-spec", "", "file containing the update spec of the job to run, as a worker")
+		runJobTokenFile = fs.String("run-job-token-file", "", "file containing the token to send with a worker's report")
+		runJobReportURL = fs.String("run-job-report-url", "", "base URL of the daemon's API to send a worker's report to")
 		// registry
 		dockerCredFile       = fs.String("docker-config", "~/.docker/config.json", "Path to config file with credentials for DockerHub, quay.io etc.")
+		registrySecretsDir   = fs.String("registry-secrets-dir", "", "directory with a file for each secret named by registry credentials in the instance config (e.g., a mounted Kubernetes Secret); if not given, those credentials can't be used")
 		memcachedHostname    = fs.String("memcached-hostname", "", "Hostname for memcached service to use when caching chunks. If empty, no memcached will be used.")
 		memcachedTimeout     = fs.Duration("memcached-timeout", time.Second, "Maximum time to wait before giving up on memcached requests.")
 		memcachedService     = fs.String("memcached-service", "memcached", "SRV service used to discover memcache servers.")
@@ -85,6 +137,10 @@ func main() {
 		registryPollInterval = fs.Duration("registry-poll-interval", 5*time.Minute, "period at which to poll registry for new images")
 		registryRPS          = fs.Int("registry-rps", 200, "maximum registry requests per second per host")
 		registryBurst        = fs.Int("registry-burst", defaultRemoteConnections, "maximum registry request burst per host (default matched to number of http worker goroutines)")
+		registryTagAliases   = fs.StringSlice("registry-tag-alias", nil, `tag that is moved from image to image, given as an image, e.g., "quay.io/weaveworks/helloworld:stable"; releases resolve it to the concrete tag it points at`)
+		registryTagOrderer   = fs.String("registry-tag-orderer", "", `rank tags for automation by asking a webhook, given as an http(s) URL, or a command, given as "exec:<command>", rather than taking the most recent image to be the latest`)
+		tagOrdererTimeout    = fs.Duration("registry-tag-orderer-timeout", 10*time.Second, "how long to wait for the tag orderer to answer")
+		tagOrdererCacheTTL   = fs.Duration("registry-tag-orderer-cache-ttl", 5*time.Minute, "how long to remember how the tag orderer ranked a repository's tags, if they haven't changed")
 		// k8s-secret backed ssh keyring configuration
 		k8sSecretName            = fs.String("k8s-secret-name", "flux-git-deploy", "Name of the k8s secret used to store the private SSH key")
 		k8sSecretVolumeMountPath = fs.String("k8s-secret-volume-mount-path", "/etc/fluxd/ssh", "Mount location of the k8s secret storing the private SSH key")
@@ -93,8 +149,10 @@ func main() {
 		sshKeyBits = optionalVar(fs, &ssh.KeyBitsValue{}, "ssh-keygen-bits", "-b argument to ssh-keygen (default unspecified)")
 		sshKeyType = optionalVar(fs, &ssh.KeyTypeValue{}, "ssh-keygen-type", "-t argument to ssh-keygen (default unspecified)")
 
-		upstreamURL = fs.String("connect", "", "Connect to an upstream service e.g., Weave Cloud, at this base address")
-		token       = fs.String("token", "", "Authentication token for upstream service")
+		upstreamURL  = fs.String("connect", "", "Connect to an upstream service e.g., Weave Cloud, at this base address")
+		upstreamGRPC = fs.String("connect-grpc", "", "Connect to the upstream service over gRPC at this address (host:port), instead of by websocket; --connect is still needed, for the API")
+		token        = fs.String("token", "", "Authentication token for upstream service")
+		tokenFile    = fs.String("token-file", "", "File containing the authentication token for upstream service (instead of --token); it is re-read periodically, and a changed token is given to the service without reconnecting (unless connected over gRPC)")
 	)
 	fs.Parse(os.Args)
 
@@ -114,11 +172,77 @@ func main() {
 		logger = log.NewContext(logger).With("caller", log.DefaultCaller)
 	}
 
+	switch *jobExecutor {
+	case jobExecutorInProcess, jobExecutorKubernetes:
+	default:
+		logger.Log("err", fmt.Sprintf("unknown job executor %q", *jobExecutor))
+		os.Exit(1)
+	}
+	switch *manifestsFormat {
+	case manifestsKubernetes, manifestsHelmValues:
+	default:
+		logger.Log("err", fmt.Sprintf("unknown manifests format %q", *manifestsFormat))
+		os.Exit(1)
+	}
+	gitConflictPolicy, err := flux.ParseGitConflictPolicy(*gitConflict)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	switch *metricsBackend {
+	case fluxmetrics.BackendPrometheus:
+		fluxmetrics.Use(fluxmetrics.Prometheus{})
+	case fluxmetrics.BackendDogStatsD:
+		statsd := fluxmetrics.NewDogStatsD(log.NewContext(logger).With("component", "metrics"))
+		fluxmetrics.Use(statsd)
+		go statsd.SendLoop(time.Tick(*dogstatsdInterval), "udp", *dogstatsdAddr)
+	default:
+		logger.Log("err", fmt.Sprintf("unknown metrics backend %q", *metricsBackend))
+		os.Exit(1)
+	}
+	// Workers for the kubernetes job executor get the same arguments
+	// as this daemon, apart from those about running jobs elsewhere,
+	// and those about connecting upstream.
+	workerArgs := workerArgs(fs)
+
 	// Platform component.
 	var clusterVersion string
 	var sshKeyRing ssh.KeyRing
 	var k8s cluster.Cluster
 	var k8sManifests cluster.Manifests
+	var executor job.Executor // nil, unless running jobs elsewhere
+	jobReports := &job.Reports{}
+	baseExclude := cluster.ParseKindFilter(*excludeKinds)
+	exclude := cluster.NewSharedKindFilter(baseExclude)
+	features, err := flux.ParseFeatures(*featureFlags)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	layout, err := flux.ParseRepoLayout(*gitLayoutDir, *gitLayoutFile)
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+	var customKinds kubernetes.CustomKinds
+	if *customKindsFile != "" {
+		customKinds, err = kubernetes.LoadCustomKinds(*customKindsFile)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+	var policyDefaults policy.NamespaceDefaults
+	if *namespacePolicies != "" {
+		policyDefaults, err = policy.LoadNamespaceDefaults(*namespacePolicies)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+	// These are replaced by any namespace policies given in the
+	// instance config
+	sharedPolicyDefaults := policy.NewSharedDefaults(policyDefaults)
 	{
 		restClientConfig, err := rest.InClusterConfig()
 		if err != nil {
@@ -180,7 +304,7 @@ func main() {
 		logger.Log("kubectl", kubectl)
 
 		kubectlApplier := kubernetes.NewKubectl(kubectl, restClientConfig, os.Stdout, os.Stderr)
-		cluster, err := kubernetes.NewCluster(clientset, kubectlApplier, sshKeyRing, logger)
+		cluster, err := kubernetes.NewCluster(clientset, kubectlApplier, sshKeyRing, exclude, customKinds, *defaultNamespace, logger)
 		if err != nil {
 			logger.Log("err", err)
 			os.Exit(1)
@@ -193,14 +317,98 @@ func main() {
 		}
 
 		k8s = cluster
-		// There is only one way we currently interpret a repo of
-		// files as manifests, and that's as Kubernetes yamels.
-		k8sManifests = &kubernetes.Manifests{}
+
+		if *jobExecutor == jobExecutorKubernetes && *runJob == "" {
+			secretMode := int32(0400)
+			executor, err = kubernetes.NewJobExecutor(clientset, jobReports, kubernetes.JobExecutorConfig{
+				Namespace:      string(namespace),
+				Image:          *jobWorkerImage,
+				ServiceAccount: *jobWorkerServiceAccount,
+				Args:           workerArgs,
+				// The workers need the same git deploy key as this
+				// daemon
+				Volumes: []v1.Volume{{
+					Name: "git-key",
+					VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{SecretName: *k8sSecretName, DefaultMode: &secretMode},
+					},
+				}},
+				VolumeMounts: []v1.VolumeMount{{
+					Name:      "git-key",
+					MountPath: *k8sSecretVolumeMountPath,
+				}},
+				ReportURL: *jobReportURL,
+				Timeout:   *jobWorkerTimeout,
+			})
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			logger.Log("job-executor", *jobExecutor, "worker-image", *jobWorkerImage)
+		}
+
+		// A repo of files is interpreted either as Kubernetes
+		// yamels, or as Helm charts, in which case it's the values
+		// of each chart that are changed.
+		switch *manifestsFormat {
+		case manifestsHelmValues:
+			imagePaths := map[string]helm.ImagePath{}
+			for _, s := range *helmImagePaths {
+				container, path, err := helm.ParseImagePath(s)
+				if err != nil {
+					logger.Log("err", err)
+					os.Exit(1)
+				}
+				imagePaths[container] = path
+			}
+			k8sManifests = &helm.Manifests{
+				Namespace:      *helmNamespace,
+				ImagePaths:     imagePaths,
+				PolicyDefaults: sharedPolicyDefaults,
+			}
+			logger.Log("manifests", *manifestsFormat)
+		default:
+			k8sManifests = &kubernetes.Manifests{
+				CustomKinds:      customKinds,
+				DefaultNamespace: *defaultNamespace,
+				PolicyDefaults:   sharedPolicyDefaults,
+			}
+		}
+
+		scope := kubernetes.Scope{
+			Include:    *gitInclude,
+			Exclude:    *gitExclude,
+			Namespaces: *namespaces,
+		}
+		if err := scope.Validate(); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		if !scope.IsEmpty() {
+			logger.Log("include", strings.Join(scope.Include, ","), "exclude", strings.Join(scope.Exclude, ","), "namespaces", strings.Join(scope.Namespaces, ","))
+		}
+		if len(scope.Include) > 0 || len(scope.Exclude) > 0 {
+			logger.Log("prune", "off", "reason", "only some paths in the repo are looked at")
+		}
+		k8sManifests = kubernetes.ScopeManifests(k8sManifests, scope)
 	}
 
 	// Registry components
 	var cache registry.Registry
 	var cacheWarmer registry.Warmer
+	var tagOrderer registry.TagOrderer
+	// Settings given in the instance config, which are applied as
+	// the config changes
+	settings := &daemon.Settings{
+		Credentials:        &registry.ConfiguredCredentials{},
+		PolicyDefaults:     sharedPolicyDefaults,
+		BasePolicyDefaults: policyDefaults,
+		ExcludeKinds:       exclude,
+		BaseExcludeKinds:   baseExclude,
+	}
+	if *registrySecretsDir != "" {
+		settings.Secrets = registry.SecretsDir(*registrySecretsDir)
+	}
 	{
 		// Cache
 		var memcacheClient registryMemcache.Client
@@ -220,6 +428,20 @@ func main() {
 		if err != nil {
 			logger.Log("err", err)
 		}
+		creds = creds.WithConfigured(settings.Credentials)
+		aliases, err := registry.ParseTagAliases(*registryTagAliases)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		if *registryTagOrderer != "" {
+			orderer, err := registry.NewTagOrderer(*registryTagOrderer, *tagOrdererTimeout)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			tagOrderer = registry.NewCachingTagOrderer(orderer, *tagOrdererCacheTTL)
+		}
 		cacheLogger := log.NewContext(logger).With("component", "cache")
 		cache = registry.NewRegistry(
 			registry.NewCacheClientFactory(creds, cacheLogger, memcacheClient, *registryCacheExpiry),
@@ -227,6 +449,7 @@ func main() {
 			*memcachedConnections,
 		)
 		cache = registry.NewInstrumentedRegistry(cache)
+		cache = registry.NewAliasingRegistry(cache, aliases)
 
 		// Remote
 		registryLogger := log.NewContext(logger).With("component", "registry")
@@ -245,6 +468,7 @@ func main() {
 			Reader:        memcacheClient,
 			Writer:        memcacheClient,
 			Burst:         *registryBurst,
+			Aliases:       aliases,
 		}
 	}
 
@@ -258,6 +482,7 @@ func main() {
 	notReadyDaemon := daemon.NewNotReadyDaemon(
 		version, k8s, gitRemoteConfig, errors.New("waiting to clone repo"))
 
+	notReadyDaemon.Settings = settings
 	daemonRef := daemon.NewRef(notReadyDaemon)
 
 	var eventWriter history.EventWriter
@@ -266,21 +491,53 @@ func main() {
 		if *upstreamURL != "" {
 			upstreamLogger := log.NewContext(logger).With("component", "upstream")
 			upstreamLogger.Log("URL", *upstreamURL)
-			upstream, err := daemonhttp.NewUpstream(
-				&http.Client{Timeout: 10 * time.Second},
-				fmt.Sprintf("fluxd/%v", version),
-				flux.Token(*token),
-				transport.NewUpstreamRouter(),
-				*upstreamURL,
-				&remote.ErrorLoggingPlatform{daemonRef, upstreamLogger},
-				upstreamLogger,
-			)
+			clientMetrics, err := fluxclient.NewMetrics(prometheus.DefaultRegisterer)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			upstreamToken := flux.Token(*token)
+			if *tokenFile != "" {
+				if upstreamToken, err = readToken(*tokenFile); err != nil {
+					logger.Log("err", err)
+					os.Exit(1)
+				}
+			}
+			var upstream *daemonhttp.Upstream
+			if *upstreamGRPC != "" {
+				upstreamLogger.Log("gRPC", *upstreamGRPC)
+				upstream, err = daemonhttp.NewGRPCUpstream(
+					&http.Client{Timeout: 10 * time.Second},
+					fmt.Sprintf("fluxd/%v", version),
+					upstreamToken,
+					transport.NewUpstreamRouter(),
+					*upstreamURL,
+					*upstreamGRPC,
+					&remote.ErrorLoggingPlatform{daemonRef, upstreamLogger},
+					clientMetrics,
+					upstreamLogger,
+				)
+			} else {
+				upstream, err = daemonhttp.NewUpstream(
+					&http.Client{Timeout: 10 * time.Second},
+					fmt.Sprintf("fluxd/%v", version),
+					upstreamToken,
+					transport.NewUpstreamRouter(),
+					*upstreamURL,
+					&remote.ErrorLoggingPlatform{daemonRef, upstreamLogger},
+					clientMetrics,
+					upstreamLogger,
+				)
+			}
 			if err != nil {
 				logger.Log("err", err)
 				os.Exit(1)
 			}
 			eventWriter = upstream
 			defer upstream.Close()
+			if *tokenFile != "" {
+				go watchToken(*tokenFile, upstreamToken, upstream.RefreshToken, upstreamLogger)
+			}
 		} else {
 			logger.Log("upstream", "no upstream URL given")
 		}
@@ -298,7 +555,7 @@ func main() {
 	go func() {
 		mux := http.NewServeMux()
 		mux.Handle("/metrics", promhttp.Handler())
-		handler := daemonhttp.NewHandler(daemonRef, daemonhttp.NewRouter())
+		handler := daemonhttp.NewHandler(daemonRef, daemonhttp.NewRouter(), jobReports)
 		mux.Handle("/api/flux/", http.StripPrefix("/api/flux", handler))
 		logger.Log("addr", *listenAddr)
 		errc <- http.ListenAndServe(*listenAddr, mux)
@@ -320,10 +577,12 @@ func main() {
 			KeyRing:         sshKeyRing,
 		}
 		gitConfig := git.Config{
-			SyncTag:   *gitSyncTag,
-			NotesRef:  *gitNotesRef,
-			UserName:  *gitUser,
-			UserEmail: *gitEmail,
+			SyncTag:        *gitSyncTag,
+			NotesRef:       *gitNotesRef,
+			UserName:       *gitUser,
+			UserEmail:      *gitEmail,
+			LFSInclude:     *gitLFSInclude,
+			ConflictPolicy: gitConflictPolicy,
 		}
 
 		for checkout == nil {
@@ -350,6 +609,28 @@ func main() {
 		}
 	}
 
+	if *runJob != "" {
+		worker := &daemon.Daemon{
+			V:         version,
+			Cluster:   k8s,
+			Manifests: k8sManifests,
+			Exclude:   exclude,
+			Layout:    layout,
+			Features:  features,
+			Registry:  cache,
+			Repo:      repo,
+			Checkout:  checkout,
+			Logger:    log.NewContext(logger).With("component", "worker"),
+			LoopVars:  &daemon.LoopVars{},
+		}
+		workerLogger := log.NewContext(logger).With("component", "worker", "jobID", *runJob)
+		if err := runJobAsWorker(worker, job.ID(*runJob), *runJobSpec, *runJobTokenFile, *runJobReportURL, workerLogger); err != nil {
+			workerLogger.Log("err", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	shutdown := make(chan struct{})
 	shutdownWg := &sync.WaitGroup{}
 
@@ -358,14 +639,39 @@ func main() {
 		jobs = job.NewQueue(shutdown, shutdownWg)
 	}
 
+	var syncObjective *remote.SyncObjective
+	if *syncFreshness > 0 {
+		if *syncFreshnessTarget <= 0 || *syncFreshnessTarget > 1 {
+			logger.Log("err", "--sync-objective-target must be more than 0 and no more than 1")
+			os.Exit(1)
+		}
+		syncObjective = &remote.SyncObjective{Freshness: *syncFreshness, Target: *syncFreshnessTarget}
+		logger.Log("sync-objective-freshness", *syncFreshness, "sync-objective-target", *syncFreshnessTarget)
+	}
+
 	daemon := &daemon.Daemon{
-		V:         version,
-		Cluster:   k8s,
-		Manifests: k8sManifests,
-		Registry:  cache,
-		Repo:      repo, Checkout: checkout,
+		V:          version,
+		Cluster:    k8s,
+		Manifests:  k8sManifests,
+		Exclude:    exclude,
+		Layout:     layout,
+		Features:   features,
+		Registry:   cache,
+		TagOrderer: tagOrderer,
+		Settings:   settings,
+		Repo:       repo, Checkout: checkout,
 		Jobs:           jobs,
-		JobStatusCache: &job.StatusCache{Size: 100},
+		JobExecutor:    executor,
+		JobStatusCache: &job.StatusCache{Size: 100, LogRetention: *jobLogRetention},
+		JobLogLimit:    *jobLogLimit,
+
+		SyncHealthWindow: *syncHealthWindow,
+		SyncObjective:    syncObjective,
+		Prune: fluxsync.Pruning{
+			Defaults:   sharedPolicyDefaults,
+			DryRun:     *syncPruneDryRun,
+			PathScoped: len(*gitInclude) > 0 || len(*gitExclude) > 0,
+		},
 
 		EventWriter: eventWriter,
 		Logger:      log.NewContext(logger).With("component", "daemon"), LoopVars: &daemon.LoopVars{
@@ -395,6 +701,38 @@ const (
 	versionCheckPeriod = 6 * time.Hour
 )
 
+func readToken(path string) (flux.Token, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading token from %s: %s", path, err)
+	}
+	return flux.Token(strings.TrimSpace(string(bytes))), nil
+}
+
+// How often to look for a new token in the token file
+const tokenPollInterval = time.Minute
+
+// watchToken looks for changes to the token in the file given, and
+// hands any new token to refresh. This is so that the token can be
+// rotated (e.g., by updating the Kubernetes secret it is mounted
+// from), without restarting.
+func watchToken(path string, current flux.Token, refresh func(flux.Token) error, logger log.Logger) {
+	for range time.Tick(tokenPollInterval) {
+		token, err := readToken(path)
+		if err != nil {
+			logger.Log("err", err)
+			continue
+		}
+		if token == current || token == "" {
+			continue
+		}
+		if err := refresh(token); err != nil {
+			logger.Log("err", err)
+		}
+		current = token
+	}
+}
+
 func checkForUpdates(clusterString string, gitString string, logger log.Logger) *checkpoint.Checker {
 	handleResponse := func(r *checkpoint.CheckResponse, err error) {
 		if err != nil {
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/daemon"
+	fluxclient "github.com/weaveworks/flux/http/client"
+	daemonhttp "github.com/weaveworks/flux/http/daemon"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
+)
+
+const (
+	jobExecutorInProcess  = "in-process"
+	jobExecutorKubernetes = "kubernetes"
+)
+
+// Flags that aren't passed on to workers, since they are about
+// running jobs elsewhere, or about connecting upstream (which only
+// the daemon proper does).
+var notForWorkers = map[string]bool{
+	"job-executor":               true,
+	"job-worker-image":           true,
+	"job-worker-service-account": true,
+	"job-worker-timeout":         true,
+	"job-report-url":             true,
+	"run-job":                    true,
+	"run-job-spec":               true,
+	"run-job-token-file":         true,
+	"run-job-report-url":         true,
+	"connect":                    true,
+	"token":                      true,
+	"token-file":                 true,
+}
+
+// workerArgs reconstructs the arguments this daemon was given, for
+// passing on to workers.
+func workerArgs(fs *pflag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *pflag.Flag) {
+		if notForWorkers[f.Name] {
+			return
+		}
+		value := f.Value.String()
+		// Slices print as "[a,b]", but are parsed as "a,b"
+		if strings.HasSuffix(f.Value.Type(), "Slice") {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+	return args
+}
+
+// How long a worker has to get its report to the daemon
+const workerReportTimeout = 30 * time.Second
+
+// runJobAsWorker runs a single job using the daemon given, then
+// reports how it went to the daemon that asked for it to be run. If
+// the report got through, the worker has done its job, even if the
+// job failed.
+func runJobAsWorker(d *daemon.Daemon, id job.ID, specFile, tokenFile, reportURL string, logger log.Logger) error {
+	specBytes, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return errors.Wrap(err, "reading job spec")
+	}
+	var spec update.Spec
+	if err := json.Unmarshal(specBytes, &spec); err != nil {
+		return errors.Wrap(err, "decoding job spec")
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return errors.Wrap(err, "reading worker token")
+	}
+
+	logger.Log("state", "in-progress")
+	status := job.Status{StatusString: job.StatusSucceeded}
+	result, err := d.Execute(context.Background(), id, spec, logger)
+	if err != nil {
+		logger.Log("state", "done", "success", "false", "err", err)
+		status = job.Status{StatusString: job.StatusFailed, Err: err.Error()}
+	} else {
+		logger.Log("state", "done", "success", "true", "revision", result.Revision)
+		status.Result = result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workerReportTimeout)
+	defer cancel()
+	c := fluxclient.New(http.DefaultClient, daemonhttp.NewRouter(), reportURL, flux.Token(strings.TrimSpace(string(token))))
+	if err := c.ReportJob(ctx, id, status); err != nil {
+		return errors.Wrap(err, "reporting job status")
+	}
+	return nil
+}
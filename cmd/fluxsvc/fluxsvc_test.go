@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	httpserver "github.com/weaveworks/flux/http/server"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/remote/rpc"
 	"github.com/weaveworks/flux/server"
 	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/service/instance"
@@ -131,9 +133,9 @@ func setup() {
 	}
 
 	// Server
-	apiServer := server.New(ver, instancer, instanceDB, messageBus, log.NewNopLogger())
+	apiServer := server.New(ver, instancer, instanceDB, messageBus, nil, log.NewNopLogger(), nil)
 	router = httpserver.NewServiceRouter()
-	handler := httpserver.NewHandler(apiServer, router, log.NewNopLogger())
+	handler := httpserver.NewHandler(apiServer, router, httpserver.DefaultAuthenticator{}, apiServer, nil, rpc.DefaultTimeouts, log.NewNopLogger(), nil)
 	ts = httptest.NewServer(handler)
 	apiClient = client.New(http.DefaultClient, router, ts.URL, "")
 }
@@ -147,7 +149,7 @@ func TestFluxsvc_ListServices(t *testing.T) {
 	defer teardown()
 
 	// Test ListServices
-	svcs, err := apiClient.ListServices("", "default")
+	svcs, err := apiClient.ListServices(context.Background(), "", "default")
 	if err != nil {
 		t.Error(err)
 	}
@@ -177,7 +179,7 @@ func TestFluxsvc_ListImages(t *testing.T) {
 	defer teardown()
 
 	// Test ListImages
-	imgs, err := apiClient.ListImages("", update.ServiceSpecAll)
+	imgs, err := apiClient.ListImages(context.Background(), "", update.ServiceSpecAll)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -189,7 +191,7 @@ func TestFluxsvc_ListImages(t *testing.T) {
 	}
 
 	// Test ListImages for specific service
-	imgs, err = apiClient.ListImages("", helloWorldSvc)
+	imgs, err = apiClient.ListImages(context.Background(), "", helloWorldSvc)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -222,7 +224,7 @@ func TestFluxsvc_Release(t *testing.T) {
 	}
 
 	// Test UpdateImages
-	r, err := apiClient.UpdateImages("", update.ReleaseSpec{
+	r, err := apiClient.UpdateImages(context.Background(), "", update.ReleaseSpec{
 		ImageSpec:    "alpine:latest",
 		Kind:         "execute",
 		ServiceSpecs: []update.ServiceSpec{helloWorldSvc},
@@ -235,7 +237,7 @@ func TestFluxsvc_Release(t *testing.T) {
 	}
 
 	// Test GetRelease
-	res, err := apiClient.JobStatus("", r)
+	res, err := apiClient.JobStatus(context.Background(), "", r)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -281,7 +283,7 @@ func TestFluxsvc_History(t *testing.T) {
 	}
 
 	// Test History
-	hist, err := apiClient.History("", helloWorldSvc, time.Now().UTC(), -1, time.Unix(0, 0))
+	hist, err := apiClient.History(context.Background(), "", helloWorldSvc, time.Now().UTC(), -1, time.Unix(0, 0))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -311,12 +313,65 @@ func TestFluxsvc_History(t *testing.T) {
 	}
 }
 
+func TestFluxsvc_WatchEvents(t *testing.T) {
+	setup()
+	defer teardown()
+
+	eventLogger, ok := apiClient.(interface {
+		LogEvent(service.InstanceID, history.Event) error
+	})
+	if !ok {
+		t.Fatal("API client does not implement LogEvent (maybe that method has moved)")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan history.Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- apiClient.WatchEvents(ctx, "", events)
+	}()
+
+	// We can't tell when the watch has been set up at the other end,
+	// so keep logging events until one comes through.
+	timeout := time.After(5 * time.Second)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for received := false; !received; {
+		select {
+		case <-tick.C:
+			err := eventLogger.LogEvent("", history.Event{
+				Type:       history.EventLock,
+				ServiceIDs: []flux.ServiceID{helloWorldSvc},
+				Message:    "default/helloworld locked.",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		case e := <-events:
+			if e.Type != history.EventLock {
+				t.Errorf("expected lock event, got %+v", e)
+			}
+			received = true
+		case err := <-errc:
+			t.Fatalf("watch ended before receiving an event: %v", err)
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestFluxsvc_Status(t *testing.T) {
 	setup()
 	defer teardown()
 
 	// Test Status
-	status, err := apiClient.Status("")
+	status, err := apiClient.Status(context.Background(), "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -349,7 +404,7 @@ func TestFluxsvc_Register(t *testing.T) {
 	setup()
 	defer teardown()
 
-	_, err := httpdaemon.NewUpstream(&http.Client{}, "fluxd/test", "", router, ts.URL, mockPlatform, log.NewNopLogger()) // For ping and for
+	_, err := httpdaemon.NewUpstream(&http.Client{}, "fluxd/test", "", router, ts.URL, mockPlatform, nil, log.NewNopLogger()) // For ping and for
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,372 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// A blue/green pair is two workloads with the same policy.BlueGreen,
+// naming the service that selects one or the other of them. The one
+// selected is live, and is never released to by automation; new
+// images go to the idle one instead, and once it's running them, the
+// service is switched over to it, in a commit of its own. The
+// workload that was live is then idle, and is brought up to date
+// with the next image poll.
+
+// blueGreenPair is what the manifests say about a blue/green pair.
+// If the pair isn't well-formed -- there aren't two workloads, or the
+// service selects neither or both -- Problem says why, and neither
+// workload is released to by automation.
+type blueGreenPair struct {
+	Service    flux.ServiceID
+	Live, Idle flux.ServiceID
+	Problem    error
+}
+
+// switches keeps, for each service of a blue/green pair, the job
+// that last released to the idle workload, so the switch can refer
+// to its commit; and the job switching the service, so that another
+// isn't queued before it has run and been synced. The zero value is
+// ready to use.
+type switches struct {
+	sync.Mutex
+	released map[flux.ServiceID]job.ID
+	pending  map[flux.ServiceID]pendingSwitch
+}
+
+type pendingSwitch struct {
+	job job.ID
+	to  flux.ServiceID
+}
+
+func (s *switches) releasedTo(services []flux.ServiceID, id job.ID) {
+	s.Lock()
+	defer s.Unlock()
+	if s.released == nil {
+		s.released = map[flux.ServiceID]job.ID{}
+	}
+	for _, service := range services {
+		s.released[service] = id
+	}
+}
+
+// blueGreenPairs finds the blue/green pairs in the manifests, and
+// gives the pair for each workload in one.
+func (d *Daemon) blueGreenPairs() (map[flux.ServiceID]*blueGreenPair, error) {
+	members, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.BlueGreen)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	topology, err := d.Manifests.ServiceTopology(d.Checkout.ManifestDir())
+	if err != nil {
+		return nil, err
+	}
+	selects := map[flux.ServiceID]map[flux.ServiceID]bool{}
+	for _, service := range topology {
+		selected := map[flux.ServiceID]bool{}
+		for _, w := range service.Workloads {
+			selected[flux.MakeServiceID(w.Namespace, w.Name)] = true
+		}
+		selects[service.ID] = selected
+	}
+
+	byService := map[flux.ServiceID][]flux.ServiceID{}
+	for id, policies := range members {
+		namespace, _ := id.Components()
+		name, _ := policies.Get(policy.BlueGreen)
+		service := flux.MakeServiceID(namespace, name)
+		byService[service] = append(byService[service], id)
+	}
+
+	pairs := map[flux.ServiceID]*blueGreenPair{}
+	for service, workloads := range byService {
+		flux.ServiceIDs(workloads).Sort()
+		pair := &blueGreenPair{Service: service}
+		selected, ok := selects[service]
+		switch {
+		case !ok:
+			pair.Problem = fmt.Errorf("service %s is not defined", service)
+		case len(workloads) != 2:
+			pair.Problem = fmt.Errorf("expected two workloads for service %s, found %d", service, len(workloads))
+		case selected[workloads[0]] == selected[workloads[1]]:
+			pair.Problem = fmt.Errorf("service %s must select exactly one of %s and %s", service, workloads[0], workloads[1])
+		case selected[workloads[0]]:
+			pair.Live, pair.Idle = workloads[0], workloads[1]
+		default:
+			pair.Live, pair.Idle = workloads[1], workloads[0]
+		}
+		for _, id := range workloads {
+			pairs[id] = pair
+		}
+	}
+	return pairs, nil
+}
+
+// holdForBlueGreen takes out of the changes given those for the live
+// workload of a blue/green pair, and gives back the rest to be
+// released now, along with the services whose idle workloads are
+// among them. If the idle workload of a pair is already running the
+// images held back from the live one, and has finished rolling them
+// out, a job is queued to switch the service over to it.
+func (d *Daemon) holdForBlueGreen(changes *update.Automated, pairs map[flux.ServiceID]*blueGreenPair, logger log.Logger) (*update.Automated, []flux.ServiceID) {
+	release := &update.Automated{}
+	held := map[flux.ServiceID][]update.Change{}
+	idleReleased := map[flux.ServiceID]bool{}
+	for _, c := range changes.Changes {
+		pair, ok := pairs[c.ServiceID]
+		switch {
+		case !ok:
+			release.Add(c.ServiceID, c.Container, c.ImageID)
+		case pair.Problem != nil:
+			logger.Log("service", c.ServiceID, "held", "automated release", "err", pair.Problem)
+		case c.ServiceID == pair.Live:
+			held[pair.Service] = append(held[pair.Service], c)
+		default:
+			release.Add(c.ServiceID, c.Container, c.ImageID)
+			idleReleased[pair.Service] = true
+		}
+	}
+
+	var releasedTo []flux.ServiceID
+	for service := range idleReleased {
+		releasedTo = append(releasedTo, service)
+	}
+	for service, changes := range held {
+		if idleReleased[service] {
+			// not there yet
+			continue
+		}
+		pair := pairs[changes[0].ServiceID]
+		ready, err := d.idleReady(pair, changes)
+		if err != nil {
+			logger.Log("service", service, "err", errors.Wrap(err, "checking idle workload"))
+			continue
+		}
+		if !ready {
+			continue
+		}
+		var images []flux.ImageID
+		for _, c := range changes {
+			images = append(images, c.ImageID)
+		}
+		d.queueSwitch(update.SwitchSpec{
+			Service: service,
+			From:    pair.Live,
+			To:      pair.Idle,
+			Images:  images,
+		}, logger)
+	}
+	return release, releasedTo
+}
+
+// idleReady says whether the idle workload of a pair is running the
+// images given in the changes (for the live workload), and has
+// finished rolling them out.
+func (d *Daemon) idleReady(pair *blueGreenPair, changes []update.Change) (bool, error) {
+	services, err := d.Cluster.SomeServices([]flux.ServiceID{pair.Idle})
+	if err != nil {
+		return false, err
+	}
+	if len(services) != 1 {
+		return false, nil
+	}
+	idle := services[0]
+	if idle.Status != cluster.StatusReady {
+		return false, nil
+	}
+	running := map[string]string{}
+	for _, c := range idle.ContainersOrNil() {
+		running[c.Name] = c.Image
+	}
+	for _, c := range changes {
+		if running[c.Container.Name] != c.ImageID.String() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// queueSwitch queues a job to switch a service from one of a
+// blue/green pair to the other, unless one is already doing so, or
+// has done so and is yet to be synced.
+func (d *Daemon) queueSwitch(spec update.SwitchSpec, logger log.Logger) {
+	d.switches.Lock()
+	defer d.switches.Unlock()
+	if p, ok := d.switches.pending[spec.Service]; ok {
+		status, ok := d.JobStatusCache.Status(p.job)
+		if ok && !status.StatusString.Terminal() {
+			return
+		}
+		if ok && status.StatusString == job.StatusSucceeded && p.to == spec.To {
+			return
+		}
+		delete(d.switches.pending, spec.Service)
+	}
+
+	if released, ok := d.switches.released[spec.Service]; ok {
+		if status, ok := d.JobStatusCache.Status(released); ok && status.StatusString == job.StatusSucceeded {
+			spec.Release = status.Result.Revision
+		}
+	}
+
+	id, err := d.UpdateManifests(context.Background(), update.Spec{
+		Type: update.Switch,
+		Spec: spec,
+	})
+	if err != nil {
+		logger.Log("service", spec.Service, "operation", "switch", "err", err)
+		return
+	}
+	if d.switches.pending == nil {
+		d.switches.pending = map[flux.ServiceID]pendingSwitch{}
+	}
+	d.switches.pending[spec.Service] = pendingSwitch{job: id, to: spec.To}
+	logger.Log("service", spec.Service, "switching", fmt.Sprintf("%s -> %s", spec.From, spec.To), "jobID", id)
+}
+
+// settled forgets the switches that have made it into the manifests
+// the daemon looks at, i.e., where the pair's live workload is the one
+// switched to.
+func (s *switches) settled(pairs map[flux.ServiceID]*blueGreenPair) {
+	s.Lock()
+	defer s.Unlock()
+	for _, pair := range pairs {
+		if p, ok := s.pending[pair.Service]; ok && p.to == pair.Live {
+			delete(s.pending, pair.Service)
+		}
+	}
+}
+
+// switchService commits the change to the service of a blue/green
+// pair that makes it select the other workload.
+func (d *Daemon) switchService(spec update.Spec, s update.SwitchSpec) DaemonJobFunc {
+	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		topology, err := d.Manifests.ServiceTopology(working.ManifestDir())
+		if err != nil {
+			return nil, err
+		}
+		var service *flux.ServiceTopology
+		for i := range topology {
+			if topology[i].ID == s.Service {
+				service = &topology[i]
+			}
+		}
+		if service == nil {
+			return nil, fmt.Errorf("service %s not found in repo", s.Service)
+		}
+		if s.From != "" {
+			selected := false
+			for _, w := range service.Workloads {
+				selected = selected || flux.MakeServiceID(w.Namespace, w.Name) == s.From
+			}
+			if !selected {
+				return nil, fmt.Errorf("service %s no longer selects %s", s.Service, s.From)
+			}
+		}
+
+		workloads, err := d.Manifests.FindDefinedServices(working.ManifestDir())
+		if err != nil {
+			return nil, err
+		}
+		switch paths := workloads[s.To]; len(paths) {
+		case 0:
+			return nil, cluster.ErrNoResourceFilesFoundForService
+		case 1:
+		default:
+			return nil, cluster.ErrMultipleResourceFilesFoundForService
+		}
+		workloadDef, err := ioutil.ReadFile(workloads[s.To][0])
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(working.ManifestDir(), service.File)
+		def, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		newDef, err := d.Manifests.SwitchSelector(def, workloadDef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "switching %s to %s", s.Service, s.To)
+		}
+		if err := d.Manifests.ValidateUpdate(def, newDef); err != nil {
+			return nil, errors.Wrapf(err, "switching %s to %s", s.Service, s.To)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, newDef, fi.Mode()); err != nil {
+			return nil, err
+		}
+		logger.Log("service", s.Service, "switched", fmt.Sprintf("%s -> %s", s.From, s.To), "file", service.File)
+
+		result := update.Result{}
+		for _, id := range []flux.ServiceID{s.Service, s.From, s.To} {
+			if id != "" {
+				result[id] = update.ServiceResult{Status: update.ReleaseStatusSuccess}
+			}
+		}
+		commitMsg := spec.Cause.Message
+		if commitMsg == "" {
+			commitMsg = fmt.Sprintf("Switch %s from %s to %s", s.Service, s.From, s.To)
+		}
+		if err := working.CommitAndPush(commitMsg, &git.Note{JobID: jobID, Spec: spec, Result: result}); err != nil {
+			d.askForSync()
+			return nil, err
+		}
+		revision, err := working.HeadRevision()
+		if err != nil {
+			return nil, err
+		}
+		return &history.CommitEventMetadata{
+			Revision: revision,
+			Spec:     &spec,
+			Result:   result,
+		}, nil
+	}
+}
+
+// switchEvent records a service of a blue/green pair being switched
+// over, with the commit that released to the workload it was
+// switched to, so the two can be seen as one release.
+func switchEvent(metadata history.CommitEventMetadata, s update.SwitchSpec, now time.Time) history.Event {
+	ids := []flux.ServiceID{s.Service, s.To}
+	if s.From != "" {
+		ids = append(ids, s.From)
+	}
+	return history.Event{
+		ServiceIDs: ids,
+		Type:       history.EventSwitch,
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   history.LogLevelInfo,
+		Metadata: &history.SwitchEventMetadata{
+			Revision: metadata.Revision,
+			Release:  s.Release,
+			Service:  s.Service,
+			From:     s.From,
+			To:       s.To,
+			Images:   s.Images,
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestHoldForBlueGreen(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	web := flux.MakeServiceID("default", "web")
+	blue := flux.MakeServiceID("default", "web-blue")
+	green := flux.MakeServiceID("default", "web-green")
+	other := flux.MakeServiceID("default", "helloworld")
+	pair := &blueGreenPair{Service: web, Live: blue, Idle: green}
+	pairs := map[flux.ServiceID]*blueGreenPair{blue: pair, green: pair}
+
+	image, _ := flux.ParseImageID("quay.io/weaveworks/web:2")
+	released := func(a *update.Automated) map[flux.ServiceID]bool {
+		ids := map[flux.ServiceID]bool{}
+		for _, c := range a.Changes {
+			ids[c.ServiceID] = true
+		}
+		return ids
+	}
+
+	greenStatus := "updating"
+	k8s.SomeServicesFunc = func([]flux.ServiceID) ([]cluster.Service, error) {
+		return []cluster.Service{{
+			ID:     green,
+			Status: greenStatus,
+			Containers: cluster.ContainersOrExcuse{
+				Containers: []cluster.Container{{Name: "web", Image: image.String()}},
+			},
+		}}, nil
+	}
+
+	// With a new image for both, only the idle workload (and
+	// anything not in a pair) is released to
+	changes := &update.Automated{}
+	changes.Add(blue, cluster.Container{Name: "web"}, image)
+	changes.Add(green, cluster.Container{Name: "web"}, image)
+	changes.Add(other, cluster.Container{Name: "greeter"}, image)
+	release, releasedTo := d.holdForBlueGreen(changes, pairs, log.NewNopLogger())
+	if ids := released(release); ids[blue] || !ids[green] || !ids[other] {
+		t.Errorf("expected the idle workload to be released to, and not the live one, got %v", ids)
+	}
+	if len(releasedTo) != 1 || releasedTo[0] != web {
+		t.Errorf("expected release to the idle workload of %s, got %v", web, releasedTo)
+	}
+
+	// Once the idle workload has the image, the live one is still
+	// held; and until the idle workload is ready, nothing is switched
+	changes = &update.Automated{}
+	changes.Add(blue, cluster.Container{Name: "web"}, image)
+	release, _ = d.holdForBlueGreen(changes, pairs, log.NewNopLogger())
+	if len(release.Changes) != 0 {
+		t.Errorf("expected the live workload to be held, got %v", release.Changes)
+	}
+	if _, ok := d.switches.pending[web]; ok {
+		t.Error("expected no switch before the idle workload is ready")
+	}
+
+	greenStatus = cluster.StatusReady
+	d.holdForBlueGreen(changes, pairs, log.NewNopLogger())
+	p, ok := d.switches.pending[web]
+	if !ok || p.to != green {
+		t.Fatalf("expected a switch to %s to be queued, got %+v", green, d.switches.pending)
+	}
+	// ... and only once
+	d.holdForBlueGreen(changes, pairs, log.NewNopLogger())
+	if d.switches.pending[web].job != p.job {
+		t.Error("expected the switch not to be queued again")
+	}
+
+	// Once the switch is in the manifests, it's forgotten
+	d.switches.settled(map[flux.ServiceID]*blueGreenPair{green: {Service: web, Live: green, Idle: blue}})
+	if _, ok := d.switches.pending[web]; ok {
+		t.Error("expected the switch to be forgotten once settled")
+	}
+
+	// A pair that isn't well-formed isn't released to at all
+	broken := &blueGreenPair{Service: web, Problem: errors.New("no service")}
+	changes = &update.Automated{}
+	changes.Add(green, cluster.Container{Name: "web"}, image)
+	release, _ = d.holdForBlueGreen(changes, map[flux.ServiceID]*blueGreenPair{green: broken}, log.NewNopLogger())
+	if len(release.Changes) != 0 {
+		t.Errorf("expected nothing released for a broken pair, got %v", release.Changes)
+	}
+}
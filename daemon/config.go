@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/registry"
+	"github.com/weaveworks/flux/service"
+)
+
+// Settings puts the daemon's part of the instance config into
+// effect, and remembers which version of it that was. It's shared by
+// the daemon and the NotReadyDaemon standing in for it, since none of
+// the settings depend on the repo.
+type Settings struct {
+	// Where the secrets for registry credentials are looked up; if
+	// nil, no credentials can be given in the instance config
+	Secrets     registry.SecretStore
+	Credentials *registry.ConfiguredCredentials
+	// The namespace defaults in use, and those given on the command
+	// line, which are used when the instance config gives none
+	PolicyDefaults     *policy.SharedDefaults
+	BasePolicyDefaults policy.NamespaceDefaults
+	// Likewise the kinds of resource left alone, and those given on
+	// the command line
+	ExcludeKinds     *cluster.SharedKindFilter
+	BaseExcludeKinds cluster.KindFilter
+
+	mu       sync.RWMutex
+	interval time.Duration
+	version  string
+	err      error
+}
+
+// apply puts the config given into effect, reporting whether the
+// sync interval or the kinds excluded have changed, either of which
+// calls for a sync. If any part of the config can't be
+// applied, none of it is, and the error is kept to be reported along
+// with the version that was last applied.
+func (s *Settings) apply(config service.DaemonConfig) (bool, error) {
+	if s == nil {
+		return false, errors.New("this daemon does not take config from the instance")
+	}
+	interval, auths, err := s.resolve(config)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
+		return false, err
+	}
+
+	if s.Credentials != nil {
+		s.Credentials.Set(auths)
+	}
+	if s.PolicyDefaults != nil {
+		if len(config.NamespacePolicies) > 0 {
+			s.PolicyDefaults.Set(config.NamespacePolicies)
+		} else {
+			s.PolicyDefaults.Set(s.BasePolicyDefaults)
+		}
+	}
+	changed := interval != s.interval
+	if s.ExcludeKinds != nil {
+		exclude := s.BaseExcludeKinds
+		if len(config.ExcludeKinds) > 0 {
+			exclude = cluster.KindFilter(config.ExcludeKinds)
+		}
+		if !reflect.DeepEqual(exclude, s.ExcludeKinds.Get()) {
+			s.ExcludeKinds.Set(exclude)
+			changed = true
+		}
+	}
+	s.interval = interval
+	s.version = config.Version
+	s.err = nil
+	return changed, nil
+}
+
+// resolve checks the config, and looks up the secret for each
+// registry.
+func (s *Settings) resolve(config service.DaemonConfig) (time.Duration, map[string]registry.Auth, error) {
+	var interval time.Duration
+	if config.SyncInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(config.SyncInterval); err != nil {
+			return 0, nil, errors.Wrap(err, "sync interval")
+		}
+	}
+	if err := config.NamespacePolicies.Validate(); err != nil {
+		return 0, nil, errors.Wrap(err, "namespace policies")
+	}
+
+	auths := map[string]registry.Auth{}
+	for _, reg := range config.Registries {
+		if s.Credentials == nil {
+			return 0, nil, errors.New("this daemon does not take registry credentials from the instance config")
+		}
+		if s.Secrets == nil {
+			return 0, nil, flux.UserConfigProblem{&flux.BaseError{
+				Help: `No secrets for registry credentials
+
+The instance config gives credentials for image registries, but the
+daemon has nowhere to look up their secrets. Run the daemon with
+--registry-secrets-dir, giving a directory (e.g., a mounted
+Kubernetes Secret) with a file for each secret named in the config.
+`,
+				Err: errors.Errorf("no secret store for registry %s", reg.Host),
+			}}
+		}
+		password, err := s.Secrets.Secret(reg.Secret)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "registry %s", reg.Host)
+		}
+		auths[reg.Host] = registry.Auth{Username: reg.Username, Password: password}
+	}
+	return interval, auths, nil
+}
+
+// syncInterval gives the sync interval from the instance config, or
+// zero if it doesn't give one.
+func (s *Settings) syncInterval() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interval
+}
+
+// applied gives the version of the config last applied, and the
+// error from applying any config given since.
+func (s *Settings) applied() (version string, err error) {
+	if s == nil {
+		return "", nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version, s.err
+}
+
+// ApplyConfig puts the daemon's part of the instance config into
+// effect. A change to the sync interval or the kinds excluded takes
+// effect from the next sync, which is done straight away.
+func (d *Daemon) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	changed, err := d.Settings.apply(config)
+	if err != nil {
+		return err
+	}
+	d.Logger.Log("config", config.Version)
+	if changed {
+		d.askForSync()
+	}
+	return nil
+}
+
+// gitPollInterval gives the interval between pulling from the repo
+// and syncing; that from the instance config if it gives one,
+// otherwise that given on the command line.
+func (d *Daemon) gitPollInterval() time.Duration {
+	if interval := d.Settings.syncInterval(); interval > 0 {
+		return interval
+	}
+	return d.GitPollInterval
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/registry"
+	"github.com/weaveworks/flux/service"
+)
+
+type secrets map[string]string
+
+func (s secrets) Secret(ref string) (string, error) {
+	if secret, ok := s[ref]; ok {
+		return secret, nil
+	}
+	return "", errors.New("no such secret: " + ref)
+}
+
+func TestSettingsApply(t *testing.T) {
+	base := policy.NamespaceDefaults{"dev": policy.Set{policy.Automated: "true"}}
+	s := &Settings{
+		Secrets:            secrets{"quay-password": "hunter2"},
+		Credentials:        &registry.ConfiguredCredentials{},
+		PolicyDefaults:     policy.NewSharedDefaults(base),
+		BasePolicyDefaults: base,
+		ExcludeKinds:       cluster.NewSharedKindFilter(cluster.KindFilter{"Secret"}),
+		BaseExcludeKinds:   cluster.KindFilter{"Secret"},
+	}
+
+	config := service.InstanceConfig{
+		SyncInterval:      "1m",
+		NamespacePolicies: policy.NamespaceDefaults{"staging": policy.Set{policy.Prune: "true"}},
+		Registries:        []service.RegistryConfig{{Host: "quay.io", Username: "robot", Secret: "quay-password"}},
+		ExcludeKinds:      []string{"ConfigMap"},
+	}.DaemonConfig()
+	changed, err := s.apply(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || s.syncInterval() != time.Minute {
+		t.Errorf("expected sync interval to change to a minute, got %s", s.syncInterval())
+	}
+	if !s.PolicyDefaults.Get()["staging"].Contains(policy.Prune) || s.PolicyDefaults.Get()["dev"] != nil {
+		t.Errorf("expected namespace policies from the config in place of the base, got %v", s.PolicyDefaults.Get())
+	}
+	if !s.ExcludeKinds.Excludes("ConfigMap") || s.ExcludeKinds.Excludes("Secret") {
+		t.Errorf("expected kinds excluded by the config in place of the base, got %v", s.ExcludeKinds.Get())
+	}
+	if version, err := s.applied(); version != config.Version || err != nil {
+		t.Errorf("expected version %q applied, got %q (error %v)", config.Version, version, err)
+	}
+
+	// A config that can't be applied leaves the last one in effect,
+	// and says why
+	bad := service.InstanceConfig{
+		Registries: []service.RegistryConfig{{Host: "gcr.io", Username: "_json_key", Secret: "missing"}},
+	}.DaemonConfig()
+	if _, err := s.apply(bad); err == nil {
+		t.Fatal("expected config with a missing secret not to apply")
+	}
+	if version, err := s.applied(); version != config.Version || err == nil {
+		t.Errorf("expected version %q still applied with an error, got %q (error %v)", config.Version, version, err)
+	}
+	if s.syncInterval() != time.Minute {
+		t.Errorf("expected sync interval to stay a minute, got %s", s.syncInterval())
+	}
+
+	// An empty config puts back what the daemon was given
+	changed, err = s.apply(service.DaemonConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || s.syncInterval() != 0 {
+		t.Errorf("expected sync interval to be unset, got %s", s.syncInterval())
+	}
+	if !s.PolicyDefaults.Get()["dev"].Contains(policy.Automated) {
+		t.Errorf("expected base namespace policies, got %v", s.PolicyDefaults.Get())
+	}
+	if !s.ExcludeKinds.Excludes("Secret") || s.ExcludeKinds.Excludes("ConfigMap") {
+		t.Errorf("expected base kinds excluded, got %v", s.ExcludeKinds.Get())
+	}
+	if version, err := s.applied(); version != "" || err != nil {
+		t.Errorf("expected no version and no error, got %q (error %v)", version, err)
+	}
+}
@@ -2,8 +2,13 @@ package daemon
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -19,44 +24,114 @@ import (
 	"github.com/weaveworks/flux/registry"
 	"github.com/weaveworks/flux/release"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/resource"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
 var ErrUnknownJob = fmt.Errorf("unknown job")
 
+// How long to wait for a job's status to change before answering
+// anyway; this needs to be comfortably inside the timeouts of the
+// transports between here and the client.
+const jobWaitTimeout = 5 * time.Second
+
 // Combine these things to form Devasta^Wan implementation of
 // Platform.
 type Daemon struct {
 	V              string
 	Cluster        cluster.Cluster
 	Manifests      cluster.Manifests
+	Exclude        *cluster.SharedKindFilter // kinds of resource the cluster has been told to leave alone
+	Layout         flux.RepoLayout           // where manifests for new resources go
+	Features       flux.Features
 	Registry       registry.Registry
+	TagOrderer     registry.TagOrderer // ranks tags for automation; if nil, the most recent image is taken to be the latest
+	Settings       *Settings           // put into effect from the instance config
 	Repo           git.Repo
 	Checkout       *git.Checkout
 	Jobs           *job.Queue
+	JobExecutor    job.Executor // where jobs are run; if nil, the daemon runs them itself
 	JobStatusCache *job.StatusCache
+	JobLogLimit    int // bytes of output to keep for each job; zero means none
 	EventWriter    history.EventWriter
 	Logger         log.Logger
+	// How far back to report sync health, and the objective for how
+	// fresh the cluster is kept, if there is one
+	SyncHealthWindow time.Duration
+	SyncObjective    *remote.SyncObjective
+	// Which resources that have gone from the repo are deleted from
+	// the cluster when syncing
+	Prune fluxsync.Pruning
 	// bookkeeping
 	*LoopVars
+	exports         exports
+	faults          faults
+	syncHealth      syncHealth
+	syncedResources syncedResources
+	deferrals       deferrals
+	expiredLocks    expiredLocks
+	switches        switches
+	syncReports     syncReports
 }
 
 // Invariant.
 var _ remote.Platform = &Daemon{}
 
-func (d *Daemon) Version() (string, error) {
+func (d *Daemon) Version(ctx context.Context) (string, error) {
 	return d.V, nil
 }
 
-func (d *Daemon) Ping() error {
+func (d *Daemon) Ping(ctx context.Context) error {
 	return d.Cluster.Ping()
 }
 
-func (d *Daemon) Export() ([]byte, error) {
+func (d *Daemon) Export(ctx context.Context) ([]byte, error) {
 	return d.Cluster.Export()
 }
 
-func (d *Daemon) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+func (d *Daemon) ExportChunk(ctx context.Context, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	return d.exports.chunk(d.Cluster, req)
+}
+
+// ExportAt gives the manifests as they were in the repo at the
+// revision given, so that what was deployed at some point can be
+// looked at without cloning the repo. They're read from a working
+// clone, so as not to disturb the checkout everything else uses.
+func (d *Daemon) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	working, err := d.Checkout.WorkingClone()
+	if err != nil {
+		return nil, err
+	}
+	defer working.Clean()
+	if _, err := working.ResetTo(ref); err != nil {
+		return nil, err
+	}
+	resources, err := d.Manifests.LoadManifests(working.ManifestDir())
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading manifests at %s", ref)
+	}
+
+	var ids []string
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	// In the same order as the cluster's export, so the two can be
+	// compared
+	sort.Sort(resourceIDsByNamespace(ids))
+	var buf bytes.Buffer
+	for _, id := range ids {
+		def := resources[id].Bytes()
+		buf.WriteString("---\n")
+		buf.Write(def)
+		if !bytes.HasSuffix(def, []byte("\n")) {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Daemon) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
 	var res []flux.ServiceStatus
 	services, err := d.Cluster.AllServices(namespace)
 	if err != nil {
@@ -77,15 +152,32 @@ func (d *Daemon) ListServices(namespace string) ([]flux.ServiceStatus, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "checking service policies")
 	}
+	pausedServices, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Paused)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking service policies")
+	}
 
+	failures := d.syncedResources.serviceFailures()
+
+	now := time.Now()
 	for _, service := range services {
+		pausedUntil, _ := pausedServices[service.ID].PausedUntil(now)
+		lock := lockedServices[service.ID]
+		lockedUntil, _ := lock.LockedUntil()
+		failure := failures[service.ID]
 		res = append(res, flux.ServiceStatus{
-			ID:         service.ID,
-			Containers: containers2containers(service.ContainersOrNil()),
-			Status:     service.Status,
-			Automated:  automatedServices.Contains(service.ID),
-			Locked:     lockedServices.Contains(service.ID),
-			Ignore:     ignoredServices.Contains(service.ID),
+			ID:                service.ID,
+			Containers:        containers2containers(service.ContainersOrNil()),
+			Status:            service.Status,
+			Automated:         automatedServices.Contains(service.ID),
+			Locked:            lockedServices.Contains(service.ID),
+			LockedUser:        lock[policy.LockedUser],
+			LockedMsg:         lock[policy.LockedMsg],
+			LockedUntil:       lockedUntil,
+			Ignore:            ignoredServices.Contains(service.ID),
+			PausedUntil:       pausedUntil,
+			SyncError:         failure.err,
+			SyncErrorRevision: failure.failedAt,
 		})
 	}
 
@@ -93,7 +185,7 @@ func (d *Daemon) ListServices(namespace string) ([]flux.ServiceStatus, error) {
 }
 
 // List the images available for set of services
-func (d *Daemon) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (d *Daemon) ListImages(ctx context.Context, spec update.ServiceSpec) ([]flux.ImageStatus, error) {
 	var services []cluster.Service
 	var err error
 	if spec == update.ServiceSpecAll {
@@ -106,6 +198,21 @@ func (d *Daemon) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error)
 		services, err = d.Cluster.SomeServices([]flux.ServiceID{id})
 	}
 
+	// Collecting the images is the expensive bit, so don't start on
+	// it if the caller has already given up.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Containers the services ignore (e.g., sidecars) are left out
+	d.Checkout.RLock()
+	ignoring, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.IgnoreContainers)
+	d.Checkout.RUnlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "checking service policies")
+	}
+	services = update.WithoutIgnoredContainers(services, ignoring)
+
 	images, err := update.CollectAvailableImages(d.Registry, services)
 	if err != nil {
 		return nil, errors.Wrap(err, "getting images for services")
@@ -128,28 +235,50 @@ func (d *Daemon) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error)
 // run), leave the revision field empty.
 type DaemonJobFunc func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error)
 
-func (d *Daemon) queueJob(do DaemonJobFunc) job.ID {
+func (d *Daemon) queueJob(spec update.Spec) job.ID {
+	return d.queueJobThen(spec, nil)
+}
+
+// queueJobThen queues a job like queueJob, and if the job succeeds,
+// calls then with its result, e.g., to record an event that should
+// only be recorded once the change has been made.
+func (d *Daemon) queueJobThen(spec update.Spec, then func(history.CommitEventMetadata) error) job.ID {
 	id := job.ID(guid.New())
 	d.Jobs.Enqueue(&job.Job{
 		ID: id,
 		Do: func(logger log.Logger) error {
+			if spec.Cause.RequestID != "" {
+				logger = log.NewContext(logger).With("request_id", spec.Cause.RequestID)
+			}
 			started := time.Now().UTC()
 			d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusRunning})
-			// make a working clone so we don't mess with files we
-			// will be reading from elsewhere
-			working, err := d.Checkout.WorkingClone()
-			if err != nil {
+			// Keep what the job logs, so it can be looked at
+			// afterwards; particularly if the job fails.
+			var jobLog *job.LogBuffer
+			if d.JobLogLimit > 0 {
+				jobLog = job.NewLogBuffer(d.JobLogLimit)
+				d.JobStatusCache.SetLog(id, jobLog)
+				logger = jobLog.Logger(logger)
+			}
+			failed := func(err error) error {
+				if jobLog != nil {
+					log.NewLogfmtLogger(jobLog).Log("err", err)
+				}
 				d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusFailed, Err: err.Error()})
 				return err
 			}
-			defer working.Clean()
-			metadata, err := do(id, working, logger)
+			metadata, err := d.executor().Execute(context.Background(), id, spec, logger)
 			if err != nil {
-				d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusFailed, Err: err.Error()})
-				return err
+				if c, ok := git.ConflictFrom(err); ok {
+					d.logConflict(c, &spec, history.LogLevelError, started, logger)
+				}
+				return failed(err)
 			}
-			d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusSucceeded, Result: *metadata})
+			d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusSucceeded, Result: metadata})
 			logger.Log("revision", metadata.Revision)
+			if metadata.Conflict != nil {
+				d.logConflict(*metadata.Conflict, &spec, history.LogLevelWarn, started, logger)
+			}
 			if metadata.Revision != "" {
 				var serviceIDs []flux.ServiceID
 				for id, result := range metadata.Result {
@@ -157,14 +286,29 @@ func (d *Daemon) queueJob(do DaemonJobFunc) job.ID {
 						serviceIDs = append(serviceIDs, id)
 					}
 				}
-				return d.LogEvent(history.Event{
+				if err := d.LogEvent(history.Event{
 					ServiceIDs: serviceIDs,
 					Type:       history.EventCommit,
 					StartedAt:  started,
 					EndedAt:    started,
 					LogLevel:   history.LogLevelInfo,
-					Metadata:   metadata,
-				})
+					Metadata:   &metadata,
+				}); err != nil {
+					return err
+				}
+				if len(metadata.PolicyChanges) > 0 {
+					if err := d.LogEvent(policyChangeEvent(metadata, spec.Cause, started)); err != nil {
+						return err
+					}
+				}
+				if s, ok := spec.Spec.(update.SwitchSpec); ok {
+					if err := d.LogEvent(switchEvent(metadata, s, started)); err != nil {
+						return err
+					}
+				}
+			}
+			if then != nil {
+				return then(metadata)
 			}
 			return nil
 		},
@@ -173,86 +317,313 @@ func (d *Daemon) queueJob(do DaemonJobFunc) job.ID {
 	return id
 }
 
+// logConflict records that a job's commit clashed with changes pushed
+// upstream, and what was done about it. Failing to record it doesn't
+// fail the job, since the job is done with either way.
+func (d *Daemon) logConflict(c flux.GitConflict, spec *update.Spec, level string, started time.Time, logger log.Logger) {
+	if err := d.LogEvent(history.Event{
+		Type:      history.EventConflict,
+		StartedAt: started,
+		EndedAt:   time.Now().UTC(),
+		LogLevel:  level,
+		Metadata: &history.ConflictEventMetadata{
+			Conflict: c,
+			Spec:     spec,
+		},
+	}); err != nil {
+		logger.Log("operation", "log conflict event", "err", err)
+	}
+}
+
 // Apply the desired changes to the config files
-func (d *Daemon) UpdateManifests(spec update.Spec) (job.ID, error) {
+func (d *Daemon) UpdateManifests(ctx context.Context, spec update.Spec) (job.ID, error) {
 	var id job.ID
 	if spec.Type == "" {
 		return id, errors.New("no type in update spec")
 	}
+	if err := d.checkNotDiverged(); err != nil {
+		return id, err
+	}
+	switch s := spec.Spec.(type) {
+	case update.ReleaseSpec:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	case update.BatchSpec:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	case update.AddSpec:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	case policy.Updates:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	case update.SwitchSpec:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	case update.RollbackSpec:
+		if err := s.Validate(); err != nil {
+			return id, err
+		}
+	}
+	if _, err := d.jobFunc(spec); err != nil {
+		return id, err
+	}
+	// Record which request this came from (and who made it, if
+	// that's not already said), so it can be traced through the
+	// commit note to the events for the update
+	md := remote.MetadataFrom(ctx)
+	if spec.Cause.RequestID == "" {
+		spec.Cause.RequestID = md.RequestID
+	}
+	if spec.Cause.User == "" {
+		spec.Cause.User = md.User
+	}
+	return d.queueJob(spec), nil
+}
+
+func (d *Daemon) executor() job.Executor {
+	if d.JobExecutor != nil {
+		return d.JobExecutor
+	}
+	return d
+}
+
+// Execute makes Daemon a job.Executor, which does the work of a job
+// in a working clone of its own repo. This is how jobs are run
+// unless another executor is given; and how a worker (which is just
+// a daemon that runs a single job) runs the job it was given.
+func (d *Daemon) Execute(ctx context.Context, id job.ID, spec update.Spec, logger log.Logger) (history.CommitEventMetadata, error) {
+	do, err := d.jobFunc(spec)
+	if err != nil {
+		return history.CommitEventMetadata{}, err
+	}
+	// It may have diverged since the job was queued
+	if err := d.checkNotDiverged(); err != nil {
+		return history.CommitEventMetadata{}, err
+	}
+	// make a working clone so we don't mess with files we
+	// will be reading from elsewhere
+	working, err := d.Checkout.WorkingClone()
+	if err != nil {
+		return history.CommitEventMetadata{}, err
+	}
+	defer working.Clean()
+	metadata, err := do(id, working, logger)
+	if err != nil {
+		return history.CommitEventMetadata{}, err
+	}
+	// If the commit clashed with changes pushed upstream, the result
+	// says what was done about it; and if the commit was dropped,
+	// there's no revision to report.
+	if c := working.Conflict(); c != nil {
+		logger.Log("conflict", strings.Join(c.Files, ","), "upstream", c.Upstream, "policy", c.Policy, "pushed", c.Pushed)
+		metadata.Conflict = c
+		if !c.Pushed {
+			metadata.Revision = ""
+		}
+	}
+	return *metadata, nil
+}
+
+func (d *Daemon) jobFunc(spec update.Spec) (DaemonJobFunc, error) {
 	switch s := spec.Spec.(type) {
 	case release.Changes:
-		return d.queueJob(d.release(spec, s)), nil
+		return d.release(spec, s), nil
 	case policy.Updates:
-		return d.queueJob(d.updatePolicy(spec, s)), nil
+		return d.updatePolicy(spec, s), nil
+	case update.BatchSpec:
+		return d.batch(spec, s), nil
+	case update.AddSpec:
+		return d.add(spec, s), nil
+	case update.SwitchSpec:
+		return d.switchService(spec, s), nil
+	case update.RollbackSpec:
+		return d.rollback(spec, s), nil
 	default:
-		return id, fmt.Errorf(`unknown update type "%s"`, spec.Type)
+		return nil, fmt.Errorf(`unknown update type "%s"`, spec.Type)
 	}
 }
 
 func (d *Daemon) updatePolicy(spec update.Spec, updates policy.Updates) DaemonJobFunc {
+	updates = updates.WithLockDetails(spec.Cause.User, spec.Cause.Message)
 	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
-		// For each update
-		var serviceIDs []flux.ServiceID
 		metadata := &history.CommitEventMetadata{
 			Spec:   &spec,
 			Result: update.Result{},
 		}
 
+		dryRun := spec.Type == update.PolicyDryRun
+		changes, err := d.applyPolicyUpdates(working, updates, metadata.Result, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		if !anythingChanged(metadata.Result) || dryRun {
+			return metadata, nil
+		}
+
+		if err := working.CommitAndPush(policyCommitMessage(updates, spec.Cause), &git.Note{JobID: jobID, Spec: spec}); err != nil {
+			// On the chance pushing failed because it was not
+			// possible to fast-forward, ask for a sync so the
+			// next attempt is more likely to succeed.
+			d.askForSync()
+			return nil, err
+		}
 		// A shortcut to make things more responsive: if anything
 		// was (probably) set to automated, we will ask for an
 		// automation run straight ASAP.
-		var anythingAutomated bool
+		if anythingAutomated(updates) {
+			d.askForImagePoll()
+		}
 
-		for serviceID, u := range updates {
-			if policy.Set(u.Add).Contains(policy.Automated) {
-				anythingAutomated = true
+		metadata.PolicyChanges = changes
+		metadata.Revision, err = working.HeadRevision()
+		if err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}
+}
+
+// applyPolicyUpdates changes the manifests in the working clone
+// according to the updates, and records what happened to each
+// service in result. Unless it's a dry run, it returns how the
+// policies of each service that was updated changed.
+func (d *Daemon) applyPolicyUpdates(working *git.Checkout, updates policy.Updates, result update.Result, dryRun bool) ([]history.PolicyChange, error) {
+	var changes []history.PolicyChange
+	// For a dry run, we make each change, record the diff, and
+	// throw the change away; so each service's diff shows only its
+	// own change.
+	for serviceID, u := range updates {
+		// find the service manifest
+		err := cluster.UpdatePolicyManifest(d.Manifests, working.ManifestDir(), string(serviceID), func(def []byte) ([]byte, error) {
+			newDef, err := d.Manifests.UpdatePolicies(def, u)
+			if err == nil {
+				if err = d.Manifests.ValidateUpdate(def, newDef); err != nil {
+					err = errors.Wrapf(err, "not updating policies for %s, since the change failed validation", serviceID)
+				}
 			}
-			// find the service manifest
-			err := cluster.UpdateManifest(d.Manifests, working.ManifestDir(), string(serviceID), func(def []byte) ([]byte, error) {
-				newDef, err := d.Manifests.UpdatePolicies(def, u)
-				if err != nil {
-					metadata.Result[serviceID] = update.ServiceResult{
-						Status: update.ReleaseStatusFailed,
-						Error:  err.Error(),
-					}
-					return nil, err
+			if err != nil {
+				result[serviceID] = update.ServiceResult{
+					Status: update.ReleaseStatusFailed,
+					Error:  err.Error(),
 				}
-				if string(newDef) == string(def) {
-					metadata.Result[serviceID] = update.ServiceResult{
-						Status: update.ReleaseStatusSkipped,
-					}
-				} else {
-					serviceIDs = append(serviceIDs, serviceID)
-					metadata.Result[serviceID] = update.ServiceResult{
-						Status: update.ReleaseStatusSuccess,
-					}
+				return nil, err
+			}
+			if string(newDef) == string(def) {
+				result[serviceID] = update.ServiceResult{
+					Status: update.ReleaseStatusSkipped,
 				}
 				return newDef, nil
-			})
-			switch err {
-			case cluster.ErrNoResourceFilesFoundForService, cluster.ErrMultipleResourceFilesFoundForService:
-				metadata.Result[serviceID] = update.ServiceResult{
-					Status: update.ReleaseStatusFailed,
-					Error:  err.Error(),
+			}
+			if !dryRun {
+				change := history.PolicyChange{ServiceID: serviceID}
+				if change.Before, err = d.Manifests.PoliciesIn(def); err != nil {
+					return nil, errors.Wrapf(err, "reading policies of %s", serviceID)
 				}
-			case nil:
-				// continue
-			default:
+				if change.After, err = d.Manifests.PoliciesIn(newDef); err != nil {
+					return nil, errors.Wrapf(err, "reading policies of %s", serviceID)
+				}
+				changes = append(changes, change)
+			}
+			result[serviceID] = update.ServiceResult{
+				Status: update.ReleaseStatusSuccess,
+			}
+			return newDef, nil
+		})
+		switch err {
+		case cluster.ErrNoResourceFilesFoundForService, cluster.ErrMultipleResourceFilesFoundForService:
+			result[serviceID] = update.ServiceResult{
+				Status: update.ReleaseStatusFailed,
+				Error:  err.Error(),
+			}
+		case nil:
+			// continue
+		default:
+			return nil, err
+		}
+		if dryRun && result[serviceID].Status == update.ReleaseStatusSuccess {
+			serviceResult := result[serviceID]
+			if serviceResult.Diff, err = working.Diff(); err != nil {
 				return nil, err
 			}
+			result[serviceID] = serviceResult
+			if err := working.Discard(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// batch applies each step of a batch update to the working clone in
+// turn, and commits the lot only if every step succeeded. Since the
+// working clone is thrown away after the job, bailing out on failure
+// leaves the repo as it was.
+func (d *Daemon) batch(spec update.Spec, steps update.BatchSpec) DaemonJobFunc {
+	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		metadata := &history.CommitEventMetadata{
+			Spec:   &spec,
+			Result: update.Result{},
+		}
+
+		var messages []string
+		var automated bool
+		for i, step := range steps {
+			stepResult := update.Result{}
+			switch s := step.Spec.(type) {
+			case policy.Updates:
+				user := step.Cause.User
+				if user == "" {
+					user = spec.Cause.User
+				}
+				s = s.WithLockDetails(user, step.Cause.Message)
+				changes, err := d.applyPolicyUpdates(working, s, stepResult, false)
+				if err != nil {
+					return nil, errors.Wrapf(err, "step %d (%s)", i+1, step.Type)
+				}
+				metadata.PolicyChanges = append(metadata.PolicyChanges, changes...)
+				automated = automated || anythingAutomated(s)
+				messages = append(messages, policyCommitMessage(s, step.Cause))
+			case release.Changes:
+				rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+				var err error
+				if stepResult, err = release.Release(rc, s, log.NewContext(logger).With("step", i+1)); err != nil {
+					return nil, errors.Wrapf(err, "step %d (%s)", i+1, step.Type)
+				}
+				message := step.Cause.Message
+				if message == "" {
+					message = s.CommitMessage()
+				}
+				messages = append(messages, message)
+			default:
+				return nil, fmt.Errorf(`step %d: unknown update type "%s"`, i+1, step.Type)
+			}
+			metadata.Steps = append(metadata.Steps, stepResult)
+			if msg := stepResult.Error(); msg != "" {
+				return nil, fmt.Errorf("step %d (%s): %s", i+1, step.Type, msg)
+			}
+			for serviceID, result := range stepResult {
+				metadata.Result[serviceID] = result
+			}
 		}
-		if len(serviceIDs) == 0 {
+		if !anythingChanged(metadata.Result) {
 			return metadata, nil
 		}
 
-		if err := working.CommitAndPush(policyCommitMessage(updates, spec.Cause), &git.Note{JobID: jobID, Spec: spec}); err != nil {
-			// On the chance pushing failed because it was not
-			// possible to fast-forward, ask for a sync so the
-			// next attempt is more likely to succeed.
+		commitMsg := spec.Cause.Message
+		if commitMsg == "" {
+			commitMsg = strings.Join(messages, "\n\n")
+		}
+		if err := working.CommitAndPush(commitMsg, &git.Note{JobID: jobID, Spec: spec, Result: metadata.Result}); err != nil {
 			d.askForSync()
 			return nil, err
 		}
-		if anythingAutomated {
+		if automated {
 			d.askForImagePoll()
 		}
 
@@ -265,6 +636,124 @@ func (d *Daemon) updatePolicy(spec update.Spec, updates policy.Updates) DaemonJo
 	}
 }
 
+// add writes the manifests for new resources into the working clone,
+// each in the file the repo layout gives for it, and commits them.
+// Resources that are already defined in the repo are refused rather
+// than overwritten; changing those is what releases and policy
+// updates are for.
+func (d *Daemon) add(spec update.Spec, a update.AddSpec) DaemonJobFunc {
+	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		resources, err := d.Manifests.ParseManifests([]byte(a.Definition))
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing resources to add")
+		}
+		if len(resources) == 0 {
+			return nil, errors.New("no resources given to add")
+		}
+		existing, err := d.Manifests.LoadManifests(working.ManifestDir())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading manifests from repo")
+		}
+
+		var ids []string
+		for id := range resources {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		// More than one resource may go in the same file, e.g., if
+		// the layout puts everything for a service together
+		files := map[string][]string{}
+		var paths []string
+		result := update.Result{}
+		for _, id := range ids {
+			if res, ok := existing[id]; ok {
+				return nil, fmt.Errorf("%s is already defined, in %s", id, res.Source())
+			}
+			kind, namespace, name := resource.SplitID(id)
+			path, err := d.Layout.PathFor(kind, namespace, name)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := files[path]; !ok {
+				paths = append(paths, path)
+			}
+			files[path] = append(files[path], id)
+			result[flux.MakeServiceID(namespace, name)] = update.ServiceResult{
+				Status: update.ReleaseStatusSuccess,
+			}
+		}
+
+		for _, path := range paths {
+			fullPath := filepath.Join(working.ManifestDir(), path)
+			if _, err := os.Stat(fullPath); err == nil {
+				return nil, fmt.Errorf("the layout gives %s as the file for %s, but it already exists", path, strings.Join(files[path], ", "))
+			}
+			var buf bytes.Buffer
+			for i, id := range files[path] {
+				if i > 0 {
+					buf.WriteString("---\n")
+				}
+				buf.Write(bytes.TrimSpace(resources[id].Bytes()))
+				buf.WriteString("\n")
+			}
+			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+				return nil, err
+			}
+			logger.Log("add", strings.Join(files[path], ", "), "file", path)
+		}
+		if err := working.Track(paths...); err != nil {
+			return nil, errors.Wrap(err, "adding new files to git")
+		}
+
+		commitMsg := spec.Cause.Message
+		if commitMsg == "" {
+			commitMsg = "Add " + strings.Join(ids, ", ")
+		}
+		if err := working.CommitAndPush(commitMsg, &git.Note{JobID: jobID, Spec: spec, Result: result}); err != nil {
+			d.askForSync()
+			return nil, err
+		}
+		revision, err := working.HeadRevision()
+		if err != nil {
+			return nil, err
+		}
+		return &history.CommitEventMetadata{
+			Revision: revision,
+			Spec:     &spec,
+			Result:   result,
+		}, nil
+	}
+}
+
+// resourceIDsByNamespace sorts resource IDs by namespace, then kind,
+// then name; a namespace itself goes before the things in it.
+type resourceIDsByNamespace []string
+
+func (ids resourceIDsByNamespace) Len() int      { return len(ids) }
+func (ids resourceIDsByNamespace) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+func (ids resourceIDsByNamespace) Less(i, j int) bool {
+	key := func(id string) (string, string, string) {
+		kind, namespace, name := resource.SplitID(id)
+		if kind == "Namespace" && namespace == "" {
+			return name, "", ""
+		}
+		return namespace, kind, name
+	}
+	nsi, kindi, namei := key(ids[i])
+	nsj, kindj, namej := key(ids[j])
+	switch {
+	case nsi != nsj:
+		return nsi < nsj
+	case kindi != kindj:
+		return kindi < kindj
+	}
+	return namei < namej
+}
+
 func (d *Daemon) release(spec update.Spec, c release.Changes) DaemonJobFunc {
 	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
 		rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
@@ -299,18 +788,38 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) DaemonJobFunc {
 	}
 }
 
+// PlanRelease works out what a release would do, in a working clone
+// of its own, and answers straight away rather than queueing a job.
+// Since nothing is committed, it doesn't need to wait its turn.
+func (d *Daemon) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	if err := d.checkNotDiverged(); err != nil {
+		return nil, err
+	}
+	spec.Kind = update.ReleaseKindPlan
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	working, err := d.Checkout.WorkingClone()
+	if err != nil {
+		return nil, err
+	}
+	defer working.Clean()
+	rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+	return release.Plan(rc, spec, d.Logger)
+}
+
 // Tell the daemon to synchronise the cluster with the manifests in
 // the git repo. This has an error return value because upstream there
 // may be comms difficulties or other sources of problems; here, we
 // always succeed because it's just bookkeeping.
-func (d *Daemon) SyncNotify() error {
+func (d *Daemon) SyncNotify(ctx context.Context) error {
 	d.askForSync()
 	return nil
 }
 
 // Ask the daemon how far it's got committing things; in particular, is the job
 // queued? running? committed? If it is done, the commit ref is returned.
-func (d *Daemon) JobStatus(jobID job.ID) (job.Status, error) {
+func (d *Daemon) JobStatus(ctx context.Context, jobID job.ID) (job.Status, error) {
 	// Is the job queued, running, or recently finished?
 	status, ok := d.JobStatusCache.Status(jobID)
 	if ok {
@@ -344,16 +853,106 @@ func (d *Daemon) JobStatus(jobID job.ID) (job.Status, error) {
 	return job.Status{}, ErrUnknownJob
 }
 
+// WaitJobStatus answers with the status of a job, once it is
+// different to the status given, or when it's waited long enough.
+func (d *Daemon) WaitJobStatus(ctx context.Context, req job.WaitRequest) (job.Status, error) {
+	timeout := time.After(jobWaitTimeout)
+	for {
+		changed := d.JobStatusCache.Changed()
+		status, err := d.JobStatus(ctx, req.ID)
+		if err != nil || status.StatusString != req.Last || status.StatusString.Terminal() {
+			return status, err
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return status, nil
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
+
+// JobLog returns the output captured while running a job, if the job
+// was run recently enough that it's still kept.
+func (d *Daemon) JobLog(ctx context.Context, jobID job.ID) (job.Log, error) {
+	if l, ok := d.JobStatusCache.Log(jobID); ok {
+		return l, nil
+	}
+	return job.Log{}, ErrUnknownJob
+}
+
+func (d *Daemon) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	config := flux.ClusterConfig{
+		ExcludeKinds: []string(d.Exclude.Get()),
+		Features:     d.Features.Names(),
+	}
+	var err error
+	config.ConfigVersion, err = d.Settings.applied()
+	if err != nil {
+		config.ConfigError = err.Error()
+	}
+	return config, nil
+}
+
+// EvaluateImage answers the question "if this image were pushed,
+// would automation release it, and where?", using the policies in the
+// repo, the services running in the cluster, and the images already
+// in the registry. It picks images and respects automation windows
+// the same way pollForNewImages does.
+func (d *Daemon) EvaluateImage(ctx context.Context, image flux.ImageID) (update.Result, error) {
+	services, err := d.Cluster.AllServices("")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting services from cluster")
+	}
+	automated, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Automated)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting automated services")
+	}
+	locked, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Locked)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting locked services")
+	}
+	paused, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Paused)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting paused services")
+	}
+	repo, err := flux.ParseImageID(image.Repository())
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing repository %s", image.Repository())
+	}
+	available, err := d.Registry.GetRepository(repo)
+	if err != nil {
+		// Not an error if missing; the image would be the only one
+		if _, ok := err.(*flux.Missing); !ok {
+			return nil, errors.Wrapf(err, "fetching image metadata for %s", image.Repository())
+		}
+	}
+	now := time.Now().UTC()
+	images := update.ImageMap{image.Repository(): available}
+	return update.EvaluateImage(image, services, images, automated, locked, paused.PausedAt(now), d.latestImage, now), nil
+}
+
+// ServiceTopology reports the services defined in the repo, and
+// the workloads each of them selects.
+func (d *Daemon) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	topology, err := d.Manifests.ServiceTopology(d.Checkout.ManifestDir())
+	if err != nil {
+		return nil, errors.Wrap(err, "finding services in repo")
+	}
+	return topology, nil
+}
+
 // Ask the daemon how far it's got applying things; in particular, is it
 // past the supplied release? Return the list of commits between where
 // we have applied and the ref given, inclusive. E.g., if you send HEAD,
 // you'll get all the commits yet to be applied. If you send a hash
 // and it's applied _past_ it, you'll get an empty list.
-func (d *Daemon) SyncStatus(commitRef string) ([]string, error) {
+func (d *Daemon) SyncStatus(ctx context.Context, commitRef string) ([]string, error) {
 	return d.Checkout.RevisionsBetween(d.Checkout.SyncTag, commitRef)
 }
 
-func (d *Daemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+func (d *Daemon) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
 	publicSSHKey, err := d.Cluster.PublicSSHKey(regenerate)
 	if err != nil {
 		return flux.GitConfig{}, err
@@ -361,9 +960,32 @@ func (d *Daemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	return flux.GitConfig{
 		Remote:       d.Repo.GitRemoteConfig,
 		PublicSSHKey: publicSSHKey,
+		Diverged:     d.Checkout.Diverged(),
+		Layout:       d.Layout,
 	}, nil
 }
 
+// ResetGitToRemote is how a user says that the branch at the remote
+// diverging was meant to happen; the daemon starts again from the
+// branch as it is now, and syncs it.
+func (d *Daemon) ResetGitToRemote(ctx context.Context) (string, error) {
+	rev, err := d.Checkout.ResetToRemote()
+	if err != nil {
+		return "", err
+	}
+	d.askForSync()
+	return rev, nil
+}
+
+// checkNotDiverged stops anything being committed on top of a branch
+// that's since been rewritten at the remote.
+func (d *Daemon) checkNotDiverged() error {
+	if diverged := d.Checkout.Diverged(); diverged != nil {
+		return git.DivergedError(d.Repo.URL, *diverged)
+	}
+	return nil
+}
+
 // Non-remote.Platform methods
 
 func (d *Daemon) LogEvent(ev history.Event) error {
@@ -371,10 +993,44 @@ func (d *Daemon) LogEvent(ev history.Event) error {
 		d.Logger.Log("event", ev, "logupstream", "false")
 		return nil
 	}
+	if len(ev.ServiceIDs) > 0 && ev.NotifyChannels == nil {
+		channels, err := d.notifyChannels()
+		if err != nil {
+			// Not worth losing the event over; it'll just go to the
+			// usual place
+			d.Logger.Log("event", ev, "err", errors.Wrap(err, "finding notification channels"))
+		}
+		for _, id := range ev.ServiceIDs {
+			if channel, ok := channels[id]; ok {
+				if ev.NotifyChannels == nil {
+					ev.NotifyChannels = map[flux.ServiceID]string{}
+				}
+				ev.NotifyChannels[id] = channel
+			}
+		}
+	}
 	d.Logger.Log("event", ev, "logupstream", "true")
 	return d.EventWriter.LogEvent(ev)
 }
 
+// notifyChannels finds the services annotated with a channel for
+// their notifications.
+func (d *Daemon) notifyChannels() (map[flux.ServiceID]string, error) {
+	d.Checkout.RLock()
+	defer d.Checkout.RUnlock()
+	services, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.NotifyChannel)
+	if err != nil {
+		return nil, err
+	}
+	channels := map[flux.ServiceID]string{}
+	for id, policies := range services {
+		if channel, ok := policies.Get(policy.NotifyChannel); ok && channel != "" {
+			channels[id] = channel
+		}
+	}
+	return channels, nil
+}
+
 // vvv helpers vvv
 
 func containers2containers(cs []cluster.Container) []flux.Container {
@@ -431,6 +1087,49 @@ func policyEvents(us policy.Updates, now time.Time) map[string]history.Event {
 	return eventsByType
 }
 
+// policyChangeEvent makes an event recording the policy changes in
+// a commit, for auditing.
+func policyChangeEvent(metadata history.CommitEventMetadata, cause update.Cause, now time.Time) history.Event {
+	var serviceIDs []flux.ServiceID
+	for _, c := range metadata.PolicyChanges {
+		serviceIDs = append(serviceIDs, c.ServiceID)
+	}
+	return history.Event{
+		ServiceIDs: serviceIDs,
+		Type:       history.EventPolicyChange,
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   history.LogLevelInfo,
+		Metadata: &history.PolicyChangeEventMetadata{
+			Revision: metadata.Revision,
+			Cause:    cause,
+			Changes:  metadata.PolicyChanges,
+		},
+	}
+}
+
+// anythingChanged says whether any service was updated, i.e., whether
+// there's something to commit.
+func anythingChanged(result update.Result) bool {
+	for _, r := range result {
+		if r.Status == update.ReleaseStatusSuccess {
+			return true
+		}
+	}
+	return false
+}
+
+// anythingAutomated says whether the updates (probably) set a service
+// to automated.
+func anythingAutomated(updates policy.Updates) bool {
+	for _, u := range updates {
+		if policy.Set(u.Add).Contains(policy.Automated) {
+			return true
+		}
+	}
+	return false
+}
+
 func policyCommitMessage(us policy.Updates, cause update.Cause) string {
 	// shortcut, since we want roughly the same information
 	events := policyEvents(us, time.Now())
@@ -439,6 +1138,9 @@ func policyCommitMessage(us policy.Updates, cause update.Cause) string {
 	switch {
 	case cause.Message != "":
 		fmt.Fprintf(commitMsg, "%s\n\n", cause.Message)
+	case len(events) == 0:
+		// e.g., only tag filters or per-container policies changed
+		fmt.Fprintf(commitMsg, "Updated service policies\n")
 	case len(events) > 1:
 		fmt.Fprintf(commitMsg, "Updated service policies\n\n")
 	default:
@@ -460,6 +1162,8 @@ func policyEventTypes(u policy.Update) []string {
 			types[history.EventAutomate] = struct{}{}
 		case policy.Locked:
 			types[history.EventLock] = struct{}{}
+		case policy.Paused:
+			types[history.EventPause] = struct{}{}
 		}
 	}
 
@@ -469,6 +1173,8 @@ func policyEventTypes(u policy.Update) []string {
 			types[history.EventDeautomate] = struct{}{}
 		case policy.Locked:
 			types[history.EventUnlock] = struct{}{}
+		case policy.Paused:
+			types[history.EventUnpause] = struct{}{}
 		}
 	}
 	var result []string
@@ -2,9 +2,13 @@ package daemon
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -47,7 +51,7 @@ var (
 func TestDaemon_Ping(t *testing.T) {
 	d, clean, _, _ := mockDaemon(t)
 	defer clean()
-	if d.Ping() != nil {
+	if d.Ping(context.Background()) != nil {
 		t.Fatal("Cluster did not return valid nil ping")
 	}
 }
@@ -57,7 +61,7 @@ func TestDaemon_Version(t *testing.T) {
 	d, clean, _, _ := mockDaemon(t)
 	defer clean()
 
-	v, err := d.Version()
+	v, err := d.Version(context.Background())
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -71,7 +75,7 @@ func TestDaemon_Export(t *testing.T) {
 	d, clean, _, _ := mockDaemon(t)
 	defer clean()
 
-	bytes, err := d.Export()
+	bytes, err := d.Export(context.Background())
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -86,7 +90,7 @@ func TestDaemon_ListServices(t *testing.T) {
 	defer clean()
 
 	// No namespace
-	s, err := d.ListServices("")
+	s, err := d.ListServices(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -95,7 +99,7 @@ func TestDaemon_ListServices(t *testing.T) {
 	}
 
 	// Just namespace
-	s, err = d.ListServices(ns)
+	s, err = d.ListServices(context.Background(), ns)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -104,7 +108,7 @@ func TestDaemon_ListServices(t *testing.T) {
 	}
 
 	// Invalid NS
-	s, err = d.ListServices(invalidNS)
+	s, err = d.ListServices(context.Background(), invalidNS)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -120,7 +124,7 @@ func TestDaemon_ListImages(t *testing.T) {
 
 	// List all images for services
 	ss := update.ServiceSpec(update.ServiceSpecAll)
-	is, err := d.ListImages(ss)
+	is, err := d.ListImages(context.Background(), ss)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -131,7 +135,7 @@ func TestDaemon_ListImages(t *testing.T) {
 
 	// List images for specific service
 	ss = update.ServiceSpec(svc)
-	is, err = d.ListImages(ss)
+	is, err = d.ListImages(context.Background(), ss)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
@@ -158,7 +162,7 @@ func TestDaemon_SyncNotify(t *testing.T) {
 		return nil
 	}
 
-	d.SyncNotify()
+	d.SyncNotify(context.Background())
 	w.Eventually(func() bool {
 		syncMu.Lock()
 		defer syncMu.Unlock()
@@ -201,7 +205,7 @@ func TestDaemon_Release(t *testing.T) {
 	id := updateImage(d, t)
 
 	// Check that job is queued
-	stat, err := d.JobStatus(id)
+	stat, err := d.JobStatus(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	} else if stat.Err != "" {
@@ -240,7 +244,7 @@ func TestDaemon_Release(t *testing.T) {
 // When I update a policy, I expect it to add to the queue
 // When I update a policy, it should add an annotation to the manifest
 func TestDaemon_PolicyUpdate(t *testing.T) {
-	d, clean, _, _ := mockDaemon(t)
+	d, clean, _, events := mockDaemon(t)
 	defer clean()
 	w := newWait(t)
 
@@ -248,7 +252,7 @@ func TestDaemon_PolicyUpdate(t *testing.T) {
 	id := updatePolicy(t, d)
 
 	// Wait for job to succeed
-	w.ForJobSucceeded(d, id)
+	stat := w.ForJobSucceeded(d, id)
 
 	// Wait and check for new annotation
 	w.Eventually(func() bool {
@@ -260,6 +264,174 @@ func TestDaemon_PolicyUpdate(t *testing.T) {
 		d.Checkout.Unlock()
 		return len(m["Deployment "+svc].Policy()) > 0
 	}, "Waiting for new annotation")
+
+	// The change is recorded, for auditing
+	var change *history.PolicyChangeEventMetadata
+	w.Eventually(func() bool {
+		es, _ := events.AllEvents(time.Time{}, -1, time.Time{})
+		for _, e := range es {
+			if e.Type == history.EventPolicyChange {
+				change = e.Metadata.(*history.PolicyChangeEventMetadata)
+				return true
+			}
+		}
+		return false
+	}, "Waiting for policy change event")
+	if change.Revision != stat.Result.Revision {
+		t.Errorf("expected policy change to be in revision %s, got %s", stat.Result.Revision, change.Revision)
+	}
+	if len(change.Changes) != 1 || change.Changes[0].ServiceID != svc {
+		t.Fatalf("expected a policy change for %s, got %+v", svc, change.Changes)
+	}
+	if c := change.Changes[0]; c.Before.Contains(policy.Locked) || !c.After.Contains(policy.Locked) {
+		t.Errorf("expected %s to have been locked, got %+v", svc, c)
+	}
+}
+
+// When I submit a batch, all the steps should be made in one commit,
+// with a result for each step
+func TestDaemon_Batch(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	id := updateManifest(t, d, update.Spec{
+		Type: update.Batch,
+		Spec: update.BatchSpec{
+			{
+				Type: update.Policy,
+				Spec: policy.Updates{
+					svc: {Add: policy.Set{policy.Locked: "true"}},
+				},
+			},
+			{
+				Type: update.Images,
+				Spec: update.ReleaseSpec{
+					Kind:         update.ReleaseKindExecute,
+					ServiceSpecs: []update.ServiceSpec{update.ServiceSpecAll},
+					ImageSpec:    newHelloImage,
+				},
+			},
+		},
+	})
+
+	stat := w.ForJobSucceeded(d, id)
+	if stat.Result.Revision == "" {
+		t.Fatal("expected the batch to have been committed")
+	}
+	if len(stat.Result.Steps) != 2 {
+		t.Fatalf("expected a result for each of two steps, got %d", len(stat.Result.Steps))
+	}
+	if s := stat.Result.Steps[0][flux.ServiceID(svc)].Status; s != update.ReleaseStatusSuccess {
+		t.Errorf("expected policy step to succeed, got %q", s)
+	}
+}
+
+// A batch with a step that can't be part of a batch should be refused
+// outright
+func TestDaemon_BatchInvalid(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+
+	_, err := d.UpdateManifests(context.Background(), update.Spec{
+		Type: update.Batch,
+		Spec: update.BatchSpec{
+			{
+				Type: update.PolicyDryRun,
+				Spec: policy.Updates{svc: {Add: policy.Set{policy.Locked: "true"}}},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a batch including a dry run")
+	}
+}
+
+// When I add a new resource, its manifest should be committed to the
+// file the layout gives for it
+func TestDaemon_Add(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	d.Layout = flux.RepoLayout{Directory: "{{.Namespace}}"}
+	w := newWait(t)
+
+	id := updateManifest(t, d, update.Spec{
+		Type: update.Add,
+		Spec: update.AddSpec{Definition: `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: goodbyeworld
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: goodbyeworld
+        image: quay.io/weaveworks/helloworld:master-a000001
+`},
+	})
+
+	stat := w.ForJobSucceeded(d, id)
+	if stat.Result.Revision == "" {
+		t.Fatal("expected the new manifest to have been committed")
+	}
+	if s := stat.Result.Result[flux.ServiceID("default/goodbyeworld")].Status; s != update.ReleaseStatusSuccess {
+		t.Errorf("expected goodbyeworld to have been added, got %q", s)
+	}
+}
+
+// Adding a resource that's already defined should fail, rather than
+// overwrite it
+func TestDaemon_AddExisting(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	id := updateManifest(t, d, update.Spec{
+		Type: update.Add,
+		Spec: update.AddSpec{Definition: testfiles.Files["helloworld-deploy.yaml"]},
+	})
+	w.Eventually(func() bool {
+		stat, err := d.JobStatus(context.Background(), id)
+		return err == nil && stat.StatusString == job.StatusFailed
+	}, "Waiting for job to fail")
+}
+
+// When I roll back a service after releasing to it, its manifest should
+// have the image it had before the release
+func TestDaemon_Rollback(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	w.ForJobSucceeded(d, updateImage(d, t))
+
+	id := updateManifest(t, d, update.Spec{
+		Type: update.Rollback,
+		Spec: update.RollbackSpec{
+			ServiceSpecs: []update.ServiceSpec{svc},
+			Kind:         update.ReleaseKindExecute,
+		},
+	})
+	stat := w.ForJobSucceeded(d, id)
+	if stat.Result.Revision == "" {
+		t.Fatal("expected the rollback to have been committed")
+	}
+	result := stat.Result.Result[flux.ServiceID(svc)]
+	if result.Status != update.ReleaseStatusSuccess || len(result.PerContainer) != 1 {
+		t.Fatalf("expected %s to have been rolled back, got %+v", svc, result)
+	}
+	if target := result.PerContainer[0].Target.String(); target != currentHelloImage {
+		t.Errorf("expected %s to go back to %s, got %s", svc, currentHelloImage, target)
+	}
+
+	w.Eventually(func() bool {
+		def, err := ioutil.ReadFile(filepath.Join(d.Checkout.ManifestDir(), "helloworld-deploy.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Contains(string(def), currentHelloImage) && !strings.Contains(string(def), newHelloImage)
+	}, "Waiting for rolled back manifest")
 }
 
 // When I call sync status, it should return a commit showing the sync
@@ -371,7 +543,10 @@ func mockDaemon(t *testing.T) (*Daemon, func(), *cluster.Mock, history.EventRead
 		}
 		k8s.SyncFunc = func(def cluster.SyncDef) error { return nil }
 		k8s.UpdatePoliciesFunc = (&kubernetes.Manifests{}).UpdatePolicies
+		k8s.PoliciesInFunc = (&kubernetes.Manifests{}).PoliciesIn
+		k8s.ValidateUpdateFunc = (&kubernetes.Manifests{}).ValidateUpdate
 		k8s.UpdateDefinitionFunc = (&kubernetes.Manifests{}).UpdateDefinition
+		k8s.CheckPullPolicyFunc = (&kubernetes.Manifests{}).CheckPullPolicy
 	}
 
 	var imageRegistry registry.Registry
@@ -450,7 +625,7 @@ func (w *wait) ForJobSucceeded(d *Daemon, jobID job.ID) job.Status {
 	var stat job.Status
 	var err error
 	w.Eventually(func() bool {
-		stat, err = d.JobStatus(jobID)
+		stat, err = d.JobStatus(context.Background(), jobID)
 		return err == nil && stat.StatusString == job.StatusSucceeded
 	}, "Waiting for job to succeed")
 	return stat
@@ -460,7 +635,7 @@ func (w *wait) ForSyncStatus(d *Daemon, rev string, expectedNumCommits int) []st
 	var revs []string
 	var err error
 	w.Eventually(func() bool {
-		revs, err = d.SyncStatus(rev)
+		revs, err = d.SyncStatus(context.Background(), rev)
 		return err == nil && len(revs) == expectedNumCommits
 	}, fmt.Sprintf("Waiting for sync status to have %d commits", expectedNumCommits))
 	return revs
@@ -487,6 +662,29 @@ func updateImage(d *Daemon, t *testing.T) job.ID {
 	})
 }
 
+func TestResourceIDsByNamespace(t *testing.T) {
+	ids := []string{
+		"Service dev/helloworld",
+		"Deployment default/helloworld",
+		"Namespace dev",
+		"Deployment dev/helloworld",
+		"Deployment dev/apple",
+		"Service default/helloworld",
+	}
+	sort.Sort(resourceIDsByNamespace(ids))
+	expected := []string{
+		"Deployment default/helloworld",
+		"Service default/helloworld",
+		"Namespace dev",
+		"Deployment dev/apple",
+		"Deployment dev/helloworld",
+		"Service dev/helloworld",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %q, got %q", expected, ids)
+	}
+}
+
 func updatePolicy(t *testing.T, d *Daemon) job.ID {
 	return updateManifest(t, d, update.Spec{
 		Type: update.Policy,
@@ -500,7 +698,7 @@ func updatePolicy(t *testing.T, d *Daemon) job.ID {
 	})
 }
 func updateManifest(t *testing.T, d *Daemon, spec update.Spec) job.ID {
-	id, err := d.UpdateManifests(spec)
+	id, err := d.UpdateManifests(context.Background(), spec)
 	if err != nil {
 		t.Fatalf("Error: %s", err.Error())
 	}
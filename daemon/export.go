@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/guid"
+	"github.com/weaveworks/flux/remote"
+)
+
+// How long an export is kept after its last chunk was asked for. If
+// the client goes away part way through, this is how long it'll be
+// before the file is cleaned up.
+const exportTimeout = 5 * time.Minute
+
+var ErrUnknownExport = errors.New("unknown export; it may have timed out")
+
+// exports keeps track of exports that are being read a chunk at a
+// time. Each export is written to a temporary file when it's started,
+// so it doesn't have to be held in memory, or done again for every
+// chunk. The zero value is ready to use.
+type exports struct {
+	sync.Mutex
+	files map[string]*exportFile
+}
+
+type exportFile struct {
+	*os.File
+	lastUsed time.Time
+}
+
+func (e *exports) chunk(c cluster.Cluster, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	e.Lock()
+	defer e.Unlock()
+	e.expire(time.Now())
+
+	var f *exportFile
+	if req.ID == "" {
+		var err error
+		if f, err = exportToFile(c); err != nil {
+			return remote.ExportChunk{}, err
+		}
+		req.ID = guid.New()
+		if e.files == nil {
+			e.files = map[string]*exportFile{}
+		}
+		e.files[req.ID] = f
+	} else if f = e.files[req.ID]; f == nil {
+		return remote.ExportChunk{}, ErrUnknownExport
+	}
+	f.lastUsed = time.Now()
+
+	buf := make([]byte, remote.ExportChunkSize)
+	n, err := f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		e.remove(req.ID)
+		return remote.ExportChunk{}, errors.Wrap(err, "reading export")
+	}
+	chunk := remote.ExportChunk{
+		ID:   req.ID,
+		Data: buf[:n],
+		More: err == nil,
+	}
+	if !chunk.More {
+		e.remove(req.ID)
+	}
+	return chunk, nil
+}
+
+func exportToFile(c cluster.Cluster) (*exportFile, error) {
+	f, err := ioutil.TempFile("", "flux-export")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating file for export")
+	}
+	// Unlink it straight away; it stays around while it's open
+	os.Remove(f.Name())
+	if err := c.ExportTo(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &exportFile{File: f}, nil
+}
+
+// expire removes any exports that haven't been used for a while. It
+// must be called with the lock held.
+func (e *exports) expire(now time.Time) {
+	for id, f := range e.files {
+		if now.Sub(f.lastUsed) > exportTimeout {
+			e.remove(id)
+		}
+	}
+}
+
+func (e *exports) remove(id string) {
+	if f, ok := e.files[id]; ok {
+		f.Close()
+		delete(e.files, id)
+	}
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/remote"
+)
+
+// simulatedFault is the error given in place of the real operation,
+// while a fault is injected.
+type simulatedFault struct {
+	kind remote.FaultKind
+}
+
+func (f simulatedFault) Error() string {
+	switch f.kind {
+	case remote.FaultGit:
+		return "simulated fault: git repo unreachable"
+	case remote.FaultRegistry:
+		return "simulated fault: registry responded with 500 Internal Server Error"
+	case remote.FaultApply:
+		return "simulated fault: applying manifests to the cluster failed"
+	}
+	return fmt.Sprintf("simulated fault: %s", f.kind)
+}
+
+// faults keeps track of the faults injected, and of which parts of
+// the daemon's regular work are failing (really or otherwise), so
+// that a failure is reported when it starts rather than every time
+// it recurs. The zero value is ready to use.
+type faults struct {
+	sync.Mutex
+	until   map[remote.FaultKind]time.Time
+	failing map[remote.FaultKind]bool
+}
+
+func (f *faults) inject(kind remote.FaultKind, d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	if f.until == nil {
+		f.until = map[remote.FaultKind]time.Time{}
+	}
+	if d == 0 {
+		delete(f.until, kind)
+		return
+	}
+	f.until[kind] = time.Now().Add(d)
+}
+
+// check returns an error if a fault of the kind given is in effect.
+func (f *faults) check(kind remote.FaultKind) error {
+	f.Lock()
+	defer f.Unlock()
+	if until, ok := f.until[kind]; ok {
+		if time.Now().Before(until) {
+			return simulatedFault{kind}
+		}
+		delete(f.until, kind)
+	}
+	return nil
+}
+
+// setFailing records whether the kind of operation given is failing,
+// and returns true if that's a change.
+func (f *faults) setFailing(kind remote.FaultKind, failing bool) bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.failing == nil {
+		f.failing = map[remote.FaultKind]bool{}
+	}
+	changed := f.failing[kind] != failing
+	f.failing[kind] = failing
+	return changed
+}
+
+// InjectFault makes the operations of the kind given fail for a
+// while, as though the thing they rely on were broken, so that
+// alerting can be tested.
+func (d *Daemon) InjectFault(ctx context.Context, spec remote.FaultSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	duration, _ := spec.Duration()
+	d.faults.inject(spec.Kind, duration)
+	d.Logger.Log("fault", spec.Kind, "for", duration)
+	return nil
+}
+
+// reportFailure logs an event for a failure of the kind given, if it
+// wasn't already failing.
+func (d *Daemon) reportFailure(kind remote.FaultKind, err error) {
+	if !d.faults.setFailing(kind, true) {
+		return
+	}
+	_, simulated := err.(simulatedFault)
+	now := time.Now().UTC()
+	if err := d.LogEvent(history.Event{
+		Type:      history.EventFailure,
+		StartedAt: now,
+		EndedAt:   now,
+		LogLevel:  history.LogLevelError,
+		Metadata: &history.FailureEventMetadata{
+			Component: string(kind),
+			Error:     err.Error(),
+			Simulated: simulated,
+		},
+	}); err != nil {
+		d.Logger.Log("err", err)
+	}
+}
+
+// reportSuccess notes that operations of the kind given are working,
+// so that the next failure gets reported.
+func (d *Daemon) reportSuccess(kind remote.FaultKind) {
+	if d.faults.setFailing(kind, false) {
+		d.Logger.Log("recovered", kind)
+	}
+}
@@ -1,19 +1,29 @@
 package daemon
 
 import (
-	"strings"
+	"context"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/update"
 )
 
 func (d *Daemon) pollForNewImages(logger log.Logger) {
 	logger.Log("msg", "polling images")
 
+	// This goes first, so a simulated failure is reported whether or
+	// not there's anything to look for
+	if err := d.faults.check(remote.FaultRegistry); err != nil {
+		logger.Log("error", err)
+		d.reportFailure(remote.FaultRegistry, err)
+		return
+	}
+
 	candidateServices, err := d.unlockedAutomatedServices()
 	if err != nil {
 		logger.Log("error", errors.Wrap(err, "getting unlocked automated services"))
@@ -29,17 +39,25 @@ func (d *Daemon) pollForNewImages(logger log.Logger) {
 		logger.Log("error", errors.Wrap(err, "checking services for new images"))
 		return
 	}
+	services = update.WithoutIgnoredContainers(services, candidateServices)
 	// Check the latest available image(s) for each service
 	imageMap, err := update.CollectAvailableImages(d.Registry, services)
 	if err != nil {
-		logger.Log("error", errors.Wrap(err, "fetching image updates"))
+		err = errors.Wrap(err, "fetching image updates")
+		logger.Log("error", err)
+		d.reportFailure(remote.FaultRegistry, err)
 		return
 	}
+	d.reportSuccess(remote.FaultRegistry)
 
 	changes := &update.Automated{}
 	for _, service := range services {
 		for _, container := range service.ContainersOrNil() {
 			logger := log.NewContext(logger).With("service", service.ID, "container", container.Name, "currentimage", container.Image)
+			if !candidateServices[service.ID].ContainerAutomated(container.Name) {
+				logger.Log("msg", "container not automated, or locked")
+				continue
+			}
 
 			currentImageID, err := flux.ParseImageID(container.Image)
 			if err != nil {
@@ -47,26 +65,44 @@ func (d *Daemon) pollForNewImages(logger log.Logger) {
 				continue
 			}
 
-			pattern := getTagPattern(candidateServices, service.ID, container.Name)
+			pattern := update.TagPattern(candidateServices, service.ID, container.Name)
 			repo := currentImageID.Repository()
 			logger.Log("repo", repo, "pattern", pattern)
 
-			if latest := imageMap.LatestImage(repo, pattern); latest != nil && latest.ID != currentImageID {
+			latest, err := d.latestImage(imageMap, repo, pattern)
+			if err != nil {
+				logger.Log("error", err)
+				continue
+			}
+			if latest != nil && latest.ID != currentImageID {
 				changes.Add(service.ID, container, latest.ID)
 				logger.Log("msg", "added image to changes", "newimage", latest.ID)
 			}
 		}
 	}
 
-	d.UpdateManifests(update.Spec{Type: update.Auto, Spec: changes})
+	changes = d.holdForWindows(changes, candidateServices, time.Now().UTC(), logger)
+	pairs, err := d.blueGreenPairs()
+	if err != nil {
+		logger.Log("error", errors.Wrap(err, "finding blue/green pairs"))
+		return
+	}
+	d.switches.settled(pairs)
+	changes, releasedTo := d.holdForBlueGreen(changes, pairs, logger)
+	id, err := d.UpdateManifests(context.Background(), update.Spec{Type: update.Auto, Spec: changes})
+	if err == nil {
+		d.switches.releasedTo(releasedTo, id)
+	}
 }
 
-func getTagPattern(services policy.ServiceMap, service flux.ServiceID, container string) string {
-	policies := services[service]
-	if pattern, ok := policies.Get(policy.Policy("tag." + container)); ok {
-		return strings.TrimPrefix(pattern, "glob:")
+// latestImage picks the image automation would release from those
+// available; if an orderer is configured, it is asked which tag
+// should win.
+func (d *Daemon) latestImage(images update.ImageMap, repo string, pattern policy.Pattern) (*flux.Image, error) {
+	if d.TagOrderer == nil {
+		return images.LatestImage(repo, pattern), nil
 	}
-	return "*"
+	return images.LatestImageOrdered(d.TagOrderer, repo, pattern)
 }
 
 func (d *Daemon) unlockedAutomatedServices() (policy.ServiceMap, error) {
@@ -78,5 +114,9 @@ func (d *Daemon) unlockedAutomatedServices() (policy.ServiceMap, error) {
 	if err != nil {
 		return nil, err
 	}
-	return automatedServices.Without(lockedServices), nil
+	pausedServices, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Paused)
+	if err != nil {
+		return nil, err
+	}
+	return automatedServices.Without(lockedServices).Without(pausedServices.PausedAt(time.Now())), nil
 }
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// expiredLocks remembers the job queued to remove locks that have
+// expired, so that another isn't queued for the same locks before it
+// has run. The zero value is ready to use.
+type expiredLocks struct {
+	sync.Mutex
+	pending job.ID
+}
+
+// unlockExpired queues a job to unlock any services whose locks have
+// expired by the time given; once the job has succeeded, it logs an
+// event saying which services were unlocked. The lock
+// details are removed along with the lock (see
+// policy.Updates.WithLockDetails).
+func (d *Daemon) unlockExpired(now time.Time, logger log.Logger) {
+	d.expiredLocks.Lock()
+	defer d.expiredLocks.Unlock()
+	if d.expiredLocks.pending != "" {
+		if status, ok := d.JobStatusCache.Status(d.expiredLocks.pending); ok && !status.StatusString.Terminal() {
+			return
+		}
+		d.expiredLocks.pending = ""
+	}
+
+	locked, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Locked)
+	if err != nil {
+		logger.Log("operation", "unlock expired", "err", err)
+		return
+	}
+	updates := policy.Updates{}
+	var ids []flux.ServiceID
+	var idStrs []string
+	for id, p := range locked {
+		if until, ok := p.LockedUntil(); ok && !until.After(now) {
+			updates[id] = policy.Update{Remove: policy.Set{policy.Locked: "true"}}
+			ids = append(ids, id)
+			idStrs = append(idStrs, id.String())
+		}
+	}
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := updates.Validate(); err != nil {
+		logger.Log("operation", "unlock expired", "err", err)
+		return
+	}
+	if err := d.checkNotDiverged(); err != nil {
+		logger.Log("operation", "unlock expired", "err", err)
+		return
+	}
+	spec := update.Spec{
+		Type:  update.Policy,
+		Cause: update.Cause{Message: "Lock expired"},
+		Spec:  updates,
+	}
+	id := d.queueJobThen(spec, func(metadata history.CommitEventMetadata) error {
+		// Only the services that were actually unlocked
+		var unlocked []flux.ServiceID
+		var unlockedStrs []string
+		for _, serviceID := range ids {
+			if r, ok := metadata.Result[serviceID]; ok && r.Status == update.ReleaseStatusSuccess {
+				unlocked = append(unlocked, serviceID)
+				unlockedStrs = append(unlockedStrs, serviceID.String())
+			}
+		}
+		if len(unlocked) == 0 {
+			return nil
+		}
+		done := time.Now().UTC()
+		return d.LogEvent(history.Event{
+			ServiceIDs: unlocked,
+			Type:       history.EventUnlock,
+			StartedAt:  done,
+			EndedAt:    done,
+			LogLevel:   history.LogLevelInfo,
+			Message:    fmt.Sprintf("Unlocked: %s (lock expired)", strings.Join(unlockedStrs, ", ")),
+		})
+	})
+	d.expiredLocks.pending = id
+	logger.Log("unlocking", strings.Join(idStrs, ","), "reason", "lock expired", "jobID", id)
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestUnlockExpired(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	runJob := func() {
+		select {
+		case j := <-d.Jobs.Ready():
+			if err := j.Do(log.NewNopLogger()); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a job")
+		}
+		if err := d.Checkout.Pull(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := func() policy.ServiceMap {
+		services, err := d.Manifests.ServicesWithPolicy(d.Checkout.ManifestDir(), policy.Locked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return services
+	}
+
+	hw := flux.MakeServiceID("default", "helloworld")
+	now := time.Now()
+	if _, err := d.UpdateManifests(context.Background(), update.Spec{
+		Type:  update.Policy,
+		Cause: update.Cause{User: "alice", Message: "waiting on the schema migration"},
+		Spec: policy.Updates{
+			hw: policy.Update{Add: policy.Set{
+				policy.Locked:      "true",
+				policy.LockedUntil: now.Add(-time.Minute).Format(time.RFC3339),
+			}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runJob()
+	if p := locked()[hw]; p[policy.LockedUser] != "alice" || p[policy.LockedMsg] != "waiting on the schema migration" {
+		t.Fatalf("expected the lock to record who and why, got %v", p)
+	}
+
+	d.unlockExpired(now, log.NewNopLogger())
+	// The job to unlock hasn't run yet, so there's nothing more to do
+	d.unlockExpired(now, log.NewNopLogger())
+	d.Jobs.Sync()
+	if n := d.Jobs.Len(); n != 1 {
+		t.Fatalf("expected one job to unlock expired locks, got %d", n)
+	}
+	unlocks := func() []history.Event {
+		es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var unlocks []history.Event
+		for _, e := range es {
+			if e.Type == history.EventUnlock {
+				unlocks = append(unlocks, e)
+			}
+		}
+		return unlocks
+	}
+	if es := unlocks(); len(es) != 0 {
+		t.Errorf("expected no unlock event before the job has run, got %#v", es)
+	}
+	runJob()
+
+	services := locked()
+	if p, ok := services[hw]; ok {
+		t.Errorf("expected %s to be unlocked, but has policies %v", hw, p)
+	}
+	if _, ok := services[flux.MakeServiceID("default", "locked-service")]; !ok {
+		t.Error("expected a lock without an expiry to stay")
+	}
+
+	if es := unlocks(); len(es) != 1 || len(es[0].ServiceIDs) != 1 || es[0].ServiceIDs[0] != hw {
+		t.Errorf("expected one unlock event for %s, got %#v", hw, es)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/resource"
 	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
@@ -35,21 +36,42 @@ func (loop *LoopVars) ensureInit() {
 func (d *Daemon) GitPollLoop(stop chan struct{}, wg *sync.WaitGroup, logger log.Logger) {
 	defer wg.Done()
 	// We want to pull the repo and sync at least every
-	// `GitPollInterval`. Being told to sync, or completing a job, may
+	// `gitPollInterval()`. Being told to sync, or completing a job, may
 	// intervene (in which case, reschedule the next pull-and-sync)
-	gitPollTimer := time.NewTimer(d.GitPollInterval)
+	gitPollTimer := time.NewTimer(d.gitPollInterval())
 	pullThen := func(k func(logger log.Logger)) {
 		defer func() {
 			gitPollTimer.Stop()
-			gitPollTimer = time.NewTimer(d.GitPollInterval)
+			gitPollTimer = time.NewTimer(d.gitPollInterval())
 		}()
-		if err := d.Checkout.Pull(); err != nil {
+		started := time.Now().UTC()
+		err := d.faults.check(remote.FaultGit)
+		if err == nil {
+			err = d.Checkout.Pull()
+		}
+		if err != nil {
 			logger.Log("operation", "pull", "err", err)
+			d.reportFailure(remote.FaultGit, err)
+			// Not getting the latest from the repo means the
+			// cluster isn't being kept in sync with it
+			d.recordSync(err)
+			d.syncReports.record(remote.SyncReport{
+				Started:  started,
+				Duration: time.Since(started),
+				Error:    errors.Wrap(err, "pulling from repo").Error(),
+			})
 			return
 		}
+		d.reportSuccess(remote.FaultGit)
 		k(logger)
 	}
 
+	// After each sync, see whether any locks have expired
+	syncThenUnlock := func(logger log.Logger) {
+		d.doSync(logger)
+		d.unlockExpired(time.Now(), logger)
+	}
+
 	imagePollTimer := time.NewTimer(d.RegistryPollInterval)
 
 	// Ask for a sync, and to poll images, straight away
@@ -67,7 +89,7 @@ func (d *Daemon) GitPollLoop(stop chan struct{}, wg *sync.WaitGroup, logger log.
 		case <-imagePollTimer.C:
 			d.askForImagePoll()
 		case <-d.syncSoon:
-			pullThen(d.doSync)
+			pullThen(syncThenUnlock)
 		case <-gitPollTimer.C:
 			// Time to poll for new commits (unless we're already
 			// about to do that)
@@ -83,7 +105,7 @@ func (d *Daemon) GitPollLoop(stop chan struct{}, wg *sync.WaitGroup, logger log.
 				continue
 			}
 			jobLogger.Log("state", "done", "success", "true")
-			pullThen(d.doSync)
+			pullThen(syncThenUnlock)
 		}
 	}
 }
@@ -110,11 +132,19 @@ func (d *LoopVars) askForImagePoll() {
 
 func (d *Daemon) doSync(logger log.Logger) {
 	started := time.Now().UTC()
+	// Anyone waiting on this sync gets a report of how it went
+	report := remote.SyncReport{Started: started}
+	defer func() {
+		report.Duration = time.Since(started)
+		d.syncReports.record(report)
+	}()
 
 	// checkout a working clone so we can mess around with tags later
 	working, err := d.Checkout.WorkingClone()
 	if err != nil {
 		logger.Log("err", err)
+		d.recordSync(err)
+		report.Error = err.Error()
 		return
 	}
 	defer working.Clean()
@@ -123,13 +153,58 @@ func (d *Daemon) doSync(logger log.Logger) {
 	// Get a map of all resources defined in the repo
 	allResources, err := d.Manifests.LoadManifests(working.ManifestDir())
 	if err != nil {
-		logger.Log("err", errors.Wrap(err, "loading resources from repo"))
+		err = errors.Wrap(err, "loading resources from repo")
+		logger.Log("err", err)
+		d.recordSync(err)
+		report.Error = err.Error()
 		return
 	}
 
-	// TODO supply deletes argument from somewhere (command-line?)
-	if err := fluxsync.Sync(d.Manifests, allResources, d.Cluster, false, logger); err != nil {
+	// The kinds excluded may change with the instance config; this
+	// sync is reported with those in effect as it starts.
+	exclude := d.Exclude.Get()
+	var result fluxsync.Result
+	err = d.faults.check(remote.FaultApply)
+	if err == nil {
+		result, err = fluxsync.Sync(d.Manifests, allResources, d.Cluster, d.Prune, logger)
+	}
+	rollouts := result.Rollouts
+	if err != nil {
 		logger.Log("err", err)
+		d.reportFailure(remote.FaultApply, err)
+	} else {
+		d.reportSuccess(remote.FaultApply)
+	}
+	d.recordSync(err)
+	revision, revErr := working.HeadRevision()
+	if revErr != nil {
+		logger.Log("err", errors.Wrap(revErr, "getting revision synced"))
+	}
+	d.syncedResources.record(revision, allResources, exclude, err)
+	syncErr := err
+	report.Revision = revision
+	report.Pruned, report.PruneDryRun = result.Pruned, d.Prune.DryRun
+	report.Drifted = result.Drifted
+	if !d.Prune.DryRun {
+		d.logPrunes(revision, result.Pruned, started, logger)
+	}
+	if len(rollouts) > 0 {
+		rolledOut := flux.ServiceIDSet{}
+		for _, r := range rollouts {
+			if res, ok := allResources[r.ResourceID]; ok {
+				rolledOut.Add(res.ServiceIDs(allResources))
+			}
+		}
+		if err := d.LogEvent(history.Event{
+			ServiceIDs: rolledOut.ToSlice(),
+			Type:       history.EventConfigRollout,
+			StartedAt:  started,
+			EndedAt:    time.Now().UTC(),
+			LogLevel:   history.LogLevelInfo,
+			Metadata:   &history.ConfigRolloutEventMetadata{Rollouts: rollouts},
+		}); err != nil {
+			logger.Log("err", err)
+		}
 	}
 
 	// Figure out which service IDs changed in this release
@@ -141,13 +216,17 @@ func (d *Daemon) doSync(logger log.Logger) {
 		changedResources, err = d.Manifests.LoadManifests(changedFiles...)
 		if err != nil {
 			logger.Log("err", errors.Wrap(err, "loading resources from repo"))
+			reportResources(&report, allResources, nil, exclude, syncErr)
 			return
 		}
+		reportResources(&report, allResources, changedResources, exclude, syncErr)
 	case isUnknownRevision(err):
 		// no synctag, We are syncing everything from scratch
 		changedResources = allResources
+		reportResources(&report, allResources, nil, exclude, syncErr)
 	default:
 		logger.Log("err", err)
+		reportResources(&report, allResources, nil, exclude, syncErr)
 	}
 	serviceIDs := flux.ServiceIDSet{}
 	for _, r := range changedResources {
@@ -190,49 +269,65 @@ func (d *Daemon) doSync(logger log.Logger) {
 			}
 
 			// If any of the commit notes has a release event, send
-			// that to the service
-			switch n.Spec.Type {
-			case update.Images:
-				// Map new note.Spec into ReleaseSpec
-				spec := n.Spec.Spec.(update.ReleaseSpec)
-				// And create a release event
-				// Then wrap inside a ReleaseEventMetadata
-				if err := d.LogEvent(history.Event{
-					ServiceIDs: serviceIDs.ToSlice(),
-					Type:       history.EventRelease,
-					StartedAt:  started,
-					EndedAt:    time.Now().UTC(),
-					LogLevel:   history.LogLevelInfo,
-					Metadata: &history.ReleaseEventMetadata{
-						ReleaseEventCommon: history.ReleaseEventCommon{
-							Revision: revisions[i],
-							Result:   n.Result,
-							Error:    n.Result.Error(),
-						},
-						Spec:  spec,
-						Cause: n.Spec.Cause,
-					},
-				}); err != nil {
-					logger.Log("err", err)
+			// that to the service. A batch may have any number of
+			// releases in it; these are attributed to the batch,
+			// unless they have a cause of their own.
+			specs := []update.Spec{n.Spec}
+			if steps, ok := n.Spec.Spec.(update.BatchSpec); ok {
+				specs = nil
+				for _, step := range steps {
+					if step.Cause == (update.Cause{}) {
+						step.Cause = n.Spec.Cause
+					}
+					specs = append(specs, step)
 				}
-			case update.Auto:
-				spec := n.Spec.Spec.(update.Automated)
-				if err := d.LogEvent(history.Event{
-					ServiceIDs: serviceIDs.ToSlice(),
-					Type:       history.EventAutoRelease,
-					StartedAt:  started,
-					EndedAt:    time.Now().UTC(),
-					LogLevel:   history.LogLevelInfo,
-					Metadata: &history.AutoReleaseEventMetadata{
-						ReleaseEventCommon: history.ReleaseEventCommon{
-							Revision: revisions[i],
-							Result:   n.Result,
-							Error:    n.Result.Error(),
+			}
+			for _, noteSpec := range specs {
+				switch noteSpec.Type {
+				case update.Images:
+					// Map new note.Spec into ReleaseSpec
+					spec := noteSpec.Spec.(update.ReleaseSpec)
+					// And create a release event
+					// Then wrap inside a ReleaseEventMetadata
+					if err := d.LogEvent(history.Event{
+						ServiceIDs: serviceIDs.ToSlice(),
+						Type:       history.EventRelease,
+						StartedAt:  started,
+						EndedAt:    time.Now().UTC(),
+						LogLevel:   history.LogLevelInfo,
+						Metadata: &history.ReleaseEventMetadata{
+							ReleaseEventCommon: history.ReleaseEventCommon{
+								Revision: revisions[i],
+								Result:   n.Result,
+								Error:    n.Result.Error(),
+								Notes:    d.releaseNotes(revisions[i], noteSpec.Cause, n.Result, logger),
+							},
+							Spec:  spec,
+							Cause: noteSpec.Cause,
 						},
-						Spec: spec,
-					},
-				}); err != nil {
-					logger.Log("err", err)
+					}); err != nil {
+						logger.Log("err", err)
+					}
+				case update.Auto:
+					spec := noteSpec.Spec.(update.Automated)
+					if err := d.LogEvent(history.Event{
+						ServiceIDs: serviceIDs.ToSlice(),
+						Type:       history.EventAutoRelease,
+						StartedAt:  started,
+						EndedAt:    time.Now().UTC(),
+						LogLevel:   history.LogLevelInfo,
+						Metadata: &history.AutoReleaseEventMetadata{
+							ReleaseEventCommon: history.ReleaseEventCommon{
+								Revision: revisions[i],
+								Result:   n.Result,
+								Error:    n.Result.Error(),
+								Notes:    d.releaseNotes(revisions[i], noteSpec.Cause, n.Result, logger),
+							},
+							Spec: spec,
+						},
+					}); err != nil {
+						logger.Log("err", err)
+					}
 				}
 			}
 		}
@@ -249,6 +344,22 @@ func (d *Daemon) doSync(logger log.Logger) {
 	}
 }
 
+// releaseNotes composes the notes for a release, looking up the
+// images involved so that their labels can be consulted. The notes
+// are a nicety, so if they can't be composed the release is logged
+// without them.
+func (d *Daemon) releaseNotes(revision string, cause update.Cause, result update.Result, logger log.Logger) *update.ReleaseNotes {
+	notes, err := update.NewReleaseNotes(revision, cause, result, func(id flux.ImageID) (flux.Image, bool) {
+		img, err := d.Registry.GetImage(id)
+		return img, err == nil
+	})
+	if err != nil {
+		logger.Log("err", errors.Wrap(err, "composing release notes"))
+		return nil
+	}
+	return &notes
+}
+
 func (d *Daemon) updateTagRev(working *git.Checkout, logger log.Logger) error {
 	oldTagRev, err := d.Checkout.TagRevision(d.Checkout.SyncTag)
 	if err != nil && !strings.Contains(err.Error(), "unknown revision or path not in the working tree") {
@@ -55,6 +55,8 @@ func daemon(t *testing.T) (*Daemon, func()) {
 	k8s.ExportFunc = func() ([]byte, error) { return nil, nil }
 	k8s.FindDefinedServicesFunc = (&kubernetes.Manifests{}).FindDefinedServices
 	k8s.ServicesWithPolicyFunc = (&kubernetes.Manifests{}).ServicesWithPolicy
+	k8s.MarkAppliedFunc = (&kubernetes.Manifests{}).MarkApplied
+	k8s.IsMarkedAppliedFunc = (&kubernetes.Manifests{}).IsMarkedApplied
 
 	events = history.NewMock()
 
@@ -1,11 +1,14 @@
 package daemon
 
 import (
+	"context"
 	"sync"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -17,6 +20,10 @@ type NotReadyDaemon struct {
 	cluster   cluster.Cluster
 	gitRemote flux.GitRemoteConfig
 	reason    error
+	exports   exports
+	// Settings from the instance config, which can be taken before
+	// the repo has been cloned
+	Settings *Settings
 }
 
 func NewNotReadyDaemon(version string, cluster cluster.Cluster, gitRemote flux.GitRemoteConfig, reason error) (nrd *NotReadyDaemon) {
@@ -42,44 +49,81 @@ func (nrd *NotReadyDaemon) UpdateReason(reason error) {
 
 // 'Not ready' platform implementation
 
-func (nrd *NotReadyDaemon) Ping() error {
+func (nrd *NotReadyDaemon) Ping(ctx context.Context) error {
 	return nrd.cluster.Ping()
 }
 
-func (nrd *NotReadyDaemon) Version() (string, error) {
+func (nrd *NotReadyDaemon) Version(ctx context.Context) (string, error) {
 	return nrd.version, nil
 }
 
-func (nrd *NotReadyDaemon) Export() ([]byte, error) {
+func (nrd *NotReadyDaemon) Export(ctx context.Context) ([]byte, error) {
 	return nrd.cluster.Export()
 }
 
-func (nrd *NotReadyDaemon) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+func (nrd *NotReadyDaemon) ExportChunk(ctx context.Context, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	return nrd.exports.chunk(nrd.cluster, req)
+}
+
+func (nrd *NotReadyDaemon) ResetGitToRemote(ctx context.Context) (string, error) {
+	return "", nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) InjectFault(ctx context.Context, spec remote.FaultSpec) error {
+	return nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) SyncHealth(ctx context.Context) (remote.SyncHealth, error) {
+	return remote.SyncHealth{}, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
 	return nil, nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) ListImages(update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (nrd *NotReadyDaemon) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	_, err := nrd.Settings.apply(config)
+	return err
+}
+
+func (nrd *NotReadyDaemon) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
 	return nil, nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) UpdateManifests(update.Spec) (job.ID, error) {
+func (nrd *NotReadyDaemon) SyncWait(ctx context.Context, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	return remote.SyncReport{}, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) UpdateManifests(context.Context, update.Spec) (job.ID, error) {
 	var id job.ID
 	return id, nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) SyncNotify() error {
+func (nrd *NotReadyDaemon) SyncNotify(ctx context.Context) error {
 	return nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) JobStatus(id job.ID) (job.Status, error) {
+func (nrd *NotReadyDaemon) JobStatus(ctx context.Context, id job.ID) (job.Status, error) {
 	return job.Status{}, nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) SyncStatus(string) ([]string, error) {
+func (nrd *NotReadyDaemon) SyncStatus(context.Context, string) ([]string, error) {
 	return nil, nrd.Reason()
 }
 
-func (nrd *NotReadyDaemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+func (nrd *NotReadyDaemon) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
 	publicSSHKey, err := nrd.cluster.PublicSSHKey(regenerate)
 	if err != nil {
 		return flux.GitConfig{}, err
@@ -89,3 +133,23 @@ func (nrd *NotReadyDaemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error
 		PublicSSHKey: publicSSHKey,
 	}, nil
 }
+
+func (nrd *NotReadyDaemon) JobLog(context.Context, job.ID) (job.Log, error) {
+	return job.Log{}, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) WaitJobStatus(context.Context, job.WaitRequest) (job.Status, error) {
+	return job.Status{}, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	return flux.ClusterConfig{}, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) EvaluateImage(context.Context, flux.ImageID) (update.Result, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	return nil, nrd.Reason()
+}
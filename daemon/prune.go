@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/resource"
+)
+
+// logPrunes logs an event for each resource deleted from the cluster
+// in a sync, because it had gone from the repo at the revision given.
+func (d *Daemon) logPrunes(revision string, pruned []string, started time.Time, logger log.Logger) {
+	for _, id := range pruned {
+		var serviceIDs []flux.ServiceID
+		if _, namespace, name := resource.SplitID(id); namespace != "" {
+			serviceIDs = append(serviceIDs, flux.MakeServiceID(namespace, name))
+		}
+		if err := d.LogEvent(history.Event{
+			ServiceIDs: serviceIDs,
+			Type:       history.EventPrune,
+			StartedAt:  started,
+			EndedAt:    time.Now().UTC(),
+			LogLevel:   history.LogLevelInfo,
+			Metadata:   &history.PruneEventMetadata{Revision: revision, ResourceID: id},
+		}); err != nil {
+			logger.Log("err", err)
+		}
+	}
+}
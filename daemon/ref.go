@@ -1,11 +1,13 @@
 package daemon
 
 import (
+	"context"
 	"sync"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -33,42 +35,98 @@ func (pr *Ref) UpdatePlatform(platform remote.Platform) {
 // remote.Platform implementation so clients don't need to be refactored around
 // Platform() API
 
-func (pr *Ref) Ping() error {
-	return pr.Platform().Ping()
+func (pr *Ref) Ping(ctx context.Context) error {
+	return pr.Platform().Ping(ctx)
 }
 
-func (pr *Ref) Version() (string, error) {
-	return pr.Platform().Version()
+func (pr *Ref) Version(ctx context.Context) (string, error) {
+	return pr.Platform().Version(ctx)
 }
 
-func (pr *Ref) Export() ([]byte, error) {
-	return pr.Platform().Export()
+func (pr *Ref) Export(ctx context.Context) ([]byte, error) {
+	return pr.Platform().Export(ctx)
 }
 
-func (pr *Ref) ListServices(namespace string) ([]flux.ServiceStatus, error) {
-	return pr.Platform().ListServices(namespace)
+func (pr *Ref) ExportChunk(ctx context.Context, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	return pr.Platform().ExportChunk(ctx, req)
 }
 
-func (pr *Ref) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error) {
-	return pr.Platform().ListImages(spec)
+func (pr *Ref) ResetGitToRemote(ctx context.Context) (string, error) {
+	return pr.Platform().ResetGitToRemote(ctx)
 }
 
-func (pr *Ref) UpdateManifests(spec update.Spec) (job.ID, error) {
-	return pr.Platform().UpdateManifests(spec)
+func (pr *Ref) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	return pr.Platform().ExportAt(ctx, ref)
 }
 
-func (pr *Ref) SyncNotify() error {
-	return pr.Platform().SyncNotify()
+func (pr *Ref) InjectFault(ctx context.Context, spec remote.FaultSpec) error {
+	return pr.Platform().InjectFault(ctx, spec)
 }
 
-func (pr *Ref) JobStatus(id job.ID) (job.Status, error) {
-	return pr.Platform().JobStatus(id)
+func (pr *Ref) SyncHealth(ctx context.Context) (remote.SyncHealth, error) {
+	return pr.Platform().SyncHealth(ctx)
 }
 
-func (pr *Ref) SyncStatus(ref string) ([]string, error) {
-	return pr.Platform().SyncStatus(ref)
+func (pr *Ref) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	return pr.Platform().ListResources(ctx)
 }
 
-func (pr *Ref) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
-	return pr.Platform().GitRepoConfig(regenerate)
+func (pr *Ref) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	return pr.Platform().ApplyConfig(ctx, config)
+}
+
+func (pr *Ref) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	return pr.Platform().PlanRelease(ctx, spec)
+}
+
+func (pr *Ref) SyncWait(ctx context.Context, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	return pr.Platform().SyncWait(ctx, req)
+}
+
+func (pr *Ref) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
+	return pr.Platform().ListServices(ctx, namespace)
+}
+
+func (pr *Ref) ListImages(ctx context.Context, spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+	return pr.Platform().ListImages(ctx, spec)
+}
+
+func (pr *Ref) UpdateManifests(ctx context.Context, spec update.Spec) (job.ID, error) {
+	return pr.Platform().UpdateManifests(ctx, spec)
+}
+
+func (pr *Ref) SyncNotify(ctx context.Context) error {
+	return pr.Platform().SyncNotify(ctx)
+}
+
+func (pr *Ref) JobStatus(ctx context.Context, id job.ID) (job.Status, error) {
+	return pr.Platform().JobStatus(ctx, id)
+}
+
+func (pr *Ref) SyncStatus(ctx context.Context, ref string) ([]string, error) {
+	return pr.Platform().SyncStatus(ctx, ref)
+}
+
+func (pr *Ref) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
+	return pr.Platform().GitRepoConfig(ctx, regenerate)
+}
+
+func (pr *Ref) JobLog(ctx context.Context, id job.ID) (job.Log, error) {
+	return pr.Platform().JobLog(ctx, id)
+}
+
+func (pr *Ref) WaitJobStatus(ctx context.Context, req job.WaitRequest) (job.Status, error) {
+	return pr.Platform().WaitJobStatus(ctx, req)
+}
+
+func (pr *Ref) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	return pr.Platform().ClusterConfig(ctx)
+}
+
+func (pr *Ref) EvaluateImage(ctx context.Context, image flux.ImageID) (update.Result, error) {
+	return pr.Platform().EvaluateImage(ctx, image)
+}
+
+func (pr *Ref) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	return pr.Platform().ServiceTopology(ctx)
 }
@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/resource"
+)
+
+type resourceSync struct {
+	revision string // last applied successfully from
+	status   string
+	err      string
+	failedAt string // the revision it failed to apply from, if it failed
+}
+
+// syncedResources keeps how each resource in the repo fared in the
+// most recent sync. The zero value is ready to use.
+type syncedResources struct {
+	sync.Mutex
+	synced map[string]resourceSync
+}
+
+// record notes the outcome of a sync of the resources given, from
+// the revision given. Resources that have gone from the repo are
+// forgotten.
+func (r *syncedResources) record(revision string, resources map[string]resource.Resource, exclude cluster.KindFilter, err error) {
+	r.Lock()
+	defer r.Unlock()
+	syncErrs, _ := err.(cluster.SyncError)
+	synced := map[string]resourceSync{}
+	for id, res := range resources {
+		s := r.synced[id] // keep the revision last applied, if it fails this time
+		kind, _, _ := resource.SplitID(id)
+		switch {
+		case res.Policy().Contains(policy.Ignore) || exclude.Excludes(kind):
+			s.status, s.err = flux.ResourceIgnored, ""
+		case syncErrs[id] != nil:
+			s.status, s.err, s.failedAt = flux.ResourceFailed, syncErrs[id].Error(), revision
+		case err != nil && syncErrs == nil:
+			s.status, s.err, s.failedAt = flux.ResourceFailed, err.Error(), revision
+		default:
+			s.revision, s.status, s.err, s.failedAt = revision, flux.ResourceApplied, "", ""
+		}
+		synced[id] = s
+	}
+	r.synced = synced
+}
+
+// list reports the resources given (as they are in the repo now),
+// along with how each fared when last synced.
+func (r *syncedResources) list(root string, resources map[string]resource.Resource) []flux.ResourceStatus {
+	r.Lock()
+	defer r.Unlock()
+	var result []flux.ResourceStatus
+	for id, res := range resources {
+		kind, namespace, name := resource.SplitID(id)
+		file := res.Source()
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		status := flux.ResourceStatus{
+			ID:        id,
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			File:      file,
+			Status:    flux.ResourcePending,
+		}
+		if s, ok := r.synced[id]; ok {
+			status.Revision, status.Status, status.Error = s.revision, s.status, s.err
+		}
+		result = append(result, status)
+	}
+	sort.Sort(resourceStatusesByNamespace(result))
+	return result
+}
+
+// serviceFailures gives, for each service with a resource that
+// failed to apply in the most recent sync, the failure. A service's
+// resources are those with its namespace and name (e.g., both the
+// Deployment and the Service); if more than one failed, the first
+// by ID is given.
+func (r *syncedResources) serviceFailures() map[flux.ServiceID]resourceSync {
+	r.Lock()
+	defer r.Unlock()
+	var ids []string
+	for id, s := range r.synced {
+		if s.status == flux.ResourceFailed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	failures := map[flux.ServiceID]resourceSync{}
+	for _, id := range ids {
+		_, namespace, name := resource.SplitID(id)
+		if namespace == "" {
+			continue // cluster-scoped, so not part of a service
+		}
+		serviceID := flux.MakeServiceID(namespace, name)
+		if _, ok := failures[serviceID]; !ok {
+			failures[serviceID] = r.synced[id]
+		}
+	}
+	return failures
+}
+
+type resourceStatusesByNamespace []flux.ResourceStatus
+
+func (s resourceStatusesByNamespace) Len() int      { return len(s) }
+func (s resourceStatusesByNamespace) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s resourceStatusesByNamespace) Less(i, j int) bool {
+	return resourceIDsByNamespace{s[i].ID, s[j].ID}.Less(0, 1)
+}
+
+// ListResources reports all the resources defined in the repo --
+// not only workloads, but namespaces, roles, custom resource
+// definitions and so on -- and whether each was applied in the most
+// recent sync.
+func (d *Daemon) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	root := d.Checkout.ManifestDir()
+	resources, err := d.Manifests.LoadManifests(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading resources from repo")
+	}
+	return d.syncedResources.list(root, resources), nil
+}
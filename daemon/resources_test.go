@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+)
+
+func TestListResources(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	d.Exclude = cluster.NewSharedKindFilter(cluster.KindFilter{"Service"})
+
+	// Not in the repo yet, as far as syncing goes
+	ns := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n"
+	if err := ioutil.WriteFile(filepath.Join(d.Checkout.ManifestDir(), "monitoring-ns.yaml"), []byte(ns), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	byID := func() map[string]flux.ResourceStatus {
+		resources, err := d.ListResources(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := map[string]flux.ResourceStatus{}
+		for _, r := range resources {
+			statuses[r.ID] = r
+		}
+		return statuses
+	}
+
+	for id, r := range byID() {
+		if r.Status != flux.ResourcePending {
+			t.Errorf("%s: expected to be pending before syncing, got %+v", id, r)
+		}
+	}
+
+	hwID := "Deployment default/helloworld"
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		return cluster.SyncError{hwID: errors.New("no such kind")}
+	}
+	d.doSync(log.NewNopLogger())
+	head, err := d.Checkout.HeadRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := byID()
+	if len(statuses) != 7 {
+		t.Fatalf("expected six resources from the repo and the namespace, got %+v", statuses)
+	}
+	if r := statuses[hwID]; r.Status != flux.ResourceFailed || r.Error != "no such kind" || r.Revision != "" {
+		t.Errorf("expected %s to have failed, got %+v", hwID, r)
+	}
+	if r := statuses["Deployment default/locked-service"]; r.Status != flux.ResourceApplied || r.Revision != head || r.File != "locked-service-deploy.yaml" {
+		t.Errorf("expected locked-service to be applied from %s, got %+v", head, r)
+	}
+	if r := statuses["Service default/helloworld"]; r.Status != flux.ResourceIgnored {
+		t.Errorf("expected an excluded kind to be ignored, got %+v", r)
+	}
+	if r := statuses["Namespace monitoring"]; r.Status != flux.ResourcePending || r.Kind != "Namespace" || r.Namespace != "" || r.Name != "monitoring" {
+		t.Errorf("expected uncommitted namespace to be pending, got %+v", r)
+	}
+
+	failures := d.syncedResources.serviceFailures()
+	if f := failures[flux.MakeServiceID("default", "helloworld")]; len(failures) != 1 || f.err != "no such kind" || f.failedAt != head {
+		t.Errorf("expected the failure to be reported for the service at %s, got %+v", head, failures)
+	}
+
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		return nil
+	}
+	d.doSync(log.NewNopLogger())
+	if r := byID()[hwID]; r.Status != flux.ResourceApplied || r.Error != "" || r.Revision != head {
+		t.Errorf("expected %s to have been applied, got %+v", hwID, r)
+	}
+	if failures := d.syncedResources.serviceFailures(); len(failures) != 0 {
+		t.Errorf("expected no failures once applied, got %+v", failures)
+	}
+}
@@ -0,0 +1,286 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/release"
+	"github.com/weaveworks/flux/update"
+)
+
+// How many commits back to look for the release a rollback undoes.
+const rollbackSearchDepth = 1000
+
+// rollback works out what each service goes back to -- the images
+// from before its last release, or its definition at the revision
+// given -- then writes that to the working clone the way a release
+// would, and commits it.
+func (d *Daemon) rollback(spec update.Spec, r update.RollbackSpec) DaemonJobFunc {
+	return func(jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		changes := &rollbackChanges{spec: r}
+		var err error
+		if r.Revision == "" {
+			changes.images, err = previousImages(working, r.ServiceSpecs)
+		} else {
+			changes.definitions, err = d.definitionsAt(r.Revision)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+		result, err := release.Release(rc, changes, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		var revision string
+		if r.Kind == update.ReleaseKindExecute && anythingChanged(result) {
+			commitMsg := spec.Cause.Message
+			if commitMsg == "" {
+				commitMsg = changes.CommitMessage()
+			}
+			if err := working.CommitAndPush(commitMsg, &git.Note{JobID: jobID, Spec: spec, Result: result}); err != nil {
+				d.askForSync()
+				return nil, err
+			}
+			revision, err = working.HeadRevision()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &history.CommitEventMetadata{
+			Revision: revision,
+			Spec:     &spec,
+			Result:   result,
+		}, nil
+	}
+}
+
+// previousImages finds the most recent release of each service given,
+// going by the notes on the commits flux has made, and gives the
+// images its containers had before that release. Containers the
+// release didn't change are left out. Since a rollback is a release
+// too, rolling back twice puts the images back again.
+func previousImages(working *git.Checkout, specs []update.ServiceSpec) (map[flux.ServiceID]map[string]flux.ImageID, error) {
+	wanted := map[flux.ServiceID]bool{}
+	for _, s := range specs {
+		id, err := s.AsID()
+		if err != nil {
+			return nil, err
+		}
+		wanted[id] = true
+	}
+
+	revisions, err := working.RevisionsBefore("HEAD")
+	if err != nil {
+		return nil, errors.Wrap(err, "listing revisions")
+	}
+	if len(revisions) > rollbackSearchDepth {
+		revisions = revisions[:rollbackSearchDepth]
+	}
+
+	images := map[flux.ServiceID]map[string]flux.ImageID{}
+	for _, rev := range revisions {
+		if len(wanted) == 0 {
+			break
+		}
+		note, err := working.GetNote(rev)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading note for %s", rev)
+		}
+		if note == nil {
+			continue
+		}
+		for id, result := range note.Result {
+			if !wanted[id] || result.Status != update.ReleaseStatusSuccess || len(result.PerContainer) == 0 {
+				continue
+			}
+			containers := map[string]flux.ImageID{}
+			for _, c := range result.PerContainer {
+				containers[c.Container] = c.Current
+			}
+			images[id] = containers
+			delete(wanted, id)
+		}
+	}
+	return images, nil
+}
+
+// definitionsAt gives the manifest file defining each service, as it
+// was at the revision given.
+func (d *Daemon) definitionsAt(ref string) (map[flux.ServiceID][]byte, error) {
+	working, err := d.Checkout.WorkingClone()
+	if err != nil {
+		return nil, err
+	}
+	defer working.Clean()
+	if _, err := working.ResetTo(ref); err != nil {
+		return nil, err
+	}
+	services, err := d.Manifests.FindDefinedServices(working.ManifestDir())
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding services defined at %s", ref)
+	}
+	defs := map[flux.ServiceID][]byte{}
+	for id, paths := range services {
+		if len(paths) != 1 {
+			return nil, fmt.Errorf("multiple resource files found for service %s at %s: %s", id, ref, strings.Join(paths, ", "))
+		}
+		def, err := ioutil.ReadFile(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		defs[id] = def
+	}
+	return defs, nil
+}
+
+// rollbackChanges is a rollback, once what it goes back to has been
+// looked up, as release.Changes; so it's selected, validated and
+// written just as a release is.
+type rollbackChanges struct {
+	spec        update.RollbackSpec
+	images      map[flux.ServiceID]map[string]flux.ImageID
+	definitions map[flux.ServiceID][]byte
+}
+
+func (c *rollbackChanges) ReleaseKind() update.ReleaseKind {
+	return c.spec.Kind
+}
+
+func (c *rollbackChanges) ReleaseType() update.ReleaseType {
+	if c.spec.Revision == "" {
+		return "rollback_images"
+	}
+	return "rollback_revision"
+}
+
+func (c *rollbackChanges) CommitMessage() string {
+	return c.spec.CommitMessage()
+}
+
+func (c *rollbackChanges) CalculateRelease(rc update.ReleaseContext, logger log.Logger) ([]*update.ServiceUpdate, update.Result, error) {
+	filters, err := c.spec.Filters(rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := update.Result{}
+	candidates, err := rc.SelectServices(result, filters...)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.spec.MarkSkipped(result)
+
+	var updates []*update.ServiceUpdate
+	for _, u := range candidates {
+		var changed bool
+		if c.spec.Revision == "" {
+			changed, err = c.revertImages(rc, u, result)
+		} else {
+			changed = c.revertDefinition(u, result)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			updates = append(updates, u)
+		}
+	}
+	return updates, result, nil
+}
+
+func (c *rollbackChanges) revertImages(rc update.ReleaseContext, u *update.ServiceUpdate, result update.Result) (bool, error) {
+	previous, ok := c.images[u.ServiceID]
+	if !ok {
+		result[u.ServiceID] = update.ServiceResult{
+			Status: update.ReleaseStatusSkipped,
+			Error:  update.NoEarlierImage,
+		}
+		return false, nil
+	}
+	containers, err := u.Service.ContainersOrError()
+	if err != nil {
+		result[u.ServiceID] = update.ServiceResult{
+			Status: update.ReleaseStatusFailed,
+			Error:  err.Error(),
+		}
+		return false, nil
+	}
+
+	// What's running may not have caught up with the repo yet, so
+	// it's the manifest that says whether anything needs changing
+	before := u.ManifestBytes
+	var containerUpdates []update.ContainerUpdate
+	for _, container := range containers {
+		target, ok := previous[container.Name]
+		if !ok {
+			continue
+		}
+		current, err := flux.ParseImageID(container.Image)
+		if err != nil {
+			return false, err
+		}
+		u.ManifestBytes, err = rc.Manifests().UpdateDefinition(u.ManifestBytes, container.Name, target)
+		if err != nil {
+			return false, err
+		}
+		var warning string
+		u.ManifestBytes, warning, err = rc.Manifests().CheckPullPolicy(u.ManifestBytes, container.Name, target)
+		if err != nil {
+			return false, err
+		}
+		containerUpdates = append(containerUpdates, update.ContainerUpdate{
+			Container: container.Name,
+			Current:   current,
+			Target:    target,
+			Warning:   warning,
+		})
+	}
+
+	if bytes.Equal(before, u.ManifestBytes) {
+		result[u.ServiceID] = update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.ImageUpToDate,
+		}
+		return false, nil
+	}
+	u.Updates = containerUpdates
+	result[u.ServiceID] = update.ServiceResult{
+		Status:       update.ReleaseStatusSuccess,
+		PerContainer: containerUpdates,
+	}
+	return true, nil
+}
+
+func (c *rollbackChanges) revertDefinition(u *update.ServiceUpdate, result update.Result) bool {
+	def, ok := c.definitions[u.ServiceID]
+	switch {
+	case !ok:
+		result[u.ServiceID] = update.ServiceResult{
+			Status: update.ReleaseStatusSkipped,
+			Error:  update.NotAtRevision,
+		}
+		return false
+	case bytes.Equal(def, u.ManifestBytes):
+		result[u.ServiceID] = update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.SameAtRevision,
+		}
+		return false
+	}
+	u.ManifestBytes = def
+	result[u.ServiceID] = update.ServiceResult{
+		Status: update.ReleaseStatusSuccess,
+	}
+	return true
+}
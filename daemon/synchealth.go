@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux/history"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+	"github.com/weaveworks/flux/remote"
+)
+
+// DefaultSyncHealthWindow is how far back sync health is reported,
+// if the daemon isn't told otherwise.
+const DefaultSyncHealthWindow = 24 * time.Hour
+
+// syncFreshnessComponent names the failure reported when the sync
+// error budget is used up.
+const syncFreshnessComponent = "sync-freshness"
+
+var (
+	syncAttempts = fluxmetrics.NewCounter(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxd",
+		Name:      "sync_attempts_total",
+		Help:      "Count of attempts to sync the cluster with the git repo.",
+	}, "success")
+	lastSuccessfulSync = fluxmetrics.NewGauge(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxd",
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "When the cluster was last synced with the git repo successfully, as a Unix time.",
+	})
+	syncBudgetRemaining = fluxmetrics.NewGauge(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxd",
+		Name:      "sync_error_budget_remaining_ratio",
+		Help:      "Fraction of the error budget for sync freshness left in the rolling window; only given if there's an objective.",
+	})
+)
+
+type syncAttempt struct {
+	at time.Time
+	ok bool
+}
+
+// syncHealth keeps the outcome of each sync attempt in a rolling
+// window. The zero value is ready to use.
+type syncHealth struct {
+	sync.Mutex
+	started  time.Time
+	attempts []syncAttempt // oldest first, all within the window
+	// the most recent success that has dropped out of the window,
+	// which says how stale the cluster was at the start of it
+	successBefore time.Time
+	exhausted     bool
+}
+
+func (h *syncHealth) prune(now time.Time, window time.Duration) {
+	if h.started.IsZero() {
+		h.started = now
+	}
+	start := now.Add(-window)
+	i := 0
+	for ; i < len(h.attempts) && h.attempts[i].at.Before(start); i++ {
+		if h.attempts[i].ok {
+			h.successBefore = h.attempts[i].at
+		}
+	}
+	h.attempts = h.attempts[i:]
+}
+
+// record notes a sync attempt, and says whether that changed whether
+// the error budget is exhausted.
+func (h *syncHealth) record(now time.Time, ok bool, window time.Duration, objective *remote.SyncObjective) (remote.SyncHealth, bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.prune(now, window)
+	h.attempts = append(h.attempts, syncAttempt{at: now, ok: ok})
+	report := h.report(now, window, objective)
+	changed := report.BudgetExhausted() != h.exhausted
+	h.exhausted = report.BudgetExhausted()
+	return report, changed
+}
+
+func (h *syncHealth) get(now time.Time, window time.Duration, objective *remote.SyncObjective) remote.SyncHealth {
+	h.Lock()
+	defer h.Unlock()
+	h.prune(now, window)
+	return h.report(now, window, objective)
+}
+
+func (h *syncHealth) report(now time.Time, window time.Duration, objective *remote.SyncObjective) remote.SyncHealth {
+	report := remote.SyncHealth{Window: window}
+	// The cluster is stale from Freshness after each successful
+	// sync, until the next; before the first, it's as though the
+	// daemon started with a successful sync.
+	last := h.successBefore
+	if last.IsZero() {
+		last = h.started
+	}
+	start := now.Add(-window)
+	var stale time.Duration
+	addStale := func(from, to time.Time) {
+		if objective == nil {
+			return
+		}
+		from = from.Add(objective.Freshness)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(from) {
+			stale += to.Sub(from)
+		}
+	}
+	for _, a := range h.attempts {
+		report.Attempts++
+		if a.ok {
+			report.Successes++
+			addStale(last, a.at)
+			last = a.at
+			report.LastSuccess = a.at
+		}
+	}
+	addStale(last, now)
+	if report.LastSuccess.IsZero() && !h.successBefore.IsZero() {
+		report.LastSuccess = h.successBefore
+	}
+	report.SinceLastSuccess = now.Sub(last)
+
+	if objective != nil {
+		report.Objective = objective
+		report.StaleTime = stale
+		budget := time.Duration(float64(window) * (1 - objective.Target))
+		if budget > 0 {
+			report.BudgetRemaining = 1 - float64(stale)/float64(budget)
+		} else if stale == 0 {
+			report.BudgetRemaining = 1
+		}
+	}
+	return report
+}
+
+// recordSync notes the outcome of an attempt to sync, and reports
+// when the error budget for sync freshness is used up, or recovers.
+func (d *Daemon) recordSync(err error) {
+	report, changed := d.syncHealth.record(time.Now().UTC(), err == nil, d.syncHealthWindow(), d.SyncObjective)
+	syncAttempts.With("success", fmt.Sprint(err == nil)).Add(1)
+	if err == nil {
+		lastSuccessfulSync.Set(float64(report.LastSuccess.Unix()))
+	}
+	if d.SyncObjective != nil {
+		syncBudgetRemaining.Set(report.BudgetRemaining)
+	}
+	if !changed {
+		return
+	}
+	if !report.BudgetExhausted() {
+		d.Logger.Log("recovered", syncFreshnessComponent)
+		return
+	}
+	now := time.Now().UTC()
+	if err := d.LogEvent(history.Event{
+		Type:      history.EventFailure,
+		StartedAt: now,
+		EndedAt:   now,
+		LogLevel:  history.LogLevelError,
+		Metadata: &history.FailureEventMetadata{
+			Component: syncFreshnessComponent,
+			Error: fmt.Sprintf("error budget for sync freshness exhausted: the cluster has been more than %s out of date for %s of the last %s",
+				report.Objective.Freshness, report.StaleTime, report.Window),
+		},
+	}); err != nil {
+		d.Logger.Log("err", err)
+	}
+}
+
+func (d *Daemon) syncHealthWindow() time.Duration {
+	if d.SyncHealthWindow > 0 {
+		return d.SyncHealthWindow
+	}
+	return DefaultSyncHealthWindow
+}
+
+// SyncHealth reports how well the cluster has been kept in sync with
+// the repo over the last while.
+func (d *Daemon) SyncHealth(ctx context.Context) (remote.SyncHealth, error) {
+	return d.syncHealth.get(time.Now().UTC(), d.syncHealthWindow(), d.SyncObjective), nil
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/remote"
+)
+
+func TestSyncHealth(t *testing.T) {
+	var h syncHealth
+	window := 8 * time.Hour
+	objective := &remote.SyncObjective{Freshness: time.Hour, Target: 0.875} // budget of one hour
+	start := time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time {
+		return start.Add(time.Duration(hours * float64(time.Hour)))
+	}
+
+	// Syncing successfully every half hour keeps within the objective
+	for i := 0; i <= 4; i++ {
+		report, changed := h.record(at(float64(i)/2), true, window, objective)
+		if changed || report.StaleTime != 0 || report.BudgetRemaining != 1 {
+			t.Fatalf("unexpected report after %d syncs: %+v, changed: %v", i+1, report, changed)
+		}
+	}
+
+	// Failing for an hour and a half uses up half the budget ...
+	report, changed := h.record(at(3.5), false, window, objective)
+	if changed || report.StaleTime != 30*time.Minute || report.BudgetRemaining != 0.5 {
+		t.Fatalf("unexpected report after failing: %+v, changed: %v", report, changed)
+	}
+	if report.Attempts != 6 || report.Successes != 5 || report.LastSuccess != at(2) || report.SinceLastSuccess != 90*time.Minute {
+		t.Errorf("unexpected counts after failing: %+v", report)
+	}
+
+	// ... and failing for another hour uses up the rest
+	report, changed = h.record(at(4.5), false, window, objective)
+	if !changed || !report.BudgetExhausted() {
+		t.Fatalf("expected budget to be exhausted: %+v, changed: %v", report, changed)
+	}
+
+	// Once the stale period drops out of the window, the budget is back
+	var recovered bool
+	for hours := 5.0; hours <= 16; hours += 0.5 {
+		report, changed = h.record(at(hours), true, window, objective)
+		recovered = recovered || changed
+	}
+	if !recovered || report.BudgetExhausted() || report.BudgetRemaining != 1 {
+		t.Fatalf("expected budget to recover: %+v", report)
+	}
+	if report.Attempts != 17 || report.LastSuccess != at(16) {
+		t.Errorf("expected only the attempts in the window: %+v", report)
+	}
+
+	// Without an objective, there's no budget to speak of
+	report = h.get(at(17), window, nil)
+	if report.Objective != nil || report.BudgetExhausted() || report.SuccessRate() != 1 {
+		t.Errorf("unexpected report without an objective: %+v", report)
+	}
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/resource"
+)
+
+// syncReports keeps the report of the most recent sync, so that
+// callers can wait for a sync to finish and find out how it went. The
+// zero value is ready to use.
+type syncReports struct {
+	sync.Mutex
+	last     remote.SyncReport
+	finished chan struct{}
+}
+
+// record keeps the report given, and tells anyone waiting that a
+// sync has finished.
+func (r *syncReports) record(report remote.SyncReport) {
+	r.Lock()
+	defer r.Unlock()
+	r.last = report
+	if r.finished != nil {
+		close(r.finished)
+	}
+	r.finished = make(chan struct{})
+}
+
+// since gives the report of the most recent sync, if it started at
+// or after the time given; otherwise, it gives a channel that will be
+// closed when the next sync finishes.
+func (r *syncReports) since(t time.Time) (remote.SyncReport, bool, <-chan struct{}) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.last.Started.IsZero() && !r.last.Started.Before(t) {
+		return r.last, true, nil
+	}
+	if r.finished == nil {
+		r.finished = make(chan struct{})
+	}
+	return remote.SyncReport{}, false, r.finished
+}
+
+// reportResources fills in how each of the resources given fared in
+// a sync. Those in changed are reported as applied, and the others as
+// unchanged; if changed is nil, which resources changed isn't known,
+// and all are reported as applied.
+func reportResources(report *remote.SyncReport, resources, changed map[string]resource.Resource, exclude cluster.KindFilter, err error) {
+	syncErrs, _ := err.(cluster.SyncError)
+	if err != nil && syncErrs == nil {
+		report.Error = err.Error()
+	}
+	for id, res := range resources {
+		kind, _, _ := resource.SplitID(id)
+		switch {
+		case res.Policy().Contains(policy.Ignore) || exclude.Excludes(kind):
+			report.Ignored = append(report.Ignored, id)
+		case syncErrs[id] != nil:
+			report.Failed = append(report.Failed, remote.ResourceError{ID: id, Error: syncErrs[id].Error()})
+		case report.Error != "":
+			// nothing was applied
+		case changed == nil:
+			report.Applied = append(report.Applied, id)
+		default:
+			if _, ok := changed[id]; ok {
+				report.Applied = append(report.Applied, id)
+			} else {
+				report.Unchanged = append(report.Unchanged, id)
+			}
+		}
+	}
+	sort.Strings(report.Applied)
+	sort.Strings(report.Unchanged)
+	sort.Strings(report.Ignored)
+	sort.Sort(resourceErrorsByID(report.Failed))
+}
+
+type resourceErrorsByID []remote.ResourceError
+
+func (s resourceErrorsByID) Len() int           { return len(s) }
+func (s resourceErrorsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s resourceErrorsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+// SyncWait asks for a sync, if the request is fresh, and waits for
+// one started since it was asked for to finish. If that takes too
+// long, it answers anyway with a report that isn't done, so that the
+// caller can ask again.
+func (d *Daemon) SyncWait(ctx context.Context, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	requested := req.Since
+	if requested.IsZero() {
+		requested = time.Now().UTC()
+		d.askForSync()
+	}
+	timeout := time.After(jobWaitTimeout)
+	for {
+		report, ok, finished := d.syncReports.since(requested)
+		if ok {
+			report.Requested, report.Done = requested, true
+			return report, nil
+		}
+		select {
+		case <-finished:
+		case <-timeout:
+			return remote.SyncReport{Requested: requested}, nil
+		case <-ctx.Done():
+			return remote.SyncReport{Requested: requested}, ctx.Err()
+		}
+	}
+}
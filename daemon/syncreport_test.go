@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/remote"
+)
+
+func TestSyncWait(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	d.Exclude = cluster.NewSharedKindFilter(cluster.KindFilter{"Service"})
+
+	hwID := "Deployment default/helloworld"
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		return cluster.SyncError{hwID: errors.New("no such kind")}
+	}
+
+	// Ask for a sync, and run it once it's been asked for
+	syncAndWait := func() remote.SyncReport {
+		reports := make(chan remote.SyncReport)
+		go func() {
+			report, err := d.SyncWait(context.Background(), remote.SyncWaitRequest{})
+			if err != nil {
+				t.Error(err)
+			}
+			reports <- report
+		}()
+		select {
+		case <-d.syncSoon:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a sync to be asked for")
+		}
+		if err := d.Checkout.Pull(); err != nil {
+			t.Fatal(err)
+		}
+		d.doSync(log.NewNopLogger())
+		return <-reports
+	}
+
+	report := syncAndWait()
+	head, err := d.Checkout.HeadRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Done || report.Converged() || report.Revision != head || report.Requested.After(report.Started) {
+		t.Errorf("expected a finished, unconverged sync of %s, got %+v", head, report)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].ID != hwID || report.Failed[0].Error != "no such kind" {
+		t.Errorf("expected %s to have failed, got %+v", hwID, report.Failed)
+	}
+	// With no sync tag yet, everything counts as changed
+	if len(report.Applied) != 2 || len(report.Unchanged) != 0 {
+		t.Errorf("expected the other deployments to be applied, got %+v", report)
+	}
+	if len(report.Ignored) != 3 || report.Ignored[0] != "Service default/helloworld" {
+		t.Errorf("expected the excluded services to be ignored, got %+v", report.Ignored)
+	}
+
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		return nil
+	}
+	report = syncAndWait()
+	if !report.Converged() {
+		t.Errorf("expected the second sync to converge, got %+v", report)
+	}
+	if len(report.Applied) != 0 || len(report.Unchanged) != 3 {
+		t.Errorf("expected nothing to have changed in the repo, got %+v", report)
+	}
+
+	// A sync asked for since the last one finished hasn't happened
+	// yet, so the report isn't done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	since := time.Now().UTC()
+	report, err = d.SyncWait(ctx, remote.SyncWaitRequest{Since: since})
+	if err != context.DeadlineExceeded || report.Done || !report.Requested.Equal(since) {
+		t.Errorf("expected to give up waiting for a sync, got %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// deferrals keeps the automated changes being held back until a
+// service's window opens, so that each deferral is recorded once
+// rather than at every image poll; and a timer for polling again when
+// the first window opens. The zero value is ready to use.
+type deferrals struct {
+	sync.Mutex
+	held  map[flux.ServiceID]string // the changes held, as a string to compare
+	timer *time.Timer
+}
+
+// hold records the changes held back for each service, and says which
+// services have changes that weren't already being held.
+func (h *deferrals) hold(changes map[flux.ServiceID]*update.Automated) []flux.ServiceID {
+	h.Lock()
+	defer h.Unlock()
+	held := map[flux.ServiceID]string{}
+	var fresh []flux.ServiceID
+	for id, c := range changes {
+		held[id] = changesKey(c)
+		if h.held[id] != held[id] {
+			fresh = append(fresh, id)
+		}
+	}
+	h.held = held
+	return fresh
+}
+
+// wakeAt arranges for f to be called at the time given, in place of
+// whatever was arranged before.
+func (h *deferrals) wakeAt(t time.Time, f func()) {
+	h.Lock()
+	defer h.Unlock()
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	h.timer = time.AfterFunc(t.Sub(time.Now()), f)
+}
+
+func changesKey(a *update.Automated) string {
+	var changes []string
+	for _, c := range a.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s", c.Container.Name, c.ImageID))
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, ",")
+}
+
+// holdForWindows takes out of the changes given those for services
+// that are outside their window for automated releases, and gives
+// back the rest to be released now. An event is logged for each
+// service the first time its changes are held back, and another
+// image poll is arranged for when the first window opens, so they
+// are released then.
+func (d *Daemon) holdForWindows(changes *update.Automated, services policy.ServiceMap, now time.Time, logger log.Logger) *update.Automated {
+	release := &update.Automated{}
+	held := map[flux.ServiceID]*update.Automated{}
+	for _, c := range changes.Changes {
+		window, ok := services[c.ServiceID].AutomationWindow()
+		if !ok || window.Contains(now) {
+			release.Add(c.ServiceID, c.Container, c.ImageID)
+			continue
+		}
+		if held[c.ServiceID] == nil {
+			held[c.ServiceID] = &update.Automated{}
+		}
+		held[c.ServiceID].Add(c.ServiceID, c.Container, c.ImageID)
+	}
+
+	var wake time.Time
+	for id := range held {
+		window, _ := services[id].AutomationWindow()
+		if opens := window.NextOpen(now); !opens.IsZero() && (wake.IsZero() || opens.Before(wake)) {
+			wake = opens
+		}
+	}
+	if !wake.IsZero() {
+		d.deferrals.wakeAt(wake, d.askForImagePoll)
+	}
+
+	for _, id := range d.deferrals.hold(held) {
+		window, _ := services[id].AutomationWindow()
+		until := window.NextOpen(now)
+		logger.Log("service", id, "deferred", "automated release", "window", window, "until", until)
+		if err := d.LogEvent(history.Event{
+			ServiceIDs: []flux.ServiceID{id},
+			Type:       history.EventDeferral,
+			StartedAt:  now,
+			EndedAt:    now,
+			LogLevel:   history.LogLevelInfo,
+			Metadata: &history.DeferralEventMetadata{
+				Spec:   *held[id],
+				Window: window.String(),
+				Until:  until,
+			},
+		}); err != nil {
+			logger.Log("err", err)
+		}
+	}
+	return release
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestHoldForWindows(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	windowed := flux.MakeServiceID("default", "helloworld")
+	always := flux.MakeServiceID("default", "test-service")
+	services := policy.ServiceMap{
+		windowed: policy.Set{policy.Automated: "true", policy.AutomateWindow: "Mon-Fri 09:00-17:00 UTC"},
+		always:   policy.Set{policy.Automated: "true"},
+	}
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	changes := &update.Automated{}
+	changes.Add(windowed, cluster.Container{Name: "greeter"}, image)
+	changes.Add(always, cluster.Container{Name: "test-service"}, image)
+
+	deferrals := func() []history.Event {
+		es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result []history.Event
+		for _, e := range es {
+			if e.Type == history.EventDeferral {
+				result = append(result, e)
+			}
+		}
+		return result
+	}
+	released := func(a *update.Automated) map[flux.ServiceID]bool {
+		ids := map[flux.ServiceID]bool{}
+		for _, c := range a.Changes {
+			ids[c.ServiceID] = true
+		}
+		return ids
+	}
+
+	saturday := time.Date(2017, 9, 9, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2017, 9, 11, 10, 0, 0, 0, time.UTC)
+
+	ids := released(d.holdForWindows(changes, services, saturday, log.NewNopLogger()))
+	if !ids[always] || ids[windowed] {
+		t.Errorf("expected only the service without a window to be released on Saturday, got %v", ids)
+	}
+	es := deferrals()
+	if len(es) != 1 {
+		t.Fatalf("expected one deferral, got %#v", es)
+	}
+	md := es[0].Metadata.(*history.DeferralEventMetadata)
+	if es[0].ServiceIDs[0] != windowed || !md.Until.Equal(time.Date(2017, 9, 11, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected deferral of %s until Monday morning, got %#v", windowed, es[0])
+	}
+
+	// The same changes held again aren't recorded again
+	d.holdForWindows(changes, services, saturday.Add(time.Hour), log.NewNopLogger())
+	if es := deferrals(); len(es) != 1 {
+		t.Errorf("expected deferral to be recorded once, got %#v", es)
+	}
+
+	ids = released(d.holdForWindows(changes, services, monday, log.NewNopLogger()))
+	if !ids[always] || !ids[windowed] {
+		t.Errorf("expected both services to be released on Monday, got %v", ids)
+	}
+}
@@ -0,0 +1,54 @@
+package flux
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Features are feature flags: whether each of a set of (usually
+// experimental) features is switched on. Features not mentioned are
+// off.
+type Features map[string]bool
+
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// Names gives the features that are switched on, in order.
+func (f Features) Names() []string {
+	var names []string
+	for name, on := range f {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFeatures reads feature flags as given on the command line:
+// each is either a feature name, which switches it on, or
+// "name=true" or "name=false".
+func ParseFeatures(flags []string) (Features, error) {
+	f := Features{}
+	for _, flag := range flags {
+		name, value := flag, "true"
+		if i := strings.Index(flag, "="); i >= 0 {
+			name, value = flag[:i], flag[i+1:]
+		}
+		if name == "" {
+			return nil, errors.Errorf("no feature name in %q", flag)
+		}
+		switch value {
+		case "true":
+			f[name] = true
+		case "false":
+			f[name] = false
+		default:
+			return nil, errors.Errorf("feature %q must be true or false, not %q", name, value)
+		}
+	}
+	return f, nil
+}
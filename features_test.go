@@ -0,0 +1,23 @@
+package flux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFeatures([]string{"prune", "self-update=false", "other=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Enabled("prune") || f.Enabled("self-update") || f.Enabled("missing") {
+		t.Errorf("unexpected features %v", f)
+	}
+	if names := f.Names(); !reflect.DeepEqual(names, []string{"other", "prune"}) {
+		t.Errorf("expected enabled features in order, got %v", names)
+	}
+
+	if _, err := ParseFeatures([]string{"prune=maybe"}); err == nil {
+		t.Error("expected error for non-boolean value")
+	}
+}
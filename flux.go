@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,6 +16,9 @@ var (
 	ErrInvalidServiceID = errors.New("invalid service ID")
 )
 
+// Token is a service token, as given to fluxctl and fluxd. It's
+// sent using the Scope-Probe authentication scheme; see
+// github.com/weaveworks/flux/http/auth for others.
 type Token string
 
 func (t Token) Set(req *http.Request) {
@@ -23,6 +27,11 @@ func (t Token) Set(req *http.Request) {
 	}
 }
 
+// Authenticate makes Token an api.Authenticator.
+func (t Token) Authenticate(req *http.Request) {
+	t.Set(req)
+}
+
 // (User) Service identifiers
 
 type ServiceID string // "default/helloworld"
@@ -155,9 +164,68 @@ type ServiceStatus struct {
 	Status     string
 	Automated  bool
 	Locked     bool
-	Ignore     bool
+	// LockedUser and LockedMsg say who locked the service and why,
+	// if that was recorded; LockedUntil is when the lock expires, if
+	// it does, or otherwise the zero time
+	LockedUser  string
+	LockedMsg   string
+	LockedUntil time.Time
+	Ignore      bool
+	// PausedUntil is when automation of the service resumes, if it
+	// is paused; otherwise it's the zero time
+	PausedUntil time.Time
+	// SyncError is the error from applying the service's manifests,
+	// if that failed in the most recent sync, and SyncErrorRevision
+	// the revision it failed at
+	SyncError         string
+	SyncErrorRevision string
+}
+
+// ServiceTopology relates a service, as defined in the manifests, to
+// the workloads it selects. A service may select any number of
+// workloads, and a workload may be selected by any number of
+// services.
+type ServiceTopology struct {
+	ID        ServiceID
+	File      string
+	Workloads []Workload
+}
+
+// Workload is a resource that runs pods; e.g., a Deployment or
+// DaemonSet. File is the manifest in which it is defined, relative
+// to the top of the manifests.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	File      string
 }
 
+// ResourceStatus reports a resource defined in the repo, workload or
+// otherwise, and how it stands with syncing. Namespace is empty for a
+// resource that isn't namespaced, e.g., a Namespace, ClusterRole or
+// CustomResourceDefinition. File is relative to the top of the
+// manifests.
+type ResourceStatus struct {
+	ID        string
+	Kind      string
+	Namespace string
+	Name      string
+	File      string
+	// Revision is the revision of the repo from which the resource
+	// was last applied successfully, or empty if it hasn't been
+	Revision string
+	Status   string
+	Error    string
+}
+
+const (
+	ResourcePending = "pending" // in the repo, but not synced yet
+	ResourceApplied = "applied"
+	ResourceFailed  = "failed"
+	ResourceIgnored = "ignored" // has the ignore policy, or is of an excluded kind
+)
+
 type Container struct {
 	Name      string
 	Current   Image
@@ -175,4 +243,80 @@ type GitRemoteConfig struct {
 type GitConfig struct {
 	Remote       GitRemoteConfig `json:"remote"`
 	PublicSSHKey ssh.PublicKey   `json:"publicSSHKey"`
+	// Diverged is set if the branch at the remote can't be
+	// fast-forwarded to from what the daemon has; until it's
+	// resolved, the daemon won't commit to the repo or sync from it.
+	Diverged *GitDivergence `json:"diverged,omitempty"`
+	// Layout says where the manifests for new resources are put
+	Layout RepoLayout `json:"layout"`
+}
+
+// GitDivergence says where the daemon's copy of the branch is, and
+// where the remote's is, when the latter doesn't follow on from the
+// former; e.g., because someone force-pushed.
+type GitDivergence struct {
+	Branch string `json:"branch"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// GitConflictPolicy says what to do when a commit the daemon is about
+// to push changes files that were also changed upstream, after the
+// daemon fetched and before it could push; usually because someone
+// edited the same manifest by hand.
+type GitConflictPolicy string
+
+const (
+	// Drop the daemon's commit, and leave the upstream changes as
+	// they are; automation will look again from there
+	ConflictPreferHuman GitConflictPolicy = "prefer-human"
+	// Put the daemon's commit on top of the upstream changes, taking
+	// its side wherever the two overlap
+	ConflictPreferAutomation GitConflictPolicy = "prefer-automation"
+	// Push nothing, fail the job, and report the conflict
+	ConflictAbort GitConflictPolicy = "abort"
+)
+
+func ParseGitConflictPolicy(s string) (GitConflictPolicy, error) {
+	switch p := GitConflictPolicy(s); p {
+	case ConflictPreferHuman, ConflictPreferAutomation, ConflictAbort:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown git conflict policy %q; expected %q, %q or %q", s, ConflictPreferHuman, ConflictPreferAutomation, ConflictAbort)
+}
+
+// GitConflict is a commit the daemon made clashing with changes
+// pushed upstream in the meantime, and what was done about it.
+type GitConflict struct {
+	Policy GitConflictPolicy `json:"policy"`
+	// The revision the branch had moved on to upstream
+	Upstream string `json:"upstream"`
+	// The files changed both by the daemon and upstream
+	Files []string `json:"files"`
+	// Whether the daemon's commit was pushed, on top of the
+	// upstream changes
+	Pushed bool `json:"pushed"`
+}
+
+// Outcome says what was done about the conflict, for messages.
+func (c GitConflict) Outcome() string {
+	switch {
+	case c.Pushed:
+		return "pushed anyway, keeping flux's side of the overlapping changes"
+	case c.Policy == ConflictPreferHuman:
+		return "dropped in favour of the upstream changes"
+	}
+	return "not pushed"
+}
+
+// ClusterConfig is how the daemon has been told to treat the cluster.
+type ClusterConfig struct {
+	// Kinds of resource that are neither synced nor exported
+	ExcludeKinds []string `json:"excludeKinds"`
+	// Features switched on in the daemon
+	Features []string `json:"features,omitempty"`
+	// The version of the instance config last applied by the daemon,
+	// and why the config given since then (if any) couldn't be
+	ConfigVersion string `json:"configVersion,omitempty"`
+	ConfigError   string `json:"configError,omitempty"`
 }
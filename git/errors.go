@@ -2,6 +2,10 @@ package git
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
 )
@@ -73,3 +77,114 @@ check the box to allow write access.
 `,
 	}}
 }
+
+// Diverged is the underlying error from DivergedError, saying where
+// each side of the branch is.
+type Diverged struct {
+	flux.GitDivergence
+}
+
+func (d *Diverged) Error() string {
+	return fmt.Sprintf("branch %q at the remote has diverged from the local copy (local %s, remote %s)", d.Branch, d.Local, d.Remote)
+}
+
+func DivergedError(url string, d flux.GitDivergence) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Err: &Diverged{d},
+		Help: `The branch in the git repository has been rewritten
+
+The branch ` + d.Branch + ` in the git repository
+
+    ` + url + `
+
+is at commit ` + d.Remote + `, which doesn't follow on from the
+commit flux last saw, ` + d.Local + `. This usually means someone
+has force-pushed to the branch.
+
+So that it doesn't commit on top of, or sync from, files that are out
+of date, flux will neither make changes to the repo nor sync it with
+the cluster until this is resolved.
+
+If the branch was rewritten on purpose, tell flux to carry on from
+the branch as it is now with
+
+    fluxctl reset-git
+
+Otherwise, put the branch back (e.g., by pushing ` + d.Local + `
+to it again), and flux will carry on by itself.
+`,
+	}}
+}
+
+// IsDiverged tells whether the error given is, or wraps, an error
+// from DivergedError.
+func IsDiverged(err error) bool {
+	if helpful, ok := pkgerrors.Cause(err).(flux.HelpfulError); ok {
+		_, ok := helpful.Base().Err.(*Diverged)
+		return ok
+	}
+	return false
+}
+
+// Conflict is the underlying error from ConflictError, saying which
+// files were changed both by flux and upstream.
+type Conflict struct {
+	flux.GitConflict
+}
+
+func (c *Conflict) Error() string {
+	return fmt.Sprintf("changes pushed upstream (at %s) also changed %s; commit %s", c.Upstream, strings.Join(c.Files, ", "), c.Outcome())
+}
+
+func ConflictError(url string, c flux.GitConflict) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Err: &Conflict{c},
+		Help: `Changes to the same files were pushed while flux was committing
+
+While flux was making a commit to the git repository
+
+    ` + url + `
+
+someone else pushed changes to some of the same files:
+
+    ` + strings.Join(c.Files, "\n    ") + `
+
+Rather than overwrite those changes, flux has not pushed its commit.
+Check that the files are as they should be; automation will carry on
+from the changes that were pushed, and releases and policy updates
+can be tried again.
+
+To have flux resolve this by itself in future, start fluxd with
+--git-conflict-policy=prefer-human (to drop its own commit) or
+--git-conflict-policy=prefer-automation (to push its commit anyway,
+keeping its side of the overlapping changes).
+`,
+	}}
+}
+
+// ConflictFrom gives the conflict, if the error given is, or wraps,
+// an error from ConflictError.
+func ConflictFrom(err error) (flux.GitConflict, bool) {
+	if helpful, ok := pkgerrors.Cause(err).(flux.HelpfulError); ok {
+		if c, ok := helpful.Base().Err.(*Conflict); ok {
+			return c.GitConflict, true
+		}
+	}
+	return flux.GitConflict{}, false
+}
+
+func UnknownRefError(ref string, actual error) error {
+	return flux.Missing{&flux.BaseError{
+		Err: actual,
+		Help: `Revision not found in the git repository
+
+The git repository does not have a commit or tag named
+
+    ` + ref + `
+
+Only commits on the branch flux uses, and tags, can be looked at. If
+the revision was pushed recently, it may not have been fetched yet;
+please wait a moment and try again.
+`,
+	}}
+}
@@ -2,8 +2,12 @@ package gittest
 
 import (
 	"io/ioutil"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/weaveworks/flux"
@@ -122,3 +126,365 @@ func TestCheckout(t *testing.T) {
 	defer anotherCheckout.Clean()
 	check(checkout)
 }
+
+func TestDiffAndDiscard(t *testing.T) {
+	repo, cleanup := Repo(t)
+	defer cleanup()
+
+	checkout, err := repo.Clone(git.Config{
+		UserName:  "example",
+		UserEmail: "example@example.com",
+		SyncTag:   "flux-test",
+		NotesRef:  "fluxtest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkout.Clean()
+
+	diff, err := checkout.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("expected no diff in a fresh checkout, got %q", diff)
+	}
+
+	var changedFile, original string
+	for file, contents := range testfiles.Files {
+		changedFile, original = file, contents
+		break
+	}
+	path := filepath.Join(checkout.ManifestDir(), changedFile)
+	if err := ioutil.WriteFile(path, []byte("CHANGED\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err = checkout.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, changedFile) || !strings.Contains(diff, "+CHANGED") {
+		t.Errorf("expected diff to show change to %s, got %q", changedFile, diff)
+	}
+
+	if err := checkout.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != original {
+		t.Errorf("expected change to be discarded, got %q", contents)
+	}
+}
+
+func TestPullDiverged(t *testing.T) {
+	repo, cleanup := Repo(t)
+	defer cleanup()
+	checkout, err := repo.Clone(git.Config{
+		UserName:  "example",
+		UserEmail: "example@example.com",
+		SyncTag:   "flux-test",
+		NotesRef:  "fluxtest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkout.Clean()
+
+	// Make a commit, so there's one to force-push away
+	working, err := checkout.WorkingClone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer working.Clean()
+	for file := range testfiles.Files {
+		if err := ioutil.WriteFile(filepath.Join(working.ManifestDir(), file), []byte("CHANGED"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	if err := working.CommitAndPush("Changed file", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkout.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	local, err := checkout.HeadRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the branch at the remote, as though someone had
+	// force-pushed
+	rewriteDir, rewriteCleanup := testfiles.TempDir(t)
+	defer rewriteCleanup()
+	rewrite := filepath.Join(rewriteDir, "repo")
+	for _, args := range [][]string{
+		{"clone", repo.URL, rewrite},
+		{"-C", rewrite, "reset", "--hard", "HEAD~1"},
+		{"-C", rewrite, "push", "--force", "origin", "master"},
+	} {
+		if err := execCommand("git", args...); err != nil {
+			t.Fatalf("git %v: %s", args, err)
+		}
+	}
+
+	err = checkout.Pull()
+	if !git.IsDiverged(err) {
+		t.Fatalf("expected diverged error from pull, got %v", err)
+	}
+	diverged := checkout.Diverged()
+	if diverged == nil || diverged.Local != local || diverged.Remote == local {
+		t.Fatalf("expected divergence from %s, got %+v", local, diverged)
+	}
+	if head, _ := checkout.HeadRevision(); head != local {
+		t.Errorf("expected checkout to stay at %s, got %s", local, head)
+	}
+
+	rev, err := checkout.ResetToRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != diverged.Remote {
+		t.Errorf("expected reset to %s, got %s", diverged.Remote, rev)
+	}
+	if checkout.Diverged() != nil {
+		t.Error("expected no divergence after reset")
+	}
+	if err := checkout.Pull(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResetTo(t *testing.T) {
+	checkout, cleanup := Checkout(t)
+	defer cleanup()
+
+	before, err := checkout.HeadRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changedFile, original string
+	for file, contents := range testfiles.Files {
+		changedFile, original = file, contents
+		break
+	}
+	if err := ioutil.WriteFile(filepath.Join(checkout.ManifestDir(), changedFile), []byte("CHANGED\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkout.CommitAndPush("Change a file", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	working, err := checkout.WorkingClone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer working.Clean()
+
+	rev, err := working.ResetTo(before[:7])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != before {
+		t.Errorf("expected to be at %s, got %s", before, rev)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(working.ManifestDir(), changedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != original {
+		t.Errorf("expected file as it was before the change, got %q", contents)
+	}
+
+	for _, ref := range []string{"no-such-revision", "--all"} {
+		_, err := working.ResetTo(ref)
+		if _, ok := err.(flux.Missing); !ok {
+			t.Errorf("expected missing error for %q, got %#v", ref, err)
+		}
+	}
+}
+
+// Test that manifests stored with Git LFS are fetched into the
+// checkout when asked for, and otherwise left as pointers. This needs
+// git-lfs, so is skipped where it's not installed.
+func TestCheckoutLFS(t *testing.T) {
+	if err := exec.Command("git", "lfs", "version").Run(); err != nil {
+		t.Skip("git-lfs is not installed")
+	}
+
+	newDir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	filesDir := filepath.Join(newDir, "files")
+	gitDir := filepath.Join(newDir, "git")
+
+	const manifest = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: big
+  namespace: default
+`
+	run := func(args ...string) {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	run("init", filesDir)
+	run("-C", filesDir, "lfs", "install", "--local")
+	run("-C", filesDir, "lfs", "track", "lfs/*.yaml")
+	if err := os.Mkdir(filepath.Join(filesDir, "lfs"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(filesDir, "lfs", "big.yaml"), []byte(manifest), 0666); err != nil {
+		t.Fatal(err)
+	}
+	run("-C", filesDir, "add", "--all")
+	run("-C", filesDir, "commit", "-m", "Add manifest stored with LFS")
+	run("clone", "--bare", filesDir, gitDir)
+	// Cloning doesn't bring the LFS objects along; they have to be
+	// pushed
+	run("-C", filesDir, "lfs", "push", "--all", "file://"+gitDir)
+
+	repo := git.Repo{
+		GitRemoteConfig: flux.GitRemoteConfig{
+			URL:    gitDir,
+			Branch: "master",
+		},
+	}
+	read := func(include []string) string {
+		checkout, err := repo.Clone(git.Config{
+			UserName:   "example",
+			UserEmail:  "example@example.com",
+			SyncTag:    "flux-test",
+			NotesRef:   "fluxtest",
+			LFSInclude: include,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer checkout.Clean()
+		contents, err := ioutil.ReadFile(filepath.Join(checkout.ManifestDir(), "lfs", "big.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(contents)
+	}
+
+	if contents := read([]string{"lfs/*"}); contents != manifest {
+		t.Errorf("expected the manifest stored with LFS to be fetched, got %q", contents)
+	}
+	if contents := read(nil); !strings.HasPrefix(contents, "version https://git-lfs.github.com/spec/") {
+		t.Errorf("expected a pointer to the manifest stored with LFS, got %q", contents)
+	}
+}
+
+// When someone else pushes while flux is committing, flux's commit
+// should go on top if the changes don't overlap, and otherwise be
+// dealt with according to the conflict policy.
+func TestCommitAndPushConflict(t *testing.T) {
+	var files []string
+	for file := range testfiles.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	fluxFile := files[0]
+
+	for _, c := range []struct {
+		policy    flux.GitConflictPolicy
+		humanFile string
+		conflict  bool
+		pushed    bool
+		contents  string
+	}{
+		{flux.ConflictAbort, files[1], false, true, "FLUX\n"},
+		{flux.ConflictPreferHuman, fluxFile, true, false, "HUMAN\n"},
+		{flux.ConflictPreferAutomation, fluxFile, true, true, "FLUX\n"},
+		{flux.ConflictAbort, fluxFile, true, false, "HUMAN\n"},
+	} {
+		func() {
+			repo, cleanup := Repo(t)
+			defer cleanup()
+			checkout, err := repo.Clone(git.Config{
+				UserName:       "example",
+				UserEmail:      "example@example.com",
+				SyncTag:        "flux-test",
+				NotesRef:       "fluxtest",
+				ConflictPolicy: c.policy,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer checkout.Clean()
+
+			working, err := checkout.WorkingClone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer working.Clean()
+			human, err := checkout.WorkingClone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer human.Clean()
+
+			if err := ioutil.WriteFile(filepath.Join(human.ManifestDir(), c.humanFile), []byte("HUMAN\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			if err := human.CommitAndPush("Human change", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ioutil.WriteFile(filepath.Join(working.ManifestDir(), fluxFile), []byte("FLUX\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			note := git.Note{
+				JobID: job.ID("job1"),
+				Spec:  update.Spec{Type: update.Images, Spec: update.ReleaseSpec{}},
+			}
+			err = working.CommitAndPush("Flux change", &note)
+			_, aborted := git.ConflictFrom(err)
+			if aborted != (c.conflict && !c.pushed && c.policy == flux.ConflictAbort) {
+				t.Fatalf("%s, %s: unexpected error %v", c.policy, c.humanFile, err)
+			} else if err != nil && !aborted {
+				t.Fatalf("%s, %s: %v", c.policy, c.humanFile, err)
+			}
+
+			conflict := working.Conflict()
+			if (conflict != nil) != c.conflict {
+				t.Fatalf("%s, %s: expected conflict %v, got %+v", c.policy, c.humanFile, c.conflict, conflict)
+			}
+			if conflict != nil {
+				if conflict.Pushed != c.pushed || !reflect.DeepEqual(conflict.Files, []string{fluxFile}) || conflict.Policy != c.policy {
+					t.Errorf("%s, %s: unexpected conflict %+v", c.policy, c.humanFile, conflict)
+				}
+			}
+
+			if err := checkout.Pull(); err != nil {
+				t.Fatal(err)
+			}
+			contents, err := ioutil.ReadFile(filepath.Join(checkout.ManifestDir(), fluxFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != c.contents {
+				t.Errorf("%s, %s: expected %q, got %q", c.policy, c.humanFile, c.contents, contents)
+			}
+			head, err := checkout.HeadRevision()
+			if err != nil {
+				t.Fatal(err)
+			}
+			headNote, err := checkout.GetNote(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.pushed && (headNote == nil || headNote.JobID != note.JobID) {
+				t.Errorf("%s, %s: expected note on the rebased commit, got %+v", c.policy, c.humanFile, headNote)
+			}
+		}()
+	}
+}
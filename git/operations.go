@@ -60,10 +60,38 @@ func push(keyRing ssh.KeyRing, workingDir, upstream string, refs []string) error
 	return nil
 }
 
-// pull the specific ref from upstream
-func pull(keyRing ssh.KeyRing, workingDir, upstream, ref string) error {
-	if err := execGitCmd(workingDir, keyRing, nil, "pull", "--ff-only", upstream, ref); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("git pull --ff-only %s %s", upstream, ref))
+// fetch the branch from upstream, leaving it at FETCH_HEAD
+func fetchBranch(keyRing ssh.KeyRing, workingDir, upstream, branch string) error {
+	if err := execGitCmd(workingDir, keyRing, nil, "fetch", upstream, branch); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("git fetch %s %s", upstream, branch))
+	}
+	return nil
+}
+
+// isAncestor says whether ancestor is rev, or one of its ancestors
+func isAncestor(workingDir, ancestor, rev string) (bool, error) {
+	err := execGitCmd(workingDir, nil, nil, "merge-base", "--is-ancestor", ancestor, rev)
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case *exec.ExitError:
+		// it exits non-zero without saying anything, if not an
+		// ancestor; anything else will have come with a message
+		return false, nil
+	}
+	return false, errors.Wrap(err, "git merge-base --is-ancestor")
+}
+
+func fastForward(workingDir, rev string) error {
+	if err := execGitCmd(workingDir, nil, nil, "merge", "--ff-only", rev); err != nil {
+		return errors.Wrap(err, "git merge --ff-only "+rev)
+	}
+	return nil
+}
+
+func resetHard(workingDir, rev string) error {
+	if err := execGitCmd(workingDir, nil, nil, "reset", "--hard", rev); err != nil {
+		return errors.Wrap(err, "git reset --hard "+rev)
 	}
 	return nil
 }
@@ -76,6 +104,15 @@ func fetch(keyRing ssh.KeyRing, workingDir, upstream, refspec string) error {
 	return nil
 }
 
+// lfsPull fetches the files stored with Git LFS that match the
+// patterns given, and checks them out in place of their pointers.
+func lfsPull(keyRing ssh.KeyRing, workingDir string, include []string) error {
+	if err := execGitCmd(workingDir, keyRing, nil, "lfs", "pull", "--include", strings.Join(include, ",")); err != nil {
+		return errors.Wrap(err, "git lfs pull")
+	}
+	return nil
+}
+
 func refExists(workingDir, ref string) (bool, error) {
 	if err := execGitCmd(workingDir, nil, nil, "rev-list", ref); err != nil {
 		if strings.Contains(err.Error(), "unknown revision") {
@@ -166,6 +203,54 @@ func changedFiles(path, subPath, ref string) ([]string, error) {
 	return splitList(out.String()), nil
 }
 
+// filesChanged lists the files that differ between two revisions.
+func filesChanged(workingDir, from, to string) ([]string, error) {
+	out := &bytes.Buffer{}
+	if err := execGitCmd(workingDir, nil, out, "diff", "--name-only", from, to); err != nil {
+		return nil, errors.Wrap(err, "git diff --name-only")
+	}
+	return splitList(out.String()), nil
+}
+
+// rebase replays the commits made on HEAD on top of the revision
+// given, passing any options on to git rebase. If that can't be done,
+// the rebase is abandoned, leaving HEAD where it was.
+func rebase(workingDir, onto string, options ...string) error {
+	args := append([]string{"rebase"}, options...)
+	args = append(args, onto)
+	if err := execGitCmd(workingDir, nil, nil, args...); err != nil {
+		execGitCmd(workingDir, nil, nil, "rebase", "--abort")
+		return errors.Wrap(err, "git rebase "+onto)
+	}
+	return nil
+}
+
+func removeNote(workingDir, notesRef, rev string) error {
+	return execGitCmd(workingDir, nil, nil, "notes", "--ref", notesRef, "remove", rev)
+}
+
+// diff gives the uncommitted changes to files in the subdirectory, as
+// a patch.
+func diff(workingDir, subdir string) (string, error) {
+	out := &bytes.Buffer{}
+	if err := execGitCmd(workingDir, nil, out, "diff", "--", subdir); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// discard throws away uncommitted changes to files in the subdirectory.
+func discard(workingDir, subdir string) error {
+	return execGitCmd(workingDir, nil, nil, "checkout", "--", subdir)
+}
+
+// track makes new files known to git, so they're included in the
+// next commit (and in diffs) along with changes to existing files.
+func track(workingDir string, paths []string) error {
+	args := append([]string{"add", "--intent-to-add", "--"}, paths...)
+	return execGitCmd(workingDir, nil, nil, args...)
+}
+
 func execGitCmd(dir string, keyRing ssh.KeyRing, out io.Writer, args ...string) error {
 	//	println("git", strings.Join(args, " "))
 	c := exec.Command("git", args...)
@@ -189,13 +274,18 @@ func execGitCmd(dir string, keyRing ssh.KeyRing, out io.Writer, args ...string)
 	return err
 }
 
+// Files stored with Git LFS are left as pointers when checked out,
+// unless they're asked for with lfsPull; otherwise, if git-lfs is
+// installed, cloning would download every large file in the repo.
+const lfsSkipSmudge = "GIT_LFS_SKIP_SMUDGE=1"
+
 func env(keyRing ssh.KeyRing) []string {
 	base := `GIT_SSH_COMMAND=ssh -o LogLevel=error`
 	if keyRing == nil {
-		return []string{base}
+		return []string{base, lfsSkipSmudge}
 	}
 	_, privateKeyPath := keyRing.KeyPair()
-	return []string{fmt.Sprintf("%s -i %q", base, privateKeyPath), "GIT_TERMINAL_PROMPT=0"}
+	return []string{fmt.Sprintf("%s -i %q", base, privateKeyPath), "GIT_TERMINAL_PROMPT=0", lfsSkipSmudge}
 }
 
 // check returns true if there are changes locally.
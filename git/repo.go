@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/weaveworks/flux"
@@ -27,6 +29,10 @@ type Checkout struct {
 	Dir  string
 	Config
 	realNotesRef string
+	// set when the branch at the remote can't be fast-forwarded to
+	diverged *flux.GitDivergence
+	// set when the last commit pushed ran into changes made upstream
+	conflict *flux.GitConflict
 	sync.RWMutex
 }
 
@@ -37,6 +43,15 @@ type Config struct {
 	NotesRef  string
 	UserName  string
 	UserEmail string
+	// LFSInclude gives the patterns (as understood by git-lfs) of
+	// files stored with Git LFS that should be fetched into the
+	// checkout. Any others are left as pointers, which is all that's
+	// needed when the repo keeps large files alongside the manifests.
+	LFSInclude []string
+	// ConflictPolicy says what to do when a commit to be pushed
+	// changes files that were changed upstream in the meantime; if
+	// empty, it's flux.ConflictAbort.
+	ConflictPolicy flux.GitConflictPolicy
 }
 
 // Get a local clone of the upstream repo, and use the config given.
@@ -69,12 +84,16 @@ func (r Repo) Clone(c Config) (*Checkout, error) {
 		return nil, err
 	}
 
-	return &Checkout{
+	checkout := &Checkout{
 		repo:         r,
 		Dir:          repoDir,
 		Config:       c,
 		realNotesRef: notesRef,
-	}, nil
+	}
+	if err := checkout.pullLFS(); err != nil {
+		return nil, err
+	}
+	return checkout, nil
 }
 
 // WorkingClone makes a(nother) clone of the repository to use for
@@ -124,26 +143,116 @@ func (c *Checkout) ManifestDir() string {
 
 // CommitAndPush commits changes made in this checkout, along with any
 // extra data as a note, and pushes the commit and note to the remote repo.
+//
+// If the branch has moved on at the remote since the checkout was
+// made, the commit is rebased onto it and pushed again, so long as
+// the commits upstream changed none of the same files. If they did,
+// the ConflictPolicy says what happens: with prefer-automation, the
+// commit is rebased anyway, keeping its side of the overlapping
+// changes; with prefer-human, the commit is dropped and the error is
+// nil; otherwise, the error is a ConflictError. Conflict says what
+// was done, in each case.
 func (c *Checkout) CommitAndPush(commitMessage string, note *Note) error {
 	c.Lock()
 	defer c.Unlock()
+	c.conflict = nil
 	if !check(c.Dir, c.repo.Path) {
 		return ErrNoChanges
 	}
 	if err := commit(c.Dir, commitMessage); err != nil {
 		return err
 	}
+	if err := c.addHeadNote(note); err != nil {
+		return err
+	}
 
+	pushErr := c.push()
+	if pushErr == nil {
+		return nil
+	}
+	upstream, overlap, err := c.upstreamChanges()
+	if err != nil {
+		return err
+	}
+	if upstream == "" {
+		// It wasn't refused for being behind; or if it was, the
+		// branch was rewritten, which the next pull will report
+		return PushError(c.repo.URL, pushErr)
+	}
+
+	var options []string
+	if len(overlap) > 0 {
+		policy := c.ConflictPolicy
+		if policy == "" {
+			policy = flux.ConflictAbort
+		}
+		c.conflict = &flux.GitConflict{
+			Policy:   policy,
+			Upstream: upstream,
+			Files:    overlap,
+		}
+		switch policy {
+		case flux.ConflictPreferHuman:
+			return nil
+		case flux.ConflictPreferAutomation:
+			// In a rebase, "theirs" is the commit being replayed
+			options = []string{"-X", "theirs"}
+		default:
+			return ConflictError(c.repo.URL, *c.conflict)
+		}
+	}
+
+	old, err := refRevision(c.Dir, "HEAD")
+	if err != nil {
+		return err
+	}
+	if err := rebase(c.Dir, upstream, options...); err != nil {
+		if c.conflict != nil {
+			return ConflictError(c.repo.URL, *c.conflict)
+		}
+		return err
+	}
 	if note != nil {
-		rev, err := refRevision(c.Dir, "HEAD")
-		if err != nil {
+		if err := removeNote(c.Dir, c.realNotesRef, old); err != nil {
 			return err
 		}
-		if err := addNote(c.Dir, rev, c.realNotesRef, note); err != nil {
+		if err := c.addHeadNote(note); err != nil {
 			return err
 		}
 	}
+	if err := c.push(); err != nil {
+		return PushError(c.repo.URL, err)
+	}
+	if c.conflict != nil {
+		c.conflict.Pushed = true
+	}
+	return nil
+}
+
+// Conflict says what happened, if the last commit pushed ran into
+// changes made upstream to the same files; otherwise it's nil.
+func (c *Checkout) Conflict() *flux.GitConflict {
+	c.RLock()
+	defer c.RUnlock()
+	return c.conflict
+}
+
+// addHeadNote puts the note, if there is one, on the HEAD commit;
+// c.Lock must be held.
+func (c *Checkout) addHeadNote(note *Note) error {
+	if note == nil {
+		return nil
+	}
+	rev, err := refRevision(c.Dir, "HEAD")
+	if err != nil {
+		return err
+	}
+	return addNote(c.Dir, rev, c.realNotesRef, note)
+}
 
+// push sends the branch, and the notes if there are any, to the
+// remote; c.Lock must be held.
+func (c *Checkout) push() error {
 	refs := []string{c.repo.Branch}
 	ok, err := refExists(c.Dir, c.realNotesRef)
 	if ok {
@@ -151,11 +260,49 @@ func (c *Checkout) CommitAndPush(commitMessage string, note *Note) error {
 	} else if err != nil {
 		return err
 	}
+	return push(c.repo.KeyRing, c.Dir, c.repo.URL, refs)
+}
 
-	if err := push(c.repo.KeyRing, c.Dir, c.repo.URL, refs); err != nil {
-		return PushError(c.repo.URL, err)
+// upstreamChanges fetches the branch from the remote and, if it has
+// moved on from the commit at HEAD was made on, gives the revision it
+// is at and the files changed both there and at HEAD. If the remote
+// hasn't moved on (or has been rewritten), the revision is empty.
+// c.Lock must be held.
+func (c *Checkout) upstreamChanges() (string, []string, error) {
+	if err := fetchBranch(c.repo.KeyRing, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
+		return "", nil, err
 	}
-	return nil
+	upstream, err := refRevision(c.Dir, "FETCH_HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	if behind, err := isAncestor(c.Dir, upstream, "HEAD"); err != nil || behind {
+		return "", nil, err
+	}
+	if follows, err := isAncestor(c.Dir, "HEAD^", upstream); err != nil || !follows {
+		return "", nil, err
+	}
+
+	theirs, err := filesChanged(c.Dir, "HEAD^", upstream)
+	if err != nil {
+		return "", nil, err
+	}
+	ours, err := filesChanged(c.Dir, "HEAD^", "HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	changed := map[string]bool{}
+	for _, f := range theirs {
+		changed[f] = true
+	}
+	var overlap []string
+	for _, f := range ours {
+		if changed[f] {
+			overlap = append(overlap, f)
+		}
+	}
+	sort.Strings(overlap)
+	return upstream, overlap, nil
 }
 
 // GetNote gets a note for the revision specified, or "" if there is no such note.
@@ -165,15 +312,119 @@ func (c *Checkout) GetNote(rev string) (*Note, error) {
 	return getNote(c.Dir, c.realNotesRef, rev)
 }
 
-// Pull fetches the latest commits on the branch we're using, and the latest notes
+// Pull fetches the latest commits on the branch we're using, and the
+// latest notes. If the branch at the remote doesn't follow on from
+// what we have (e.g., because it was force-pushed), nothing is
+// changed, and the error is a DivergedError; the checkout stays
+// diverged until the remote branch is put back, or ResetToRemote is
+// called.
 func (c *Checkout) Pull() error {
 	c.Lock()
 	defer c.Unlock()
-	if err := pull(c.repo.KeyRing, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
+	if err := fetchBranch(c.repo.KeyRing, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
 		return err
 	}
+	local, err := refRevision(c.Dir, "HEAD")
+	if err != nil {
+		return err
+	}
+	remote, err := refRevision(c.Dir, "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	ok, err := isAncestor(c.Dir, local, remote)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.diverged = &flux.GitDivergence{
+			Branch: c.repo.Branch,
+			Local:  local,
+			Remote: remote,
+		}
+		return DivergedError(c.repo.URL, *c.diverged)
+	}
+	c.diverged = nil
+	if err := fastForward(c.Dir, remote); err != nil {
+		return err
+	}
+	if err := c.pullLFS(); err != nil {
+		return err
+	}
+	return c.fetchRefs("")
+}
+
+// ResetToRemote makes the checkout the same as the branch at the
+// remote, whether or not that follows on from what we had, and so
+// gets the checkout out of being diverged. It returns the revision
+// now checked out.
+func (c *Checkout) ResetToRemote() (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := fetchBranch(c.repo.KeyRing, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
+		return "", err
+	}
+	remote, err := refRevision(c.Dir, "FETCH_HEAD")
+	if err != nil {
+		return "", err
+	}
+	if err := resetHard(c.Dir, remote); err != nil {
+		return "", err
+	}
+	if err := c.pullLFS(); err != nil {
+		return "", err
+	}
+	c.diverged = nil
+	// The notes may have been rewritten along with the branch
+	return remote, c.fetchRefs("+")
+}
+
+// ResetTo makes the checkout the same as the revision given, which
+// may be a commit or a tag; it's for looking at the files as they
+// were, in a working clone. It returns the revision (i.e., the commit
+// hash) checked out.
+func (c *Checkout) ResetTo(ref string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	// Don't let the ref be taken for an option
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return "", UnknownRefError(ref, errors.New("invalid revision"))
+	}
+	rev, err := refRevision(c.Dir, ref+"^{commit}")
+	if err != nil {
+		return "", UnknownRefError(ref, err)
+	}
+	if err := resetHard(c.Dir, rev); err != nil {
+		return "", err
+	}
+	return rev, nil
+}
+
+// pullLFS fetches the files stored with Git LFS that are wanted in
+// the checkout, if there are any. Working clones don't bother, since
+// they're for rewriting manifests, and the pointers are committed
+// back as they are.
+func (c *Checkout) pullLFS() error {
+	if len(c.LFSInclude) == 0 {
+		return nil
+	}
+	return lfsPull(c.repo.KeyRing, c.Dir, c.LFSInclude)
+}
+
+// Diverged returns where the local and remote branches are, if the
+// last pull found that they had diverged, or nil otherwise.
+func (c *Checkout) Diverged() *flux.GitDivergence {
+	c.RLock()
+	defer c.RUnlock()
+	return c.diverged
+}
+
+// fetchRefs gets the latest notes and sync tag; c.Lock must be
+// held. A prefix of "+" fetches the notes even if they don't follow
+// on from what we have.
+func (c *Checkout) fetchRefs(prefix string) error {
 	for _, ref := range []string{
-		c.realNotesRef + ":" + c.realNotesRef,
+		prefix + c.realNotesRef + ":" + c.realNotesRef,
 		c.SyncTag,
 	} {
 		// this fetches and updates the local ref, so we'll see the new
@@ -228,3 +479,31 @@ func (c *Checkout) ChangedFiles(ref string) ([]string, error) {
 	}
 	return list, err
 }
+
+// Diff gives the changes made to files in this checkout that haven't
+// been committed, as a patch.
+func (c *Checkout) Diff() (string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return diff(c.Dir, c.repo.Path)
+}
+
+// Track includes new files, given relative to the top of the
+// manifests, in what will be committed.
+func (c *Checkout) Track(paths ...string) error {
+	c.Lock()
+	defer c.Unlock()
+	var fullPaths []string
+	for _, path := range paths {
+		fullPaths = append(fullPaths, filepath.Join(c.ManifestDir(), path))
+	}
+	return track(c.Dir, fullPaths)
+}
+
+// Discard throws away any changes made to files in this checkout
+// that haven't been committed.
+func (c *Checkout) Discard() error {
+	c.Lock()
+	defer c.Unlock()
+	return discard(c.Dir, c.repo.Path)
+}
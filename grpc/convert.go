@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// Conversions between the flux types and their protobuf
+// counterparts.
+
+func imageToProto(im flux.Image) *Image {
+	var created string
+	if !im.CreatedAt.IsZero() {
+		created = im.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return &Image{Id: im.ID.String(), CreatedAt: created}
+}
+
+func containersToProto(containers []flux.Container) []*Container {
+	var res []*Container
+	for _, c := range containers {
+		pc := &Container{Name: c.Name, Current: imageToProto(c.Current)}
+		for _, im := range c.Available {
+			pc.Available = append(pc.Available, imageToProto(im))
+		}
+		res = append(res, pc)
+	}
+	return res
+}
+
+func servicesToProto(services []flux.ServiceStatus) []*ServiceStatus {
+	var res []*ServiceStatus
+	for _, s := range services {
+		res = append(res, &ServiceStatus{
+			Id:         s.ID.String(),
+			Containers: containersToProto(s.Containers),
+			Status:     s.Status,
+			Automated:  s.Automated,
+			Locked:     s.Locked,
+			Ignore:     s.Ignore,
+		})
+	}
+	return res
+}
+
+func imagesToProto(images []flux.ImageStatus) []*ImageStatus {
+	var res []*ImageStatus
+	for _, im := range images {
+		res = append(res, &ImageStatus{
+			Id:         im.ID.String(),
+			Containers: containersToProto(im.Containers),
+		})
+	}
+	return res
+}
+
+func causeFromProto(c *Cause) update.Cause {
+	if c == nil {
+		return update.Cause{}
+	}
+	return update.Cause{User: c.User, Message: c.Message}
+}
+
+func releaseSpecFromProto(req *UpdateImagesRequest) (update.ReleaseSpec, error) {
+	var spec update.ReleaseSpec
+	for _, s := range req.Services {
+		serviceSpec, err := update.ParseServiceSpec(s)
+		if err != nil {
+			return spec, err
+		}
+		spec.ServiceSpecs = append(spec.ServiceSpecs, serviceSpec)
+	}
+	imageSpec, err := update.ParseImageSpec(req.Image)
+	if err != nil {
+		return spec, err
+	}
+	spec.ImageSpec = imageSpec
+	kind, err := update.ParseReleaseKind(req.Kind)
+	if err != nil {
+		return spec, err
+	}
+	spec.Kind = kind
+	for _, ex := range req.Excludes {
+		id, err := flux.ParseServiceID(ex)
+		if err != nil {
+			return spec, err
+		}
+		spec.Excludes = append(spec.Excludes, id)
+	}
+	return spec, nil
+}
+
+func policySetFromProto(m map[string]string) policy.Set {
+	if len(m) == 0 {
+		return nil
+	}
+	set := policy.Set{}
+	for p, v := range m {
+		set[policy.Policy(p)] = v
+	}
+	return set
+}
+
+func policyUpdatesFromProto(updates map[string]*PolicyUpdate) (policy.Updates, error) {
+	res := policy.Updates{}
+	for s, u := range updates {
+		id, err := flux.ParseServiceID(s)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			u = &PolicyUpdate{}
+		}
+		res[id] = policy.Update{
+			Add:    policySetFromProto(u.Add),
+			Remove: policySetFromProto(u.Remove),
+		}
+	}
+	return res, nil
+}
+
+func jobStatusToProto(s job.Status) *JobStatus {
+	res := &JobStatus{
+		Status:   string(s.StatusString),
+		Error:    s.Err,
+		Revision: s.Result.Revision,
+	}
+	if len(s.Result.Result) > 0 {
+		res.Result = map[string]*ServiceResult{}
+		for id, r := range s.Result.Result {
+			sr := &ServiceResult{Status: string(r.Status), Error: r.Error}
+			for _, c := range r.PerContainer {
+				sr.PerContainer = append(sr.PerContainer, &ContainerUpdate{
+					Container: c.Container,
+					Current:   c.Current.String(),
+					Target:    c.Target.String(),
+				})
+			}
+			res.Result[id.String()] = sr
+		}
+	}
+	return res
+}
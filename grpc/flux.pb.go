@@ -0,0 +1,1026 @@
+// Code generated by protoc-gen-go.
+// source: flux.proto
+// DO NOT EDIT!
+
+/*
+Package grpc is a generated protocol buffer package.
+
+It is generated from these files:
+
+	flux.proto
+
+It has these top-level messages:
+
+	Image
+	Container
+	ServiceStatus
+	ListServicesRequest
+	ListServicesResponse
+	ImageStatus
+	ListImagesRequest
+	ListImagesResponse
+	Cause
+	UpdateImagesRequest
+	PolicyUpdate
+	UpdatePoliciesRequest
+	JobResponse
+	JobRequest
+	ContainerUpdate
+	ServiceResult
+	JobStatus
+	SyncStatusRequest
+	SyncStatusResponse
+	ExportRequest
+	ExportResponse
+*/
+package grpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc1 "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Image struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// RFC3339, or empty if not known
+	CreatedAt string `protobuf:"bytes,2,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+}
+
+func (m *Image) Reset()                    { *m = Image{} }
+func (m *Image) String() string            { return proto.CompactTextString(m) }
+func (*Image) ProtoMessage()               {}
+func (*Image) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *Image) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Image) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+type Container struct {
+	Name      string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Current   *Image   `protobuf:"bytes,2,opt,name=current" json:"current,omitempty"`
+	Available []*Image `protobuf:"bytes,3,rep,name=available" json:"available,omitempty"`
+}
+
+func (m *Container) Reset()                    { *m = Container{} }
+func (m *Container) String() string            { return proto.CompactTextString(m) }
+func (*Container) ProtoMessage()               {}
+func (*Container) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Container) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Container) GetCurrent() *Image {
+	if m != nil {
+		return m.Current
+	}
+	return nil
+}
+
+func (m *Container) GetAvailable() []*Image {
+	if m != nil {
+		return m.Available
+	}
+	return nil
+}
+
+type ServiceStatus struct {
+	Id         string       `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Containers []*Container `protobuf:"bytes,2,rep,name=containers" json:"containers,omitempty"`
+	Status     string       `protobuf:"bytes,3,opt,name=status" json:"status,omitempty"`
+	Automated  bool         `protobuf:"varint,4,opt,name=automated" json:"automated,omitempty"`
+	Locked     bool         `protobuf:"varint,5,opt,name=locked" json:"locked,omitempty"`
+	Ignore     bool         `protobuf:"varint,6,opt,name=ignore" json:"ignore,omitempty"`
+}
+
+func (m *ServiceStatus) Reset()                    { *m = ServiceStatus{} }
+func (m *ServiceStatus) String() string            { return proto.CompactTextString(m) }
+func (*ServiceStatus) ProtoMessage()               {}
+func (*ServiceStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ServiceStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ServiceStatus) GetContainers() []*Container {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+func (m *ServiceStatus) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ServiceStatus) GetAutomated() bool {
+	if m != nil {
+		return m.Automated
+	}
+	return false
+}
+
+func (m *ServiceStatus) GetLocked() bool {
+	if m != nil {
+		return m.Locked
+	}
+	return false
+}
+
+func (m *ServiceStatus) GetIgnore() bool {
+	if m != nil {
+		return m.Ignore
+	}
+	return false
+}
+
+type ListServicesRequest struct {
+	// Empty means all namespaces
+	Namespace string `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+}
+
+func (m *ListServicesRequest) Reset()                    { *m = ListServicesRequest{} }
+func (m *ListServicesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListServicesRequest) ProtoMessage()               {}
+func (*ListServicesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ListServicesRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type ListServicesResponse struct {
+	Services []*ServiceStatus `protobuf:"bytes,1,rep,name=services" json:"services,omitempty"`
+}
+
+func (m *ListServicesResponse) Reset()                    { *m = ListServicesResponse{} }
+func (m *ListServicesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListServicesResponse) ProtoMessage()               {}
+func (*ListServicesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ListServicesResponse) GetServices() []*ServiceStatus {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+type ImageStatus struct {
+	Id         string       `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Containers []*Container `protobuf:"bytes,2,rep,name=containers" json:"containers,omitempty"`
+}
+
+func (m *ImageStatus) Reset()                    { *m = ImageStatus{} }
+func (m *ImageStatus) String() string            { return proto.CompactTextString(m) }
+func (*ImageStatus) ProtoMessage()               {}
+func (*ImageStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ImageStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *ImageStatus) GetContainers() []*Container {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type ListImagesRequest struct {
+	// A service ID, or "<all>"
+	Service string `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
+}
+
+func (m *ListImagesRequest) Reset()                    { *m = ListImagesRequest{} }
+func (m *ListImagesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListImagesRequest) ProtoMessage()               {}
+func (*ListImagesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ListImagesRequest) GetService() string {
+	if m != nil {
+		return m.Service
+	}
+	return ""
+}
+
+type ListImagesResponse struct {
+	Images []*ImageStatus `protobuf:"bytes,1,rep,name=images" json:"images,omitempty"`
+}
+
+func (m *ListImagesResponse) Reset()                    { *m = ListImagesResponse{} }
+func (m *ListImagesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListImagesResponse) ProtoMessage()               {}
+func (*ListImagesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ListImagesResponse) GetImages() []*ImageStatus {
+	if m != nil {
+		return m.Images
+	}
+	return nil
+}
+
+type Cause struct {
+	User    string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *Cause) Reset()                    { *m = Cause{} }
+func (m *Cause) String() string            { return proto.CompactTextString(m) }
+func (*Cause) ProtoMessage()               {}
+func (*Cause) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Cause) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Cause) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type UpdateImagesRequest struct {
+	// Service IDs, or "<all>"
+	Services []string `protobuf:"bytes,1,rep,name=services" json:"services,omitempty"`
+	// An image ID, or "<all latest>"
+	Image string `protobuf:"bytes,2,opt,name=image" json:"image,omitempty"`
+	// "plan" or "execute"
+	Kind     string   `protobuf:"bytes,3,opt,name=kind" json:"kind,omitempty"`
+	Excludes []string `protobuf:"bytes,4,rep,name=excludes" json:"excludes,omitempty"`
+	Cause    *Cause   `protobuf:"bytes,5,opt,name=cause" json:"cause,omitempty"`
+}
+
+func (m *UpdateImagesRequest) Reset()                    { *m = UpdateImagesRequest{} }
+func (m *UpdateImagesRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdateImagesRequest) ProtoMessage()               {}
+func (*UpdateImagesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *UpdateImagesRequest) GetServices() []string {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+func (m *UpdateImagesRequest) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+func (m *UpdateImagesRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *UpdateImagesRequest) GetExcludes() []string {
+	if m != nil {
+		return m.Excludes
+	}
+	return nil
+}
+
+func (m *UpdateImagesRequest) GetCause() *Cause {
+	if m != nil {
+		return m.Cause
+	}
+	return nil
+}
+
+type PolicyUpdate struct {
+	Add    map[string]string `protobuf:"bytes,1,rep,name=add" json:"add,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Remove map[string]string `protobuf:"bytes,2,rep,name=remove" json:"remove,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PolicyUpdate) Reset()                    { *m = PolicyUpdate{} }
+func (m *PolicyUpdate) String() string            { return proto.CompactTextString(m) }
+func (*PolicyUpdate) ProtoMessage()               {}
+func (*PolicyUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *PolicyUpdate) GetAdd() map[string]string {
+	if m != nil {
+		return m.Add
+	}
+	return nil
+}
+
+func (m *PolicyUpdate) GetRemove() map[string]string {
+	if m != nil {
+		return m.Remove
+	}
+	return nil
+}
+
+type UpdatePoliciesRequest struct {
+	// Keyed by service ID
+	Updates map[string]*PolicyUpdate `protobuf:"bytes,1,rep,name=updates" json:"updates,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Cause   *Cause                   `protobuf:"bytes,2,opt,name=cause" json:"cause,omitempty"`
+	// Report what would change, rather than committing it
+	DryRun bool `protobuf:"varint,3,opt,name=dry_run,json=dryRun" json:"dry_run,omitempty"`
+}
+
+func (m *UpdatePoliciesRequest) Reset()                    { *m = UpdatePoliciesRequest{} }
+func (m *UpdatePoliciesRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePoliciesRequest) ProtoMessage()               {}
+func (*UpdatePoliciesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *UpdatePoliciesRequest) GetUpdates() map[string]*PolicyUpdate {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+func (m *UpdatePoliciesRequest) GetCause() *Cause {
+	if m != nil {
+		return m.Cause
+	}
+	return nil
+}
+
+func (m *UpdatePoliciesRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+type JobResponse struct {
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId" json:"job_id,omitempty"`
+}
+
+func (m *JobResponse) Reset()                    { *m = JobResponse{} }
+func (m *JobResponse) String() string            { return proto.CompactTextString(m) }
+func (*JobResponse) ProtoMessage()               {}
+func (*JobResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *JobResponse) GetJobId() string {
+	if m != nil {
+		return m.JobId
+	}
+	return ""
+}
+
+type JobRequest struct {
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId" json:"job_id,omitempty"`
+}
+
+func (m *JobRequest) Reset()                    { *m = JobRequest{} }
+func (m *JobRequest) String() string            { return proto.CompactTextString(m) }
+func (*JobRequest) ProtoMessage()               {}
+func (*JobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *JobRequest) GetJobId() string {
+	if m != nil {
+		return m.JobId
+	}
+	return ""
+}
+
+type ContainerUpdate struct {
+	Container string `protobuf:"bytes,1,opt,name=container" json:"container,omitempty"`
+	Current   string `protobuf:"bytes,2,opt,name=current" json:"current,omitempty"`
+	Target    string `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+}
+
+func (m *ContainerUpdate) Reset()                    { *m = ContainerUpdate{} }
+func (m *ContainerUpdate) String() string            { return proto.CompactTextString(m) }
+func (*ContainerUpdate) ProtoMessage()               {}
+func (*ContainerUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *ContainerUpdate) GetContainer() string {
+	if m != nil {
+		return m.Container
+	}
+	return ""
+}
+
+func (m *ContainerUpdate) GetCurrent() string {
+	if m != nil {
+		return m.Current
+	}
+	return ""
+}
+
+func (m *ContainerUpdate) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+type ServiceResult struct {
+	Status       string             `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Error        string             `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	PerContainer []*ContainerUpdate `protobuf:"bytes,3,rep,name=per_container,json=perContainer" json:"per_container,omitempty"`
+}
+
+func (m *ServiceResult) Reset()                    { *m = ServiceResult{} }
+func (m *ServiceResult) String() string            { return proto.CompactTextString(m) }
+func (*ServiceResult) ProtoMessage()               {}
+func (*ServiceResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *ServiceResult) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ServiceResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ServiceResult) GetPerContainer() []*ContainerUpdate {
+	if m != nil {
+		return m.PerContainer
+	}
+	return nil
+}
+
+type JobStatus struct {
+	// "queued", "running", "failed" or "succeeded"
+	Status string `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Error  string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// The commit made by the job, if it succeeded
+	Revision string `protobuf:"bytes,3,opt,name=revision" json:"revision,omitempty"`
+	// Keyed by service ID
+	Result map[string]*ServiceResult `protobuf:"bytes,4,rep,name=result" json:"result,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *JobStatus) Reset()                    { *m = JobStatus{} }
+func (m *JobStatus) String() string            { return proto.CompactTextString(m) }
+func (*JobStatus) ProtoMessage()               {}
+func (*JobStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *JobStatus) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *JobStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *JobStatus) GetRevision() string {
+	if m != nil {
+		return m.Revision
+	}
+	return ""
+}
+
+func (m *JobStatus) GetResult() map[string]*ServiceResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+type SyncStatusRequest struct {
+	Ref string `protobuf:"bytes,1,opt,name=ref" json:"ref,omitempty"`
+}
+
+func (m *SyncStatusRequest) Reset()                    { *m = SyncStatusRequest{} }
+func (m *SyncStatusRequest) String() string            { return proto.CompactTextString(m) }
+func (*SyncStatusRequest) ProtoMessage()               {}
+func (*SyncStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *SyncStatusRequest) GetRef() string {
+	if m != nil {
+		return m.Ref
+	}
+	return ""
+}
+
+type SyncStatusResponse struct {
+	Revisions []string `protobuf:"bytes,1,rep,name=revisions" json:"revisions,omitempty"`
+}
+
+func (m *SyncStatusResponse) Reset()                    { *m = SyncStatusResponse{} }
+func (m *SyncStatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*SyncStatusResponse) ProtoMessage()               {}
+func (*SyncStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *SyncStatusResponse) GetRevisions() []string {
+	if m != nil {
+		return m.Revisions
+	}
+	return nil
+}
+
+type ExportRequest struct {
+}
+
+func (m *ExportRequest) Reset()                    { *m = ExportRequest{} }
+func (m *ExportRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExportRequest) ProtoMessage()               {}
+func (*ExportRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+type ExportResponse struct {
+	Config []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+}
+
+func (m *ExportResponse) Reset()                    { *m = ExportResponse{} }
+func (m *ExportResponse) String() string            { return proto.CompactTextString(m) }
+func (*ExportResponse) ProtoMessage()               {}
+func (*ExportResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *ExportResponse) GetConfig() []byte {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Image)(nil), "flux.v6.Image")
+	proto.RegisterType((*Container)(nil), "flux.v6.Container")
+	proto.RegisterType((*ServiceStatus)(nil), "flux.v6.ServiceStatus")
+	proto.RegisterType((*ListServicesRequest)(nil), "flux.v6.ListServicesRequest")
+	proto.RegisterType((*ListServicesResponse)(nil), "flux.v6.ListServicesResponse")
+	proto.RegisterType((*ImageStatus)(nil), "flux.v6.ImageStatus")
+	proto.RegisterType((*ListImagesRequest)(nil), "flux.v6.ListImagesRequest")
+	proto.RegisterType((*ListImagesResponse)(nil), "flux.v6.ListImagesResponse")
+	proto.RegisterType((*Cause)(nil), "flux.v6.Cause")
+	proto.RegisterType((*UpdateImagesRequest)(nil), "flux.v6.UpdateImagesRequest")
+	proto.RegisterType((*PolicyUpdate)(nil), "flux.v6.PolicyUpdate")
+	proto.RegisterType((*UpdatePoliciesRequest)(nil), "flux.v6.UpdatePoliciesRequest")
+	proto.RegisterType((*JobResponse)(nil), "flux.v6.JobResponse")
+	proto.RegisterType((*JobRequest)(nil), "flux.v6.JobRequest")
+	proto.RegisterType((*ContainerUpdate)(nil), "flux.v6.ContainerUpdate")
+	proto.RegisterType((*ServiceResult)(nil), "flux.v6.ServiceResult")
+	proto.RegisterType((*JobStatus)(nil), "flux.v6.JobStatus")
+	proto.RegisterType((*SyncStatusRequest)(nil), "flux.v6.SyncStatusRequest")
+	proto.RegisterType((*SyncStatusResponse)(nil), "flux.v6.SyncStatusResponse")
+	proto.RegisterType((*ExportRequest)(nil), "flux.v6.ExportRequest")
+	proto.RegisterType((*ExportResponse)(nil), "flux.v6.ExportResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc1.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc1.SupportPackageIsVersion4
+
+// Client API for Flux service
+
+type FluxClient interface {
+	// List the services running in a namespace, or all namespaces.
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc1.CallOption) (*ListServicesResponse, error)
+	// List the images for the services given, and those available.
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc1.CallOption) (*ListImagesResponse, error)
+	// Start a job releasing images to services.
+	UpdateImages(ctx context.Context, in *UpdateImagesRequest, opts ...grpc1.CallOption) (*JobResponse, error)
+	// Start a job adding or removing policies for services.
+	UpdatePolicies(ctx context.Context, in *UpdatePoliciesRequest, opts ...grpc1.CallOption) (*JobResponse, error)
+	// Get the status of a job.
+	JobStatus(ctx context.Context, in *JobRequest, opts ...grpc1.CallOption) (*JobStatus, error)
+	// Get the status of a job each time it changes, until it finishes.
+	WatchJob(ctx context.Context, in *JobRequest, opts ...grpc1.CallOption) (Flux_WatchJobClient, error)
+	// List the commits up to a ref that are yet to be applied.
+	SyncStatus(ctx context.Context, in *SyncStatusRequest, opts ...grpc1.CallOption) (*SyncStatusResponse, error)
+	// Export the cluster's configuration.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc1.CallOption) (*ExportResponse, error)
+}
+
+type fluxClient struct {
+	cc *grpc1.ClientConn
+}
+
+func NewFluxClient(cc *grpc1.ClientConn) FluxClient {
+	return &fluxClient{cc}
+}
+
+func (c *fluxClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc1.CallOption) (*ListServicesResponse, error) {
+	out := new(ListServicesResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/ListServices", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc1.CallOption) (*ListImagesResponse, error) {
+	out := new(ListImagesResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/ListImages", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) UpdateImages(ctx context.Context, in *UpdateImagesRequest, opts ...grpc1.CallOption) (*JobResponse, error) {
+	out := new(JobResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/UpdateImages", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) UpdatePolicies(ctx context.Context, in *UpdatePoliciesRequest, opts ...grpc1.CallOption) (*JobResponse, error) {
+	out := new(JobResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/UpdatePolicies", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) JobStatus(ctx context.Context, in *JobRequest, opts ...grpc1.CallOption) (*JobStatus, error) {
+	out := new(JobStatus)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/JobStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) WatchJob(ctx context.Context, in *JobRequest, opts ...grpc1.CallOption) (Flux_WatchJobClient, error) {
+	stream, err := grpc1.NewClientStream(ctx, &_Flux_serviceDesc.Streams[0], c.cc, "/flux.v6.Flux/WatchJob", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fluxWatchJobClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Flux_WatchJobClient interface {
+	Recv() (*JobStatus, error)
+	grpc1.ClientStream
+}
+
+type fluxWatchJobClient struct {
+	grpc1.ClientStream
+}
+
+func (x *fluxWatchJobClient) Recv() (*JobStatus, error) {
+	m := new(JobStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *fluxClient) SyncStatus(ctx context.Context, in *SyncStatusRequest, opts ...grpc1.CallOption) (*SyncStatusResponse, error) {
+	out := new(SyncStatusResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/SyncStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc1.CallOption) (*ExportResponse, error) {
+	out := new(ExportResponse)
+	err := grpc1.Invoke(ctx, "/flux.v6.Flux/Export", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Flux service
+
+type FluxServer interface {
+	// List the services running in a namespace, or all namespaces.
+	ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error)
+	// List the images for the services given, and those available.
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	// Start a job releasing images to services.
+	UpdateImages(context.Context, *UpdateImagesRequest) (*JobResponse, error)
+	// Start a job adding or removing policies for services.
+	UpdatePolicies(context.Context, *UpdatePoliciesRequest) (*JobResponse, error)
+	// Get the status of a job.
+	JobStatus(context.Context, *JobRequest) (*JobStatus, error)
+	// Get the status of a job each time it changes, until it finishes.
+	WatchJob(*JobRequest, Flux_WatchJobServer) error
+	// List the commits up to a ref that are yet to be applied.
+	SyncStatus(context.Context, *SyncStatusRequest) (*SyncStatusResponse, error)
+	// Export the cluster's configuration.
+	Export(context.Context, *ExportRequest) (*ExportResponse, error)
+}
+
+func RegisterFluxServer(s *grpc1.Server, srv FluxServer) {
+	s.RegisterService(&_Flux_serviceDesc, srv)
+}
+
+func _Flux_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).ListServices(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/ListServices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).ListImages(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/ListImages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).ListImages(ctx, req.(*ListImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_UpdateImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).UpdateImages(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/UpdateImages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).UpdateImages(ctx, req.(*UpdateImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_UpdatePolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).UpdatePolicies(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/UpdatePolicies",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).UpdatePolicies(ctx, req.(*UpdatePoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_JobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).JobStatus(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/JobStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).JobStatus(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_WatchJob_Handler(srv interface{}, stream grpc1.ServerStream) error {
+	m := new(JobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FluxServer).WatchJob(m, &fluxWatchJobServer{stream})
+}
+
+type Flux_WatchJobServer interface {
+	Send(*JobStatus) error
+	grpc1.ServerStream
+}
+
+type fluxWatchJobServer struct {
+	grpc1.ServerStream
+}
+
+func (x *fluxWatchJobServer) Send(m *JobStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Flux_SyncStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).SyncStatus(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/SyncStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).SyncStatus(ctx, req.(*SyncStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Flux_Export_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc1.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServer).Export(ctx, in)
+	}
+	info := &grpc1.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.v6.Flux/Export",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServer).Export(ctx, req.(*ExportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Flux_serviceDesc = grpc1.ServiceDesc{
+	ServiceName: "flux.v6.Flux",
+	HandlerType: (*FluxServer)(nil),
+	Methods: []grpc1.MethodDesc{
+		{
+			MethodName: "ListServices",
+			Handler:    _Flux_ListServices_Handler,
+		},
+		{
+			MethodName: "ListImages",
+			Handler:    _Flux_ListImages_Handler,
+		},
+		{
+			MethodName: "UpdateImages",
+			Handler:    _Flux_UpdateImages_Handler,
+		},
+		{
+			MethodName: "UpdatePolicies",
+			Handler:    _Flux_UpdatePolicies_Handler,
+		},
+		{
+			MethodName: "JobStatus",
+			Handler:    _Flux_JobStatus_Handler,
+		},
+		{
+			MethodName: "SyncStatus",
+			Handler:    _Flux_SyncStatus_Handler,
+		},
+		{
+			MethodName: "Export",
+			Handler:    _Flux_Export_Handler,
+		},
+	},
+	Streams: []grpc1.StreamDesc{
+		{
+			StreamName:    "WatchJob",
+			Handler:       _Flux_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "flux.proto",
+}
+
+func init() { proto.RegisterFile("flux.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 981 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xdb, 0x6e, 0xdb, 0x46,
+	0x10, 0x05, 0x75, 0xa1, 0xc4, 0x91, 0xec, 0x24, 0x6b, 0xd9, 0x21, 0x18, 0x25, 0x70, 0xd9, 0x14,
+	0x30, 0x10, 0x57, 0x08, 0x94, 0xc6, 0xa8, 0x0b, 0xf4, 0x21, 0x0e, 0x1c, 0x20, 0x6e, 0x1f, 0x6a,
+	0x1a, 0x45, 0x81, 0xbe, 0x18, 0x2b, 0x72, 0xad, 0x32, 0x96, 0xb8, 0xea, 0x2e, 0xa9, 0x5a, 0x40,
+	0x7f, 0xa3, 0x8f, 0xfd, 0x90, 0xfe, 0x4d, 0xbf, 0xa0, 0xe8, 0x27, 0x14, 0x7b, 0x25, 0x69, 0xc9,
+	0x41, 0x0d, 0xe4, 0x8d, 0x33, 0x3b, 0x73, 0xe6, 0xb2, 0x67, 0x8f, 0x04, 0x70, 0x35, 0x2b, 0x6e,
+	0x46, 0x0b, 0x46, 0x73, 0x8a, 0x3a, 0xf2, 0x7b, 0x79, 0x14, 0x1e, 0x41, 0xfb, 0xfd, 0x1c, 0x4f,
+	0x09, 0xda, 0x86, 0x46, 0x9a, 0xf8, 0xce, 0xbe, 0x73, 0xe0, 0x45, 0x8d, 0x34, 0x41, 0x4f, 0x01,
+	0x62, 0x46, 0x70, 0x4e, 0x92, 0x4b, 0x9c, 0xfb, 0x0d, 0xe9, 0xf7, 0xb4, 0xe7, 0x4d, 0x1e, 0xfe,
+	0x06, 0xde, 0x5b, 0x9a, 0xe5, 0x38, 0xcd, 0x08, 0x43, 0x08, 0x5a, 0x19, 0x9e, 0x13, 0x9d, 0x2d,
+	0xbf, 0xd1, 0x01, 0x74, 0xe2, 0x82, 0x31, 0x92, 0xa9, 0xe4, 0xde, 0x78, 0x7b, 0xa4, 0x6b, 0x8e,
+	0x64, 0xc1, 0xc8, 0x1c, 0xa3, 0x43, 0xf0, 0xf0, 0x12, 0xa7, 0x33, 0x3c, 0x99, 0x11, 0xbf, 0xb9,
+	0xdf, 0xdc, 0x10, 0x5b, 0x06, 0x84, 0x7f, 0x39, 0xb0, 0x75, 0x41, 0xd8, 0x32, 0x8d, 0xc9, 0x45,
+	0x8e, 0xf3, 0x82, 0xaf, 0x75, 0x3e, 0x06, 0x88, 0x4d, 0x6b, 0xdc, 0x6f, 0x48, 0x40, 0x64, 0x01,
+	0x6d, 0xd7, 0x51, 0x25, 0x0a, 0xed, 0x81, 0xcb, 0x25, 0x9a, 0xdf, 0x94, 0x38, 0xda, 0x42, 0x43,
+	0xf0, 0x70, 0x91, 0xd3, 0xb9, 0x98, 0xda, 0x6f, 0xed, 0x3b, 0x07, 0xdd, 0xa8, 0x74, 0x88, 0xac,
+	0x19, 0x8d, 0xaf, 0x49, 0xe2, 0xb7, 0xe5, 0x91, 0xb6, 0x84, 0x3f, 0x9d, 0x66, 0x94, 0x11, 0xdf,
+	0x55, 0x7e, 0x65, 0x85, 0xaf, 0x60, 0xe7, 0xfb, 0x94, 0xe7, 0xba, 0x7d, 0x1e, 0x91, 0x5f, 0x0b,
+	0xc2, 0x73, 0x51, 0x44, 0xac, 0x8c, 0x2f, 0x70, 0x6c, 0x76, 0x58, 0x3a, 0xc2, 0x33, 0x18, 0xd4,
+	0x93, 0xf8, 0x82, 0x66, 0x9c, 0xa0, 0x31, 0x74, 0xb9, 0xf6, 0xf9, 0x8e, 0x1c, 0x72, 0xcf, 0x0e,
+	0x59, 0x5b, 0x50, 0x64, 0xe3, 0xc2, 0x73, 0xe8, 0xc9, 0x85, 0x7e, 0xba, 0xcd, 0x85, 0x5f, 0xc2,
+	0x23, 0xd1, 0x9e, 0x84, 0xb5, 0x13, 0xf9, 0xd0, 0xd1, 0x35, 0x35, 0xba, 0x31, 0xc3, 0x13, 0x40,
+	0xd5, 0x70, 0x3d, 0xcb, 0x21, 0xb8, 0xa9, 0xf4, 0xe8, 0x49, 0x06, 0xf5, 0xfb, 0xd7, 0x73, 0xe8,
+	0x98, 0xf0, 0x35, 0xb4, 0xdf, 0xe2, 0x82, 0x13, 0xc1, 0xbb, 0x82, 0x13, 0x66, 0x78, 0x27, 0xbe,
+	0x45, 0xe9, 0x39, 0xe1, 0x1c, 0x4f, 0x89, 0x26, 0xad, 0x31, 0xc3, 0x3f, 0x1d, 0xd8, 0xf9, 0x71,
+	0x91, 0xe0, 0x9c, 0xd4, 0x9b, 0x0d, 0x6e, 0x2d, 0xd2, 0x2b, 0x17, 0x86, 0x06, 0xd0, 0x4e, 0xe7,
+	0x25, 0x96, 0x32, 0x44, 0xdd, 0xeb, 0x34, 0x4b, 0x34, 0x57, 0xe4, 0xb7, 0x40, 0x21, 0x37, 0xf1,
+	0xac, 0x48, 0x08, 0xf7, 0x5b, 0x0a, 0xc5, 0xd8, 0xe8, 0x39, 0xb4, 0x63, 0xd1, 0xb0, 0xa4, 0x49,
+	0x95, 0xdd, 0x72, 0x8c, 0x48, 0x1d, 0x86, 0xff, 0x38, 0xd0, 0xff, 0x81, 0xce, 0xd2, 0x78, 0xa5,
+	0xba, 0x44, 0x2f, 0xa1, 0x89, 0x93, 0x44, 0xaf, 0xe4, 0x99, 0x4d, 0xaa, 0xc6, 0x8c, 0xde, 0x24,
+	0xc9, 0x69, 0x96, 0xb3, 0x55, 0x24, 0x42, 0xd1, 0x31, 0xb8, 0x8c, 0xcc, 0xe9, 0x92, 0xe8, 0xcb,
+	0xfb, 0x6c, 0x73, 0x52, 0x24, 0x63, 0x54, 0x9e, 0x4e, 0x08, 0x8e, 0xa0, 0x6b, 0xb0, 0xd0, 0x43,
+	0x68, 0x5e, 0x93, 0x95, 0x5e, 0xab, 0xf8, 0x14, 0x7b, 0x58, 0xe2, 0x59, 0x61, 0xf7, 0x20, 0x8d,
+	0x6f, 0x1a, 0x5f, 0x3b, 0xc1, 0x31, 0xf4, 0x2a, 0x70, 0xf7, 0x49, 0x0d, 0xff, 0x75, 0x60, 0x57,
+	0x75, 0x24, 0xbb, 0x4b, 0xcb, 0x2b, 0x39, 0x85, 0x4e, 0x21, 0x0f, 0x0c, 0x21, 0x5e, 0xd8, 0x41,
+	0x36, 0x26, 0x68, 0x2f, 0x57, 0x23, 0x99, 0xdc, 0x72, 0xef, 0x8d, 0x8f, 0xec, 0x1d, 0x3d, 0x86,
+	0x4e, 0xc2, 0x56, 0x97, 0xac, 0xc8, 0xe4, 0x85, 0x76, 0x23, 0x37, 0x61, 0xab, 0xa8, 0xc8, 0x82,
+	0x73, 0xe8, 0x57, 0x71, 0x37, 0xcc, 0xf6, 0xa2, 0x3a, 0x5b, 0x6f, 0xbc, 0xbb, 0x71, 0xdd, 0xd5,
+	0x91, 0x9f, 0x43, 0xef, 0x8c, 0x4e, 0x2c, 0xef, 0x77, 0xc1, 0xfd, 0x40, 0x27, 0x97, 0xf6, 0x11,
+	0xb6, 0x3f, 0xd0, 0xc9, 0xfb, 0x24, 0xfc, 0x1c, 0x40, 0x46, 0xa9, 0x65, 0xdc, 0x11, 0x84, 0xe1,
+	0x81, 0x7d, 0x91, 0x9a, 0x30, 0x43, 0xf0, 0xec, 0xcb, 0x34, 0x42, 0x62, 0x1d, 0xe2, 0x65, 0x54,
+	0x15, 0xd9, 0x2b, 0x15, 0x78, 0x0f, 0xdc, 0x1c, 0xb3, 0x29, 0xc9, 0x8d, 0xfa, 0x29, 0x2b, 0xfc,
+	0xdd, 0x4a, 0x6d, 0x44, 0x78, 0x31, 0xcb, 0x2b, 0x32, 0xe9, 0xd4, 0x64, 0x72, 0x00, 0x6d, 0xc2,
+	0x18, 0x65, 0xe6, 0x8e, 0xa5, 0x81, 0xbe, 0x85, 0xad, 0x05, 0x61, 0x97, 0x65, 0x4b, 0x4a, 0xdc,
+	0xfd, 0x75, 0x45, 0xd1, 0x8b, 0xea, 0x2f, 0x08, 0xb3, 0xbe, 0xf0, 0x6f, 0x07, 0xbc, 0x33, 0x3a,
+	0xd1, 0x5a, 0x75, 0xbf, 0xd2, 0x01, 0x74, 0x19, 0x59, 0xa6, 0x3c, 0xa5, 0x99, 0x9e, 0xc9, 0xda,
+	0xe8, 0x48, 0x3c, 0x12, 0x31, 0x8e, 0xdf, 0xba, 0xf5, 0xb2, 0x6c, 0xb5, 0x91, 0x9a, 0xd7, 0xbe,
+	0x10, 0x61, 0x04, 0xe7, 0x82, 0xe9, 0xd6, 0xbd, 0x81, 0x0d, 0x87, 0x75, 0x36, 0xac, 0xc9, 0xb1,
+	0xca, 0xae, 0xd2, 0xe1, 0x0b, 0x78, 0x74, 0xb1, 0xca, 0x62, 0xad, 0x6f, 0xfa, 0xbe, 0x1f, 0x42,
+	0x93, 0x91, 0x2b, 0x03, 0xcc, 0xc8, 0x55, 0x38, 0x06, 0x54, 0x0d, 0xd3, 0xe4, 0x19, 0x82, 0x67,
+	0x66, 0x32, 0xc2, 0x55, 0x3a, 0xc2, 0x07, 0xb0, 0x75, 0x7a, 0xb3, 0xa0, 0x2c, 0xd7, 0xb0, 0xe1,
+	0x01, 0x6c, 0x1b, 0x87, 0x06, 0xd8, 0x03, 0x37, 0xa6, 0xd9, 0x55, 0x3a, 0x95, 0xb5, 0xfa, 0x91,
+	0xb6, 0xc6, 0x7f, 0xb4, 0xa0, 0xf5, 0x6e, 0x56, 0xdc, 0xa0, 0xef, 0xa0, 0x5f, 0xfd, 0xe9, 0x41,
+	0x43, 0x3b, 0xd1, 0x86, 0x9f, 0xb1, 0xe0, 0xe9, 0x1d, 0xa7, 0xba, 0xda, 0x29, 0x40, 0xa9, 0xfc,
+	0x28, 0xa8, 0x05, 0xd7, 0x04, 0x39, 0x78, 0xb2, 0xf1, 0x4c, 0xc3, 0x9c, 0x98, 0x47, 0xa9, 0x81,
+	0x86, 0xb7, 0x94, 0xa1, 0x0e, 0x35, 0xa8, 0xde, 0xad, 0xc5, 0x78, 0x07, 0xdb, 0x75, 0x19, 0x41,
+	0xcf, 0x3e, 0xae, 0x2f, 0x77, 0xe0, 0x7c, 0x55, 0x25, 0xe8, 0x4e, 0x3d, 0x44, 0xe5, 0xa1, 0x75,
+	0x6e, 0xa1, 0xd7, 0xd0, 0xfd, 0x09, 0xe7, 0xf1, 0x2f, 0x67, 0x74, 0xf2, 0xbf, 0x93, 0x5e, 0x3a,
+	0x62, 0x7f, 0x25, 0x09, 0x2a, 0xfb, 0x5b, 0x23, 0x50, 0xf0, 0x64, 0xe3, 0x99, 0xee, 0xf9, 0x18,
+	0x5c, 0x45, 0x03, 0x54, 0xf2, 0xb3, 0x46, 0x94, 0xe0, 0xf1, 0x9a, 0x5f, 0xa5, 0x9e, 0xb8, 0x3f,
+	0xb7, 0xa6, 0x6c, 0x11, 0x4f, 0x5c, 0xf9, 0x1f, 0xf2, 0xd5, 0x7f, 0x03, 0x00, 0xd3, 0x80, 0xdd,
+	0xb0, 0x51, 0x0a, 0x00, 0x00,
+}
@@ -0,0 +1,200 @@
+package grpc
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	httpserver "github.com/weaveworks/flux/http/server"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// Server adapts the client API to the gRPC service definition, so it
+// can be served alongside the HTTP transport. Calls are checked by
+// the same Authenticator and Limiter as HTTP requests are.
+type Server struct {
+	service api.ClientService
+	authn   httpserver.Authenticator
+	limiter *httpserver.Limiter
+}
+
+var _ FluxServer = &Server{}
+
+func NewServer(s api.ClientService, authn httpserver.Authenticator, limiter *httpserver.Limiter) *Server {
+	return &Server{service: s, authn: authn, limiter: limiter}
+}
+
+// admit checks the credentials in the metadata of a call the same
+// way the HTTP transport checks those in the headers of a request
+// (gRPC metadata being HTTP/2 headers underneath), then checks the
+// instance is within its rate limit, and gives back the instance the
+// call is for.
+func admit(ctx context.Context, authn httpserver.Authenticator, limiter *httpserver.Limiter, role httpserver.Role) (service.InstanceID, error) {
+	r := &http.Request{Header: http.Header{}}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+	inst, err := authn.Authenticate(r, role)
+	switch {
+	case errors.Cause(err) == httpserver.ErrorForbidden:
+		return "", grpc.Errorf(codes.PermissionDenied, "%s", err.Error())
+	case err != nil:
+		return "", grpc.Errorf(codes.Unauthenticated, "%s", err.Error())
+	case inst == "":
+		inst = service.NoInstanceID
+	}
+	if ok, _ := limiter.Allow(inst); !ok {
+		return "", grpc.Errorf(codes.ResourceExhausted, "%s", httpserver.ErrorRateLimited.Error())
+	}
+	return inst, nil
+}
+
+// errorToStatus gives an error the gRPC status code that corresponds
+// to the HTTP status code it would get.
+func errorToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch e := errors.Cause(err).(type) {
+	case flux.Missing:
+		return grpc.Errorf(codes.NotFound, "%s", e.Error())
+	case flux.UserConfigProblem:
+		return grpc.Errorf(codes.FailedPrecondition, "%s", e.Error())
+	}
+	return grpc.Errorf(codes.Internal, "%s", err.Error())
+}
+
+func invalidArgument(err error) error {
+	return grpc.Errorf(codes.InvalidArgument, "%s", err.Error())
+}
+
+func (s *Server) ListServices(ctx context.Context, req *ListServicesRequest) (*ListServicesResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+	services, err := s.service.ListServices(ctx, inst, req.Namespace)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &ListServicesResponse{Services: servicesToProto(services)}, nil
+}
+
+func (s *Server) ListImages(ctx context.Context, req *ListImagesRequest) (*ListImagesResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := update.ParseServiceSpec(req.Service)
+	if err != nil {
+		return nil, invalidArgument(errors.Wrapf(err, "parsing service spec %q", req.Service))
+	}
+	images, err := s.service.ListImages(ctx, inst, spec)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &ListImagesResponse{Images: imagesToProto(images)}, nil
+}
+
+func (s *Server) UpdateImages(ctx context.Context, req *UpdateImagesRequest) (*JobResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleWrite)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := releaseSpecFromProto(req)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	id, err := s.service.UpdateImages(ctx, inst, spec, causeFromProto(req.Cause))
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &JobResponse{JobId: string(id)}, nil
+}
+
+func (s *Server) UpdatePolicies(ctx context.Context, req *UpdatePoliciesRequest) (*JobResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleWrite)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := policyUpdatesFromProto(req.Updates)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	id, err := s.service.UpdatePolicies(ctx, inst, updates, causeFromProto(req.Cause), req.DryRun)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &JobResponse{JobId: string(id)}, nil
+}
+
+func (s *Server) JobStatus(ctx context.Context, req *JobRequest) (*JobStatus, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.service.JobStatus(ctx, inst, job.ID(req.JobId))
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return jobStatusToProto(status), nil
+}
+
+func (s *Server) WatchJob(req *JobRequest, stream Flux_WatchJobServer) error {
+	ctx := stream.Context()
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return err
+	}
+	updates := make(chan job.Status)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.service.WatchJob(ctx, inst, job.ID(req.JobId), updates)
+	}()
+	for {
+		select {
+		case status := <-updates:
+			if err := stream.Send(jobStatusToProto(status)); err != nil {
+				return err
+			}
+		case err := <-errc:
+			return errorToStatus(err)
+		}
+	}
+}
+
+func (s *Server) SyncStatus(ctx context.Context, req *SyncStatusRequest) (*SyncStatusResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.service.SyncStatus(ctx, inst, req.Ref)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &SyncStatusResponse{Revisions: revisions}, nil
+}
+
+func (s *Server) Export(ctx context.Context, req *ExportRequest) (*ExportResponse, error) {
+	inst, err := admit(ctx, s.authn, s.limiter, httpserver.RoleRead)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.service.Export(ctx, inst)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+	return &ExportResponse{Config: config}, nil
+}
@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/http/auth"
+	httpserver "github.com/weaveworks/flux/http/server"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+)
+
+// Only the methods used in the tests are implemented; anything else
+// will panic.
+type mockService struct {
+	api.ClientService
+	instances []service.InstanceID
+}
+
+func (m *mockService) ListServices(_ context.Context, inst service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+	m.instances = append(m.instances, inst)
+	img, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000001")
+	return []flux.ServiceStatus{
+		{
+			ID: flux.MakeServiceID(namespace, "helloworld"),
+			Containers: []flux.Container{
+				{Name: "helloworld", Current: flux.Image{ID: img, CreatedAt: time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC)}},
+			},
+			Automated: true,
+		},
+	}, nil
+}
+
+func (m *mockService) JobStatus(context.Context, service.InstanceID, job.ID) (job.Status, error) {
+	return job.Status{}, flux.Missing{&flux.BaseError{Help: "no such job", Err: errors.New("no such job")}}
+}
+
+func (m *mockService) WatchJob(ctx context.Context, _ service.InstanceID, _ job.ID, updates chan<- job.Status) error {
+	for _, s := range []job.StatusString{job.StatusQueued, job.StatusRunning, job.StatusSucceeded} {
+		select {
+		case updates <- job.Status{StatusString: s}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+func setup(t *testing.T, authn httpserver.Authenticator, limiter *httpserver.Limiter) (FluxClient, *mockService, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockService{}
+	server := grpc.NewServer()
+	RegisterFluxServer(server, NewServer(mock, authn, limiter))
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFluxClient(conn), mock, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestServer_ListServices(t *testing.T) {
+	client, mock, cleanup := setup(t, httpserver.DefaultAuthenticator{}, nil)
+	defer cleanup()
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-scope-orgid", "instance"))
+	resp, err := client.ListServices(ctx, &ListServicesRequest{Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.instances) != 1 || mock.instances[0] != "instance" {
+		t.Errorf("expected instance ID to be passed from metadata, got %v", mock.instances)
+	}
+	if len(resp.Services) != 1 {
+		t.Fatalf("expected one service, got %+v", resp.Services)
+	}
+	s := resp.Services[0]
+	if s.Id != "default/helloworld" || !s.Automated {
+		t.Errorf("unexpected service %+v", s)
+	}
+	if len(s.Containers) != 1 || s.Containers[0].Current.CreatedAt != "2017-07-01T00:00:00Z" {
+		t.Errorf("unexpected containers %+v", s.Containers)
+	}
+}
+
+func TestServer_ErrorCodes(t *testing.T) {
+	client, _, cleanup := setup(t, httpserver.DefaultAuthenticator{}, nil)
+	defer cleanup()
+
+	_, err := client.JobStatus(context.Background(), &JobRequest{JobId: "nope"})
+	if code := grpc.Code(err); code != codes.NotFound {
+		t.Errorf("expected code %s, got %s (%v)", codes.NotFound, code, err)
+	}
+	_, err = client.ListImages(context.Background(), &ListImagesRequest{Service: "not a service"})
+	if code := grpc.Code(err); code != codes.InvalidArgument {
+		t.Errorf("expected code %s, got %s (%v)", codes.InvalidArgument, code, err)
+	}
+}
+
+func TestServer_WatchJob(t *testing.T) {
+	client, _, cleanup := setup(t, httpserver.DefaultAuthenticator{}, nil)
+	defer cleanup()
+
+	stream, err := client.WatchJob(context.Background(), &JobRequest{JobId: "job"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		status, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, status.Status)
+	}
+	expected := []string{"queued", "running", "succeeded"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected statuses %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected statuses %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestServer_AuthenticateAndLimit(t *testing.T) {
+	// A rate low enough that no tokens come back during the test
+	limiter := httpserver.NewLimiter(httpserver.RateLimit{RPS: 0.01, Burst: 1})
+	client, mock, cleanup := setup(t, httpserver.DefaultAuthenticator{Validator: auth.Bearer("secret")}, limiter)
+	defer cleanup()
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-scope-orgid", "instance"))
+	_, err := client.ListServices(ctx, &ListServicesRequest{})
+	if code := grpc.Code(err); code != codes.Unauthenticated {
+		t.Errorf("expected code %s without credentials, got %s (%v)", codes.Unauthenticated, code, err)
+	}
+	if len(mock.instances) != 0 {
+		t.Errorf("expected call without credentials not to reach the service, got %v", mock.instances)
+	}
+
+	ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-scope-orgid", "instance", "authorization", "Bearer secret"))
+	if _, err := client.ListServices(ctx, &ListServicesRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.ListServices(ctx, &ListServicesRequest{})
+	if code := grpc.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected code %s after burst, got %s (%v)", codes.ResourceExhausted, code, err)
+	}
+	if len(mock.instances) != 1 {
+		t.Errorf("expected call refused not to reach the service, got %v", mock.instances)
+	}
+}
@@ -0,0 +1,270 @@
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	httpserver "github.com/weaveworks/flux/http/server"
+	"github.com/weaveworks/flux/remote/rpc"
+)
+
+// The upstream service is how daemons connect to the service over
+// gRPC, as an alternative to the websocket. The daemon dials out and
+// opens a bidirectional stream, and the same RPC protocol as is
+// spoken over the websocket is carried in the stream's frames; so
+// it's equivalent to
+//
+//     message Frame { bytes data = 1; }
+//     service Upstream { rpc Connect(stream Frame) returns (stream Frame); }
+//
+// but written out here rather than generated, since the frames are
+// opaque to gRPC.
+
+// The metadata keys used when connecting: the daemon offers the
+// protocols it speaks, most preferred first, and the service answers
+// with the one it picked in the stream's header.
+const (
+	ProtocolsOfferedKey  = "flux-rpc-protocols"
+	ProtocolSelectedKey  = "flux-rpc-protocol"
+	authorizationKey     = "authorization"
+	upstreamConnectRoute = "/flux.v6.Upstream/Connect"
+)
+
+// Frame is a chunk of the RPC byte stream.
+type Frame struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Frame) Reset()         { *m = Frame{} }
+func (m *Frame) String() string { return proto.CompactTextString(m) }
+func (*Frame) ProtoMessage()    {}
+
+type UpstreamServer interface {
+	Connect(Upstream_ConnectServer) error
+}
+
+type Upstream_ConnectServer interface {
+	Send(*Frame) error
+	Recv() (*Frame, error)
+	grpc.ServerStream
+}
+
+type upstreamConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *upstreamConnectServer) Send(m *Frame) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *upstreamConnectServer) Recv() (*Frame, error) {
+	m := new(Frame)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Upstream_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UpstreamServer).Connect(&upstreamConnectServer{stream})
+}
+
+var _Upstream_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "flux.v6.Upstream",
+	HandlerType: (*UpstreamServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _Upstream_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "upstream.go",
+}
+
+func RegisterUpstreamServer(s *grpc.Server, srv UpstreamServer) {
+	s.RegisterService(&_Upstream_serviceDesc, srv)
+}
+
+type Upstream_ConnectClient interface {
+	Send(*Frame) error
+	Recv() (*Frame, error)
+	grpc.ClientStream
+}
+
+type upstreamConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *upstreamConnectClient) Send(m *Frame) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *upstreamConnectClient) Recv() (*Frame, error) {
+	m := new(Frame)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// frameStream is what the client and server ends of a Connect stream
+// have in common.
+type frameStream interface {
+	Send(*Frame) error
+	Recv() (*Frame, error)
+}
+
+// streamConn makes a stream of frames into a connection the RPC
+// client or server can use.
+type streamConn struct {
+	stream frameStream
+	close  func() error
+
+	mu  sync.Mutex // serialises sends
+	buf []byte     // what's left of the last frame received
+}
+
+func (c *streamConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		frame, err := c.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = frame.Data
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *streamConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The frame may be held on to until it's sent, so it needs its
+	// own copy.
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := c.stream.Send(&Frame{Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *streamConn) Close() error {
+	return c.close()
+}
+
+// Upstream accepts connections from daemons, and hands them to the
+// daemon service as platforms, as the websocket endpoint does. Each
+// connection is checked by the same Authenticator and Limiter as the
+// websocket endpoint uses, so (e.g.) daemon tokens are required here
+// if they are there.
+type Upstream struct {
+	daemons  api.DaemonService
+	authn    httpserver.Authenticator
+	limiter  *httpserver.Limiter
+	timeouts rpc.Timeouts
+}
+
+var _ UpstreamServer = &Upstream{}
+
+func NewUpstream(daemons api.DaemonService, authn httpserver.Authenticator, limiter *httpserver.Limiter, timeouts rpc.Timeouts) *Upstream {
+	return &Upstream{daemons: daemons, authn: authn, limiter: limiter, timeouts: timeouts}
+}
+
+func (u *Upstream) Connect(stream Upstream_ConnectServer) error {
+	ctx := stream.Context()
+	inst, err := admit(ctx, u.authn, u.limiter, httpserver.RoleDaemon)
+	if err != nil {
+		return err
+	}
+
+	// There's no redirecting a gRPC connection; the daemon will have
+	// to be pointed at the other service.
+	migratedTo, err := u.daemons.MigratedTo(inst)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	if migratedTo != "" {
+		return grpc.Errorf(codes.FailedPrecondition, "instance has moved to %s", migratedTo)
+	}
+
+	var offered []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		offered = md[ProtocolsOfferedKey]
+	}
+	protocol, err := rpc.SelectProtocol(offered)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	if err := stream.SendHeader(metadata.Pairs(ProtocolSelectedKey, protocol)); err != nil {
+		return err
+	}
+
+	// The stream is closed when this returns, so closing the
+	// connection needn't do anything itself.
+	conn := &streamConn{stream: stream, close: func() error { return nil }}
+	rpcClient, err := rpc.NewClient(protocol, conn, u.timeouts)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	defer rpcClient.Close()
+	// This blocks until the daemon disconnects
+	return errorToStatus(u.daemons.RegisterDaemon(inst, rpcClient))
+}
+
+// ConnectUpstream opens a stream to the upstream service at the
+// other end of the gRPC connection, offering the RPC protocols this
+// daemon speaks, and gives back a connection over which to serve the
+// platform, along with the protocol the service picked. Closing the
+// connection ends the stream.
+func ConnectUpstream(ctx context.Context, cc *grpc.ClientConn, token flux.Token) (io.ReadWriteCloser, string, error) {
+	md := metadata.MD{ProtocolsOfferedKey: rpc.Protocols}
+	if existing, ok := metadata.FromOutgoingContext(ctx); ok {
+		md = metadata.Join(existing, md)
+	}
+	if token != "" {
+		md[authorizationKey] = []string{fmt.Sprintf("Scope-Probe token=%s", token)}
+	}
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
+	s, err := grpc.NewClientStream(ctx, &_Upstream_serviceDesc.Streams[0], cc, upstreamConnectRoute)
+	if err != nil {
+		cancel()
+		return nil, "", err
+	}
+	header, err := s.Header()
+	if err != nil {
+		cancel()
+		return nil, "", errors.Wrap(err, "waiting for service to accept connection")
+	}
+	stream := &upstreamConnectClient{s}
+	p := header[ProtocolSelectedKey]
+	if len(p) == 0 {
+		// The service refused the connection without sending a
+		// header; the reason comes with the end of the stream.
+		_, err := stream.Recv()
+		cancel()
+		if err == nil || err == io.EOF {
+			err = errors.New("service did not select a protocol")
+		}
+		return nil, "", err
+	}
+	protocol := p[0]
+	return &streamConn{stream: stream, close: func() error {
+		err := stream.CloseSend()
+		cancel()
+		return err
+	}}, protocol, nil
+}
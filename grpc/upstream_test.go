@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	httpserver "github.com/weaveworks/flux/http/server"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/remote/rpc"
+	"github.com/weaveworks/flux/service"
+)
+
+// Only the methods used in the tests are implemented; anything else
+// will panic.
+type mockDaemons struct {
+	api.DaemonService
+	migratedTo string
+	platforms  chan remote.Platform
+	instances  chan service.InstanceID
+	done       chan struct{}
+}
+
+func (m *mockDaemons) MigratedTo(service.InstanceID) (string, error) {
+	return m.migratedTo, nil
+}
+
+func (m *mockDaemons) RegisterDaemon(inst service.InstanceID, p remote.Platform) error {
+	m.instances <- inst
+	m.platforms <- p
+	<-m.done
+	return nil
+}
+
+func setupUpstream(t *testing.T, daemons *mockDaemons, authn httpserver.Authenticator) (*grpc.ClientConn, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterUpstreamServer(server, NewUpstream(daemons, authn, nil, rpc.DefaultTimeouts))
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestUpstream_Connect(t *testing.T) {
+	daemons := &mockDaemons{
+		platforms: make(chan remote.Platform, 1),
+		instances: make(chan service.InstanceID, 1),
+		done:      make(chan struct{}),
+	}
+	defer close(daemons.done)
+	cc, cleanup := setupUpstream(t, daemons, httpserver.DefaultAuthenticator{})
+	defer cleanup()
+
+	// Stands in for the authenticating proxy
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-scope-orgid", "instance"))
+	conn, protocol, err := ConnectUpstream(ctx, cc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if protocol != rpc.Protocols[0] {
+		t.Errorf("expected most preferred protocol %q to be selected, got %q", rpc.Protocols[0], protocol)
+	}
+
+	// The daemon end serves the platform over the stream
+	server, err := rpc.NewServer(&remote.MockPlatform{VersionAnswer: "1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeProtocol(protocol, conn)
+
+	if inst := <-daemons.instances; inst != "instance" {
+		t.Errorf("expected daemon to be registered for instance from metadata, got %q", inst)
+	}
+	platform := <-daemons.platforms
+	version, err := platform.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "1.2.3" {
+		t.Errorf("expected version from daemon, got %q", version)
+	}
+}
+
+func TestUpstream_Migrated(t *testing.T) {
+	cc, cleanup := setupUpstream(t, &mockDaemons{migratedTo: "https://elsewhere.example.com/api/flux"}, httpserver.DefaultAuthenticator{})
+	defer cleanup()
+
+	_, _, err := ConnectUpstream(context.Background(), cc, "")
+	if code := grpc.Code(err); code != codes.FailedPrecondition {
+		t.Errorf("expected code %s, got %s (%v)", codes.FailedPrecondition, code, err)
+	}
+}
+
+type tokensMap map[service.InstanceID]string
+
+func (m tokensMap) ValidateDaemonToken(inst service.InstanceID, token string) error {
+	if t, ok := m[inst]; ok && t == token {
+		return nil
+	}
+	return errors.New("unknown token")
+}
+
+func TestUpstream_DaemonTokens(t *testing.T) {
+	daemons := &mockDaemons{
+		platforms: make(chan remote.Platform, 1),
+		instances: make(chan service.InstanceID, 1),
+		done:      make(chan struct{}),
+	}
+	defer close(daemons.done)
+	cc, cleanup := setupUpstream(t, daemons, httpserver.DefaultAuthenticator{
+		DaemonTokens: tokensMap{"instance": "daemon-secret"},
+	})
+	defer cleanup()
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-scope-orgid", "instance"))
+	for _, token := range []flux.Token{"", "not-a-token"} {
+		_, _, err := ConnectUpstream(ctx, cc, token)
+		if code := grpc.Code(err); code != codes.Unauthenticated {
+			t.Errorf("token %q: expected code %s, got %s (%v)", token, codes.Unauthenticated, code, err)
+		}
+	}
+
+	conn, _, err := ConnectUpstream(ctx, cc, "daemon-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if inst := <-daemons.instances; inst != "instance" {
+		t.Errorf("expected daemon to be registered for instance, got %q", inst)
+	}
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"sync"
+
+	"github.com/weaveworks/flux/service"
+)
+
+// How many events a watcher can fall behind by, before it starts
+// missing them.
+const watcherBuffer = 16
+
+// Broadcaster passes events on to whoever is watching for them, per
+// instance. It only knows about events published in this process.
+type Broadcaster struct {
+	mu       sync.Mutex
+	watchers map[service.InstanceID]map[chan Event]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		watchers: map[service.InstanceID]map[chan Event]struct{}{},
+	}
+}
+
+// Watch returns a channel on which the events published for the
+// instance will be sent, and a func to call when no longer
+// interested.
+func (b *Broadcaster) Watch(inst service.InstanceID) (<-chan Event, func()) {
+	c := make(chan Event, watcherBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchers[inst] == nil {
+		b.watchers[inst] = map[chan Event]struct{}{}
+	}
+	b.watchers[inst][c] = struct{}{}
+	return c, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.watchers[inst], c)
+		if len(b.watchers[inst]) == 0 {
+			delete(b.watchers, inst)
+		}
+	}
+}
+
+// Publish sends the event to each watcher of the instance. A watcher
+// that isn't keeping up misses the event, rather than holding
+// everyone else up.
+func (b *Broadcaster) Publish(inst service.InstanceID, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.watchers[inst] {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
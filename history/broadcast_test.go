@@ -0,0 +1,37 @@
+package history
+
+import (
+	"testing"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	watchA, cancelA := b.Watch("instance-a")
+	watchB, cancelB := b.Watch("instance-b")
+	defer cancelB()
+
+	b.Publish("instance-a", Event{Message: "hello"})
+	select {
+	case e := <-watchA:
+		if e.Message != "hello" {
+			t.Errorf("expected event with message %q, got %q", "hello", e.Message)
+		}
+	default:
+		t.Fatal("expected event for watcher of instance-a")
+	}
+	select {
+	case e := <-watchB:
+		t.Errorf("did not expect event for another instance, got %+v", e)
+	default:
+	}
+
+	// A watcher that has gone away should not receive anything, nor
+	// should publishing block
+	cancelA()
+	for i := 0; i < watcherBuffer+1; i++ {
+		b.Publish("instance-a", Event{})
+	}
+	if len(watchA) != 0 {
+		t.Errorf("expected no events after cancelling, got %d", len(watchA))
+	}
+}
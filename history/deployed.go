@@ -0,0 +1,74 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/update"
+)
+
+// DeployedImage records the image that a release put into a
+// container, and when that happened.
+type DeployedImage struct {
+	Container  string       `json:"container"`
+	ID         flux.ImageID `json:"id"`
+	DeployedAt time.Time    `json:"deployedAt"`
+	// The release event that deployed the image
+	EventID EventID `json:"eventID"`
+}
+
+// DeployedImages works out which image was running in each container
+// of a service at the time given, from the service's history. The
+// events must be in descending timestamp order, as they are returned
+// from an EventReader. Only releases (manual or automated) that
+// succeeded for the service are counted; changes made to the
+// manifests outside of flux don't show up in the history, so won't
+// be accounted for. Containers that haven't been released at or
+// before the time given are not included.
+func DeployedImages(events []Event, id flux.ServiceID, at time.Time) []DeployedImage {
+	seen := map[string]bool{}
+	var deployed []DeployedImage
+	for _, e := range events {
+		if e.StartedAt.After(at) {
+			continue
+		}
+		var result update.Result
+		switch m := e.Metadata.(type) {
+		case *ReleaseEventMetadata:
+			result = m.Result
+		case *AutoReleaseEventMetadata:
+			result = m.Result
+		default:
+			continue
+		}
+		serviceResult, ok := result[id]
+		if !ok || serviceResult.Status != update.ReleaseStatusSuccess {
+			continue
+		}
+		deployedAt := e.EndedAt
+		if deployedAt.IsZero() {
+			deployedAt = e.StartedAt
+		}
+		for _, c := range serviceResult.PerContainer {
+			if seen[c.Container] {
+				continue
+			}
+			seen[c.Container] = true
+			deployed = append(deployed, DeployedImage{
+				Container:  c.Container,
+				ID:         c.Target,
+				DeployedAt: deployedAt,
+				EventID:    e.ID,
+			})
+		}
+	}
+	sort.Sort(deployedByContainer(deployed))
+	return deployed
+}
+
+type deployedByContainer []DeployedImage
+
+func (d deployedByContainer) Len() int           { return len(d) }
+func (d deployedByContainer) Less(i, j int) bool { return d[i].Container < d[j].Container }
+func (d deployedByContainer) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,71 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/update"
+)
+
+func release(id EventID, at time.Time, svc flux.ServiceID, status update.ServiceUpdateStatus, container, image string) Event {
+	imageID, _ := flux.ParseImageID(image)
+	return Event{
+		ID:        id,
+		Type:      EventRelease,
+		StartedAt: at,
+		EndedAt:   at.Add(time.Second),
+		Metadata: &ReleaseEventMetadata{
+			ReleaseEventCommon: ReleaseEventCommon{
+				Result: update.Result{
+					svc: update.ServiceResult{
+						Status: status,
+						PerContainer: []update.ContainerUpdate{
+							{Container: container, Target: imageID},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDeployedImages(t *testing.T) {
+	svc := flux.ServiceID("default/helloworld")
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// In descending order, as from the database
+	events := []Event{
+		release(4, t0.Add(3*time.Hour), svc, update.ReleaseStatusSuccess, "helloworld", "quay.io/weaveworks/helloworld:v3"),
+		release(3, t0.Add(2*time.Hour), svc, update.ReleaseStatusFailed, "helloworld", "quay.io/weaveworks/helloworld:broken"),
+		{ID: 5, Type: EventSync, StartedAt: t0.Add(90 * time.Minute), Metadata: &SyncEventMetadata{}},
+		release(2, t0.Add(time.Hour), svc, update.ReleaseStatusSuccess, "sidecar", "quay.io/weaveworks/sidecar:v2"),
+		release(1, t0, svc, update.ReleaseStatusSuccess, "helloworld", "quay.io/weaveworks/helloworld:v1"),
+	}
+
+	deployed := DeployedImages(events, svc, t0.Add(150*time.Minute))
+	if len(deployed) != 2 {
+		t.Fatalf("expected two containers, got %+v", deployed)
+	}
+	if deployed[0].Container != "helloworld" || deployed[0].ID.String() != "quay.io/weaveworks/helloworld:v1" || deployed[0].EventID != 1 {
+		t.Errorf("expected helloworld:v1 from the first release, got %+v", deployed[0])
+	}
+	if !deployed[0].DeployedAt.Equal(t0.Add(time.Second)) {
+		t.Errorf("expected deployment time to be the end of the release, got %s", deployed[0].DeployedAt)
+	}
+	if deployed[1].Container != "sidecar" || deployed[1].ID.String() != "quay.io/weaveworks/sidecar:v2" {
+		t.Errorf("expected sidecar:v2, got %+v", deployed[1])
+	}
+
+	deployed = DeployedImages(events, svc, t0.Add(4*time.Hour))
+	if len(deployed) != 2 || deployed[0].ID.String() != "quay.io/weaveworks/helloworld:v3" {
+		t.Errorf("expected latest release to be reported, got %+v", deployed)
+	}
+
+	if deployed = DeployedImages(events, svc, t0.Add(-time.Hour)); len(deployed) != 0 {
+		t.Errorf("expected nothing deployed before the first release, got %+v", deployed)
+	}
+	if deployed = DeployedImages(events, flux.ServiceID("default/other"), t0.Add(4*time.Hour)); len(deployed) != 0 {
+		t.Errorf("expected nothing deployed for another service, got %+v", deployed)
+	}
+}
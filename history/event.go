@@ -10,19 +10,33 @@ import (
 	"errors"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
 // These are all the types of events.
 const (
-	EventCommit      = "commit"
-	EventSync        = "sync"
-	EventRelease     = "release"
-	EventAutoRelease = "autorelease"
-	EventAutomate    = "automate"
-	EventDeautomate  = "deautomate"
-	EventLock        = "lock"
-	EventUnlock      = "unlock"
+	EventCommit        = "commit"
+	EventSync          = "sync"
+	EventRelease       = "release"
+	EventAutoRelease   = "autorelease"
+	EventAutomate      = "automate"
+	EventDeautomate    = "deautomate"
+	EventLock          = "lock"
+	EventUnlock        = "unlock"
+	EventPause         = "pause"
+	EventUnpause       = "unpause"
+	EventConfigRollout = "configrollout"
+	EventPromotion     = "promotion"
+	EventConnect       = "connect"
+	EventDisconnect    = "disconnect"
+	EventFailure       = "failure"
+	EventDeferral      = "deferral"
+	EventPolicyChange  = "policychange"
+	EventSwitch        = "switch"
+	EventPrune         = "prune"
+	EventConflict      = "conflict"
 
 	LogLevelDebug = "debug"
 	LogLevelInfo  = "info"
@@ -62,6 +76,13 @@ type Event struct {
 	// Metadata is Event.Type-specific metadata. If an event has no metadata,
 	// this will be nil.
 	Metadata EventMetadata `json:"metadata,omitempty"`
+
+	// NotifyChannels gives the channel for each service that has
+	// asked for its notifications to go somewhere in particular.
+	// It's filled in by the daemon, for the benefit of the
+	// notifications sent when the event is logged; it isn't kept in
+	// the history.
+	NotifyChannels map[flux.ServiceID]string `json:"notifyChannels,omitempty"`
 }
 
 func (e Event) ServiceIDStrings() []string {
@@ -152,6 +173,100 @@ func (e Event) String() string {
 		return fmt.Sprintf("Locked: %s", strings.Join(strServiceIDs, ", "))
 	case EventUnlock:
 		return fmt.Sprintf("Unlocked: %s", strings.Join(strServiceIDs, ", "))
+	case EventPause:
+		return fmt.Sprintf("Paused automation: %s", strings.Join(strServiceIDs, ", "))
+	case EventUnpause:
+		return fmt.Sprintf("Unpaused automation: %s", strings.Join(strServiceIDs, ", "))
+	case EventConfigRollout:
+		return fmt.Sprintf("Rolled out config changes: %s", strings.Join(strServiceIDs, ", "))
+	case EventPromotion:
+		metadata := e.Metadata.(*PromotionEventMetadata)
+		var detail string
+		if metadata.Error != "" {
+			detail = fmt.Sprintf(": %s", metadata.Error)
+		}
+		return fmt.Sprintf(
+			"Promotion of %s, stage %d of %d (%s): %s%s",
+			metadata.Spec.ImageSpec,
+			metadata.Stage+1,
+			metadata.Stages,
+			metadata.Instance,
+			metadata.State,
+			detail,
+		)
+	case EventConnect:
+		metadata := e.Metadata.(*ConnectEventMetadata)
+		if metadata.Attempts > 1 {
+			return fmt.Sprintf("Connected to service after %d attempts", metadata.Attempts)
+		}
+		return "Connected to service"
+	case EventDisconnect:
+		metadata := e.Metadata.(*DisconnectEventMetadata)
+		if metadata.Error != "" {
+			return fmt.Sprintf("Disconnected from service: %s", metadata.Error)
+		}
+		return "Disconnected from service"
+	case EventFailure:
+		metadata := e.Metadata.(*FailureEventMetadata)
+		var simulated string
+		if metadata.Simulated {
+			simulated = " (simulated)"
+		}
+		return fmt.Sprintf("Failure%s in %s: %s", simulated, metadata.Component, metadata.Error)
+	case EventDeferral:
+		metadata := e.Metadata.(*DeferralEventMetadata)
+		return fmt.Sprintf(
+			"Deferred automated release to %s until %s (window %s)",
+			strings.Join(strServiceIDs, ", "),
+			metadata.Until.Format(time.RFC3339),
+			metadata.Window,
+		)
+	case EventPolicyChange:
+		metadata := e.Metadata.(*PolicyChangeEventMetadata)
+		var changes []string
+		for _, c := range metadata.Changes {
+			changes = append(changes, fmt.Sprintf("%s (%s)", c.ServiceID, c))
+		}
+		var user string
+		if metadata.Cause.User != "" {
+			user = fmt.Sprintf(", by %s", metadata.Cause.User)
+		}
+		return fmt.Sprintf("Changed policies: %s%s", strings.Join(changes, ", "), user)
+	case EventSwitch:
+		metadata := e.Metadata.(*SwitchEventMetadata)
+		var released string
+		if metadata.Release != "" {
+			released = fmt.Sprintf(", released in %s", shortRevision(metadata.Release))
+		}
+		return fmt.Sprintf(
+			"Switched %s from %s to %s: %s%s",
+			metadata.Service,
+			metadata.From,
+			metadata.To,
+			shortRevision(metadata.Revision),
+			released,
+		)
+	case EventPrune:
+		metadata := e.Metadata.(*PruneEventMetadata)
+		return fmt.Sprintf(
+			"Pruned %s, which is no longer in the repo at %s",
+			metadata.ResourceID,
+			shortRevision(metadata.Revision),
+		)
+	case EventConflict:
+		metadata := e.Metadata.(*ConflictEventMetadata)
+		var what string
+		if metadata.Spec != nil {
+			what = " for " + metadata.Spec.Type + " update"
+		}
+		return fmt.Sprintf(
+			"Commit%s conflicted with changes pushed upstream (at %s) to %s; %s (policy %s)",
+			what,
+			shortRevision(metadata.Conflict.Upstream),
+			strings.Join(metadata.Conflict.Files, ", "),
+			metadata.Conflict.Outcome(),
+			metadata.Conflict.Policy,
+		)
 	default:
 		return fmt.Sprintf("Unknown event: %s", e.Type)
 	}
@@ -169,6 +284,15 @@ type CommitEventMetadata struct {
 	Revision string        `json:"revision,omitempty"`
 	Spec     *update.Spec  `json:"spec"`
 	Result   update.Result `json:"result,omitempty"`
+	// For a batch update, the result of each step in turn; Result
+	// has them all together.
+	Steps []update.Result `json:"steps,omitempty"`
+	// How the policies of each service were changed, if any were;
+	// these are logged as a policy change event, too.
+	PolicyChanges []PolicyChange `json:"policyChanges,omitempty"`
+	// Set if the commit ran into changes made upstream to the same
+	// files; if it wasn't pushed, there's no revision
+	Conflict *flux.GitConflict `json:"conflict,omitempty"`
 }
 
 func (c CommitEventMetadata) ShortRevision() string {
@@ -188,6 +312,9 @@ type ReleaseEventCommon struct {
 	Result   update.Result `json:"result"`
 	// Message of the error if there was one.
 	Error string `json:"error,omitempty"`
+	// Notes for people, as composed by the daemon; these may be
+	// absent, if the daemon predates them.
+	Notes *update.ReleaseNotes `json:"notes,omitempty"`
 }
 
 // ReleaseEventMetadata is the metadata for when service(s) are released
@@ -204,6 +331,159 @@ type AutoReleaseEventMetadata struct {
 	Spec update.Automated `json:"spec"`
 }
 
+// ConfigRolloutEventMetadata is for when the pods of services are
+// replaced, because the ConfigMaps or Secrets they refer to changed
+type ConfigRolloutEventMetadata struct {
+	Rollouts []ConfigRollout `json:"rollouts"`
+}
+
+// ConfigRollout records the checksum of the config a resource refers
+// to changing. Old is empty if the resource didn't have a checksum
+// before.
+type ConfigRollout struct {
+	ResourceID string `json:"resourceID"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new"`
+}
+
+// PromotionEventMetadata is for when a promotion of a release from
+// one instance to the next moves along. It's logged for the instance
+// the promotion was started from, and for the instance at the stage
+// in question.
+type PromotionEventMetadata struct {
+	ID       string                 `json:"id"`
+	From     service.InstanceID     `json:"from"`
+	Instance service.InstanceID     `json:"instance"`
+	Stage    int                    `json:"stage"`
+	Stages   int                    `json:"stages"`
+	State    service.PromotionState `json:"state"`
+	Spec     update.ReleaseSpec     `json:"spec"`
+	Cause    update.Cause           `json:"cause"`
+	Revision string                 `json:"revision,omitempty"`
+	Analysis *service.CanaryResult  `json:"analysis,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// ConnectEventMetadata is for when the daemon connects to the
+// service.
+type ConnectEventMetadata struct {
+	// The attempts it took to connect, including the successful one
+	Attempts int    `json:"attempts"`
+	Protocol string `json:"protocol,omitempty"`
+	// The last thing to go wrong, if it took more than one attempt
+	LastError string `json:"lastError,omitempty"`
+}
+
+// DisconnectEventMetadata is for when the daemon's connection to the
+// service ends. Since the service may not be there to hear about it
+// at the time, it can be logged after reconnecting.
+type DisconnectEventMetadata struct {
+	// How long the connection was up
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// FailureEventMetadata is for when something the daemon does
+// regularly (e.g., pulling from git) starts failing. It's only logged
+// when it starts, rather than every time it fails.
+type FailureEventMetadata struct {
+	// What failed: "git", "registry", or "apply"
+	Component string `json:"component"`
+	Error     string `json:"error"`
+	// Set if the failure was asked for, to test alerting
+	Simulated bool `json:"simulated,omitempty"`
+}
+
+// DeferralEventMetadata is for when automation would have released
+// new images to a service, but it was outside the service's window
+// for automated releases. The release happens when the window opens,
+// if the images are still the newest then; Until is when that is, or
+// the zero time if the window never opens.
+type DeferralEventMetadata struct {
+	Spec   update.Automated `json:"spec"`
+	Window string           `json:"window"`
+	Until  time.Time        `json:"until"`
+}
+
+// PolicyChangeEventMetadata is for when the policies of services
+// are changed and committed, e.g., by `fluxctl automate`. It records
+// who made the change, and the commit it's in, as well as what
+// changed.
+type PolicyChangeEventMetadata struct {
+	Revision string         `json:"revision"`
+	Cause    update.Cause   `json:"cause"`
+	Changes  []PolicyChange `json:"changes"`
+}
+
+// PolicyChange records the policies of a service before and after a
+// change to them, as written in its manifest; i.e., without any
+// defaults for its namespace.
+type PolicyChange struct {
+	ServiceID flux.ServiceID `json:"serviceID"`
+	Before    policy.Set     `json:"before,omitempty"`
+	After     policy.Set     `json:"after,omitempty"`
+}
+
+// String summarises the change, e.g., "+automated, -locked,
+// tag.app=semver:~1".
+func (c PolicyChange) String() string {
+	changed := map[policy.Policy]string{}
+	for p, v := range c.After {
+		if old, ok := c.Before[p]; ok && old == v {
+			continue
+		}
+		if v == "true" {
+			changed[p] = "+" + string(p)
+		} else {
+			changed[p] = fmt.Sprintf("%s=%s", p, v)
+		}
+	}
+	for p := range c.Before {
+		if _, ok := c.After[p]; !ok {
+			changed[p] = "-" + string(p)
+		}
+	}
+	var ps []string
+	for p := range changed {
+		ps = append(ps, string(p))
+	}
+	sort.Strings(ps)
+	var changes []string
+	for _, p := range ps {
+		changes = append(changes, changed[policy.Policy(p)])
+	}
+	return strings.Join(changes, ", ")
+}
+
+// SwitchEventMetadata is for when automation switches a service from
+// one of a blue/green pair of workloads to the other, having released
+// new images to it. Revision is the commit switching the service, and
+// Release the commit that released the images, if known; together
+// they are one release of the pair.
+type SwitchEventMetadata struct {
+	Revision string         `json:"revision"`
+	Release  string         `json:"release,omitempty"`
+	Service  flux.ServiceID `json:"service"`
+	From     flux.ServiceID `json:"from"`
+	To       flux.ServiceID `json:"to"`
+	Images   []flux.ImageID `json:"images,omitempty"`
+}
+
+// PruneEventMetadata is for when a resource is deleted from the
+// cluster because its manifest has gone from the repo, in a namespace
+// with the prune policy. Revision is the revision synced.
+type PruneEventMetadata struct {
+	Revision   string `json:"revision"`
+	ResourceID string `json:"resourceID"`
+}
+
+// ConflictEventMetadata is for when a commit made for a job changed
+// the same files as commits pushed upstream in the meantime.
+type ConflictEventMetadata struct {
+	Conflict flux.GitConflict `json:"conflict"`
+	Spec     *update.Spec     `json:"spec,omitempty"`
+}
+
 type UnknownEventMetadata map[string]interface{}
 
 func (e *Event) UnmarshalJSON(in []byte) error {
@@ -253,6 +533,76 @@ func (e *Event) UnmarshalJSON(in []byte) error {
 		}
 		e.Metadata = &metadata
 		break
+	case EventConfigRollout:
+		var metadata ConfigRolloutEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventPromotion:
+		var metadata PromotionEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventConnect:
+		var metadata ConnectEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventDisconnect:
+		var metadata DisconnectEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventFailure:
+		var metadata FailureEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventDeferral:
+		var metadata DeferralEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventPolicyChange:
+		var metadata PolicyChangeEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventSwitch:
+		var metadata SwitchEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventPrune:
+		var metadata PruneEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventConflict:
+		var metadata ConflictEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
 	default:
 		if len(wireEvent.MetadataBytes) > 0 {
 			var metadata UnknownEventMetadata
@@ -292,6 +642,46 @@ func (rem *AutoReleaseEventMetadata) Type() string {
 	return EventAutoRelease
 }
 
+func (crm *ConfigRolloutEventMetadata) Type() string {
+	return EventConfigRollout
+}
+
+func (pem *PromotionEventMetadata) Type() string {
+	return EventPromotion
+}
+
+func (cem *ConnectEventMetadata) Type() string {
+	return EventConnect
+}
+
+func (dem *DisconnectEventMetadata) Type() string {
+	return EventDisconnect
+}
+
+func (fem *FailureEventMetadata) Type() string {
+	return EventFailure
+}
+
+func (dem *DeferralEventMetadata) Type() string {
+	return EventDeferral
+}
+
+func (pem *PolicyChangeEventMetadata) Type() string {
+	return EventPolicyChange
+}
+
+func (sem *SwitchEventMetadata) Type() string {
+	return EventSwitch
+}
+
+func (pem *PruneEventMetadata) Type() string {
+	return EventPrune
+}
+
+func (cem *ConflictEventMetadata) Type() string {
+	return EventConflict
+}
+
 // Special exception from pointer receiver rule, as UnknownEventMetadata is a
 // type alias for a map
 func (uem UnknownEventMetadata) Type() string {
@@ -2,8 +2,13 @@ package history
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -60,3 +65,118 @@ func TestEvent_ParseNoMetadata(t *testing.T) {
 		t.Fatal("Hasn't been unmarshalled properly")
 	}
 }
+
+func TestEvent_ParsePromotionMetadata(t *testing.T) {
+	origEvent := Event{
+		Type: EventPromotion,
+		Metadata: &PromotionEventMetadata{
+			ID:       "promotion-1",
+			From:     "staging",
+			Instance: "prod",
+			Stage:    1,
+			Stages:   2,
+			State:    service.PromotionFailed,
+			Spec:     update.ReleaseSpec{ImageSpec: "quay.io/weaveworks/helloworld:v2"},
+			Error:    "release job failed",
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	if err := e.UnmarshalJSON(bytes); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Metadata.(*PromotionEventMetadata); !ok {
+		t.Fatalf("Wrong event type unmarshalled: %T", e.Metadata)
+	}
+	expected := "Promotion of quay.io/weaveworks/helloworld:v2, stage 2 of 2 (prod): failed: release job failed"
+	if got := e.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestEvent_ParseConnectionMetadata(t *testing.T) {
+	for _, example := range []struct {
+		event    Event
+		expected string
+	}{
+		{
+			Event{Type: EventConnect, Metadata: &ConnectEventMetadata{Attempts: 3, LastError: "connection refused"}},
+			"Connected to service after 3 attempts",
+		},
+		{
+			Event{Type: EventDisconnect, Metadata: &DisconnectEventMetadata{Duration: time.Hour, Error: "unexpected EOF"}},
+			"Disconnected from service: unexpected EOF",
+		},
+		{
+			Event{Type: EventFailure, Metadata: &FailureEventMetadata{Component: "git", Error: "host unreachable", Simulated: true}},
+			"Failure (simulated) in git: host unreachable",
+		},
+	} {
+		bytes, _ := json.Marshal(example.event)
+		e := Event{}
+		if err := e.UnmarshalJSON(bytes); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Metadata, example.event.Metadata) {
+			t.Errorf("expected metadata %#v, got %#v", example.event.Metadata, e.Metadata)
+		}
+		if got := e.String(); got != example.expected {
+			t.Errorf("expected %q, got %q", example.expected, got)
+		}
+	}
+}
+
+func TestEvent_ParseDeferralMetadata(t *testing.T) {
+	until := time.Date(2017, 9, 11, 9, 0, 0, 0, time.UTC)
+	event := Event{
+		ServiceIDs: []flux.ServiceID{flux.MakeServiceID("default", "helloworld")},
+		Type:       EventDeferral,
+		Metadata: &DeferralEventMetadata{
+			Window: "Mon-Fri 09:00-17:00 UTC",
+			Until:  until,
+		},
+	}
+	bytes, _ := json.Marshal(event)
+	e := Event{}
+	if err := e.UnmarshalJSON(bytes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.Metadata, event.Metadata) {
+		t.Errorf("expected metadata %#v, got %#v", event.Metadata, e.Metadata)
+	}
+	expected := "Deferred automated release to default/helloworld until 2017-09-11T09:00:00Z (window Mon-Fri 09:00-17:00 UTC)"
+	if got := e.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestEvent_ParsePolicyChangeMetadata(t *testing.T) {
+	id := flux.MakeServiceID("default", "helloworld")
+	event := Event{
+		ServiceIDs: []flux.ServiceID{id},
+		Type:       EventPolicyChange,
+		Metadata: &PolicyChangeEventMetadata{
+			Revision: "abc123",
+			Cause:    cause,
+			Changes: []PolicyChange{{
+				ServiceID: id,
+				Before:    policy.Set{policy.Locked: "true", "tag.app": "glob:1.*"},
+				After:     policy.Set{policy.Automated: "true", "tag.app": "semver:~1"},
+			}},
+		},
+	}
+	bytes, _ := json.Marshal(event)
+	e := Event{}
+	if err := e.UnmarshalJSON(bytes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.Metadata, event.Metadata) {
+		t.Errorf("expected metadata %#v, got %#v", event.Metadata, e.Metadata)
+	}
+	expected := "Changed policies: default/helloworld (+automated, -locked, tag.app=semver:~1), by test user"
+	if got := e.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
-
 	"github.com/weaveworks/flux"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
 	"github.com/weaveworks/flux/service"
 )
 
@@ -17,13 +15,12 @@ const (
 )
 
 var (
-	requestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	requestDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "history",
 		Name:      "request_duration_seconds",
 		Help:      "Request duration in seconds.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{LabelMethod, LabelSuccess})
+	}, LabelMethod, LabelSuccess)
 )
 
 type instrumentedDB struct {
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"sort"
+
+	"github.com/weaveworks/flux"
+)
+
+// ServiceSummary is what's needed to show a service in a list: the
+// most recent events of interest for it, and whether it's automated
+// or locked.
+type ServiceSummary struct {
+	ID flux.ServiceID `json:"id"`
+	// The most recent release (manual or automated) of the service
+	LastRelease *Event `json:"lastRelease,omitempty"`
+	// The most recent sync that touched the service
+	LastSync  *Event `json:"lastSync,omitempty"`
+	Automated bool   `json:"automated"`
+	Locked    bool   `json:"locked"`
+}
+
+// Summarizer works out the summary of each of a set of services, from
+// events given to it a batch at a time, so that the history can be
+// read only as far back as is needed. The events must be in
+// descending timestamp order, as they are returned from an
+// EventReader.
+type Summarizer struct {
+	summaries map[flux.ServiceID]*ServiceSummary
+	// how many summaries still lack something
+	incomplete int
+}
+
+// NewSummarizer starts a summary of each of the services given, with
+// their automation state as it is now.
+func NewSummarizer(services []flux.ServiceStatus) *Summarizer {
+	s := &Summarizer{summaries: map[flux.ServiceID]*ServiceSummary{}}
+	for _, service := range services {
+		if _, ok := s.summaries[service.ID]; ok {
+			continue
+		}
+		s.summaries[service.ID] = &ServiceSummary{
+			ID:        service.ID,
+			Automated: service.Automated,
+			Locked:    service.Locked,
+		}
+		s.incomplete++
+	}
+	return s
+}
+
+// Add looks through a batch of events, each older than any given
+// before, for those of interest.
+func (s *Summarizer) Add(events []Event) {
+	for i := range events {
+		e := &events[i]
+		switch e.Type {
+		case EventRelease, EventAutoRelease, EventSync:
+		default:
+			continue
+		}
+		for _, id := range e.ServiceIDs {
+			summary, ok := s.summaries[id]
+			if !ok {
+				continue
+			}
+			complete := summary.LastRelease != nil && summary.LastSync != nil
+			if e.Type == EventSync {
+				if summary.LastSync == nil {
+					summary.LastSync = e
+				}
+			} else if summary.LastRelease == nil {
+				summary.LastRelease = e
+			}
+			if !complete && summary.LastRelease != nil && summary.LastSync != nil {
+				s.incomplete--
+			}
+		}
+	}
+}
+
+// Done says whether every service has been given all it can have,
+// in which case there's no need to look at any older events.
+func (s *Summarizer) Done() bool {
+	return s.incomplete == 0
+}
+
+// Summaries gives the summary of each service, in order of ID.
+func (s *Summarizer) Summaries() []ServiceSummary {
+	res := make([]ServiceSummary, 0, len(s.summaries))
+	for _, summary := range s.summaries {
+		res = append(res, *summary)
+	}
+	sort.Sort(summariesByID(res))
+	return res
+}
+
+type summariesByID []ServiceSummary
+
+func (s summariesByID) Len() int           { return len(s) }
+func (s summariesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s summariesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
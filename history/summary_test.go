@@ -0,0 +1,49 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestSummarizer(t *testing.T) {
+	hello, other := flux.ServiceID("default/helloworld"), flux.ServiceID("default/other")
+	t0 := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s := NewSummarizer([]flux.ServiceStatus{
+		{ID: hello, Automated: true},
+		{ID: other, Locked: true},
+	})
+	// In descending order, as from the database, in two batches
+	s.Add([]Event{
+		{ID: 5, Type: EventSync, ServiceIDs: []flux.ServiceID{hello}, StartedAt: t0.Add(4 * time.Hour)},
+		{ID: 4, Type: EventLock, ServiceIDs: []flux.ServiceID{other}, StartedAt: t0.Add(3 * time.Hour)},
+		{ID: 3, Type: EventAutoRelease, ServiceIDs: []flux.ServiceID{hello}, StartedAt: t0.Add(2 * time.Hour)},
+	})
+	if s.Done() {
+		t.Fatal("expected summaries to be incomplete, since other has no events yet")
+	}
+	s.Add([]Event{
+		{ID: 2, Type: EventSync, ServiceIDs: []flux.ServiceID{hello, other}, StartedAt: t0.Add(time.Hour)},
+		{ID: 1, Type: EventRelease, ServiceIDs: []flux.ServiceID{hello}, StartedAt: t0},
+	})
+
+	summaries := s.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("expected two summaries, got %+v", summaries)
+	}
+	h, o := summaries[0], summaries[1]
+	if h.ID != hello || !h.Automated || h.Locked {
+		t.Errorf("expected automated, unlocked %s first, got %+v", hello, h)
+	}
+	if h.LastRelease == nil || h.LastRelease.ID != 3 || h.LastSync == nil || h.LastSync.ID != 5 {
+		t.Errorf("expected latest release 3 and sync 5 for %s, got %+v", hello, h)
+	}
+	if o.ID != other || !o.Locked || o.LastRelease != nil || o.LastSync == nil || o.LastSync.ID != 2 {
+		t.Errorf("expected locked %s with sync 2 and no release, got %+v", other, o)
+	}
+	if s.Done() {
+		t.Error("expected summaries to be incomplete, since other has never been released")
+	}
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 	"sort"
+	"strings"
 
 	"github.com/golang/gddo/httputil/header"
 )
@@ -68,3 +69,15 @@ func indexOf(ss []string, search string) int {
 	}
 	return len(ss)
 }
+
+// acceptsEncoding reports whether the Accept-Encoding header of a
+// request allows the given content coding, either by naming it or by
+// a wildcard, with a non-zero quality.
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, spec := range header.ParseAccept(r.Header, "Accept-Encoding") {
+		if strings.EqualFold(spec.Value, coding) || spec.Value == "*" {
+			return spec.Q > 0
+		}
+	}
+	return false
+}
@@ -40,3 +40,25 @@ func Test_NegotiateContentType(t *testing.T) {
 		t.Errorf("Quality beats preference: expected %q, got %q", "text/html", got)
 	}
 }
+
+func Test_AcceptsEncoding(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"identity", false},
+	} {
+		h := http.Header{}
+		if c.header != "" {
+			h.Set("Accept-Encoding", c.header)
+		}
+		if got := acceptsEncoding(&http.Request{Header: h}, "gzip"); got != c.want {
+			t.Errorf("Accept-Encoding %q: expected %v, got %v", c.header, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package auth has the authentication schemes that clients can use
+// with the flux API. Each scheme is configured with credentials;
+// clients use it to put the credentials in requests, and servers use
+// it to check that requests have the same credentials.
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	transport "github.com/weaveworks/flux/http"
+)
+
+// Scheme is an authentication scheme along with its credentials.
+type Scheme interface {
+	api.Authenticator
+	api.Validator
+}
+
+var (
+	ErrMissingCredentials = errors.New("no credentials in request")
+	ErrWrongCredentials   = errors.New("credentials in request are not valid")
+)
+
+// The names of the schemes, as used in New
+const (
+	SchemeScopeProbe = "scope-probe"
+	SchemeBearer     = "bearer"
+	SchemeBasic      = "basic"
+	// For a custom header, this is followed by a colon and the
+	// header name, e.g., "header:X-Flux-Token"
+	SchemeHeader = "header"
+)
+
+// New makes an authentication scheme given its name and the
+// credentials to use. For basic auth, the credentials are
+// "username:password"; for the others, they are the token.
+func New(scheme, credentials string) (Scheme, error) {
+	switch {
+	case scheme == SchemeScopeProbe:
+		return ScopeProbe(credentials), nil
+	case scheme == SchemeBearer:
+		return Bearer(credentials), nil
+	case scheme == SchemeBasic:
+		parts := strings.SplitN(credentials, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(`credentials for basic auth must be of the form "username:password"`)
+		}
+		return Basic{Username: parts[0], Password: parts[1]}, nil
+	case strings.HasPrefix(scheme, SchemeHeader+":"):
+		name := strings.TrimPrefix(scheme, SchemeHeader+":")
+		if name == "" {
+			return nil, errors.New("no header name given for custom header authentication")
+		}
+		return Header{Name: name, Value: credentials}, nil
+	}
+	return nil, errors.Errorf("unknown authentication scheme %q", scheme)
+}
+
+// ScopeProbe is the scheme that flux.Token uses.
+type ScopeProbe flux.Token
+
+func (t ScopeProbe) Authenticate(req *http.Request) {
+	flux.Token(t).Set(req)
+}
+
+func (t ScopeProbe) Validate(req *http.Request) error {
+	return validateAuthorization(req, "Scope-Probe token=", string(t))
+}
+
+// Bearer is for OAuth2-style bearer tokens (RFC 6750).
+type Bearer string
+
+func (t Bearer) Authenticate(req *http.Request) {
+	if t != "" {
+		req.Header.Set("Authorization", "Bearer "+string(t))
+	}
+}
+
+func (t Bearer) Validate(req *http.Request) error {
+	return validateAuthorization(req, "Bearer ", string(t))
+}
+
+// Basic is HTTP basic authentication (RFC 7617).
+type Basic struct {
+	Username, Password string
+}
+
+func (b Basic) Authenticate(req *http.Request) {
+	req.SetBasicAuth(b.Username, b.Password)
+}
+
+func (b Basic) Validate(req *http.Request) error {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return ErrMissingCredentials
+	}
+	// Check both, regardless, so the time taken doesn't give away
+	// which was wrong
+	userOK := equal(username, b.Username)
+	passwordOK := equal(password, b.Password)
+	if !userOK || !passwordOK {
+		return ErrWrongCredentials
+	}
+	return nil
+}
+
+// Header puts a token in a header of its own, rather than in the
+// Authorization header.
+type Header struct {
+	Name, Value string
+}
+
+func (h Header) Authenticate(req *http.Request) {
+	if h.Value != "" {
+		req.Header.Set(h.Name, h.Value)
+	}
+}
+
+func (h Header) Validate(req *http.Request) error {
+	value := req.Header.Get(h.Name)
+	if value == "" {
+		return ErrMissingCredentials
+	}
+	if !equal(value, h.Value) {
+		return ErrWrongCredentials
+	}
+	return nil
+}
+
+func validateAuthorization(req *http.Request, prefix, expected string) error {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return ErrMissingCredentials
+	}
+	if !equal(strings.TrimPrefix(header, prefix), expected) {
+		return ErrWrongCredentials
+	}
+	return nil
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Handler checks each request with the validator before passing it
+// on, unless it matches a route in except (which may be nil).
+// Requests that fail validation get 401 Unauthorized.
+func Handler(v api.Validator, except *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var match mux.RouteMatch
+		if except == nil || !except.Match(r, &match) {
+			if err := v.Validate(r); err != nil {
+				transport.WriteError(w, r, http.StatusUnauthorized, transport.ErrorUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
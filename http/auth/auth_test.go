@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	transport "github.com/weaveworks/flux/http"
+)
+
+func TestSchemes(t *testing.T) {
+	for _, c := range []struct {
+		scheme, credentials, wrong string
+	}{
+		{SchemeScopeProbe, "abc123", "def456"},
+		{SchemeBearer, "abc123", "def456"},
+		{SchemeBasic, "flux:s3cret", "flux:guess"},
+		{"header:X-Flux-Token", "abc123", "def456"},
+	} {
+		server, err := New(c.scheme, c.credentials)
+		if err != nil {
+			t.Fatalf("%s: %s", c.scheme, err)
+		}
+		client, _ := New(c.scheme, c.credentials)
+		wrong, _ := New(c.scheme, c.wrong)
+
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		if err := server.Validate(req); err != ErrMissingCredentials {
+			t.Errorf("%s: expected missing credentials, got %v", c.scheme, err)
+		}
+		client.Authenticate(req)
+		if err := server.Validate(req); err != nil {
+			t.Errorf("%s: expected credentials to be valid, got %v", c.scheme, err)
+		}
+
+		req, _ = http.NewRequest("GET", "http://example.com/", nil)
+		wrong.Authenticate(req)
+		if err := server.Validate(req); err != ErrWrongCredentials {
+			t.Errorf("%s: expected wrong credentials, got %v", c.scheme, err)
+		}
+	}
+
+	for _, bad := range [][2]string{{"kerberos", "x"}, {SchemeBasic, "no-password"}, {"header:", "x"}} {
+		if _, err := New(bad[0], bad[1]); err == nil {
+			t.Errorf("expected error for scheme %q with credentials %q", bad[0], bad[1])
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := Handler(Bearer("abc123"), transport.NewUpstreamRouter(), next)
+
+	for _, c := range []struct {
+		path   string
+		token  Bearer
+		status int
+	}{
+		{"/v6/services", "", http.StatusUnauthorized},
+		{"/v6/services", "wrong", http.StatusUnauthorized},
+		{"/v6/services", "abc123", http.StatusOK},
+		// Daemon routes are exempt
+		{"/v6/daemon", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", c.path, nil)
+		c.token.Authenticate(req)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s with token %q: expected %d, got %d", c.path, c.token, c.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// CacheTTLs says how long the answers from each of the cached
+// methods are kept. Zero means the method isn't cached at all.
+type CacheTTLs struct {
+	ListServices time.Duration
+	ListImages   time.Duration
+	SyncStatus   time.Duration
+}
+
+var DefaultCacheTTLs = CacheTTLs{
+	ListServices: 10 * time.Second,
+	ListImages:   30 * time.Second,
+	SyncStatus:   5 * time.Second,
+}
+
+// CachingClient keeps the answers to the read methods that are most
+// often polled (e.g., by dashboards), so that asking again within
+// the TTL doesn't reach the daemon. Anything that changes the
+// instance -- releasing, changing policies, syncing -- throws away
+// what's kept for that instance. All the other methods are passed
+// straight through.
+type CachingClient struct {
+	api.ClientService
+	ttls CacheTTLs
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+var _ api.ClientService = &CachingClient{}
+
+func NewCachingClient(c api.ClientService, ttls CacheTTLs) *CachingClient {
+	return &CachingClient{
+		ClientService: c,
+		ttls:          ttls,
+		now:           time.Now,
+		entries:       map[string]cacheEntry{},
+	}
+}
+
+// Invalidate forgets everything kept for the instance given.
+func (c *CachingClient) Invalidate(inst service.InstanceID) {
+	prefix := cacheKey(inst)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cached gets the value for the key if it's there and still fresh;
+// otherwise it calls fetch, and keeps the result for the TTL. Errors
+// aren't kept.
+func (c *CachingClient) cached(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return fetch()
+	}
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{value: value, expires: c.now().Add(ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// cacheKey makes a key from the instance and then anything else
+// that distinguishes the request. The instance comes first, so that
+// it can be used as a prefix when invalidating.
+func cacheKey(inst service.InstanceID, parts ...string) string {
+	return strings.Join(append([]string{string(inst)}, parts...), "\x00") + "\x00"
+}
+
+func (c *CachingClient) ListServices(ctx context.Context, inst service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+	v, err := c.cached(cacheKey(inst, "ListServices", namespace), c.ttls.ListServices, func() (interface{}, error) {
+		return c.ClientService.ListServices(ctx, inst, namespace)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]flux.ServiceStatus), nil
+}
+
+func (c *CachingClient) ListImages(ctx context.Context, inst service.InstanceID, spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+	v, err := c.cached(cacheKey(inst, "ListImages", string(spec)), c.ttls.ListImages, func() (interface{}, error) {
+		return c.ClientService.ListImages(ctx, inst, spec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]flux.ImageStatus), nil
+}
+
+func (c *CachingClient) SyncStatus(ctx context.Context, inst service.InstanceID, ref string) ([]string, error) {
+	v, err := c.cached(cacheKey(inst, "SyncStatus", ref), c.ttls.SyncStatus, func() (interface{}, error) {
+		return c.ClientService.SyncStatus(ctx, inst, ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+func (c *CachingClient) UpdateImages(ctx context.Context, inst service.InstanceID, spec update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+	if spec.Kind != update.ReleaseKindPlan {
+		defer c.Invalidate(inst)
+	}
+	return c.ClientService.UpdateImages(ctx, inst, spec, cause)
+}
+
+func (c *CachingClient) UpdatePolicies(ctx context.Context, inst service.InstanceID, updates policy.Updates, cause update.Cause, dryRun bool) (job.ID, error) {
+	if !dryRun {
+		defer c.Invalidate(inst)
+	}
+	return c.ClientService.UpdatePolicies(ctx, inst, updates, cause, dryRun)
+}
+
+func (c *CachingClient) UpdateBatch(ctx context.Context, inst service.InstanceID, spec update.BatchSpec, cause update.Cause) (job.ID, error) {
+	defer c.Invalidate(inst)
+	return c.ClientService.UpdateBatch(ctx, inst, spec, cause)
+}
+
+func (c *CachingClient) AddManifests(ctx context.Context, inst service.InstanceID, spec update.AddSpec, cause update.Cause) (job.ID, error) {
+	defer c.Invalidate(inst)
+	return c.ClientService.AddManifests(ctx, inst, spec, cause)
+}
+
+func (c *CachingClient) Rollback(ctx context.Context, inst service.InstanceID, spec update.RollbackSpec, cause update.Cause) (job.ID, error) {
+	defer c.Invalidate(inst)
+	return c.ClientService.Rollback(ctx, inst, spec, cause)
+}
+
+func (c *CachingClient) SyncNotify(ctx context.Context, inst service.InstanceID) error {
+	defer c.Invalidate(inst)
+	return c.ClientService.SyncNotify(ctx, inst)
+}
+
+func (c *CachingClient) SyncWait(ctx context.Context, inst service.InstanceID, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	defer c.Invalidate(inst)
+	return c.ClientService.SyncWait(ctx, inst, req)
+}
+
+func (c *CachingClient) ResetGitToRemote(ctx context.Context, inst service.InstanceID) (string, error) {
+	defer c.Invalidate(inst)
+	return c.ClientService.ResetGitToRemote(ctx, inst)
+}
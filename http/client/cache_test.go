@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// countingService answers ListServices and SyncStatus, counting how
+// often it's asked.
+type countingService struct {
+	api.ClientService
+	calls int
+	err   error
+}
+
+func (s *countingService) ListServices(ctx context.Context, inst service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []flux.ServiceStatus{{ID: flux.ServiceID(namespace + "/helloworld")}}, nil
+}
+
+func (s *countingService) SyncStatus(ctx context.Context, inst service.InstanceID, ref string) ([]string, error) {
+	s.calls++
+	return []string{ref}, nil
+}
+
+func (s *countingService) UpdateImages(ctx context.Context, inst service.InstanceID, spec update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+	return job.ID("job"), nil
+}
+
+func (s *countingService) SyncWait(ctx context.Context, inst service.InstanceID, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	return remote.SyncReport{}, nil
+}
+
+func (s *countingService) ResetGitToRemote(ctx context.Context, inst service.InstanceID) (string, error) {
+	return "HEAD", nil
+}
+
+func TestCachingClient(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backend := &countingService{}
+	c := NewCachingClient(backend, CacheTTLs{ListServices: time.Minute})
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		services, err := c.ListServices(ctx, "inst", "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 1 || services[0].ID != "default/helloworld" {
+			t.Errorf("unexpected answer %v", services)
+		}
+	}
+	if backend.calls != 1 {
+		t.Errorf("expected one call to the backend, got %d", backend.calls)
+	}
+
+	// Different arguments, or a different instance, aren't answered
+	// from what's kept
+	c.ListServices(ctx, "inst", "kube-system")
+	c.ListServices(ctx, "other", "default")
+	if backend.calls != 3 {
+		t.Errorf("expected three calls to the backend, got %d", backend.calls)
+	}
+
+	// Not cached, since it has no TTL
+	c.SyncStatus(ctx, "inst", "HEAD")
+	c.SyncStatus(ctx, "inst", "HEAD")
+	if backend.calls != 5 {
+		t.Errorf("expected five calls to the backend, got %d", backend.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	c.ListServices(ctx, "inst", "default")
+	if backend.calls != 6 {
+		t.Errorf("expected call to the backend after expiry, got %d calls", backend.calls)
+	}
+}
+
+func TestCachingClientInvalidation(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingService{}
+	c := NewCachingClient(backend, DefaultCacheTTLs)
+
+	c.ListServices(ctx, "inst", "default")
+	c.ListServices(ctx, "other", "default")
+	c.UpdateImages(ctx, "inst", update.ReleaseSpec{Kind: update.ReleaseKindPlan}, update.Cause{})
+	c.ListServices(ctx, "inst", "default")
+	if backend.calls != 2 {
+		t.Errorf("expected planning a release to leave the cache alone, got %d calls", backend.calls)
+	}
+
+	c.UpdateImages(ctx, "inst", update.ReleaseSpec{Kind: update.ReleaseKindExecute}, update.Cause{})
+	c.ListServices(ctx, "inst", "default")
+	c.ListServices(ctx, "other", "default")
+	if backend.calls != 3 {
+		t.Errorf("expected only the released instance to be invalidated, got %d calls", backend.calls)
+	}
+
+	c.SyncWait(ctx, "inst", remote.SyncWaitRequest{})
+	c.ListServices(ctx, "inst", "default")
+	if backend.calls != 4 {
+		t.Errorf("expected waiting for a sync to invalidate the instance, got %d calls", backend.calls)
+	}
+
+	c.ResetGitToRemote(ctx, "inst")
+	c.ListServices(ctx, "inst", "default")
+	if backend.calls != 5 {
+		t.Errorf("expected resetting git to invalidate the instance, got %d calls", backend.calls)
+	}
+}
+
+func TestCachingClientDoesNotCacheErrors(t *testing.T) {
+	backend := &countingService{err: errors.New("daemon not there")}
+	c := NewCachingClient(backend, DefaultCacheTTLs)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ListServices(context.Background(), "inst", "default"); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if backend.calls != 2 {
+		t.Errorf("expected each call to reach the backend, got %d", backend.calls)
+	}
+}
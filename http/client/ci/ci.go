@@ -0,0 +1,224 @@
+// Package ci has helpers for what a CI pipeline usually wants from
+// flux: to release an image, or change some policies, then wait until
+// the change has been committed and applied to the cluster. They work
+// with any api.ClientService, but are meant to be used with the
+// client in http/client.
+package ci
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// ErrTimeout is returned when a change hasn't been committed and
+// applied within the time given.
+var ErrTimeout = errors.New("timed out waiting for change to be applied")
+
+// Outcome is what became of a change.
+type Outcome struct {
+	JobID job.ID
+	// Revision is the commit made for the change; it's empty if
+	// the change made no difference to the repo.
+	Revision string
+	Result   update.Result
+}
+
+// Client submits changes and waits for them. Use New to get one with
+// reasonable values for how to poll.
+type Client struct {
+	API      api.ClientService
+	Instance service.InstanceID
+	Cause    update.Cause
+	// PollInterval is how long to wait between asking after a job
+	// or sync at first; it is doubled each time, up to
+	// MaxPollInterval.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// MaxErrors is how many failures in a row to put up with while
+	// polling, before giving up; failures that won't go away by
+	// asking again, like a job failing, are returned straight away.
+	MaxErrors int
+	// Apply says whether to wait for the commit to be applied to
+	// the cluster, or only for it to be made.
+	Apply bool
+}
+
+// New makes a Client that waits for changes to be applied.
+func New(client api.ClientService, cause update.Cause) *Client {
+	return &Client{
+		API:             client,
+		Cause:           cause,
+		PollInterval:    250 * time.Millisecond,
+		MaxPollInterval: 5 * time.Second,
+		MaxErrors:       5,
+		Apply:           true,
+	}
+}
+
+// ReleaseAndWait releases the image given to the service given, and
+// waits for the release to be committed and (if c.Apply) applied.
+func (c *Client) ReleaseAndWait(ctx context.Context, id flux.ServiceID, image flux.ImageID, timeout time.Duration) (Outcome, error) {
+	spec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{update.ServiceSpec(id)},
+		ImageSpec:    update.ImageSpecFromID(image),
+		Kind:         update.ReleaseKindExecute,
+	}
+	return c.submitAndWait(ctx, timeout, func(ctx context.Context) (job.ID, error) {
+		return c.API.UpdateImages(ctx, c.Instance, spec, c.Cause)
+	})
+}
+
+// SetPoliciesAndWait makes the policy changes given, and waits for
+// them to be committed and (if c.Apply) applied.
+func (c *Client) SetPoliciesAndWait(ctx context.Context, updates policy.Updates, timeout time.Duration) (Outcome, error) {
+	return c.submitAndWait(ctx, timeout, func(ctx context.Context) (job.ID, error) {
+		return c.API.UpdatePolicies(ctx, c.Instance, updates, c.Cause, false)
+	})
+}
+
+func (c *Client) submitAndWait(ctx context.Context, timeout time.Duration, submit func(context.Context) (job.ID, error)) (Outcome, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var outcome Outcome
+	var err error
+	// A request that was refused for being one too many can safely
+	// be made again; anything else might have got as far as making
+	// a job, so isn't repeated.
+	for {
+		outcome.JobID, err = submit(ctx)
+		tooMany, ok := errors.Cause(err).(transport.TooManyRequests)
+		if !ok {
+			break
+		}
+		if err := sleep(ctx, tooMany.RetryAfter); err != nil {
+			return outcome, err
+		}
+	}
+	if err != nil {
+		return outcome, errors.Wrap(err, "submitting change")
+	}
+
+	metadata, err := c.WaitForJob(ctx, outcome.JobID)
+	if err != nil && err.Error() != git.ErrNoChanges.Error() {
+		return outcome, err
+	}
+	outcome.Revision = metadata.Revision
+	outcome.Result = metadata.Result
+	if c.Apply && outcome.Revision != "" {
+		if err := c.WaitForSync(ctx, outcome.Revision); err != nil {
+			return outcome, err
+		}
+	}
+	return outcome, nil
+}
+
+// WaitForJob waits until the job given has finished, and gives back
+// what it did; or, if it failed, the job's error.
+func (c *Client) WaitForJob(ctx context.Context, id job.ID) (history.CommitEventMetadata, error) {
+	var result history.CommitEventMetadata
+	err := c.poll(ctx, func() (bool, error) {
+		j, err := c.API.JobStatus(ctx, c.Instance, id)
+		if err != nil {
+			return false, err
+		}
+		switch j.StatusString {
+		case job.StatusFailed:
+			return false, permanent{j}
+		case job.StatusSucceeded:
+			if j.Err != "" {
+				return false, permanent{j}
+			}
+			result = j.Result
+			return true, nil
+		}
+		return false, nil
+	})
+	return result, err
+}
+
+// WaitForSync waits until the revision given has been applied to the
+// cluster.
+func (c *Client) WaitForSync(ctx context.Context, revision string) error {
+	return c.poll(ctx, func() (bool, error) {
+		refs, err := c.API.SyncStatus(ctx, c.Instance, revision)
+		return err == nil && len(refs) == 0, err
+	})
+}
+
+// permanent marks an error that asking again won't fix.
+type permanent struct {
+	error
+}
+
+// poll calls f until it says it's done, backing off in between. An
+// error from f is tolerated up to c.MaxErrors times in a row, unless
+// it's one that won't go away.
+func (c *Client) poll(ctx context.Context, f func() (bool, error)) error {
+	delay := c.PollInterval
+	var errs int
+	for {
+		done, err := f()
+		switch {
+		case done:
+			return nil
+		case err != nil && !transient(err):
+			if p, ok := err.(permanent); ok {
+				return p.error
+			}
+			return err
+		case err != nil:
+			errs++
+			if errs > c.MaxErrors {
+				return errors.Wrapf(err, "giving up after %d errors", errs)
+			}
+		default:
+			errs = 0
+		}
+
+		wait := delay
+		if tooMany, ok := errors.Cause(err).(transport.TooManyRequests); ok && tooMany.RetryAfter > wait {
+			wait = tooMany.RetryAfter
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		if delay *= 2; delay > c.MaxPollInterval {
+			delay = c.MaxPollInterval
+		}
+	}
+}
+
+// transient says whether an error might go away by itself; i.e., it's
+// not a job failing, or something that wasn't there or was refused.
+func transient(err error) bool {
+	switch errors.Cause(err).(type) {
+	case permanent, flux.Missing, flux.UserConfigProblem:
+		return false
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,129 @@
+package ci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/history"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// fakeAPI answers just the methods the helpers use; anything else
+// panics, since the embedded interface is nil.
+type fakeAPI struct {
+	api.ClientService
+	submitErrs []error
+	submitted  int
+	statuses   []job.Status
+	statusErrs []error
+	syncs      [][]string
+}
+
+func (f *fakeAPI) UpdateImages(_ context.Context, _ service.InstanceID, spec update.ReleaseSpec, _ update.Cause) (job.ID, error) {
+	f.submitted++
+	if len(f.submitErrs) > 0 {
+		err := f.submitErrs[0]
+		f.submitErrs = f.submitErrs[1:]
+		return "", err
+	}
+	return job.ID("job-1"), nil
+}
+
+func (f *fakeAPI) JobStatus(_ context.Context, _ service.InstanceID, _ job.ID) (job.Status, error) {
+	if len(f.statusErrs) > 0 {
+		err := f.statusErrs[0]
+		f.statusErrs = f.statusErrs[1:]
+		if err != nil {
+			return job.Status{}, err
+		}
+	}
+	status := f.statuses[0]
+	if len(f.statuses) > 1 {
+		f.statuses = f.statuses[1:]
+	}
+	return status, nil
+}
+
+func (f *fakeAPI) SyncStatus(_ context.Context, _ service.InstanceID, _ string) ([]string, error) {
+	refs := f.syncs[0]
+	if len(f.syncs) > 1 {
+		f.syncs = f.syncs[1:]
+	}
+	return refs, nil
+}
+
+func testClient(f *fakeAPI) *Client {
+	c := New(f, update.Cause{User: "ci"})
+	c.PollInterval = time.Millisecond
+	c.MaxPollInterval = time.Millisecond
+	c.MaxErrors = 2
+	return c
+}
+
+var (
+	serviceID = flux.MakeServiceID("default", "helloworld")
+	image, _  = flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+)
+
+func TestReleaseAndWait(t *testing.T) {
+	f := &fakeAPI{
+		submitErrs: []error{transport.TooManyRequests{BaseError: &flux.BaseError{Err: errors.New("slow down")}}},
+		statuses: []job.Status{
+			{StatusString: job.StatusQueued},
+			{StatusString: job.StatusRunning},
+			{StatusString: job.StatusSucceeded, Result: history.CommitEventMetadata{Revision: "abc123"}},
+		},
+		// The first error should be tolerated, as it's one we can
+		// expect to go away
+		statusErrs: []error{flux.ServerException{BaseError: &flux.BaseError{Err: errors.New("oops")}}},
+		syncs:      [][]string{{"abc123"}, {}},
+	}
+	outcome, err := testClient(f).ReleaseAndWait(context.Background(), serviceID, image, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.JobID != "job-1" || outcome.Revision != "abc123" {
+		t.Errorf("unexpected outcome %+v", outcome)
+	}
+	if f.submitted != 2 {
+		t.Errorf("expected release to be submitted again after too many requests, got %d submissions", f.submitted)
+	}
+}
+
+func TestReleaseAndWaitFailures(t *testing.T) {
+	// A failed job is reported as is
+	f := &fakeAPI{statuses: []job.Status{{StatusString: job.StatusFailed, Err: "no such image"}}}
+	if _, err := testClient(f).ReleaseAndWait(context.Background(), serviceID, image, time.Second); err == nil || err.Error() != "no such image" {
+		t.Errorf("expected job error, got %v", err)
+	}
+
+	// Only so many errors are put up with
+	oops := flux.ServerException{BaseError: &flux.BaseError{Err: errors.New("oops")}}
+	f = &fakeAPI{statusErrs: []error{oops, oops, oops}, statuses: []job.Status{{StatusString: job.StatusSucceeded}}}
+	if _, err := testClient(f).ReleaseAndWait(context.Background(), serviceID, image, time.Second); err == nil {
+		t.Error("expected error after repeated failures")
+	}
+
+	// A release that changes nothing has nothing to wait for
+	f = &fakeAPI{statuses: []job.Status{{StatusString: job.StatusFailed, Err: "no changes made in repo"}}}
+	outcome, err := testClient(f).ReleaseAndWait(context.Background(), serviceID, image, time.Second)
+	if err != nil || outcome.Revision != "" {
+		t.Errorf("expected no error and no revision, got %+v, %v", outcome, err)
+	}
+
+	// A sync that never happens times out
+	f = &fakeAPI{
+		statuses: []job.Status{{StatusString: job.StatusSucceeded, Result: history.CommitEventMetadata{Revision: "abc123"}}},
+		syncs:    [][]string{{"abc123"}},
+	}
+	if _, err := testClient(f).ReleaseAndWait(context.Background(), serviceID, image, 20*time.Millisecond); err != ErrTimeout {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
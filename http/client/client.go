@@ -2,10 +2,13 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
-	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,175 +16,515 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
 	"github.com/weaveworks/flux/history"
 	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/http/websocket"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
 	"github.com/weaveworks/flux/ssh"
 	"github.com/weaveworks/flux/update"
 )
 
 type Client struct {
 	client   *http.Client
-	token    flux.Token
+	auth     api.Authenticator
 	router   *mux.Router
 	endpoint string
+	metrics  *Metrics
+	etags    *etagCache
+	retry    RetryPolicy
+	hooks    []RequestHook
 }
 
-func New(c *http.Client, router *mux.Router, endpoint string, t flux.Token) *Client {
+// RequestHook is given each request before it is sent, and may alter
+// it; e.g., to add headers for tracing or authentication.
+type RequestHook func(*http.Request)
+
+// New makes a client for the API at the endpoint given. Options, if
+// given, say how to make connections; see Option.
+func New(c *http.Client, router *mux.Router, endpoint string, t flux.Token, opts ...Option) *Client {
+	if len(opts) > 0 {
+		c = withOptions(c, opts)
+	}
 	return &Client{
 		client:   c,
-		token:    t,
+		auth:     t,
 		router:   router,
 		endpoint: endpoint,
+		etags:    newETagCache(),
+		retry:    NoRetry,
 	}
 }
 
-func (c *Client) ListServices(_ service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+// WithMetrics gives a copy of the client that records each request
+// it makes in the metrics given.
+func (c *Client) WithMetrics(m *Metrics) *Client {
+	instrumented := *c
+	instrumented.metrics = m
+	return &instrumented
+}
+
+// WithAuthenticator gives a copy of the client that authenticates
+// its requests using the scheme given, rather than the token it was
+// created with.
+func (c *Client) WithAuthenticator(a api.Authenticator) *Client {
+	authenticated := *c
+	authenticated.auth = a
+	return &authenticated
+}
+
+// WithRequestHook gives a copy of the client that calls the hook
+// given on each request it makes, after any hooks the client already
+// has.
+func (c *Client) WithRequestHook(h RequestHook) *Client {
+	hooked := *c
+	hooked.hooks = append(append([]RequestHook(nil), c.hooks...), h)
+	return &hooked
+}
+
+func (c *Client) ListServices(ctx context.Context, _ service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
 	var res []flux.ServiceStatus
-	err := c.get(&res, "ListServices", "namespace", namespace)
+	err := c.get(ctx, &res, "ListServices", transport.ListServicesParams{Namespace: namespace})
+	return res, err
+}
+
+func (c *Client) EvaluateImage(ctx context.Context, _ service.InstanceID, image flux.ImageID) (update.Result, error) {
+	var res update.Result
+	err := c.get(ctx, &res, "EvaluateImage", transport.EvaluateImageParams{Image: image})
 	return res, err
 }
 
-func (c *Client) ListImages(_ service.InstanceID, s update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (c *Client) ServiceTopology(ctx context.Context, _ service.InstanceID) ([]flux.ServiceTopology, error) {
+	var res []flux.ServiceTopology
+	err := c.get(ctx, &res, "ServiceTopology", nil)
+	return res, err
+}
+
+func (c *Client) ListImages(ctx context.Context, _ service.InstanceID, s update.ServiceSpec) ([]flux.ImageStatus, error) {
 	var res []flux.ImageStatus
-	err := c.get(&res, "ListImages", "service", string(s))
+	err := c.get(ctx, &res, "ListImages", transport.ListImagesParams{Service: s})
 	return res, err
 }
 
-func (c *Client) UpdateImages(_ service.InstanceID, s update.ReleaseSpec, cause update.Cause) (job.ID, error) {
-	args := []string{
-		"image", string(s.ImageSpec),
-		"kind", string(s.Kind),
-		"user", cause.User,
-	}
-	for _, spec := range s.ServiceSpecs {
-		args = append(args, "service", string(spec))
-	}
-	for _, ex := range s.Excludes {
-		args = append(args, "exclude", string(ex))
-	}
-	if cause.Message != "" {
-		args = append(args, "message", cause.Message)
-	}
+func (c *Client) SyncHealth(ctx context.Context, _ service.InstanceID) (remote.SyncHealth, error) {
+	var res remote.SyncHealth
+	err := c.get(ctx, &res, "SyncHealth", nil)
+	return res, err
+}
+
+func (c *Client) ListResources(ctx context.Context, _ service.InstanceID) ([]flux.ResourceStatus, error) {
+	var res []flux.ResourceStatus
+	err := c.get(ctx, &res, "ListResources", nil)
+	return res, err
+}
+
+func (c *Client) SyncWait(ctx context.Context, _ service.InstanceID, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	var res remote.SyncReport
+	err := c.methodWithResp(ctx, "POST", &res, "SyncWait", req, nil)
+	return res, err
+}
 
+func (c *Client) UpdateImages(ctx context.Context, _ service.InstanceID, s update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+	params := transport.UpdateImagesParams{
+		Services:    s.ServiceSpecs,
+		Image:       s.ImageSpec,
+		Kind:        s.Kind,
+		Excludes:    s.Excludes,
+		Containers:  s.Containers,
+		CauseParams: transport.NewCauseParams(cause),
+	}
 	var res job.ID
-	err := c.methodWithResp("POST", &res, "UpdateImages", nil, args...)
+	err := c.methodWithResp(ctx, "POST", &res, "UpdateImages", nil, params)
 	return res, err
 }
 
-func (c *Client) SyncNotify(_ service.InstanceID) error {
-	if err := c.post("SyncNotify"); err != nil {
+func (c *Client) PlanRelease(ctx context.Context, _ service.InstanceID, s update.ReleaseSpec) (update.Result, error) {
+	params := transport.PlanReleaseParams{
+		Services:   s.ServiceSpecs,
+		Image:      s.ImageSpec,
+		Excludes:   s.Excludes,
+		Containers: s.Containers,
+	}
+	var res update.Result
+	err := c.methodWithResp(ctx, "POST", &res, "PlanRelease", nil, params)
+	return res, err
+}
+
+func (c *Client) SyncNotify(ctx context.Context, _ service.InstanceID) error {
+	if err := c.post(ctx, "SyncNotify"); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *Client) JobStatus(_ service.InstanceID, jobID job.ID) (job.Status, error) {
+func (c *Client) ResetGitToRemote(ctx context.Context, _ service.InstanceID) (string, error) {
+	var res string
+	err := c.methodWithResp(ctx, "POST", &res, "ResetGitToRemote", nil, nil)
+	return res, err
+}
+
+func (c *Client) JobStatus(ctx context.Context, _ service.InstanceID, jobID job.ID) (job.Status, error) {
 	var res job.Status
-	err := c.get(&res, "JobStatus", "id", string(jobID))
+	err := c.get(ctx, &res, "JobStatus", transport.JobParams{ID: jobID})
+	return res, err
+}
+
+func (c *Client) JobLog(ctx context.Context, _ service.InstanceID, jobID job.ID) (job.Log, error) {
+	var res job.Log
+	err := c.get(ctx, &res, "JobLog", transport.JobParams{ID: jobID})
 	return res, err
 }
 
-func (c *Client) SyncStatus(_ service.InstanceID, ref string) ([]string, error) {
+// ReportJob reports the final status of a job run by a worker. This
+// is only served by the daemon, so the client must have been made
+// with the daemon's router, and the token the worker was given.
+func (c *Client) ReportJob(ctx context.Context, jobID job.ID, status job.Status) error {
+	return c.methodWithResp(ctx, "POST", nil, "ReportJob", status, transport.JobParams{ID: jobID})
+}
+
+// WatchJob sends the status of a job to updates each time it
+// changes, until the job finishes or the context is cancelled.
+func (c *Client) WatchJob(ctx context.Context, _ service.InstanceID, jobID job.ID, updates chan<- job.Status) error {
+	u, err := transport.MakeURLWithParams(c.endpoint, c.router, "WatchJob", transport.JobParams{ID: jobID})
+	if err != nil {
+		return errors.Wrap(err, "constructing URL")
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return errors.Wrapf(err, "constructing request %s", u)
+	}
+	req = req.WithContext(ctx)
+	c.auth.Authenticate(req)
+	req.Header.Set("Accept", transport.EventStreamContentType+", application/json")
+
+	resp, err := c.executeRequest("WatchJob", req)
+	if err != nil {
+		return errors.Wrap(err, "executing HTTP request")
+	}
+	defer resp.Body.Close()
+
+	// Cancelling the request's context interrupts any read in
+	// progress, so we don't need to close the body ourselves.
+	return transport.ReadJobStatusEvents(ctx, resp.Body, updates)
+}
+
+// WatchEvents sends each event logged for the instance to events,
+// until the context is cancelled or the server hangs up.
+func (c *Client) WatchEvents(ctx context.Context, _ service.InstanceID, events chan<- history.Event) error {
+	u, err := transport.MakeURL(c.endpoint, c.router, "WatchEvents")
+	if err != nil {
+		return errors.Wrap(err, "constructing URL")
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	ws, err := websocket.Dial(c.client, "flux-client", c.auth, u)
+	if err != nil {
+		return errors.Wrap(err, "connecting to event stream")
+	}
+	defer ws.Close()
+
+	// Closing the websocket is how to interrupt a read in progress
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+		case <-done:
+		}
+	}()
+
+	dec := json.NewDecoder(ws)
+	for {
+		var e history.Event
+		if err := dec.Decode(&e); err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+			if websocket.IsExpectedWSCloseError(err) {
+				return nil
+			}
+			return errors.Wrap(err, "reading event stream")
+		}
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *Client) SyncStatus(ctx context.Context, _ service.InstanceID, ref string) ([]string, error) {
 	var res []string
-	err := c.get(&res, "SyncStatus", "ref", ref)
+	err := c.get(ctx, &res, "SyncStatus", transport.SyncStatusParams{Ref: ref})
 	return res, err
 }
 
-func (c *Client) UpdatePolicies(_ service.InstanceID, updates policy.Updates, cause update.Cause) (job.ID, error) {
-	args := []string{"user", cause.User}
-	if cause.Message != "" {
-		args = append(args, "message", cause.Message)
+func (c *Client) UpdatePolicies(ctx context.Context, _ service.InstanceID, updates policy.Updates, cause update.Cause, dryRun bool) (job.ID, error) {
+	params := transport.UpdatePoliciesParams{
+		DryRun:      dryRun,
+		CauseParams: transport.NewCauseParams(cause),
 	}
 	var res job.ID
-	return res, c.methodWithResp("PATCH", &res, "UpdatePolicies", updates, args...)
+	return res, c.methodWithResp(ctx, "PATCH", &res, "UpdatePolicies", updates, params)
 }
 
-func (c *Client) LogEvent(_ service.InstanceID, event history.Event) error {
-	return c.postWithBody("LogEvent", event)
+func (c *Client) ValidatePolicies(ctx context.Context, _ service.InstanceID, updates policy.Updates) error {
+	return c.postWithBody(ctx, "ValidatePolicies", updates)
+}
+
+func (c *Client) UpdateBatch(ctx context.Context, _ service.InstanceID, steps update.BatchSpec, cause update.Cause) (job.ID, error) {
+	params := transport.UpdateBatchParams{
+		CauseParams: transport.NewCauseParams(cause),
+	}
+	var res job.ID
+	return res, c.methodWithResp(ctx, "POST", &res, "UpdateBatch", steps, params)
 }
 
-func (c *Client) History(_ service.InstanceID, s update.ServiceSpec, before time.Time, limit int64, after time.Time) ([]history.Entry, error) {
-	params := []string{"service", string(s)}
-	if !before.IsZero() {
-		params = append(params, "before", before.Format(time.RFC3339Nano))
+func (c *Client) AddManifests(ctx context.Context, _ service.InstanceID, spec update.AddSpec, cause update.Cause) (job.ID, error) {
+	params := transport.AddManifestsParams{
+		CauseParams: transport.NewCauseParams(cause),
 	}
-	if !after.IsZero() {
-		params = append(params, "after", after.Format(time.RFC3339Nano))
+	var res job.ID
+	return res, c.methodWithResp(ctx, "POST", &res, "AddManifests", spec, params)
+}
+
+func (c *Client) Rollback(ctx context.Context, _ service.InstanceID, spec update.RollbackSpec, cause update.Cause) (job.ID, error) {
+	params := transport.RollbackParams{
+		CauseParams: transport.NewCauseParams(cause),
+	}
+	var res job.ID
+	return res, c.methodWithResp(ctx, "POST", &res, "Rollback", spec, params)
+}
+
+func (c *Client) LogEvent(_ service.InstanceID, event history.Event) error {
+	return c.postWithBody(context.Background(), "LogEvent", event)
+}
+
+func (c *Client) History(ctx context.Context, _ service.InstanceID, s update.ServiceSpec, before time.Time, limit int64, after time.Time) ([]history.Entry, error) {
+	params := transport.HistoryParams{
+		Service: s,
+		Before:  before,
+		After:   after,
 	}
 	if limit >= 0 {
-		params = append(params, "limit", fmt.Sprint(limit))
+		params.Limit = &limit
 	}
 	var res []history.Entry
-	err := c.get(&res, "History", params...)
+	err := c.get(ctx, &res, "History", params)
 	return res, err
 }
 
-func (c *Client) GetConfig(_ service.InstanceID, fingerprint string) (service.InstanceConfig, error) {
-	var params []string
-	if fingerprint != "" {
-		params = append(params, "fingerprint", fingerprint)
-	}
+func (c *Client) DeployedImages(ctx context.Context, _ service.InstanceID, id flux.ServiceID, at time.Time) ([]history.DeployedImage, error) {
+	var res []history.DeployedImage
+	err := c.get(ctx, &res, "DeployedImages", transport.DeployedImagesParams{Service: id, At: at})
+	return res, err
+}
+
+func (c *Client) ServiceSummaries(ctx context.Context, _ service.InstanceID) ([]history.ServiceSummary, error) {
+	var res []history.ServiceSummary
+	err := c.get(ctx, &res, "ServiceSummaries", nil)
+	return res, err
+}
+
+func (c *Client) ReleaseNotes(ctx context.Context, _ service.InstanceID, jobID job.ID) (update.ReleaseNotes, error) {
+	var res update.ReleaseNotes
+	err := c.get(ctx, &res, "ReleaseNotes", transport.JobParams{ID: jobID})
+	return res, err
+}
+
+func (c *Client) GetConfig(ctx context.Context, _ service.InstanceID, fingerprint string) (service.InstanceConfig, error) {
 	var res service.InstanceConfig
-	err := c.get(&res, "GetConfig", params...)
+	err := c.get(ctx, &res, "GetConfig", transport.GetConfigParams{Fingerprint: fingerprint})
 	return res, err
 }
 
-func (c *Client) SetConfig(_ service.InstanceID, config service.InstanceConfig) error {
-	return c.postWithBody("SetConfig", config)
+func (c *Client) SetConfig(ctx context.Context, _ service.InstanceID, config service.InstanceConfig) error {
+	return c.postWithBody(ctx, "SetConfig", config)
+}
+
+func (c *Client) PatchConfig(ctx context.Context, _ service.InstanceID, patch service.ConfigPatch) error {
+	return c.patchWithBody(ctx, "PatchConfig", patch)
+}
+
+func (c *Client) ListRecycledConfigs(ctx context.Context, _ service.InstanceID) ([]service.RecycledConfig, error) {
+	var res []service.RecycledConfig
+	err := c.get(ctx, &res, "ListRecycledConfigs", nil)
+	return res, err
 }
 
-func (c *Client) PatchConfig(_ service.InstanceID, patch service.ConfigPatch) error {
-	return c.patchWithBody("PatchConfig", patch)
+func (c *Client) RestoreConfig(ctx context.Context, _ service.InstanceID, index int) error {
+	return c.methodWithResp(ctx, "POST", nil, "RestoreConfig", nil, transport.RestoreConfigParams{Index: index})
 }
 
-func (c *Client) Status(_ service.InstanceID) (service.Status, error) {
+func (c *Client) Status(ctx context.Context, _ service.InstanceID) (service.Status, error) {
 	var res service.Status
-	err := c.get(&res, "Status")
+	err := c.get(ctx, &res, "Status", nil)
 	return res, err
 }
 
-func (c *Client) Export(_ service.InstanceID) ([]byte, error) {
+func (c *Client) Export(ctx context.Context, _ service.InstanceID) ([]byte, error) {
 	var res []byte
-	err := c.get(&res, "Export")
+	err := c.get(ctx, &res, "Export", nil)
 	return res, err
 }
 
-func (c *Client) PublicSSHKey(_ service.InstanceID, regenerate bool) (ssh.PublicKey, error) {
+func (c *Client) ExportAt(ctx context.Context, _ service.InstanceID, ref string) ([]byte, error) {
+	var res []byte
+	err := c.get(ctx, &res, "Export", transport.ExportParams{Ref: ref})
+	return res, err
+}
+
+// ExportTo asks for the export as YAML, and copies it to the writer
+// as it arrives. Services that predate streaming answer with JSON
+// regardless, so that's dealt with too.
+func (c *Client) ExportTo(ctx context.Context, _ service.InstanceID, w io.Writer) error {
+	u, err := transport.MakeURL(c.endpoint, c.router, "Export")
+	if err != nil {
+		return errors.Wrap(err, "constructing URL")
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return errors.Wrapf(err, "constructing request %s", u)
+	}
+	req = req.WithContext(ctx)
+	c.auth.Authenticate(req)
+	req.Header.Set("Accept", transport.YAMLContentType+", application/json;q=0.5")
+	for _, hook := range c.hooks {
+		hook(req)
+	}
+
+	resp, err := c.executeRequest("Export", req)
+	if err != nil {
+		return errors.Wrap(err, "executing HTTP request")
+	}
+	defer resp.Body.Close()
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var config []byte
+		if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+			return errors.Wrap(err, "decoding response from server")
+		}
+		_, err = w.Write(config)
+		return err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return errors.Wrap(err, "reading response from server")
+	}
+	return nil
+}
+
+func (c *Client) PublicSSHKey(ctx context.Context, _ service.InstanceID, regenerate bool) (ssh.PublicKey, error) {
 	if regenerate {
-		err := c.post("RegeneratePublicSSHKey")
+		err := c.post(ctx, "RegeneratePublicSSHKey")
 		if err != nil {
 			return ssh.PublicKey{}, err
 		}
 	}
 
 	var res ssh.PublicKey
-	err := c.get(&res, "GetPublicSSHKey")
+	err := c.get(ctx, &res, "GetPublicSSHKey", nil)
+	return res, err
+}
+
+func (c *Client) Check(ctx context.Context, _ service.InstanceID) (service.CheckReport, error) {
+	var res service.CheckReport
+	err := c.get(ctx, &res, "Check", nil)
+	return res, err
+}
+
+func (c *Client) ListWebhookSecrets(ctx context.Context, _ service.InstanceID) ([]service.WebhookSecret, error) {
+	var res []service.WebhookSecret
+	err := c.get(ctx, &res, "ListWebhookSecrets", nil)
+	return res, err
+}
+
+func (c *Client) CreateWebhookSecret(ctx context.Context, _ service.InstanceID, hook string) (service.WebhookSecret, error) {
+	var res service.WebhookSecret
+	err := c.methodWithResp(ctx, "POST", &res, "CreateWebhookSecret", nil, transport.WebhookParams{Hook: hook})
+	return res, err
+}
+
+func (c *Client) DeleteWebhookSecret(ctx context.Context, _ service.InstanceID, hook string) error {
+	return c.methodWithResp(ctx, "DELETE", nil, "DeleteWebhookSecret", nil, transport.WebhookParams{Hook: hook})
+}
+
+func (c *Client) IssueDaemonToken(ctx context.Context, _ service.InstanceID) (service.DaemonToken, error) {
+	var res service.DaemonToken
+	err := c.methodWithResp(ctx, "POST", &res, "IssueDaemonToken", nil, nil)
+	return res, err
+}
+
+func (c *Client) Promote(ctx context.Context, _ service.InstanceID, spec service.PromotionSpec, cause update.Cause) (string, error) {
+	params := transport.PromoteParams{
+		CauseParams: transport.NewCauseParams(cause),
+	}
+	var res string
+	return res, c.methodWithResp(ctx, "POST", &res, "Promote", spec, params)
+}
+
+func (c *Client) ListPromotions(ctx context.Context, _ service.InstanceID) ([]service.Promotion, error) {
+	var res []service.Promotion
+	err := c.get(ctx, &res, "ListPromotions", nil)
+	return res, err
+}
+
+func (c *Client) CancelPromotion(ctx context.Context, _ service.InstanceID, id string) error {
+	return c.methodWithResp(ctx, "DELETE", nil, "CancelPromotion", nil, transport.PromotionParams{ID: id})
+}
+
+func (c *Client) ExportInstance(ctx context.Context, _ service.InstanceID) (instance.Migration, error) {
+	var res instance.Migration
+	err := c.get(ctx, &res, "ExportInstance", nil)
 	return res, err
 }
 
-// post is a simple query-param only post request
-func (c *Client) post(route string, queryParams ...string) error {
-	return c.postWithBody(route, nil, queryParams...)
+func (c *Client) ImportInstance(ctx context.Context, _ service.InstanceID, m instance.Migration) error {
+	return c.postWithBody(ctx, "ImportInstance", m)
+}
+
+func (c *Client) MigrateInstance(ctx context.Context, _ service.InstanceID, target service.MigrationTarget) error {
+	return c.postWithBody(ctx, "MigrateInstance", target)
+}
+
+func (c *Client) InjectFault(ctx context.Context, _ service.InstanceID, spec remote.FaultSpec) error {
+	return c.postWithBody(ctx, "InjectFault", spec)
+}
+
+// post is a simple post request, with neither parameters nor body
+func (c *Client) post(ctx context.Context, route string) error {
+	return c.postWithBody(ctx, route, nil)
 }
 
 // postWithBody is a more complex post request, which includes a json-ified body.
 // If body is not nil, it is encoded to json before sending
-func (c *Client) postWithBody(route string, body interface{}, queryParams ...string) error {
-	return c.methodWithResp("POST", nil, route, body, queryParams...)
+func (c *Client) postWithBody(ctx context.Context, route string, body interface{}) error {
+	return c.methodWithResp(ctx, "POST", nil, route, body, nil)
 }
 
-func (c *Client) patchWithBody(route string, body interface{}, queryParams ...string) error {
-	return c.methodWithResp("PATCH", nil, route, body, queryParams...)
+func (c *Client) patchWithBody(ctx context.Context, route string, body interface{}) error {
+	return c.methodWithResp(ctx, "PATCH", nil, route, body, nil)
 }
 
-// methodWithResp is the full enchilada, it handles body and query-param
+// methodWithResp is the full enchilada, it handles body and parameter
 // encoding, as well as decoding the response into the provided destination.
 // Note, the response will only be decoded into the dest if the len is > 0.
-func (c *Client) methodWithResp(method string, dest interface{}, route string, body interface{}, queryParams ...string) error {
-	u, err := transport.MakeURL(c.endpoint, c.router, route, queryParams...)
+// The params are given as a struct from the transport package (or nil).
+func (c *Client) methodWithResp(ctx context.Context, method string, dest interface{}, route string, body interface{}, params interface{}) error {
+	u, err := transport.MakeURLWithParams(c.endpoint, c.router, route, params)
 	if err != nil {
 		return errors.Wrap(err, "constructing URL")
 	}
@@ -198,10 +541,14 @@ func (c *Client) methodWithResp(method string, dest interface{}, route string, b
 	if err != nil {
 		return errors.Wrapf(err, "constructing request %s", u)
 	}
-	c.token.Set(req)
+	req = req.WithContext(ctx)
+	c.auth.Authenticate(req)
 	req.Header.Set("Accept", "application/json")
+	for _, hook := range c.hooks {
+		hook(req)
+	}
 
-	resp, err := c.executeRequest(req)
+	resp, err := c.executeRequest(route, req)
 	if err != nil {
 		return errors.Wrap(err, "executing HTTP request")
 	}
@@ -221,8 +568,8 @@ func (c *Client) methodWithResp(method string, dest interface{}, route string, b
 }
 
 // get executes a get request against the flux server. it unmarshals the response into dest.
-func (c *Client) get(dest interface{}, route string, queryParams ...string) error {
-	u, err := transport.MakeURL(c.endpoint, c.router, route, queryParams...)
+func (c *Client) get(ctx context.Context, dest interface{}, route string, params interface{}) error {
+	u, err := transport.MakeURLWithParams(c.endpoint, c.router, route, params)
 	if err != nil {
 		return errors.Wrap(err, "constructing URL")
 	}
@@ -231,45 +578,183 @@ func (c *Client) get(dest interface{}, route string, queryParams ...string) erro
 	if err != nil {
 		return errors.Wrapf(err, "constructing request %s", u)
 	}
-	c.token.Set(req)
+	req = req.WithContext(ctx)
+	c.auth.Authenticate(req)
 	req.Header.Set("Accept", "application/json")
+	for _, hook := range c.hooks {
+		hook(req)
+	}
+	// If we've had this before, and it came with an ETag, we only
+	// need it again if it's changed.
+	cached, haveCached := c.etags.get(u.String())
+	if haveCached {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
 
-	resp, err := c.executeRequest(req)
+	resp, err := c.executeRequest(route, req)
 	if err != nil {
 		return errors.Wrap(err, "executing HTTP request")
 	}
 	defer resp.Body.Close()
 
-	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+	var body []byte
+	if resp.StatusCode == http.StatusNotModified && haveCached {
+		body = cached.body
+	} else {
+		if body, err = ioutil.ReadAll(resp.Body); err != nil {
+			return errors.Wrap(err, "reading response from server")
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			c.etags.put(u.String(), etag, body)
+		}
+	}
+	if err := json.Unmarshal(body, dest); err != nil {
 		return errors.Wrap(err, "decoding response from server")
 	}
 	return nil
 }
 
-func (c *Client) executeRequest(req *http.Request) (*http.Response, error) {
+// executeRequest does the request, and retries it if it failed and
+// the retry policy that applies allows it.
+func (c *Client) executeRequest(route string, req *http.Request) (*http.Response, error) {
+	policy := c.retryPolicy(req)
+	for attempt := 1; ; attempt++ {
+		resp, err := c.executeOnce(route, req)
+		if attempt >= policy.MaxAttempts || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		// The body (if there is one) has been consumed, so the
+		// next attempt needs a fresh one.
+		req = req.WithContext(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, errors.Wrap(err, "resetting request body for retry")
+			}
+		}
+		if c.metrics != nil {
+			c.metrics.Retries.WithLabelValues(req.Method, route).Inc()
+		}
+	}
+}
+
+func (c *Client) executeOnce(route string, req *http.Request) (*http.Response, error) {
+	// Asking for gzip explicitly (rather than leaving it to
+	// http.Transport) means we get compressed responses whatever
+	// RoundTripper the client was given.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	begin := time.Now()
 	resp, err := c.client.Do(req)
+	if c.metrics != nil {
+		c.metrics.Observe(req.Method, route, false, begin, resp, err)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "executing HTTP request")
 	}
+	if err := decompressBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 	switch resp.StatusCode {
-	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusNotModified:
 		return resp, nil
 	case http.StatusUnauthorized:
 		return resp, transport.ErrorUnauthorized
 	default:
-		// Use the content type to discriminate between `flux.BaseError`,
-		// and the previous "any old error"
-		if strings.HasPrefix(resp.Header.Get(http.CanonicalHeaderKey("Content-Type")), "application/json") {
-			var niceError flux.BaseError
-			if err := json.NewDecoder(resp.Body).Decode(&niceError); err != nil {
-				return resp, errors.Wrap(err, "decoding error in response body")
-			}
-			return resp, &niceError
+		return resp, responseError(resp)
+	}
+}
+
+// responseError reads the error out of an unsuccessful response, and
+// gives it the same type it had when it was returned by the server,
+// going by the status code, so that callers can tell e.g., something
+// missing from a problem with their input.
+func responseError(resp *http.Response) error {
+	var baseErr *flux.BaseError
+	// Use the content type to discriminate between `flux.BaseError`,
+	// and the previous "any old error"
+	if strings.HasPrefix(resp.Header.Get(http.CanonicalHeaderKey("Content-Type")), "application/json") {
+		var niceError flux.BaseError
+		if err := json.NewDecoder(resp.Body).Decode(&niceError); err != nil {
+			return errors.Wrap(err, "decoding error in response body")
+		}
+		if niceError.Err == nil {
+			niceError.Err = errors.New(resp.Status)
 		}
+		baseErr = &niceError
+	} else {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return resp, errors.Wrap(err, "reading assumed plaintext response body")
+			return errors.Wrap(err, "reading assumed plaintext response body")
 		}
-		return resp, errors.New(resp.Status + " " + string(body))
+		msg := resp.Status + " " + string(body)
+		baseErr = &flux.BaseError{
+			Help: msg,
+			Err:  errors.New(msg),
+		}
+	}
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusNotFound:
+		return flux.Missing{BaseError: baseErr}
+	case code == http.StatusUnprocessableEntity:
+		return flux.UserConfigProblem{BaseError: baseErr}
+	case code == http.StatusTooManyRequests:
+		return transport.TooManyRequests{
+			BaseError:  baseErr,
+			RetryAfter: retryAfter(resp),
+		}
+	case code >= 500:
+		return flux.ServerException{BaseError: baseErr}
 	}
+	return baseErr
+}
+
+// retryAfter gets the delay from a Retry-After header, if there is
+// one. Only the number-of-seconds form is understood, since that is
+// what the service sends.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// decompressBody replaces the body of a gzipped response with a
+// reader of the uncompressed content.
+func decompressBody(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading gzipped response body")
+	}
+	resp.Body = gzipBody{gz, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
 }
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	transport "github.com/weaveworks/flux/http"
+)
+
+func TestClientDecompressesResponses(t *testing.T) {
+	big := []string{strings.Repeat("a", 4096)}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("expected client to ask for gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		transport.JSONResponse(w, r, big)
+	}))
+	defer ts.Close()
+
+	// Make sure it's the client doing the decompression, and not the
+	// transport.
+	hc := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	c := New(hc, transport.NewAPIRouter(), ts.URL, "")
+	res, err := c.SyncStatus(context.Background(), "", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != big[0] {
+		t.Errorf("expected response to survive compression, got %d entries", len(res))
+	}
+}
+
+func TestClientConditionalGet(t *testing.T) {
+	var requests, conditional int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		transport.ETagJSONResponse(w, r, []string{"abc123"})
+	}))
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "")
+	for i := 0; i < 2; i++ {
+		res, err := c.SyncStatus(context.Background(), "", "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0] != "abc123" {
+			t.Errorf("request %d: unexpected result %v", i, res)
+		}
+	}
+	if requests != 2 || conditional != 1 {
+		t.Errorf("expected second request to be conditional; got %d requests, %d conditional", requests, conditional)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		transport.JSONResponse(w, r, []string{})
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.SyncStatus(ctx, "", "HEAD")
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("expected error from cancelled request")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not cancelled")
+	}
+}
+
+func TestClientRequestHooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Trace-Id") != "abc" || r.Header.Get("X-Audit") != "second" {
+			t.Errorf("expected headers from hooks, got %v", r.Header)
+		}
+		transport.JSONResponse(w, r, []string{})
+	}))
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").
+		WithRequestHook(func(req *http.Request) {
+			req.Header.Set("X-Trace-Id", "abc")
+			req.Header.Set("X-Audit", "first")
+		}).
+		WithRequestHook(func(req *http.Request) {
+			req.Header.Set("X-Audit", "second")
+		})
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SyncNotify(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientExportTo(t *testing.T) {
+	config := strings.Repeat("---\nkind: Namespace\n", 1000)
+	for name, handler := range map[string]http.HandlerFunc{
+		"streaming": func(w http.ResponseWriter, r *http.Request) {
+			transport.StreamResponse(w, r, transport.YAMLContentType, func(out io.Writer) error {
+				_, err := io.WriteString(out, config)
+				return err
+			})
+		},
+		// Services from before streaming send JSON whatever is asked for
+		"json": func(w http.ResponseWriter, r *http.Request) {
+			transport.JSONResponse(w, r, []byte(config))
+		},
+	} {
+		ts := httptest.NewServer(handler)
+		c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "")
+		var out bytes.Buffer
+		if err := c.ExportTo(context.Background(), "", &out); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		if out.String() != config {
+			t.Errorf("%s: expected %d bytes of config, got %d", name, len(config), out.Len())
+		}
+		ts.Close()
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		handler http.HandlerFunc
+		check   func(error) bool
+	}{
+		"missing": {
+			func(w http.ResponseWriter, r *http.Request) {
+				transport.ErrorResponse(w, r, flux.Missing{&flux.BaseError{Help: "no such thing", Err: errors.New("missing")}})
+			},
+			func(err error) bool { _, ok := err.(flux.Missing); return ok },
+		},
+		"user config": {
+			func(w http.ResponseWriter, r *http.Request) {
+				transport.ErrorResponse(w, r, flux.UserConfigProblem{&flux.BaseError{Help: "fix your config", Err: errors.New("bad config")}})
+			},
+			func(err error) bool { _, ok := err.(flux.UserConfigProblem); return ok },
+		},
+		"server exception": {
+			func(w http.ResponseWriter, r *http.Request) {
+				transport.ErrorResponse(w, r, errors.New("whoops"))
+			},
+			func(err error) bool { _, ok := err.(flux.ServerException); return ok },
+		},
+		"rate limited": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "3")
+				transport.WriteError(w, r, http.StatusTooManyRequests, &flux.BaseError{Help: "slow down", Err: errors.New("rate limited")})
+			},
+			func(err error) bool {
+				tooMany, ok := err.(transport.TooManyRequests)
+				return ok && tooMany.RetryAfter == 3*time.Second
+			},
+		},
+		"plain text": {
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no such thing", http.StatusNotFound)
+			},
+			func(err error) bool { _, ok := err.(flux.Missing); return ok },
+		},
+	} {
+		ts := httptest.NewServer(tc.handler)
+		c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "")
+		_, err := c.ListServices(context.Background(), "", "")
+		err = errors.Cause(err)
+		if err == nil || !tc.check(err) {
+			t.Errorf("%s: did not get expected type of error; got %#v", name, err)
+		}
+		if helpful, ok := err.(flux.HelpfulError); !ok || helpful.Base().Help == "" {
+			t.Errorf("%s: expected error with help text, got %#v", name, err)
+		}
+		ts.Close()
+	}
+}
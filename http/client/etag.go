@@ -0,0 +1,35 @@
+package client
+
+import (
+	"sync"
+)
+
+// etagCache remembers the last response body for each URL that came
+// with an ETag, so a request for the same URL can be made conditional
+// and a 304 Not Modified answered from here.
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+func newETagCache() *etagCache {
+	return &etagCache{entries: map[string]etagEntry{}}
+}
+
+func (c *etagCache) get(url string) (etagEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	return e, ok
+}
+
+func (c *etagCache) put(url, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = etagEntry{etag: etag, body: body}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+// Metrics are what a Client records about the requests it makes, if
+// it's been given them with WithMetrics. The labels match those the
+// server uses, so the two sides can be compared.
+type Metrics struct {
+	RequestDuration *stdprometheus.HistogramVec
+	Errors          *stdprometheus.CounterVec
+	Retries         *stdprometheus.CounterVec
+}
+
+// The kinds of error counted in Metrics.Errors
+const (
+	ErrorKindTransport    = "transport" // no response at all
+	ErrorKindUnauthorized = "unauthorized"
+	ErrorKindMissing      = "missing"
+	ErrorKindUserConfig   = "user_config"
+	ErrorKindRateLimited  = "rate_limited"
+	ErrorKindClient       = "client" // any other 4xx
+	ErrorKindServer       = "server"
+)
+
+// NewMetrics makes the client metrics and registers them with the
+// registry given.
+func NewMetrics(reg stdprometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		RequestDuration: stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{
+			Namespace: "flux",
+			Subsystem: "client",
+			Name:      "request_duration_seconds",
+			Help:      "Time (in seconds) spent making HTTP requests to the flux API.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelRoute, "status_code", "ws"}),
+		Errors: stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "client",
+			Name:      "request_errors_total",
+			Help:      "Number of HTTP requests to the flux API that failed, by the kind of failure.",
+		}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelRoute, "kind"}),
+		Retries: stdprometheus.NewCounterVec(stdprometheus.CounterOpts{
+			Namespace: "flux",
+			Subsystem: "client",
+			Name:      "request_retries_total",
+			Help:      "Number of times HTTP requests to the flux API were retried.",
+		}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelRoute}),
+	}
+	for _, c := range []stdprometheus.Collector{m.RequestDuration, m.Errors, m.Retries} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Observe records a request made to the route given, which began at
+// begin and got the response (which may be nil, if there was an
+// error) given. This is for requests the Client makes, and for those
+// made by other means, e.g., to connect a websocket, for which ws
+// should be true.
+func (m *Metrics) Observe(method, route string, ws bool, begin time.Time, resp *http.Response, err error) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	m.RequestDuration.WithLabelValues(method, route, code, strconv.FormatBool(ws)).Observe(time.Since(begin).Seconds())
+	if kind := errorKind(resp, err); kind != "" {
+		m.Errors.WithLabelValues(method, route, kind).Inc()
+	}
+}
+
+// errorKind says what kind of failure a request had, going by the
+// response status (in the same way as responseError does), or
+// returns "" if it didn't fail.
+func errorKind(resp *http.Response, err error) string {
+	if resp == nil {
+		if err != nil {
+			return ErrorKindTransport
+		}
+		return ""
+	}
+	switch code := resp.StatusCode; {
+	case code == http.StatusUnauthorized:
+		return ErrorKindUnauthorized
+	case code == http.StatusNotFound:
+		return ErrorKindMissing
+	case code == http.StatusUnprocessableEntity:
+		return ErrorKindUserConfig
+	case code == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case code >= 500:
+		return ErrorKindServer
+	case code >= 400:
+		return ErrorKindClient
+	}
+	return ""
+}
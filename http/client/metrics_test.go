@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	transport "github.com/weaveworks/flux/http"
+)
+
+func TestClientRecordsMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["abc123"]`))
+	}))
+	defer ts.Close()
+
+	reg := stdprometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithMetrics(metrics)
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 1 { // no errors, so only durations
+		t.Fatalf("expected one request duration series, got %+v", families)
+	}
+	labels := map[string]string{}
+	for _, l := range families[0].GetMetric()[0].GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["route"] != "SyncStatus" || labels["status_code"] != "200" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if n := families[0].GetMetric()[0].GetHistogram().GetSampleCount(); n != 1 {
+		t.Errorf("expected one observation, got %d", n)
+	}
+}
+
+func TestClientRecordsErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such job", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	reg := stdprometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithMetrics(metrics)
+	if _, err := c.JobStatus(context.Background(), "", "job-1"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, f := range families {
+		if f.GetName() != "flux_client_request_errors_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["route"] == "JobStatus" && labels["kind"] == ErrorKindMissing && m.GetCounter().GetValue() == 1 {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected one missing error for JobStatus, got %+v", families)
+	}
+}
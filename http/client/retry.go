@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy says how many times to try a request, and how long to
+// wait in between. Only requests that are safe to repeat (i.e., with
+// idempotent methods) are retried, and only if they failed to get a
+// response at all, or got a server error (5xx) as a response.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times to try a request,
+	// including the first; one or fewer means no retries.
+	MaxAttempts int
+	// InitialBackoff is how long to wait before the first retry;
+	// it's doubled for each retry after that, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of each backoff that is randomised,
+	// so that clients that failed together don't all retry
+	// together.
+	Jitter float64
+}
+
+var (
+	// NoRetry is the policy clients start with: every request is
+	// tried exactly once.
+	NoRetry = RetryPolicy{MaxAttempts: 1}
+
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Jitter:         0.2,
+	}
+)
+
+// backoff gives the time to wait before the retry following the
+// attempt given (counting from one).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(p.Jitter * float64(d) * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy gives a context that makes requests made
+// with it use the retry policy given, rather than the client's.
+func ContextWithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// WithRetryPolicy gives a copy of the client that retries requests
+// according to the policy given.
+func (c *Client) WithRetryPolicy(p RetryPolicy) *Client {
+	retrying := *c
+	retrying.retry = p
+	return &retrying
+}
+
+func (c *Client) retryPolicy(req *http.Request) RetryPolicy {
+	switch req.Method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+	default:
+		return NoRetry
+	}
+	if p, ok := req.Context().Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	return c.retry
+}
+
+// shouldRetry decides whether the outcome of an attempt is worth
+// trying again for.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if resp == nil {
+		return err != nil
+	}
+	return resp.StatusCode >= 500
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	transport "github.com/weaveworks/flux/http"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+	Jitter:         0.5,
+}
+
+// failingServer answers with 503 Service Unavailable until it's been
+// asked `failures` times.
+func failingServer(failures int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if *requests <= failures {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		transport.JSONResponse(w, r, []string{"abc123"})
+	}))
+}
+
+func TestClientRetriesGet(t *testing.T) {
+	var requests int
+	ts := failingServer(2, &requests)
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithRetryPolicy(testRetryPolicy)
+	res, err := c.SyncStatus(context.Background(), "", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != "abc123" {
+		t.Errorf("unexpected result %v", res)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 attempts, got %d", requests)
+	}
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	var requests int
+	ts := failingServer(5, &requests)
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithRetryPolicy(testRetryPolicy)
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err == nil {
+		t.Error("expected error after running out of attempts")
+	}
+	if requests != testRetryPolicy.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", testRetryPolicy.MaxAttempts, requests)
+	}
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	var requests int
+	ts := failingServer(1, &requests)
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithRetryPolicy(testRetryPolicy)
+	if err := c.SyncNotify(context.Background(), ""); err == nil {
+		t.Error("expected error from POST")
+	}
+	if requests != 1 {
+		t.Errorf("expected POST to be attempted once, got %d", requests)
+	}
+}
+
+func TestClientRetryPolicyOverride(t *testing.T) {
+	var requests int
+	ts := failingServer(1, &requests)
+	defer ts.Close()
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), ts.URL, "").WithRetryPolicy(testRetryPolicy)
+	ctx := ContextWithRetryPolicy(context.Background(), NoRetry)
+	if _, err := c.SyncStatus(ctx, "", "HEAD"); err == nil {
+		t.Error("expected error when retries are turned off for the request")
+	}
+	if requests != 1 {
+		t.Errorf("expected one attempt, got %d", requests)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if d := p.backoff(attempt); d != expected {
+			t.Errorf("attempt %d: expected backoff %s, got %s", attempt, expected, d)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(2); d < time.Second || d > 3*time.Second {
+			t.Fatalf("expected jittered backoff within 50%% of 2s, got %s", d)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// An Option changes how the client makes its connections. If any
+// are given to New, the client gets a transport of its own, made
+// according to the options, in place of whichever transport the
+// *http.Client given has; the rest of the *http.Client (e.g., its
+// timeout) is kept.
+type Option func(*transportOptions)
+
+type transportOptions struct {
+	tls         *tls.Config
+	proxy       func(*http.Request) (*url.URL, error)
+	dialTimeout time.Duration
+}
+
+// The same as for http.DefaultTransport
+const defaultDialTimeout = 30 * time.Second
+
+// WithTLSConfig makes the client use the TLS config given, e.g., to
+// present a client certificate or to trust a private CA. See
+// LoadTLSConfig for making one from files.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *transportOptions) {
+		o.tls = c
+	}
+}
+
+// WithProxy makes the client send its requests via the proxy at the
+// URL given; a nil URL means don't use a proxy at all. Without this
+// option, the proxy is taken from the environment (HTTPS_PROXY and
+// so on).
+func WithProxy(proxyURL *url.URL) Option {
+	return func(o *transportOptions) {
+		if proxyURL == nil {
+			o.proxy = nil
+			return
+		}
+		o.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithDialTimeout limits how long the client waits for a connection
+// to be made.
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *transportOptions) {
+		o.dialTimeout = d
+	}
+}
+
+func withOptions(c *http.Client, opts []Option) *http.Client {
+	o := transportOptions{
+		proxy:       http.ProxyFromEnvironment,
+		dialTimeout: defaultDialTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var configured http.Client
+	if c != nil {
+		configured = *c
+	}
+	configured.Transport = &http.Transport{
+		Proxy: o.proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   o.dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       o.tls,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &configured
+}
+
+// LoadTLSConfig makes a TLS config from PEM files. If caFile is
+// given, the certificates in it are trusted in place of the system's
+// certificate authorities. If certFile and keyFile are given, they
+// are presented as the client's certificate. Any of them may be
+// empty.
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading CA certificates")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case certFile != "" || keyFile != "":
+		return nil, errors.New("a client certificate needs both the certificate and the key")
+	}
+	return config, nil
+}
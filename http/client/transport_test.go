@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	transport "github.com/weaveworks/flux/http"
+)
+
+// writeSelfSigned makes a self-signed certificate, and writes it and
+// its key to files in dir.
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fluxctl"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-client-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, clientCert := writeSelfSigned(t, dir)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transport.JSONResponse(w, r, []string{"ok"})
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	ts.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trusting the server, but without a client certificate, it
+	// shouldn't get through
+	tlsConfig, err := LoadTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(nil, transport.NewAPIRouter(), ts.URL, "", WithTLSConfig(tlsConfig))
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+
+	tlsConfig, err = LoadTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = New(nil, transport.NewAPIRouter(), ts.URL, "", WithTLSConfig(tlsConfig))
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := LoadTLSConfig("", certFile, ""); err == nil {
+		t.Error("expected error for certificate without key")
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		transport.JSONResponse(w, r, []string{"ok"})
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	c := New(http.DefaultClient, transport.NewAPIRouter(), "http://flux.example.com/api/flux", "", WithProxy(proxyURL))
+	if _, err := c.SyncStatus(context.Background(), "", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://flux.example.com/api/flux/v6/sync?ref=HEAD"; proxied != expected {
+		t.Errorf("expected request for %q to go via proxy, got %q", expected, proxied)
+	}
+}
@@ -1,38 +1,32 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
-	"github.com/weaveworks/common/middleware"
 
 	"github.com/weaveworks/flux"
 	transport "github.com/weaveworks/flux/http"
 	"github.com/weaveworks/flux/job"
-	fluxmetrics "github.com/weaveworks/flux/metrics"
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
-var (
-	requestDuration = stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{
-		Namespace: "flux",
-		Name:      "request_duration_seconds",
-		Help:      "Time (in seconds) spent serving HTTP requests.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelRoute, "status_code", "ws"})
-)
-
 // An API server for the daemon
 func NewRouter() *mux.Router {
 	r := transport.NewAPIRouter()
 	// All old versions are deprecated in the daemon. Use an up to
 	// date client!
 	transport.DeprecateVersions(r, "v1", "v2", "v3", "v4", "v5")
+	// Workers running jobs on the daemon's behalf report back here
+	r.NewRoute().Name("ReportJob").Methods("POST").Path("/v6/jobs/{id}/report")
 	// We assume every request that doesn't match a route is a client
 	// calling an old or hitherto unsupported API.
 	r.NewRoute().Name("NotFound").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -42,31 +36,48 @@ func NewRouter() *mux.Router {
 	return r
 }
 
-func NewHandler(d remote.Platform, r *mux.Router) http.Handler {
-	handle := HTTPServer{d}
+// NewHandler makes the daemon's API handler. If reports is not nil,
+// reports from workers are passed on to it; otherwise, they are
+// refused.
+func NewHandler(d remote.Platform, r *mux.Router, reports *job.Reports) http.Handler {
+	handle := HTTPServer{d, reports}
 	r.Get("SyncNotify").HandlerFunc(handle.SyncNotify)
+	r.Get("ResetGitToRemote").HandlerFunc(handle.ResetGitToRemote)
 	r.Get("JobStatus").HandlerFunc(handle.JobStatus)
+	r.Get("JobLog").HandlerFunc(handle.JobLog)
+	r.Get("WatchJob").HandlerFunc(handle.WatchJob)
+	r.Get("ReportJob").HandlerFunc(handle.ReportJob)
 	r.Get("SyncStatus").HandlerFunc(handle.SyncStatus)
+	r.Get("SyncHealth").HandlerFunc(handle.SyncHealth)
+	r.Get("ListResources").HandlerFunc(handle.ListResources)
+	r.Get("SyncWait").HandlerFunc(handle.SyncWait)
 	r.Get("UpdateImages").HandlerFunc(handle.UpdateImages)
+	r.Get("PlanRelease").HandlerFunc(handle.PlanRelease)
 	r.Get("UpdatePolicies").HandlerFunc(handle.UpdatePolicies)
+	r.Get("ValidatePolicies").HandlerFunc(handle.ValidatePolicies)
+	r.Get("UpdateBatch").HandlerFunc(handle.UpdateBatch)
+	r.Get("AddManifests").HandlerFunc(handle.AddManifests)
+	r.Get("Rollback").HandlerFunc(handle.Rollback)
 	r.Get("ListServices").HandlerFunc(handle.ListServices)
 	r.Get("ListImages").HandlerFunc(handle.ListImages)
+	r.Get("EvaluateImage").HandlerFunc(handle.EvaluateImage)
+	r.Get("ServiceTopology").HandlerFunc(handle.ServiceTopology)
 	r.Get("Export").HandlerFunc(handle.Export)
 	r.Get("GetPublicSSHKey").HandlerFunc(handle.GetPublicSSHKey)
 	r.Get("RegeneratePublicSSHKey").HandlerFunc(handle.RegeneratePublicSSHKey)
+	r.Get("Check").HandlerFunc(handle.Check)
+	r.Get("Spec").HandlerFunc(transport.SpecHandler(r, "Flux daemon API", daemonOperations()))
 
-	return middleware.Instrument{
-		RouteMatcher: r,
-		Duration:     requestDuration,
-	}.Wrap(r)
+	return transport.Instrument(r, r)
 }
 
 type HTTPServer struct {
-	daemon remote.Platform
+	daemon  remote.Platform
+	reports *job.Reports
 }
 
 func (s HTTPServer) SyncNotify(w http.ResponseWriter, r *http.Request) {
-	err := s.daemon.SyncNotify()
+	err := s.daemon.SyncNotify(r.Context())
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -74,9 +85,18 @@ func (s HTTPServer) SyncNotify(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (s HTTPServer) ResetGitToRemote(w http.ResponseWriter, r *http.Request) {
+	rev, err := s.daemon.ResetGitToRemote(r.Context())
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, rev)
+}
+
 func (s HTTPServer) JobStatus(w http.ResponseWriter, r *http.Request) {
 	id := job.ID(mux.Vars(r)["id"])
-	status, err := s.daemon.JobStatus(id)
+	status, err := s.daemon.JobStatus(r.Context(), id)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -84,9 +104,53 @@ func (s HTTPServer) JobStatus(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, status)
 }
 
+func (s HTTPServer) JobLog(w http.ResponseWriter, r *http.Request) {
+	id := job.ID(mux.Vars(r)["id"])
+	log, err := s.daemon.JobLog(r.Context(), id)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, log)
+}
+
+func (s HTTPServer) WatchJob(w http.ResponseWriter, r *http.Request) {
+	id := job.ID(mux.Vars(r)["id"])
+	transport.StreamJobStatus(w, r, func(ctx context.Context, updates chan<- job.Status) error {
+		return remote.WatchJob(ctx, s.daemon, id, updates)
+	})
+}
+
+// The token a worker was given comes back with its report, the same
+// way a flux.Token is sent.
+const reportTokenPrefix = "Scope-Probe token="
+
+func (s HTTPServer) ReportJob(w http.ResponseWriter, r *http.Request) {
+	id := job.ID(mux.Vars(r)["id"])
+	var status job.Status
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrap(err, "decoding job report"))
+		return
+	}
+	if !status.StatusString.Terminal() {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Errorf("job report has non-final status %q", status.StatusString))
+		return
+	}
+	if s.reports == nil {
+		transport.ErrorResponse(w, r, job.ErrUnexpectedReport)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), reportTokenPrefix)
+	if err := s.reports.Report(id, token, status); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (s HTTPServer) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	ref := mux.Vars(r)["ref"]
-	commits, err := s.daemon.SyncStatus(ref)
+	commits, err := s.daemon.SyncStatus(r.Context(), ref)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -102,65 +166,82 @@ func (s HTTPServer) ListImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	d, err := s.daemon.ListImages(spec)
+	d, err := s.daemon.ListImages(r.Context(), spec)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
-	transport.JSONResponse(w, r, d)
+	transport.ETagJSONResponse(w, r, d)
 }
 
-func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
-	var (
-		vars  = mux.Vars(r)
-		image = vars["image"]
-		kind  = vars["kind"]
-	)
-	if err := r.ParseForm(); err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing form"))
-		return
-	}
-	var serviceSpecs []update.ServiceSpec
-	for _, service := range r.Form["service"] {
-		serviceSpec, err := update.ParseServiceSpec(service)
-		if err != nil {
-			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service spec %q", service))
-			return
-		}
-		serviceSpecs = append(serviceSpecs, serviceSpec)
+func (s HTTPServer) EvaluateImage(w http.ResponseWriter, r *http.Request) {
+	image := mux.Vars(r)["image"]
+	id, err := flux.ParseImageID(image)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing image %q", image))
+		return
 	}
-	imageSpec, err := update.ParseImageSpec(image)
+
+	res, err := s.daemon.EvaluateImage(r.Context(), id)
 	if err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing image spec %q", image))
+		transport.ErrorResponse(w, r, err)
 		return
 	}
-	releaseKind, err := update.ParseReleaseKind(kind)
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPServer) SyncHealth(w http.ResponseWriter, r *http.Request) {
+	res, err := s.daemon.SyncHealth(r.Context())
 	if err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing release kind %q", kind))
+		transport.ErrorResponse(w, r, err)
 		return
 	}
+	transport.JSONResponse(w, r, res)
+}
 
-	var excludes []flux.ServiceID
-	for _, ex := range r.URL.Query()["exclude"] {
-		s, err := flux.ParseServiceID(ex)
-		if err != nil {
-			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing excluded service %q", ex))
-			return
-		}
-		excludes = append(excludes, s)
+func (s HTTPServer) ListResources(w http.ResponseWriter, r *http.Request) {
+	res, err := s.daemon.ListResources(r.Context())
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
 	}
+	transport.JSONResponse(w, r, res)
+}
 
-	spec := update.ReleaseSpec{
-		ServiceSpecs: serviceSpecs,
-		ImageSpec:    imageSpec,
-		Kind:         releaseKind,
-		Excludes:     excludes,
+func (s HTTPServer) SyncWait(w http.ResponseWriter, r *http.Request) {
+	var req remote.SyncWaitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	res, err := s.daemon.SyncWait(r.Context(), req)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPServer) ServiceTopology(w http.ResponseWriter, r *http.Request) {
+	res, err := s.daemon.ServiceTopology(r.Context())
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
+	spec, err := transport.ParseReleaseSpec(r)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
 	}
 	cause := update.Cause{
 		User:    r.FormValue("user"),
 		Message: r.FormValue("message"),
 	}
-	result, err := s.daemon.UpdateManifests(update.Spec{Type: update.Images, Cause: cause, Spec: spec})
+	result, err := s.daemon.UpdateManifests(r.Context(), update.Spec{Type: update.Images, Cause: cause, Spec: spec})
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -168,6 +249,22 @@ func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, result)
 }
 
+func (s HTTPServer) PlanRelease(w http.ResponseWriter, r *http.Request) {
+	spec, err := transport.ParseReleaseSpec(r)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	spec.Kind = update.ReleaseKindPlan
+
+	plan, err := s.daemon.PlanRelease(r.Context(), spec)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, plan)
+}
+
 func (s HTTPServer) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	var updates policy.Updates
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
@@ -180,7 +277,92 @@ func (s HTTPServer) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 		Message: r.FormValue("message"),
 	}
 
-	jobID, err := s.daemon.UpdateManifests(update.Spec{Type: update.Policy, Cause: cause, Spec: updates})
+	specType := update.Policy
+	if r.FormValue("dryRun") == "true" {
+		specType = update.PolicyDryRun
+	}
+
+	jobID, err := s.daemon.UpdateManifests(r.Context(), update.Spec{Type: specType, Cause: cause, Spec: updates})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+// ValidatePolicies needs nothing from the daemon, since the policies
+// flux knows and the values they take are the same for any daemon.
+func (s HTTPServer) ValidatePolicies(w http.ResponseWriter, r *http.Request) {
+	var updates policy.Updates
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := updates.Validate(); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s HTTPServer) UpdateBatch(w http.ResponseWriter, r *http.Request) {
+	var steps update.BatchSpec
+	if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	cause := update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	}
+
+	jobID, err := s.daemon.UpdateManifests(r.Context(), update.Spec{Type: update.Batch, Cause: cause, Spec: steps})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPServer) AddManifests(w http.ResponseWriter, r *http.Request) {
+	var spec update.AddSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	cause := update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	}
+
+	jobID, err := s.daemon.UpdateManifests(r.Context(), update.Spec{Type: update.Add, Cause: cause, Spec: spec})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPServer) Rollback(w http.ResponseWriter, r *http.Request) {
+	var spec update.RollbackSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	cause := update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	}
+
+	jobID, err := s.daemon.UpdateManifests(r.Context(), update.Spec{Type: update.Rollback, Cause: cause, Spec: spec})
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -191,16 +373,32 @@ func (s HTTPServer) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 
 func (s HTTPServer) ListServices(w http.ResponseWriter, r *http.Request) {
 	namespace := mux.Vars(r)["namespace"]
-	res, err := s.daemon.ListServices(namespace)
+	res, err := s.daemon.ListServices(r.Context(), namespace)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
-	transport.JSONResponse(w, r, res)
+	transport.ETagJSONResponse(w, r, res)
 }
 
 func (s HTTPServer) Export(w http.ResponseWriter, r *http.Request) {
-	status, err := s.daemon.Export()
+	if ref := r.URL.Query().Get("ref"); ref != "" {
+		manifests, err := s.daemon.ExportAt(r.Context(), ref)
+		if err != nil {
+			transport.ErrorResponse(w, r, err)
+			return
+		}
+		transport.YAMLResponse(w, r, manifests)
+		return
+	}
+	if transport.AcceptsStream(r, transport.YAMLContentType) {
+		transport.StreamResponse(w, r, transport.YAMLContentType, func(out io.Writer) error {
+			return remote.ExportTo(r.Context(), s.daemon, out)
+		})
+		return
+	}
+
+	status, err := s.daemon.Export(r.Context())
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -210,7 +408,7 @@ func (s HTTPServer) Export(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s HTTPServer) GetPublicSSHKey(w http.ResponseWriter, r *http.Request) {
-	res, err := s.daemon.GitRepoConfig(false)
+	res, err := s.daemon.GitRepoConfig(r.Context(), false)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -219,7 +417,7 @@ func (s HTTPServer) GetPublicSSHKey(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s HTTPServer) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Request) {
-	_, err := s.daemon.GitRepoConfig(true)
+	_, err := s.daemon.GitRepoConfig(r.Context(), true)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -227,3 +425,14 @@ func (s HTTPServer) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
+
+func (s HTTPServer) Check(w http.ResponseWriter, r *http.Request) {
+	// We're talking to the daemon directly, so being connected just
+	// means it responds.
+	var report service.CheckReport
+	report.Run(service.CheckConnected, func() (string, error) {
+		return "", s.daemon.Ping(r.Context())
+	})
+	remote.CheckPlatform(r.Context(), s.daemon, &report)
+	transport.JSONResponse(w, r, report)
+}
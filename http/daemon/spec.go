@@ -0,0 +1,27 @@
+package daemon
+
+import (
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/http/openapi"
+	"github.com/weaveworks/flux/job"
+)
+
+// The routes only the daemon serves, in addition to those in
+// transport.APIOperations.
+var daemonOnlyOperations = map[string]openapi.Operation{
+	"ReportJob": {
+		Summary: "Report the final status of a job run by a worker; the request must carry the token the worker was given",
+		Request: job.Status{},
+	},
+}
+
+func daemonOperations() map[string]openapi.Operation {
+	ops := map[string]openapi.Operation{}
+	for name, op := range transport.APIOperations {
+		ops[name] = op
+	}
+	for name, op := range daemonOnlyOperations {
+		ops[name] = op
+	}
+	return ops
+}
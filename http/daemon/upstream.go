@@ -1,22 +1,30 @@
 package daemon
 
 import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
-	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/weaveworks/flux"
+	fluxgrpc "github.com/weaveworks/flux/grpc"
 	"github.com/weaveworks/flux/history"
 	transport "github.com/weaveworks/flux/http"
 	fluxclient "github.com/weaveworks/flux/http/client"
 	"github.com/weaveworks/flux/http/websocket"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/remote/rpc"
 	"github.com/weaveworks/flux/service"
@@ -24,53 +32,197 @@ import (
 
 // Upstream handles communication from the daemon to a service
 type Upstream struct {
-	client    *http.Client
-	ua        string
-	token     flux.Token
-	url       *url.URL
-	endpoint  string
-	apiClient *fluxclient.Client
-	platform  remote.Platform
-	logger    log.Logger
-	quit      chan struct{}
+	client   *http.Client
+	ua       string
+	router   *mux.Router
+	metrics  *fluxclient.Metrics
+	platform remote.Platform
+	logger   log.Logger
+	quit     chan struct{}
+	// If this is set, the daemon connects over gRPC rather than by
+	// websocket; the HTTP endpoint is still used for the API.
+	grpcConn *grpc.ClientConn
 
-	ws websocket.Websocket
+	// These can all change: the token when it's refreshed, and the
+	// endpoints when the service redirects us elsewhere.
+	mu           sync.Mutex
+	token        flux.Token
+	url          *url.URL
+	endpoint     string
+	httpEndpoint string
+	apiClient    *fluxclient.Client
+	ws           websocket.Websocket
 }
 
 var (
 	ErrEndpointDeprecated = errors.New("Your fluxd version is deprecated - please upgrade, see https://github.com/weaveworks/flux/releases")
-	connectionDuration    = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+	connectionDuration    = fluxmetrics.NewGauge(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "fluxd",
 		Name:      "connection_duration_seconds",
 		Help:      "Duration in seconds of the current connection to fluxsvc. Zero means unconnected.",
-	}, []string{"target"})
+	}, "target")
+	connectionAttempts = fluxmetrics.NewCounter(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxd",
+		Name:      "connection_attempts_total",
+		Help:      "Count of attempts to connect to fluxsvc.",
+	}, "target", fluxmetrics.LabelSuccess)
+	disconnections = fluxmetrics.NewCounter(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxd",
+		Name:      "disconnections_total",
+		Help:      "Count of connections to fluxsvc that have ended.",
+	}, "target")
 )
 
-func NewUpstream(client *http.Client, ua string, t flux.Token, router *mux.Router, endpoint string, p remote.Platform, logger log.Logger) (*Upstream, error) {
+const (
+	// After a failed attempt to connect, the daemon waits this long
+	// before trying again, doubling the wait each time up to the
+	// maximum.
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 2 * time.Minute
+)
+
+// NewUpstream connects to the service at the endpoint given, and
+// keeps the connection up. Requests made to the service are recorded
+// in metrics, if they are supplied.
+func NewUpstream(client *http.Client, ua string, t flux.Token, router *mux.Router, endpoint string, p remote.Platform, metrics *fluxclient.Metrics, logger log.Logger) (*Upstream, error) {
+	a := newUpstream(client, ua, t, router, p, metrics, logger)
+	if err := a.setEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	go a.loop()
+	return a, nil
+}
+
+// NewGRPCUpstream is like NewUpstream, but connects to the service's
+// gRPC server at the address given, rather than by websocket. TLS is
+// used if the endpoint is https (or wss).
+func NewGRPCUpstream(client *http.Client, ua string, t flux.Token, router *mux.Router, endpoint, grpcAddr string, p remote.Platform, metrics *fluxclient.Metrics, logger log.Logger) (*Upstream, error) {
+	a := newUpstream(client, ua, t, router, p, metrics, logger)
+	if err := a.setEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	creds := grpc.WithInsecure()
+	if u, err := url.Parse(a.httpEndpoint); err == nil && u.Scheme == "https" {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+	// This doesn't block; the connection is made (and remade) as
+	// it's needed.
+	conn, err := grpc.Dial(grpcAddr, creds, grpc.WithUserAgent(ua))
+	if err != nil {
+		return nil, errors.Wrapf(err, "dialling gRPC address %s", grpcAddr)
+	}
+	a.grpcConn = conn
+	go a.loop()
+	return a, nil
+}
+
+func newUpstream(client *http.Client, ua string, t flux.Token, router *mux.Router, p remote.Platform, metrics *fluxclient.Metrics, logger log.Logger) *Upstream {
+	return &Upstream{
+		client:   client,
+		ua:       ua,
+		router:   router,
+		metrics:  metrics,
+		platform: p,
+		logger:   logger,
+		quit:     make(chan struct{}),
+		token:    t,
+	}
+}
+
+// setEndpoint makes the service at the endpoint given the one to
+// connect to, and to send API requests to.
+func (a *Upstream) setEndpoint(endpoint string) error {
 	httpEndpoint, wsEndpoint, err := inferEndpoints(endpoint)
 	if err != nil {
-		return nil, errors.Wrap(err, "inferring WS/HTTP endpoints")
+		return errors.Wrap(err, "inferring WS/HTTP endpoints")
 	}
 
-	u, err := transport.MakeURL(wsEndpoint, router, "RegisterDaemon")
+	u, err := transport.MakeURL(wsEndpoint, a.router, "RegisterDaemon")
 	if err != nil {
-		return nil, errors.Wrap(err, "constructing URL")
+		return errors.Wrap(err, "constructing URL")
 	}
 
-	a := &Upstream{
-		client:    client,
-		ua:        ua,
-		token:     t,
-		url:       u,
-		endpoint:  wsEndpoint,
-		apiClient: fluxclient.New(client, router, httpEndpoint, t),
-		platform:  p,
-		logger:    logger,
-		quit:      make(chan struct{}),
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.url = u
+	a.endpoint = wsEndpoint
+	a.httpEndpoint = httpEndpoint
+	a.apiClient = a.newAPIClient()
+	return nil
+}
+
+// setToken makes the token the one used for connecting, and for API
+// requests.
+func (a *Upstream) setToken(t flux.Token) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = t
+	a.apiClient = a.newAPIClient()
+}
+
+// newAPIClient makes a client with the current endpoint and token;
+// a.mu must be held.
+func (a *Upstream) newAPIClient() *fluxclient.Client {
+	apiClient := fluxclient.New(a.client, a.router, a.httpEndpoint, a.token)
+	if a.metrics != nil {
+		apiClient = apiClient.WithMetrics(a.metrics)
+	}
+	return apiClient
+}
+
+// redirect follows a redirection from registering with the service,
+// as is given when the instance has been migrated to another
+// service. The location is that of the registration route at the
+// other service, so the base endpoint is what's left when that route
+// is taken off.
+func (a *Upstream) redirect(location string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return errors.Wrapf(err, "parsing redirect location %q", location)
+	}
+	path, err := a.router.Get("RegisterDaemon").GetPathTemplate()
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(u.Path, path) {
+		return errors.Errorf("redirect location %q is not a daemon registration URL", location)
+	}
+	u.Path = strings.TrimSuffix(u.Path, path)
+	u.RawQuery = ""
+	return a.setEndpoint(u.String())
+}
+
+// RefreshToken adopts a new token, e.g., because the old one is
+// about to expire. If there's a websocket connection to the service,
+// the token is sent along it, so the connection can stay up;
+// otherwise it'll be used next time a connection is made.
+func (a *Upstream) RefreshToken(t flux.Token) error {
+	a.setToken(t)
+	a.mu.Lock()
+	ws := a.ws
+	a.mu.Unlock()
+	if ws == nil {
+		return nil
+	}
+	err := ws.SendControl(websocket.ControlMessage{
+		Type:  websocket.ControlRefreshToken,
+		Token: t,
+	})
+	return errors.Wrap(err, "sending refreshed token to service")
+}
+
+func (a *Upstream) handleControl(m websocket.ControlMessage) {
+	switch m.Type {
+	case websocket.ControlTokenRefreshed:
+		if m.Error != "" {
+			a.logger.Log("token", "refresh rejected", "err", m.Error)
+			return
+		}
+		a.logger.Log("token", "refreshed")
 	}
-	go a.loop()
-	return a, nil
 }
 
 func inferEndpoints(endpoint string) (httpEndpoint, wsEndpoint string, err error) {
@@ -101,46 +253,157 @@ func inferEndpoints(endpoint string) (httpEndpoint, wsEndpoint string, err error
 	}
 }
 
+// backoff gives the time to wait before each attempt to reconnect.
+// The waits are jittered, so that when the service restarts, the
+// daemons connected to it don't all come back at once.
+type backoff struct {
+	min, max time.Duration
+	next     time.Duration
+}
+
+func (b *backoff) wait() time.Duration {
+	if b.next < b.min {
+		b.next = b.min
+	}
+	d := b.next
+	b.next *= 2
+	if b.next > b.max {
+		b.next = b.max
+	}
+	// Somewhere between half of it and all of it
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.next = b.min
+}
+
+// loop keeps the daemon connected to the service, until the upstream
+// is closed. Connecting, and being disconnected, are recorded in the
+// history; since the service isn't necessarily there to be told when
+// the daemon is disconnected, that's logged once it's reconnected.
 func (a *Upstream) loop() {
-	backoff := 5 * time.Second
+	b := &backoff{min: minReconnectBackoff, max: maxReconnectBackoff}
+	var (
+		attempts    int
+		lastErr     error
+		connectedAt time.Time
+		pending     []history.Event
+	)
+	onConnected := func(protocol string) {
+		connectedAt = time.Now()
+		events := append(pending, history.Event{
+			Type:      history.EventConnect,
+			StartedAt: connectedAt,
+			EndedAt:   connectedAt,
+			LogLevel:  history.LogLevelInfo,
+			Metadata: &history.ConnectEventMetadata{
+				Attempts:  attempts,
+				Protocol:  protocol,
+				LastError: errString(lastErr),
+			},
+		})
+		pending = nil
+		// Not in the way of serving RPCs, which the service will
+		// want to make as soon as it has the connection.
+		go func() {
+			for _, e := range events {
+				if err := a.LogEvent(e); err != nil {
+					a.logger.Log("event", e.Type, "err", errors.Wrap(err, "logging connection event"))
+				}
+			}
+		}()
+	}
+
 	errc := make(chan error, 1)
 	for {
+		attempts++
+		connectedAt = time.Time{}
 		go func() {
-			errc <- a.connect()
+			errc <- a.connect(onConnected)
 		}()
+		var err error
 		select {
-		case err := <-errc:
-			if err != nil {
-				a.logger.Log("err", err)
-				if err == ErrEndpointDeprecated {
-					// We have logged the deprecation error, now crashloop to garner attention
-					os.Exit(1)
-				}
+		case err = <-errc:
+		case <-a.quit:
+			return
+		}
+
+		target := a.currentEndpoint()
+		if !connectedAt.IsZero() {
+			now := time.Now()
+			disconnections.With("target", target).Add(1)
+			pending = append(pending, history.Event{
+				Type:      history.EventDisconnect,
+				StartedAt: now,
+				EndedAt:   now,
+				LogLevel:  history.LogLevelWarn,
+				Metadata: &history.DisconnectEventMetadata{
+					Duration: now.Sub(connectedAt),
+					Error:    errString(err),
+				},
+			})
+			attempts, lastErr = 0, nil
+			b.reset()
+		} else if err != nil {
+			connectionAttempts.With("target", target, fluxmetrics.LabelSuccess, "false").Add(1)
+			lastErr = err
+		}
+
+		if err != nil {
+			a.logger.Log("err", err)
+			if err == ErrEndpointDeprecated {
+				// We have logged the deprecation error, now crashloop to garner attention
+				os.Exit(1)
 			}
-			time.Sleep(backoff)
+		}
+
+		wait := b.wait()
+		a.logger.Log("reconnecting", true, "attempt", attempts+1, "after", wait)
+		select {
+		case <-time.After(wait):
 		case <-a.quit:
 			return
 		}
 	}
 }
 
-func (a *Upstream) connect() error {
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// connect connects to the service and serves RPCs from it, until the
+// connection ends. onConnected is called once the connection is made,
+// with the protocol agreed on.
+func (a *Upstream) connect(onConnected func(protocol string)) error {
 	a.setConnectionDuration(0)
 	a.logger.Log("connecting", true)
-	ws, err := websocket.Dial(a.client, a.ua, a.token, a.url)
-	if err != nil {
-		if err, ok := err.(*websocket.DialErr); ok && err.HTTPResponse != nil && err.HTTPResponse.StatusCode == http.StatusGone {
-			return ErrEndpointDeprecated
-		}
-		return errors.Wrapf(err, "executing websocket %s", a.url)
+	var (
+		conn     io.ReadWriteCloser
+		protocol string
+		err      error
+	)
+	if a.grpcConn != nil {
+		conn, protocol, err = a.dialGRPC()
+	} else {
+		conn, protocol, err = a.dialWebsocket()
+	}
+	if err != nil || conn == nil {
+		return err
 	}
-	a.ws = ws
 	defer func() {
+		a.mu.Lock()
 		a.ws = nil
+		a.mu.Unlock()
 		// TODO: handle this error
-		a.logger.Log("connection closing", true, "err", ws.Close())
+		a.logger.Log("connection closing", true, "err", conn.Close())
 	}()
-	a.logger.Log("connected", true)
+	a.logger.Log("connected", true, "protocol", protocol)
+	connectionAttempts.With("target", a.currentEndpoint(), fluxmetrics.LabelSuccess, "true").Add(1)
+	onConnected(protocol)
 
 	// Instrument connection lifespan
 	connectedAt := time.Now()
@@ -160,31 +423,118 @@ func (a *Upstream) connect() error {
 		}
 	}()
 
-	// Hook up the rpc server. We are a websocket _client_, but an RPC
+	// Hook up the rpc server. We are the one connecting, but the RPC
 	// _server_.
 	rpcserver, err := rpc.NewServer(a.platform)
 	if err != nil {
 		return errors.Wrap(err, "initializing rpc client")
 	}
-	rpcserver.ServeConn(ws)
+	if err := rpcserver.ServeProtocol(protocol, conn); err != nil {
+		return errors.Wrap(err, "serving rpc")
+	}
 	a.logger.Log("disconnected", true)
 	return nil
 }
 
+// dialWebsocket connects to the service by websocket, giving back
+// the connection and the protocol agreed on. If the service
+// redirected us, there's no connection and no error, and the next
+// attempt will go to the new endpoint.
+func (a *Upstream) dialWebsocket() (io.ReadWriteCloser, string, error) {
+	a.mu.Lock()
+	token, u := a.token, a.url
+	a.mu.Unlock()
+	begin := time.Now()
+	ws, err := websocket.Dial(a.client, a.ua, token, u, rpc.Protocols...)
+	a.observeDial(begin, err)
+	if err != nil {
+		if err, ok := err.(*websocket.DialErr); ok && err.HTTPResponse != nil {
+			switch err.HTTPResponse.StatusCode {
+			case http.StatusGone:
+				return nil, "", ErrEndpointDeprecated
+			case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+				location := err.HTTPResponse.Header.Get("Location")
+				if err := a.redirect(location); err != nil {
+					return nil, "", errors.Wrap(err, "following redirect")
+				}
+				a.logger.Log("redirected", location)
+				return nil, "", nil
+			}
+		}
+		return nil, "", errors.Wrapf(err, "executing websocket %s", u)
+	}
+	ws.OnControl(a.handleControl)
+	a.mu.Lock()
+	a.ws = ws
+	a.mu.Unlock()
+	// A service from before protocols were negotiated won't have
+	// picked one, and speaks V6.
+	protocol := ws.Subprotocol()
+	if protocol == "" {
+		protocol = rpc.ProtocolV6
+	}
+	return ws, protocol, nil
+}
+
+// dialGRPC opens a stream to the service over the gRPC connection.
+// There's no control channel, so a refreshed token is only used when
+// reconnecting.
+func (a *Upstream) dialGRPC() (io.ReadWriteCloser, string, error) {
+	a.mu.Lock()
+	token := a.token
+	a.mu.Unlock()
+	conn, protocol, err := fluxgrpc.ConnectUpstream(context.Background(), a.grpcConn, token)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "connecting over gRPC")
+	}
+	return conn, protocol, nil
+}
+
+// observeDial records connecting to the service in the client
+// metrics, as a request to the RegisterDaemon route.
+func (a *Upstream) observeDial(begin time.Time, err error) {
+	if a.metrics == nil {
+		return
+	}
+	var resp *http.Response
+	switch err := err.(type) {
+	case nil:
+		resp = &http.Response{StatusCode: http.StatusSwitchingProtocols}
+	case *websocket.DialErr:
+		resp = err.HTTPResponse
+	}
+	a.metrics.Observe("GET", "RegisterDaemon", true, begin, resp, err)
+}
+
 func (a *Upstream) setConnectionDuration(duration float64) {
-	connectionDuration.With("target", a.endpoint).Set(duration)
+	connectionDuration.With("target", a.currentEndpoint()).Set(duration)
+}
+
+func (a *Upstream) currentEndpoint() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.endpoint
 }
 
 func (a *Upstream) LogEvent(event history.Event) error {
 	// Instance ID is set via token here, so we can leave it blank.
-	return a.apiClient.LogEvent(service.InstanceID(""), event)
+	a.mu.Lock()
+	apiClient := a.apiClient
+	a.mu.Unlock()
+	return apiClient.LogEvent(service.InstanceID(""), event)
 }
 
 // Close closes the connection to the service
 func (a *Upstream) Close() error {
 	close(a.quit)
-	if a.ws == nil {
+	if a.grpcConn != nil {
+		return a.grpcConn.Close()
+	}
+	a.mu.Lock()
+	ws := a.ws
+	a.mu.Unlock()
+	if ws == nil {
 		return nil
 	}
-	return a.ws.Close()
+	return ws.Close()
 }
@@ -1,7 +1,11 @@
 package daemon
 
 import (
+	"net/http"
 	"testing"
+	"time"
+
+	transport "github.com/weaveworks/flux/http"
 )
 
 func TestEndpointInference(t *testing.T) {
@@ -37,3 +41,39 @@ func TestUnsupportedEndpoint(t *testing.T) {
 		t.Error("Expected err, got nil")
 	}
 }
+
+func TestRedirect(t *testing.T) {
+	a := &Upstream{
+		client: http.DefaultClient,
+		router: transport.NewUpstreamRouter(),
+	}
+	if err := a.setEndpoint("https://cloud.weave.works/api/flux"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.redirect("https://flux.example.com/flux/v6/daemon"); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "https://flux.example.com/flux", a.httpEndpoint)
+	assertEquals(t, "wss://flux.example.com/flux", a.endpoint)
+	assertEquals(t, "wss://flux.example.com/flux/v6/daemon", a.url.String())
+
+	if err := a.redirect("https://flux.example.com/somewhere/else"); err == nil {
+		t.Error("expected error redirecting to something other than the registration route")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{min: time.Second, max: 8 * time.Second}
+	for _, ceiling := range []time.Duration{1, 2, 4, 8, 8} {
+		ceiling *= time.Second
+		wait := b.wait()
+		if wait < ceiling/2 || wait > ceiling {
+			t.Errorf("expected wait between %s and %s, got %s", ceiling/2, ceiling, wait)
+		}
+	}
+	b.reset()
+	if wait := b.wait(); wait > time.Second {
+		t.Errorf("expected wait of at most %s after reset, got %s", time.Second, wait)
+	}
+}
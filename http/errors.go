@@ -2,6 +2,7 @@ package http
 
 import (
 	"errors"
+	"time"
 
 	"github.com/weaveworks/flux"
 )
@@ -62,3 +63,11 @@ and include this path:
 		Err: errors.New("API endpoint not found"),
 	}
 }
+
+// TooManyRequests is what the client gives back when a request was
+// refused because of rate limiting. RetryAfter is how long the
+// service said to wait before trying again, if it said.
+type TooManyRequests struct {
+	*flux.BaseError
+	RetryAfter time.Duration
+}
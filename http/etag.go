@@ -0,0 +1,51 @@
+package http
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ETagJSONResponse is like JSONResponse, but tags the response with a
+// hash of its content, and answers 304 Not Modified if the client
+// already has that content. This is for things that are polled, like
+// the lists of services and images.
+func ETagJSONResponse(w http.ResponseWriter, r *http.Request, result interface{}) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		ErrorResponse(w, r, err)
+		return
+	}
+
+	// The tag is weak, since the same content may be sent gzipped
+	// or not.
+	sum := sha1.Sum(body)
+	etag := `W/"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, r, body)
+}
+
+// etagMatches says whether the value of an If-None-Match header
+// matches the etag given, using weak comparison (i.e., ignoring
+// whether either is marked as weak).
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := `W/"abc"`
+	for _, c := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"def", W/"abc"`, true},
+		{`"def"`, false},
+		{"*", true},
+	} {
+		if got := etagMatches(c.header, etag); got != c.want {
+			t.Errorf("If-None-Match %q: expected %v, got %v", c.header, c.want, got)
+		}
+	}
+}
+
+func TestETagJSONResponse(t *testing.T) {
+	respond := func(ifNoneMatch string, result interface{}) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		ETagJSONResponse(w, r, result)
+		return w
+	}
+
+	first := respond("", []string{"a", "b"})
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d and %q", first.Code, etag)
+	}
+
+	same := respond(etag, []string{"a", "b"})
+	if same.Code != http.StatusNotModified || same.Body.Len() != 0 {
+		t.Errorf("expected 304 with no body for unchanged result, got %d", same.Code)
+	}
+
+	changed := respond(etag, []string{"a", "c"})
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Errorf("expected 200 with a new ETag for changed result, got %d", changed.Code)
+	}
+}
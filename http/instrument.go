@@ -0,0 +1,74 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+var requestDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
+	Namespace: "flux",
+	Name:      "request_duration_seconds",
+	Help:      "Time (in seconds) spent serving HTTP requests.",
+}, fluxmetrics.LabelMethod, fluxmetrics.LabelRoute, "status_code", "ws")
+
+// Instrument wraps a handler so that the time taken to serve each
+// request is recorded, labelled with the name of the route it
+// matched (or "other"), the status code, and whether it was a
+// websocket. The histogram is made with the metrics backend, like
+// the rest of the metrics.
+func Instrument(routes *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		begin := time.Now()
+		route := "other"
+		var match mux.RouteMatch
+		if routes.Match(r, &match) && match.Route != nil && match.Route.GetName() != "" {
+			route = match.Route.GetName()
+		}
+		ws := strings.ToLower(r.Header.Get("Upgrade")) == "websocket"
+
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		requestDuration.With(
+			fluxmetrics.LabelMethod, r.Method,
+			fluxmetrics.LabelRoute, route,
+			"status_code", strconv.Itoa(sw.code),
+			"ws", strconv.FormatBool(ws),
+		).Observe(time.Since(begin).Seconds())
+	})
+}
+
+// statusWriter keeps the status code of the response. It passes on
+// flushing, for streamed responses, and hijacking, for websockets.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
+	}
+	return hj.Hijack()
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+func TestInstrumentUsesBackend(t *testing.T) {
+	statsd := fluxmetrics.NewDogStatsD(log.NewNopLogger())
+	fluxmetrics.Use(statsd)
+
+	r := NewAPIRouter()
+	handler := Instrument(r, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v6/services" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	for _, path := range []string{"/v6/services?namespace=", "/v6/nonesuch"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := statsd.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := buf.String()
+	for _, expected := range []string{
+		"route:ListServices,status_code:200,ws:false",
+		"route:other,status_code:404,ws:false",
+	} {
+		if !strings.Contains(lines, expected) {
+			t.Errorf("expected %q in output, got:\n%s", expected, lines)
+		}
+	}
+}
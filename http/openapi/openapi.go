@@ -0,0 +1,328 @@
+// Package openapi generates an OpenAPI (v3) description of the flux
+// HTTP API, from the mux routers that define the routes and a table
+// of the request and response types for each route.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const Version = "3.0.0"
+
+// Operation describes the things about a route that can't be
+// discovered from the router itself. Request and Response are
+// example values (usually zero values) of the types used in the
+// request and response bodies; nil means there is no body.
+type Operation struct {
+	Summary  string
+	Query    []string
+	Request  interface{}
+	Response interface{}
+}
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps (lower-case) HTTP methods to operations.
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                    `json:"operationId"`
+	Summary     string                    `json:"summary,omitempty"`
+	Parameters  []Parameter               `json:"parameters,omitempty"`
+	RequestBody *RequestBody              `json:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+const jsonContentType = "application/json"
+
+// The methods we try against each route, to find out which it
+// accepts.
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Generate walks the router given and describes each route that has
+// an entry in ops. Routes without an entry (e.g., catch-alls and
+// deprecated versions) are left out.
+func Generate(router *mux.Router, title, version string, ops map[string]Operation) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+		},
+	}
+	schemas := &schemaGenerator{components: doc.Components.Schemas}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		name := route.GetName()
+		op, ok := ops[name]
+		if !ok {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return errors.Wrapf(err, "getting path for route %s", name)
+		}
+
+		pathParams := pathParameters(path)
+		var params []Parameter
+		for _, p := range pathParams {
+			params = append(params, Parameter{Name: p, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, q := range op.Query {
+			params = append(params, Parameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+		}
+
+		item, ok := doc.Paths[openAPIPath(path)]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[openAPIPath(path)] = item
+		}
+		for _, method := range routeMethods(route, path, op.Query) {
+			o := &OperationObject{
+				OperationID: name,
+				Summary:     op.Summary,
+				Parameters:  params,
+				Responses:   map[string]ResponseObject{},
+			}
+			if op.Request != nil {
+				o.RequestBody = &RequestBody{
+					Content: map[string]MediaType{
+						jsonContentType: {Schema: schemas.schemaFor(reflect.TypeOf(op.Request))},
+					},
+				}
+			}
+			if op.Response != nil {
+				o.Responses["200"] = ResponseObject{
+					Description: "OK",
+					Content: map[string]MediaType{
+						jsonContentType: {Schema: schemas.schemaFor(reflect.TypeOf(op.Response))},
+					},
+				}
+			} else {
+				o.Responses["200"] = ResponseObject{Description: "OK"}
+			}
+			o.Responses["default"] = ResponseObject{
+				Description: "Error",
+				Content: map[string]MediaType{
+					jsonContentType: {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+				},
+			}
+			item[strings.ToLower(method)] = o
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// This is the shape of flux.BaseError, as returned by the API
+	doc.Components.Schemas["Error"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"help": {Type: "string"},
+			"err":  {Type: "string"},
+		},
+	}
+	return doc, nil
+}
+
+var pathParamRE = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+func pathParameters(path string) []string {
+	var params []string
+	for _, m := range pathParamRE.FindAllStringSubmatch(path, -1) {
+		params = append(params, m[1])
+	}
+	return params
+}
+
+// openAPIPath removes any regular expressions from path variables,
+// since OpenAPI path templates don't have them.
+func openAPIPath(path string) string {
+	return pathParamRE.ReplaceAllString(path, "{$1}")
+}
+
+// routeMethods finds the methods a route accepts by trying each of
+// them with a request constructed to otherwise match.
+func routeMethods(route *mux.Route, path string, query []string) []string {
+	example := pathParamRE.ReplaceAllString(path, "x")
+	q := url.Values{}
+	for _, param := range query {
+		q.Set(param, "x")
+	}
+	u := &url.URL{Path: example, RawQuery: q.Encode()}
+
+	var accepted []string
+	for _, method := range methods {
+		req, err := http.NewRequest(method, u.String(), nil)
+		if err != nil {
+			continue
+		}
+		var match mux.RouteMatch
+		if route.Match(req, &match) {
+			accepted = append(accepted, method)
+		}
+	}
+	return accepted
+}
+
+type schemaGenerator struct {
+	components map[string]*Schema
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	byteSliceType     = reflect.TypeOf([]byte(nil))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaFor gives a schema for a Go type, following the rules of
+// encoding/json. Named struct types go in the components, and are
+// referred to, so that recursive types terminate.
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == byteSliceType:
+		return &Schema{Type: "string", Format: "byte"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// Custom encoding; we can't say what it looks like.
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.components[name]; !ok {
+			// Placeholder, in case the type refers to itself
+			g.components[name] = &Schema{Type: "object"}
+			g.components[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+	}
+}
+
+// schemaName makes a name for a type that's unique enough, by
+// qualifying it with the last element of its package path.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
@@ -0,0 +1,84 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type thing struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Children []thing   `json:"children,omitempty"`
+	Ignored  string    `json:"-"`
+	embedded
+}
+
+type embedded struct {
+	Count int `json:"count"`
+}
+
+func TestGenerate(t *testing.T) {
+	r := mux.NewRouter()
+	r.NewRoute().Name("GetThing").Methods("HEAD", "GET").Path("/v1/things/{id}").Queries("verbose", "{verbose}")
+	r.NewRoute().Name("PostThing").Methods("POST").Path("/v1/things/{id}")
+	r.NewRoute().Name("Undocumented").Methods("GET").Path("/v1/other")
+
+	doc, err := Generate(r, "test", "v1", map[string]Operation{
+		"GetThing":  {Query: []string{"verbose"}, Response: thing{}},
+		"PostThing": {Request: thing{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Paths) != 1 {
+		t.Fatalf("expected only the documented path, got %+v", doc.Paths)
+	}
+	item, ok := doc.Paths["/v1/things/{id}"]
+	if !ok {
+		t.Fatalf("expected path /v1/things/{id}, got %+v", doc.Paths)
+	}
+	for _, method := range []string{"get", "head", "post"} {
+		if _, ok := item[method]; !ok {
+			t.Errorf("expected operation for method %s", method)
+		}
+	}
+	if len(item) != 3 {
+		t.Errorf("expected three operations, got %d", len(item))
+	}
+
+	get := item["get"]
+	if get.OperationID != "GetThing" {
+		t.Errorf("expected operation ID GetThing, got %q", get.OperationID)
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].In != "path" || get.Parameters[1].Name != "verbose" {
+		t.Errorf("unexpected parameters %+v", get.Parameters)
+	}
+	if ref := get.Responses["200"].Content[jsonContentType].Schema.Ref; ref != "#/components/schemas/openapi.thing" {
+		t.Errorf("expected response to refer to thing schema, got %q", ref)
+	}
+	if item["post"].RequestBody == nil {
+		t.Error("expected request body for POST")
+	}
+
+	schema, ok := doc.Components.Schemas["openapi.thing"]
+	if !ok {
+		t.Fatalf("expected schema for thing in components, got %+v", doc.Components.Schemas)
+	}
+	for _, prop := range []string{"name", "created", "children", "count"} {
+		if _, ok := schema.Properties[prop]; !ok {
+			t.Errorf("expected property %q in schema", prop)
+		}
+	}
+	if _, ok := schema.Properties["Ignored"]; ok {
+		t.Error("did not expect ignored field in schema")
+	}
+	if f := schema.Properties["created"].Format; f != "date-time" {
+		t.Errorf("expected time to have format date-time, got %q", f)
+	}
+	if ref := schema.Properties["children"].Items.Ref; ref != "#/components/schemas/openapi.thing" {
+		t.Errorf("expected recursive reference, got %q", ref)
+	}
+}
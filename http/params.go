@@ -0,0 +1,375 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
+)
+
+// The parameters each route takes, in the URL path or query, are
+// given by a struct with a field per parameter. The name of each
+// parameter is given in a `param` struct tag; with the option
+// `omitempty`, the parameter isn't sent if it has its zero value
+// (otherwise it is sent, even if empty, since some routes need it to
+// be present to match). Fields can be strings (or types based on
+// string), bools, ints, time.Times, slices of strings (each is sent
+// as another value for the parameter), pointers to those (nil
+// meaning leave it out), or anything that's a fmt.Stringer. Embedded
+// structs have their fields included.
+//
+// Structs that implement Validator are checked before being encoded,
+// so that a request that can't succeed isn't sent.
+
+type Validator interface {
+	Validate() error
+}
+
+// EncodeParams turns a parameters struct into URL values; a nil
+// params gives no values.
+func EncodeParams(params interface{}) (url.Values, error) {
+	values := url.Values{}
+	if params == nil {
+		return values, nil
+	}
+	if v, ok := params.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	val := reflect.ValueOf(params)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Errorf("parameters must be given as a struct, not %s", val.Type())
+	}
+	if err := encodeStruct(values, val); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func encodeStruct(values url.Values, val reflect.Value) error {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := encodeStruct(values, val.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := field.Tag.Get("param")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, opts = tag[:comma], tag[comma+1:]
+		}
+		fv := val.Field(i)
+		if opts == "omitempty" && isZero(fv) {
+			continue
+		}
+		strs, err := encodeValue(fv)
+		if err != nil {
+			return errors.Wrapf(err, "encoding parameter %q", name)
+		}
+		for _, s := range strs {
+			values.Add(name, s)
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func encodeValue(v reflect.Value) ([]string, error) {
+	if v.Type() == timeType {
+		return []string{v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.Ptr {
+		return []string{s.String()}, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeValue(v.Elem())
+	case reflect.String:
+		return []string{v.String()}, nil
+	case reflect.Bool:
+		return []string{strconv.FormatBool(v.Bool())}, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return []string{strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Slice:
+		var strs []string
+		for i := 0; i < v.Len(); i++ {
+			s, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, s...)
+		}
+		return strs, nil
+	}
+	return nil, errors.Errorf("unsupported type %s", v.Type())
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil() || (v.Kind() == reflect.Slice && v.Len() == 0)
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func missingParam(name string) error {
+	return errors.Errorf("parameter %q must be given", name)
+}
+
+// ---
+
+// CauseParams are included in the parameters of any request that
+// changes things, to say who did it and why.
+type CauseParams struct {
+	User    string `param:"user"`
+	Message string `param:"message,omitempty"`
+}
+
+func NewCauseParams(cause update.Cause) CauseParams {
+	return CauseParams{User: cause.User, Message: cause.Message}
+}
+
+type ListServicesParams struct {
+	Namespace string `param:"namespace"` // may be empty, meaning all namespaces
+}
+
+type ListImagesParams struct {
+	Service update.ServiceSpec `param:"service"`
+}
+
+func (p ListImagesParams) Validate() error {
+	if p.Service == "" {
+		return missingParam("service")
+	}
+	return nil
+}
+
+type EvaluateImageParams struct {
+	Image flux.ImageID `param:"image"`
+}
+
+type UpdateImagesParams struct {
+	Services   []update.ServiceSpec   `param:"service"`
+	Image      update.ImageSpec       `param:"image"`
+	Kind       update.ReleaseKind     `param:"kind"`
+	Excludes   []flux.ServiceID       `param:"exclude,omitempty"`
+	Containers []update.ContainerSpec `param:"container,omitempty"`
+	CauseParams
+}
+
+func (p UpdateImagesParams) Validate() error {
+	if len(p.Services) == 0 {
+		return missingParam("service")
+	}
+	if p.Image == "" {
+		return missingParam("image")
+	}
+	if _, err := update.ParseReleaseKind(string(p.Kind)); err != nil {
+		return err
+	}
+	return nil
+}
+
+type PlanReleaseParams struct {
+	Services   []update.ServiceSpec   `param:"service"`
+	Image      update.ImageSpec       `param:"image"`
+	Excludes   []flux.ServiceID       `param:"exclude,omitempty"`
+	Containers []update.ContainerSpec `param:"container,omitempty"`
+}
+
+func (p PlanReleaseParams) Validate() error {
+	if len(p.Services) == 0 {
+		return missingParam("service")
+	}
+	if p.Image == "" {
+		return missingParam("image")
+	}
+	return nil
+}
+
+// ParseReleaseSpec gets the release spec from a request to the
+// UpdateImages or PlanRelease route. Any error is the fault of the
+// request. The kind of release is only there for UpdateImages; for
+// PlanRelease, it's left empty.
+func ParseReleaseSpec(r *http.Request) (update.ReleaseSpec, error) {
+	var spec update.ReleaseSpec
+	if err := r.ParseForm(); err != nil {
+		return spec, errors.Wrapf(err, "parsing form")
+	}
+	for _, service := range r.Form["service"] {
+		serviceSpec, err := update.ParseServiceSpec(service)
+		if err != nil {
+			return spec, errors.Wrapf(err, "parsing service spec %q", service)
+		}
+		spec.ServiceSpecs = append(spec.ServiceSpecs, serviceSpec)
+	}
+	image := r.Form.Get("image")
+	imageSpec, err := update.ParseImageSpec(image)
+	if err != nil {
+		return spec, errors.Wrapf(err, "parsing image spec %q", image)
+	}
+	spec.ImageSpec = imageSpec
+	if kind := r.Form.Get("kind"); kind != "" {
+		releaseKind, err := update.ParseReleaseKind(kind)
+		if err != nil {
+			return spec, errors.Wrapf(err, "parsing release kind %q", kind)
+		}
+		spec.Kind = releaseKind
+	}
+	for _, ex := range r.Form["exclude"] {
+		s, err := flux.ParseServiceID(ex)
+		if err != nil {
+			return spec, errors.Wrapf(err, "parsing excluded service %q", ex)
+		}
+		spec.Excludes = append(spec.Excludes, s)
+	}
+	for _, c := range r.Form["container"] {
+		containerSpec, err := update.ParseContainerSpec(c)
+		if err != nil {
+			return spec, err
+		}
+		spec.Containers = append(spec.Containers, containerSpec)
+	}
+	return spec, nil
+}
+
+type UpdatePoliciesParams struct {
+	DryRun bool `param:"dryRun,omitempty"`
+	CauseParams
+}
+
+type UpdateBatchParams struct {
+	CauseParams
+}
+
+type AddManifestsParams struct {
+	CauseParams
+}
+
+type RollbackParams struct {
+	CauseParams
+}
+
+// JobParams are for the routes about a particular job.
+type JobParams struct {
+	ID job.ID `param:"id"`
+}
+
+func (p JobParams) Validate() error {
+	if p.ID == "" {
+		return missingParam("id")
+	}
+	return nil
+}
+
+type SyncStatusParams struct {
+	Ref string `param:"ref"`
+}
+
+// ExportParams are for exporting the manifests in the repo as of a
+// revision, rather than what's in the cluster.
+type ExportParams struct {
+	Ref string `param:"ref"`
+}
+
+func (p ExportParams) Validate() error {
+	if p.Ref == "" {
+		return missingParam("ref")
+	}
+	return nil
+}
+
+type HistoryParams struct {
+	Service update.ServiceSpec `param:"service"`
+	Before  time.Time          `param:"before,omitempty"`
+	After   time.Time          `param:"after,omitempty"`
+	Limit   *int64             `param:"limit"`
+}
+
+func (p HistoryParams) Validate() error {
+	if p.Service == "" {
+		return missingParam("service")
+	}
+	return nil
+}
+
+type DeployedImagesParams struct {
+	Service flux.ServiceID `param:"service"`
+	At      time.Time      `param:"at,omitempty"`
+}
+
+func (p DeployedImagesParams) Validate() error {
+	if p.Service == "" {
+		return missingParam("service")
+	}
+	return nil
+}
+
+type GetConfigParams struct {
+	Fingerprint string `param:"fingerprint,omitempty"`
+}
+
+type RestoreConfigParams struct {
+	Index int `param:"index"`
+}
+
+func (p RestoreConfigParams) Validate() error {
+	if p.Index < 0 {
+		return errors.Errorf("index must not be negative")
+	}
+	return nil
+}
+
+// WebhookParams are for the routes about a particular webhook.
+type WebhookParams struct {
+	Hook string `param:"hook"`
+}
+
+func (p WebhookParams) Validate() error {
+	if p.Hook == "" {
+		return missingParam("hook")
+	}
+	return nil
+}
+
+type PromoteParams struct {
+	CauseParams
+}
+
+// PromotionParams are for the routes about a particular promotion.
+type PromotionParams struct {
+	ID string `param:"id"`
+}
+
+func (p PromotionParams) Validate() error {
+	if p.ID == "" {
+		return missingParam("id")
+	}
+	return nil
+}
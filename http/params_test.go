@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/update"
+)
+
+func TestEncodeParams(t *testing.T) {
+	limit := int64(0)
+	at := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, example := range []struct {
+		params   interface{}
+		expected url.Values
+	}{
+		{nil, url.Values{}},
+		// Sent even if empty, since the route needs it
+		{ListServicesParams{}, url.Values{"namespace": {""}}},
+		{
+			UpdateImagesParams{
+				Services:    []update.ServiceSpec{"default/foo", "default/bar"},
+				Image:       update.ImageSpecLatest,
+				Kind:        update.ReleaseKindPlan,
+				CauseParams: CauseParams{User: "fred"},
+			},
+			url.Values{
+				"service": {"default/foo", "default/bar"},
+				"image":   {"<all latest>"},
+				"kind":    {"plan"},
+				"user":    {"fred"},
+			},
+		},
+		{
+			UpdatePoliciesParams{DryRun: true, CauseParams: CauseParams{Message: "why not"}},
+			url.Values{"dryRun": {"true"}, "user": {""}, "message": {"why not"}},
+		},
+		{
+			HistoryParams{Service: update.ServiceSpecAll, After: at, Limit: &limit},
+			url.Values{"service": {"<all>"}, "after": {"2017-06-01T12:00:00Z"}, "limit": {"0"}},
+		},
+		{HistoryParams{Service: update.ServiceSpecAll}, url.Values{"service": {"<all>"}}},
+	} {
+		values, err := EncodeParams(example.params)
+		if err != nil {
+			t.Errorf("%#v: %s", example.params, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, example.expected) {
+			t.Errorf("%#v: expected %v, got %v", example.params, example.expected, values)
+		}
+	}
+}
+
+func TestEncodeParamsValidates(t *testing.T) {
+	for _, params := range []interface{}{
+		UpdateImagesParams{Image: update.ImageSpecLatest, Kind: update.ReleaseKindPlan},
+		UpdateImagesParams{Services: []update.ServiceSpec{update.ServiceSpecAll}, Image: update.ImageSpecLatest, Kind: "maybe"},
+		JobParams{},
+		WebhookParams{},
+		PromotionParams{},
+		"not a struct",
+	} {
+		if _, err := EncodeParams(params); err == nil {
+			t.Errorf("%#v: expected error", params)
+		}
+	}
+}
+
+func TestMakeURLWithParams(t *testing.T) {
+	u, err := MakeURLWithParams("http://example.com/api/flux", NewAPIRouter(), "JobLog", JobParams{ID: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The job ID is in the path, so shouldn't also be in the query
+	if expected := "http://example.com/api/flux/v6/jobs/job-1/log"; u.String() != expected {
+		t.Errorf("expected %q, got %q", expected, u.String())
+	}
+
+	u, err = MakeURLWithParams("http://example.com", NewAPIRouter(), "JobStatus", JobParams{ID: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://example.com/v6/jobs?id=job-1"; u.String() != expected {
+		t.Errorf("expected %q, got %q", expected, u.String())
+	}
+}
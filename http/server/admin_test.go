@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/http/auth"
+)
+
+func TestAdminHandlerRequiresToken(t *testing.T) {
+	authn := AdminAuthenticator{Validator: auth.ScopeProbe("secret")}
+	handler := NewAdminHandler(nil, authn, log.NewNopLogger(), nil)
+
+	request := func(token flux.Token) int {
+		r, _ := http.NewRequest("GET", "/v6/spec", nil)
+		token.Set(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := request(""); code != http.StatusUnauthorized {
+		t.Errorf("expected request without token to be refused, got %d", code)
+	}
+	if code := request("other"); code != http.StatusUnauthorized {
+		t.Errorf("expected request with the wrong token to be refused, got %d", code)
+	}
+	if code := request("secret"); code != http.StatusOK {
+		t.Errorf("expected request with the admin token to be served, got %d", code)
+	}
+
+	// The admin routes aren't served by the API handler
+	r, _ := http.NewRequest("POST", "/v6/admin/migrate", nil)
+	if match := (&mux.RouteMatch{}); NewServiceRouter().Match(r, match) && match.Route.GetName() != "NotFound" {
+		t.Errorf("expected admin route not to be served with the API, matched %q", match.Route.GetName())
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/http/auth"
+	"github.com/weaveworks/flux/service"
+)
+
+// Role is the kind of access a request needs, so that an
+// Authenticator can decide whether whoever made the request is
+// allowed it.
+type Role string
+
+const (
+	RoleRead   Role = "read"   // looking at an instance (GET and HEAD)
+	RoleWrite  Role = "write"  // changing an instance
+	RoleAdmin  Role = "admin"  // moving instances between services, injecting faults; only on the admin handler
+	RoleDaemon Role = "daemon" // a daemon connecting, or reporting events
+)
+
+// Authenticator decides which instance a request is for, and whether
+// it may have the role given. If it returns an error, the request is
+// refused: with 403 Forbidden if the error is (or wraps)
+// ErrorForbidden, and 401 Unauthorized otherwise.
+//
+// This is the place to plug in other means of authentication, e.g.,
+// trusting headers put there by a proxy in front of the service.
+type Authenticator interface {
+	Authenticate(r *http.Request, role Role) (service.InstanceID, error)
+}
+
+var ErrorForbidden = &flux.BaseError{
+	Help: `The request is not allowed
+
+The credentials in the request were accepted, but do not allow what
+was requested. Please check with whoever runs the flux service that
+you have the access you need.
+`,
+	Err: errors.New("request not allowed"),
+}
+
+// DaemonTokens checks the tokens presented by daemons;
+// *server.Server is one.
+type DaemonTokens interface {
+	ValidateDaemonToken(inst service.InstanceID, token string) error
+}
+
+// DefaultAuthenticator takes the instance from the header in which
+// it's been passed along (usually by an authenticating proxy), and,
+// if it has a Validator, checks the credentials in every request
+// except those from daemons. Requests from daemons are checked only
+// if it has DaemonTokens, in which case they must carry a token
+// issued to the daemon of the instance. Admin requests are always
+// refused, since the credentials of an instance never allow them;
+// see AdminAuthenticator.
+type DefaultAuthenticator struct {
+	Validator    api.Validator
+	DaemonTokens DaemonTokens
+}
+
+func (a DefaultAuthenticator) Authenticate(r *http.Request, role Role) (service.InstanceID, error) {
+	inst := getInstanceID(r)
+	switch {
+	case role == RoleAdmin:
+		return "", ErrorForbidden
+	case role == RoleDaemon && a.DaemonTokens != nil:
+		token, ok := daemonToken(r)
+		if !ok {
+			return "", auth.ErrMissingCredentials
+		}
+		if err := a.DaemonTokens.ValidateDaemonToken(inst, token); err != nil {
+			return "", err
+		}
+	case role != RoleDaemon && a.Validator != nil:
+		if err := a.Validator.Validate(r); err != nil {
+			return "", err
+		}
+	}
+	return inst, nil
+}
+
+// AdminAuthenticator is for the admin handler. It accepts only
+// requests with the credentials its Validator checks for (and with no
+// Validator, nothing at all). Since whoever has those is trusted with
+// every instance, the instance is taken from the instance header as
+// given.
+type AdminAuthenticator struct {
+	Validator api.Validator
+}
+
+func (a AdminAuthenticator) Authenticate(r *http.Request, role Role) (service.InstanceID, error) {
+	if a.Validator == nil {
+		return "", ErrorForbidden
+	}
+	if err := a.Validator.Validate(r); err != nil {
+		return "", err
+	}
+	return getInstanceID(r), nil
+}
+
+// daemonToken gets the token a daemon has put in the request, which
+// it does the same way as flux.Token.
+func daemonToken(r *http.Request) (string, bool) {
+	const prefix = "Scope-Probe token="
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(header, prefix), true
+}
+
+// Authenticate wraps a handler so that it only sees requests the
+// Authenticator has accepted, with the instance it resolved put in
+// the instance header (which is where the handlers, and the rate
+// limiting, look for it).
+func Authenticate(a Authenticator, next http.Handler) http.Handler {
+	admin, upstream := transport.NewAdminRouter(), transport.NewUpstreamRouter()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inst, err := a.Authenticate(r, requestRole(admin, upstream, r))
+		switch {
+		case errors.Cause(err) == ErrorForbidden:
+			transport.WriteError(w, r, http.StatusForbidden, ErrorForbidden)
+			return
+		case err != nil:
+			transport.WriteError(w, r, http.StatusUnauthorized, transport.ErrorUnauthorized)
+			return
+		}
+		if inst == service.NoInstanceID || inst == "" {
+			r.Header.Del(service.InstanceIDHeaderKey)
+		} else {
+			r.Header.Set(service.InstanceIDHeaderKey, string(inst))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestRole(admin, upstream *mux.Router, r *http.Request) Role {
+	var match mux.RouteMatch
+	switch {
+	case admin.Match(r, &match):
+		return RoleAdmin
+	case upstream.Match(r, &match):
+		return RoleDaemon
+	case r.Method == "GET" || r.Method == "HEAD":
+		return RoleRead
+	}
+	return RoleWrite
+}
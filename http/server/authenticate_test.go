@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/http/auth"
+	"github.com/weaveworks/flux/service"
+)
+
+type authenticatorFunc func(*http.Request, Role) (service.InstanceID, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request, role Role) (service.InstanceID, error) {
+	return f(r, role)
+}
+
+func TestAuthenticate(t *testing.T) {
+	var gotRole Role
+	a := authenticatorFunc(func(r *http.Request, role Role) (service.InstanceID, error) {
+		gotRole = role
+		switch r.Header.Get("X-User") {
+		case "":
+			return "", errors.New("no user")
+		case "guest":
+			if role != RoleRead {
+				return "", ErrorForbidden
+			}
+		}
+		return "instance-of-" + service.InstanceID(r.Header.Get("X-User")), nil
+	})
+
+	var gotInstance service.InstanceID
+	handler := Authenticate(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotInstance = getInstanceID(r)
+	}))
+
+	for _, c := range []struct {
+		method, path, user string
+		role               Role
+		code               int
+	}{
+		{"GET", "/v6/services", "alice", RoleRead, http.StatusOK},
+		{"POST", "/v6/update-images", "alice", RoleWrite, http.StatusOK},
+		{"POST", "/v6/admin/migrate", "alice", RoleAdmin, http.StatusOK},
+		{"POST", "/v6/admin/faults", "alice", RoleAdmin, http.StatusOK},
+		{"GET", "/v6/daemon", "alice", RoleDaemon, http.StatusOK},
+		{"GET", "/v6/services", "", RoleRead, http.StatusUnauthorized},
+		{"GET", "/v6/services", "guest", RoleRead, http.StatusOK},
+		{"POST", "/v6/update-images", "guest", RoleWrite, http.StatusForbidden},
+	} {
+		gotInstance = ""
+		r, _ := http.NewRequest(c.method, c.path, nil)
+		r.Header.Set("X-User", c.user)
+		// Whatever the client says doesn't count
+		r.Header.Set(service.InstanceIDHeaderKey, "spoofed")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if gotRole != c.role {
+			t.Errorf("%s %s: expected role %q, got %q", c.method, c.path, c.role, gotRole)
+		}
+		if w.Code != c.code {
+			t.Errorf("%s %s as %q: expected status %d, got %d", c.method, c.path, c.user, c.code, w.Code)
+			continue
+		}
+		if c.code == http.StatusOK && gotInstance != "instance-of-"+service.InstanceID(c.user) {
+			t.Errorf("%s %s as %q: expected instance to be resolved, got %q", c.method, c.path, c.user, gotInstance)
+		}
+	}
+}
+
+func TestDefaultAuthenticator(t *testing.T) {
+	a := DefaultAuthenticator{Validator: auth.Bearer("secret")}
+
+	r, _ := http.NewRequest("GET", "/v6/services", nil)
+	r.Header.Set(service.InstanceIDHeaderKey, "inst")
+	if _, err := a.Authenticate(r, RoleRead); err == nil {
+		t.Error("expected request without a token to be refused")
+	}
+	// Daemons aren't expected to have the token
+	if inst, err := a.Authenticate(r, RoleDaemon); err != nil || inst != "inst" {
+		t.Errorf("expected daemon request to be accepted for instance, got %q, %v", inst, err)
+	}
+
+	auth.Bearer("secret").Authenticate(r)
+	if inst, err := a.Authenticate(r, RoleWrite); err != nil || inst != "inst" {
+		t.Errorf("expected request with token to be accepted for instance, got %q, %v", inst, err)
+	}
+	// The credentials of an instance aren't enough for admin requests
+	if _, err := a.Authenticate(r, RoleAdmin); err != ErrorForbidden {
+		t.Errorf("expected admin request to be forbidden, got %v", err)
+	}
+
+	// With no validator, everything but admin requests is let through
+	r, _ = http.NewRequest("GET", "/v6/services", nil)
+	if inst, err := (DefaultAuthenticator{}).Authenticate(r, RoleWrite); err != nil || inst != service.NoInstanceID {
+		t.Errorf("expected request to be accepted with no instance, got %q, %v", inst, err)
+	}
+	if _, err := (DefaultAuthenticator{}).Authenticate(r, RoleAdmin); err != ErrorForbidden {
+		t.Errorf("expected admin request to be forbidden, got %v", err)
+	}
+}
+
+func TestAdminAuthenticator(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/v6/admin/migrate", nil)
+	r.Header.Set(service.InstanceIDHeaderKey, "inst")
+	auth.Bearer("secret").Authenticate(r)
+
+	// With no validator, nothing is let through
+	if _, err := (AdminAuthenticator{}).Authenticate(r, RoleAdmin); err != ErrorForbidden {
+		t.Errorf("expected request to be forbidden, got %v", err)
+	}
+
+	if _, err := (AdminAuthenticator{Validator: auth.Bearer("other")}).Authenticate(r, RoleAdmin); err == nil {
+		t.Error("expected request with the wrong token to be refused")
+	}
+	if inst, err := (AdminAuthenticator{Validator: auth.Bearer("secret")}).Authenticate(r, RoleAdmin); err != nil || inst != "inst" {
+		t.Errorf("expected request with token to be accepted for instance, got %q, %v", inst, err)
+	}
+}
+
+type tokensMap map[service.InstanceID]string
+
+func (m tokensMap) ValidateDaemonToken(inst service.InstanceID, token string) error {
+	if t, ok := m[inst]; ok && t == token {
+		return nil
+	}
+	return errors.New("unknown token")
+}
+
+func TestDaemonTokens(t *testing.T) {
+	a := DefaultAuthenticator{
+		Validator:    auth.Bearer("secret"),
+		DaemonTokens: tokensMap{"inst": "daemon-secret"},
+	}
+	request := func(authn api.Authenticator) *http.Request {
+		r, _ := http.NewRequest("GET", "/v6/daemon", nil)
+		r.Header.Set(service.InstanceIDHeaderKey, "inst")
+		if authn != nil {
+			authn.Authenticate(r)
+		}
+		return r
+	}
+
+	if inst, err := a.Authenticate(request(flux.Token("daemon-secret")), RoleDaemon); err != nil || inst != "inst" {
+		t.Errorf("expected daemon with its token to be accepted for instance, got %q, %v", inst, err)
+	}
+	for _, c := range []struct {
+		name  string
+		authn api.Authenticator
+		role  Role
+	}{
+		{"daemon without a token", nil, RoleDaemon},
+		{"daemon with another token", flux.Token("other"), RoleDaemon},
+		{"user's token used by a daemon", auth.Bearer("secret"), RoleDaemon},
+		{"daemon's token used for the API", flux.Token("daemon-secret"), RoleRead},
+	} {
+		if _, err := a.Authenticate(request(c.authn), c.role); err == nil {
+			t.Errorf("%s: expected request to be refused", c.name)
+		}
+	}
+}
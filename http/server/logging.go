@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux/redact"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+)
+
+// maxLoggedError is as much of the body of an error response as is
+// kept, to be logged. Errors are usually a line or two; this stops an
+// unusually large one from being held on to (and from growing the
+// pooled buffer it's kept in).
+const maxLoggedError = 4 * 1024
+
+// teeWriters are reused from one request to the next, buffers and
+// all, so that logging a request costs next to nothing, however big
+// the response.
+var teeWriters = sync.Pool{
+	New: func() interface{} { return &teeWriter{} },
+}
+
+// logging logs each request, after it's been served, with anything
+// the rules say shouldn't be kept redacted.
+func logging(next http.Handler, logger log.Logger, rules *redact.Rules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		begin := time.Now()
+		tw := teeWriters.Get().(*teeWriter)
+		tw.reset(w)
+		defer func() {
+			tw.reset(nil)
+			teeWriters.Put(tw)
+		}()
+
+		next.ServeHTTP(tw, r)
+
+		keyvals := []interface{}{
+			"url", mustUnescape(rules.URL(r.URL)),
+			"took", time.Since(begin).String(),
+			"status_code", tw.code,
+		}
+		if tw.code != http.StatusOK {
+			keyvals = append(keyvals, "error", rules.String(tw.errorText()))
+		}
+		md := remote.MetadataFrom(r.Context())
+		md.RequestID = rules.Header(service.RequestIDHeaderKey, md.RequestID)
+		md.User = rules.Header(service.UserHeaderKey, md.User)
+		logger.Log(append(keyvals, md.Keyvals()...)...)
+	})
+}
+
+// codeWriter intercepts the HTTP status code. WriteHeader may not be called in
+// case of success, so either prepopulate code with http.StatusOK, or check for
+// zero on the read side.
+type codeWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *codeWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *codeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *codeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
+	}
+	return hj.Hijack()
+}
+
+// teeWriter intercepts the HTTP status code, and if it's an error,
+// stores the start of the response. Successful responses are passed
+// straight through, since only errors are logged.
+type teeWriter struct {
+	codeWriter
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *teeWriter) reset(rw http.ResponseWriter) {
+	w.codeWriter = codeWriter{rw, http.StatusOK}
+	w.buf.Reset()
+	w.truncated = false
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	if w.code != http.StatusOK {
+		room := maxLoggedError - w.buf.Len()
+		if len(p) > room {
+			w.buf.Write(p[:room])
+			w.truncated = true
+		} else {
+			w.buf.Write(p) // best-effort
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *teeWriter) errorText() string {
+	text := strings.TrimSpace(w.buf.String())
+	if w.truncated {
+		text += " ..."
+	}
+	return text
+}
+
+func mustUnescape(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestLoggingErrors(t *testing.T) {
+	var logged map[interface{}]interface{}
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		logged = map[interface{}]interface{}{}
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			logged[keyvals[i]] = keyvals[i+1]
+		}
+		return nil
+	})
+	var status int
+	var body []byte
+	handler := logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write(body)
+	}), logger, nil)
+
+	for _, c := range []struct {
+		status   int
+		body     string
+		expected interface{}
+	}{
+		{http.StatusOK, "all good", nil},
+		{http.StatusNotFound, "no such service\n", "no such service"},
+		{http.StatusInternalServerError, strings.Repeat("x", maxLoggedError+1), strings.Repeat("x", maxLoggedError) + " ..."},
+		// the buffer from the last request is not carried over
+		{http.StatusBadRequest, "bad request", "bad request"},
+	} {
+		status, body = c.status, []byte(c.body)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/v6/services", nil))
+		if w.Body.String() != c.body {
+			t.Errorf("%d: expected the whole response to be passed on, got %q", c.status, w.Body.String())
+		}
+		if logged["status_code"] != c.status {
+			t.Errorf("%d: expected status code to be logged, got %v", c.status, logged["status_code"])
+		}
+		if logged["error"] != c.expected {
+			t.Errorf("%d: expected error %q to be logged, got %q", c.status, c.expected, logged["error"])
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter that keeps nothing, so that
+// benchmarks measure only what the middleware does.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkLoggingLargeResponse is like serving ListImages for an
+// instance with plenty of images: a large, successful response,
+// written in chunks as it's encoded.
+func BenchmarkLoggingLargeResponse(b *testing.B) {
+	chunk := bytes.Repeat([]byte(`{"ID":"quay.io/weaveworks/helloworld:master-a000001","CreatedAt":"2017-09-01T00:00:00Z"},`), 40)
+	handler := logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 256; i++ {
+			w.Write(chunk)
+		}
+	}), log.NewNopLogger(), nil)
+	r := httptest.NewRequest("GET", "/v6/images?service=default/helloworld", nil)
+	w := &discardWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(256 * len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, r)
+	}
+}
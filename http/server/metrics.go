@@ -1,19 +1,19 @@
 package server
 
 import (
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
 )
 
-func init() {
-	stdprometheus.MustRegister(requestDuration)
-}
-
 var (
-	requestDuration = stdprometheus.NewHistogramVec(stdprometheus.HistogramOpts{
+	requests = fluxmetrics.NewCounter(fluxmetrics.Opts{
+		Namespace: "flux",
+		Name:      "rate_limited_requests_total",
+		Help:      "Count of API requests (HTTP or gRPC) subject to the rate limit, and whether each was refused.",
+	}, "limited")
+	// Only counted with RateLimit.CountPerInstance
+	instanceRequests = fluxmetrics.NewCounter(fluxmetrics.Opts{
 		Namespace: "flux",
-		Name:      "request_duration_seconds",
-		Help:      "Time (in seconds) spent serving HTTP requests.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelRoute, "status_code", "ws"})
+		Name:      "instance_requests_total",
+		Help:      "Count of API requests (HTTP or gRPC) per instance, and whether each was refused by the rate limit.",
+	}, "instance", "limited")
 )
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/weaveworks/flux"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/service"
+)
+
+// RateLimit says how many requests each instance may make. Each
+// instance gets its own token bucket, so that one busy instance
+// can't crowd out the others.
+type RateLimit struct {
+	RPS   float64 // requests per second, on average; zero means no limit
+	Burst int     // how many requests can be made at once
+	// Count requests per instance, as well as in total. This makes a
+	// metric series for each instance, so is best left off when
+	// there are many instances.
+	CountPerInstance bool
+}
+
+// Buckets that haven't been used for this long at least are let go
+// of; see Limiter.get.
+const minLimiterIdle = time.Minute
+
+var ErrorRateLimited = &flux.BaseError{
+	Help: `Too many requests
+
+You (or the tools you are using) have made more requests to the flux
+service than it allows in a short time. Please wait for the time given
+in the Retry-After header, then try again.
+`,
+	Err: errors.New("rate limit exceeded"),
+}
+
+// Limiter keeps a token bucket per instance. It's shared by the
+// transports, so an instance gets the same allowance whether its
+// requests come over HTTP or gRPC.
+type Limiter struct {
+	limit RateLimit
+	idle  time.Duration
+
+	mu       sync.Mutex
+	limiters map[service.InstanceID]*instanceLimiter
+	swept    time.Time
+}
+
+type instanceLimiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+// NewLimiter makes a Limiter for the rate limit given; or, if there's
+// no limit, returns nil, which lets everything through.
+func NewLimiter(limit RateLimit) *Limiter {
+	if limit.RPS <= 0 {
+		return nil
+	}
+	// A bucket left alone long enough to fill up again is no
+	// different to a new one
+	idle := time.Duration(float64(limit.Burst) / limit.RPS * float64(time.Second))
+	if idle < minLimiterIdle {
+		idle = minLimiterIdle
+	}
+	return &Limiter{
+		limit:    limit,
+		idle:     idle,
+		limiters: map[service.InstanceID]*instanceLimiter{},
+	}
+}
+
+// get gives the bucket for the instance, as of now. So that
+// instances that have gone quiet don't keep their buckets forever,
+// every so often those that haven't been used for a while are let go
+// of; since they'll have filled up, a new one does just as well if
+// the instance comes back.
+func (l *Limiter) get(inst service.InstanceID, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) >= l.idle {
+		for id, limiter := range l.limiters {
+			if now.Sub(limiter.lastUsed) >= l.idle {
+				delete(l.limiters, id)
+			}
+		}
+		l.swept = now
+	}
+	limiter, ok := l.limiters[inst]
+	if !ok {
+		limiter = &instanceLimiter{Limiter: rate.NewLimiter(rate.Limit(l.limit.RPS), l.limit.Burst)}
+		l.limiters[inst] = limiter
+	}
+	limiter.lastUsed = now
+	return limiter.Limiter
+}
+
+// count records a request, and whether it was refused.
+func (l *Limiter) count(inst service.InstanceID, limited bool) {
+	requests.With("limited", fmt.Sprint(limited)).Add(1)
+	if l.limit.CountPerInstance {
+		instanceRequests.With("instance", string(inst), "limited", fmt.Sprint(limited)).Add(1)
+	}
+}
+
+// Allow says whether the instance may make a request now. If not, it
+// also says how long to wait before trying again, or zero if the
+// request can never be allowed.
+func (l *Limiter) Allow(inst service.InstanceID) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := time.Now()
+	reservation := l.get(inst, now).ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if !reservation.OK() || delay > 0 {
+		// We're not going to wait; give the token back so that
+		// refused requests don't count against the instance.
+		reservation.CancelAt(now)
+		l.count(inst, true)
+		if !reservation.OK() {
+			delay = 0
+		}
+		return false, delay
+	}
+	l.count(inst, false)
+	return true, 0
+}
+
+// LimitInstances wraps a handler so that requests from an instance
+// are refused, with 429 Too Many Requests, if the instance has made
+// more than its share of requests recently.
+func LimitInstances(l *Limiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := l.Allow(getInstanceID(r))
+		if !ok {
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+			transport.WriteError(w, r, http.StatusTooManyRequests, ErrorRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
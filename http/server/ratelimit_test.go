@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/service"
+)
+
+func TestLimitInstances(t *testing.T) {
+	// A rate low enough that no tokens come back during the test
+	handler := LimitInstances(NewLimiter(RateLimit{RPS: 0.01, Burst: 2}), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(inst string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/v6/services", nil)
+		r.Header.Set(service.InstanceIDHeaderKey, inst)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("noisy"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: expected status 200, got %d", i, w.Code)
+		}
+	}
+	w := request("noisy")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 after burst, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header on refused request")
+	}
+
+	// Another instance has its own allowance
+	if w := request("quiet"); w.Code != http.StatusOK {
+		t.Errorf("expected other instance to be allowed, got %d", w.Code)
+	}
+}
+
+func TestLimitInstancesNoLimit(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := LimitInstances(NewLimiter(RateLimit{}), next)
+	for i := 0; i < 100; i++ {
+		r, _ := http.NewRequest("GET", "/v6/services", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected no limit with zero RPS, got status %d", w.Code)
+		}
+	}
+}
+
+func TestLimiterLetsGoOfIdleInstances(t *testing.T) {
+	l := NewLimiter(RateLimit{RPS: 1, Burst: 1})
+	t0 := time.Now()
+	l.get("gone-quiet", t0)
+	l.get("still-busy", t0.Add(30*time.Second))
+	l.get("new", t0.Add(l.idle+time.Second))
+
+	if _, ok := l.limiters["gone-quiet"]; ok {
+		t.Error("expected the bucket of an instance idle for longer than it takes to fill up to be let go of")
+	}
+	for _, inst := range []service.InstanceID{"still-busy", "new"} {
+		if _, ok := l.limiters[inst]; !ok {
+			t.Errorf("expected the bucket of %s to be kept", inst)
+		}
+	}
+}
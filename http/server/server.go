@@ -1,24 +1,22 @@
 package server
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
+	"io/ioutil"
 	"net/http"
-	"net/url"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	"github.com/weaveworks/common/middleware"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/guid"
 	"github.com/weaveworks/flux/history"
 	transport "github.com/weaveworks/flux/http"
 	"github.com/weaveworks/flux/http/httperror"
@@ -26,9 +24,11 @@ import (
 	"github.com/weaveworks/flux/integrations/github"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/redact"
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/remote/rpc"
 	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -57,12 +57,25 @@ func NewServiceRouter() *mux.Router {
 
 	// V6 service routes
 	r.NewRoute().Name("History").Methods("GET").Path("/v6/history").Queries("service", "{service}")
+	r.NewRoute().Name("DeployedImages").Methods("GET").Path("/v6/deployed").Queries("service", "{service}")
+	r.NewRoute().Name("ServiceSummaries").Methods("GET").Path("/v6/history/summary")
+	r.NewRoute().Name("ReleaseNotes").Methods("GET").Path("/v6/release-notes").Queries("id", "{id}")
 	r.NewRoute().Name("Status").Methods("GET").Path("/v6/status")
 	r.NewRoute().Name("GetConfig").Methods("GET").Path("/v6/config")
 	r.NewRoute().Name("SetConfig").Methods("POST").Path("/v6/config")
 	r.NewRoute().Name("PatchConfig").Methods("PATCH").Path("/v6/config")
+	r.NewRoute().Name("ListRecycledConfigs").Methods("GET").Path("/v6/config/recycled")
+	r.NewRoute().Name("RestoreConfig").Methods("POST").Path("/v6/config/restore").Queries("index", "{index}")
 	r.NewRoute().Name("PostIntegrationsGithub").Methods("POST").Path("/v6/integrations/github").Queries("owner", "{owner}", "repository", "{repository}")
 	r.NewRoute().Name("IsConnected").Methods("HEAD", "GET").Path("/v6/ping")
+	r.NewRoute().Name("ListWebhookSecrets").Methods("GET").Path("/v6/webhooks")
+	r.NewRoute().Name("CreateWebhookSecret").Methods("POST").Path("/v6/webhooks/{hook}/secret")
+	r.NewRoute().Name("DeleteWebhookSecret").Methods("DELETE").Path("/v6/webhooks/{hook}/secret")
+	r.NewRoute().Name("GitWebhook").Methods("POST").Path("/v6/webhooks/git")
+	r.NewRoute().Name("IssueDaemonToken").Methods("POST").Path("/v6/daemon-token")
+	r.NewRoute().Name("Promote").Methods("POST").Path("/v6/promotions")
+	r.NewRoute().Name("ListPromotions").Methods("GET").Path("/v6/promotions")
+	r.NewRoute().Name("CancelPromotion").Methods("DELETE").Path("/v6/promotions/{id}")
 
 	// We assume every request that doesn't match a route is a client
 	// calling an old or hitherto unsupported API.
@@ -73,19 +86,32 @@ func NewServiceRouter() *mux.Router {
 	return r
 }
 
-func NewHandler(s api.FluxService, r *mux.Router, logger log.Logger) http.Handler {
-	handle := HTTPService{s}
+func NewHandler(s api.FluxService, r *mux.Router, authn Authenticator, secrets WebhookSecrets, limiter *Limiter, rpcTimeouts rpc.Timeouts, logger log.Logger, rules *redact.Rules) http.Handler {
+	handle := HTTPService{s, rpcTimeouts}
 	for method, handlerMethod := range map[string]http.HandlerFunc{
 		"ListServices":             handle.ListServices,
 		"ListServicesV3":           handle.ListServices,
 		"ListImages":               handle.ListImages,
 		"ListImagesV3":             handle.ListImages,
+		"EvaluateImage":            handle.EvaluateImage,
+		"ServiceTopology":          handle.ServiceTopology,
+		"SyncHealth":               handle.SyncHealth,
+		"ListResources":            handle.ListResources,
+		"SyncWait":                 handle.SyncWait,
 		"UpdateImages":             handle.UpdateImages,
+		"PlanRelease":              handle.PlanRelease,
 		"UpdatePolicies":           handle.UpdatePolicies,
 		"UpdatePoliciesV4":         handle.UpdatePolicies,
+		"ValidatePolicies":         handle.ValidatePolicies,
+		"UpdateBatch":              handle.UpdateBatch,
+		"AddManifests":             handle.AddManifests,
+		"Rollback":                 handle.Rollback,
 		"LogEvent":                 handle.LogEvent,
 		"History":                  handle.History,
 		"HistoryV3":                handle.History,
+		"DeployedImages":           handle.DeployedImages,
+		"ServiceSummaries":         handle.ServiceSummaries,
+		"ReleaseNotes":             handle.ReleaseNotes,
 		"Status":                   handle.Status,
 		"StatusV3":                 handle.Status,
 		"GetConfigV4":              handle.GetConfig,
@@ -94,6 +120,8 @@ func NewHandler(s api.FluxService, r *mux.Router, logger log.Logger) http.Handle
 		"SetConfigV4":              handle.SetConfig,
 		"PatchConfig":              handle.PatchConfig,
 		"PatchConfigV4":            handle.PatchConfig,
+		"ListRecycledConfigs":      handle.ListRecycledConfigs,
+		"RestoreConfig":            handle.RestoreConfig,
 		"PostIntegrationsGithub":   handle.PostIntegrationsGithub,
 		"PostIntegrationsGithubV5": handle.PostIntegrationsGithub,
 		"Export":                   handle.Export,
@@ -101,34 +129,74 @@ func NewHandler(s api.FluxService, r *mux.Router, logger log.Logger) http.Handle
 		"RegisterDaemon":           handle.RegisterV6,
 		"IsConnected":              handle.IsConnected,
 		"SyncNotify":               handle.SyncNotify,
+		"ResetGitToRemote":         handle.ResetGitToRemote,
 		"JobStatus":                handle.JobStatus,
+		"JobLog":                   handle.JobLog,
+		"WatchJob":                 handle.WatchJob,
+		"WatchEvents":              handle.WatchEvents,
 		"SyncStatus":               handle.SyncStatus,
 		"GetPublicSSHKey":          handle.GetPublicSSHKey,
 		"RegeneratePublicSSHKey":   handle.RegeneratePublicSSHKey,
+		"Check":                    handle.Check,
+		"ListWebhookSecrets":       handle.ListWebhookSecrets,
+		"CreateWebhookSecret":      handle.CreateWebhookSecret,
+		"DeleteWebhookSecret":      handle.DeleteWebhookSecret,
+		"GitWebhook":               ValidateWebhook(secrets, "git", http.HandlerFunc(handle.SyncNotify)).ServeHTTP,
+		"IssueDaemonToken":         handle.IssueDaemonToken,
+		"Promote":                  handle.Promote,
+		"ListPromotions":           handle.ListPromotions,
+		"CancelPromotion":          handle.CancelPromotion,
+		"Spec":                     transport.SpecHandler(r, "Flux service API", serviceOperations()),
 	} {
-		handler := logging(handlerMethod, log.NewContext(logger).With("method", method))
+		handler := logging(handlerMethod, log.NewContext(logger).With("method", method), rules)
 		r.Get(method).Handler(handler)
 	}
 
-	return middleware.Instrument{
-		RouteMatcher: r,
-		Duration:     requestDuration,
-	}.Wrap(r)
+	return transport.Instrument(r, Authenticate(authn, requestMetadata(LimitInstances(limiter, r))))
+}
+
+// NewAdminHandler serves the admin routes, for moving instances
+// between services and injecting faults. These aren't for the users
+// of instances, so are kept off the API: they're meant to be served
+// on a listener of their own, with an Authenticator that only lets
+// operators through (e.g., AdminAuthenticator).
+func NewAdminHandler(s api.FluxService, authn Authenticator, logger log.Logger, rules *redact.Rules) http.Handler {
+	r := transport.NewAdminRouter()
+	r.NewRoute().Name("Spec").Methods("GET").Path("/v6/spec")
+	r.NewRoute().Name("NotFound").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transport.WriteError(w, r, http.StatusNotFound, transport.MakeAPINotFound(r.URL.Path))
+	})
+
+	handle := HTTPService{service: s}
+	for method, handlerMethod := range map[string]http.HandlerFunc{
+		"ExportInstance":  handle.ExportInstance,
+		"ImportInstance":  handle.ImportInstance,
+		"MigrateInstance": handle.MigrateInstance,
+		"InjectFault":     handle.InjectFault,
+		"Spec":            transport.SpecHandler(r, "Flux service admin API", adminOperations),
+	} {
+		handler := logging(handlerMethod, log.NewContext(logger).With("method", method), rules)
+		r.Get(method).Handler(handler)
+	}
+
+	return transport.Instrument(r, Authenticate(authn, requestMetadata(r)))
 }
 
 type HTTPService struct {
 	service api.FluxService
+	// how long to wait for connected daemons to answer each method
+	rpcTimeouts rpc.Timeouts
 }
 
 func (s HTTPService) ListServices(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 	namespace := mux.Vars(r)["namespace"]
-	res, err := s.service.ListServices(inst, namespace)
+	res, err := s.service.ListServices(r.Context(), inst, namespace)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
-	transport.JSONResponse(w, r, res)
+	transport.ETagJSONResponse(w, r, res)
 }
 
 func (s HTTPService) ListImages(w http.ResponseWriter, r *http.Request) {
@@ -140,62 +208,177 @@ func (s HTTPService) ListImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	d, err := s.service.ListImages(inst, spec)
+	d, err := s.service.ListImages(r.Context(), inst, spec)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.ETagJSONResponse(w, r, d)
+}
+
+func (s HTTPService) EvaluateImage(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	image := mux.Vars(r)["image"]
+	id, err := flux.ParseImageID(image)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing image %q", image))
+		return
+	}
+
+	res, err := s.service.EvaluateImage(r.Context(), inst, id)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) ServiceTopology(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	res, err := s.service.ServiceTopology(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) SyncHealth(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	res, err := s.service.SyncHealth(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) ListResources(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	res, err := s.service.ListResources(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) SyncWait(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var req remote.SyncWaitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := s.service.SyncWait(r.Context(), inst, req)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
 
-	transport.JSONResponse(w, r, d)
+	transport.JSONResponse(w, r, res)
 }
 
 func (s HTTPService) UpdateImages(w http.ResponseWriter, r *http.Request) {
-	var (
-		inst  = getInstanceID(r)
-		vars  = mux.Vars(r)
-		image = vars["image"]
-		kind  = vars["kind"]
-	)
-	if err := r.ParseForm(); err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing form"))
+	inst := getInstanceID(r)
+	spec, err := transport.ParseReleaseSpec(r)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	var serviceSpecs []update.ServiceSpec
-	for _, service := range r.Form["service"] {
-		serviceSpec, err := update.ParseServiceSpec(service)
-		if err != nil {
-			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service spec %q", service))
-			return
-		}
-		serviceSpecs = append(serviceSpecs, serviceSpec)
+
+	jobID, err := s.service.UpdateImages(r.Context(), inst, spec, update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
 	}
-	imageSpec, err := update.ParseImageSpec(image)
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPService) PlanRelease(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	spec, err := transport.ParseReleaseSpec(r)
 	if err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing image spec %q", image))
+		transport.WriteError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	releaseKind, err := update.ParseReleaseKind(kind)
+	spec.Kind = update.ReleaseKindPlan
+
+	plan, err := s.service.PlanRelease(r.Context(), inst, spec)
 	if err != nil {
-		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing release kind %q", kind))
+		transport.ErrorResponse(w, r, err)
 		return
 	}
+	transport.JSONResponse(w, r, plan)
+}
 
-	var excludes []flux.ServiceID
-	for _, ex := range r.URL.Query()["exclude"] {
-		s, err := flux.ParseServiceID(ex)
-		if err != nil {
-			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing excluded service %q", ex))
-			return
-		}
-		excludes = append(excludes, s)
+func (s HTTPService) UpdateBatch(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var steps update.BatchSpec
+	if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	jobID, err := s.service.UpdateBatch(r.Context(), inst, steps, update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPService) AddManifests(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var spec update.AddSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
 	}
 
-	jobID, err := s.service.UpdateImages(inst, update.ReleaseSpec{
-		ServiceSpecs: serviceSpecs,
-		ImageSpec:    imageSpec,
-		Kind:         releaseKind,
-		Excludes:     excludes,
-	}, update.Cause{
+	jobID, err := s.service.AddManifests(r.Context(), inst, spec, update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPService) Rollback(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var spec update.RollbackSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	jobID, err := s.service.Rollback(r.Context(), inst, spec, update.Cause{
 		User:    r.FormValue("user"),
 		Message: r.FormValue("message"),
 	})
@@ -209,7 +392,7 @@ func (s HTTPService) UpdateImages(w http.ResponseWriter, r *http.Request) {
 
 func (s HTTPService) SyncNotify(w http.ResponseWriter, r *http.Request) {
 	instID := getInstanceID(r)
-	err := s.service.SyncNotify(instID)
+	err := s.service.SyncNotify(r.Context(), instID)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -217,10 +400,31 @@ func (s HTTPService) SyncNotify(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (s HTTPService) ResetGitToRemote(w http.ResponseWriter, r *http.Request) {
+	instID := getInstanceID(r)
+	rev, err := s.service.ResetGitToRemote(r.Context(), instID)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, rev)
+}
+
 func (s HTTPService) JobStatus(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 	id := job.ID(mux.Vars(r)["id"])
-	res, err := s.service.JobStatus(inst, id)
+	res, err := s.service.JobStatus(r.Context(), inst, id)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) JobLog(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id := job.ID(mux.Vars(r)["id"])
+	res, err := s.service.JobLog(r.Context(), inst, id)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -228,10 +432,62 @@ func (s HTTPService) JobStatus(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, res)
 }
 
+func (s HTTPService) WatchJob(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id := job.ID(mux.Vars(r)["id"])
+	transport.StreamJobStatus(w, r, func(ctx context.Context, updates chan<- job.Status) error {
+		return s.service.WatchJob(ctx, inst, id, updates)
+	})
+}
+
+func (s HTTPService) WatchEvents(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	ws, err := websocket.Upgrade(w, r, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	defer ws.Close()
+
+	// A hijacked connection's request context isn't cancelled when
+	// the client goes away, so we cancel it ourselves.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events := make(chan history.Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.service.WatchEvents(ctx, inst, events)
+	}()
+
+	// Nothing is expected from the client; reading is just how we
+	// find out that it's gone away.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(gone)
+	}()
+
+	enc := json.NewEncoder(ws)
+	for {
+		select {
+		case e := <-events:
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		case <-errc:
+			return
+		case <-gone:
+			return
+		}
+	}
+}
+
 func (s HTTPService) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 	rev := mux.Vars(r)["ref"]
-	res, err := s.service.SyncStatus(inst, rev)
+	res, err := s.service.SyncStatus(r.Context(), inst, rev)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -248,10 +504,10 @@ func (s HTTPService) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobID, err := s.service.UpdatePolicies(inst, updates, update.Cause{
+	jobID, err := s.service.UpdatePolicies(r.Context(), inst, updates, update.Cause{
 		User:    r.FormValue("user"),
 		Message: r.FormValue("message"),
-	})
+	}, r.FormValue("dryRun") == "true")
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -260,6 +516,23 @@ func (s HTTPService) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, jobID)
 }
 
+func (s HTTPService) ValidatePolicies(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var updates policy.Updates
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.service.ValidatePolicies(r.Context(), inst, updates); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s HTTPService) LogEvent(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 
@@ -311,7 +584,7 @@ func (s HTTPService) History(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h, err := s.service.History(inst, spec, before, limit, after)
+	h, err := s.service.History(r.Context(), inst, spec, before, limit, after)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -327,10 +600,56 @@ func (s HTTPService) History(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, h)
 }
 
+func (s HTTPService) DeployedImages(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id, err := flux.ParseServiceID(mux.Vars(r)["service"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing service ID %q", mux.Vars(r)["service"]))
+		return
+	}
+
+	at := time.Now().UTC()
+	if r.FormValue("at") != "" {
+		at, err = time.Parse(time.RFC3339Nano, r.FormValue("at"))
+		if err != nil {
+			transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing time %q", r.FormValue("at")))
+			return
+		}
+	}
+
+	deployed, err := s.service.DeployedImages(r.Context(), inst, id, at)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, deployed)
+}
+
+func (s HTTPService) ServiceSummaries(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	summaries, err := s.service.ServiceSummaries(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, summaries)
+}
+
+func (s HTTPService) ReleaseNotes(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id := job.ID(mux.Vars(r)["id"])
+	notes, err := s.service.ReleaseNotes(r.Context(), inst, id)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, notes)
+}
+
 func (s HTTPService) GetConfig(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 	fingerprint := r.FormValue("fingerprint")
-	config, err := s.service.GetConfig(inst, fingerprint)
+	config, err := s.service.GetConfig(r.Context(), inst, fingerprint)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -348,10 +667,14 @@ func (s HTTPService) SetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.service.SetConfig(inst, config); err != nil {
+	before, beforeErr := s.service.GetConfig(r.Context(), inst, "")
+	if err := s.service.SetConfig(r.Context(), inst, config); err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
+	if beforeErr == nil {
+		warnRemovedSections(w, before, config)
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -365,14 +688,138 @@ func (s HTTPService) PatchConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.service.PatchConfig(inst, patch); err != nil {
+	before, beforeErr := s.service.GetConfig(r.Context(), inst, "")
+	if err := s.service.PatchConfig(r.Context(), inst, patch); err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
 	}
+	if beforeErr == nil {
+		if after, err := s.service.GetConfig(r.Context(), inst, ""); err == nil {
+			warnRemovedSections(w, before, after)
+		}
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// warnRemovedSections adds a warning to the response for each part of
+// the config that was there before an update, but isn't after it,
+// since that's more often a mistake than not.
+func warnRemovedSections(w http.ResponseWriter, before, after service.InstanceConfig) {
+	removed, err := before.RemovedSections(after)
+	if err != nil {
+		return
+	}
+	for _, section := range removed {
+		w.Header().Add("Warning", fmt.Sprintf(`199 flux "config %s was removed; the previous config can be restored with POST /v6/config/restore?index=0"`, section))
+	}
+}
+
+func (s HTTPService) ListRecycledConfigs(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	recycled, err := s.service.ListRecycledConfigs(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, recycled)
+}
+
+func (s HTTPService) RestoreConfig(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, errors.Wrapf(err, "parsing index %q", mux.Vars(r)["index"]))
+		return
+	}
+	if err := s.service.RestoreConfig(r.Context(), inst, index); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s HTTPService) ListWebhookSecrets(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	secrets, err := s.service.ListWebhookSecrets(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, secrets)
+}
+
+func (s HTTPService) CreateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	hook := mux.Vars(r)["hook"]
+	secret, err := s.service.CreateWebhookSecret(r.Context(), inst, hook)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, secret)
+}
+
+func (s HTTPService) DeleteWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	hook := mux.Vars(r)["hook"]
+	if err := s.service.DeleteWebhookSecret(r.Context(), inst, hook); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s HTTPService) IssueDaemonToken(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	token, err := s.service.IssueDaemonToken(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, token)
+}
+
+func (s HTTPService) Promote(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var spec service.PromotionSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := s.service.Promote(r.Context(), inst, spec, update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, id)
+}
+
+func (s HTTPService) ListPromotions(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	promotions, err := s.service.ListPromotions(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, promotions)
+}
+
+func (s HTTPService) CancelPromotion(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id := mux.Vars(r)["id"]
+	if err := s.service.CancelPromotion(r.Context(), inst, id); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s HTTPService) PostIntegrationsGithub(w http.ResponseWriter, r *http.Request) {
 	var (
 		inst  = getInstanceID(r)
@@ -388,7 +835,7 @@ func (s HTTPService) PostIntegrationsGithub(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Obtain public key from daemon
-	publicKey, err := s.service.PublicSSHKey(inst, false)
+	publicKey, err := s.service.PublicSSHKey(r.Context(), inst, false)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -415,7 +862,7 @@ func (s HTTPService) PostIntegrationsGithub(w http.ResponseWriter, r *http.Reque
 
 func (s HTTPService) Status(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
-	status, err := s.service.Status(inst)
+	status, err := s.service.Status(r.Context(), inst)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -424,27 +871,48 @@ func (s HTTPService) Status(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, status)
 }
 
+// RegisterV6 handles daemons connecting at the V6 endpoint. The
+// version of the RPC protocol is negotiated when the connection is
+// upgraded to a websocket, starting with V6.
 func (s HTTPService) RegisterV6(w http.ResponseWriter, r *http.Request) {
-	s.doRegister(w, r, func(conn io.ReadWriteCloser) platformCloser {
-		return rpc.NewClientV6(conn)
-	})
-}
-
-type platformCloser interface {
-	remote.Platform
-	io.Closer
+	s.doRegister(w, r)
 }
 
-type platformCloserFn func(io.ReadWriteCloser) platformCloser
-
-func (s HTTPService) doRegister(w http.ResponseWriter, r *http.Request, newRPCFn platformCloserFn) {
+func (s HTTPService) doRegister(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 
+	// If the instance has moved to another service, the daemon
+	// should go there instead.
+	migratedTo, err := s.service.MigratedTo(inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	if migratedTo != "" {
+		u, err := transport.MakeURL(migratedTo, transport.NewUpstreamRouter(), "RegisterDaemon")
+		if err != nil {
+			transport.ErrorResponse(w, r, err)
+			return
+		}
+		http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+		return
+	}
+
 	// This is not client-facing, so we don't do content
-	// negotiation here.
+	// negotiation here; but we do agree on a protocol for RPC.
+	offered := websocket.Subprotocols(r)
+	protocol, err := rpc.SelectProtocol(offered)
+	if err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	var header http.Header
+	if len(offered) > 0 {
+		header = http.Header{"Sec-Websocket-Protocol": []string{protocol}}
+	}
 
 	// Upgrade to a websocket
-	ws, err := websocket.Upgrade(w, r, nil)
+	ws, err := websocket.Upgrade(w, r, header)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, err.Error())
@@ -453,7 +921,23 @@ func (s HTTPService) doRegister(w http.ResponseWriter, r *http.Request, newRPCFn
 
 	// Set up RPC. The service is a websocket _server_ but an RPC
 	// _client_.
-	rpcClient := newRPCFn(ws)
+	rpcClient, err := rpc.NewClient(protocol, ws, s.rpcTimeouts)
+	if err != nil {
+		// Can't happen, since we selected a protocol we know
+		ws.Close()
+		return
+	}
+
+	// The daemon may send a new token when its old one is rotated,
+	// rather than reconnecting. Tokens are checked before requests
+	// get here (that's how we know the instance ID), and the
+	// connection stays with the instance it was made for; so all
+	// there is to do is let the daemon know it can carry on.
+	ws.OnControl(func(m websocket.ControlMessage) {
+		if m.Type == websocket.ControlRefreshToken {
+			ws.SendControl(websocket.ControlMessage{Type: websocket.ControlTokenRefreshed})
+		}
+	})
 
 	// Make platform available to clients
 	// This should block until the daemon disconnects
@@ -465,6 +949,66 @@ func (s HTTPService) doRegister(w http.ResponseWriter, r *http.Request, newRPCFn
 	rpcClient.Close() // also closes the underlying socket
 }
 
+func (s HTTPService) ExportInstance(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	m, err := s.service.ExportInstance(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, m)
+}
+
+func (s HTTPService) ImportInstance(w http.ResponseWriter, r *http.Request) {
+	var m instance.Migration
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Unless told otherwise, the instance keeps its ID
+	inst := getInstanceID(r)
+	if inst == service.NoInstanceID {
+		inst = m.ID
+	}
+	if err := s.service.ImportInstance(r.Context(), inst, m); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s HTTPService) MigrateInstance(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	var target service.MigrationTarget
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.service.MigrateInstance(r.Context(), inst, target); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s HTTPService) InjectFault(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	var spec remote.FaultSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.service.InjectFault(r.Context(), inst, spec); err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s HTTPService) IsConnected(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 
@@ -483,6 +1027,14 @@ func (s HTTPService) IsConnected(w http.ResponseWriter, r *http.Request) {
 		transport.JSONResponse(w, r, service.FluxdStatus{
 			Connected: false,
 		})
+	case service.DaemonReconnecting:
+		reconnecting := err.(service.DaemonReconnecting)
+		transport.JSONResponse(w, r, service.FluxdStatus{
+			Connected:     false,
+			Reconnecting:  true,
+			LastHeartbeat: reconnecting.LastSeen,
+			Version:       reconnecting.Version,
+		})
 	case remote.FatalError: // An error from nats, but probably due to not connected.
 		transport.JSONResponse(w, r, service.FluxdStatus{
 			Connected: false,
@@ -494,7 +1046,25 @@ func (s HTTPService) IsConnected(w http.ResponseWriter, r *http.Request) {
 
 func (s HTTPService) Export(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
-	status, err := s.service.Export(inst)
+	if ref := r.URL.Query().Get("ref"); ref != "" {
+		manifests, err := s.service.ExportAt(r.Context(), inst, ref)
+		if err != nil {
+			transport.ErrorResponse(w, r, err)
+			return
+		}
+		transport.YAMLResponse(w, r, manifests)
+		return
+	}
+	// Clients that can take it get the YAML as it comes, rather than
+	// in a JSON string once it's all arrived.
+	if transport.AcceptsStream(r, transport.YAMLContentType) {
+		transport.StreamResponse(w, r, transport.YAMLContentType, func(out io.Writer) error {
+			return s.service.ExportTo(r.Context(), inst, out)
+		})
+		return
+	}
+
+	status, err := s.service.Export(r.Context(), inst)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -505,7 +1075,7 @@ func (s HTTPService) Export(w http.ResponseWriter, r *http.Request) {
 
 func (s HTTPService) GetPublicSSHKey(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
-	publicSSHKey, err := s.service.PublicSSHKey(inst, false)
+	publicSSHKey, err := s.service.PublicSSHKey(r.Context(), inst, false)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -516,7 +1086,7 @@ func (s HTTPService) GetPublicSSHKey(w http.ResponseWriter, r *http.Request) {
 
 func (s HTTPService) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
-	_, err := s.service.PublicSSHKey(inst, true)
+	_, err := s.service.PublicSSHKey(r.Context(), inst, true)
 	if err != nil {
 		transport.ErrorResponse(w, r, err)
 		return
@@ -526,25 +1096,35 @@ func (s HTTPService) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Reque
 	return
 }
 
+func (s HTTPService) Check(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	report, err := s.service.Check(r.Context(), inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, report)
+}
+
 // --- end handlers
 
-func logging(next http.Handler, logger log.Logger) http.Handler {
+// requestMetadata puts the metadata for a request in its context, so
+// that it's logged, and passed along to the daemon with any calls
+// made on behalf of the request. A request ID is made up if the
+// request doesn't come with one; either way, it's put in the
+// response, so it can be quoted back.
+func requestMetadata(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		begin := time.Now()
-		cw := &codeWriter{w, http.StatusOK}
-		tw := &teeWriter{cw, bytes.Buffer{}}
-
-		next.ServeHTTP(tw, r)
-
-		requestLogger := log.NewContext(logger).With(
-			"url", mustUnescape(r.URL.String()),
-			"took", time.Since(begin).String(),
-			"status_code", cw.code,
-		)
-		if cw.code != http.StatusOK {
-			requestLogger = requestLogger.With("error", strings.TrimSpace(tw.buf.String()))
+		md := remote.Metadata{
+			RequestID: r.Header.Get(service.RequestIDHeaderKey),
+			User:      r.Header.Get(service.UserHeaderKey),
 		}
-		requestLogger.Log()
+		if md.RequestID == "" {
+			md.RequestID = guid.New()
+		}
+		w.Header().Set(service.RequestIDHeaderKey, md.RequestID)
+		next.ServeHTTP(w, r.WithContext(remote.WithMetadata(r.Context(), md)))
 	})
 }
 
@@ -555,50 +1135,3 @@ func getInstanceID(req *http.Request) service.InstanceID {
 	}
 	return service.InstanceID(s)
 }
-
-// codeWriter intercepts the HTTP status code. WriteHeader may not be called in
-// case of success, so either prepopulate code with http.StatusOK, or check for
-// zero on the read side.
-type codeWriter struct {
-	http.ResponseWriter
-	code int
-}
-
-func (w *codeWriter) WriteHeader(code int) {
-	w.code = code
-	w.ResponseWriter.WriteHeader(code)
-}
-
-func (w *codeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj, ok := w.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
-	}
-	return hj.Hijack()
-}
-
-// teeWriter intercepts and stores the HTTP response.
-type teeWriter struct {
-	http.ResponseWriter
-	buf bytes.Buffer
-}
-
-func (w *teeWriter) Write(p []byte) (int, error) {
-	w.buf.Write(p) // best-effort
-	return w.ResponseWriter.Write(p)
-}
-
-func (w *teeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj, ok := w.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
-	}
-	return hj.Hijack()
-}
-
-func mustUnescape(s string) string {
-	if unescaped, err := url.QueryUnescape(s); err == nil {
-		return unescaped
-	}
-	return s
-}
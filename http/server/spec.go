@@ -0,0 +1,118 @@
+package server
+
+import (
+	"github.com/weaveworks/flux/history"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/http/openapi"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
+	"github.com/weaveworks/flux/update"
+)
+
+// The service-only v6 routes; the upstream (daemon-facing) routes and
+// the routes kept for backwards compatibility aren't included.
+var serviceOnlyOperations = map[string]openapi.Operation{
+	"History": {
+		Summary:  "Get the history of events for a service, or all services",
+		Query:    []string{"service", "before", "after", "limit", "simple"},
+		Response: []history.Entry{},
+	},
+	"DeployedImages": {
+		Summary:  "Get the image deployed to each container of a service at a point in time (default now)",
+		Query:    []string{"service", "at"},
+		Response: []history.DeployedImage{},
+	},
+	"ServiceSummaries": {
+		Summary:  "Get the latest release and sync of every service, and whether it's automated or locked, e.g., for showing a list of services",
+		Response: []history.ServiceSummary{},
+	},
+	"ReleaseNotes": {
+		Summary:  "Compose release notes for a job that has finished; these include the git commits images were built from, if the images are labelled with them",
+		Query:    []string{"id"},
+		Response: update.ReleaseNotes{},
+	},
+	"Status": {
+		Summary:  "Get the status of the service, daemon and git repo",
+		Response: service.Status{},
+	},
+	"GetConfig": {
+		Summary:  "Get the instance configuration",
+		Query:    []string{"fingerprint"},
+		Response: service.InstanceConfig{},
+	},
+	"SetConfig": {
+		Summary: "Replace the instance configuration",
+		Request: service.InstanceConfig{},
+	},
+	"PatchConfig": {
+		Summary: "Update parts of the instance configuration",
+		Request: service.ConfigPatch{},
+	},
+	"ListRecycledConfigs": {
+		Summary:  "List the versions of the instance configuration that have been replaced, most recent first",
+		Response: []service.RecycledConfig{},
+	},
+	"RestoreConfig": {
+		Summary: "Restore the instance configuration at the index given in the list of replaced versions",
+		Query:   []string{"index"},
+	},
+	"PostIntegrationsGithub": {
+		Summary: "Add the daemon's public key as a deploy key to a GitHub repository",
+		Query:   []string{"owner", "repository"},
+	},
+	"ListWebhookSecrets": {
+		Summary:  "List the webhooks that have secrets, without the secrets",
+		Response: []service.WebhookSecret{},
+	},
+	"CreateWebhookSecret": {
+		Summary:  "Create or replace the secret for a webhook; the secret is only ever returned here",
+		Response: service.WebhookSecret{},
+	},
+	"DeleteWebhookSecret": {
+		Summary: "Remove the secret for a webhook",
+	},
+	"GitWebhook": {
+		Summary: "Receive a push notification from the git host, signed with the secret for the \"git\" webhook, and tell the daemon to sync",
+	},
+	"IssueDaemonToken": {
+		Summary:  "Issue a new token for the daemon, replacing the oldest; the token is only ever returned here",
+		Response: service.DaemonToken{},
+	},
+	"IsConnected": {
+		Summary:  "Check whether the daemon is connected",
+		Response: service.FluxdStatus{},
+	},
+}
+
+func serviceOperations() map[string]openapi.Operation {
+	ops := map[string]openapi.Operation{}
+	for name, op := range transport.APIOperations {
+		ops[name] = op
+	}
+	for name, op := range serviceOnlyOperations {
+		ops[name] = op
+	}
+	return ops
+}
+
+// The admin routes, which are served on their own (see
+// NewAdminHandler).
+var adminOperations = map[string]openapi.Operation{
+	"ExportInstance": {
+		Summary:  "Export the instance's configuration and history, e.g., to import elsewhere",
+		Response: instance.Migration{},
+	},
+	"ImportInstance": {
+		Summary: "Import configuration and history exported from another service; into the instance given, or else the instance exported",
+		Request: instance.Migration{},
+	},
+	"MigrateInstance": {
+		Summary: "Move the instance to another service (one of those this service is allowed to migrate to), and redirect its daemon there",
+		Request: service.MigrationTarget{},
+	},
+	"InjectFault": {
+		Summary: "Make the daemon simulate a failure for a while (at most an hour), to test alerting",
+		Request: remote.FaultSpec{},
+	},
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	transport "github.com/weaveworks/flux/http"
+	"github.com/weaveworks/flux/service"
+)
+
+// The header in which webhook senders put the signature of the
+// request body. This is the header GitHub uses; others (e.g., Docker
+// registries) can be configured to do the same.
+const WebhookSignatureHeader = "X-Hub-Signature"
+
+// WebhookSecrets is the source of secrets for validating webhooks;
+// *server.Server is one.
+type WebhookSecrets interface {
+	WebhookSecret(inst service.InstanceID, hook string) (string, error)
+}
+
+var ErrorWebhookSignature = &flux.BaseError{
+	Help: `The webhook request was not signed correctly
+
+Requests to webhook receivers must be signed using the secret for the
+webhook, by putting an HMAC of the body in the header ` + WebhookSignatureHeader + `,
+as "sha1=<hex digest>" or "sha256=<hex digest>".
+
+If you have lost the secret, you can create a new one by POSTing to
+/v6/webhooks/{hook}/secret.
+`,
+	Err: errors.New("invalid webhook signature"),
+}
+
+// ValidateWebhook wraps a webhook receiver so that it only sees
+// requests that were signed with the secret for the hook.
+func ValidateWebhook(secrets WebhookSecrets, hook string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, err := secrets.WebhookSecret(getInstanceID(r), hook)
+		if err != nil {
+			transport.ErrorResponse(w, r, err)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			transport.WriteError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if !validSignature(r.Header.Get(WebhookSignatureHeader), secret, body) {
+			transport.WriteError(w, r, http.StatusUnauthorized, ErrorWebhookSignature)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validSignature(signature, secret string, body []byte) bool {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	default:
+		return false
+	}
+	given, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/remote/rpc"
+	"github.com/weaveworks/flux/service"
+)
+
+type secretsMap map[string]string
+
+func (m secretsMap) WebhookSecret(_ service.InstanceID, hook string) (string, error) {
+	return m[hook], nil
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateWebhook(t *testing.T) {
+	const body = `{"ref":"refs/heads/master"}`
+	var received string
+	handler := ValidateWebhook(secretsMap{"git": "s3cret"}, "git", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+	}))
+
+	for _, c := range []struct {
+		name      string
+		signature string
+		code      int
+	}{
+		{"valid", sign("s3cret", body), http.StatusOK},
+		{"wrong secret", sign("guess", body), http.StatusUnauthorized},
+		{"unknown algorithm", "md5=abcd", http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	} {
+		received = ""
+		r, _ := http.NewRequest("POST", "/hook", strings.NewReader(body))
+		r.Header.Set(WebhookSignatureHeader, c.signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.code, w.Code)
+		}
+		if c.code == http.StatusOK && received != body {
+			t.Errorf("%s: expected handler to get body %q, got %q", c.name, body, received)
+		}
+		if c.code != http.StatusOK && received != "" {
+			t.Errorf("%s: expected handler not to be called", c.name)
+		}
+	}
+}
+
+type notifyService struct {
+	api.FluxService
+	notified []service.InstanceID
+}
+
+func (s *notifyService) SyncNotify(_ context.Context, inst service.InstanceID) error {
+	s.notified = append(s.notified, inst)
+	return nil
+}
+
+func TestGitWebhookRoute(t *testing.T) {
+	const body = `{"ref":"refs/heads/master"}`
+	svc := &notifyService{}
+	handler := NewHandler(svc, NewServiceRouter(), DefaultAuthenticator{}, secretsMap{"git": "s3cret"}, nil, rpc.DefaultTimeouts, log.NewNopLogger(), nil)
+
+	request := func(signature string) int {
+		r, _ := http.NewRequest("POST", "/v6/webhooks/git", strings.NewReader(body))
+		r.Header.Set(service.InstanceIDHeaderKey, "inst")
+		r.Header.Set(WebhookSignatureHeader, signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := request(sign("guess", body)); code != http.StatusUnauthorized {
+		t.Errorf("expected badly signed request to be refused, got %d", code)
+	}
+	if len(svc.notified) != 0 {
+		t.Fatalf("expected no sync notification for badly signed request, got %v", svc.notified)
+	}
+	if code := request(sign("s3cret", body)); code != http.StatusAccepted {
+		t.Errorf("expected signed request to be accepted, got %d", code)
+	}
+	if len(svc.notified) != 1 || svc.notified[0] != "inst" {
+		t.Errorf("expected one sync notification for instance \"inst\", got %v", svc.notified)
+	}
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/http/openapi"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/ssh"
+	"github.com/weaveworks/flux/update"
+)
+
+// APIOperations describes the request and response types of the
+// routes in NewAPIRouter, for generating an API specification. Keep
+// it up to date when adding routes!
+var APIOperations = map[string]openapi.Operation{
+	"ListServices": {
+		Summary:  "List the services running in the cluster, optionally only those in a namespace",
+		Query:    []string{"namespace"},
+		Response: []flux.ServiceStatus{},
+	},
+	"ListImages": {
+		Summary:  "List the images running and available for a service, or all services",
+		Query:    []string{"service"},
+		Response: []flux.ImageStatus{},
+	},
+	"UpdateImages": {
+		Summary:  "Start a job releasing an image to services",
+		Query:    []string{"service", "image", "kind", "exclude", "container", "user", "message"},
+		Response: job.ID(""),
+	},
+	"PlanRelease": {
+		Summary:  "Work out what releasing an image to services would do, without doing it (as a release result, with the change to each manifest)",
+		Query:    []string{"service", "image", "exclude", "container"},
+		Response: update.Result{},
+	},
+	"ServiceTopology": {
+		Summary:  "List the services defined in the repo, with the workloads each selects and the files they are defined in",
+		Response: []flux.ServiceTopology{},
+	},
+	"EvaluateImage": {
+		Summary:  "Report which services automation would release an image to, were it pushed",
+		Query:    []string{"image"},
+		Response: update.Result{},
+	},
+	"UpdatePolicies": {
+		Summary:  "Start a job adding or removing policies for services; with dryRun=true, report the changes instead of committing them",
+		Query:    []string{"user", "message", "dryRun"},
+		Request:  policy.Updates{},
+		Response: job.ID(""),
+	},
+	"ValidatePolicies": {
+		Summary: "Check policy updates without making them; an error says which policy is unknown, or which value can't be parsed",
+		Request: policy.Updates{},
+	},
+	"UpdateBatch": {
+		Summary:  "Start a job making an ordered list of image and policy updates in a single commit; if any step fails, none are committed",
+		Query:    []string{"user", "message"},
+		Request:  update.BatchSpec{},
+		Response: job.ID(""),
+	},
+	"AddManifests": {
+		Summary:  "Start a job writing the manifests for new resources to the repo, in the files the repo layout gives for them, and committing them",
+		Query:    []string{"user", "message"},
+		Request:  update.AddSpec{},
+		Response: job.ID(""),
+	},
+	"Rollback": {
+		Summary:  "Start a job putting services back to the images they had before their last release or, given a revision, to how they were defined at that commit",
+		Query:    []string{"user", "message"},
+		Request:  update.RollbackSpec{},
+		Response: job.ID(""),
+	},
+	"SyncNotify": {
+		Summary: "Ask the daemon to sync with the git repo",
+	},
+	"ResetGitToRemote": {
+		Summary:  "Discard the daemon's local commits and reset to the upstream branch, after it has diverged (e.g., been force-pushed); responds with the revision reset to",
+		Response: "",
+	},
+	"JobStatus": {
+		Summary:  "Get the status of a job",
+		Query:    []string{"id"},
+		Response: job.Status{},
+	},
+	"JobLog": {
+		Summary:  "Get the output captured while running a job",
+		Response: job.Log{},
+	},
+	"WatchJob": {
+		Summary: "Follow the status of a job, as a stream of server-sent events, until it finishes",
+	},
+	"WatchEvents": {
+		Summary: "Follow the events logged for the instance, as JSON messages over a websocket",
+	},
+	"SyncStatus": {
+		Summary:  "List the commits up to a ref that are yet to be applied to the cluster",
+		Query:    []string{"ref"},
+		Response: []string{},
+	},
+	"Export": {
+		Summary:  "Export the cluster's configuration, or with ref, the manifests in the git repo as of that commit or tag; ask for application/x-yaml to have it as YAML",
+		Query:    []string{"ref"},
+		Response: []byte{},
+	},
+	"GetPublicSSHKey": {
+		Summary:  "Get the public SSH key the daemon uses to access the git repo",
+		Response: ssh.PublicKey{},
+	},
+	"RegeneratePublicSSHKey": {
+		Summary: "Generate a new SSH key for the daemon",
+	},
+	"SyncHealth": {
+		Summary:  "Report how well the cluster has been kept in sync with the git repo, over a rolling window, and against the objective for sync freshness if there is one",
+		Response: remote.SyncHealth{},
+	},
+	"ListResources": {
+		Summary:  "List all the resources defined in the git repo, including those that aren't workloads (e.g., namespaces, cluster roles and custom resource definitions), and how each fared in the most recent sync",
+		Response: []flux.ResourceStatus{},
+	},
+	"SyncWait": {
+		Summary:  "Wait for a full sync of the git repo to the cluster, and report which resources were applied, unchanged, ignored or failed; if the report isn't done, ask again giving the time it was requested",
+		Request:  remote.SyncWaitRequest{},
+		Response: remote.SyncReport{},
+	},
+	"Check": {
+		Summary:  "Check that flux is set up and working",
+		Response: service.CheckReport{},
+	},
+	"Spec": {
+		Summary:  "Get this specification",
+		Response: openapi.Document{},
+	},
+}
+
+// SpecHandler serves an OpenAPI specification of the routes in the
+// router given, as described by ops.
+func SpecHandler(router *mux.Router, title string, ops map[string]openapi.Operation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := openapi.Generate(router, title, "v6", ops)
+		if err != nil {
+			ErrorResponse(w, r, err)
+			return
+		}
+		JSONResponse(w, r, doc)
+	}
+}
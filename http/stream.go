@@ -0,0 +1,211 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/job"
+)
+
+const EventStreamContentType = "text/event-stream"
+
+// Event types used in streams of job statuses
+const (
+	EventJobStatus = "status"
+	EventError     = "error"
+)
+
+// StreamJobStatus writes each status that watch sends, as a
+// server-sent event, until watch returns or the client goes away. An
+// error from watch before anything has been sent gets the usual error
+// response; after that, it's sent as an event.
+func StreamJobStatus(w http.ResponseWriter, r *http.Request, watch func(ctx context.Context, updates chan<- job.Status) error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	updates := make(chan job.Status)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watch(ctx, updates)
+	}()
+
+	var first job.Status
+	select {
+	case first = <-updates:
+	case err := <-errc:
+		if err != nil {
+			ErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, EventJobStatus, first)
+
+	for {
+		select {
+		case status := <-updates:
+			writeEvent(w, EventJobStatus, status)
+		case err := <-errc:
+			if err != nil {
+				_, outErr := errorResponseBody(err)
+				writeEvent(w, EventError, outErr)
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		event, body = EventError, []byte(fmt.Sprintf(`{"err":%q}`, err.Error()))
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ReadJobStatusEvents reads a stream as written by StreamJobStatus,
+// sending each status to updates. It returns when the stream ends, or
+// with the error if an error event is read. If the context is
+// cancelled, it returns nil, on the assumption that the stream was
+// closed because of that.
+func ReadJobStatusEvents(ctx context.Context, r io.Reader, updates chan<- job.Status) error {
+	scanner := bufio.NewScanner(r)
+	var event string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			switch event {
+			case EventJobStatus:
+				var status job.Status
+				if err := json.Unmarshal(data, &status); err != nil {
+					return errors.Wrap(err, "decoding job status")
+				}
+				select {
+				case updates <- status:
+				case <-ctx.Done():
+					return nil
+				}
+			case EventError:
+				var apiErr flux.BaseError
+				if err := json.Unmarshal(data, &apiErr); err != nil {
+					return errors.Wrap(err, "decoding error")
+				}
+				return &apiErr
+			}
+		}
+	}
+	select {
+	case <-ctx.Done():
+		// the stream was most likely closed from under us
+		return nil
+	default:
+		return scanner.Err()
+	}
+}
+
+// YAMLContentType is used for exports streamed as raw YAML, rather
+// than wrapped up in JSON.
+const YAMLContentType = "application/x-yaml"
+
+// AcceptsStream says whether the client asked for the content type
+// given, which is sent as a stream, in preference to JSON.
+func AcceptsStream(r *http.Request, contentType string) bool {
+	return negotiateContentType(r, []string{"application/json", contentType}) == contentType
+}
+
+// StreamResponse sends the response body as write writes it, rather
+// than collecting it first. An error from write before anything has
+// been written gets the usual error response; after that, the
+// response is cut short, so the client doesn't mistake what it got
+// for the whole thing.
+func StreamResponse(w http.ResponseWriter, r *http.Request, contentType string, write func(io.Writer) error) {
+	out := &streamWriter{
+		w:           w,
+		contentType: contentType,
+		gzip:        acceptsEncoding(r, "gzip"),
+	}
+	err := write(out)
+	switch {
+	case err != nil && !out.started:
+		ErrorResponse(w, r, err)
+	case err != nil:
+		panic(http.ErrAbortHandler)
+	default:
+		out.finish()
+	}
+}
+
+// YAMLResponse sends YAML that's already all there, to clients that
+// asked for it, and as JSON to the rest, in the same way as a route
+// that would otherwise use StreamResponse.
+func YAMLResponse(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !AcceptsStream(r, YAMLContentType) {
+		JSONResponse(w, r, body)
+		return
+	}
+	StreamResponse(w, r, YAMLContentType, func(out io.Writer) error {
+		_, err := out.Write(body)
+		return err
+	})
+}
+
+// streamWriter holds off on sending the response header until
+// there's something to send, so an error can still be reported
+// properly up to that point.
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	gzip        bool
+	started     bool
+	out         io.Writer
+}
+
+func (s *streamWriter) start() {
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.Header().Add("Vary", "Accept-Encoding")
+	s.out = s.w
+	if s.gzip {
+		s.w.Header().Set("Content-Encoding", "gzip")
+		s.out = gzip.NewWriter(s.w)
+	}
+	s.w.WriteHeader(http.StatusOK)
+	s.started = true
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.started {
+		s.start()
+	}
+	return s.out.Write(p)
+}
+
+func (s *streamWriter) finish() {
+	if !s.started {
+		s.start()
+	}
+	if gz, ok := s.out.(*gzip.Writer); ok {
+		gz.Close()
+	}
+}
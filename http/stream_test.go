@@ -0,0 +1,147 @@
+package http
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flux/job"
+)
+
+func TestStreamJobStatus(t *testing.T) {
+	statuses := []job.Status{
+		{StatusString: job.StatusQueued},
+		{StatusString: job.StatusRunning},
+		{StatusString: job.StatusFailed, Err: "boom"},
+	}
+	watchErr := errors.New("lost track of job")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		StreamJobStatus(w, r, func(_ context.Context, updates chan<- job.Status) error {
+			for _, s := range statuses {
+				updates <- s
+			}
+			return watchErr
+		})
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != EventStreamContentType {
+		t.Errorf("expected content type %q, got %q", EventStreamContentType, ct)
+	}
+
+	updates := make(chan job.Status, len(statuses))
+	err = ReadJobStatusEvents(context.Background(), resp.Body, updates)
+	if err == nil || err.Error() != watchErr.Error() {
+		t.Errorf("expected error %q from stream, got %v", watchErr, err)
+	}
+	close(updates)
+	var got []job.Status
+	for s := range updates {
+		got = append(got, s)
+	}
+	if len(got) != len(statuses) {
+		t.Fatalf("expected %d statuses, got %d", len(statuses), len(got))
+	}
+	for i := range statuses {
+		if got[i].StatusString != statuses[i].StatusString || got[i].Err != statuses[i].Err {
+			t.Errorf("expected %+v, got %+v", statuses[i], got[i])
+		}
+	}
+}
+
+func TestStreamJobStatus_ErrorFirst(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/v6/jobs/foo/watch", nil)
+	StreamJobStatus(w, r, func(_ context.Context, updates chan<- job.Status) error {
+		return errors.New("unknown job")
+	})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected error response before any events, got status %d", w.Code)
+	}
+}
+
+func TestStreamResponse(t *testing.T) {
+	writeErr := errors.New("ran out of cluster")
+	for _, c := range []struct {
+		name   string
+		chunks []string
+		err    error
+		status int
+		body   string
+		cutOff bool
+	}{
+		{name: "ok", chunks: []string{"---\n", "kind: Namespace\n"}, status: http.StatusOK, body: "---\nkind: Namespace\n"},
+		{name: "empty", status: http.StatusOK},
+		{name: "error first", err: writeErr, status: http.StatusInternalServerError},
+		{name: "error part way", chunks: []string{"---\n"}, err: writeErr, status: http.StatusOK, cutOff: true},
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			StreamResponse(w, r, YAMLContentType, func(out io.Writer) error {
+				for _, chunk := range c.chunks {
+					io.WriteString(out, chunk)
+					if gz, ok := out.(*streamWriter).out.(*gzip.Writer); ok {
+						gz.Flush()
+					}
+					w.(http.Flusher).Flush()
+				}
+				return c.err
+			})
+		}))
+
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, resp.StatusCode)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		ts.Close()
+		if c.cutOff {
+			if err == nil {
+				t.Errorf("%s: expected error reading cut off response", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		}
+		if c.status == http.StatusOK {
+			if ct := resp.Header.Get("Content-Type"); ct != YAMLContentType {
+				t.Errorf("%s: expected content type %q, got %q", c.name, YAMLContentType, ct)
+			}
+			if string(body) != c.body {
+				t.Errorf("%s: expected body %q, got %q", c.name, c.body, body)
+			}
+		}
+	}
+}
+
+func TestAcceptsStream(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                 false,
+		"application/json": false,
+		YAMLContentType:    true,
+		YAMLContentType + ", application/json;q=0.5": true,
+	} {
+		r, _ := http.NewRequest("GET", "http://example.com/", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		if got := AcceptsStream(r, YAMLContentType); got != expected {
+			t.Errorf("Accept %q: expected %v, got %v", accept, expected, got)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -31,15 +33,31 @@ func NewAPIRouter() *mux.Router {
 
 	r.NewRoute().Name("ListServices").Methods("GET").Path("/v6/services").Queries("namespace", "{namespace}") // optional namespace!
 	r.NewRoute().Name("ListImages").Methods("GET").Path("/v6/images").Queries("service", "{service}")
+	r.NewRoute().Name("ServiceTopology").Methods("GET").Path("/v6/topology")
+	r.NewRoute().Name("EvaluateImage").Methods("GET").Path("/v6/evaluate-image").Queries("image", "{image}")
 
 	r.NewRoute().Name("UpdateImages").Methods("POST").Path("/v6/update-images").Queries("service", "{service}", "image", "{image}", "kind", "{kind}")
+	r.NewRoute().Name("PlanRelease").Methods("POST").Path("/v6/release-plan").Queries("service", "{service}", "image", "{image}")
 	r.NewRoute().Name("UpdatePolicies").Methods("PATCH").Path("/v6/policies")
+	r.NewRoute().Name("ValidatePolicies").Methods("POST").Path("/v6/policies/validate")
+	r.NewRoute().Name("UpdateBatch").Methods("POST").Path("/v6/update-batch")
+	r.NewRoute().Name("AddManifests").Methods("POST").Path("/v6/manifests")
+	r.NewRoute().Name("Rollback").Methods("POST").Path("/v6/rollback")
 	r.NewRoute().Name("SyncNotify").Methods("POST").Path("/v6/sync")
+	r.NewRoute().Name("ResetGitToRemote").Methods("POST").Path("/v6/git/reset")
 	r.NewRoute().Name("JobStatus").Methods("GET").Path("/v6/jobs").Queries("id", "{id}")
+	r.NewRoute().Name("JobLog").Methods("GET").Path("/v6/jobs/{id}/log")
+	r.NewRoute().Name("WatchJob").Methods("GET").Path("/v6/jobs/{id}/watch")
+	r.NewRoute().Name("WatchEvents").Methods("GET").Path("/v6/events/stream")
 	r.NewRoute().Name("SyncStatus").Methods("GET").Path("/v6/sync").Queries("ref", "{ref}")
+	r.NewRoute().Name("SyncHealth").Methods("GET").Path("/v6/sync/health")
+	r.NewRoute().Name("ListResources").Methods("GET").Path("/v6/resources")
+	r.NewRoute().Name("SyncWait").Methods("POST").Path("/v6/sync/wait")
 	r.NewRoute().Name("Export").Methods("HEAD", "GET").Path("/v6/export")
 	r.NewRoute().Name("GetPublicSSHKey").Methods("GET").Path("/v6/identity.pub")
 	r.NewRoute().Name("RegeneratePublicSSHKey").Methods("POST").Path("/v6/identity.pub")
+	r.NewRoute().Name("Check").Methods("GET").Path("/v6/check")
+	r.NewRoute().Name("Spec").Methods("GET").Path("/v6/spec")
 
 	return r // TODO 404 though?
 }
@@ -55,32 +73,88 @@ func NewUpstreamRouter() *mux.Router {
 	return r
 }
 
+// AdminRoutes are for moving instances between services, and
+// injecting faults to test alerting.
+func AdminRoutes(r *mux.Router) {
+	r.NewRoute().Name("ExportInstance").Methods("GET").Path("/v6/admin/instance")
+	r.NewRoute().Name("ImportInstance").Methods("POST").Path("/v6/admin/instance")
+	r.NewRoute().Name("MigrateInstance").Methods("POST").Path("/v6/admin/migrate")
+	r.NewRoute().Name("InjectFault").Methods("POST").Path("/v6/admin/faults")
+}
+
+func NewAdminRouter() *mux.Router {
+	r := mux.NewRouter()
+	AdminRoutes(r)
+	return r
+}
+
+var pathVarRE = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// MakeURL constructs the URL for the named route, with the
+// parameters given as pairs of name and value.
 func MakeURL(endpoint string, router *mux.Router, routeName string, urlParams ...string) (*url.URL, error) {
 	if len(urlParams)%2 != 0 {
 		panic("urlParams must be even!")
 	}
+	v := url.Values{}
+	for i := 0; i < len(urlParams); i += 2 {
+		v.Add(urlParams[i], urlParams[i+1])
+	}
+	return MakeURLWithParams(endpoint, router, routeName, v)
+}
+
+// MakeURLWithParams constructs the URL for the named route. The
+// parameters are either url.Values, or a parameters struct as
+// described for EncodeParams.
+func MakeURLWithParams(endpoint string, router *mux.Router, routeName string, params interface{}) (*url.URL, error) {
+	v, ok := params.(url.Values)
+	if !ok {
+		var err error
+		if v, err = EncodeParams(params); err != nil {
+			return nil, errors.Wrapf(err, "parameters for %s", routeName)
+		}
+	}
 
 	endpointURL, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, errors.Wrapf(err, "parsing endpoint %s", endpoint)
 	}
 
-	routeURL, err := router.Get(routeName).URL()
+	route := router.Get(routeName)
+	tmpl, err := route.GetPathTemplate()
 	if err != nil {
 		return nil, errors.Wrapf(err, "retrieving route path %s", routeName)
 	}
 
-	v := url.Values{}
-	for i := 0; i < len(urlParams); i += 2 {
-		v.Add(urlParams[i], urlParams[i+1])
+	// Parameters that appear in the path go there; the rest go in
+	// the query.
+	var pathParams []string
+	query := url.Values{}
+	for name, values := range v {
+		query[name] = values
+	}
+	for _, m := range pathVarRE.FindAllStringSubmatch(tmpl, -1) {
+		name := m[1]
+		pathParams = append(pathParams, name, query.Get(name))
+		delete(query, name)
+	}
+
+	routeURL, err := route.URLPath(pathParams...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "retrieving route path %s", routeName)
 	}
 
 	endpointURL.Path = path.Join(endpointURL.Path, routeURL.Path)
-	endpointURL.RawQuery = v.Encode()
+	endpointURL.RawQuery = query.Encode()
 	return endpointURL, nil
 }
 
 func WriteError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	// Errors that can explain themselves to users are sent in the
+	// form that includes the explanation.
+	if helpful, ok := errors.Cause(err).(flux.HelpfulError); ok {
+		err = helpful.Base()
+	}
 	// An Accept header with "application/json" is sent by clients
 	// understanding how to decode JSON errors. Older clients don't
 	// send an Accept header, so we just give them the error text.
@@ -115,19 +189,43 @@ func WriteError(w http.ResponseWriter, r *http.Request, code int, err error) {
 	fmt.Fprint(w, err.Error())
 }
 
+// Responses smaller than this aren't worth the bother of compressing.
+const gzipMinSize = 1024
+
+// JSONResponse writes the result as JSON, gzipped if it's big enough
+// to benefit and the client says it can take it.
 func JSONResponse(w http.ResponseWriter, r *http.Request, result interface{}) {
 	body, err := json.Marshal(result)
 	if err != nil {
 		ErrorResponse(w, r, err)
 		return
 	}
+	writeJSON(w, r, body)
+}
 
+func writeJSON(w http.ResponseWriter, r *http.Request, body []byte) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(body) >= gzipMinSize && acceptsEncoding(r, "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		gz := gzip.NewWriter(w)
+		gz.Write(body)
+		gz.Close()
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
 }
 
 func ErrorResponse(w http.ResponseWriter, r *http.Request, apiError error) {
+	code, outErr := errorResponseBody(apiError)
+	WriteError(w, r, code, outErr)
+}
+
+// errorResponseBody decides the status code and error to send back
+// for an error from the API.
+func errorResponseBody(apiError error) (int, *flux.BaseError) {
 	var outErr *flux.BaseError
 	var code int
 	err := errors.Cause(apiError)
@@ -145,6 +243,5 @@ func ErrorResponse(w http.ResponseWriter, r *http.Request, apiError error) {
 		code = http.StatusInternalServerError
 		outErr = flux.CoverAllError(apiError)
 	}
-
-	WriteError(w, r, code, outErr)
+	return code, outErr
 }
@@ -9,7 +9,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 
-	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
 )
 
 type DialErr struct {
@@ -24,8 +24,10 @@ func (de DialErr) Error() string {
 	return "connecting to websocket (unknown error)"
 }
 
-// Dial initiates a new websocket connection.
-func Dial(client *http.Client, ua string, token flux.Token, u *url.URL) (Websocket, error) {
+// Dial initiates a new websocket connection. Any protocols given are
+// offered to the server, most preferred first; see
+// Websocket.Subprotocol for which was chosen.
+func Dial(client *http.Client, ua string, auth api.Authenticator, u *url.URL, protocols ...string) (Websocket, error) {
 	// Build the http request
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
@@ -36,10 +38,12 @@ func Dial(client *http.Client, ua string, token flux.Token, u *url.URL) (Websock
 	req.Header.Set("User-Agent", ua)
 
 	// Add authentication if provided
-	token.Set(req)
+	auth.Authenticate(req)
 
 	// Use http client to do the http request
-	conn, resp, err := dialer(client).Dial(u.String(), req.Header)
+	d := dialer(client)
+	d.Subprotocols = protocols
+	conn, resp, err := d.Dial(u.String(), req.Header)
 	if err != nil {
 		if resp != nil {
 			err = &DialErr{u, resp}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/weaveworks/flux"
+)
+
+// Types of control message
+const (
+	// Sent by a client to give the server a new token, e.g., because
+	// the old one has been rotated out
+	ControlRefreshToken = "refresh-token"
+	// Sent by the server in reply to a token refresh; Error is set
+	// if the token wasn't accepted
+	ControlTokenRefreshed = "token-refreshed"
+)
+
+// ControlMessage is sent in-band, alongside the byte stream. Control
+// messages go in text frames, while the byte stream uses binary
+// frames, so they don't get mixed up; they're only ever seen by the
+// control handler (if there is one).
+type ControlMessage struct {
+	Type  string     `json:"type"`
+	Token flux.Token `json:"token,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// ControlHandler is called with each control message received. It
+// is called from Read, so it shouldn't block for long.
+type ControlHandler func(ControlMessage)
+
+func (p *pingingWebsocket) SendControl(m ControlMessage) error {
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+	if err := p.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+	return p.conn.WriteMessage(websocket.TextMessage, bytes)
+}
+
+func (p *pingingWebsocket) OnControl(h ControlHandler) {
+	p.controlLock.Lock()
+	p.control = h
+	p.controlLock.Unlock()
+}
+
+// handleControl decodes a control message from a text frame, and
+// gives it to the handler. Messages that can't be decoded, or that
+// arrive when there's no handler, are dropped.
+func (p *pingingWebsocket) handleControl(bytes []byte) {
+	p.controlLock.Lock()
+	h := p.control
+	p.controlLock.Unlock()
+	if h == nil {
+		return
+	}
+	var m ControlMessage
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		return
+	}
+	h(m)
+}
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"io"
+	"io/ioutil"
 	"sync"
 	"time"
 
@@ -31,6 +32,9 @@ type pingingWebsocket struct {
 	writeLock sync.Mutex
 	reader    io.Reader
 	conn      *websocket.Conn
+
+	controlLock sync.Mutex
+	control     ControlHandler
 }
 
 // Ping adds a periodic ping to a websocket connection.
@@ -42,6 +46,10 @@ func Ping(c *websocket.Conn) Websocket {
 	return p
 }
 
+func (p *pingingWebsocket) Subprotocol() string {
+	return p.conn.Subprotocol()
+}
+
 func (p *pingingWebsocket) ping() {
 	p.writeLock.Lock()
 	defer p.writeLock.Unlock()
@@ -64,13 +72,26 @@ func (p *pingingWebsocket) Read(b []byte) (int, error) {
 	for p.reader == nil {
 		msgType, r, err := p.conn.NextReader()
 		if err != nil {
+			// Once a read has failed -- e.g., because no pong arrived
+			// in time -- the connection is no good. Close it, so that
+			// writes fail too, rather than disappearing into a
+			// half-open connection.
+			p.conn.Close()
 			if IsExpectedWSCloseError(err) {
 				return 0, io.EOF
 			}
 			return 0, err
 		}
+		if msgType == websocket.TextMessage {
+			bytes, err := ioutil.ReadAll(r)
+			if err != nil {
+				return 0, err
+			}
+			p.handleControl(bytes)
+			p.conn.SetReadDeadline(time.Now().Add(pongWait))
+			continue
+		}
 		if msgType != websocket.BinaryMessage {
-			// Ignore non-binary messages for now.
 			continue
 		}
 		p.reader = r
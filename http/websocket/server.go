@@ -10,6 +10,13 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// Subprotocols gives the protocols offered by the client, most
+// preferred first. To accept one, give it as the
+// Sec-Websocket-Protocol header in the response to Upgrade.
+func Subprotocols(r *http.Request) []string {
+	return websocket.Subprotocols(r)
+}
+
 // Upgrade upgrades the HTTP server connection to the WebSocket protocol.
 func Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (Websocket, error) {
 	wsConn, err := upgrader.Upgrade(w, r, responseHeader)
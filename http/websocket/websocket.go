@@ -13,6 +13,15 @@ type Websocket interface {
 	io.Reader
 	io.Writer
 	Close() error
+	// SendControl sends a message in-band, without disturbing the
+	// byte stream.
+	SendControl(ControlMessage) error
+	// OnControl sets the handler for control messages received
+	// from the other end.
+	OnControl(ControlHandler)
+	// Subprotocol is the protocol agreed on for the byte stream when
+	// connecting, or "" if there wasn't one.
+	Subprotocol() string
 }
 
 // IsExpectedWSCloseError returns boolean indicating whether the error is a
@@ -3,6 +3,7 @@ package websocket
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -83,3 +84,97 @@ func TestByteStream(t *testing.T) {
 		t.Fatalf("did not collect message as expected, got %s", buf.String())
 	}
 }
+
+func TestControlMessages(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	refreshed := make(chan flux.Token, 1)
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := Upgrade(w, r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws.OnControl(func(m ControlMessage) {
+			if m.Type == ControlRefreshToken {
+				refreshed <- m.Token
+				ws.SendControl(ControlMessage{Type: ControlTokenRefreshed})
+			}
+		})
+		if _, err := io.Copy(buf, ws); err != nil {
+			t.Fatal(err)
+		}
+		wg.Done()
+	})
+
+	srv := httptest.NewServer(upgrade)
+
+	url, _ := url.Parse(srv.URL)
+	url.Scheme = "ws"
+
+	ws, err := Dial(http.DefaultClient, "fluxd/test", flux.Token("old"), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acked := make(chan ControlMessage, 1)
+	ws.OnControl(func(m ControlMessage) {
+		acked <- m
+	})
+	// Control messages are only seen while reading
+	go io.Copy(ioutil.Discard, ws)
+
+	if _, err := ws.Write([]byte("before ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SendControl(ControlMessage{Type: ControlRefreshToken, Token: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Write([]byte("after")); err != nil {
+		t.Fatal(err)
+	}
+
+	if tok := <-refreshed; tok != "new" {
+		t.Errorf("expected server to get new token, got %q", tok)
+	}
+	if m := <-acked; m.Type != ControlTokenRefreshed || m.Error != "" {
+		t.Errorf("expected acknowledgement of token, got %+v", m)
+	}
+
+	if err := ws.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	wg.Wait()
+	if buf.String() != "before after" {
+		t.Fatalf("expected byte stream to be undisturbed by control messages, got %q", buf.String())
+	}
+}
+
+func TestSubprotocol(t *testing.T) {
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered := Subprotocols(r)
+		if len(offered) != 2 || offered[0] != "proto.v2" {
+			t.Errorf("expected protocols offered in order, got %v", offered)
+		}
+		ws, err := Upgrade(w, r, http.Header{"Sec-Websocket-Protocol": []string{"proto.v1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, ws)
+	})
+
+	srv := httptest.NewServer(upgrade)
+	defer srv.Close()
+
+	url, _ := url.Parse(srv.URL)
+	url.Scheme = "ws"
+
+	ws, err := Dial(http.DefaultClient, "fluxd/test", flux.Token(""), url, "proto.v2", "proto.v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if p := ws.Subprotocol(); p != "proto.v1" {
+		t.Errorf("expected protocol chosen by server, got %q", p)
+	}
+}
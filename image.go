@@ -17,14 +17,18 @@ const (
 var (
 	ErrInvalidImageID   = errors.New("invalid image ID")
 	ErrBlankImageID     = errors.Wrap(ErrInvalidImageID, "blank image name")
-	ErrMalformedImageID = errors.Wrap(ErrInvalidImageID, `expected image name as either <image>:<tag> or just <image>`)
+	ErrMalformedImageID = errors.Wrap(ErrInvalidImageID, `expected image name as [host[:port]/][namespace/]image[:tag][@digest]`)
 )
 
 // ImageID is a fully qualified name that refers to a particular Image.
-// It is in the format: host[:port]/Namespace/Image[:tag]
-// Here, we refer to the "name" == Namespace/Image
+// It is in the format: host[:port]/Namespace/Image[:tag][@digest]
+// Here, we refer to the "name" == Namespace/Image. Images from
+// registries other than Docker Hub may have no namespace.
 type ImageID struct {
 	Host, Namespace, Image, Tag string
+	// Digest pins the image to particular content, e.g.,
+	// "sha256:<hex>"; it's usually empty.
+	Digest string
 }
 
 func ParseImageID(s string) (ImageID, error) {
@@ -32,30 +36,44 @@ func ParseImageID(s string) (ImageID, error) {
 		return ImageID{}, ErrBlankImageID
 	}
 	var img ImageID
-	parts := strings.Split(s, ":")
-	switch len(parts) {
-	case 0:
-		return ImageID{}, ErrMalformedImageID
-	case 1:
+	if i := strings.Index(s, "@"); i >= 0 {
+		img.Digest = s[i+1:]
+		s = s[:i]
+		if !strings.Contains(img.Digest, ":") {
+			return ImageID{}, ErrMalformedImageID
+		}
+	}
+	// A colon after the last slash introduces the tag; one before
+	// it must be a port on the host.
+	if i := strings.LastIndex(s, ":"); i > strings.LastIndex(s, "/") {
+		img.Tag = s[i+1:]
+		s = s[:i]
+	} else if img.Digest == "" {
 		img.Tag = "latest"
-	case 2:
-		img.Tag = parts[1]
-		s = parts[0]
-	default:
-		return ImageID{}, ErrMalformedImageID
 	}
 	if s == "" {
 		return ImageID{}, ErrBlankImageID
 	}
-	parts = strings.Split(s, "/")
+
+	parts := strings.Split(s, "/")
+	for i, part := range parts {
+		// Only the host may have a colon in it
+		if part == "" || (i > 0 || len(parts) == 1) && strings.Contains(part, ":") {
+			return ImageID{}, ErrMalformedImageID
+		}
+	}
 	switch len(parts) {
 	case 1:
 		img.Host = dockerHubHost
 		img.Namespace = dockerHubLibrary
 		img.Image = parts[0]
 	case 2:
-		img.Host = dockerHubHost
-		img.Namespace = parts[0]
+		if looksLikeHost(parts[0]) {
+			img.Host = parts[0]
+		} else {
+			img.Host = dockerHubHost
+			img.Namespace = parts[0]
+		}
 		img.Image = parts[1]
 	case 3:
 		img.Host = parts[0]
@@ -64,19 +82,50 @@ func ParseImageID(s string) (ImageID, error) {
 	default:
 		return ImageID{}, ErrMalformedImageID
 	}
+
+	// Docker Hub goes by a few names, and its official images are
+	// in the library namespace whether it's mentioned or not.
+	if dockerHubAliases[img.Host] {
+		img.Host = dockerHubHost
+		if img.Namespace == "" {
+			img.Namespace = dockerHubLibrary
+		}
+	}
 	return img, nil
 }
 
+var dockerHubAliases = map[string]bool{
+	dockerHubHost:          true,
+	"docker.io":            true,
+	"registry-1.docker.io": true,
+}
+
+// The first part of an image name is taken to be a registry host
+// (rather than a namespace on Docker Hub) if it has a domain or a
+// port, or is localhost.
+func looksLikeHost(s string) bool {
+	return strings.ContainsAny(s, ".:") || s == "localhost"
+}
+
 // Fully qualified name
 func (i ImageID) String() string {
 	if i.Image == "" {
 		return "" // Doesn't make sense to return anything if it doesn't even have an image
 	}
-	var ta string
+	return i.Repository() + i.suffix()
+}
+
+// suffix is the tag and digest parts of the name, with their
+// separators, if they're present.
+func (i ImageID) suffix() string {
+	var s string
 	if i.Tag != "" {
-		ta = fmt.Sprintf(":%s", i.Tag)
+		s = ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
 	}
-	return fmt.Sprintf("%s%s", i.Repository(), ta)
+	return s
 }
 
 // ImageID is serialized/deserialized as a string
@@ -104,19 +153,34 @@ func (i ImageID) Repository() string {
 
 // HostNamespaceImage includes all parts of the image, even if it is from dockerhub.
 func (i ImageID) HostNamespaceImage() string {
-	return fmt.Sprintf("%s/%s/%s", i.Host, i.Namespace, i.Image)
+	if i.Host == "" {
+		return i.NamespaceImage()
+	}
+	return fmt.Sprintf("%s/%s", i.Host, i.NamespaceImage())
 }
 
 func (i ImageID) NamespaceImage() string {
+	if i.Namespace == "" {
+		return i.Image
+	}
 	return fmt.Sprintf("%s/%s", i.Namespace, i.Image)
 }
 
 func (i ImageID) FullID() string {
-	return fmt.Sprintf("%s/%s/%s:%s", i.Host, i.Namespace, i.Image, i.Tag)
+	return i.HostNamespaceImage() + i.suffix()
 }
 
 func (i ImageID) Components() (host, repo, tag string) {
-	return i.Host, fmt.Sprintf("%s/%s", i.Namespace, i.Image), i.Tag
+	return i.Host, i.NamespaceImage(), i.Tag
+}
+
+// Reference is what to ask the registry for to get this particular
+// image: the digest if there is one, otherwise the tag.
+func (i ImageID) Reference() string {
+	if i.Digest != "" {
+		return i.Digest
+	}
+	return i.Tag
 }
 
 // WithNewTag makes a new copy of an ImageID with a new tag
@@ -128,10 +192,16 @@ func (i ImageID) WithNewTag(t string) ImageID {
 }
 
 // Image can't really be a primitive string only, because we need to also
-// record information about its creation time. (maybe more in the future)
+// record information about its creation time, and the labels it was
+// built with.
 type Image struct {
 	ID        ImageID
 	CreatedAt time.Time
+	Labels    map[string]string
+	// ContentID identifies what's in the image, whatever it's tagged
+	// as; two tags with the same ContentID are the same image. It's
+	// empty if the registry didn't say.
+	ContentID string
 }
 
 func (im Image) MarshalJSON() ([]byte, error) {
@@ -141,18 +211,24 @@ func (im Image) MarshalJSON() ([]byte, error) {
 	}
 	encode := struct {
 		ID        ImageID
-		CreatedAt string `json:",omitempty"`
-	}{im.ID, t}
+		CreatedAt string            `json:",omitempty"`
+		Labels    map[string]string `json:",omitempty"`
+		ContentID string            `json:",omitempty"`
+	}{im.ID, t, im.Labels, im.ContentID}
 	return json.Marshal(encode)
 }
 
 func (im *Image) UnmarshalJSON(b []byte) error {
 	unencode := struct {
 		ID        ImageID
-		CreatedAt string `json:",omitempty"`
+		CreatedAt string            `json:",omitempty"`
+		Labels    map[string]string `json:",omitempty"`
+		ContentID string            `json:",omitempty"`
 	}{}
 	json.Unmarshal(b, &unencode)
 	im.ID = unencode.ID
+	im.Labels = unencode.Labels
+	im.ContentID = unencode.ContentID
 	if unencode.CreatedAt == "" {
 		im.CreatedAt = time.Time{}
 	} else {
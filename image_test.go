@@ -26,6 +26,11 @@ func TestImageID_ParseImageID(t *testing.T) {
 		{"quay.io/library/alpine", "quay.io/library/alpine:latest"},
 		{"quay.io/library/alpine:latest", "quay.io/library/alpine:latest"},
 		{"quay.io/library/alpine:mytag", "quay.io/library/alpine:mytag"},
+		{"localhost:5000/alpine", "localhost:5000/alpine:latest"},
+		{"registry:5000/repo/image:v1", "registry:5000/repo/image:v1"},
+		{"docker.io/alpine:3.5", "alpine:3.5"},
+		{"registry:5000/repo/image@sha256:abc123", "registry:5000/repo/image@sha256:abc123"},
+		{"alpine:3.5@sha256:abc123", "alpine:3.5@sha256:abc123"},
 	} {
 		i, err := ParseImageID(x.test)
 		if err != nil {
@@ -46,6 +51,9 @@ func TestImageID_ParseImageIDErrorCases(t *testing.T) {
 		{"alpine::"},
 		{"alpine:invalid:"},
 		{"/too/many/slashes/"},
+		{"alpine@"},
+		{"alpine@abc123"},
+		{"repo/ima:ge/foo"},
 	} {
 		_, err := ParseImageID(x.test)
 		if err == nil {
@@ -127,3 +135,20 @@ func TestImage_OrderByCreationDate(t *testing.T) {
 		}
 	}
 }
+
+func TestImageID_HostWithPort(t *testing.T) {
+	i, err := ParseImageID("localhost:5000/helloworld@sha256:abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ImageID{Host: "localhost:5000", Image: "helloworld", Digest: "sha256:abc123"}
+	if i != expected {
+		t.Errorf("expected %#v, got %#v", expected, i)
+	}
+	if i.NamespaceImage() != "helloworld" {
+		t.Errorf("expected repository path without namespace, got %q", i.NamespaceImage())
+	}
+	if i.Reference() != i.Digest {
+		t.Errorf("expected digest to be used as the reference, got %q", i.Reference())
+	}
+}
@@ -0,0 +1,104 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoData is returned when a query gives no value, e.g., because
+// there are no samples yet for the series it selects.
+var ErrNoData = errors.New("query gave no data")
+
+// Client runs queries against the Prometheus HTTP API.
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient makes a client for the Prometheus at the base URL given,
+// e.g., "http://prometheus.monitoring:9090".
+func NewClient(baseURL string) *Client {
+	return &Client{
+		url:  strings.TrimSuffix(baseURL, "/"),
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// sample is a value as Prometheus gives it: the time, then the value
+// as a string.
+type sample [2]interface{}
+
+func (s sample) value() (float64, error) {
+	str, ok := s[1].(string)
+	if !ok {
+		return 0, errors.Errorf("unexpected sample value %v", s[1])
+	}
+	return strconv.ParseFloat(str, 64)
+}
+
+// Query runs an instant query at the time given, which must give a
+// single value: either a scalar, or a vector with one element.
+func (c *Client) Query(ctx context.Context, query string, at time.Time) (float64, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatFloat(float64(at.UnixNano())/1e9, 'f', 3, 64))
+	req, err := http.NewRequest("GET", c.url+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "constructing request")
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err, "querying Prometheus")
+	}
+	defer resp.Body.Close()
+
+	var res queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, errors.Wrapf(err, "decoding response from Prometheus (HTTP status %s)", resp.Status)
+	}
+	if res.Status != "success" {
+		return 0, errors.Errorf("Prometheus answered %s: %s", resp.Status, res.Error)
+	}
+
+	switch res.Data.ResultType {
+	case "scalar":
+		var s sample
+		if err := json.Unmarshal(res.Data.Result, &s); err != nil {
+			return 0, errors.Wrap(err, "decoding scalar")
+		}
+		return s.value()
+	case "vector":
+		var vector []struct {
+			Value sample `json:"value"`
+		}
+		if err := json.Unmarshal(res.Data.Result, &vector); err != nil {
+			return 0, errors.Wrap(err, "decoding vector")
+		}
+		switch len(vector) {
+		case 0:
+			return 0, ErrNoData
+		case 1:
+			return vector[0].Value.value()
+		default:
+			return 0, errors.Errorf("query gave %d series; it should give one (e.g., by aggregating with sum)", len(vector))
+		}
+	default:
+		return 0, errors.Errorf("query gave a %s; it should give a scalar or a vector with one element", res.Data.ResultType)
+	}
+}
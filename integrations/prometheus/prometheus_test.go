@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	responses := map[string]string{
+		"vector":  `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1500000000,"0.25"]}]}}`,
+		"scalar":  `{"status":"success","data":{"resultType":"scalar","result":[1500000000,"3"]}}`,
+		"empty":   `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		"several": `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"a":"1"},"value":[1500000000,"1"]},{"metric":{"a":"2"},"value":[1500000000,"2"]}]}}`,
+		"matrix":  `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+		"bad":     `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	}
+	var gotTime string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		gotTime = r.URL.Query().Get("time")
+		body, ok := responses[r.URL.Query().Get("query")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("query") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL + "/")
+	ctx := context.Background()
+	at := time.Unix(1500000000, 0)
+
+	if v, err := c.Query(ctx, "vector", at); err != nil || v != 0.25 {
+		t.Errorf("expected 0.25, got %v (error %v)", v, err)
+	}
+	if gotTime != "1500000000.000" {
+		t.Errorf("expected query at the time given, got %q", gotTime)
+	}
+	if v, err := c.Query(ctx, "scalar", at); err != nil || v != 3 {
+		t.Errorf("expected 3, got %v (error %v)", v, err)
+	}
+	if _, err := c.Query(ctx, "empty", at); err != ErrNoData {
+		t.Errorf("expected ErrNoData, got %v", err)
+	}
+	for _, q := range []string{"several", "matrix", "bad"} {
+		if _, err := c.Query(ctx, q, at); err == nil {
+			t.Errorf("expected an error from query %q", q)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package job
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/update"
+)
+
+// Executor does the work described by an update spec, on behalf of a
+// job. The daemon looks after the job's status, log and events
+// either way; an executor only decides where the work is done, e.g.,
+// in the daemon's own process, or in a worker elsewhere.
+type Executor interface {
+	Execute(ctx context.Context, id ID, spec update.Spec, logger log.Logger) (history.CommitEventMetadata, error)
+}
+
+// ---
+
+var ErrUnexpectedReport = flux.Missing{&flux.BaseError{
+	Help: `No job is waiting for this report
+
+The job may already have finished or timed out, or the credentials
+given with the report may be wrong.`,
+	Err: errors.New("no job is expecting this report"),
+}}
+
+// Reports connects executors that run jobs elsewhere with the
+// results those jobs report back. An executor says it is expecting a
+// report for a job, and the token that has to come with it; whatever
+// receives the report (e.g., an API handler) passes it on.
+type Reports struct {
+	mu       sync.Mutex
+	expected map[ID]expectedReport
+}
+
+type expectedReport struct {
+	token  string
+	status chan Status
+}
+
+// Expect registers interest in a report for the job given, which
+// must come with the token given. The channel returned receives the
+// report, if it arrives before Forget is called.
+func (r *Reports) Expect(id ID, token string) <-chan Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.expected == nil {
+		r.expected = map[ID]expectedReport{}
+	}
+	c := make(chan Status, 1)
+	r.expected[id] = expectedReport{token: token, status: c}
+	return c
+}
+
+// Forget stops expecting a report for the job given.
+func (r *Reports) Forget(id ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.expected, id)
+}
+
+// Report passes on the report for a job. Only the first report is
+// accepted; after that, or if the token doesn't match, it's an error.
+func (r *Reports) Report(id ID, token string, status Status) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exp, ok := r.expected[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(exp.token)) != 1 {
+		return ErrUnexpectedReport
+	}
+	delete(r.expected, id)
+	exp.status <- status
+	return nil
+}
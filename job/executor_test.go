@@ -0,0 +1,39 @@
+package job
+
+import (
+	"testing"
+)
+
+func TestReports(t *testing.T) {
+	var reports Reports
+	status := Status{StatusString: StatusSucceeded}
+
+	if err := reports.Report("job-1", "token", status); err == nil {
+		t.Error("expected error for report nobody was expecting")
+	}
+
+	c := reports.Expect("job-1", "token")
+	if err := reports.Report("job-1", "wrong", status); err == nil {
+		t.Error("expected error for report with the wrong token")
+	}
+	if err := reports.Report("job-1", "token", status); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-c:
+		if got.StatusString != StatusSucceeded {
+			t.Errorf("expected status %q, got %q", StatusSucceeded, got.StatusString)
+		}
+	default:
+		t.Fatal("expected report to have been passed on")
+	}
+	if err := reports.Report("job-1", "token", status); err == nil {
+		t.Error("expected error for a second report")
+	}
+
+	reports.Expect("job-2", "token")
+	reports.Forget("job-2")
+	if err := reports.Report("job-2", "token", status); err == nil {
+		t.Error("expected error for report after it was forgotten")
+	}
+}
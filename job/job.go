@@ -26,6 +26,12 @@ const (
 	StatusSucceeded StatusString = "succeeded"
 )
 
+// Terminal is true of the states a job doesn't leave; i.e., once it
+// has finished, one way or the other.
+func (s StatusString) Terminal() bool {
+	return s == StatusFailed || s == StatusSucceeded
+}
+
 // Status holds the possible states of a job; either,
 //  1. queued or otherwise pending
 //  2. succeeded with a job-specific result
@@ -40,6 +46,13 @@ func (s Status) Error() string {
 	return s.Err
 }
 
+// WaitRequest asks for the status of a job once it has changed from
+// Last, so that a caller can follow a job without polling.
+type WaitRequest struct {
+	ID   ID
+	Last StatusString
+}
+
 // Queue is an unbounded queue of jobs; enqueuing a job will always
 // proceed, while dequeuing is done by receiving from a channel. It is
 // also possible to iterate over the current list of jobs.
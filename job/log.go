@@ -0,0 +1,66 @@
+package job
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Log is the output captured while running a job, e.g., the logging
+// from git and from applying changes.
+type Log struct {
+	Output string
+	// Truncated is true if there was more output than the limit
+	// allowed, in which case the output is the start of it.
+	Truncated bool
+}
+
+// LogBuffer collects the output of a job, up to a limit in bytes;
+// anything written after the limit is reached is dropped, and the
+// log marked as truncated. It's safe to write to from more than one
+// goroutine.
+type LogBuffer struct {
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+	mu        sync.Mutex
+}
+
+func NewLogBuffer(limit int) *LogBuffer {
+	return &LogBuffer{limit: limit}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		b.truncated = true
+		// Report everything as written, so that loggers don't
+		// complain; we've dropped the rest on purpose.
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *LogBuffer) Log() Log {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Log{
+		Output:    b.buf.String(),
+		Truncated: b.truncated,
+	}
+}
+
+// Logger returns a logger that writes to the log buffer as well as
+// to the logger given.
+func (b *LogBuffer) Logger(logger log.Logger) log.Logger {
+	captured := log.NewLogfmtLogger(b)
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		captured.Log(keyvals...)
+		return logger.Log(keyvals...)
+	})
+}
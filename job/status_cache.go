@@ -2,22 +2,31 @@ package job
 
 import (
 	"sync"
+	"time"
 )
 
 type StatusCache struct {
 	// Size is the number of statuses to store. When full, jobs are evicted in FIFO ordering.
 	// oldest ones will be evicted to make room.
 	Size int
+	// LogRetention is how long to keep the log for a job, after its
+	// status was last updated. Zero means logs are kept for as long
+	// as the status is.
+	LogRetention time.Duration
 
 	// Store cache entries in an array to make fifo eviction easier. Efficiency
 	// doesn't matter because the cache is small and computers are fast.
 	cache []cacheEntry
+	// changed is closed, and replaced, whenever a status is set
+	changed chan struct{}
 	sync.RWMutex
 }
 
 type cacheEntry struct {
-	ID     ID
-	Status Status
+	ID      ID
+	Status  Status
+	Log     *LogBuffer
+	Updated time.Time
 }
 
 func (c *StatusCache) SetStatus(id ID, status Status) {
@@ -26,9 +35,15 @@ func (c *StatusCache) SetStatus(id ID, status Status) {
 	}
 	c.Lock()
 	defer c.Unlock()
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
 	if i := c.statusIndex(id); i >= 0 {
 		// already exists, update
 		c.cache[i].Status = status
+		c.cache[i].Updated = time.Now()
+		return
 	}
 	// Evict, if we need to. Eviction is done first, so that append can only copy
 	// the things we care about keeping. Micro-optimize to the max.
@@ -36,8 +51,9 @@ func (c *StatusCache) SetStatus(id ID, status Status) {
 		c.cache = c.cache[len(c.cache)-(c.Size-1):]
 	}
 	c.cache = append(c.cache, cacheEntry{
-		ID:     id,
-		Status: status,
+		ID:      id,
+		Status:  status,
+		Updated: time.Now(),
 	})
 }
 
@@ -51,6 +67,43 @@ func (c *StatusCache) Status(id ID) (Status, bool) {
 	return c.cache[i].Status, true
 }
 
+// Changed returns a channel that is closed the next time a status
+// is set. To avoid missing a change, get the channel before looking
+// at the status.
+func (c *StatusCache) Changed() <-chan struct{} {
+	c.Lock()
+	defer c.Unlock()
+	if c.changed == nil {
+		c.changed = make(chan struct{})
+	}
+	return c.changed
+}
+
+// SetLog attaches a log to the job with the given ID. It has no
+// effect if there's no status for the job.
+func (c *StatusCache) SetLog(id ID, log *LogBuffer) {
+	c.Lock()
+	defer c.Unlock()
+	if i := c.statusIndex(id); i >= 0 {
+		c.cache[i].Log = log
+	}
+}
+
+// Log returns the log for the job with the given ID, if there is one
+// and it has not expired.
+func (c *StatusCache) Log(id ID) (Log, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	i := c.statusIndex(id)
+	if i < 0 || c.cache[i].Log == nil {
+		return Log{}, false
+	}
+	if c.LogRetention > 0 && time.Since(c.cache[i].Updated) > c.LogRetention {
+		return Log{}, false
+	}
+	return c.cache[i].Log.Log(), true
+}
+
 func (c *StatusCache) statusIndex(id ID) int {
 	// entries are sorted by arrival time, not id, so we can't use binary search.
 	for i := range c.cache {
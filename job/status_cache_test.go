@@ -0,0 +1,83 @@
+package job
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestLogBuffer_Truncates(t *testing.T) {
+	buf := NewLogBuffer(10)
+	buf.Write([]byte("0123456"))
+	buf.Write([]byte("789abc"))
+	l := buf.Log()
+	if l.Output != "0123456789" {
+		t.Errorf("expected output to be cut off at limit, got %q", l.Output)
+	}
+	if !l.Truncated {
+		t.Error("expected log to be marked as truncated")
+	}
+}
+
+func TestLogBuffer_Logger(t *testing.T) {
+	buf := NewLogBuffer(1024)
+	buf.Logger(log.NewNopLogger()).Log("msg", "hello")
+	if l := buf.Log(); !strings.Contains(l.Output, "msg=hello") || l.Truncated {
+		t.Errorf("expected logged line in output, got %+v", l)
+	}
+}
+
+func TestStatusCache_Log(t *testing.T) {
+	cache := &StatusCache{Size: 10}
+	if _, ok := cache.Log("job"); ok {
+		t.Error("expected no log for unknown job")
+	}
+
+	cache.SetStatus("job", Status{StatusString: StatusRunning})
+	if _, ok := cache.Log("job"); ok {
+		t.Error("expected no log before one is set")
+	}
+
+	buf := NewLogBuffer(1024)
+	cache.SetLog("job", buf)
+	buf.Write([]byte("output"))
+	cache.SetStatus("job", Status{StatusString: StatusSucceeded})
+	if l, ok := cache.Log("job"); !ok || l.Output != "output" {
+		t.Errorf("expected log with output, got %+v (%v)", l, ok)
+	}
+	if len(cache.cache) != 1 {
+		t.Errorf("expected updating a status to keep one entry, got %d", len(cache.cache))
+	}
+
+	cache.LogRetention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Log("job"); ok {
+		t.Error("expected log to have expired")
+	}
+	if _, ok := cache.Status("job"); !ok {
+		t.Error("expected status to outlive the log")
+	}
+}
+
+func TestStatusCache_Changed(t *testing.T) {
+	cache := &StatusCache{Size: 10}
+	changed := cache.Changed()
+	select {
+	case <-changed:
+		t.Fatal("expected no change before a status is set")
+	default:
+	}
+	cache.SetStatus("job", Status{StatusString: StatusQueued})
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected change to be signalled when a status is set")
+	}
+	select {
+	case <-cache.Changed():
+		t.Fatal("expected a fresh channel after a change")
+	default:
+	}
+}
@@ -0,0 +1,87 @@
+package flux
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLayoutFilename is the filename new manifests are given, if
+// the layout doesn't say otherwise.
+const DefaultLayoutFilename = "{{.Name}}-{{lower .Kind}}.yaml"
+
+// RepoLayout says where in the repo (relative to the top of the
+// manifests) the manifest for a new resource goes. Both fields are
+// templates over the resource's Kind, Namespace and Name, with the
+// function `lower` to hand; e.g., a Directory of "{{.Namespace}}"
+// puts the manifests for each namespace in a directory of its own.
+type RepoLayout struct {
+	Directory string `json:"directory"`
+	Filename  string `json:"filename"`
+}
+
+// ParseRepoLayout checks that each part of a layout is a usable
+// template, filling in the default filename if none is given.
+func ParseRepoLayout(directory, filename string) (RepoLayout, error) {
+	layout := RepoLayout{Directory: directory, Filename: filename}
+	if layout.Filename == "" {
+		layout.Filename = DefaultLayoutFilename
+	}
+	// Trying it out catches mistakes like referring to fields that
+	// don't exist, as well as bad syntax
+	if _, err := layout.PathFor("Deployment", "default", "example"); err != nil {
+		return RepoLayout{}, err
+	}
+	return layout, nil
+}
+
+// PathFor gives the path, relative to the top of the manifests, of
+// the file in which the resource given should be defined.
+func (l RepoLayout) PathFor(kind, namespace, name string) (string, error) {
+	vars := struct {
+		Kind, Namespace, Name string
+	}{kind, namespace, name}
+
+	filenameTmpl := l.Filename
+	if filenameTmpl == "" {
+		filenameTmpl = DefaultLayoutFilename
+	}
+	dir, err := executeLayoutTemplate("directory", l.Directory, vars)
+	if err != nil {
+		return "", err
+	}
+	filename, err := executeLayoutTemplate("filename", filenameTmpl, vars)
+	if err != nil {
+		return "", err
+	}
+	if filename == "" || strings.Contains(filename, "/") {
+		return "", errors.Errorf("layout gives %q as the filename for %s %s/%s; it must be non-empty and not contain a '/'", filename, kind, namespace, name)
+	}
+
+	path := filepath.Join(dir, filename)
+	// Don't let a layout (or a resource name) put files outside the
+	// manifests
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, "../") {
+		return "", errors.Errorf("layout gives %q as the path for %s %s/%s, which is outside the manifests", path, kind, namespace, name)
+	}
+	return path, nil
+}
+
+var layoutFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+}
+
+func executeLayoutTemplate(part, text string, vars interface{}) (string, error) {
+	tmpl, err := template.New(part).Funcs(layoutFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing layout %s template", part)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", errors.Wrapf(err, "applying layout %s template", part)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,46 @@
+package flux
+
+import (
+	"testing"
+)
+
+func TestRepoLayoutPathFor(t *testing.T) {
+	for _, c := range []struct {
+		layout RepoLayout
+		path   string
+	}{
+		{RepoLayout{}, "helloworld-deployment.yaml"},
+		{RepoLayout{Directory: "{{.Namespace}}"}, "default/helloworld-deployment.yaml"},
+		{RepoLayout{Directory: "apps/{{.Namespace}}", Filename: "{{.Name}}.yaml"}, "apps/default/helloworld.yaml"},
+		{RepoLayout{Filename: "{{lower .Kind}}s/{{.Name}}.yaml"}, ""},
+		{RepoLayout{Directory: "..", Filename: "{{.Name}}.yaml"}, ""},
+		{RepoLayout{Directory: "/etc"}, ""},
+		{RepoLayout{Filename: "{{.Image}}.yaml"}, ""},
+	} {
+		path, err := c.layout.PathFor("Deployment", "default", "helloworld")
+		if c.path == "" {
+			if err == nil {
+				t.Errorf("%+v: expected error, got path %q", c.layout, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error %v", c.layout, err)
+		} else if path != c.path {
+			t.Errorf("%+v: expected %q, got %q", c.layout, c.path, path)
+		}
+	}
+}
+
+func TestParseRepoLayout(t *testing.T) {
+	layout, err := ParseRepoLayout("{{.Namespace}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.Filename != DefaultLayoutFilename {
+		t.Errorf("expected default filename, got %q", layout.Filename)
+	}
+	if _, err := ParseRepoLayout("{{.Namespace", ""); err == nil {
+		t.Error("expected error for a bad template")
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/dogstatsd"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// The backends that can be chosen, by name, at startup.
+const (
+	BackendPrometheus = "prometheus"
+	BackendDogStatsD  = "dogstatsd"
+)
+
+// Opts name and describe a metric. As with Prometheus, the full name
+// of the metric is made from the namespace, subsystem and name.
+type Opts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	// Buckets are the upper bounds of the buckets of a histogram,
+	// for backends that need them; if empty, the backend's default
+	// is used.
+	Buckets []float64
+}
+
+// Backend makes metrics that are reported to some monitoring system.
+// Each metric has the labels given; for backends without labels as
+// such, they become tags, or similar.
+type Backend interface {
+	NewCounter(opts Opts, labels []string) metrics.Counter
+	NewGauge(opts Opts, labels []string) metrics.Gauge
+	NewHistogram(opts Opts, labels []string) metrics.Histogram
+}
+
+// Prometheus is the backend that registers metrics with the default
+// Prometheus registry, to be scraped from /metrics.
+type Prometheus struct{}
+
+func (Prometheus) NewCounter(opts Opts, labels []string) metrics.Counter {
+	return prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, labels)
+}
+
+func (Prometheus) NewGauge(opts Opts, labels []string) metrics.Gauge {
+	return prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, labels)
+}
+
+func (Prometheus) NewHistogram(opts Opts, labels []string) metrics.Histogram {
+	return prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+		Buckets:   opts.Buckets,
+	}, labels)
+}
+
+// DogStatsD is the backend that sends metrics to a DogStatsD agent
+// (e.g., Datadog's), with labels as tags. Observations are buffered
+// until sent, by SendLoop. Histograms are sent as such, so the
+// buckets of a metric are ignored.
+type DogStatsD struct {
+	*dogstatsd.Dogstatsd
+}
+
+// NewDogStatsD makes a DogStatsD backend. The logger is for errors
+// in sending the metrics.
+func NewDogStatsD(logger log.Logger) DogStatsD {
+	return DogStatsD{dogstatsd.New("", logger)}
+}
+
+// dotted gives the full name of a metric, in the style of StatsD,
+// e.g., "flux.fluxd.sync_attempts_total".
+func dotted(opts Opts) string {
+	var parts []string
+	for _, p := range []string{opts.Namespace, opts.Subsystem, opts.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (d DogStatsD) NewCounter(opts Opts, _ []string) metrics.Counter {
+	return d.Dogstatsd.NewCounter(dotted(opts), 1)
+}
+
+func (d DogStatsD) NewGauge(opts Opts, _ []string) metrics.Gauge {
+	return d.Dogstatsd.NewGauge(dotted(opts))
+}
+
+func (d DogStatsD) NewHistogram(opts Opts, _ []string) metrics.Histogram {
+	return d.Dogstatsd.NewHistogram(dotted(opts), 1)
+}
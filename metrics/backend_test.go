@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestUseDogStatsD(t *testing.T) {
+	attempts := NewCounter(Opts{
+		Namespace: "flux",
+		Subsystem: "test",
+		Name:      "attempts_total",
+	}, LabelSuccess)
+
+	statsd := NewDogStatsD(log.NewNopLogger())
+	Use(statsd)
+	// Declared after the backend was chosen
+	duration := NewHistogram(Opts{
+		Namespace: "flux",
+		Subsystem: "test",
+		Name:      "duration_seconds",
+	}, LabelMethod)
+
+	attempts.With(LabelSuccess, "true").Add(1)
+	duration.With(LabelMethod, "Sync").Observe(0.5)
+
+	buf := &bytes.Buffer{}
+	if _, err := statsd.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := buf.String()
+	for _, expected := range []string{
+		"flux.test.attempts_total:1.000000|c|#success:true",
+		"flux.test.duration_seconds:0.500000|h|#method:Sync",
+	} {
+		if !strings.Contains(lines, expected) {
+			t.Errorf("expected %q in output, got:\n%s", expected, lines)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// Metrics are declared as package variables, before main has had a
+// chance to choose a backend; so what's declared here is a stand-in,
+// which makes the metric proper with the chosen backend when it's
+// first used, or when the backend is chosen with Use.
+
+type lazy interface {
+	make()
+}
+
+var (
+	mu       sync.Mutex
+	backend  Backend
+	declared []lazy
+)
+
+// Use makes all the metrics declared so far, and any declared
+// afterwards, with the backend given. It should be called once, at
+// startup, before any metrics are recorded; metrics used before then
+// are made with the Prometheus backend.
+func Use(b Backend) {
+	mu.Lock()
+	backend = b
+	ds := declared
+	mu.Unlock()
+	for _, d := range ds {
+		d.make()
+	}
+}
+
+func currentBackend() Backend {
+	mu.Lock()
+	defer mu.Unlock()
+	if backend == nil {
+		return Prometheus{}
+	}
+	return backend
+}
+
+func declare(d lazy) {
+	mu.Lock()
+	defer mu.Unlock()
+	declared = append(declared, d)
+}
+
+// NewCounter declares a counter with the labels given.
+func NewCounter(opts Opts, labels ...string) metrics.Counter {
+	c := &counter{opts: opts, labels: labels}
+	declare(c)
+	return c
+}
+
+// NewGauge declares a gauge with the labels given.
+func NewGauge(opts Opts, labels ...string) metrics.Gauge {
+	g := &gauge{opts: opts, labels: labels}
+	declare(g)
+	return g
+}
+
+// NewHistogram declares a histogram with the labels given.
+func NewHistogram(opts Opts, labels ...string) metrics.Histogram {
+	h := &histogram{opts: opts, labels: labels}
+	declare(h)
+	return h
+}
+
+type counter struct {
+	once   sync.Once
+	opts   Opts
+	labels []string
+	c      metrics.Counter
+}
+
+func (c *counter) make() {
+	c.once.Do(func() {
+		c.c = currentBackend().NewCounter(c.opts, c.labels)
+	})
+}
+
+func (c *counter) With(labelValues ...string) metrics.Counter {
+	c.make()
+	return c.c.With(labelValues...)
+}
+
+func (c *counter) Add(delta float64) {
+	c.make()
+	c.c.Add(delta)
+}
+
+type gauge struct {
+	once   sync.Once
+	opts   Opts
+	labels []string
+	g      metrics.Gauge
+}
+
+func (g *gauge) make() {
+	g.once.Do(func() {
+		g.g = currentBackend().NewGauge(g.opts, g.labels)
+	})
+}
+
+func (g *gauge) With(labelValues ...string) metrics.Gauge {
+	g.make()
+	return g.g.With(labelValues...)
+}
+
+func (g *gauge) Set(value float64) {
+	g.make()
+	g.g.Set(value)
+}
+
+type histogram struct {
+	once   sync.Once
+	opts   Opts
+	labels []string
+	h      metrics.Histogram
+}
+
+func (h *histogram) make() {
+	h.once.Do(func() {
+		h.h = currentBackend().NewHistogram(h.opts, h.labels)
+	})
+}
+
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	h.make()
+	return h.h.With(labelValues...)
+}
+
+func (h *histogram) Observe(value float64) {
+	h.make()
+	h.h.Observe(value)
+}
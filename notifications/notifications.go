@@ -1,23 +1,84 @@
 package notifications
 
 import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
 	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/service/instance"
 )
 
 func Event(cfg instance.Config, e history.Event) error {
-	// If this is a release
-	if cfg.Settings.Slack.HookURL != "" {
-		switch e.Type {
-		case history.EventRelease:
-			r := e.Metadata.(*history.ReleaseEventMetadata)
-			return slackNotifyRelease(cfg.Settings.Slack, r, r.Error)
-		case history.EventAutoRelease:
-			r := e.Metadata.(*history.AutoReleaseEventMetadata)
-			return slackNotifyAutoRelease(cfg.Settings.Slack, r, r.Error)
-		case history.EventSync:
-			return slackNotifySync(cfg.Settings.Slack, &e)
+	var errs []string
+	for _, slack := range slackRoutes(cfg.Settings.Slack, e) {
+		if err := slackEvent(slack, e); err != nil {
+			errs = append(errs, err.Error())
 		}
 	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
 	return nil
 }
+
+func slackEvent(config service.NotifierConfig, e history.Event) error {
+	switch e.Type {
+	case history.EventRelease:
+		r := e.Metadata.(*history.ReleaseEventMetadata)
+		return slackNotifyRelease(config, r, r.Error)
+	case history.EventAutoRelease:
+		r := e.Metadata.(*history.AutoReleaseEventMetadata)
+		return slackNotifyAutoRelease(config, r, r.Error)
+	case history.EventSync:
+		return slackNotifySync(config, &e)
+	case history.EventFailure:
+		return slackNotifyFailure(config, &e)
+	case history.EventConflict:
+		return slackNotifyConflict(config, &e)
+	}
+	return nil
+}
+
+// slackRoutes works out where the notification for an event should
+// go. Services that have a channel of their own get it sent there;
+// if any of the services in the event don't (or there are no
+// services), it goes to the instance's hook as well. Each
+// destination is given as the config to use for it.
+func slackRoutes(config service.NotifierConfig, e history.Event) []service.NotifierConfig {
+	var (
+		channels  = map[string]bool{}
+		toDefault = len(e.ServiceIDs) == 0
+	)
+	for _, id := range e.ServiceIDs {
+		if channel := e.NotifyChannels[id]; channel != "" {
+			channels[channel] = true
+		} else {
+			toDefault = true
+		}
+	}
+
+	var routes []service.NotifierConfig
+	if toDefault && config.HookURL != "" {
+		routes = append(routes, config)
+	}
+	var names []string
+	for channel := range channels {
+		names = append(names, channel)
+	}
+	sort.Strings(names)
+	for _, channel := range names {
+		route := config
+		if hookURL, ok := config.Channels[channel]; ok {
+			route.HookURL, route.Channel = hookURL, ""
+		} else {
+			route.Channel = channel
+		}
+		if route.HookURL != "" {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
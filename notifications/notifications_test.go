@@ -1,8 +1,10 @@
 package notifications
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/weaveworks/flux"
@@ -66,3 +68,147 @@ func TestRelease_DryRun(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNotifyChannels(t *testing.T) {
+	var defaultChannels []string
+	defaultHook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMsg
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		defaultChannels = append(defaultChannels, msg.Channel)
+	}))
+	defer defaultHook.Close()
+	var mapped int
+	paymentsHook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mapped++
+	}))
+	defer paymentsHook.Close()
+
+	cfg := instance.Config{
+		Settings: service.InstanceConfig{
+			Slack: service.NotifierConfig{
+				HookURL:      defaultHook.URL,
+				NotifyEvents: []string{history.EventSync},
+				Channels: map[string]string{
+					"#team-payments": paymentsHook.URL,
+				},
+			},
+		},
+	}
+	payments, frontend, other := flux.ServiceID("default/payments"), flux.ServiceID("default/frontend"), flux.ServiceID("default/other")
+	ev := history.Event{
+		Type:       history.EventSync,
+		ServiceIDs: []flux.ServiceID{payments, frontend},
+		NotifyChannels: map[flux.ServiceID]string{
+			payments: "#team-payments",
+			frontend: "#team-frontend",
+		},
+		Metadata: &history.SyncEventMetadata{},
+	}
+	if err := Event(cfg, ev); err != nil {
+		t.Fatal(err)
+	}
+	// Every service has a channel, so the instance's own channel
+	// isn't told; the unmapped channel goes via the default hook
+	if !reflect.DeepEqual(defaultChannels, []string{"#team-frontend"}) {
+		t.Errorf("expected one message to #team-frontend via default hook, got %q", defaultChannels)
+	}
+	if mapped != 1 {
+		t.Errorf("expected one message to the mapped hook, got %d", mapped)
+	}
+
+	defaultChannels = nil
+	ev.ServiceIDs = append(ev.ServiceIDs, other)
+	if err := Event(cfg, ev); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(defaultChannels, []string{"", "#team-frontend"}) {
+		t.Errorf("expected messages to the default channel and #team-frontend, got %q", defaultChannels)
+	}
+}
+
+func TestNotifyFailure(t *testing.T) {
+	var msgs []SlackMsg
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMsg
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}))
+	defer server.Close()
+
+	ev := history.Event{
+		Type: history.EventFailure,
+		Metadata: &history.FailureEventMetadata{
+			Component: "registry",
+			Error:     "registry responded with 500 Internal Server Error",
+			Simulated: true,
+		},
+	}
+	slack := service.NotifierConfig{HookURL: server.URL}
+	// Failures aren't notified unless asked for
+	if err := Event(instance.Config{Settings: service.InstanceConfig{Slack: slack}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %+v", msgs)
+	}
+
+	slack.NotifyEvents = []string{history.EventFailure}
+	if err := Event(instance.Config{Settings: service.InstanceConfig{Slack: slack}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || len(msgs[0].Attachments) != 1 || msgs[0].Attachments[0].Text != ev.String() {
+		t.Errorf("expected one message with the failure attached, got %+v", msgs)
+	}
+}
+
+func TestNotifyConflict(t *testing.T) {
+	var msgs []SlackMsg
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMsg
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}))
+	defer server.Close()
+
+	conflict := &history.ConflictEventMetadata{
+		Conflict: flux.GitConflict{
+			Policy:   flux.ConflictPreferHuman,
+			Upstream: "1234567890abcdef",
+			Files:    []string{"helloworld-deploy.yaml"},
+		},
+	}
+	ev := history.Event{Type: history.EventConflict, Metadata: conflict}
+	slack := service.NotifierConfig{HookURL: server.URL}
+	// Conflicts that were dealt with aren't notified unless asked for
+	if err := Event(instance.Config{Settings: service.InstanceConfig{Slack: slack}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %+v", msgs)
+	}
+
+	// .. but aborted commits are
+	conflict.Conflict.Policy = flux.ConflictAbort
+	if err := Event(instance.Config{Settings: service.InstanceConfig{Slack: slack}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || len(msgs[0].Attachments) != 1 || msgs[0].Attachments[0].Text != ev.String() {
+		t.Fatalf("expected one message with the conflict attached, got %+v", msgs)
+	}
+
+	// .. unless the config says which events to notify of, and it's
+	// not one of them
+	slack.NotifyEvents = []string{history.EventRelease}
+	if err := Event(instance.Config{Settings: service.InstanceConfig{Slack: slack}}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Errorf("expected no further messages, got %+v", msgs[1:])
+	}
+}
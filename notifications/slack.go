@@ -20,6 +20,7 @@ import (
 )
 
 type SlackMsg struct {
+	Channel     string            `json:"channel,omitempty"`
 	Username    string            `json:"username"`
 	Text        string            `json:"text"`
 	Attachments []SlackAttachment `json:"attachments,omitempty"`
@@ -113,6 +114,12 @@ func slackNotifyRelease(config service.NotifierConfig, release *history.ReleaseE
 		attachments = append(attachments, result)
 	}
 
+	if notes, err := slackNotesAttachment(config, release.Notes); err != nil {
+		return err
+	} else if notes != nil {
+		attachments = append(attachments, *notes)
+	}
+
 	return notify(config, SlackMsg{
 		Username:    config.Username,
 		Text:        text,
@@ -133,6 +140,11 @@ func slackNotifyAutoRelease(config service.NotifierConfig, release *history.Auto
 	if release.Result != nil {
 		attachments = append(attachments, slackResultAttachment(release.Result))
 	}
+	if notes, err := slackNotesAttachment(config, release.Notes); err != nil {
+		return err
+	} else if notes != nil {
+		attachments = append(attachments, *notes)
+	}
 	text, err := instantiateTemplate("auto-release", AutoReleaseTemplate, struct {
 		Images []flux.ImageID
 	}{
@@ -159,6 +171,32 @@ func slackNotifySync(config service.NotifierConfig, sync *history.Event) error {
 	})
 }
 
+func slackNotifyFailure(config service.NotifierConfig, failure *history.Event) error {
+	if !hasNotifyEvent(config, history.EventFailure) {
+		return nil
+	}
+	return notify(config, SlackMsg{
+		Username:    config.Username,
+		Attachments: []SlackAttachment{errorAttachment(failure.String())},
+	})
+}
+
+// slackNotifyConflict tells of a commit that clashed with changes
+// pushed upstream. Unless the config says which events to notify of,
+// it's only sent when the commit was given up on, since then someone
+// needs to look at it.
+func slackNotifyConflict(config service.NotifierConfig, conflict *history.Event) error {
+	metadata := conflict.Metadata.(*history.ConflictEventMetadata)
+	aborted := metadata.Conflict.Policy == flux.ConflictAbort && !metadata.Conflict.Pushed
+	if !hasNotifyEvent(config, history.EventConflict) && !(config.NotifyEvents == nil && aborted) {
+		return nil
+	}
+	return notify(config, SlackMsg{
+		Username:    config.Username,
+		Attachments: []SlackAttachment{errorAttachment(conflict.String())},
+	})
+}
+
 func slackResultAttachment(res update.Result) SlackAttachment {
 	buf := &bytes.Buffer{}
 	update.PrintResults(buf, res, false)
@@ -173,7 +211,28 @@ func slackResultAttachment(res update.Result) SlackAttachment {
 	}
 }
 
+// slackNotesAttachment renders the release notes given, if the
+// config asks for them; otherwise it returns nil.
+func slackNotesAttachment(config service.NotifierConfig, notes *update.ReleaseNotes) (*SlackAttachment, error) {
+	if !config.ReleaseNotes || notes == nil || len(notes.Services) == 0 {
+		return nil, nil
+	}
+	text := notes.Text
+	if config.ReleaseNotesTemplate != "" {
+		var err error
+		if text, err = notes.Render(config.ReleaseNotesTemplate); err != nil {
+			return nil, errors.Wrap(err, "rendering release notes")
+		}
+	}
+	return &SlackAttachment{
+		Fallback: text,
+		Text:     "```" + text + "```",
+		Markdown: []string{"text"},
+	}, nil
+}
+
 func notify(config service.NotifierConfig, msg SlackMsg) error {
+	msg.Channel = config.Channel
 	buf := &bytes.Buffer{}
 	if err := json.NewEncoder(buf).Encode(msg); err != nil {
 		return errors.Wrap(err, "encoding Slack POST request")
@@ -105,3 +105,39 @@ func TestSlackNotifierErrorHandling(t *testing.T) {
 		t.Fatalf("Expected error back: %q, got %q", expected, err.Error())
 	}
 }
+
+func TestSlackNotesAttachment(t *testing.T) {
+	notes := &update.ReleaseNotes{
+		Services: []update.ServiceNotes{{
+			ID: "default/helloworld",
+			Containers: []update.ContainerNotes{
+				{Container: "helloworld", Repository: "helloworld", From: "1", To: "2"},
+			},
+		}},
+		Text: "default/helloworld: 1 -> 2",
+	}
+
+	// Not asked for, so not attached
+	if a, err := slackNotesAttachment(service.NotifierConfig{}, notes); err != nil || a != nil {
+		t.Errorf("expected no attachment when notes not switched on, got %#v, %v", a, err)
+	}
+
+	a, err := slackNotesAttachment(service.NotifierConfig{ReleaseNotes: true}, notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil || a.Fallback != notes.Text {
+		t.Errorf("expected notes text in attachment, got %#v", a)
+	}
+
+	a, err = slackNotesAttachment(service.NotifierConfig{
+		ReleaseNotes:         true,
+		ReleaseNotesTemplate: `{{range .Services}}{{.ID}} updated{{end}}`,
+	}, notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil || a.Fallback != "default/helloworld updated" {
+		t.Errorf("expected notes rendered with configured template, got %#v", a)
+	}
+}
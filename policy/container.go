@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AutomatedPrefix and LockedPrefix are the prefixes of the policies
+// that apply automation and locking to individual containers of a
+// service; the rest of the policy name is the container's. A
+// container of an automated service is automated unless it has
+// `automated.<container>: "false"`, and a container is locked if the
+// service is, or it has `locked.<container>: "true"`.
+const (
+	AutomatedPrefix = "automated."
+	LockedPrefix    = "locked."
+)
+
+// ContainerAutomated says whether automation may update the container
+// given: the service must be automated, and the container not left
+// out of automation, locked, or ignored.
+func (s Set) ContainerAutomated(container string) bool {
+	if !s.Contains(Automated) || s.ContainerLocked(container) || s.ContainerIgnored(container) {
+		return false
+	}
+	return s[Policy(AutomatedPrefix+container)] != "false"
+}
+
+// ContainerLocked says whether the container given is locked, either
+// by itself or along with the rest of the service.
+func (s Set) ContainerLocked(container string) bool {
+	return s.Contains(Locked) || s[Policy(LockedPrefix+container)] == "true"
+}
+
+// ContainerIgnored says whether the container given is one of those
+// the service ignores, according to its IgnoreContainers policy.
+func (s Set) ContainerIgnored(container string) bool {
+	for _, c := range strings.Split(s[IgnoreContainers], ",") {
+		if strings.TrimSpace(c) == container {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContainerPolicies checks that the values given to
+// per-container automation and locking policies are "true" or
+// "false", and that containers to ignore are named.
+func (s Set) validateContainerPolicies() error {
+	if v, ok := s[IgnoreContainers]; ok {
+		for _, c := range strings.Split(v, ",") {
+			if strings.TrimSpace(c) == "" {
+				return fmt.Errorf("policy %s: container names must not be empty, in %q", IgnoreContainers, v)
+			}
+		}
+	}
+	for p, v := range s {
+		if !hasContainerPrefix(p) {
+			continue
+		}
+		if v != "true" && v != "false" {
+			return fmt.Errorf("policy %s: value must be true or false, not %q", p, v)
+		}
+	}
+	return nil
+}
+
+func hasContainerPrefix(p Policy) bool {
+	for _, prefix := range []string{AutomatedPrefix, LockedPrefix} {
+		if strings.HasPrefix(string(p), prefix) {
+			return true
+		}
+	}
+	return false
+}
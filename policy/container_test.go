@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestContainerPolicies(t *testing.T) {
+	for _, c := range []struct {
+		policies                   Set
+		automated, locked, ignored bool
+	}{
+		{Set{}, false, false, false},
+		{Set{Automated: "true"}, true, false, false},
+		{Set{Automated: "true", "automated.app": "false"}, false, false, false},
+		{Set{Automated: "true", "automated.sidecar": "false"}, true, false, false},
+		{Set{Automated: "true", "automated.app": "true"}, true, false, false},
+		{Set{"automated.app": "true"}, false, false, false},
+		{Set{Automated: "true", Locked: "true"}, false, true, false},
+		{Set{Automated: "true", "locked.app": "true"}, false, true, false},
+		{Set{Automated: "true", "locked.sidecar": "true"}, true, false, false},
+		{Set{Automated: "true", IgnoreContainers: "istio-proxy, app"}, false, false, true},
+		{Set{Automated: "true", IgnoreContainers: "istio-proxy"}, true, false, false},
+	} {
+		if got := c.policies.ContainerAutomated("app"); got != c.automated {
+			t.Errorf("%v: expected automated to be %v, got %v", c.policies, c.automated, got)
+		}
+		if got := c.policies.ContainerLocked("app"); got != c.locked {
+			t.Errorf("%v: expected locked to be %v, got %v", c.policies, c.locked, got)
+		}
+		if got := c.policies.ContainerIgnored("app"); got != c.ignored {
+			t.Errorf("%v: expected ignored to be %v, got %v", c.policies, c.ignored, got)
+		}
+	}
+
+	id := flux.MakeServiceID("default", "helloworld")
+	if err := (Updates{id: Update{Add: Set{"locked.app": "yes"}}}).Validate(); err == nil {
+		t.Error("expected a per-container lock that isn't true or false not to validate")
+	}
+	if err := (Updates{id: Update{Add: Set{IgnoreContainers: "istio-proxy,"}}}).Validate(); err == nil {
+		t.Error("expected a list of containers to ignore with an empty name in it not to validate")
+	}
+	if err := (Updates{id: Update{Add: Set{"automated.app": "false", "locked.sidecar": "true", IgnoreContainers: "istio-proxy"}}}).Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
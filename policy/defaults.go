@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NamespaceDefaults gives, for each namespace, policies that apply to
+// every service in the namespace, unless a service says otherwise
+// (see Apply). Written as YAML, they look like
+//
+//     staging:
+//       automated: "true"
+//     prod:
+//       tag.*: "semver:*"
+type NamespaceDefaults map[string]Set
+
+// ParseNamespaceDefaults reads namespace defaults from YAML, and
+// checks that the policies in them are valid.
+func ParseNamespaceDefaults(def []byte) (NamespaceDefaults, error) {
+	var defaults NamespaceDefaults
+	if err := yaml.Unmarshal(def, &defaults); err != nil {
+		return nil, err
+	}
+	if err := defaults.Validate(); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// Validate checks that the policies given for each namespace are
+// valid.
+func (d NamespaceDefaults) Validate() error {
+	var namespaces []string
+	for namespace := range d {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		if err := d[namespace].validate(); err != nil {
+			return fmt.Errorf("namespace %s: %s", namespace, err)
+		}
+	}
+	return nil
+}
+
+// LoadNamespaceDefaults reads namespace defaults from the file given.
+func LoadNamespaceDefaults(path string) (NamespaceDefaults, error) {
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNamespaceDefaults(def)
+}
+
+// Apply gives the policies of a service in the namespace given: the
+// defaults for the namespace, overridden by the service's own
+// policies. These are given as they are written in its manifest, so
+// that a boolean policy given as "false" (or anything other than
+// "true") turns off a default for the service.
+func (d NamespaceDefaults) Apply(namespace string, own map[Policy]string) Set {
+	var policies Set
+	if defaults, ok := d[namespace]; ok {
+		policies = clone(defaults)
+	}
+	for p, v := range own {
+		if Boolean(p) && v != "true" {
+			delete(policies, p)
+			continue
+		}
+		policies = policies.Set(p, v)
+	}
+	return policies
+}
+
+// SharedDefaults holds namespace defaults that may be replaced while
+// they are in use, e.g., when they are given anew in the instance
+// config. A nil *SharedDefaults holds no defaults.
+type SharedDefaults struct {
+	mu       sync.RWMutex
+	defaults NamespaceDefaults
+}
+
+func NewSharedDefaults(d NamespaceDefaults) *SharedDefaults {
+	return &SharedDefaults{defaults: d}
+}
+
+// Get gives the defaults currently held. They must not be modified.
+func (s *SharedDefaults) Get() NamespaceDefaults {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.defaults
+}
+
+// Set replaces the defaults held.
+func (s *SharedDefaults) Set(d NamespaceDefaults) {
+	s.mu.Lock()
+	s.defaults = d
+	s.mu.Unlock()
+}
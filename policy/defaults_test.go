@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNamespaceDefaults(t *testing.T) {
+	defaults, err := ParseNamespaceDefaults([]byte(`
+staging:
+  automated: true
+prod:
+  tag.*: "semver:*"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NamespaceDefaults{
+		"staging": Set{Automated: "true"},
+		"prod":    Set{TagAllContainers: "semver:*"},
+	}
+	if !reflect.DeepEqual(expected, defaults) {
+		t.Errorf("expected %v, got %v", expected, defaults)
+	}
+
+	if _, err := ParseNamespaceDefaults([]byte("prod:\n  tag.*: \"semver:not a range\"\n")); err == nil {
+		t.Error("expected an error for a tag filter that can't be parsed")
+	}
+}
+
+func TestApplyNamespaceDefaults(t *testing.T) {
+	defaults := NamespaceDefaults{
+		"staging": Set{Automated: "true", TagAllContainers: "glob:master-*"},
+	}
+	for _, c := range []struct {
+		namespace string
+		own       map[Policy]string
+		expected  Set
+	}{
+		{"prod", nil, nil},
+		{"prod", map[Policy]string{Locked: "true", Automated: "false"}, Set{Locked: "true"}},
+		{"staging", nil, Set{Automated: "true", TagAllContainers: "glob:master-*"}},
+		{"staging", map[Policy]string{Automated: "false"}, Set{TagAllContainers: "glob:master-*"}},
+		{"staging", map[Policy]string{TagAllContainers: "semver:*", Policy(TagPrefix + "greeter"): "glob:*"}, Set{
+			Automated:                     "true",
+			TagAllContainers:              "semver:*",
+			Policy(TagPrefix + "greeter"): "glob:*",
+		}},
+	} {
+		if got := defaults.Apply(c.namespace, c.own); !reflect.DeepEqual(c.expected, got) {
+			t.Errorf("%s %v: expected %v, got %v", c.namespace, c.own, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	glob "github.com/ryanuber/go-glob"
+)
+
+const (
+	globPrefix   = "glob:"
+	semverPrefix = "semver:"
+	regexPrefix  = "regex:"
+)
+
+// TagPrefix is the prefix of the policies that filter the tags that
+// automation may update a container to; the rest of the policy name
+// is the container's. TagAllContainers is the filter for containers
+// that don't have one of their own, e.g., as given by a namespace
+// default.
+const (
+	TagPrefix        = "tag."
+	TagAllContainers = Policy(TagPrefix + "*")
+)
+
+// TagAll is the pattern used for a container with no tag policy.
+var TagAll Pattern = globPattern("*")
+
+// Pattern is a filter on image tags, as given in a `tag.<container>`
+// policy. It's a glob by default, or with the prefix "glob:"; with
+// the prefix "semver:", a range of semantic versions, e.g.,
+// "semver:^1.2"; or, with the prefix "regex:", a regular expression
+// that must match the whole tag, e.g.,
+// "regex:release-\d{4}-\d{2}-\d{2}".
+type Pattern interface {
+	// Matches says whether the tag passes the filter.
+	Matches(tag string) bool
+	// Newer says whether, of two tags that both match, the first is
+	// the newer. A pattern that doesn't know (a glob, say) says
+	// false, and the images are taken in the order they were made.
+	Newer(a, b string) bool
+	// String gives the pattern as it's written in a policy.
+	String() string
+}
+
+// ParsePattern gives the pattern written in a policy, or an error if
+// it's a semver range or regular expression that can't be parsed.
+func ParsePattern(s string) (Pattern, error) {
+	switch {
+	case strings.HasPrefix(s, semverPrefix):
+		c, err := parseConstraint(strings.TrimPrefix(s, semverPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid semver range %q: %s", s, err)
+		}
+		return semverPattern{pattern: s, constraint: c}, nil
+	case strings.HasPrefix(s, regexPrefix):
+		re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(s, regexPrefix) + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %s", s, err)
+		}
+		return regexPattern{pattern: s, re: re}, nil
+	default:
+		return globPattern(strings.TrimPrefix(s, globPrefix)), nil
+	}
+}
+
+// NewPattern gives the pattern written in a policy. A pattern that
+// can't be parsed matches nothing, so that a mistake in the policy
+// doesn't let automation loose on every tag.
+func NewPattern(s string) Pattern {
+	p, err := ParsePattern(s)
+	if err != nil {
+		return invalidPattern(s)
+	}
+	return p
+}
+
+// ValidateTagPatterns checks that the tag filters in a set of
+// policies can be parsed.
+func (s Set) ValidateTagPatterns() error {
+	for p, v := range s {
+		if !strings.HasPrefix(string(p), TagPrefix) {
+			continue
+		}
+		if _, err := ParsePattern(v); err != nil {
+			return fmt.Errorf("policy %s: %s", p, err)
+		}
+	}
+	return nil
+}
+
+// TagPattern gives the pattern for a container, as set by its
+// `tag.<container>` policy, or failing that by `tag.*`; or TagAll if
+// there's neither.
+func (s Set) TagPattern(container string) Pattern {
+	if pattern, ok := s.Get(Policy(TagPrefix + container)); ok {
+		return NewPattern(pattern)
+	}
+	if pattern, ok := s.Get(TagAllContainers); ok {
+		return NewPattern(pattern)
+	}
+	return TagAll
+}
+
+type globPattern string
+
+// Matches leaves out the tag "latest", unless that's what's asked
+// for, since it could be any image.
+func (g globPattern) Matches(tag string) bool {
+	if !strings.EqualFold(string(g), "latest") && strings.EqualFold(tag, "latest") {
+		return false
+	}
+	return glob.Glob(string(g), tag)
+}
+
+func (g globPattern) Newer(a, b string) bool {
+	return false
+}
+
+func (g globPattern) String() string {
+	return string(g)
+}
+
+type semverPattern struct {
+	pattern    string
+	constraint constraint
+}
+
+func (s semverPattern) Matches(tag string) bool {
+	v, err := parseVersion(tag)
+	return err == nil && s.constraint.matches(v)
+}
+
+// Newer goes by semver precedence.
+func (s semverPattern) Newer(a, b string) bool {
+	v, errA := parseVersion(a)
+	w, errB := parseVersion(b)
+	return errA == nil && errB == nil && v.compare(w) > 0
+}
+
+func (s semverPattern) String() string {
+	return s.pattern
+}
+
+type regexPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (r regexPattern) Matches(tag string) bool {
+	return r.re.MatchString(tag)
+}
+
+func (r regexPattern) Newer(a, b string) bool {
+	return false
+}
+
+func (r regexPattern) String() string {
+	return r.pattern
+}
+
+// invalidPattern is a pattern that couldn't be parsed; it matches
+// nothing.
+type invalidPattern string
+
+func (i invalidPattern) Matches(tag string) bool {
+	return false
+}
+
+func (i invalidPattern) Newer(a, b string) bool {
+	return false
+}
+
+func (i invalidPattern) String() string {
+	return string(i)
+}
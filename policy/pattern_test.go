@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestGlobPattern(t *testing.T) {
+	for pattern, cases := range map[string]map[string]bool{
+		"*":              {"master-a000001": true, "1.2.3": true, "latest": false},
+		"glob:release-*": {"release-1": true, "master-a000001": false},
+		"latest":         {"latest": true, "1.2.3": false},
+	} {
+		p := NewPattern(pattern)
+		for tag, expected := range cases {
+			if p.Matches(tag) != expected {
+				t.Errorf("%s matching %s: expected %v", pattern, tag, expected)
+			}
+		}
+	}
+	if NewPattern("*").Newer("2.0.0", "1.0.0") {
+		t.Error("expected a glob to have no opinion on which tag is newer")
+	}
+}
+
+func TestSemverPattern(t *testing.T) {
+	for pattern, cases := range map[string]map[string]bool{
+		"semver:^1.2": {
+			"1.2.0": true, "v1.9.3": true, "1.2": true, "1.1.9": false, "2.0.0": false,
+			"1.3.0-beta.1": false, "master-a000001": false, "latest": false,
+		},
+		"semver:^0.2.3":         {"0.2.3": true, "0.2.9": true, "0.3.0": false, "0.2.2": false},
+		"semver:^0.0.3":         {"0.0.3": true, "0.0.4": false},
+		"semver:~1.2.3":         {"1.2.3": true, "1.2.9": true, "1.3.0": false},
+		"semver:1.2.x":          {"1.2.0": true, "1.2.7": true, "1.3.0": false},
+		"semver:*":              {"0.0.1": true, "10.1.2": true, "1.0.0-rc.1": false, "release": false},
+		"semver:1.2.3":          {"1.2.3": true, "v1.2.3+build.5": true, "1.2.4": false},
+		"semver:>=1.0, <1.4":    {"1.0.0": true, "1.3.99": true, "1.4.0": false, "0.9.0": false},
+		"semver:<1.0 || >=2.0":  {"0.9.0": true, "2.1.0": true, "1.5.0": false},
+		"semver:>=1.0.0-beta.2": {"1.0.0-beta.10": true, "1.0.0-beta.1": false, "1.0.0": true},
+		"semver:!=1.2.3":        {"1.2.3": false, "1.2.4": true},
+		"semver:^1.2 ||":        {"1.2.0": false},
+		"semver:not.a.version":  {"1.0.0": false},
+	} {
+		p := NewPattern(pattern)
+		if p.String() != pattern {
+			t.Errorf("%s: expected pattern to be written as it was given, got %s", pattern, p)
+		}
+		for tag, expected := range cases {
+			if p.Matches(tag) != expected {
+				t.Errorf("%s matching %s: expected %v", pattern, tag, expected)
+			}
+		}
+	}
+}
+
+func TestSemverNewer(t *testing.T) {
+	p := NewPattern("semver:*")
+	// In ascending order of precedence, as in the semver spec
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "v1.0.1", "1.2", "1.10.0", "2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		if !p.Newer(ordered[i], ordered[i-1]) || p.Newer(ordered[i-1], ordered[i]) {
+			t.Errorf("expected %s to be newer than %s", ordered[i], ordered[i-1])
+		}
+	}
+	if p.Newer("1.0.0", "v1.0.0+build.1") {
+		t.Error("expected versions differing only in build to be equal")
+	}
+}
+
+func TestTagPattern(t *testing.T) {
+	s := Set{Policy(TagPrefix + "greeter"): "semver:~1.2"}
+	if p := s.TagPattern("greeter"); p.String() != "semver:~1.2" {
+		t.Errorf("expected the container's pattern, got %s", p)
+	}
+	if p := s.TagPattern("sidecar"); p != TagAll {
+		t.Errorf("expected TagAll for a container without a policy, got %s", p)
+	}
+	s = s.Set(TagAllContainers, "glob:master-*")
+	if p := s.TagPattern("sidecar"); p.String() != "master-*" {
+		t.Errorf("expected the pattern for all containers, got %s", p)
+	}
+	if p := s.TagPattern("greeter"); p.String() != "semver:~1.2" {
+		t.Errorf("expected the container's own pattern to take precedence, got %s", p)
+	}
+}
+
+func TestRegexPattern(t *testing.T) {
+	for pattern, cases := range map[string]map[string]bool{
+		`regex:release-\d{4}-\d{2}-\d{2}`: {
+			"release-2017-08-01": true, "release-2017-8-1": false, "pre-release-2017-08-01": false,
+			"release-2017-08-01-hotfix": false, "latest": false,
+		},
+		`regex:master-[0-9a-f]+|latest`: {"master-a000001": true, "latest": true, "dev-a000001": false},
+		`regex:release-(`:               {"release-1": false},
+	} {
+		p := NewPattern(pattern)
+		if p.String() != pattern {
+			t.Errorf("%s: expected pattern to be written as it was given, got %s", pattern, p)
+		}
+		for tag, expected := range cases {
+			if p.Matches(tag) != expected {
+				t.Errorf("%s matching %s: expected %v", pattern, tag, expected)
+			}
+		}
+	}
+}
+
+func TestValidateUpdates(t *testing.T) {
+	id := flux.MakeServiceID("default", "helloworld")
+	for _, pattern := range []string{"*", "glob:master-*", "semver:^1.2", `regex:release-\d+`} {
+		u := Updates{id: Update{Add: Set{Policy(TagPrefix + "greeter"): pattern}}}
+		if err := u.Validate(); err != nil {
+			t.Errorf("%s: expected no error, got %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"semver:not.a.version", "regex:release-("} {
+		u := Updates{id: Update{Add: Set{Policy(TagPrefix + "greeter"): pattern}}}
+		if err := u.Validate(); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+	// Only tag filters are taken as patterns
+	u := Updates{id: Update{Add: Set{NotifyChannel: "regex:("}}}
+	if err := u.Validate(); err != nil {
+		t.Errorf("expected other policies to be left alone, got %v", err)
+	}
+}
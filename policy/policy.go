@@ -2,7 +2,9 @@ package policy
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/weaveworks/flux"
 )
@@ -12,6 +14,56 @@ const (
 	Ignore    = Policy("ignore")
 	Locked    = Policy("locked")
 	Automated = Policy("automated")
+	// PullPolicy says what to do, when releasing an image, about an
+	// image pull policy that doesn't suit the image's tag. Its value
+	// is PullPolicyWarn or PullPolicyFix.
+	PullPolicy = Policy("pull-policy")
+	// NotifyChannel names the channel to which notifications about
+	// a service are sent, in place of the instance's usual channel.
+	NotifyChannel = Policy("notify-channel")
+	// RolloutOnConfigChange means the pods of a service are replaced
+	// when the ConfigMaps or Secrets they refer to change in the
+	// repo, rather than carrying on with the config they started
+	// with.
+	RolloutOnConfigChange = Policy("rollout-on-config-change")
+	// Paused holds back automated releases of a service until the
+	// time given as its value (in RFC3339 format), after which
+	// automation carries on by itself. Unlike Locked, it doesn't
+	// stop the service being released by hand.
+	Paused = Policy("paused")
+	// AutomateWindow restricts automated releases of a service to
+	// the times given as its value, e.g., "Mon-Fri 09:00-17:00 UTC"
+	// (see Window). Releases that would happen outside the window
+	// are held back until it opens.
+	AutomateWindow = Policy("automate.window")
+	// IgnoreContainers lists containers of a service (e.g., sidecars
+	// like istio-proxy), separated by commas, that are left out of
+	// the service's images and never released.
+	IgnoreContainers = Policy("ignore-containers")
+	// BlueGreen marks a service as one of a blue/green pair, both of
+	// which have it. Its value is the name of the (Kubernetes)
+	// Service, in the same namespace, that selects one or the other;
+	// automation releases to the one not selected, and once that's
+	// running the new images, switches the Service over to it.
+	BlueGreen = Policy("bluegreen")
+	// Prune, given to a namespace (as an annotation on the Namespace,
+	// or in the namespace's defaults), means resources in the
+	// namespace that are in the cluster but have gone from the repo
+	// are deleted when syncing. Protected resources are never
+	// deleted this way.
+	Prune     = Policy("prune")
+	Protected = Policy("protected")
+	// LockedUser and LockedMsg record who locked a service, and why.
+	// LockedUntil, if given, is when the lock expires (in RFC3339
+	// format), after which the daemon unlocks the service.
+	LockedUser  = Policy("locked_user")
+	LockedMsg   = Policy("locked_msg")
+	LockedUntil = Policy("locked_until")
+)
+
+const (
+	PullPolicyWarn = "warn"
+	PullPolicyFix  = "fix"
 )
 
 // Policy is an string, denoting the current deployment policy of a service,
@@ -20,7 +72,7 @@ type Policy string
 
 func Boolean(policy Policy) bool {
 	switch policy {
-	case Locked, Automated, Ignore:
+	case Locked, Automated, Ignore, RolloutOnConfigChange, Prune, Protected:
 		return true
 	}
 	return false
@@ -33,6 +85,35 @@ type Update struct {
 	Remove Set `json:"remove"`
 }
 
+// WithLockDetails gives the updates with who locked each service
+// being locked, and why, filled in from the user and message given,
+// unless they're already there; and with the details of the lock
+// removed along with it, for each service being unlocked.
+func (u Updates) WithLockDetails(user, message string) Updates {
+	result := Updates{}
+	for id, update := range u {
+		if update.Add.Contains(Locked) {
+			add := clone(update.Add)
+			if _, ok := add[LockedUser]; !ok && user != "" {
+				add[LockedUser] = user
+			}
+			if _, ok := add[LockedMsg]; !ok && message != "" {
+				add[LockedMsg] = message
+			}
+			update.Add = add
+			// A new lock doesn't inherit the expiry of an old one
+			if _, ok := add[LockedUntil]; !ok {
+				update.Remove = update.Remove.Add(LockedUntil)
+			}
+		}
+		if update.Remove.Contains(Locked) {
+			update.Remove = update.Remove.Add(LockedUser, LockedMsg, LockedUntil)
+		}
+		result[id] = update
+	}
+	return result
+}
+
 type Set map[Policy]string
 
 // We used to specify a set of policies as []Policy, and in some places
@@ -80,6 +161,46 @@ func clone(s Set) Set {
 	return newMap
 }
 
+// validate checks that the values of the policies that have to be
+// parsed can be.
+func (s Set) validate() error {
+	if err := s.ValidateTagPatterns(); err != nil {
+		return err
+	}
+	if err := s.validateContainerPolicies(); err != nil {
+		return err
+	}
+	for p, v := range s {
+		if Boolean(p) && v != "true" && v != "false" {
+			return fmt.Errorf("policy %s: value must be true or false, not %q", p, v)
+		}
+	}
+	if v, ok := s[PullPolicy]; ok && v != PullPolicyWarn && v != PullPolicyFix {
+		return fmt.Errorf("policy %s: value must be %s or %s, not %q", PullPolicy, PullPolicyWarn, PullPolicyFix, v)
+	}
+	if v, ok := s[Paused]; ok {
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("policy %s: %s", Paused, err)
+		}
+	}
+	if v, ok := s[AutomateWindow]; ok {
+		if _, err := ParseWindow(v); err != nil {
+			return fmt.Errorf("policy %s: %s", AutomateWindow, err)
+		}
+	}
+	if v, ok := s[BlueGreen]; ok {
+		if v == "" || strings.ContainsAny(v, "/ ") {
+			return fmt.Errorf("policy %s: value must be the name of a service in the same namespace, not %q", BlueGreen, v)
+		}
+	}
+	if v, ok := s[LockedUntil]; ok {
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("policy %s: %s", LockedUntil, err)
+		}
+	}
+	return nil
+}
+
 func (s Set) Contains(needle Policy) bool {
 	for p, _ := range s {
 		if p == needle {
@@ -94,6 +215,35 @@ func (s Set) Get(p Policy) (string, bool) {
 	return v, ok
 }
 
+// LockedUntil returns when the lock on a service expires, if it was
+// given an expiry that can be parsed.
+func (s Set) LockedUntil() (time.Time, bool) {
+	v, ok := s[LockedUntil]
+	if !ok {
+		return time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// PausedUntil returns the time until which automation is paused,
+// and whether it is still paused at the time given. A value that
+// can't be parsed as a time doesn't pause anything.
+func (s Set) PausedUntil(now time.Time) (time.Time, bool) {
+	v, ok := s[Paused]
+	if !ok {
+		return time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, v)
+	if err != nil || !now.Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
 type ServiceMap map[flux.ServiceID]Set
 
 func (s ServiceMap) ToSlice() []flux.ServiceID {
@@ -109,6 +259,18 @@ func (s ServiceMap) Contains(id flux.ServiceID) bool {
 	return ok
 }
 
+// PausedAt returns those services that have automation paused at the
+// time given.
+func (s ServiceMap) PausedAt(now time.Time) ServiceMap {
+	newMap := ServiceMap{}
+	for k, v := range s {
+		if _, ok := v.PausedUntil(now); ok {
+			newMap[k] = v
+		}
+	}
+	return newMap
+}
+
 func (s ServiceMap) Without(other ServiceMap) ServiceMap {
 	newMap := ServiceMap{}
 	for k, v := range s {
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
 )
 
 func TestJSON(t *testing.T) {
@@ -41,3 +44,64 @@ func TestJSON(t *testing.T) {
 		t.Errorf("Parsing equivalent list did not preserve policy. Expected:\n%#v\nGot:\n%#v\n", policy, policy2)
 	}
 }
+
+func TestPausedUntil(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	services := ServiceMap{
+		"default/paused":  Set{Paused: now.Add(time.Hour).Format(time.RFC3339)},
+		"default/resumed": Set{Paused: now.Add(-time.Hour).Format(time.RFC3339)},
+		"default/garbage": Set{Paused: "tomorrow"},
+		"default/other":   Set{Locked: "true"},
+	}
+
+	until, ok := services["default/paused"].PausedUntil(now)
+	if !ok || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected paused until %s, got %s (%v)", now.Add(time.Hour), until, ok)
+	}
+
+	paused := services.PausedAt(now)
+	if len(paused) != 1 || !paused.Contains("default/paused") {
+		t.Errorf("expected only default/paused to be paused, got %v", paused.ToSlice())
+	}
+}
+
+func TestWithLockDetails(t *testing.T) {
+	locking, unlocking, other := flux.MakeServiceID("default", "locking"), flux.MakeServiceID("default", "unlocking"), flux.MakeServiceID("default", "other")
+	updates := Updates{
+		locking:   Update{Add: Set{Locked: "true"}},
+		unlocking: Update{Remove: Set{Locked: "true"}},
+		other:     Update{Add: Set{Automated: "true"}},
+	}.WithLockDetails("alice", "waiting on the migration")
+
+	expected := Updates{
+		locking: Update{
+			Add:    Set{Locked: "true", LockedUser: "alice", LockedMsg: "waiting on the migration"},
+			Remove: Set{LockedUntil: "true"},
+		},
+		unlocking: Update{Remove: Set{Locked: "true", LockedUser: "true", LockedMsg: "true", LockedUntil: "true"}},
+		other:     Update{Add: Set{Automated: "true"}},
+	}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("expected:\n%#v\ngot:\n%#v", expected, updates)
+	}
+
+	// Details given explicitly are kept
+	until := "2017-09-11T17:00:00Z"
+	updates = Updates{
+		locking: Update{Add: Set{Locked: "true", LockedUser: "bob", LockedUntil: until}},
+	}.WithLockDetails("alice", "")
+	if u := updates[locking]; u.Add[LockedUser] != "bob" || u.Add[LockedUntil] != until || u.Remove.Contains(LockedUntil) {
+		t.Errorf("expected the lock details given to be kept, got %#v", u)
+	}
+	if _, ok := updates[locking].Add[LockedMsg]; ok {
+		t.Errorf("expected no message to be recorded, got %#v", updates[locking])
+	}
+
+	expiry, ok := updates[locking].Add.LockedUntil()
+	if !ok || !expiry.Equal(time.Date(2017, 9, 11, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the lock to expire at %s, got %s (%v)", until, expiry, ok)
+	}
+	if _, ok := (Set{Locked: "true", LockedUntil: "never"}).LockedUntil(); ok {
+		t.Error("expected an expiry that can't be parsed to be ignored")
+	}
+}
@@ -0,0 +1,271 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version, as given in an image tag. Tags are
+// a little looser than semver proper: a leading "v" is allowed, and
+// the minor and patch numbers may be left off.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i] // build metadata doesn't count
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		for _, id := range v.pre {
+			if id == "" {
+				return v, fmt.Errorf("empty pre-release identifier in %q", s)
+			}
+		}
+		s = s[:i]
+	}
+	nums, _, err := parseNumbers(s, false)
+	if err != nil {
+		return v, err
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// parseNumbers parses up to three dot-separated numbers, giving
+// zeroes for any left off; and how many were given, before any
+// wildcard, if wildcards are allowed.
+func parseNumbers(s string, wildcards bool) ([3]int, int, error) {
+	var nums [3]int
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nums, 0, fmt.Errorf("too many parts in version %q", s)
+	}
+	given := 0
+	for i, p := range parts {
+		if wildcards && (p == "x" || p == "X" || p == "*") {
+			return nums, given, nil
+		}
+		n, err := strconv.ParseUint(p, 10, 31)
+		if err != nil {
+			return nums, 0, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = int(n)
+		given++
+	}
+	return nums, given, nil
+}
+
+// compare gives the precedence of v relative to w, as defined by
+// semver: -1 if lower, 1 if higher, and 0 if the same.
+func (v version) compare(w version) int {
+	for _, d := range []int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		if c := compareIdentifiers(v.pre[i], w.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.pre) - len(w.pre))
+}
+
+// compareIdentifiers compares pre-release identifiers: numeric ones
+// numerically, and lower than alphanumeric ones, which are compared
+// lexically.
+func compareIdentifiers(a, b string) int {
+	m, aErr := strconv.ParseUint(a, 10, 64)
+	n, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case m < n:
+			return -1
+		case m > n:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// comparator is a single test of a version, like ">=1.2.0".
+type comparator struct {
+	op string
+	v  version
+}
+
+func (c comparator) test(v version) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// constraint is a semver range: any of a set of alternatives (given
+// separated by "||"), each of which is a set of comparators that must
+// all pass (given separated by spaces or commas). Comparators may use
+// the shorthands "^1.2" (compatible with 1.2, i.e., >=1.2.0 <2.0.0),
+// "~1.2" (>=1.2.0 <1.3.0), and wildcards, e.g., "1.2.x".
+type constraint struct {
+	alternatives [][]comparator
+	// Pre-release versions only match if a comparator mentions a
+	// pre-release; otherwise, ">=1.0" would let in "2.0.0-alpha".
+	pre bool
+}
+
+var operators = []string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, alt := range strings.Split(s, "||") {
+		var comparators []comparator
+		for _, term := range strings.Fields(strings.Replace(alt, ",", " ", -1)) {
+			cs, err := parseTerm(term)
+			if err != nil {
+				return c, err
+			}
+			for _, comp := range cs {
+				c.pre = c.pre || len(comp.v.pre) > 0
+			}
+			comparators = append(comparators, cs...)
+		}
+		if len(comparators) == 0 {
+			return c, fmt.Errorf("empty range in %q", s)
+		}
+		c.alternatives = append(c.alternatives, comparators)
+	}
+	return c, nil
+}
+
+// parseTerm expands a single term of a constraint into comparators.
+func parseTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, o := range operators {
+		if strings.HasPrefix(term, o) {
+			op = o
+			break
+		}
+	}
+	s := strings.TrimPrefix(strings.TrimPrefix(term, op), "v")
+	var pre []string
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v, err := parseVersion(s)
+		if err != nil {
+			return nil, err
+		}
+		pre, s = v.pre, s[:i]
+	}
+	nums, given, err := parseNumbers(s, true)
+	if err != nil {
+		return nil, err
+	}
+	if given < 3 && pre != nil {
+		return nil, fmt.Errorf("pre-release given without a full version in %q", term)
+	}
+	v := version{nums[0], nums[1], nums[2], pre}
+	lowest := comparator{">=", v}
+
+	// next gives the lowest version above those that have the same
+	// first n numbers as v
+	next := func(n int) comparator {
+		switch n {
+		case 0:
+			return comparator{">=", version{}}
+		case 1:
+			return comparator{"<", version{major: v.major + 1}}
+		case 2:
+			return comparator{"<", version{major: v.major, minor: v.minor + 1}}
+		}
+		return comparator{"<", version{major: v.major, minor: v.minor, patch: v.patch + 1}}
+	}
+
+	switch op {
+	case "^":
+		// Changes to the left-most non-zero number given are breaking
+		n := 1
+		switch {
+		case v.major == 0 && given >= 2 && v.minor == 0 && given == 3:
+			n = 3
+		case v.major == 0 && given >= 2:
+			n = 2
+		}
+		return []comparator{lowest, next(n)}, nil
+	case "~":
+		n := 2
+		if given < 2 {
+			n = 1
+		}
+		return []comparator{lowest, next(n)}, nil
+	case "", "=":
+		if given == 3 {
+			return []comparator{{"=", v}}, nil
+		}
+		return []comparator{lowest, next(given)}, nil
+	case "!=":
+		if given < 3 {
+			return nil, fmt.Errorf("wildcard not allowed with != in %q", term)
+		}
+	}
+	return []comparator{{op, v}}, nil
+}
+
+func (c constraint) matches(v version) bool {
+	if len(v.pre) > 0 && !c.pre {
+		return false
+	}
+	for _, alt := range c.alternatives {
+		ok := true
+		for _, comp := range alt {
+			if !comp.test(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
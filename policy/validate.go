@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/weaveworks/flux"
+)
+
+// known are the policies flux acts on (or records), besides those
+// with a prefix naming a container.
+var known = []Policy{
+	Ignore,
+	Locked,
+	Automated,
+	PullPolicy,
+	NotifyChannel,
+	RolloutOnConfigChange,
+	Paused,
+	AutomateWindow,
+	IgnoreContainers,
+	BlueGreen,
+	Prune,
+	Protected,
+	LockedUser,
+	LockedMsg,
+	LockedUntil,
+}
+
+// Known says whether a policy is one that flux knows about; either
+// one of the policies above, or a tag filter, or per-container
+// automation or locking, for a named container.
+func Known(p Policy) bool {
+	for _, k := range known {
+		if p == k {
+			return true
+		}
+	}
+	for _, prefix := range []string{TagPrefix, AutomatedPrefix, LockedPrefix} {
+		if strings.HasPrefix(string(p), prefix) && len(p) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the policies being added by the updates are
+// ones flux knows about, and that their values can be parsed, where
+// they have to be; e.g., tag filters, automation windows, and lock
+// expiries. The error, if there is one, is a flux.UserConfigProblem
+// saying what would be accepted instead.
+func (u Updates) Validate() error {
+	var ids []flux.ServiceID
+	for id := range u {
+		ids = append(ids, id)
+	}
+	flux.ServiceIDs(ids).Sort()
+	for _, id := range ids {
+		add := u[id].Add
+		for _, p := range add.sorted() {
+			if !Known(p) {
+				return unknownPolicy(id, p)
+			}
+		}
+		if err := add.validate(); err != nil {
+			return invalidPolicy(fmt.Errorf("%s: %s", id, err))
+		}
+	}
+	return nil
+}
+
+func (s Set) sorted() []Policy {
+	var ps []string
+	for p := range s {
+		ps = append(ps, string(p))
+	}
+	sort.Strings(ps)
+	var result []Policy
+	for _, p := range ps {
+		result = append(result, Policy(p))
+	}
+	return result
+}
+
+func unknownPolicy(id flux.ServiceID, p Policy) error {
+	var names []string
+	for _, k := range known {
+		names = append(names, "  "+string(k))
+	}
+	return flux.UserConfigProblem{&flux.BaseError{
+		Help: `Unknown policy
+
+A policy in the update isn't one that flux knows about, so adding it
+would have no effect. Check the spelling against the policies flux
+knows:
+
+` + strings.Join(names, "\n") + `
+
+as well as "tag.<container>" (or "tag.*") for a tag filter, and
+"automated.<container>" or "locked.<container>" for automating or
+locking a single container.
+`,
+		Err: fmt.Errorf("%s: unknown policy %q", id, p),
+	}}
+}
+
+func invalidPolicy(err error) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Help: `Invalid policy update
+
+A policy in the update has a value that can't be parsed; the error
+says which. A tag filter is a glob (optionally prefixed with
+"glob:"), a semver range prefixed with "semver:", or a regular
+expression prefixed with "regex:". An automation window is given as
+days, times and an optional time zone, e.g.,
+"Mon-Fri 09:00-17:00 UTC". The expiry of a lock, and the end of a
+pause, are times in RFC3339 format, e.g., "2017-09-11T17:00:00Z".
+The policies that are switched on or off (e.g., "automated", or
+"locked.<container>") take "true" or "false", and "pull-policy" takes
+"warn" or "fix".
+`,
+		Err: err,
+	}}
+}
@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestValidateUnknownPolicies(t *testing.T) {
+	id := flux.MakeServiceID("default", "helloworld")
+	for _, p := range []Policy{Automated, LockedUntil, "tag.greeter", TagAllContainers, "automated.greeter", "locked.greeter"} {
+		if !Known(p) {
+			t.Errorf("expected %s to be known", p)
+		}
+	}
+	for _, p := range []Policy{"automate", "tag.", "locked.", "tags.greeter"} {
+		if Known(p) {
+			t.Errorf("expected %s not to be known", p)
+		}
+	}
+
+	err := Updates{id: Update{Add: Set{"automate": "true"}}}.Validate()
+	if _, ok := err.(flux.UserConfigProblem); !ok {
+		t.Fatalf("expected a flux.UserConfigProblem, got %#v", err)
+	}
+	// Removing a policy that isn't there does no harm
+	if err := (Updates{id: Update{Remove: Set{"automate": "true"}}}).Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestValidatePolicyValues(t *testing.T) {
+	id := flux.MakeServiceID("default", "helloworld")
+	for _, s := range []Set{
+		{Automated: "yes"},
+		{Locked: ""},
+		{PullPolicy: "always"},
+		{Paused: "tomorrow"},
+		{LockedUntil: "2017-09-11"},
+		{AutomateWindow: "Mon-Fri"},
+	} {
+		err := Updates{id: Update{Add: s}}.Validate()
+		if _, ok := err.(flux.UserConfigProblem); !ok {
+			t.Errorf("%v: expected a flux.UserConfigProblem, got %#v", s, err)
+		}
+	}
+	for _, s := range []Set{
+		{Automated: "true"},
+		{Ignore: "false"},
+		{PullPolicy: PullPolicyFix},
+		{Paused: "2017-09-11T17:00:00Z"},
+		{Locked: "true", LockedUser: "jane", LockedMsg: "", LockedUntil: "2017-09-11T17:00:00Z"},
+		{NotifyChannel: "#releases", BlueGreen: "helloworld"},
+	} {
+		if err := (Updates{id: Update{Add: s}}).Validate(); err != nil {
+			t.Errorf("%v: expected no error, got %v", s, err)
+		}
+	}
+}
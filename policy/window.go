@@ -0,0 +1,173 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a weekly window of time, e.g., "Mon-Fri 09:00-17:00
+// UTC", as given in an `automate.window` policy. It's written as the
+// days (a comma-separated list of days or ranges of days, or "*" for
+// every day), the times it opens and closes, and optionally a time
+// zone (UTC if not given). If it closes before it opens, it runs past
+// midnight into the next day; e.g., "Fri 22:00-02:00" is open late on
+// Friday and early on Saturday.
+type Window struct {
+	spec        string
+	days        [7]bool // indexed by time.Weekday, for the day it opens
+	open, close int     // minutes into the day
+	loc         *time.Location
+}
+
+var dayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// ParseWindow parses a window as written in a policy.
+func ParseWindow(s string) (Window, error) {
+	w := Window{spec: s, loc: time.UTC}
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return w, fmt.Errorf("window %q is not of the form <days> <opens>-<closes> [<time zone>]", s)
+	}
+	if err := w.parseDays(fields[0]); err != nil {
+		return w, fmt.Errorf("window %q: %s", s, err)
+	}
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return w, fmt.Errorf("window %q: times must be given as <opens>-<closes>", s)
+	}
+	var err error
+	if w.open, err = parseTimeOfDay(times[0]); err != nil {
+		return w, fmt.Errorf("window %q: %s", s, err)
+	}
+	if w.close, err = parseTimeOfDay(times[1]); err != nil {
+		return w, fmt.Errorf("window %q: %s", s, err)
+	}
+	if w.open == w.close {
+		return w, fmt.Errorf("window %q opens and closes at the same time", s)
+	}
+	if len(fields) == 3 {
+		if w.loc, err = time.LoadLocation(fields[2]); err != nil {
+			return w, fmt.Errorf("window %q: unknown time zone %q", s, fields[2])
+		}
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(s string) error {
+	if s == "*" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+		return nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		ends := strings.Split(part, "-")
+		if len(ends) > 2 {
+			return fmt.Errorf("bad range of days %q", part)
+		}
+		first, err := parseDay(ends[0])
+		if err != nil {
+			return err
+		}
+		last := first
+		if len(ends) == 2 {
+			if last, err = parseDay(ends[1]); err != nil {
+				return err
+			}
+		}
+		// A range can go over the end of the week, e.g., Fri-Mon
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseDay accepts the name of a day, or any abbreviation of it of
+// at least three letters.
+func parseDay(s string) (int, error) {
+	s = strings.ToLower(s)
+	if len(s) >= 3 {
+		for i, name := range dayNames {
+			if strings.HasPrefix(name, s) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", s)
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("time %q is not of the form HH:MM", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("bad hour in %q", s)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("bad minute in %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// Contains says whether the window is open at the time given.
+func (w Window) Contains(t time.Time) bool {
+	if w.loc == nil {
+		return false
+	}
+	t = t.In(w.loc)
+	day, minute := t.Weekday(), t.Hour()*60+t.Minute()
+	if w.open < w.close {
+		return w.days[day] && minute >= w.open && minute < w.close
+	}
+	// It runs past midnight, so it may have opened yesterday
+	return (w.days[day] && minute >= w.open) || (w.days[(day+6)%7] && minute < w.close)
+}
+
+// NextOpen gives the time given if the window is open then, or
+// otherwise when it next opens; or the zero time, if it never does.
+func (w Window) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	if w.loc == nil {
+		return time.Time{}
+	}
+	local := t.In(w.loc)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		opens := time.Date(day.Year(), day.Month(), day.Day(), w.open/60, w.open%60, 0, 0, w.loc)
+		if w.days[opens.Weekday()] && opens.After(t) {
+			return opens
+		}
+	}
+	return time.Time{}
+}
+
+func (w Window) String() string {
+	return w.spec
+}
+
+// AutomationWindow gives the window in which automated releases of a
+// service may happen, if it has one. A window that can't be parsed
+// never opens, so that a mistake in the policy doesn't let
+// automation run at any time.
+func (s Set) AutomationWindow() (Window, bool) {
+	v, ok := s[AutomateWindow]
+	if !ok {
+		return Window{}, false
+	}
+	w, err := ParseWindow(v)
+	if err != nil {
+		return Window{spec: v}, true
+	}
+	return w, true
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestParseWindow(t *testing.T) {
+	for _, s := range []string{
+		"Mon-Fri 09:00-17:00 UTC",
+		"mon,wed,friday 09:00-17:00",
+		"Fri-Mon 22:00-06:00 Europe/London",
+		"* 00:00-24:00",
+	} {
+		if _, err := ParseWindow(s); err != nil {
+			t.Errorf("%q: expected no error, got %v", s, err)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"Mon-Fri",
+		"Mon-Fri 09:00",
+		"Mo 09:00-17:00",
+		"Mon-Fri 9-17",
+		"Mon-Fri 09:00-25:00",
+		"Mon-Fri 09:00-09:00",
+		"Mon-Fri 09:00-17:00 Nowhere/Special",
+		"Mon-Fri 09:00-17:00 UTC extra",
+	} {
+		if _, err := ParseWindow(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	// 2017-09-04 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2017, 9, day, hour, minute, 0, 0, time.UTC)
+	}
+	for spec, cases := range map[string]map[time.Time]bool{
+		"Mon-Fri 09:00-17:00 UTC": {
+			at(4, 9, 0): true, at(4, 16, 59): true, at(4, 17, 0): false, at(4, 8, 59): false,
+			at(8, 12, 0): true, at(9, 12, 0): false, at(10, 12, 0): false,
+		},
+		"Fri 22:00-02:00": {
+			at(8, 23, 0): true, at(9, 1, 59): true, at(9, 2, 0): false, at(8, 1, 0): false,
+		},
+		"Sat-Mon 10:00-11:00": {
+			at(9, 10, 30): true, at(10, 10, 30): true, at(4, 10, 30): true, at(5, 10, 30): false,
+		},
+		"Mon 09:00-17:00 America/New_York": {
+			at(4, 13, 0): true, at(4, 12, 59): false, at(4, 20, 59): true, at(4, 21, 0): false,
+		},
+	} {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for when, expected := range cases {
+			if w.Contains(when) != expected {
+				t.Errorf("%s at %s: expected open to be %v", spec, when.Format(time.RFC1123), expected)
+			}
+		}
+	}
+}
+
+func TestWindowNextOpen(t *testing.T) {
+	w, err := ParseWindow("Mon-Fri 09:00-17:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for from, expected := range map[time.Time]time.Time{
+		// open already
+		time.Date(2017, 9, 4, 10, 0, 0, 0, time.UTC): time.Date(2017, 9, 4, 10, 0, 0, 0, time.UTC),
+		// later the same day
+		time.Date(2017, 9, 4, 7, 0, 0, 0, time.UTC): time.Date(2017, 9, 4, 9, 0, 0, 0, time.UTC),
+		// Friday evening, so Monday morning
+		time.Date(2017, 9, 8, 18, 0, 0, 0, time.UTC): time.Date(2017, 9, 11, 9, 0, 0, 0, time.UTC),
+	} {
+		if got := w.NextOpen(from); !got.Equal(expected) {
+			t.Errorf("from %s: expected %s, got %s", from, expected, got)
+		}
+	}
+}
+
+func TestAutomationWindow(t *testing.T) {
+	if _, ok := (Set{}).AutomationWindow(); ok {
+		t.Error("expected no window without the policy")
+	}
+	w, ok := Set{AutomateWindow: "Mon-Fri"}.AutomationWindow()
+	if !ok {
+		t.Fatal("expected a window")
+	}
+	now := time.Date(2017, 9, 4, 10, 0, 0, 0, time.UTC)
+	if w.Contains(now) || !w.NextOpen(now).IsZero() {
+		t.Error("expected a window that can't be parsed never to open")
+	}
+
+	id := flux.MakeServiceID("default", "helloworld")
+	if err := (Updates{id: Update{Add: Set{AutomateWindow: "Mon-Fri"}}}).Validate(); err == nil {
+		t.Error("expected an update with a bad window not to validate")
+	}
+	if err := (Updates{id: Update{Add: Set{AutomateWindow: "Mon-Fri 09:00-17:00"}}}).Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+// Package redact takes things that shouldn't be kept -- tokens,
+// passwords, whatever else a deployment deems sensitive -- out of
+// what is logged, and out of events before they are stored or sent
+// on as notifications.
+package redact
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/history"
+)
+
+// Mask is put in place of whatever is redacted.
+const Mask = "REDACTED"
+
+// Rules say what to redact. A nil *Rules redacts nothing.
+type Rules struct {
+	headers  map[string]bool
+	params   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// New makes rules that redact the values of the HTTP headers and URL
+// query parameters named, and any text matching the regular
+// expressions given. If an expression has groups in it, only the
+// text matched by the groups is redacted; e.g., `password=(\S+)`
+// leaves "password=" in place.
+func New(headers, params, patterns []string) (*Rules, error) {
+	r := &Rules{
+		headers: map[string]bool{},
+		params:  map[string]bool{},
+	}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range params {
+		r.params[p] = true
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling redaction pattern %q", p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// String redacts the text matching any of the patterns.
+func (r *Rules) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = redactMatches(re, s)
+	}
+	return s
+}
+
+func redactMatches(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		// The whole match, or if there are groups, each group
+		spans := m[:2]
+		if len(m) > 2 {
+			spans = m[2:]
+		}
+		for i := 0; i+1 < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			if start < last { // unmatched (-1), or nested in a group already done
+				continue
+			}
+			out = append(out, s[last:start]...)
+			out = append(out, Mask...)
+			last = end
+		}
+	}
+	return string(append(out, s[last:]...))
+}
+
+// Header gives the value of a header as it may be logged: masked if
+// it's one of those to redact, and otherwise with the patterns
+// applied.
+func (r *Rules) Header(name, value string) string {
+	if r == nil {
+		return value
+	}
+	if r.headers[http.CanonicalHeaderKey(name)] {
+		return Mask
+	}
+	return r.String(value)
+}
+
+// URL gives the URL as it may be logged, with the values of query
+// parameters to be redacted masked, and the patterns applied.
+func (r *Rules) URL(u *url.URL) string {
+	if r == nil {
+		return u.String()
+	}
+	if len(r.params) > 0 && u.RawQuery != "" {
+		query := u.Query()
+		changed := false
+		for name, values := range query {
+			if !r.params[name] {
+				continue
+			}
+			for i := range values {
+				values[i] = Mask
+			}
+			changed = true
+		}
+		if changed {
+			redacted := *u
+			redacted.RawQuery = query.Encode()
+			u = &redacted
+		}
+	}
+	return r.String(u.String())
+}
+
+// Event gives the event with the patterns applied to all the text in
+// it, including its metadata.
+func (r *Rules) Event(e history.Event) (history.Event, error) {
+	if r == nil || len(r.patterns) == 0 {
+		return e, nil
+	}
+	// Going via JSON reaches every field of every kind of metadata,
+	// and it's how events get here from the daemon anyway.
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return e, errors.Wrap(err, "encoding event to redact")
+	}
+	var fields interface{}
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return e, errors.Wrap(err, "decoding event to redact")
+	}
+	fields, changed := r.redactJSON(fields)
+	if !changed {
+		return e, nil
+	}
+	if bytes, err = json.Marshal(fields); err != nil {
+		return e, errors.Wrap(err, "encoding redacted event")
+	}
+	var redacted history.Event
+	if err := json.Unmarshal(bytes, &redacted); err != nil {
+		return e, errors.Wrap(err, "decoding redacted event")
+	}
+	return redacted, nil
+}
+
+func (r *Rules) redactJSON(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		redacted := r.String(v)
+		return redacted, redacted != v
+	case []interface{}:
+		changed := false
+		for i := range v {
+			var c bool
+			v[i], c = r.redactJSON(v[i])
+			changed = changed || c
+		}
+		return v, changed
+	case map[string]interface{}:
+		changed := false
+		for k := range v {
+			var c bool
+			v[k], c = r.redactJSON(v[k])
+			changed = changed || c
+		}
+		return v, changed
+	}
+	return v, false
+}
@@ -0,0 +1,94 @@
+package redact
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestString(t *testing.T) {
+	r, err := New(nil, nil, []string{`xoxb-[0-9a-z-]+`, `password=(\S+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, expected := range map[string]string{
+		"nothing to see here":                       "nothing to see here",
+		"slack token xoxb-1234-abcd, and xoxb-5678": "slack token REDACTED, and REDACTED",
+		"login with password=hunter2 please":        "login with password=REDACTED please",
+	} {
+		if got := r.String(in); got != expected {
+			t.Errorf("%q: expected %q, got %q", in, expected, got)
+		}
+	}
+
+	if _, err := New(nil, nil, []string{"("}); err == nil {
+		t.Error("expected error for bad pattern")
+	}
+	var none *Rules
+	if got := none.String("password=hunter2"); got != "password=hunter2" {
+		t.Errorf("expected nil rules to redact nothing, got %q", got)
+	}
+}
+
+func TestHeaderAndURL(t *testing.T) {
+	r, err := New([]string{"x-flux-user"}, []string{"token"}, []string{`secret-\w+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Header("X-Flux-User", "alice"); got != Mask {
+		t.Errorf("expected header to be masked, got %q", got)
+	}
+	if got := r.Header("X-Request-Id", "secret-abc"); got != Mask {
+		t.Errorf("expected pattern to apply to header, got %q", got)
+	}
+
+	u, _ := url.Parse("/v6/images?service=default/helloworld&token=abc123")
+	if got, expected := r.URL(u), "/v6/images?service=default%2Fhelloworld&token=REDACTED"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	u, _ = url.Parse("/v6/webhooks/secret-hook")
+	if got, expected := r.URL(u), "/v6/webhooks/REDACTED"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	r, err := New(nil, nil, []string{`password=(\S+)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	e := history.Event{
+		Type:       history.EventRelease,
+		ServiceIDs: []flux.ServiceID{flux.MakeServiceID("default", "helloworld")},
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   history.LogLevelInfo,
+		Metadata: &history.ReleaseEventMetadata{
+			Cause: update.Cause{User: "alice", Message: "rolling back; password=hunter2 leaked"},
+			Error: "could not log in with password=hunter2",
+		},
+	}
+	redacted, err := r.Event(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, ok := redacted.Metadata.(*history.ReleaseEventMetadata)
+	if !ok {
+		t.Fatalf("expected release metadata, got %T", redacted.Metadata)
+	}
+	if md.Cause.Message != "rolling back; password=REDACTED leaked" || md.Error != "could not log in with password=REDACTED" {
+		t.Errorf("expected metadata to be redacted, got %+v", md)
+	}
+	if md.Cause.User != "alice" || !redacted.StartedAt.Equal(now) || redacted.ServiceIDs[0] != e.ServiceIDs[0] {
+		t.Errorf("expected the rest of the event to be as it was, got %+v", redacted)
+	}
+	// The original is left alone
+	if e.Metadata.(*history.ReleaseEventMetadata).Error != "could not log in with password=hunter2" {
+		t.Error("expected original event to be unchanged")
+	}
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+)
+
+// TagAliases gives, for each repository, the tags that are moved from
+// image to image (e.g., "stable"), rather than naming a particular
+// image. The keys are repositories as given by
+// flux.ImageID.Repository().
+type TagAliases map[string][]string
+
+// ParseTagAliases parses aliases given as images, e.g.,
+// "quay.io/weaveworks/helloworld:stable".
+func ParseTagAliases(images []string) (TagAliases, error) {
+	aliases := TagAliases{}
+	for _, s := range images {
+		id, err := flux.ParseImageID(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing tag alias %q", s)
+		}
+		if id.Digest != "" {
+			return nil, fmt.Errorf("tag alias %q must be a tag, not a digest", s)
+		}
+		aliases[id.Repository()] = append(aliases[id.Repository()], id.Tag)
+	}
+	return aliases, nil
+}
+
+// IsAlias says whether the image given is named by an alias tag.
+func (a TagAliases) IsAlias(id flux.ImageID) bool {
+	if id.Digest != "" {
+		return false
+	}
+	for _, tag := range a[id.Repository()] {
+		if tag == id.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+type aliasingRegistry struct {
+	next    Registry
+	aliases TagAliases
+}
+
+// NewAliasingRegistry wraps a registry so that alias tags are never
+// given as images of a repository, and asking for an image by an
+// alias gives the image with the concrete tag the alias points at.
+// That way, the alias itself never ends up in a manifest.
+func NewAliasingRegistry(next Registry, aliases TagAliases) Registry {
+	return &aliasingRegistry{
+		next:    next,
+		aliases: aliases,
+	}
+}
+
+func (r *aliasingRegistry) GetRepository(id flux.ImageID) ([]flux.Image, error) {
+	images, err := r.next.GetRepository(id)
+	if err != nil {
+		return nil, err
+	}
+	var concrete []flux.Image
+	for _, image := range images {
+		if !r.aliases.IsAlias(image.ID) {
+			concrete = append(concrete, image)
+		}
+	}
+	return concrete, nil
+}
+
+// GetImage gives the image named; or, if it's named by an alias, the
+// most recent image with a concrete tag that has the same content.
+func (r *aliasingRegistry) GetImage(id flux.ImageID) (flux.Image, error) {
+	image, err := r.next.GetImage(id)
+	if err != nil || !r.aliases.IsAlias(id) {
+		return image, err
+	}
+	if image.ContentID == "" {
+		return flux.Image{}, fmt.Errorf("cannot resolve tag alias %s, since the registry gives no content ID for it", id)
+	}
+	images, err := r.GetRepository(id)
+	if err != nil {
+		return flux.Image{}, errors.Wrapf(err, "resolving tag alias %s", id)
+	}
+	for _, candidate := range images {
+		if candidate.ContentID == image.ContentID {
+			return candidate, nil
+		}
+	}
+	return flux.Image{}, fmt.Errorf("cannot resolve tag alias %s, since no other tag points at the same image", id)
+}
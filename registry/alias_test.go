@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+func aliasTestImage(t *testing.T, s, contentID string, created time.Time) flux.Image {
+	id, err := flux.ParseImageID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flux.Image{ID: id, ContentID: contentID, CreatedAt: created}
+}
+
+func TestParseTagAliases(t *testing.T) {
+	aliases, err := ParseTagAliases([]string{"quay.io/weaveworks/helloworld:stable", "alpine:stable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"quay.io/weaveworks/helloworld:stable", "index.docker.io/library/alpine:stable"} {
+		id, _ := flux.ParseImageID(s)
+		if !aliases.IsAlias(id) {
+			t.Errorf("expected %s to be an alias", s)
+		}
+	}
+	for _, s := range []string{"quay.io/weaveworks/helloworld:v1", "quay.io/weaveworks/sidecar:stable"} {
+		id, _ := flux.ParseImageID(s)
+		if aliases.IsAlias(id) {
+			t.Errorf("expected %s not to be an alias", s)
+		}
+	}
+
+	if _, err := ParseTagAliases([]string{"alpine@sha256:abc"}); err == nil {
+		t.Error("expected error for an alias given as a digest")
+	}
+}
+
+func TestAliasingRegistry(t *testing.T) {
+	now := time.Now()
+	images := []flux.Image{
+		aliasTestImage(t, "quay.io/weaveworks/helloworld:stable", "b", now.Add(-time.Hour)),
+		aliasTestImage(t, "quay.io/weaveworks/helloworld:v2", "c", now),
+		aliasTestImage(t, "quay.io/weaveworks/helloworld:v1", "b", now.Add(-time.Hour)),
+		aliasTestImage(t, "quay.io/weaveworks/helloworld:v0", "a", now.Add(-2*time.Hour)),
+		aliasTestImage(t, "quay.io/weaveworks/helloworld:broken", "", now),
+	}
+	aliases, _ := ParseTagAliases([]string{
+		"quay.io/weaveworks/helloworld:stable",
+		"quay.io/weaveworks/helloworld:broken",
+		"quay.io/weaveworks/helloworld:missing",
+	})
+	reg := NewAliasingRegistry(NewMockRegistry(images, nil), aliases)
+
+	repo, err := reg.GetRepository(images[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, image := range repo {
+		if aliases.IsAlias(image.ID) {
+			t.Errorf("expected alias %s not to be given in repository", image.ID)
+		}
+	}
+	if len(repo) != 3 {
+		t.Errorf("expected 3 concrete images, got %d", len(repo))
+	}
+
+	image, err := reg.GetImage(images[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ID.Tag != "v1" {
+		t.Errorf("expected alias to resolve to v1, got %s", image.ID)
+	}
+
+	image, err = reg.GetImage(images[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.ID != images[1].ID {
+		t.Errorf("expected concrete tag to be given as is, got %s", image.ID)
+	}
+
+	for _, s := range []string{"quay.io/weaveworks/helloworld:broken", "quay.io/weaveworks/helloworld:missing"} {
+		id, _ := flux.ParseImageID(s)
+		if _, err := reg.GetImage(id); err == nil {
+			t.Errorf("expected error resolving %s", s)
+		}
+	}
+}
@@ -234,7 +234,7 @@ type manifestKey struct {
 }
 
 func NewManifestKey(username string, id flux.ImageID) (Keyer, error) {
-	return &manifestKey{username, id.HostNamespaceImage(), id.Tag}, nil
+	return &manifestKey{username, id.HostNamespaceImage(), id.Reference()}, nil
 }
 
 func (k *manifestKey) Key() string {
@@ -4,20 +4,16 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
-
 	fluxmetrics "github.com/weaveworks/flux/metrics"
 )
 
 var (
-	memcacheRequestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	memcacheRequestDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "memcache",
 		Name:      "request_duration_seconds",
 		Help:      "Duration of memcache requests, in seconds.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess})
+	}, fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess)
 )
 
 type instrumentedMemcacheClient struct {
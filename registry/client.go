@@ -37,7 +37,7 @@ func (a *Remote) Tags(id flux.ImageID) ([]string, error) {
 // We need to do some adapting here to convert from the return values
 // from dockerregistry to our domain types.
 func (a *Remote) Manifest(id flux.ImageID) (flux.Image, error) {
-	history, err := a.Registry.Manifest(id.NamespaceImage(), id.Tag)
+	history, err := a.Registry.Manifest(id.NamespaceImage(), id.Reference())
 	if err != nil || history == nil {
 		return flux.Image{}, errors.Wrap(err, "getting remote manifest")
 	}
@@ -46,9 +46,13 @@ func (a *Remote) Manifest(id flux.ImageID) (flux.Image, error) {
 	// oddly called "History", which are layer metadata as JSON
 	// strings; these appear most-recent (i.e., topmost layer) first,
 	// so happily we can just decode the first entry to get a created
-	// time.
+	// time, the labels it was built with, and an ID for its content.
 	type v1image struct {
+		ID      string    `json:"id"`
 		Created time.Time `json:"created"`
+		Config  struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
 	}
 	var topmost v1image
 	var img flux.Image
@@ -58,6 +62,8 @@ func (a *Remote) Manifest(id flux.ImageID) (flux.Image, error) {
 			if !topmost.Created.IsZero() {
 				img.CreatedAt = topmost.Created
 			}
+			img.Labels = topmost.Config.Labels
+			img.ContentID = topmost.ID
 		}
 	}
 
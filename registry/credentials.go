@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/url"
 	"strings"
+	"sync"
 )
 
 // Registry Credentials
@@ -17,9 +18,59 @@ type creds struct {
 
 // Credentials to a (Docker) registry.
 type Credentials struct {
+	m          map[string]creds
+	configured *ConfiguredCredentials
+}
+
+// ConfiguredCredentials are credentials given in the instance config,
+// which may change while the daemon is running. Credentials made
+// with WithConfigured look in them first, and see each change as
+// it's made. The zero value is ready to use.
+type ConfiguredCredentials struct {
+	sync.RWMutex
 	m map[string]creds
 }
 
+// Auth is a username and password for a registry.
+type Auth struct {
+	Username, Password string
+}
+
+// Set replaces the configured credentials with those given, for each
+// host.
+func (c *ConfiguredCredentials) Set(auths map[string]Auth) {
+	m := map[string]creds{}
+	for host, auth := range auths {
+		m[host] = creds{username: auth.Username, password: auth.Password}
+	}
+	c.Lock()
+	c.m = m
+	c.Unlock()
+}
+
+func (c *ConfiguredCredentials) get(host string) (creds, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	cred, ok := c.m[host]
+	return cred, ok
+}
+
+func (c *ConfiguredCredentials) hosts() []string {
+	c.RLock()
+	defer c.RUnlock()
+	var hosts []string
+	for host := range c.m {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// WithConfigured gives the credentials, with those configured for
+// the instance taking precedence for any host they're given for.
+func (cs Credentials) WithConfigured(c *ConfiguredCredentials) Credentials {
+	return Credentials{m: cs.m, configured: c}
+}
+
 // NoCredentials returns a usable but empty credentials object.
 func NoCredentials() Credentials {
 	return Credentials{
@@ -86,6 +137,11 @@ func CredentialsFromFile(path string) (Credentials, error) {
 
 // For yields an authenticator for a specific host.
 func (cs Credentials) credsFor(host string) creds {
+	if cs.configured != nil {
+		if cred, found := cs.configured.get(host); found {
+			return cred
+		}
+	}
 	if cred, found := cs.m[host]; found {
 		return cred
 	}
@@ -103,5 +159,12 @@ func (cs Credentials) Hosts() []string {
 	for host := range cs.m {
 		hosts = append(hosts, host)
 	}
+	if cs.configured != nil {
+		for _, host := range cs.configured.hosts() {
+			if _, ok := cs.m[host]; !ok {
+				hosts = append(hosts, host)
+			}
+		}
+	}
 	return hosts
 }
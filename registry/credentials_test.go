@@ -157,3 +157,38 @@ func TestRemoteFactory_ParseHost(t *testing.T) {
 		}
 	}
 }
+
+func TestConfiguredCredentials(t *testing.T) {
+	file, cleanup := writeCreds(t, fmt.Sprintf(tmpl, host, okCreds))
+	defer cleanup()
+	fromFile, err := CredentialsFromFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configured := &ConfiguredCredentials{}
+	creds := fromFile.WithConfigured(configured)
+	if c := creds.credsFor(host); c.username != user {
+		t.Fatalf("Expected %q, got %q.", user, c.username)
+	}
+
+	// Changes are seen by the credentials already made
+	configured.Set(map[string]Auth{
+		host:      {Username: "configured", Password: "secret"},
+		"quay.io": {Username: "robot", Password: "token"},
+	})
+	if c := creds.credsFor(host); c.username != "configured" || c.password != "secret" {
+		t.Fatalf("Expected configured credentials to take precedence, got %+v.", c)
+	}
+	if c := creds.credsFor("quay.io"); c.username != "robot" {
+		t.Fatalf("Expected %q, got %q.", "robot", c.username)
+	}
+	if len(creds.Hosts()) != 2 {
+		t.Fatalf("Expected two hosts, got %v.", creds.Hosts())
+	}
+
+	configured.Set(nil)
+	if c := creds.credsFor(host); c.username != user {
+		t.Fatalf("Expected %q, got %q.", user, c.username)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/flux"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
 )
@@ -18,19 +16,18 @@ const (
 )
 
 var (
-	registryDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	registryDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "registry",
 		Name:      "fetch_duration_seconds",
 		Help:      "Duration of image metadata requests (from cache), in seconds.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelSuccess})
-	remoteDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	}, fluxmetrics.LabelSuccess)
+	remoteDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "client",
 		Name:      "fetch_duration_seconds",
 		Help:      "Duration of remote image metadata requests, in seconds",
-	}, []string{LabelRequestKind, fluxmetrics.LabelSuccess})
+	}, LabelRequestKind, fluxmetrics.LabelSuccess)
 )
 
 type InstrumentedRegistry Registry
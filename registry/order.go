@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TagOrderer ranks the tags of a repository, most preferred first, for
+// when how the tags are made up means neither their names nor when
+// the images were built says which is newest. Tags it leaves out of
+// the ranking are not wanted at all.
+type TagOrderer interface {
+	OrderTags(repo string, tags []string) ([]string, error)
+}
+
+const execOrdererPrefix = "exec:"
+
+// NewTagOrderer makes an orderer from its description, which is
+// either the URL of a webhook, or "exec:" followed by a command. A
+// webhook is POSTed
+//
+//	{"repository": "<repo>", "tags": ["<tag>", ...]}
+//
+// and answers with the tags ranked, in the same form. A command is
+// run with the repository as its last argument, and given the tags
+// on stdin, one per line; it prints them ranked, one per line. Either
+// has as long as the timeout given to answer.
+func NewTagOrderer(desc string, timeout time.Duration) (TagOrderer, error) {
+	if strings.HasPrefix(desc, execOrdererPrefix) {
+		args := strings.Fields(strings.TrimPrefix(desc, execOrdererPrefix))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no command given for tag orderer %q", desc)
+		}
+		return &execOrderer{args: args, timeout: timeout}, nil
+	}
+	if !strings.HasPrefix(desc, "http://") && !strings.HasPrefix(desc, "https://") {
+		return nil, fmt.Errorf(`tag orderer %q is neither an http(s) URL nor "exec:<command>"`, desc)
+	}
+	return &webhookOrderer{url: desc, client: &http.Client{Timeout: timeout}}, nil
+}
+
+type execOrderer struct {
+	args    []string
+	timeout time.Duration
+}
+
+func (o *execOrderer) OrderTags(repo string, tags []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, o.args[0], append(o.args[1:], repo)...)
+	cmd.Stdin = strings.NewReader(strings.Join(tags, "\n") + "\n")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("tag orderer %s timed out after %s", o.args[0], o.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("tag orderer %s: %s", o.args[0], msg)
+		}
+		return nil, errors.Wrapf(err, "running tag orderer %s", o.args[0])
+	}
+	var ranked []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if tag := strings.TrimSpace(line); tag != "" {
+			ranked = append(ranked, tag)
+		}
+	}
+	return ranked, nil
+}
+
+type webhookOrderer struct {
+	url    string
+	client *http.Client
+}
+
+type tagOrdering struct {
+	Repository string   `json:"repository,omitempty"`
+	Tags       []string `json:"tags"`
+}
+
+func (o *webhookOrderer) OrderTags(repo string, tags []string) ([]string, error) {
+	body, err := json.Marshal(tagOrdering{Repository: repo, Tags: tags})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Post(o.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "calling tag orderer")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tag orderer responded with %s", resp.Status)
+	}
+	var ranked tagOrdering
+	if err := json.NewDecoder(resp.Body).Decode(&ranked); err != nil {
+		return nil, errors.Wrap(err, "decoding response from tag orderer")
+	}
+	return ranked.Tags, nil
+}
+
+type orderingEntry struct {
+	ranked  []string
+	expires time.Time
+}
+
+type cachingOrderer struct {
+	next TagOrderer
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]orderingEntry
+}
+
+// NewCachingTagOrderer wraps an orderer so that asking again to rank
+// the same tags of a repository gets the same answer, without asking
+// the orderer, until the time given has passed. Failures are not
+// kept.
+func NewCachingTagOrderer(next TagOrderer, ttl time.Duration) TagOrderer {
+	return &cachingOrderer{
+		next:    next,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]orderingEntry{},
+	}
+}
+
+func (o *cachingOrderer) OrderTags(repo string, tags []string) ([]string, error) {
+	key := repo + "\n" + strings.Join(tags, "\n")
+	now := o.now()
+
+	o.mu.Lock()
+	entry, ok := o.entries[key]
+	// Sweep out whatever has expired, so tags that are no longer
+	// asked about don't pile up
+	for k, e := range o.entries {
+		if !now.Before(e.expires) {
+			delete(o.entries, k)
+		}
+	}
+	o.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.ranked, nil
+	}
+
+	ranked, err := o.next.OrderTags(repo, tags)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	o.entries[key] = orderingEntry{ranked: ranked, expires: now.Add(o.ttl)}
+	o.mu.Unlock()
+	return ranked, nil
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecTagOrderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-tag-orderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "order.sh")
+	// Drop the tags of other repositories, and rank the rest in
+	// reverse
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\n[ \"$1\" = helloworld ] && sort -r\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	orderer, err := NewTagOrderer("exec:"+script, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranked, err := orderer.OrderTags("helloworld", []string{"v1", "v3", "v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"v3", "v2", "v1"}; !reflect.DeepEqual(expected, ranked) {
+		t.Errorf("expected %v, got %v", expected, ranked)
+	}
+	if _, err := orderer.OrderTags("other", []string{"v1"}); err == nil {
+		t.Error("expected an error when the command fails")
+	}
+
+	slow, _ := NewTagOrderer("exec:sleep 10", 10*time.Millisecond)
+	if _, err := slow.OrderTags("helloworld", []string{"v1"}); err == nil {
+		t.Error("expected an error when the command takes too long")
+	}
+}
+
+func TestWebhookTagOrderer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tagOrdering
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Repository != "helloworld" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(tagOrdering{Tags: []string{req.Tags[len(req.Tags)-1], req.Tags[0]}})
+	}))
+	defer server.Close()
+
+	orderer, err := NewTagOrderer(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranked, err := orderer.OrderTags("helloworld", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"c", "a"}; !reflect.DeepEqual(expected, ranked) {
+		t.Errorf("expected %v, got %v", expected, ranked)
+	}
+	if _, err := orderer.OrderTags("other", []string{"a"}); err == nil {
+		t.Error("expected an error when the webhook responds with an error")
+	}
+
+	for _, desc := range []string{"exec:", "order.sh", "ftp://example.com/order"} {
+		if _, err := NewTagOrderer(desc, time.Second); err == nil {
+			t.Errorf("expected error for tag orderer %q", desc)
+		}
+	}
+}
+
+type countingOrderer int
+
+func (c *countingOrderer) OrderTags(repo string, tags []string) ([]string, error) {
+	*c++
+	return tags, nil
+}
+
+func TestCachingTagOrderer(t *testing.T) {
+	var calls countingOrderer
+	now := time.Now()
+	orderer := NewCachingTagOrderer(&calls, time.Minute).(*cachingOrderer)
+	orderer.now = func() time.Time { return now }
+
+	orderer.OrderTags("helloworld", []string{"v1", "v2"})
+	orderer.OrderTags("helloworld", []string{"v1", "v2"})
+	if calls != 1 {
+		t.Errorf("expected the same tags to be ranked once, got %d calls", calls)
+	}
+	orderer.OrderTags("helloworld", []string{"v1", "v2", "v3"})
+	orderer.OrderTags("sidecar", []string{"v1", "v2"})
+	if calls != 3 {
+		t.Errorf("expected new tags, or another repository, to be ranked afresh; got %d calls", calls)
+	}
+	now = now.Add(2 * time.Minute)
+	orderer.OrderTags("helloworld", []string{"v1", "v2"})
+	if calls != 4 {
+		t.Errorf("expected a ranking to expire; got %d calls", calls)
+	}
+}
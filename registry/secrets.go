@@ -0,0 +1,32 @@
+package registry
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SecretStore looks up the secrets that config refers to, so that
+// the config itself needn't hold them; e.g., the password for a
+// registry given in the instance config.
+type SecretStore interface {
+	Secret(ref string) (string, error)
+}
+
+// SecretsDir is a SecretStore that keeps each secret in a file in a
+// directory, named by the reference; e.g., a Kubernetes Secret
+// mounted as a volume, with a key for each secret.
+type SecretsDir string
+
+func (d SecretsDir) Secret(ref string) (string, error) {
+	if ref == "" || ref != filepath.Base(ref) || strings.HasPrefix(ref, ".") {
+		return "", errors.Errorf("secret reference %q is not the name of a file", ref)
+	}
+	bytes, err := ioutil.ReadFile(filepath.Join(string(d), ref))
+	if err != nil {
+		return "", errors.Wrapf(err, "reading secret %q", ref)
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
@@ -0,0 +1,29 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "quay-password"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := SecretsDir(dir)
+	if s, err := secrets.Secret("quay-password"); err != nil || s != "s3cr3t" {
+		t.Errorf("expected %q, got %q (err %v)", "s3cr3t", s, err)
+	}
+	for _, ref := range []string{"", "missing", "../quay-password", "/etc/passwd", ".hidden"} {
+		if _, err := secrets.Secret(ref); err == nil {
+			t.Errorf("expected an error for %q", ref)
+		}
+	}
+}
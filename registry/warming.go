@@ -26,6 +26,9 @@ type Warmer struct {
 	Writer        cache.Writer
 	Reader        cache.Reader
 	Burst         int
+	// Alias tags get moved from image to image, so their manifests
+	// are fetched every time, rather than only when they expire.
+	Aliases TagAliases
 }
 
 // Continuously get the images to populate the cache with, and
@@ -100,6 +103,10 @@ func (w *Warmer) warm(id flux.ImageID) {
 		// See if we have the manifest already cached
 		// We don't want to re-download a manifest again.
 		i := id.WithNewTag(tag)
+		if w.Aliases.IsAlias(i) {
+			toUpdate = append(toUpdate, i)
+			continue
+		}
 		key, err := cache.NewManifestKey(username, i)
 		if err != nil {
 			w.Logger.Log("err", errors.Wrap(err, "creating key for memcache"))
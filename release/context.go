@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/git"
@@ -56,6 +59,28 @@ func (rc *ReleaseContext) WriteUpdates(updates []*update.ServiceUpdate) error {
 	return err
 }
 
+// ValidateUpdates checks each of the updated manifests against the
+// file as it is in the repo, before anything is written; so if one of
+// them is broken, none of them is written.
+func (rc *ReleaseContext) ValidateUpdates(updates []*update.ServiceUpdate) error {
+	rc.repo.Lock()
+	defer rc.repo.Unlock()
+	for _, u := range updates {
+		before, err := ioutil.ReadFile(u.ManifestPath)
+		if err != nil {
+			return err
+		}
+		if err := rc.manifests.ValidateUpdate(before, u.ManifestBytes); err != nil {
+			path := u.ManifestPath
+			if rel, err := filepath.Rel(rc.repo.ManifestDir(), path); err == nil {
+				path = rel
+			}
+			return errors.Wrapf(err, "not releasing %s, since the change to %s failed validation", u.ServiceID, path)
+		}
+	}
+	return nil
+}
+
 // ---
 
 // SelectServices finds the services that exist both in the definition
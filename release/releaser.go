@@ -36,6 +36,42 @@ func Release(rc *ReleaseContext, changes Changes, logger log.Logger) (results up
 	return results, err
 }
 
+// Plan works out what a release would do, without committing
+// anything. Each service's update is written, diffed, and thrown away
+// in turn, so that each result has the change to its own manifest.
+func Plan(rc *ReleaseContext, changes Changes, logger log.Logger) (results update.Result, err error) {
+	defer func(start time.Time) {
+		update.ObserveRelease(
+			start,
+			err == nil,
+			changes.ReleaseType(),
+			update.ReleaseKindPlan,
+		)
+	}(time.Now())
+
+	logger = log.NewContext(logger).With("type", "release", "dry-run", true)
+
+	updates, results, err := changes.CalculateRelease(rc, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range updates {
+		if err := ApplyChanges(rc, []*update.ServiceUpdate{u}, logger); err != nil {
+			return nil, err
+		}
+		result := results[u.ServiceID]
+		if result.Diff, err = rc.repo.Diff(); err != nil {
+			return nil, err
+		}
+		results[u.ServiceID] = result
+		if err := rc.repo.Discard(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
 func ApplyChanges(rc *ReleaseContext, updates []*update.ServiceUpdate, logger log.Logger) error {
 	logger.Log("updates", len(updates))
 	if len(updates) == 0 {
@@ -43,8 +79,15 @@ func ApplyChanges(rc *ReleaseContext, updates []*update.ServiceUpdate, logger lo
 		return nil
 	}
 
-	timer := update.NewStageTimer("write_changes")
-	err := rc.WriteUpdates(updates)
+	timer := update.NewStageTimer("validate_changes")
+	err := rc.ValidateUpdates(updates)
+	timer.ObserveDuration()
+	if err != nil {
+		return err
+	}
+
+	timer = update.NewStageTimer("write_changes")
+	err = rc.WriteUpdates(updates)
 	timer.ObserveDuration()
 	return err
 }
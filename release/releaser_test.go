@@ -1,7 +1,10 @@
 package release
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 	"github.com/weaveworks/flux/cluster/kubernetes"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/git/gittest"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/registry"
 	"github.com/weaveworks/flux/update"
 )
@@ -389,6 +393,77 @@ func Test_ImageStatus(t *testing.T) {
 	}
 }
 
+func Test_TagFilter(t *testing.T) {
+	mockCluster := &cluster.Mock{
+		AllServicesFunc: func(string) ([]cluster.Service, error) {
+			return allSvcs, nil
+		},
+		SomeServicesFunc: func([]flux.ServiceID) ([]cluster.Service, error) {
+			return []cluster.Service{hwSvc}, nil
+		},
+	}
+	filterRegistry := registry.NewMockRegistry([]flux.Image{
+		flux.Image{
+			ID:        oldImageID,
+			CreatedAt: timeNow.Add(-time.Hour),
+		},
+		flux.Image{
+			ID:        newImageID,
+			CreatedAt: timeNow,
+		},
+		flux.Image{
+			ID:        sidecarImageID,
+			CreatedAt: timeNow,
+		},
+	}, nil)
+
+	checkout, cleanup := setup(t)
+	defer cleanup()
+	path := filepath.Join(checkout.ManifestDir(), "helloworld-deploy.yaml")
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err = mockManifests.UpdatePolicies(def, policy.Update{
+		Add: policy.Set{policy.Policy(policy.TagPrefix + container): `regex:master-a\d{5}1`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path, def, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &ReleaseContext{
+		cluster:   mockCluster,
+		manifests: mockManifests,
+		repo:      checkout,
+		registry:  filterRegistry,
+	}
+	spec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{hwSvcSpec},
+		ImageSpec:    update.ImageSpecLatest,
+		Kind:         update.ReleaseKindExecute,
+		Excludes:     []flux.ServiceID{},
+	}
+	// The newest image doesn't pass the filter, so there's nothing to
+	// release
+	testRelease(t, "tag filter", ctx, spec, update.Result{
+		flux.ServiceID("default/helloworld"): update.ServiceResult{
+			Status: update.ReleaseStatusSkipped,
+			Error:  update.ImageUpToDate,
+		},
+		flux.ServiceID("default/locked-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+		flux.ServiceID("default/test-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+	})
+}
+
 func testRelease(t *testing.T, name string, ctx *ReleaseContext, spec update.ReleaseSpec, expected update.Result) {
 	results, err := Release(ctx, spec, log.NewNopLogger())
 	if err != nil {
@@ -398,3 +473,188 @@ func testRelease(t *testing.T, name string, ctx *ReleaseContext, spec update.Rel
 		t.Errorf("%s - expected:\n%#v, got:\n%#v", name, expected, results)
 	}
 }
+
+func Test_LockedContainer(t *testing.T) {
+	mockCluster := &cluster.Mock{
+		AllServicesFunc: func(string) ([]cluster.Service, error) {
+			return allSvcs, nil
+		},
+		SomeServicesFunc: func([]flux.ServiceID) ([]cluster.Service, error) {
+			return []cluster.Service{hwSvc}, nil
+		},
+	}
+
+	checkout, cleanup := setup(t)
+	defer cleanup()
+	path := filepath.Join(checkout.ManifestDir(), "helloworld-deploy.yaml")
+	def, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err = mockManifests.UpdatePolicies(def, policy.Update{
+		Add: policy.Set{policy.Policy(policy.LockedPrefix + container): "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path, def, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &ReleaseContext{
+		cluster:   mockCluster,
+		manifests: mockManifests,
+		repo:      checkout,
+		registry:  mockRegistry,
+	}
+	spec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{hwSvcSpec},
+		ImageSpec:    update.ImageSpecFromID(newImageID),
+		Kind:         update.ReleaseKindExecute,
+		Excludes:     []flux.ServiceID{},
+	}
+	// The only container using the image is locked, so the service
+	// is skipped, though it isn't locked itself
+	testRelease(t, "locked container", ctx, spec, update.Result{
+		flux.ServiceID("default/helloworld"): update.ServiceResult{
+			Status: update.ReleaseStatusSkipped,
+			Error:  update.Locked,
+		},
+		flux.ServiceID("default/locked-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+		flux.ServiceID("default/test-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+	})
+}
+
+func Test_ContainerSpec(t *testing.T) {
+	mockCluster := &cluster.Mock{
+		AllServicesFunc: func(string) ([]cluster.Service, error) {
+			return allSvcs, nil
+		},
+		SomeServicesFunc: func([]flux.ServiceID) ([]cluster.Service, error) {
+			return []cluster.Service{hwSvc}, nil
+		},
+	}
+	newSidecarID, _ := flux.ParseImageID("quay.io/weaveworks/sidecar:master-a000003")
+	containerRegistry := registry.NewMockRegistry([]flux.Image{
+		flux.Image{
+			ID:        newImageID,
+			CreatedAt: timeNow,
+		},
+		flux.Image{
+			ID:        newSidecarID,
+			CreatedAt: timeNow,
+		},
+	}, nil)
+
+	checkout, cleanup := setup(t)
+	defer cleanup()
+	ctx := &ReleaseContext{
+		cluster:   mockCluster,
+		manifests: mockManifests,
+		repo:      checkout,
+		registry:  containerRegistry,
+	}
+	notIncluded := update.Result{
+		flux.ServiceID("default/locked-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+		flux.ServiceID("default/test-service"): update.ServiceResult{
+			Status: update.ReleaseStatusIgnored,
+			Error:  update.NotIncluded,
+		},
+	}
+	expect := func(result update.ServiceResult) update.Result {
+		expected := update.Result{hwSvcID: result}
+		for id, r := range notIncluded {
+			expected[id] = r
+		}
+		return expected
+	}
+
+	// Only the container picked out is released, though both have
+	// newer images
+	spec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{hwSvcSpec},
+		ImageSpec:    update.ImageSpecLatest,
+		Kind:         update.ReleaseKindExecute,
+		Containers:   []update.ContainerSpec{update.ContainerSpec(hwSvcID.String() + ":sidecar")},
+	}
+	testRelease(t, "picked container", ctx, spec, expect(update.ServiceResult{
+		Status: update.ReleaseStatusSuccess,
+		PerContainer: []update.ContainerUpdate{
+			update.ContainerUpdate{
+				Container: "sidecar",
+				Current:   sidecarImageID,
+				Target:    newSidecarID,
+			},
+		},
+	}))
+
+	// A specific image not used by the container picked out isn't
+	// released to the service
+	spec.ImageSpec = update.ImageSpecFromID(newImageID)
+	testRelease(t, "picked container not using image", ctx, spec, expect(update.ServiceResult{
+		Status: update.ReleaseStatusIgnored,
+		Error:  update.DoesNotUseImage,
+	}))
+
+	spec.Containers = []update.ContainerSpec{update.ContainerSpec(hwSvcID.String() + ":nonesuch")}
+	testRelease(t, "no such container", ctx, spec, expect(update.ServiceResult{
+		Status: update.ReleaseStatusFailed,
+		Error:  update.ContainerNotFound + `: "nonesuch"`,
+	}))
+
+	// Containers can only be picked out of services being released
+	spec.Containers = []update.ContainerSpec{update.ContainerSpec("default/test-service:test-service")}
+	if _, err := Release(ctx, spec, log.NewNopLogger()); err == nil {
+		t.Error("expected error picking out a container of a service not being released")
+	}
+}
+
+func Test_Plan(t *testing.T) {
+	mockCluster := &cluster.Mock{
+		AllServicesFunc: func(string) ([]cluster.Service, error) {
+			return allSvcs, nil
+		},
+		SomeServicesFunc: func([]flux.ServiceID) ([]cluster.Service, error) {
+			return []cluster.Service{hwSvc}, nil
+		},
+	}
+
+	checkout, cleanup := setup(t)
+	defer cleanup()
+	ctx := &ReleaseContext{
+		cluster:   mockCluster,
+		manifests: mockManifests,
+		repo:      checkout,
+		registry:  mockRegistry,
+	}
+	spec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{hwSvcSpec},
+		ImageSpec:    update.ImageSpecFromID(newImageID),
+		Kind:         update.ReleaseKindPlan,
+	}
+	results, err := Plan(ctx, spec, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := results[hwSvcID]
+	if result.Status != update.ReleaseStatusSuccess || result.Impact == nil {
+		t.Fatalf("expected a planned release of %s, with its impact, got %#v", hwSvcID, result)
+	}
+	if !strings.Contains(result.Diff, newImageID.String()) {
+		t.Errorf("expected the diff to show the new image, got:\n%s", result.Diff)
+	}
+
+	// Nothing is left changed
+	if diff, err := checkout.Diff(); err != nil || diff != "" {
+		t.Errorf("expected no changes left in the checkout, got %q (error %v)", diff, err)
+	}
+}
@@ -0,0 +1,49 @@
+package remote
+
+import (
+	"context"
+	"errors"
+
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// CheckPlatform adds to the report the checks that can be answered
+// by the daemon itself: whether it speaks the current protocol,
+// whether it can get at its git repo, and whether it can get image
+// metadata from the registries.
+func CheckPlatform(ctx context.Context, p Platform, report *service.CheckReport) {
+	report.Run(service.CheckVersion, func() (string, error) {
+		version, err := p.Version(ctx)
+		if err != nil {
+			return "", err
+		}
+		// GitRepoConfig is among the most recent additions to the
+		// protocol, so an old daemon will fail here.
+		_, err = p.GitRepoConfig(ctx, false)
+		return version, err
+	})
+
+	report.Run(service.CheckGit, func() (string, error) {
+		config, err := p.GitRepoConfig(ctx, false)
+		if err != nil {
+			return "", err
+		}
+		if config.Remote.URL == "" {
+			return "", errors.New("no git repo configured")
+		}
+		if config.Diverged != nil {
+			return config.Remote.URL, errors.New("the branch has been rewritten since the daemon last saw it; use fluxctl reset-git to carry on from it as it is now")
+		}
+		// This needs the repo to have been cloned, and the sync tag
+		// to be present; the latter means the daemon has been able
+		// to push to the repo.
+		_, err = p.SyncStatus(ctx, "HEAD")
+		return config.Remote.URL, err
+	})
+
+	report.Run(service.CheckRegistry, func() (string, error) {
+		_, err := p.ListImages(ctx, update.ServiceSpecAll)
+		return "", err
+	})
+}
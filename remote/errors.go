@@ -1,6 +1,8 @@
 package remote
 
 import (
+	"github.com/pkg/errors"
+
 	"github.com/weaveworks/flux"
 )
 
@@ -32,9 +34,7 @@ If you are still stuck, please log an issue:
 	}}
 }
 
-func UpgradeNeededError(err error) error {
-	return flux.UserConfigProblem{&flux.BaseError{
-		Help: `Your fluxd needs to be upgraded
+const upgradeNeededHelp = `Your fluxd needs to be upgraded
 
 To service this request, we need to ask the agent running in your
 cluster (fluxd) to perform an operation on our behalf, but the
@@ -42,11 +42,25 @@ version you have running is too old to understand the request.
 
 Please install the latest version of fluxd and try again.
 
-`,
-		Err: err,
+`
+
+func UpgradeNeededError(err error) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Help: upgradeNeededHelp,
+		Err:  err,
 	}}
 }
 
+// IsUpgradeNeeded says whether the error is one from
+// UpgradeNeededError; i.e., whether the daemon didn't know the
+// method, so that the caller can fall back to an older one.
+func IsUpgradeNeeded(err error) bool {
+	if helpful, ok := errors.Cause(err).(flux.HelpfulError); ok {
+		return helpful.Base().Help == upgradeNeededHelp
+	}
+	return false
+}
+
 func ClusterError(err error) error {
 	return flux.UserConfigProblem{&flux.BaseError{
 		Help: `Error from Flux daemon
@@ -74,3 +88,56 @@ If you are still stuck, please log an issue:
 		Err: err,
 	}}
 }
+
+// The kinds of error an ApplicationError can be, corresponding to
+// the types of error in the flux package.
+const (
+	ApplicationErrorMissing = "missing"
+	ApplicationErrorUser    = "user"
+	ApplicationErrorServer  = "server"
+)
+
+// ApplicationError is how an error from a platform method is sent
+// between the daemon and the service, so that the help that goes
+// with it, and what kind of error it is, aren't lost on the way.
+type ApplicationError struct {
+	Type    string `json:"type,omitempty"`
+	Help    string `json:"help"`
+	Message string `json:"message"`
+}
+
+// ApplicationErrorFrom gives the wire form of an error, or nil if
+// there's nothing more to it than its message.
+func ApplicationErrorFrom(err error) *ApplicationError {
+	if err == nil {
+		return nil
+	}
+	appErr := &ApplicationError{Message: err.Error()}
+	switch cause := errors.Cause(err).(type) {
+	case flux.Missing:
+		appErr.Type, appErr.Help = ApplicationErrorMissing, cause.Help
+	case flux.UserConfigProblem:
+		appErr.Type, appErr.Help = ApplicationErrorUser, cause.Help
+	case flux.ServerException:
+		appErr.Type, appErr.Help = ApplicationErrorServer, cause.Help
+	case flux.HelpfulError:
+		appErr.Help = cause.Base().Help
+	default:
+		return nil
+	}
+	return appErr
+}
+
+// Err reconstructs the error that was sent.
+func (e *ApplicationError) Err() error {
+	base := &flux.BaseError{Help: e.Help, Err: errors.New(e.Message)}
+	switch e.Type {
+	case ApplicationErrorMissing:
+		return flux.Missing{base}
+	case ApplicationErrorUser:
+		return flux.UserConfigProblem{base}
+	case ApplicationErrorServer:
+		return flux.ServerException{base}
+	}
+	return base
+}
@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ExportChunkSize is the most data a daemon will put in a single
+// export chunk. It's kept well under the message size limits of the
+// transports between the daemon and the service.
+const ExportChunkSize = 512 * 1024
+
+// ExportChunkRequest asks for the part of an export starting at
+// Offset. An empty ID asks the daemon to start a new export; for
+// subsequent chunks, it's the ID given back with the first chunk.
+type ExportChunkRequest struct {
+	ID     string
+	Offset int64
+}
+
+// ExportChunk is a part of an export. When More is false, this is
+// the last chunk and the daemon has forgotten the export.
+type ExportChunk struct {
+	ID   string
+	Data []byte
+	More bool
+}
+
+// ExportTo writes the cluster's configuration to the writer given,
+// getting it from the platform a chunk at a time. If the daemon
+// doesn't know how to export in chunks, it falls back to getting the
+// whole lot in one go.
+func ExportTo(ctx context.Context, p Platform, w io.Writer) error {
+	var req ExportChunkRequest
+	for {
+		chunk, err := p.ExportChunk(ctx, req)
+		if IsUpgradeNeeded(err) && req.ID == "" {
+			config, err := p.Export(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(config)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return errors.Wrap(err, "writing export")
+		}
+		if !chunk.More {
+			return nil
+		}
+		req.ID = chunk.ID
+		req.Offset += int64(len(chunk.Data))
+	}
+}
@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// chunkingPlatform serves its export a few bytes at a time
+type chunkingPlatform struct {
+	MockPlatform
+	config string
+	size   int
+}
+
+func (p *chunkingPlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (ExportChunk, error) {
+	if req.Offset > 0 && req.ID != "export" {
+		return ExportChunk{}, errors.New("unexpected export ID " + req.ID)
+	}
+	end := int(req.Offset) + p.size
+	if end > len(p.config) {
+		end = len(p.config)
+	}
+	return ExportChunk{
+		ID:   "export",
+		Data: []byte(p.config[req.Offset:end]),
+		More: end < len(p.config),
+	}, nil
+}
+
+func TestExportTo(t *testing.T) {
+	config := "---\nkind: Namespace\nmetadata:\n  name: default\n"
+	p := &chunkingPlatform{config: config, size: 7}
+	var out bytes.Buffer
+	if err := ExportTo(context.Background(), p, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != config {
+		t.Errorf("expected %q, got %q", config, out.String())
+	}
+}
+
+func TestExportToFallsBack(t *testing.T) {
+	p := &MockPlatform{
+		ExportAnswer:     []byte("---\nkind: Namespace\n"),
+		ExportChunkError: UpgradeNeededError(errors.New("ExportChunk method not implemented")),
+	}
+	var out bytes.Buffer
+	if err := ExportTo(context.Background(), p, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(p.ExportAnswer) {
+		t.Errorf("expected %q, got %q", p.ExportAnswer, out.String())
+	}
+
+	p.ExportChunkError = errors.New("cluster on fire")
+	if err := ExportTo(context.Background(), p, &out); err != p.ExportChunkError {
+		t.Errorf("expected error from chunk to be returned, got %v", err)
+	}
+}
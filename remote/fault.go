@@ -0,0 +1,57 @@
+package remote
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FaultKind names a kind of failure a daemon can be asked to
+// simulate, by way of testing that the alerting for it works.
+type FaultKind string
+
+const (
+	// FaultGit makes fetching from the git repo fail
+	FaultGit FaultKind = "git"
+	// FaultRegistry makes the image registry look like it's answering
+	// with server errors
+	FaultRegistry FaultKind = "registry"
+	// FaultApply makes applying the manifests to the cluster fail
+	FaultApply FaultKind = "apply"
+)
+
+// MaxFaultDuration is the longest a fault can be injected for, so
+// that a forgotten one doesn't leave an instance broken.
+const MaxFaultDuration = time.Hour
+
+// FaultSpec asks the daemon to simulate a failure of the kind given,
+// for the duration given (e.g., "10m"). A duration of zero clears the
+// fault.
+type FaultSpec struct {
+	Kind FaultKind `json:"kind"`
+	For  string    `json:"for"`
+}
+
+// Duration parses the duration of the fault, checking it's within
+// bounds.
+func (s FaultSpec) Duration() (time.Duration, error) {
+	d, err := time.ParseDuration(s.For)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing duration of fault %q", s.For)
+	}
+	if d < 0 || d > MaxFaultDuration {
+		return 0, errors.Errorf("duration of fault must be between 0 and %s, got %s", MaxFaultDuration, d)
+	}
+	return d, nil
+}
+
+// Validate checks that the spec is one a daemon can act on.
+func (s FaultSpec) Validate() error {
+	switch s.Kind {
+	case FaultGit, FaultRegistry, FaultApply:
+	default:
+		return errors.Errorf("unknown kind of fault %q; expected one of %q, %q or %q", s.Kind, FaultGit, FaultRegistry, FaultApply)
+	}
+	_, err := s.Duration()
+	return err
+}
@@ -1,10 +1,13 @@
 package remote
 
 import (
+	"context"
+
 	"github.com/go-kit/kit/log"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -13,93 +16,228 @@ type ErrorLoggingPlatform struct {
 	Logger   log.Logger
 }
 
-func (p *ErrorLoggingPlatform) Ping() (err error) {
+func (p *ErrorLoggingPlatform) Ping(ctx context.Context) (err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "Ping", "error", err)
+			p.log(ctx, "method", "Ping", "error", err)
 		}
 	}()
-	return p.Platform.Ping()
+	return p.Platform.Ping(ctx)
 }
 
-func (p *ErrorLoggingPlatform) Version() (v string, err error) {
+func (p *ErrorLoggingPlatform) Version(ctx context.Context) (v string, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "Version", "error", err, "version", v)
+			p.log(ctx, "method", "Version", "error", err, "version", v)
 		}
 	}()
-	return p.Platform.Version()
+	return p.Platform.Version(ctx)
 }
 
-func (p *ErrorLoggingPlatform) Export() (config []byte, err error) {
+func (p *ErrorLoggingPlatform) Export(ctx context.Context) (config []byte, err error) {
 	defer func() {
 		if err != nil {
 			// Omit config as it could be large
-			p.Logger.Log("method", "Export", "error", err)
+			p.log(ctx, "method", "Export", "error", err)
+		}
+	}()
+	return p.Platform.Export(ctx)
+}
+
+func (p *ErrorLoggingPlatform) ListServices(ctx context.Context, maybeNamespace string) (_ []flux.ServiceStatus, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "ListServices", "error", err)
+		}
+	}()
+	return p.Platform.ListServices(ctx, maybeNamespace)
+}
+
+func (p *ErrorLoggingPlatform) ListImages(ctx context.Context, spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "ListImages", "error", err)
+		}
+	}()
+	return p.Platform.ListImages(ctx, spec)
+}
+
+func (p *ErrorLoggingPlatform) SyncNotify(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "SyncNotify", "error", err)
+		}
+	}()
+	return p.Platform.SyncNotify(ctx)
+}
+
+func (p *ErrorLoggingPlatform) JobStatus(ctx context.Context, jobID job.ID) (_ job.Status, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "JobStatus", "error", err)
+		}
+	}()
+	return p.Platform.JobStatus(ctx, jobID)
+}
+
+func (p *ErrorLoggingPlatform) SyncStatus(ctx context.Context, rev string) (_ []string, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "SyncStatus", "error", err)
+		}
+	}()
+	return p.Platform.SyncStatus(ctx, rev)
+}
+
+func (p *ErrorLoggingPlatform) UpdateManifests(ctx context.Context, u update.Spec) (_ job.ID, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "UpdateManifests", "error", err)
 		}
 	}()
-	return p.Platform.Export()
+	return p.Platform.UpdateManifests(ctx, u)
 }
 
-func (p *ErrorLoggingPlatform) ListServices(maybeNamespace string) (_ []flux.ServiceStatus, err error) {
+func (p *ErrorLoggingPlatform) GitRepoConfig(ctx context.Context, regenerate bool) (_ flux.GitConfig, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "ListServices", "error", err)
+			p.log(ctx, "method", "GitRepoConfig", "error", err)
 		}
 	}()
-	return p.Platform.ListServices(maybeNamespace)
+	return p.Platform.GitRepoConfig(ctx, regenerate)
 }
 
-func (p *ErrorLoggingPlatform) ListImages(spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
+func (p *ErrorLoggingPlatform) JobLog(ctx context.Context, id job.ID) (_ job.Log, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "ListImages", "error", err)
+			p.log(ctx, "method", "JobLog", "error", err)
 		}
 	}()
-	return p.Platform.ListImages(spec)
+	return p.Platform.JobLog(ctx, id)
 }
 
-func (p *ErrorLoggingPlatform) SyncNotify() (err error) {
+func (p *ErrorLoggingPlatform) WaitJobStatus(ctx context.Context, req job.WaitRequest) (_ job.Status, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "SyncNotify", "error", err)
+			p.log(ctx, "method", "WaitJobStatus", "error", err)
 		}
 	}()
-	return p.Platform.SyncNotify()
+	return p.Platform.WaitJobStatus(ctx, req)
 }
 
-func (p *ErrorLoggingPlatform) JobStatus(jobID job.ID) (_ job.Status, err error) {
+func (p *ErrorLoggingPlatform) ClusterConfig(ctx context.Context) (_ flux.ClusterConfig, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "JobStatus", "error", err)
+			p.log(ctx, "method", "ClusterConfig", "error", err)
 		}
 	}()
-	return p.Platform.JobStatus(jobID)
+	return p.Platform.ClusterConfig(ctx)
 }
 
-func (p *ErrorLoggingPlatform) SyncStatus(rev string) (_ []string, err error) {
+func (p *ErrorLoggingPlatform) EvaluateImage(ctx context.Context, image flux.ImageID) (_ update.Result, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "SyncStatus", "error", err)
+			p.log(ctx, "method", "EvaluateImage", "error", err)
 		}
 	}()
-	return p.Platform.SyncStatus(rev)
+	return p.Platform.EvaluateImage(ctx, image)
 }
 
-func (p *ErrorLoggingPlatform) UpdateManifests(u update.Spec) (_ job.ID, err error) {
+func (p *ErrorLoggingPlatform) ServiceTopology(ctx context.Context) (_ []flux.ServiceTopology, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "UpdateManifests", "error", err)
+			p.log(ctx, "method", "ServiceTopology", "error", err)
 		}
 	}()
-	return p.Platform.UpdateManifests(u)
+	return p.Platform.ServiceTopology(ctx)
 }
 
-func (p *ErrorLoggingPlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig, err error) {
+func (p *ErrorLoggingPlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (chunk ExportChunk, err error) {
 	defer func() {
 		if err != nil {
-			p.Logger.Log("method", "GitRepoConfig", "error", err)
+			// Omit the data as it could be large
+			p.log(ctx, "method", "ExportChunk", "error", err, "export", req.ID, "offset", req.Offset)
 		}
 	}()
-	return p.Platform.GitRepoConfig(regenerate)
+	return p.Platform.ExportChunk(ctx, req)
+}
+
+func (p *ErrorLoggingPlatform) ResetGitToRemote(ctx context.Context) (_ string, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "ResetGitToRemote", "error", err)
+		}
+	}()
+	return p.Platform.ResetGitToRemote(ctx)
+}
+
+func (p *ErrorLoggingPlatform) ExportAt(ctx context.Context, ref string) (_ []byte, err error) {
+	defer func() {
+		if err != nil {
+			// Omit the manifests as they could be large
+			p.log(ctx, "method", "ExportAt", "error", err, "ref", ref)
+		}
+	}()
+	return p.Platform.ExportAt(ctx, ref)
+}
+
+func (p *ErrorLoggingPlatform) InjectFault(ctx context.Context, spec FaultSpec) (err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "InjectFault", "error", err, "kind", spec.Kind)
+		}
+	}()
+	return p.Platform.InjectFault(ctx, spec)
+}
+
+func (p *ErrorLoggingPlatform) SyncHealth(ctx context.Context) (_ SyncHealth, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "SyncHealth", "error", err)
+		}
+	}()
+	return p.Platform.SyncHealth(ctx)
+}
+
+func (p *ErrorLoggingPlatform) ListResources(ctx context.Context) (_ []flux.ResourceStatus, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "ListResources", "error", err)
+		}
+	}()
+	return p.Platform.ListResources(ctx)
+}
+
+func (p *ErrorLoggingPlatform) SyncWait(ctx context.Context, req SyncWaitRequest) (_ SyncReport, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "SyncWait", "error", err)
+		}
+	}()
+	return p.Platform.SyncWait(ctx, req)
+}
+
+func (p *ErrorLoggingPlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) (err error) {
+	defer func() {
+		if err != nil {
+			// Omit the config, so as not to log where secrets are kept
+			p.log(ctx, "method", "ApplyConfig", "error", err)
+		}
+	}()
+	return p.Platform.ApplyConfig(ctx, config)
+}
+
+func (p *ErrorLoggingPlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (_ update.Result, err error) {
+	defer func() {
+		if err != nil {
+			p.log(ctx, "method", "PlanRelease", "error", err)
+		}
+	}()
+	return p.Platform.PlanRelease(ctx, spec)
+}
+
+// log logs the keyvals given along with any metadata that came with
+// the call, so errors can be matched up with the request.
+func (p *ErrorLoggingPlatform) log(ctx context.Context, keyvals ...interface{}) {
+	p.Logger.Log(append(keyvals, MetadataFrom(ctx).Keyvals()...)...)
 }
@@ -0,0 +1,40 @@
+package remote
+
+import (
+	"context"
+)
+
+// Metadata goes along with calls to a platform, so that what happens
+// in the daemon can be tied back to the API request that caused it.
+type Metadata struct {
+	RequestID string `json:"requestID,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+type metadataKey struct{}
+
+// WithMetadata gives a context carrying the metadata, for calls made
+// with it.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFrom gives the metadata carried by the context, or the
+// zero value if there isn't any.
+func MetadataFrom(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// Keyvals gives the metadata as key/value pairs for logging, leaving
+// out whatever isn't set.
+func (md Metadata) Keyvals() []interface{} {
+	var keyvals []interface{}
+	if md.RequestID != "" {
+		keyvals = append(keyvals, "request_id", md.RequestID)
+	}
+	if md.User != "" {
+		keyvals = append(keyvals, "user", md.User)
+	}
+	return keyvals
+}
@@ -1,12 +1,11 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
@@ -16,13 +15,12 @@ import (
 )
 
 var (
-	requestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	requestDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "platform",
 		Name:      "request_duration_seconds",
 		Help:      "Request duration in seconds.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess})
+	}, fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess)
 )
 
 type instrumentedPlatform struct {
@@ -33,104 +31,244 @@ func Instrument(p Platform) Platform {
 	return &instrumentedPlatform{p}
 }
 
-func (i *instrumentedPlatform) Ping() (err error) {
+func (i *instrumentedPlatform) Ping(ctx context.Context) (err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "Ping",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.Ping()
+	return i.p.Ping(ctx)
 }
 
-func (i *instrumentedPlatform) Version() (v string, err error) {
+func (i *instrumentedPlatform) Version(ctx context.Context) (v string, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "Version",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.Version()
+	return i.p.Version(ctx)
 }
 
-func (i *instrumentedPlatform) Export() (config []byte, err error) {
+func (i *instrumentedPlatform) Export(ctx context.Context) (config []byte, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "Export",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.Export()
+	return i.p.Export(ctx)
 }
 
-func (i *instrumentedPlatform) ListServices(namespace string) (_ []flux.ServiceStatus, err error) {
+func (i *instrumentedPlatform) ListServices(ctx context.Context, namespace string) (_ []flux.ServiceStatus, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "ListServices",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.ListServices(namespace)
+	return i.p.ListServices(ctx, namespace)
 }
 
-func (i *instrumentedPlatform) ListImages(spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
+func (i *instrumentedPlatform) ListImages(ctx context.Context, spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "ListImages",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.ListImages(spec)
+	return i.p.ListImages(ctx, spec)
 }
 
-func (i *instrumentedPlatform) UpdateManifests(spec update.Spec) (_ job.ID, err error) {
+func (i *instrumentedPlatform) UpdateManifests(ctx context.Context, spec update.Spec) (_ job.ID, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "UpdateManifests",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.UpdateManifests(spec)
+	return i.p.UpdateManifests(ctx, spec)
 }
 
-func (i *instrumentedPlatform) SyncNotify() (err error) {
+func (i *instrumentedPlatform) SyncNotify(ctx context.Context) (err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "SyncNotify",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.SyncNotify()
+	return i.p.SyncNotify(ctx)
 }
 
-func (i *instrumentedPlatform) JobStatus(id job.ID) (_ job.Status, err error) {
+func (i *instrumentedPlatform) JobStatus(ctx context.Context, id job.ID) (_ job.Status, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "JobStatus",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.JobStatus(id)
+	return i.p.JobStatus(ctx, id)
 }
 
-func (i *instrumentedPlatform) SyncStatus(cursor string) (_ []string, err error) {
+func (i *instrumentedPlatform) SyncStatus(ctx context.Context, cursor string) (_ []string, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "SyncStatus",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.SyncStatus(cursor)
+	return i.p.SyncStatus(ctx, cursor)
 }
 
-func (i *instrumentedPlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig, err error) {
+func (i *instrumentedPlatform) GitRepoConfig(ctx context.Context, regenerate bool) (_ flux.GitConfig, err error) {
 	defer func(begin time.Time) {
 		requestDuration.With(
 			fluxmetrics.LabelMethod, "GitRepoConfig",
 			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
 		).Observe(time.Since(begin).Seconds())
 	}(time.Now())
-	return i.p.GitRepoConfig(regenerate)
+	return i.p.GitRepoConfig(ctx, regenerate)
+}
+
+func (i *instrumentedPlatform) JobLog(ctx context.Context, id job.ID) (_ job.Log, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "JobLog",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.JobLog(ctx, id)
+}
+
+func (i *instrumentedPlatform) WaitJobStatus(ctx context.Context, req job.WaitRequest) (_ job.Status, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "WaitJobStatus",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.WaitJobStatus(ctx, req)
+}
+
+func (i *instrumentedPlatform) ClusterConfig(ctx context.Context) (_ flux.ClusterConfig, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ClusterConfig",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ClusterConfig(ctx)
+}
+
+func (i *instrumentedPlatform) EvaluateImage(ctx context.Context, image flux.ImageID) (_ update.Result, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "EvaluateImage",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.EvaluateImage(ctx, image)
+}
+
+func (i *instrumentedPlatform) ServiceTopology(ctx context.Context) (_ []flux.ServiceTopology, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ServiceTopology",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ServiceTopology(ctx)
+}
+
+func (i *instrumentedPlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (_ ExportChunk, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ExportChunk",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ExportChunk(ctx, req)
+}
+
+func (i *instrumentedPlatform) ResetGitToRemote(ctx context.Context) (_ string, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ResetGitToRemote",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ResetGitToRemote(ctx)
+}
+
+func (i *instrumentedPlatform) ExportAt(ctx context.Context, ref string) (_ []byte, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ExportAt",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ExportAt(ctx, ref)
+}
+
+func (i *instrumentedPlatform) InjectFault(ctx context.Context, spec FaultSpec) (err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "InjectFault",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.InjectFault(ctx, spec)
+}
+
+func (i *instrumentedPlatform) SyncHealth(ctx context.Context) (_ SyncHealth, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "SyncHealth",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.SyncHealth(ctx)
+}
+
+func (i *instrumentedPlatform) ListResources(ctx context.Context) (_ []flux.ResourceStatus, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ListResources",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ListResources(ctx)
+}
+
+func (i *instrumentedPlatform) SyncWait(ctx context.Context, req SyncWaitRequest) (_ SyncReport, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "SyncWait",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.SyncWait(ctx, req)
+}
+
+func (i *instrumentedPlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) (err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ApplyConfig",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ApplyConfig(ctx, config)
+}
+
+func (i *instrumentedPlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (_ update.Result, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "PlanRelease",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.PlanRelease(ctx, spec)
 }
 
 // BusMetrics has metrics for messages buses.
@@ -140,12 +278,12 @@ type BusMetrics struct {
 
 var (
 	BusMetricsImpl = BusMetrics{
-		KickCount: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		KickCount: fluxmetrics.NewCounter(fluxmetrics.Opts{
 			Namespace: "flux",
 			Subsystem: "bus",
 			Name:      "kick_total",
 			Help:      "Count of bus subscriptions kicked off by a newer subscription.",
-		}, []string{}),
+		}),
 	}
 )
 
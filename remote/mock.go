@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -10,6 +11,8 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/guid"
 	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -42,29 +45,73 @@ type MockPlatform struct {
 
 	GitRepoConfigAnswer flux.GitConfig
 	GitRepoConfigError  error
+
+	JobLogAnswer job.Log
+	JobLogError  error
+
+	WaitJobStatusAnswer job.Status
+	WaitJobStatusError  error
+
+	ClusterConfigAnswer flux.ClusterConfig
+	ClusterConfigError  error
+
+	EvaluateImageAnswer update.Result
+	EvaluateImageError  error
+
+	ServiceTopologyAnswer []flux.ServiceTopology
+	ServiceTopologyError  error
+
+	ExportChunkAnswer ExportChunk
+	ExportChunkError  error
+
+	ResetGitToRemoteAnswer string
+	ResetGitToRemoteError  error
+
+	ExportAtArgTest func(string) error
+	ExportAtAnswer  []byte
+	ExportAtError   error
+
+	InjectFaultError error
+
+	SyncHealthAnswer SyncHealth
+	SyncHealthError  error
+
+	ListResourcesAnswer []flux.ResourceStatus
+	ListResourcesError  error
+
+	SyncWaitArgTest func(SyncWaitRequest) error
+	SyncWaitAnswer  SyncReport
+	SyncWaitError   error
+
+	ApplyConfigArgTest func(service.DaemonConfig) error
+	ApplyConfigError   error
+
+	PlanReleaseArgTest func(update.ReleaseSpec) error
+	PlanReleaseAnswer  update.Result
+	PlanReleaseError   error
 }
 
-func (p *MockPlatform) Ping() error {
+func (p *MockPlatform) Ping(ctx context.Context) error {
 	return p.PingError
 }
 
-func (p *MockPlatform) Version() (string, error) {
+func (p *MockPlatform) Version(ctx context.Context) (string, error) {
 	return p.VersionAnswer, p.VersionError
 }
 
-func (p *MockPlatform) Export() ([]byte, error) {
+func (p *MockPlatform) Export(ctx context.Context) ([]byte, error) {
 	return p.ExportAnswer, p.ExportError
 }
 
-func (p *MockPlatform) ListServices(ns string) ([]flux.ServiceStatus, error) {
+func (p *MockPlatform) ListServices(ctx context.Context, ns string) ([]flux.ServiceStatus, error) {
 	return p.ListServicesAnswer, p.ListServicesError
 }
 
-func (p *MockPlatform) ListImages(update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (p *MockPlatform) ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error) {
 	return p.ListImagesAnswer, p.ListImagesError
 }
 
-func (p *MockPlatform) UpdateManifests(s update.Spec) (job.ID, error) {
+func (p *MockPlatform) UpdateManifests(ctx context.Context, s update.Spec) (job.ID, error) {
 	if p.UpdateManifestsArgTest != nil {
 		if err := p.UpdateManifestsArgTest(s); err != nil {
 			return job.ID(""), err
@@ -73,22 +120,98 @@ func (p *MockPlatform) UpdateManifests(s update.Spec) (job.ID, error) {
 	return p.UpdateManifestsAnswer, p.UpdateManifestsError
 }
 
-func (p *MockPlatform) SyncNotify() error {
+func (p *MockPlatform) SyncNotify(ctx context.Context) error {
 	return p.SyncNotifyError
 }
 
-func (p *MockPlatform) SyncStatus(string) ([]string, error) {
+func (p *MockPlatform) SyncStatus(context.Context, string) ([]string, error) {
 	return p.SyncStatusAnswer, p.SyncStatusError
 }
 
-func (p *MockPlatform) JobStatus(job.ID) (job.Status, error) {
+func (p *MockPlatform) JobStatus(context.Context, job.ID) (job.Status, error) {
 	return p.JobStatusAnswer, p.JobStatusError
 }
 
-func (p *MockPlatform) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+func (p *MockPlatform) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
 	return p.GitRepoConfigAnswer, p.GitRepoConfigError
 }
 
+func (p *MockPlatform) JobLog(context.Context, job.ID) (job.Log, error) {
+	return p.JobLogAnswer, p.JobLogError
+}
+
+func (p *MockPlatform) WaitJobStatus(context.Context, job.WaitRequest) (job.Status, error) {
+	return p.WaitJobStatusAnswer, p.WaitJobStatusError
+}
+
+func (p *MockPlatform) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	return p.ClusterConfigAnswer, p.ClusterConfigError
+}
+
+func (p *MockPlatform) EvaluateImage(context.Context, flux.ImageID) (update.Result, error) {
+	return p.EvaluateImageAnswer, p.EvaluateImageError
+}
+
+func (p *MockPlatform) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	return p.ServiceTopologyAnswer, p.ServiceTopologyError
+}
+
+func (p *MockPlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (ExportChunk, error) {
+	return p.ExportChunkAnswer, p.ExportChunkError
+}
+
+func (p *MockPlatform) ResetGitToRemote(ctx context.Context) (string, error) {
+	return p.ResetGitToRemoteAnswer, p.ResetGitToRemoteError
+}
+
+func (p *MockPlatform) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	if p.ExportAtArgTest != nil {
+		if err := p.ExportAtArgTest(ref); err != nil {
+			return nil, err
+		}
+	}
+	return p.ExportAtAnswer, p.ExportAtError
+}
+
+func (p *MockPlatform) InjectFault(ctx context.Context, spec FaultSpec) error {
+	return p.InjectFaultError
+}
+
+func (p *MockPlatform) SyncHealth(ctx context.Context) (SyncHealth, error) {
+	return p.SyncHealthAnswer, p.SyncHealthError
+}
+
+func (p *MockPlatform) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	return p.ListResourcesAnswer, p.ListResourcesError
+}
+
+func (p *MockPlatform) SyncWait(ctx context.Context, req SyncWaitRequest) (SyncReport, error) {
+	if p.SyncWaitArgTest != nil {
+		if err := p.SyncWaitArgTest(req); err != nil {
+			return SyncReport{}, err
+		}
+	}
+	return p.SyncWaitAnswer, p.SyncWaitError
+}
+
+func (p *MockPlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	if p.ApplyConfigArgTest != nil {
+		if err := p.ApplyConfigArgTest(config); err != nil {
+			return err
+		}
+	}
+	return p.ApplyConfigError
+}
+
+func (p *MockPlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	if p.PlanReleaseArgTest != nil {
+		if err := p.PlanReleaseArgTest(spec); err != nil {
+			return nil, err
+		}
+	}
+	return p.PlanReleaseAnswer, p.PlanReleaseError
+}
+
 var _ Platform = &MockPlatform{}
 
 // -- Battery of tests for a platform mechanism. Since these
@@ -97,6 +220,7 @@ var _ Platform = &MockPlatform{}
 
 func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 	// set up
+	ctx := context.Background()
 	namespace := "the-space-of-names"
 	serviceID := flux.ServiceID(namespace + "/service")
 	serviceList := []flux.ServiceID{serviceID}
@@ -170,11 +294,11 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 	// OK, here we go
 	client := wrap(mock)
 
-	if err := client.Ping(); err != nil {
+	if err := client.Ping(ctx); err != nil {
 		t.Fatal(err)
 	}
 
-	ss, err := client.ListServices(namespace)
+	ss, err := client.ListServices(ctx, namespace)
 	if err != nil {
 		t.Error(err)
 	}
@@ -182,12 +306,12 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 		t.Error(fmt.Errorf("expected:\n%#v\ngot:\n%#v", mock.ListServicesAnswer, ss))
 	}
 	mock.ListServicesError = fmt.Errorf("list services query failure")
-	ss, err = client.ListServices(namespace)
+	ss, err = client.ListServices(ctx, namespace)
 	if err == nil {
 		t.Error("expected error from ListServices, got nil")
 	}
 
-	ims, err := client.ListImages(update.ServiceSpecAll)
+	ims, err := client.ListImages(ctx, update.ServiceSpecAll)
 	if err != nil {
 		t.Error(err)
 	}
@@ -195,11 +319,11 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 		t.Error(fmt.Errorf("expected:\n%#v\ngot:\n%#v", mock.ListImagesAnswer, ims))
 	}
 	mock.ListImagesError = fmt.Errorf("list images error")
-	if _, err = client.ListImages(update.ServiceSpecAll); err == nil {
+	if _, err = client.ListImages(ctx, update.ServiceSpecAll); err == nil {
 		t.Error("expected error from ListImages, got nil")
 	}
 
-	jobid, err := mock.UpdateManifests(updateSpec)
+	jobid, err := mock.UpdateManifests(ctx, updateSpec)
 	if err != nil {
 		t.Error(err)
 	}
@@ -207,19 +331,228 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 		t.Error(fmt.Errorf("expected %q, got %q", mock.UpdateManifestsAnswer, jobid))
 	}
 	mock.UpdateManifestsError = fmt.Errorf("update manifests error")
-	if _, err = client.UpdateManifests(updateSpec); err == nil {
+	if _, err = client.UpdateManifests(ctx, updateSpec); err == nil {
 		t.Error("expected error from UpdateManifests, got nil")
 	}
 
-	if err := client.SyncNotify(); err != nil {
+	if err := client.SyncNotify(ctx); err != nil {
 		t.Error(err)
 	}
 
-	syncSt, err := client.SyncStatus("HEAD")
+	syncSt, err := client.SyncStatus(ctx, "HEAD")
 	if err != nil {
 		t.Error(err)
 	}
 	if !reflect.DeepEqual(mock.SyncStatusAnswer, syncSt) {
 		t.Error(fmt.Errorf("expected: %#v\ngot: %#v"), mock.SyncStatusAnswer, syncSt)
 	}
+
+	mock.JobLogAnswer = job.Log{Output: "git push\n", Truncated: true}
+	jobLog, err := client.JobLog(ctx, jobid)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.JobLogAnswer, jobLog) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.JobLogAnswer, jobLog)
+	}
+
+	mock.WaitJobStatusAnswer = job.Status{StatusString: job.StatusRunning}
+	jobStatus, err := client.WaitJobStatus(ctx, job.WaitRequest{ID: jobid, Last: job.StatusQueued})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.WaitJobStatusAnswer, jobStatus) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.WaitJobStatusAnswer, jobStatus)
+	}
+
+	mock.ClusterConfigAnswer = flux.ClusterConfig{ExcludeKinds: []string{"Secret"}, ConfigVersion: "0123456789abcdef"}
+	clusterConfig, err := client.ClusterConfig(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ClusterConfigAnswer, clusterConfig) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.ClusterConfigAnswer, clusterConfig)
+	}
+
+	mock.EvaluateImageAnswer = update.Result{
+		flux.ServiceID("default/service1"): update.ServiceResult{Status: update.ReleaseStatusSkipped, Error: update.Locked},
+	}
+	evaluation, err := client.EvaluateImage(ctx, flux.ImageID{Namespace: "weaveworks", Image: "helloworld", Tag: "v2"})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.EvaluateImageAnswer, evaluation) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.EvaluateImageAnswer, evaluation)
+	}
+
+	mock.ServiceTopologyAnswer = []flux.ServiceTopology{
+		{
+			ID:   flux.ServiceID("default/service1"),
+			File: "service1-svc.yaml",
+			Workloads: []flux.Workload{
+				{Kind: "Deployment", Namespace: "default", Name: "service1", File: "service1-dep.yaml"},
+			},
+		},
+	}
+	topology, err := client.ServiceTopology(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ServiceTopologyAnswer, topology) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.ServiceTopologyAnswer, topology)
+	}
+
+	mock.ExportChunkAnswer = ExportChunk{
+		ID:   "export-1",
+		Data: []byte("kind: Namespace\n"),
+		More: true,
+	}
+	chunk, err := client.ExportChunk(ctx, ExportChunkRequest{ID: "export-1", Offset: 1024})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ExportChunkAnswer, chunk) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.ExportChunkAnswer, chunk)
+	}
+
+	mock.ResetGitToRemoteAnswer = "abc123"
+	rev, err := client.ResetGitToRemote(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if rev != mock.ResetGitToRemoteAnswer {
+		t.Errorf("expected: %q\ngot: %q", mock.ResetGitToRemoteAnswer, rev)
+	}
+
+	mock.ExportAtArgTest = func(ref string) error {
+		if ref != "v1.0" {
+			return fmt.Errorf("expected ref %q, got %q", "v1.0", ref)
+		}
+		return nil
+	}
+	mock.ExportAtAnswer = []byte("kind: Deployment\n")
+	manifests, err := client.ExportAt(ctx, "v1.0")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ExportAtAnswer, manifests) {
+		t.Errorf("expected: %q\ngot: %q", mock.ExportAtAnswer, manifests)
+	}
+	mock.SyncHealthAnswer = SyncHealth{
+		Window:           24 * time.Hour,
+		Attempts:         10,
+		Successes:        9,
+		LastSuccess:      time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC),
+		SinceLastSuccess: 5 * time.Minute,
+		Objective:        &SyncObjective{Freshness: 15 * time.Minute, Target: 0.99},
+		StaleTime:        time.Minute,
+		BudgetRemaining:  0.93,
+	}
+	health, err := client.SyncHealth(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.SyncHealthAnswer, health) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.SyncHealthAnswer, health)
+	}
+
+	mock.ListResourcesAnswer = []flux.ResourceStatus{
+		{
+			ID:     "ClusterRole prometheus",
+			Kind:   "ClusterRole",
+			Name:   "prometheus",
+			File:   "monitoring/rbac.yaml",
+			Status: flux.ResourcePending,
+		},
+		{
+			ID:        "Deployment monitoring/prometheus",
+			Kind:      "Deployment",
+			Namespace: "monitoring",
+			Name:      "prometheus",
+			File:      "monitoring/prometheus-dep.yaml",
+			Revision:  "a1b2c3d",
+			Status:    flux.ResourceFailed,
+			Error:     "the server could not find the requested resource",
+		},
+	}
+	resources, err := client.ListResources(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ListResourcesAnswer, resources) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.ListResourcesAnswer, resources)
+	}
+
+	requested := time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC)
+	mock.SyncWaitArgTest = func(req SyncWaitRequest) error {
+		if !req.Since.Equal(requested) {
+			return fmt.Errorf("expected sync requested at %s, got %s", requested, req.Since)
+		}
+		return nil
+	}
+	mock.SyncWaitAnswer = SyncReport{
+		Requested: requested,
+		Done:      true,
+		Revision:  "a1b2c3d4e5f6",
+		Started:   requested.Add(time.Second),
+		Duration:  12 * time.Second,
+		Applied:   []string{"Deployment default/helloworld"},
+		Unchanged: []string{"Namespace monitoring"},
+		Failed: []ResourceError{
+			{ID: "Deployment monitoring/prometheus", Error: "the server could not find the requested resource"},
+		},
+	}
+	report, err := client.SyncWait(ctx, SyncWaitRequest{Since: requested})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.SyncWaitAnswer, report) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.SyncWaitAnswer, report)
+	}
+
+	config := service.InstanceConfig{
+		SyncInterval:      "1m",
+		NamespacePolicies: policy.NamespaceDefaults{"dev": policy.Set{policy.Automated: "true"}},
+		Registries:        []service.RegistryConfig{{Host: "quay.io", Username: "robot", Secret: "quay-password"}},
+	}.DaemonConfig()
+	mock.ApplyConfigArgTest = func(got service.DaemonConfig) error {
+		if !reflect.DeepEqual(config, got) {
+			return fmt.Errorf("expected config %#v, got %#v", config, got)
+		}
+		return nil
+	}
+	if err := client.ApplyConfig(ctx, config); err != nil {
+		t.Error(err)
+	}
+
+	releaseSpec := update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{update.ServiceSpecAll},
+		ImageSpec:    update.ImageSpecLatest,
+		Kind:         update.ReleaseKindPlan,
+		Excludes:     []flux.ServiceID{flux.ServiceID("default/excluded")},
+	}
+	mock.PlanReleaseArgTest = func(got update.ReleaseSpec) error {
+		if !reflect.DeepEqual(releaseSpec, got) {
+			return fmt.Errorf("expected release spec %#v, got %#v", releaseSpec, got)
+		}
+		return nil
+	}
+	current, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000001")
+	target, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000002")
+	mock.PlanReleaseAnswer = update.Result{
+		flux.ServiceID("default/service1"): update.ServiceResult{
+			Status: update.ReleaseStatusSuccess,
+			PerContainer: []update.ContainerUpdate{
+				{Container: "helloworld", Current: current, Target: target},
+			},
+			Diff: "-        image: quay.io/weaveworks/helloworld:master-a000001\n+        image: quay.io/weaveworks/helloworld:master-a000002\n",
+		},
+	}
+	plan, err := client.PlanRelease(ctx, releaseSpec)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.PlanReleaseAnswer, plan) {
+		t.Errorf("expected: %#v\ngot: %#v", mock.PlanReleaseAnswer, plan)
+	}
 }
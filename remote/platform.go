@@ -3,6 +3,8 @@
 package remote
 
 import (
+	"context"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/service"
@@ -13,8 +15,8 @@ import (
 // `Platform`.
 
 type PlatformV4 interface {
-	Ping() error
-	Version() (string, error)
+	Ping(context.Context) error
+	Version(context.Context) (string, error)
 	// Deprecated
 	//	AllServices(maybeNamespace string, ignored flux.ServiceIDSet) ([]Service, error)
 	//	SomeServices([]flux.ServiceID) ([]Service, error)
@@ -26,7 +28,7 @@ type PlatformV5 interface {
 	// We still support this, for bootstrapping; but it might
 	// reasonably be moved to the daemon interface, or removed in
 	// favour of letting people use their cluster-specific tooling.
-	Export() ([]byte, error)
+	Export(context.Context) ([]byte, error)
 	// Deprecated
 	//	Sync(SyncDef) error
 }
@@ -38,24 +40,83 @@ type PlatformV5 interface {
 type PlatformV6 interface {
 	PlatformV5
 	// These are new, or newly moved to this interface
-	ListServices(namespace string) ([]flux.ServiceStatus, error)
-	ListImages(update.ServiceSpec) ([]flux.ImageStatus, error)
+	ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error)
+	ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error)
 	// Send a spec for updating config to the daemon
-	UpdateManifests(update.Spec) (job.ID, error)
+	UpdateManifests(context.Context, update.Spec) (job.ID, error)
 	// Poke the daemon to sync with git
-	SyncNotify() error
+	SyncNotify(context.Context) error
 	// Ask the daemon where it's up to with syncing
-	SyncStatus(string) ([]string, error)
+	SyncStatus(context.Context, string) ([]string, error)
 	// Ask the daemon where it's up to with job processing
-	JobStatus(job.ID) (job.Status, error)
+	JobStatus(context.Context, job.ID) (job.Status, error)
 	// Get the daemon's public SSH key
-	GitRepoConfig(regenerate bool) (flux.GitConfig, error)
+	GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error)
+}
+
+// V7 is negotiated when the daemon connects, rather than being
+// assumed (see the rpc package). A daemon that speaks it has all of
+// these methods; some were added to V6 piecemeal, and clients
+// speaking V6 have to cope with daemons that don't have them.
+type PlatformV7 interface {
+	PlatformV6
+	// Get the log captured while running a job
+	JobLog(context.Context, job.ID) (job.Log, error)
+	// Wait for the status of a job to change from that given, and
+	// return it. This may give back the same status, if nothing has
+	// changed for a while.
+	WaitJobStatus(context.Context, job.WaitRequest) (job.Status, error)
+	// ClusterConfig reports how the daemon is configured to treat the
+	// cluster; e.g., which kinds of resource it leaves alone.
+	ClusterConfig(context.Context) (flux.ClusterConfig, error)
+	// EvaluateImage reports what automation would do, were the image
+	// given to appear in its repository.
+	EvaluateImage(context.Context, flux.ImageID) (update.Result, error)
+	// ServiceTopology reports which workloads each service defined
+	// in the repo selects, and the files they're defined in.
+	ServiceTopology(context.Context) ([]flux.ServiceTopology, error)
+	// ExportChunk gets a piece of the cluster's configuration, so
+	// that a large export can be sent without holding it all in
+	// memory. See ExportTo for how the chunks fit together.
+	ExportChunk(context.Context, ExportChunkRequest) (ExportChunk, error)
+	// ResetGitToRemote makes the daemon carry on from the branch as
+	// it is at the remote, after it has diverged from what the
+	// daemon had (e.g., because of a force push). It returns the
+	// revision the daemon is now at.
+	ResetGitToRemote(context.Context) (string, error)
+	// ExportAt gives the manifests in the git repo as they were at
+	// the revision given (a commit or tag), as a YAML stream.
+	ExportAt(ctx context.Context, ref string) ([]byte, error)
+	// InjectFault makes the daemon simulate a failure for a while,
+	// so that alerting can be tested. It's only for use in staging.
+	InjectFault(context.Context, FaultSpec) error
+	// SyncHealth reports how well the daemon has been keeping the
+	// cluster in sync with the repo, and against its objective for
+	// that, if it has one.
+	SyncHealth(context.Context) (SyncHealth, error)
+	// ListResources reports all the resources defined in the repo,
+	// including those that aren't workloads, and how each fared in
+	// the most recent sync.
+	ListResources(context.Context) ([]flux.ResourceStatus, error)
+	// SyncWait waits for a full sync to finish, asking for one if
+	// the request is fresh, and reports how it went. Like
+	// WaitJobStatus, it may answer before then; the report says
+	// whether it's done.
+	SyncWait(context.Context, SyncWaitRequest) (SyncReport, error)
+	// ApplyConfig gives the daemon its part of the instance config,
+	// in place of any it was given before. Once it returns without
+	// error, the daemon reports the config's version as applied.
+	ApplyConfig(context.Context, service.DaemonConfig) error
+	// PlanRelease works out what the release would do, including
+	// the change to each manifest, and answers with that rather than
+	// starting a job.
+	PlanRelease(context.Context, update.ReleaseSpec) (update.Result, error)
 }
 
 // Platform is the SPI for the daemon; i.e., it's all the things we
 // have to ask to the daemon, rather than the service.
 type Platform interface {
-	PlatformV6
+	PlatformV7
 }
 
 // Wrap errors in this to indicate that the platform should be
@@ -1,11 +1,14 @@
 package rpc
 
 import (
+	"context"
+
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -13,43 +16,99 @@ type baseClient struct{}
 
 var _ remote.Platform = baseClient{}
 
-func (bc baseClient) Version() (string, error) {
+func (bc baseClient) Version(ctx context.Context) (string, error) {
 	return "", remote.UpgradeNeededError(errors.New("Version method not implemented"))
 }
 
-func (bc baseClient) Ping() error {
+func (bc baseClient) Ping(ctx context.Context) error {
 	return remote.UpgradeNeededError(errors.New("Ping method not implemented"))
 }
 
-func (bc baseClient) Export() ([]byte, error) {
+func (bc baseClient) Export(ctx context.Context) ([]byte, error) {
 	return nil, remote.UpgradeNeededError(errors.New("Export method not implemented"))
 }
 
-func (bc baseClient) ListServices(string) ([]flux.ServiceStatus, error) {
+func (bc baseClient) ListServices(context.Context, string) ([]flux.ServiceStatus, error) {
 	return nil, remote.UpgradeNeededError(errors.New("ListServices method not implemented"))
 }
 
-func (bc baseClient) ListImages(update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (bc baseClient) ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error) {
 	return nil, remote.UpgradeNeededError(errors.New("ListImages method not implemented"))
 }
 
-func (bc baseClient) UpdateManifests(update.Spec) (job.ID, error) {
+func (bc baseClient) UpdateManifests(context.Context, update.Spec) (job.ID, error) {
 	var id job.ID
 	return id, remote.UpgradeNeededError(errors.New("UpdateManifests method not implemented"))
 }
 
-func (bc baseClient) SyncNotify() error {
+func (bc baseClient) SyncNotify(ctx context.Context) error {
 	return remote.UpgradeNeededError(errors.New("SyncNotify method not implemented"))
 }
 
-func (bc baseClient) JobStatus(job.ID) (job.Status, error) {
+func (bc baseClient) JobStatus(context.Context, job.ID) (job.Status, error) {
 	return job.Status{}, remote.UpgradeNeededError(errors.New("JobStatus method not implemented"))
 }
 
-func (bc baseClient) SyncStatus(string) ([]string, error) {
+func (bc baseClient) SyncStatus(context.Context, string) ([]string, error) {
 	return nil, remote.UpgradeNeededError(errors.New("SyncStatus method not implemented"))
 }
 
-func (bc baseClient) GitRepoConfig(bool) (flux.GitConfig, error) {
+func (bc baseClient) GitRepoConfig(context.Context, bool) (flux.GitConfig, error) {
 	return flux.GitConfig{}, remote.UpgradeNeededError(errors.New("GitRepoConfig method not implemented"))
 }
+
+func (bc baseClient) JobLog(context.Context, job.ID) (job.Log, error) {
+	return job.Log{}, remote.UpgradeNeededError(errors.New("JobLog method not implemented"))
+}
+
+func (bc baseClient) WaitJobStatus(context.Context, job.WaitRequest) (job.Status, error) {
+	return job.Status{}, remote.UpgradeNeededError(errors.New("WaitJobStatus method not implemented"))
+}
+
+func (bc baseClient) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	return flux.ClusterConfig{}, remote.UpgradeNeededError(errors.New("ClusterConfig method not implemented"))
+}
+
+func (bc baseClient) EvaluateImage(context.Context, flux.ImageID) (update.Result, error) {
+	return nil, remote.UpgradeNeededError(errors.New("EvaluateImage method not implemented"))
+}
+
+func (bc baseClient) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	return nil, remote.UpgradeNeededError(errors.New("ServiceTopology method not implemented"))
+}
+
+func (bc baseClient) ExportChunk(context.Context, remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	return remote.ExportChunk{}, remote.UpgradeNeededError(errors.New("ExportChunk method not implemented"))
+}
+
+func (bc baseClient) ResetGitToRemote(context.Context) (string, error) {
+	return "", remote.UpgradeNeededError(errors.New("ResetGitToRemote method not implemented"))
+}
+
+func (bc baseClient) ExportAt(context.Context, string) ([]byte, error) {
+	return nil, remote.UpgradeNeededError(errors.New("ExportAt method not implemented"))
+}
+
+func (bc baseClient) InjectFault(context.Context, remote.FaultSpec) error {
+	return remote.UpgradeNeededError(errors.New("InjectFault method not implemented"))
+}
+
+func (bc baseClient) SyncHealth(context.Context) (remote.SyncHealth, error) {
+	return remote.SyncHealth{}, remote.UpgradeNeededError(errors.New("SyncHealth method not implemented"))
+}
+
+func (bc baseClient) ListResources(context.Context) ([]flux.ResourceStatus, error) {
+	return nil, remote.UpgradeNeededError(errors.New("ListResources method not implemented"))
+}
+
+func (bc baseClient) SyncWait(context.Context, remote.SyncWaitRequest) (remote.SyncReport, error) {
+	return remote.SyncReport{}, remote.UpgradeNeededError(errors.New("SyncWait method not implemented"))
+}
+
+func (bc baseClient) ApplyConfig(context.Context, service.DaemonConfig) error {
+	return remote.UpgradeNeededError(errors.New("ApplyConfig method not implemented"))
+}
+
+func (bc baseClient) PlanRelease(context.Context, update.ReleaseSpec) (update.Result, error) {
+	return nil, remote.UpgradeNeededError(errors.New("PlanRelease method not implemented"))
+}
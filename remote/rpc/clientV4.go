@@ -1,10 +1,15 @@
 package rpc
 
 import (
+	"context"
 	"io"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/remote"
 )
 
@@ -12,30 +17,33 @@ import (
 // talking to remote daemons.
 type RPCClientV4 struct {
 	*baseClient
-	client *rpc.Client
+	client   *rpc.Client
+	timeouts Timeouts
+	// whether the codec can send metadata along with calls
+	sendMetadata bool
 }
 
 var _ remote.PlatformV4 = &RPCClientV4{}
 
 // NewClient creates a new rpc-backed implementation of the platform.
 func NewClientV4(conn io.ReadWriteCloser) *RPCClientV4 {
-	return &RPCClientV4{&baseClient{}, jsonrpc.NewClient(conn)}
+	return &RPCClientV4{&baseClient{}, jsonrpc.NewClient(conn), DefaultTimeouts, false}
 }
 
 // Ping is used to check if the remote platform is available.
-func (p *RPCClientV4) Ping() error {
-	err := p.client.Call("RPCServer.Ping", struct{}{}, nil)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+func (p *RPCClientV4) Ping(ctx context.Context) error {
+	err := p.call(ctx, "RPCServer.Ping", struct{}{}, nil)
+	if isFatal(ctx, err) {
 		return remote.FatalError{err}
 	}
 	return err
 }
 
 // Version is used to check if the remote platform is available
-func (p *RPCClientV4) Version() (string, error) {
+func (p *RPCClientV4) Version(ctx context.Context) (string, error) {
 	var version string
-	err := p.client.Call("RPCServer.Version", struct{}{}, &version)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.Version", struct{}{}, &version)
+	if isFatal(ctx, err) {
 		return "", remote.FatalError{err}
 	} else if err != nil && err.Error() == "rpc: can't find method RPCServer.Version" {
 		// "Version" is not supported by this version of fluxd (it is old). Fail
@@ -45,6 +53,50 @@ func (p *RPCClientV4) Version() (string, error) {
 	return version, err
 }
 
+// A daemon that knows about timeouts will answer when a call's time
+// is up; give it a little longer than that to get the answer back,
+// before giving up on it.
+const timeoutGrace = 5 * time.Second
+
+// call makes an RPC, returning early if the context is cancelled
+// first, or if the daemon doesn't answer within the method's
+// timeout. There's no way to tell the daemon to stop, so it will
+// carry on regardless; but the caller doesn't have to wait for it.
+func (p *RPCClientV4) call(ctx context.Context, method string, args, reply interface{}) error {
+	timeout := p.timeouts.For(method)
+	timer := time.NewTimer(timeout + timeoutGrace)
+	defer timer.Stop()
+	var appErr error
+	if p.sendMetadata {
+		args = callArgs{args, remote.MetadataFrom(ctx), &appErr}
+	}
+	call := p.client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		// The error as it was, if the daemon sent enough to put it
+		// back together
+		if appErr != nil {
+			return appErr
+		}
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return remote.UnavailableError(errors.Errorf("no answer from the daemon to %s within %s", method, timeout))
+	}
+}
+
+// isFatal says whether an error from call means the connection is no
+// good. Errors from the method itself, the caller giving up, and
+// timeouts (which may just mean the daemon is busy) don't.
+func isFatal(ctx context.Context, err error) bool {
+	switch err.(type) {
+	case nil, rpc.ServerError, flux.HelpfulError:
+		return false
+	}
+	return err != ctx.Err()
+}
+
 // Close closes the connection to the remote platform, it does *not* cause the
 // remote platform to shut down.
 func (p *RPCClientV4) Close() error {
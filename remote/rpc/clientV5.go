@@ -1,8 +1,8 @@
 package rpc
 
 import (
+	"context"
 	"io"
-	"net/rpc"
 
 	"github.com/weaveworks/flux/remote"
 )
@@ -21,10 +21,10 @@ func NewClientV5(conn io.ReadWriteCloser) *RPCClientV5 {
 }
 
 // Export is used to get service configuration in platform-specific format
-func (p *RPCClientV5) Export() ([]byte, error) {
+func (p *RPCClientV5) Export(ctx context.Context) ([]byte, error) {
 	var config []byte
-	err := p.client.Call("RPCServer.Export", struct{}{}, &config)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.Export", struct{}{}, &config)
+	if isFatal(ctx, err) {
 		return nil, remote.FatalError{err}
 	}
 	return config, err
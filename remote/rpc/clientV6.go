@@ -1,12 +1,15 @@
 package rpc
 
 import (
+	"context"
 	"io"
 	"net/rpc"
+	"strings"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -24,75 +27,252 @@ func NewClientV6(conn io.ReadWriteCloser) *RPCClientV6 {
 }
 
 // Export is used to get service configuration in platform-specific format
-func (p *RPCClientV6) Export() ([]byte, error) {
+func (p *RPCClientV6) Export(ctx context.Context) ([]byte, error) {
 	var config []byte
-	err := p.client.Call("RPCServer.Export", struct{}{}, &config)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.Export", struct{}{}, &config)
+	if isFatal(ctx, err) {
 		return nil, remote.FatalError{err}
 	}
 	return config, err
 }
 
 // Export is used to get service configuration in platform-specific format
-func (p *RPCClientV6) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+func (p *RPCClientV6) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
 	var services []flux.ServiceStatus
-	err := p.client.Call("RPCServer.ListServices", namespace, &services)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.ListServices", namespace, &services)
+	if isFatal(ctx, err) {
 		return nil, remote.FatalError{err}
 	}
 	return services, err
 }
 
-func (p *RPCClientV6) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (p *RPCClientV6) ListImages(ctx context.Context, spec update.ServiceSpec) ([]flux.ImageStatus, error) {
 	var images []flux.ImageStatus
-	err := p.client.Call("RPCServer.ListImages", spec, &images)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.ListImages", spec, &images)
+	if isFatal(ctx, err) {
 		return nil, remote.FatalError{err}
 	}
 	return images, err
 }
 
-func (p *RPCClientV6) UpdateManifests(u update.Spec) (job.ID, error) {
+func (p *RPCClientV6) UpdateManifests(ctx context.Context, u update.Spec) (job.ID, error) {
 	var result job.ID
-	err := p.client.Call("RPCServer.UpdateManifests", u, &result)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.UpdateManifests", u, &result)
+	if isFatal(ctx, err) {
 		return result, remote.FatalError{err}
 	}
 	return result, err
 }
 
-func (p *RPCClientV6) SyncNotify() error {
+func (p *RPCClientV6) SyncNotify(ctx context.Context) error {
 	var result struct{}
-	err := p.client.Call("RPCServer.SyncNotify", struct{}{}, &result)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.SyncNotify", struct{}{}, &result)
+	if isFatal(ctx, err) {
 		return remote.FatalError{err}
 	}
 	return err
 }
 
-func (p *RPCClientV6) JobStatus(jobID job.ID) (job.Status, error) {
+func (p *RPCClientV6) JobStatus(ctx context.Context, jobID job.ID) (job.Status, error) {
 	var result job.Status
-	err := p.client.Call("RPCServer.JobStatus", jobID, &result)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.JobStatus", jobID, &result)
+	if isFatal(ctx, err) {
 		return job.Status{}, remote.FatalError{err}
 	}
 	return result, err
 }
 
-func (p *RPCClientV6) SyncStatus(ref string) ([]string, error) {
+func (p *RPCClientV6) SyncStatus(ctx context.Context, ref string) ([]string, error) {
 	var result []string
-	err := p.client.Call("RPCServer.SyncStatus", ref, &result)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.SyncStatus", ref, &result)
+	if isFatal(ctx, err) {
 		return nil, remote.FatalError{err}
 	}
 	return result, err
 }
 
-func (p *RPCClientV6) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+func (p *RPCClientV6) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
 	var result flux.GitConfig
-	err := p.client.Call("RPCServer.GitRepoConfig", regenerate, &result)
-	if _, ok := err.(rpc.ServerError); !ok && err != nil {
+	err := p.call(ctx, "RPCServer.GitRepoConfig", regenerate, &result)
+	if isFatal(ctx, err) {
 		return flux.GitConfig{}, remote.FatalError{err}
 	}
 	return result, err
 }
+
+func (p *RPCClientV6) JobLog(ctx context.Context, id job.ID) (job.Log, error) {
+	var result job.Log
+	err := p.call(ctx, "RPCServer.JobLog", id, &result)
+	if isFatal(ctx, err) {
+		return job.Log{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return job.Log{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) WaitJobStatus(ctx context.Context, req job.WaitRequest) (job.Status, error) {
+	var result job.Status
+	err := p.call(ctx, "RPCServer.WaitJobStatus", req, &result)
+	if isFatal(ctx, err) {
+		return job.Status{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return job.Status{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	var result flux.ClusterConfig
+	err := p.call(ctx, "RPCServer.ClusterConfig", struct{}{}, &result)
+	if isFatal(ctx, err) {
+		return flux.ClusterConfig{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return flux.ClusterConfig{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) EvaluateImage(ctx context.Context, image flux.ImageID) (update.Result, error) {
+	var result update.Result
+	err := p.call(ctx, "RPCServer.EvaluateImage", image, &result)
+	if isFatal(ctx, err) {
+		return nil, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return nil, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	var result []flux.ServiceTopology
+	err := p.call(ctx, "RPCServer.ServiceTopology", struct{}{}, &result)
+	if isFatal(ctx, err) {
+		return nil, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return nil, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ExportChunk(ctx context.Context, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	var result remote.ExportChunk
+	err := p.call(ctx, "RPCServer.ExportChunk", req, &result)
+	if isFatal(ctx, err) {
+		return remote.ExportChunk{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return remote.ExportChunk{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ResetGitToRemote(ctx context.Context) (string, error) {
+	var result string
+	err := p.call(ctx, "RPCServer.ResetGitToRemote", struct{}{}, &result)
+	if isFatal(ctx, err) {
+		return "", remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return "", remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	var result []byte
+	err := p.call(ctx, "RPCServer.ExportAt", ref, &result)
+	if isFatal(ctx, err) {
+		return nil, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return nil, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) InjectFault(ctx context.Context, spec remote.FaultSpec) error {
+	var result struct{}
+	err := p.call(ctx, "RPCServer.InjectFault", spec, &result)
+	if isFatal(ctx, err) {
+		return remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return remote.UpgradeNeededError(err)
+	}
+	return err
+}
+
+func (p *RPCClientV6) SyncHealth(ctx context.Context) (remote.SyncHealth, error) {
+	var result remote.SyncHealth
+	err := p.call(ctx, "RPCServer.SyncHealth", struct{}{}, &result)
+	if isFatal(ctx, err) {
+		return remote.SyncHealth{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return remote.SyncHealth{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	var result []flux.ResourceStatus
+	err := p.call(ctx, "RPCServer.ListResources", struct{}{}, &result)
+	if isFatal(ctx, err) {
+		return nil, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return nil, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) SyncWait(ctx context.Context, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	var result remote.SyncReport
+	err := p.call(ctx, "RPCServer.SyncWait", req, &result)
+	if isFatal(ctx, err) {
+		return remote.SyncReport{}, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return remote.SyncReport{}, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+func (p *RPCClientV6) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	var result struct{}
+	err := p.call(ctx, "RPCServer.ApplyConfig", config, &result)
+	if isFatal(ctx, err) {
+		return remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return remote.UpgradeNeededError(err)
+	}
+	return err
+}
+
+func (p *RPCClientV6) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	var result update.Result
+	err := p.call(ctx, "RPCServer.PlanRelease", spec, &result)
+	if isFatal(ctx, err) {
+		return nil, remote.FatalError{err}
+	}
+	if isMethodNotFound(err) {
+		return nil, remote.UpgradeNeededError(err)
+	}
+	return result, err
+}
+
+// Daemons from before a method was added to the protocol will answer
+// with an error from the rpc package, rather than the method.
+func isMethodNotFound(err error) bool {
+	if err, ok := err.(rpc.ServerError); ok {
+		return strings.HasPrefix(string(err), "rpc: can't find method ")
+	}
+	return false
+}
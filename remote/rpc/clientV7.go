@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+
+	"github.com/weaveworks/flux/remote"
+)
+
+// RPCClientV7 is the rpc-backed implementation of a platform, for
+// talking to daemons that have negotiated V7 of the protocol. Those
+// daemons have all the methods of remote.PlatformV7, which the V6
+// client already knows how to call; methods new to V7 go here.
+type RPCClientV7 struct {
+	*RPCClientV6
+}
+
+var _ remote.PlatformV7 = &RPCClientV7{}
+
+// NewClientV7 creates a new rpc-backed implementation of the
+// platform. Each call tells the daemon how long it has to answer,
+// according to the timeouts given, and passes along the metadata in
+// its context.
+func NewClientV7(conn io.ReadWriteCloser, timeouts Timeouts) *RPCClientV7 {
+	return newClientV7(newClientCodec(conn, timeouts), timeouts)
+}
+
+func newClientV7(codec rpc.ClientCodec, timeouts Timeouts) *RPCClientV7 {
+	client := rpc.NewClientWithCodec(codec)
+	v4 := &RPCClientV4{&baseClient{}, client, timeouts, true}
+	return &RPCClientV7{&RPCClientV6{&RPCClientV5{v4}}}
+}
@@ -0,0 +1,450 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux/remote"
+)
+
+// The codecs here speak the same JSON-RPC as net/rpc/jsonrpc, with
+// two additions: a request can say how long the caller will wait for
+// an answer, as "timeout" in milliseconds; and it can carry metadata
+// (the request ID and user it's on behalf of), as "metadata". The
+// server answers with an error if the method hasn't returned by the
+// timeout (though there's no way to stop the method), and drops the
+// method's own answer if it comes later. Since the fields are ignored
+// by servers that don't know about them, and optional for clients,
+// the server codec is used for every version of the protocol, and the
+// client codec for V7.
+//
+// An answer with an error can also carry the detail of the error
+// (e.g., the help for it), as "applicationError", so that the client
+// can give back an error of the same kind rather than just the
+// message. Clients that don't know about it will ignore it.
+//
+// V7 connections can also use gob in place of JSON (see codec_gob.go).
+// The codecs here look after timeouts, metadata and errors, and leave
+// the encoding of requests and responses to a clientEncoding or
+// serverEncoding.
+
+// clientEncoding writes requests and reads responses on the client
+// side of a connection.
+type clientEncoding interface {
+	writeRequest(h requestHeader, param interface{}) error
+	// readResponseHeader gives the sequence number of the request
+	// answered, and the error, if the answer is one, along with its
+	// detail if that was sent.
+	readResponseHeader() (seq uint64, errMsg string, appErr *remote.ApplicationError, err error)
+	readResponseBody(x interface{}) error
+}
+
+// serverEncoding reads requests and writes responses on the server
+// side of a connection.
+type serverEncoding interface {
+	readRequest() (incomingRequest, error)
+	// writeResponse answers the request with the ID given, with
+	// either the result or the error (and its detail, if there's
+	// any). It's only called with the serverCodec locked.
+	writeResponse(id interface{}, result interface{}, errMsg string, appErr *remote.ApplicationError) error
+}
+
+// requestHeader is everything about a request except its argument.
+type requestHeader struct {
+	method   string
+	seq      uint64
+	timeout  int64 // in milliseconds
+	metadata *remote.Metadata
+}
+
+// incomingRequest is a request as read by the server. The ID is
+// whatever the encoding wants back in the response; params decodes
+// the argument, and is called exactly once.
+type incomingRequest struct {
+	method   string
+	id       interface{}
+	timeout  int64
+	metadata remote.Metadata
+	params   func(x interface{}) error
+}
+
+type clientRequest struct {
+	Method   string           `json:"method"`
+	Params   [1]interface{}   `json:"params"`
+	ID       uint64           `json:"id"`
+	Timeout  int64            `json:"timeout,omitempty"`
+	Metadata *remote.Metadata `json:"metadata,omitempty"`
+}
+
+// callArgs is how the client gets the metadata for a call to the
+// codec, since net/rpc only passes the arguments along. Likewise,
+// net/rpc only gives back the message of an error; so if the answer
+// comes with the detail of the error, the codec puts the error
+// reconstructed from it in appErr.
+type callArgs struct {
+	args     interface{}
+	metadata remote.Metadata
+	appErr   *error
+}
+
+type clientResponse struct {
+	ID               uint64                   `json:"id"`
+	Result           *json.RawMessage         `json:"result"`
+	Error            interface{}              `json:"error"`
+	ApplicationError *remote.ApplicationError `json:"applicationError"`
+}
+
+type pendingCall struct {
+	method string
+	appErr *error
+}
+
+type clientCodec struct {
+	enc      clientEncoding
+	c        io.Closer
+	timeouts Timeouts
+
+	mu      sync.Mutex
+	pending map[uint64]pendingCall
+}
+
+func newClientCodec(conn io.ReadWriteCloser, timeouts Timeouts) rpc.ClientCodec {
+	return newClientCodecWithEncoding(conn, newJSONClientEncoding(conn), timeouts)
+}
+
+func newClientCodecWithEncoding(conn io.Closer, enc clientEncoding, timeouts Timeouts) rpc.ClientCodec {
+	return &clientCodec{
+		enc:      enc,
+		c:        conn,
+		timeouts: timeouts,
+		pending:  map[uint64]pendingCall{},
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
+	h := requestHeader{
+		method:  r.ServiceMethod,
+		seq:     r.Seq,
+		timeout: int64(c.timeouts.For(r.ServiceMethod) / time.Millisecond),
+	}
+	call := pendingCall{method: r.ServiceMethod}
+	if a, ok := param.(callArgs); ok {
+		param = a.args
+		if a.metadata != (remote.Metadata{}) {
+			h.metadata = &a.metadata
+		}
+		call.appErr = a.appErr
+	}
+	c.mu.Lock()
+	c.pending[r.Seq] = call
+	c.mu.Unlock()
+	return c.enc.writeRequest(h, param)
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	seq, msg, appErr, err := c.enc.readResponseHeader()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	call := c.pending[seq]
+	delete(c.pending, seq)
+	c.mu.Unlock()
+
+	// This happens before net/rpc says the call is done, so the
+	// caller will see it
+	if appErr != nil && call.appErr != nil {
+		*call.appErr = appErr.Err()
+	}
+	r.ServiceMethod = call.method
+	r.Seq = seq
+	r.Error = msg
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(x interface{}) error {
+	return c.enc.readResponseBody(x)
+}
+
+func (c *clientCodec) Close() error {
+	return c.c.Close()
+}
+
+type jsonClientEncoding struct {
+	dec  *json.Decoder
+	enc  *json.Encoder
+	resp clientResponse
+}
+
+func newJSONClientEncoding(conn io.ReadWriter) *jsonClientEncoding {
+	return &jsonClientEncoding{
+		dec: json.NewDecoder(conn),
+		enc: json.NewEncoder(conn),
+	}
+}
+
+func (e *jsonClientEncoding) writeRequest(h requestHeader, param interface{}) error {
+	return e.enc.Encode(&clientRequest{
+		Method:   h.method,
+		Params:   [1]interface{}{param},
+		ID:       h.seq,
+		Timeout:  h.timeout,
+		Metadata: h.metadata,
+	})
+}
+
+func (e *jsonClientEncoding) readResponseHeader() (uint64, string, *remote.ApplicationError, error) {
+	e.resp = clientResponse{}
+	if err := e.dec.Decode(&e.resp); err != nil {
+		return 0, "", nil, err
+	}
+	if e.resp.Error != nil || e.resp.Result == nil {
+		msg, ok := e.resp.Error.(string)
+		if !ok {
+			return 0, "", nil, fmt.Errorf("invalid error %v", e.resp.Error)
+		}
+		if msg == "" {
+			msg = "unspecified error"
+		}
+		return e.resp.ID, msg, e.resp.ApplicationError, nil
+	}
+	return e.resp.ID, "", nil, nil
+}
+
+func (e *jsonClientEncoding) readResponseBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	return json.Unmarshal(*e.resp.Result, x)
+}
+
+// ---
+
+type serverRequest struct {
+	Method   string           `json:"method"`
+	Params   *json.RawMessage `json:"params"`
+	ID       *json.RawMessage `json:"id"`
+	Timeout  int64            `json:"timeout"`
+	Metadata remote.Metadata  `json:"metadata"`
+}
+
+type serverResponse struct {
+	ID               *json.RawMessage         `json:"id"`
+	Result           interface{}              `json:"result"`
+	Error            interface{}              `json:"error"`
+	ApplicationError *remote.ApplicationError `json:"applicationError,omitempty"`
+}
+
+type pendingRequest struct {
+	id      interface{}
+	method  string
+	timeout *time.Timer
+	// the detail of the error the method answered with, if it did
+	// (see RPCServer.answer)
+	appErr *remote.ApplicationError
+}
+
+// serverCodec reads requests from the connection, and gives each to
+// a requestCodec of its own, so that it can be served with the
+// metadata it came with.
+type serverCodec struct {
+	dec serverEncoding // only reading; see mu for writing
+	c   io.Closer
+
+	// mu guards writing to the connection as well as the pending
+	// requests, since a timeout may answer a request at any time
+	mu      sync.Mutex
+	enc     serverEncoding
+	seq     uint64
+	pending map[uint64]*pendingRequest
+}
+
+func newServerCodec(conn io.ReadWriteCloser) *serverCodec {
+	return newServerCodecWithEncoding(conn, newJSONServerEncoding(conn))
+}
+
+func newServerCodecWithEncoding(conn io.Closer, enc serverEncoding) *serverCodec {
+	return &serverCodec{
+		dec:     enc,
+		enc:     enc,
+		c:       conn,
+		pending: map[uint64]*pendingRequest{},
+	}
+}
+
+// readRequest reads the next request from the connection.
+func (c *serverCodec) readRequest() (*requestCodec, error) {
+	req, err := c.dec.readRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	seq := c.seq
+	p := &pendingRequest{id: req.id, method: req.method}
+	if req.timeout > 0 {
+		timeout := time.Duration(req.timeout) * time.Millisecond
+		p.timeout = time.AfterFunc(timeout, func() {
+			c.expire(seq, timeout)
+		})
+	}
+	c.pending[seq] = p
+	return &requestCodec{server: c, req: req, seq: seq}, nil
+}
+
+// setApplicationError keeps the detail of the error a method
+// answered with, to be sent along with the error message when the
+// request is answered.
+func (c *serverCodec) setApplicationError(seq uint64, appErr *remote.ApplicationError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pending[seq]; ok {
+		p.appErr = appErr
+	}
+}
+
+func (c *serverCodec) writeResponse(r *rpc.Response, x interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[r.Seq]
+	if !ok {
+		// Already answered, because it took too long
+		return nil
+	}
+	delete(c.pending, r.Seq)
+	if p.timeout != nil {
+		p.timeout.Stop()
+	}
+
+	var appErr *remote.ApplicationError
+	if r.Error != "" {
+		x, appErr = nil, p.appErr
+	}
+	return c.enc.writeResponse(p.id, x, r.Error, appErr)
+}
+
+// expire answers a request that has run out of time.
+func (c *serverCodec) expire(seq uint64, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[seq]
+	if !ok {
+		return
+	}
+	delete(c.pending, seq)
+	// If this fails, so will everything else on the connection,
+	// which will be noticed there
+	c.enc.writeResponse(p.id, nil, fmt.Sprintf("%s did not complete within %s", p.method, timeout), nil)
+}
+
+func (c *serverCodec) Close() error {
+	return c.c.Close()
+}
+
+// requestCodec is an rpc.ServerCodec for a single request, already
+// read from the connection; its response goes back via the
+// serverCodec, which keeps track of the request by its sequence
+// number.
+type requestCodec struct {
+	server *serverCodec
+	req    incomingRequest
+	seq    uint64
+	read   bool
+}
+
+func (c *requestCodec) ReadRequestHeader(r *rpc.Request) error {
+	if c.read {
+		return io.EOF
+	}
+	c.read = true
+	r.ServiceMethod = c.req.method
+	r.Seq = c.seq
+	return nil
+}
+
+// ReadRequestBody gives an RPCServer method the context for the
+// call, with the metadata sent with the request, leaving the method
+// to read the argument itself (see Call). Anything else, e.g., a nil
+// when net/rpc is discarding the argument, is read straight away.
+func (c *requestCodec) ReadRequestBody(x interface{}) error {
+	if call, ok := x.(*Call); ok {
+		call.ctx = remote.WithMetadata(context.Background(), c.req.metadata)
+		call.req = c
+		return nil
+	}
+	return c.req.params(x)
+}
+
+func (c *requestCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	return c.server.writeResponse(r, x)
+}
+
+// Close does nothing, since the connection is shared with other
+// requests.
+func (c *requestCodec) Close() error {
+	return nil
+}
+
+type jsonServerEncoding struct {
+	dec *json.Decoder
+	enc *json.Encoder
+}
+
+func newJSONServerEncoding(conn io.ReadWriter) *jsonServerEncoding {
+	return &jsonServerEncoding{
+		dec: json.NewDecoder(conn),
+		enc: json.NewEncoder(conn),
+	}
+}
+
+var errMissingParams = errors.New("jsonrpc: request body missing params")
+
+func (e *jsonServerEncoding) readRequest() (incomingRequest, error) {
+	var req serverRequest
+	if err := e.dec.Decode(&req); err != nil {
+		return incomingRequest{}, err
+	}
+	return incomingRequest{
+		method:   req.Method,
+		id:       req.ID,
+		timeout:  req.Timeout,
+		metadata: req.Metadata,
+		params: func(x interface{}) error {
+			if x == nil {
+				return nil
+			}
+			if req.Params == nil {
+				return errMissingParams
+			}
+			params := [1]interface{}{x}
+			return json.Unmarshal(*req.Params, &params)
+		},
+	}, nil
+}
+
+var null = json.RawMessage([]byte("null"))
+
+func (e *jsonServerEncoding) writeResponse(id interface{}, result interface{}, errMsg string, appErr *remote.ApplicationError) error {
+	resp := serverResponse{}
+	if raw, ok := id.(*json.RawMessage); ok && raw != nil {
+		resp.ID = raw
+	} else {
+		resp.ID = &null
+	}
+	if errMsg == "" {
+		resp.Result = result
+	} else {
+		resp.Error = errMsg
+		resp.ApplicationError = appErr
+	}
+	return e.enc.Encode(resp)
+}
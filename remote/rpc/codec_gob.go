@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"encoding/gob"
+	"io"
+	"sync"
+
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/update"
+)
+
+// The gob encoding is as net/rpc's own: a header, then the argument
+// or result as a value of its own. Since the header has to be read
+// before the type of the value is known, the value has to be read
+// before the next header can be; on the server, that means reading a
+// request waits for the previous request's argument to be read.
+
+func init() {
+	// update.Spec holds one of these, as an interface{}
+	gob.Register(update.ReleaseSpec{})
+	gob.Register(policy.Updates{})
+	gob.Register(update.Automated{})
+	gob.Register(update.BatchSpec{})
+	gob.Register(update.AddSpec{})
+	gob.Register(update.RollbackSpec{})
+}
+
+type gobRequest struct {
+	Method   string
+	Seq      uint64
+	Timeout  int64
+	Metadata remote.Metadata
+}
+
+type gobResponse struct {
+	Seq   uint64
+	Error string
+	// Left out by daemons from before it was added; gob doesn't
+	// mind fields that are missing, or that it doesn't know
+	ApplicationError *remote.ApplicationError
+}
+
+// The body sent in place of a result, when answering with an error.
+type gobNoResult struct{}
+
+type gobClientEncoding struct {
+	dec *gob.Decoder
+	enc *gob.Encoder
+}
+
+func newGobClientEncoding(conn io.ReadWriter) *gobClientEncoding {
+	return &gobClientEncoding{
+		dec: gob.NewDecoder(conn),
+		enc: gob.NewEncoder(conn),
+	}
+}
+
+func (e *gobClientEncoding) writeRequest(h requestHeader, param interface{}) error {
+	req := gobRequest{
+		Method:  h.method,
+		Seq:     h.seq,
+		Timeout: h.timeout,
+	}
+	if h.metadata != nil {
+		req.Metadata = *h.metadata
+	}
+	if err := e.enc.Encode(&req); err != nil {
+		return err
+	}
+	return e.enc.Encode(param)
+}
+
+func (e *gobClientEncoding) readResponseHeader() (uint64, string, *remote.ApplicationError, error) {
+	var resp gobResponse
+	if err := e.dec.Decode(&resp); err != nil {
+		return 0, "", nil, err
+	}
+	return resp.Seq, resp.Error, resp.ApplicationError, nil
+}
+
+// readResponseBody must be called after every header, even if only
+// to discard the body (which net/rpc does, with nil).
+func (e *gobClientEncoding) readResponseBody(x interface{}) error {
+	return e.dec.Decode(x)
+}
+
+type gobServerEncoding struct {
+	dec *gob.Decoder
+	enc *gob.Encoder
+	// closed when the last request's argument has been read
+	lastRead chan struct{}
+}
+
+func newGobServerEncoding(conn io.ReadWriter) *gobServerEncoding {
+	return &gobServerEncoding{
+		dec: gob.NewDecoder(conn),
+		enc: gob.NewEncoder(conn),
+	}
+}
+
+func (e *gobServerEncoding) readRequest() (incomingRequest, error) {
+	if e.lastRead != nil {
+		<-e.lastRead
+	}
+	var req gobRequest
+	if err := e.dec.Decode(&req); err != nil {
+		return incomingRequest{}, err
+	}
+	read := make(chan struct{})
+	e.lastRead = read
+	var once sync.Once
+	return incomingRequest{
+		method:   req.Method,
+		id:       req.Seq,
+		timeout:  req.Timeout,
+		metadata: req.Metadata,
+		params: func(x interface{}) (err error) {
+			once.Do(func() {
+				err = e.dec.Decode(x)
+				close(read)
+			})
+			return err
+		},
+	}, nil
+}
+
+func (e *gobServerEncoding) writeResponse(id interface{}, result interface{}, errMsg string, appErr *remote.ApplicationError) error {
+	seq, _ := id.(uint64)
+	if err := e.enc.Encode(&gobResponse{Seq: seq, Error: errMsg, ApplicationError: appErr}); err != nil {
+		return err
+	}
+	if errMsg != "" || result == nil {
+		result = gobNoResult{}
+	}
+	return e.enc.Encode(result)
+}
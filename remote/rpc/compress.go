@@ -0,0 +1,53 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// compressedConn deflates everything written to a connection, and
+// inflates everything read from it. Each write is flushed and sent
+// as one write to the connection underneath, so that (over a
+// websocket) each request or response is still a message of its own,
+// and the other end can decode it without waiting for more.
+type compressedConn struct {
+	conn io.ReadWriteCloser
+	r    io.Reader
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   *flate.Writer
+}
+
+func newCompressedConn(conn io.ReadWriteCloser) *compressedConn {
+	c := &compressedConn{conn: conn, r: flate.NewReader(conn)}
+	// This only fails for a bad compression level
+	c.w, _ = flate.NewWriter(&c.buf, flate.DefaultCompression)
+	return c
+}
+
+func (c *compressedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *compressedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Reset()
+	if _, err := c.w.Write(p); err != nil {
+		return 0, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return 0, err
+	}
+	if _, err := c.conn.Write(c.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *compressedConn) Close() error {
+	return c.conn.Close()
+}
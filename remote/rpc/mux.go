@@ -0,0 +1,188 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// muxConn interleaves the requests and responses written to a
+// connection, so that a big one (an Export, say) doesn't hold up the
+// others until it's all been sent. Each write is taken to be a whole
+// message, as it is with the JSON encoding; it's queued, and sent as
+// frames of at most muxFrameSize, taking turns with the other
+// messages queued. Each frame is tagged with the message it's part
+// of, and the other end gives each message to the decoder once it
+// has all its frames; since JSON-RPC answers carry the ID of the
+// request, it doesn't matter that they arrive in a different order.
+//
+// The gob encoding relies on messages arriving in the order they're
+// written, so it can't be used with this.
+type muxConn struct {
+	conn io.ReadWriteCloser
+
+	// Reading is done by the decoder, one call at a time; this
+	// guards against anything else doing so
+	readMu  sync.Mutex
+	partial map[uint64][]byte
+	ready   []byte
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*muxMessage
+	nextID  uint64
+	err     error
+	stopped bool
+}
+
+type muxMessage struct {
+	id   uint64
+	data []byte
+}
+
+const (
+	muxFrameSize = 32 * 1024
+	// A frame header is the message ID, the length of the data in
+	// the frame, and flags
+	muxHeaderSize = 8 + 4 + 1
+	muxFlagLast   = 1
+)
+
+var errMuxClosed = errors.New("connection closed")
+
+func newMuxConn(conn io.ReadWriteCloser) *muxConn {
+	c := &muxConn{
+		conn:    conn,
+		partial: map[uint64][]byte{},
+	}
+	c.cond = sync.NewCond(&c.mu)
+	go c.sendLoop()
+	return c
+}
+
+// Write queues a message to be sent, and returns without waiting for
+// it to go; an error in sending will be returned by a subsequent
+// write. The message is copied, since encoders reuse their buffers.
+func (c *muxConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.nextID++
+	c.queue = append(c.queue, &muxMessage{
+		id:   c.nextID,
+		data: append([]byte(nil), p...),
+	})
+	c.cond.Signal()
+	return len(p), nil
+}
+
+// sendLoop sends a frame from each message queued in turn, until the
+// connection fails or is closed.
+func (c *muxConn) sendLoop() {
+	header := make([]byte, muxHeaderSize)
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.stopped {
+			c.cond.Wait()
+		}
+		if c.stopped {
+			c.mu.Unlock()
+			return
+		}
+		msg := c.queue[0]
+		c.queue = c.queue[1:]
+		frame := msg.data
+		if len(frame) > muxFrameSize {
+			frame = frame[:muxFrameSize]
+		}
+		msg.data = msg.data[len(frame):]
+		last := len(msg.data) == 0
+		if !last {
+			// Go to the back of the queue
+			c.queue = append(c.queue, msg)
+		}
+		c.mu.Unlock()
+
+		binary.BigEndian.PutUint64(header[0:8], msg.id)
+		binary.BigEndian.PutUint32(header[8:12], uint32(len(frame)))
+		header[12] = 0
+		if last {
+			header[12] = muxFlagLast
+		}
+		// The header and data go in one write, so that over a
+		// websocket each frame is a message of its own
+		if _, err := c.conn.Write(append(header, frame...)); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *muxConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.stopped = true
+	c.queue = nil
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	c.conn.Close()
+}
+
+// Read gives the bytes of whole messages, in the order they were
+// completed.
+func (c *muxConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for len(c.ready) == 0 {
+		if err := c.readFrame(); err != nil {
+			// Nothing more will be read, so stop sending too
+			c.fail(err)
+			return 0, err
+		}
+	}
+	n := copy(p, c.ready)
+	c.ready = c.ready[n:]
+	return n, nil
+}
+
+func (c *muxConn) readFrame() error {
+	var header [muxHeaderSize]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		return err
+	}
+	id := binary.BigEndian.Uint64(header[0:8])
+	size := binary.BigEndian.Uint32(header[8:12])
+	if size > muxFrameSize {
+		return errors.Errorf("frame of %d bytes is bigger than the maximum of %d", size, muxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return err
+	}
+	msg := append(c.partial[id], data...)
+	if header[12]&muxFlagLast == 0 {
+		c.partial[id] = msg
+		return nil
+	}
+	delete(c.partial, id)
+	c.ready = append(c.ready, msg...)
+	return nil
+}
+
+func (c *muxConn) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errMuxClosed
+	}
+	c.stopped = true
+	c.queue = nil
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return c.conn.Close()
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMuxConnInterleaves(t *testing.T) {
+	clientConn, serverConn := pipes()
+	client, server := newMuxConn(clientConn), newMuxConn(serverConn)
+	defer client.Close()
+	defer server.Close()
+
+	big := bytes.Repeat([]byte("x"), 4*muxFrameSize+1)
+	small := []byte("small")
+	if _, err := server.Write(big); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Write(small); err != nil {
+		t.Fatal(err)
+	}
+
+	// The small message was written after the big one, but it
+	// shouldn't have to wait for all of the big one to be sent
+	got := make([]byte, len(small))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, small) {
+		t.Fatalf("expected small message first, got %q", got)
+	}
+	got = make([]byte, len(big))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, big) {
+		t.Error("expected big message to arrive intact")
+	}
+}
+
+func TestMuxConnClosed(t *testing.T) {
+	clientConn, serverConn := pipes()
+	client := newMuxConn(clientConn)
+	serverConn.Close()
+	client.Close()
+	if _, err := client.Write([]byte("hello")); err == nil {
+		t.Error("expected error writing to closed connection")
+	}
+}
@@ -1,6 +1,9 @@
 package nats
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"strings"
 	"time"
@@ -26,17 +29,31 @@ const (
 	presenceTick   = 50 * time.Millisecond
 	encoderType    = nats.JSON_ENCODER
 
-	methodKick            = ".Platform.Kick"
-	methodPing            = ".Platform.Ping"
-	methodVersion         = ".Platform.Version"
-	methodExport          = ".Platform.Export"
-	methodListServices    = ".Platform.ListServices"
-	methodListImages      = ".Platform.ListImages"
-	methodSyncNotify      = ".Platform.SyncNotify"
-	methodJobStatus       = ".Platform.JobStatus"
-	methodSyncStatus      = ".Platform.SyncStatus"
-	methodUpdateManifests = ".Platform.UpdateManifests"
-	methodGitRepoConfig   = ".Platform.GitRepoConfig"
+	methodKick             = ".Platform.Kick"
+	methodPing             = ".Platform.Ping"
+	methodVersion          = ".Platform.Version"
+	methodExport           = ".Platform.Export"
+	methodListServices     = ".Platform.ListServices"
+	methodListImages       = ".Platform.ListImages"
+	methodSyncNotify       = ".Platform.SyncNotify"
+	methodJobStatus        = ".Platform.JobStatus"
+	methodSyncStatus       = ".Platform.SyncStatus"
+	methodUpdateManifests  = ".Platform.UpdateManifests"
+	methodGitRepoConfig    = ".Platform.GitRepoConfig"
+	methodJobLog           = ".Platform.JobLog"
+	methodWaitJobStatus    = ".Platform.WaitJobStatus"
+	methodClusterConfig    = ".Platform.ClusterConfig"
+	methodEvaluateImage    = ".Platform.EvaluateImage"
+	methodServiceTopology  = ".Platform.ServiceTopology"
+	methodExportChunk      = ".Platform.ExportChunk"
+	methodResetGitToRemote = ".Platform.ResetGitToRemote"
+	methodExportAt         = ".Platform.ExportAt"
+	methodInjectFault      = ".Platform.InjectFault"
+	methodSyncHealth       = ".Platform.SyncHealth"
+	methodListResources    = ".Platform.ListResources"
+	methodSyncWait         = ".Platform.SyncWait"
+	methodApplyConfig      = ".Platform.ApplyConfig"
+	methodPlanRelease      = ".Platform.PlanRelease"
 )
 
 var timeout = defaultTimeout
@@ -109,6 +126,9 @@ func (n *NATS) Ping(instID service.InstanceID) error {
 type ErrorResponse struct {
 	Error string
 	Fatal bool
+	// The detail of the error, if there's more to it than the
+	// message; this is absent in responses from older daemons
+	ApplicationError *remote.ApplicationError `json:",omitempty"`
 }
 
 type ping struct{}
@@ -168,11 +188,82 @@ type GitRepoConfigResponse struct {
 	ErrorResponse
 }
 
+type JobLogResponse struct {
+	Result job.Log
+	ErrorResponse
+}
+
+type WaitJobStatusResponse struct {
+	Result job.Status
+	ErrorResponse
+}
+
+type ClusterConfigResponse struct {
+	Result flux.ClusterConfig
+	ErrorResponse
+}
+
+type EvaluateImageResponse struct {
+	Result update.Result
+	ErrorResponse
+}
+
+type ServiceTopologyResponse struct {
+	Result []flux.ServiceTopology
+	ErrorResponse
+}
+
+type ExportChunkResponse struct {
+	Result remote.ExportChunk
+	ErrorResponse
+}
+
+type ResetGitToRemoteResponse struct {
+	Result string
+	ErrorResponse
+}
+
+type ExportAtResponse struct {
+	Result []byte
+	ErrorResponse
+}
+
+type InjectFaultResponse struct {
+	ErrorResponse
+}
+
+type SyncHealthResponse struct {
+	Result remote.SyncHealth
+	ErrorResponse
+}
+
+type ListResourcesResponse struct {
+	Result []flux.ResourceStatus
+	ErrorResponse
+}
+
+type SyncWaitResponse struct {
+	Result remote.SyncReport
+	ErrorResponse
+}
+
+type ApplyConfigResponse struct {
+	ErrorResponse
+}
+
+type PlanReleaseResponse struct {
+	Result update.Result
+	ErrorResponse
+}
+
 func extractError(resp ErrorResponse) error {
 	if resp.Error != "" {
 		if resp.Fatal {
 			return remote.FatalError{errors.New(resp.Error)}
 		}
+		if resp.ApplicationError != nil {
+			return resp.ApplicationError.Err()
+		}
 		return rpc.ServerError(resp.Error)
 	}
 	return nil
@@ -186,9 +277,34 @@ func makeErrorResponse(err error) (resp ErrorResponse) {
 		resp.Fatal = true
 	}
 	resp.Error = err.Error()
+	resp.ApplicationError = remote.ApplicationErrorFrom(err)
 	return resp
 }
 
+// request is what's sent for each call, so that the metadata for
+// the call goes along with its arguments.
+type request struct {
+	Metadata remote.Metadata `json:"metadata"`
+	Args     interface{}     `json:"args"`
+}
+
+// Since the metadata is the first field, a request can be told apart
+// from the bare arguments sent by service instances that predate it.
+var requestPrefix = []byte(`{"metadata":`)
+
+// splitRequest gives the metadata and the encoded arguments from the
+// data of a request.
+func splitRequest(data []byte) (remote.Metadata, []byte) {
+	var req struct {
+		Metadata remote.Metadata `json:"metadata"`
+		Args     json.RawMessage `json:"args"`
+	}
+	if !bytes.HasPrefix(data, requestPrefix) || json.Unmarshal(data, &req) != nil {
+		return remote.Metadata{}, data
+	}
+	return req.Metadata, req.Args
+}
+
 // natsPlatform collects the things you need to make a request via NATS
 // together, and implements remote.Platform using that mechanism.
 type natsPlatform struct {
@@ -196,9 +312,32 @@ type natsPlatform struct {
 	instance string
 }
 
-func (r *natsPlatform) Ping() error {
+// request sends a request to the daemon and waits for the response,
+// giving up if the context is cancelled, or when its deadline passes
+// (if that's sooner than the usual timeout).
+func (r *natsPlatform) request(ctx context.Context, method string, args, response interface{}) error {
+	t := timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := deadline.Sub(time.Now()); d < t {
+			t = d
+		}
+	}
+	errc := make(chan error, 1)
+	go func() {
+		req := request{Metadata: remote.MetadataFrom(ctx), Args: args}
+		errc <- r.conn.Request(r.instance+method, req, response, t)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *natsPlatform) Ping(ctx context.Context) error {
 	var response PingResponse
-	if err := r.conn.Request(r.instance+methodPing, ping{}, &response, timeout); err != nil {
+	if err := r.request(ctx, methodPing, ping{}, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -207,9 +346,9 @@ func (r *natsPlatform) Ping() error {
 	return extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) Version() (string, error) {
+func (r *natsPlatform) Version(ctx context.Context) (string, error) {
 	var response VersionResponse
-	if err := r.conn.Request(r.instance+methodVersion, version{}, &response, timeout); err != nil {
+	if err := r.request(ctx, methodVersion, version{}, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -218,9 +357,9 @@ func (r *natsPlatform) Version() (string, error) {
 	return response.Version, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) Export() ([]byte, error) {
+func (r *natsPlatform) Export(ctx context.Context) ([]byte, error) {
 	var response ExportResponse
-	if err := r.conn.Request(r.instance+methodExport, export{}, &response, timeout); err != nil {
+	if err := r.request(ctx, methodExport, export{}, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -229,9 +368,9 @@ func (r *natsPlatform) Export() ([]byte, error) {
 	return response.Config, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+func (r *natsPlatform) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
 	var response ListServicesResponse
-	if err := r.conn.Request(r.instance+methodListServices, namespace, &response, timeout); err != nil {
+	if err := r.request(ctx, methodListServices, namespace, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -240,9 +379,9 @@ func (r *natsPlatform) ListServices(namespace string) ([]flux.ServiceStatus, err
 	return response.Result, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (r *natsPlatform) ListImages(ctx context.Context, spec update.ServiceSpec) ([]flux.ImageStatus, error) {
 	var response ListImagesResponse
-	if err := r.conn.Request(r.instance+methodListImages, spec, &response, timeout); err != nil {
+	if err := r.request(ctx, methodListImages, spec, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -251,9 +390,9 @@ func (r *natsPlatform) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus,
 	return response.Result, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) UpdateManifests(u update.Spec) (job.ID, error) {
+func (r *natsPlatform) UpdateManifests(ctx context.Context, u update.Spec) (job.ID, error) {
 	var response UpdateManifestsResponse
-	if err := r.conn.Request(r.instance+methodUpdateManifests, u, &response, timeout); err != nil {
+	if err := r.request(ctx, methodUpdateManifests, u, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -262,9 +401,9 @@ func (r *natsPlatform) UpdateManifests(u update.Spec) (job.ID, error) {
 	return response.Result, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) SyncNotify() error {
+func (r *natsPlatform) SyncNotify(ctx context.Context) error {
 	var response SyncNotifyResponse
-	if err := r.conn.Request(r.instance+methodSyncNotify, sync{}, &response, timeout); err != nil {
+	if err := r.request(ctx, methodSyncNotify, sync{}, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -273,9 +412,9 @@ func (r *natsPlatform) SyncNotify() error {
 	return extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) JobStatus(jobID job.ID) (job.Status, error) {
+func (r *natsPlatform) JobStatus(ctx context.Context, jobID job.ID) (job.Status, error) {
 	var response JobStatusResponse
-	if err := r.conn.Request(r.instance+methodJobStatus, jobID, &response, timeout); err != nil {
+	if err := r.request(ctx, methodJobStatus, jobID, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -284,9 +423,9 @@ func (r *natsPlatform) JobStatus(jobID job.ID) (job.Status, error) {
 	return response.Result, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) SyncStatus(ref string) ([]string, error) {
+func (r *natsPlatform) SyncStatus(ctx context.Context, ref string) ([]string, error) {
 	var response SyncStatusResponse
-	if err := r.conn.Request(r.instance+methodSyncStatus, ref, &response, timeout); err != nil {
+	if err := r.request(ctx, methodSyncStatus, ref, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -295,9 +434,9 @@ func (r *natsPlatform) SyncStatus(ref string) ([]string, error) {
 	return response.Result, extractError(response.ErrorResponse)
 }
 
-func (r *natsPlatform) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+func (r *natsPlatform) GitRepoConfig(ctx context.Context, regenerate bool) (flux.GitConfig, error) {
 	var response GitRepoConfigResponse
-	if err := r.conn.Request(r.instance+methodGitRepoConfig, regenerate, &response, timeout); err != nil {
+	if err := r.request(ctx, methodGitRepoConfig, regenerate, &response); err != nil {
 		if err == nats.ErrTimeout {
 			err = remote.UnavailableError(err)
 		}
@@ -306,6 +445,160 @@ func (r *natsPlatform) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	return response.Result, extractError(response.ErrorResponse)
 }
 
+func (r *natsPlatform) JobLog(ctx context.Context, id job.ID) (job.Log, error) {
+	var response JobLogResponse
+	if err := r.request(ctx, methodJobLog, id, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return job.Log{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) WaitJobStatus(ctx context.Context, req job.WaitRequest) (job.Status, error) {
+	var response WaitJobStatusResponse
+	if err := r.request(ctx, methodWaitJobStatus, req, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return job.Status{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	var response ClusterConfigResponse
+	if err := r.request(ctx, methodClusterConfig, nil, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return flux.ClusterConfig{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) EvaluateImage(ctx context.Context, image flux.ImageID) (update.Result, error) {
+	var response EvaluateImageResponse
+	if err := r.request(ctx, methodEvaluateImage, image, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return nil, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	var response ServiceTopologyResponse
+	if err := r.request(ctx, methodServiceTopology, nil, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return nil, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ExportChunk(ctx context.Context, req remote.ExportChunkRequest) (remote.ExportChunk, error) {
+	var response ExportChunkResponse
+	if err := r.request(ctx, methodExportChunk, req, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return remote.ExportChunk{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ResetGitToRemote(ctx context.Context) (string, error) {
+	var response ResetGitToRemoteResponse
+	if err := r.request(ctx, methodResetGitToRemote, nil, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return "", err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	var response ExportAtResponse
+	if err := r.request(ctx, methodExportAt, ref, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return nil, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) InjectFault(ctx context.Context, spec remote.FaultSpec) error {
+	var response InjectFaultResponse
+	if err := r.request(ctx, methodInjectFault, spec, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return err
+	}
+	return extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) SyncHealth(ctx context.Context) (remote.SyncHealth, error) {
+	var response SyncHealthResponse
+	if err := r.request(ctx, methodSyncHealth, nil, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return remote.SyncHealth{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	var response ListResourcesResponse
+	if err := r.request(ctx, methodListResources, nil, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return nil, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) SyncWait(ctx context.Context, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	var response SyncWaitResponse
+	if err := r.request(ctx, methodSyncWait, req, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return remote.SyncReport{}, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	var response ApplyConfigResponse
+	if err := r.request(ctx, methodApplyConfig, config, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return err
+	}
+	return extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	var response PlanReleaseResponse
+	if err := r.request(ctx, methodPlanRelease, spec, &response); err != nil {
+		if err == nats.ErrTimeout {
+			err = remote.UnavailableError(err)
+		}
+		return nil, err
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
 // --- end Platform implementation
 
 // Connect returns a remote.Platform implementation that can be used
@@ -344,6 +637,13 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 	errc := make(chan error)
 
 	processRequest := func(request *nats.Msg) {
+		// The requester won't wait longer than this for an answer,
+		// so there's no use in carrying on after it.
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		metadata, data := splitRequest(request.Data)
+		ctx = remote.WithMetadata(ctx, metadata)
+
 		var err error
 		switch {
 		case strings.HasSuffix(request.Subject, methodKick):
@@ -355,15 +655,15 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 
 		case strings.HasSuffix(request.Subject, methodPing):
 			var p ping
-			err = encoder.Decode(request.Subject, request.Data, &p)
+			err = encoder.Decode(request.Subject, data, &p)
 			if err == nil {
-				err = platform.Ping()
+				err = platform.Ping(ctx)
 			}
 			n.enc.Publish(request.Reply, PingResponse{makeErrorResponse(err)})
 
 		case strings.HasSuffix(request.Subject, methodVersion):
 			var vsn string
-			vsn, err = platform.Version()
+			vsn, err = platform.Version(ctx)
 			n.enc.Publish(request.Reply, VersionResponse{vsn, makeErrorResponse(err)})
 
 		case strings.HasSuffix(request.Subject, methodExport):
@@ -371,9 +671,9 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req   export
 				bytes []byte
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				bytes, err = platform.Export()
+				bytes, err = platform.Export(ctx)
 			}
 			n.enc.Publish(request.Reply, ExportResponse{bytes, makeErrorResponse(err)})
 
@@ -382,9 +682,9 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				namespace string
 				res       []flux.ServiceStatus
 			)
-			err = encoder.Decode(request.Subject, request.Data, &namespace)
+			err = encoder.Decode(request.Subject, data, &namespace)
 			if err == nil {
-				res, err = platform.ListServices(namespace)
+				res, err = platform.ListServices(ctx, namespace)
 			}
 			n.enc.Publish(request.Reply, ListServicesResponse{res, makeErrorResponse(err)})
 
@@ -393,9 +693,9 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req update.ServiceSpec
 				res []flux.ImageStatus
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				res, err = platform.ListImages(req)
+				res, err = platform.ListImages(ctx, req)
 			}
 			n.enc.Publish(request.Reply, ListImagesResponse{res, makeErrorResponse(err)})
 
@@ -404,14 +704,14 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req update.Spec
 				res job.ID
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				res, err = platform.UpdateManifests(req)
+				res, err = platform.UpdateManifests(ctx, req)
 			}
 			n.enc.Publish(request.Reply, UpdateManifestsResponse{res, makeErrorResponse(err)})
 
 		case strings.HasSuffix(request.Subject, methodSyncNotify):
-			err = platform.SyncNotify()
+			err = platform.SyncNotify(ctx)
 			n.enc.Publish(request.Reply, SyncNotifyResponse{makeErrorResponse(err)})
 
 		case strings.HasSuffix(request.Subject, methodJobStatus):
@@ -419,9 +719,9 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req job.ID
 				res job.Status
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				res, err = platform.JobStatus(req)
+				res, err = platform.JobStatus(ctx, req)
 			}
 			n.enc.Publish(request.Reply, JobStatusResponse{
 				Result:        res,
@@ -433,9 +733,9 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req string
 				res []string
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				res, err = platform.SyncStatus(req)
+				res, err = platform.SyncStatus(ctx, req)
 			}
 			n.enc.Publish(request.Reply, SyncStatusResponse{res, makeErrorResponse(err)})
 
@@ -444,12 +744,130 @@ func (n *NATS) Subscribe(instID service.InstanceID, platform remote.Platform, do
 				req bool
 				res flux.GitConfig
 			)
-			err = encoder.Decode(request.Subject, request.Data, &req)
+			err = encoder.Decode(request.Subject, data, &req)
 			if err == nil {
-				res, err = platform.GitRepoConfig(req)
+				res, err = platform.GitRepoConfig(ctx, req)
 			}
 			n.enc.Publish(request.Reply, GitRepoConfigResponse{res, makeErrorResponse(err)})
 
+		case strings.HasSuffix(request.Subject, methodJobLog):
+			var (
+				req job.ID
+				res job.Log
+			)
+			err = encoder.Decode(request.Subject, data, &req)
+			if err == nil {
+				res, err = platform.JobLog(ctx, req)
+			}
+			n.enc.Publish(request.Reply, JobLogResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodWaitJobStatus):
+			var (
+				req job.WaitRequest
+				res job.Status
+			)
+			err = encoder.Decode(request.Subject, data, &req)
+			if err == nil {
+				res, err = platform.WaitJobStatus(ctx, req)
+			}
+			n.enc.Publish(request.Reply, WaitJobStatusResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodClusterConfig):
+			var res flux.ClusterConfig
+			res, err = platform.ClusterConfig(ctx)
+			n.enc.Publish(request.Reply, ClusterConfigResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodEvaluateImage):
+			var (
+				req flux.ImageID
+				res update.Result
+			)
+			err = encoder.Decode(request.Subject, data, &req)
+			if err == nil {
+				res, err = platform.EvaluateImage(ctx, req)
+			}
+			n.enc.Publish(request.Reply, EvaluateImageResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodServiceTopology):
+			var res []flux.ServiceTopology
+			res, err = platform.ServiceTopology(ctx)
+			n.enc.Publish(request.Reply, ServiceTopologyResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodExportChunk):
+			var (
+				req remote.ExportChunkRequest
+				res remote.ExportChunk
+			)
+			err = encoder.Decode(request.Subject, data, &req)
+			if err == nil {
+				res, err = platform.ExportChunk(ctx, req)
+			}
+			n.enc.Publish(request.Reply, ExportChunkResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodResetGitToRemote):
+			var res string
+			res, err = platform.ResetGitToRemote(ctx)
+			n.enc.Publish(request.Reply, ResetGitToRemoteResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodExportAt):
+			var (
+				ref string
+				res []byte
+			)
+			err = encoder.Decode(request.Subject, data, &ref)
+			if err == nil {
+				res, err = platform.ExportAt(ctx, ref)
+			}
+			n.enc.Publish(request.Reply, ExportAtResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodInjectFault):
+			var spec remote.FaultSpec
+			err = encoder.Decode(request.Subject, data, &spec)
+			if err == nil {
+				err = platform.InjectFault(ctx, spec)
+			}
+			n.enc.Publish(request.Reply, InjectFaultResponse{makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodSyncHealth):
+			var res remote.SyncHealth
+			res, err = platform.SyncHealth(ctx)
+			n.enc.Publish(request.Reply, SyncHealthResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodListResources):
+			var res []flux.ResourceStatus
+			res, err = platform.ListResources(ctx)
+			n.enc.Publish(request.Reply, ListResourcesResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodSyncWait):
+			var (
+				req remote.SyncWaitRequest
+				res remote.SyncReport
+			)
+			err = encoder.Decode(request.Subject, data, &req)
+			if err == nil {
+				res, err = platform.SyncWait(ctx, req)
+			}
+			n.enc.Publish(request.Reply, SyncWaitResponse{res, makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodApplyConfig):
+			var config service.DaemonConfig
+			err = encoder.Decode(request.Subject, data, &config)
+			if err == nil {
+				err = platform.ApplyConfig(ctx, config)
+			}
+			n.enc.Publish(request.Reply, ApplyConfigResponse{makeErrorResponse(err)})
+
+		case strings.HasSuffix(request.Subject, methodPlanRelease):
+			var (
+				spec update.ReleaseSpec
+				res  update.Result
+			)
+			err = encoder.Decode(request.Subject, data, &spec)
+			if err == nil {
+				res, err = platform.PlanRelease(ctx, spec)
+			}
+			n.enc.Publish(request.Reply, PlanReleaseResponse{res, makeErrorResponse(err)})
+
 		default:
 			err = errors.New("unknown message: " + request.Subject)
 		}
@@ -1,6 +1,8 @@
 package nats
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 
 	"flag"
@@ -47,7 +49,7 @@ func TestPing(t *testing.T) {
 	// AwaitPresence uses Ping, so we have to install our error after
 	// subscribe succeeds.
 	platA.PingError = remote.FatalError{errors.New("ping problem")}
-	if err := platA.Ping(); err == nil {
+	if err := platA.Ping(context.Background()); err == nil {
 		t.Fatalf("expected error from directly calling ping, got nil")
 	}
 
@@ -106,7 +108,7 @@ func TestFatalErrorDisconnects(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = plat.ListServices("")
+	_, err = plat.ListServices(context.Background(), "")
 	if err == nil {
 		t.Error("expected error, got nil")
 	} else if _, ok := err.(remote.FatalError); !ok {
@@ -149,3 +151,24 @@ func TestNewConnectionKicks(t *testing.T) {
 		t.Errorf("expected no error from second connection, but got %q", err)
 	}
 }
+
+func TestSplitRequest(t *testing.T) {
+	md := remote.Metadata{RequestID: "req-1", User: "alice"}
+	data, err := json.Marshal(request{Metadata: md, Args: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotMD, args := splitRequest(data)
+	if gotMD != md {
+		t.Errorf("expected metadata %+v, got %+v", md, gotMD)
+	}
+	if string(args) != `"default"` {
+		t.Errorf("expected just the arguments, got %s", string(args))
+	}
+
+	// Requests from before there was metadata are only the arguments
+	gotMD, args = splitRequest([]byte(`"default"`))
+	if gotMD != (remote.Metadata{}) || string(args) != `"default"` {
+		t.Errorf("expected bare arguments to be left alone, got %+v and %s", gotMD, string(args))
+	}
+}
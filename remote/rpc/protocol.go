@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/remote"
+)
+
+// The versions of the protocol, as named when negotiating which to
+// use. A daemon offers those it speaks when it connects (as
+// websocket subprotocols), most preferred first, and the service
+// picks one. Daemons from before there was any negotiation offer
+// nothing, and speak V6.
+const (
+	ProtocolV6 = "flux-rpc.v6"
+	ProtocolV7 = "flux-rpc.v7"
+)
+
+// From V7, options can follow the version in the name of a
+// protocol, each after a "+": gob in place of JSON; compressing
+// everything sent, e.g., "flux-rpc.v7+gob+deflate"; and interleaving
+// requests and responses, so a big one doesn't hold up the rest
+// (which can't be combined with gob).
+const (
+	OptionGob     = "gob"
+	OptionDeflate = "deflate"
+	OptionMux     = "mux"
+)
+
+// Protocols are the versions of the protocol this package speaks,
+// with the options it supports, most preferred first.
+var Protocols = []string{
+	ProtocolV7 + "+" + OptionMux + "+" + OptionDeflate,
+	ProtocolV7 + "+" + OptionGob + "+" + OptionDeflate,
+	ProtocolV7 + "+" + OptionDeflate,
+	ProtocolV7,
+	ProtocolV6,
+}
+
+// protocol is a protocol name taken apart.
+type protocol struct {
+	version string
+	gob     bool
+	deflate bool
+	mux     bool
+}
+
+func parseProtocol(name string) (protocol, error) {
+	parts := strings.Split(name, "+")
+	p := protocol{version: parts[0]}
+	switch p.version {
+	case ProtocolV6:
+		if len(parts) > 1 {
+			return p, errors.Errorf("protocol %q does not take options", name)
+		}
+		return p, nil
+	case ProtocolV7:
+	default:
+		return p, errors.Errorf("unsupported protocol %q", name)
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case OptionGob:
+			p.gob = true
+		case OptionDeflate:
+			p.deflate = true
+		case OptionMux:
+			p.mux = true
+		default:
+			return p, errors.Errorf("unsupported option %q in protocol %q", opt, name)
+		}
+	}
+	if p.gob && p.mux {
+		return p, errors.Errorf("protocol %q combines options %q and %q, which don't go together", name, OptionGob, OptionMux)
+	}
+	return p, nil
+}
+
+// wrap gives the connection to use for the protocol, i.e., with
+// compression and interleaving if they're wanted. Frames are
+// compressed as they're sent, since the compressed stream has to be
+// read in the order it was written.
+func (p protocol) wrap(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	if p.deflate {
+		conn = newCompressedConn(conn)
+	}
+	if p.mux {
+		conn = newMuxConn(conn)
+	}
+	return conn
+}
+
+// SelectProtocol picks the protocol to speak with a daemon, from
+// those it offered.
+func SelectProtocol(offered []string) (string, error) {
+	if len(offered) == 0 {
+		return ProtocolV6, nil
+	}
+	for _, p := range offered {
+		for _, supported := range Protocols {
+			if p == supported {
+				return p, nil
+			}
+		}
+	}
+	return "", errors.Errorf("none of the protocols offered (%s) is supported", strings.Join(offered, ", "))
+}
+
+// Client is a platform at the other end of a connection, which is
+// closed along with it.
+type Client interface {
+	remote.Platform
+	io.Closer
+}
+
+// NewClient makes a client speaking the protocol given, as returned
+// by SelectProtocol, which waits for each method as long as the
+// timeouts given say. Daemons speaking V7 are also told the timeout,
+// and will give up themselves.
+func NewClient(name string, conn io.ReadWriteCloser, timeouts Timeouts) (Client, error) {
+	p, err := parseProtocol(name)
+	if err != nil {
+		return nil, err
+	}
+	if p.version == ProtocolV6 {
+		c := NewClientV6(conn)
+		c.timeouts = timeouts
+		return c, nil
+	}
+	conn = p.wrap(conn)
+	var enc clientEncoding = newJSONClientEncoding(conn)
+	if p.gob {
+		enc = newGobClientEncoding(conn)
+	}
+	return newClientV7(newClientCodecWithEncoding(conn, enc, timeouts), timeouts), nil
+}
@@ -1,12 +1,19 @@
 package rpc
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/update"
 )
 
 func pipes() (io.ReadWriteCloser, io.ReadWriteCloser) {
@@ -66,10 +73,407 @@ func TestBadRPC(t *testing.T) {
 	go server.ServeConn(serverConn)
 
 	client := NewClientV6(clientConn)
-	if err = client.Ping(); err == nil {
+	if err = client.Ping(context.Background()); err == nil {
 		t.Error("expected error from RPC system, got nil")
 	}
 	if _, ok := err.(remote.FatalError); !ok {
 		t.Errorf("expected remote.FatalError from RPC mechanism, got %s", reflect.TypeOf(err))
 	}
 }
+
+func TestRPCV7(t *testing.T) {
+	wrap := func(mock remote.Platform) remote.Platform {
+		clientConn, serverConn := pipes()
+
+		server, err := NewServer(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.ServeConn(serverConn)
+		client, err := NewClient(ProtocolV7, clientConn, DefaultTimeouts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	remote.PlatformTestBattery(t, wrap)
+}
+
+func TestRPCProtocols(t *testing.T) {
+	for _, protocol := range Protocols {
+		t.Run(protocol, func(t *testing.T) {
+			wrap := func(mock remote.Platform) remote.Platform {
+				clientConn, serverConn := pipes()
+
+				server, err := NewServer(mock)
+				if err != nil {
+					t.Fatal(err)
+				}
+				go server.ServeProtocol(protocol, serverConn)
+				client, err := NewClient(protocol, clientConn, DefaultTimeouts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return client
+			}
+			remote.PlatformTestBattery(t, wrap)
+		})
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		expected protocol
+	}{
+		{ProtocolV6, protocol{version: ProtocolV6}},
+		{ProtocolV7, protocol{version: ProtocolV7}},
+		{"flux-rpc.v7+deflate", protocol{version: ProtocolV7, deflate: true}},
+		{"flux-rpc.v7+deflate+gob", protocol{version: ProtocolV7, gob: true, deflate: true}},
+		{"flux-rpc.v7+mux+deflate", protocol{version: ProtocolV7, mux: true, deflate: true}},
+	} {
+		got, err := parseProtocol(c.name)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, got)
+		}
+	}
+	for _, bad := range []string{"flux-rpc.v6+gob", "flux-rpc.v7+zip", "flux-rpc.v99", "flux-rpc.v7+gob+mux"} {
+		if _, err := parseProtocol(bad); err == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestSelectProtocol(t *testing.T) {
+	for _, c := range []struct {
+		offered  []string
+		expected string
+	}{
+		{nil, ProtocolV6},
+		{[]string{ProtocolV6}, ProtocolV6},
+		{[]string{ProtocolV7, ProtocolV6}, ProtocolV7},
+		{[]string{"flux-rpc.v7+gob+deflate", ProtocolV7}, "flux-rpc.v7+gob+deflate"},
+		{[]string{"flux-rpc.v7+zip", ProtocolV7}, ProtocolV7},
+		{[]string{"flux-rpc.v99", ProtocolV6}, ProtocolV6},
+	} {
+		got, err := SelectProtocol(c.offered)
+		if err != nil {
+			t.Errorf("offered %v: unexpected error %v", c.offered, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("offered %v: expected %q, got %q", c.offered, c.expected, got)
+		}
+	}
+	if _, err := SelectProtocol([]string{"flux-rpc.v99"}); err == nil {
+		t.Error("expected error when no protocol offered is supported")
+	}
+}
+
+func TestApplicationErrors(t *testing.T) {
+	notFound := flux.Missing{&flux.BaseError{
+		Help: "No such service\n\nThere's no service by that name.\n",
+		Err:  errors.New("service not found"),
+	}}
+	mock := &remote.MockPlatform{
+		ListImagesError: notFound,
+		SyncNotifyError: errors.New("plain error"),
+	}
+	for _, protocol := range Protocols {
+		// Daemons that speak V6 are from before there was any
+		// detail sent with errors, so the client doesn't look for it
+		if protocol == ProtocolV6 {
+			continue
+		}
+		t.Run(protocol, func(t *testing.T) {
+			clientConn, serverConn := pipes()
+			server, err := NewServer(mock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go server.ServeProtocol(protocol, serverConn)
+			client, err := NewClient(protocol, clientConn, DefaultTimeouts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			_, err = client.ListImages(context.Background(), update.ServiceSpecAll)
+			missing, ok := err.(flux.Missing)
+			if !ok {
+				t.Fatalf("expected flux.Missing, got %T: %v", err, err)
+			}
+			if missing.Help != notFound.Help || missing.Error() != notFound.Error() {
+				t.Errorf("expected %+v, got %+v", notFound.BaseError, missing.BaseError)
+			}
+
+			// Errors that are just a message stay that way
+			err = client.SyncNotify(context.Background())
+			if _, ok := err.(flux.HelpfulError); ok || err == nil || err.Error() != "plain error" {
+				t.Errorf("expected plain error, got %T: %v", err, err)
+			}
+		})
+	}
+}
+
+// A platform that doesn't answer ListImages until told to
+type hungPlatform struct {
+	*remote.MockPlatform
+	unblock chan struct{}
+}
+
+func (p hungPlatform) ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error) {
+	<-p.unblock
+	return nil, nil
+}
+
+func TestTimeoutEnforcedByServer(t *testing.T) {
+	hung := hungPlatform{&remote.MockPlatform{}, make(chan struct{})}
+	defer close(hung.unblock)
+	clientConn, serverConn := pipes()
+	server, err := NewServer(hung)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeConn(serverConn)
+
+	timeouts := Timeouts{
+		Default: time.Second,
+		Methods: map[string]time.Duration{"ListImages": 50 * time.Millisecond},
+	}
+	client := NewClientV7(clientConn, timeouts)
+	begin := time.Now()
+	_, err = client.ListImages(context.Background(), update.ServiceSpecAll)
+	if err == nil {
+		t.Fatal("expected error from call that timed out")
+	}
+	if _, ok := err.(remote.FatalError); ok {
+		t.Errorf("expected timeout not to be fatal, got %v", err)
+	}
+	// The client allows some grace beyond the timeout, so if it's
+	// come back before then, it was the server's answer.
+	if elapsed := time.Since(begin); elapsed >= timeoutGrace {
+		t.Errorf("expected the server to answer when the timeout was up, but took %s", elapsed)
+	}
+
+	// The connection is still good for other calls
+	if err := client.Ping(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+// A platform that remembers the metadata it was called with
+type metadataPlatform struct {
+	*remote.MockPlatform
+	got chan remote.Metadata
+}
+
+func (p metadataPlatform) SyncNotify(ctx context.Context) error {
+	p.got <- remote.MetadataFrom(ctx)
+	return nil
+}
+
+func TestMetadata(t *testing.T) {
+	platform := metadataPlatform{&remote.MockPlatform{}, make(chan remote.Metadata, 1)}
+	clientConn, serverConn := pipes()
+	server, err := NewServer(platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeConn(serverConn)
+
+	client := NewClientV7(clientConn, DefaultTimeouts)
+	md := remote.Metadata{RequestID: "req-1", User: "alice"}
+	if err := client.SyncNotify(remote.WithMetadata(context.Background(), md)); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-platform.got; got != md {
+		t.Errorf("expected metadata %+v to be passed along, got %+v", md, got)
+	}
+
+	// A call without any is fine too
+	if err := client.SyncNotify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-platform.got; got != (remote.Metadata{}) {
+		t.Errorf("expected no metadata, got %+v", got)
+	}
+}
+
+// A platform that remembers the metadata it was called with, and
+// then waits to be let go of
+type heldPlatform struct {
+	metadataPlatform
+	release chan struct{}
+}
+
+func (p heldPlatform) SyncNotify(ctx context.Context) error {
+	p.got <- remote.MetadataFrom(ctx)
+	<-p.release
+	return nil
+}
+
+// Calls in progress at the same time are each given the metadata
+// they were sent with, though they're served by the same receiver.
+func TestMetadataConcurrentCalls(t *testing.T) {
+	platform := heldPlatform{metadataPlatform{&remote.MockPlatform{}, make(chan remote.Metadata)}, make(chan struct{})}
+	clientConn, serverConn := pipes()
+	server, err := NewServer(platform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeConn(serverConn)
+
+	client := NewClientV7(clientConn, DefaultTimeouts)
+	errs := make(chan error, 2)
+	for _, id := range []string{"req-1", "req-2"} {
+		go func(md remote.Metadata) {
+			errs <- client.SyncNotify(remote.WithMetadata(context.Background(), md))
+		}(remote.Metadata{RequestID: id})
+	}
+	// Both calls are in progress before either is let go of
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		got[(<-platform.got).RequestID] = true
+	}
+	close(platform.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if !got["req-1"] || !got["req-2"] {
+		t.Errorf("expected each call to have its own metadata, got %v", got)
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts([]string{"1m", "ListImages=5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := timeouts.For("RPCServer.ListImages"); d != 5*time.Minute {
+		t.Errorf("expected timeout for ListImages of 5m, got %s", d)
+	}
+	if d := timeouts.For("JobStatus"); d != time.Minute {
+		t.Errorf("expected default timeout of 1m, got %s", d)
+	}
+	if d := timeouts.For("Ping"); d != DefaultTimeouts.Methods["Ping"] {
+		t.Errorf("expected default timeout for Ping to be kept, got %s", d)
+	}
+	if DefaultTimeouts.Methods["ListImages"] == 5*time.Minute {
+		t.Error("expected parsing not to change the defaults")
+	}
+
+	for _, bad := range [][]string{{"=1m"}, {"ListImages=soon"}, {"-1s"}} {
+		if _, err := ParseTimeouts(bad); err == nil {
+			t.Errorf("expected error parsing %v", bad)
+		}
+	}
+}
+
+// ---
+
+// countingConn counts the bytes read from a connection.
+type countingConn struct {
+	io.ReadWriteCloser
+	read int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+// A platform answering with roughly what a daemon in a modest
+// cluster would.
+func benchmarkPlatform() *remote.MockPlatform {
+	var services []flux.ServiceStatus
+	var config bytes.Buffer
+	for i := 0; i < 50; i++ {
+		current, _ := flux.ParseImageID(fmt.Sprintf("quay.io/weaveworks/service%d:master-a%06d", i, i))
+		var available []flux.Image
+		for j := 0; j < 10; j++ {
+			id, _ := flux.ParseImageID(fmt.Sprintf("quay.io/weaveworks/service%d:master-b%06d", i, j))
+			available = append(available, flux.Image{ID: id, CreatedAt: time.Date(2017, 7, 1, j, 0, 0, 0, time.UTC)})
+		}
+		services = append(services, flux.ServiceStatus{
+			ID:         flux.MakeServiceID("default", fmt.Sprintf("service%d", i)),
+			Containers: []flux.Container{{Name: "main", Current: flux.Image{ID: current}, Available: available}},
+			Status:     "ready",
+			Automated:  i%2 == 0,
+		})
+		fmt.Fprintf(&config, `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: service%d
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        name: service%d
+    spec:
+      containers:
+      - name: main
+        image: %s
+        ports:
+        - containerPort: 80
+`, i, i, current)
+	}
+	return &remote.MockPlatform{
+		ListServicesAnswer: services,
+		ExportAnswer:       config.Bytes(),
+	}
+}
+
+// The benchmarks log how many bytes the service receives per
+// response, which is what the protocol options are meant to reduce.
+func benchmarkProtocol(b *testing.B, call func(remote.Platform) error) {
+	for _, protocol := range Protocols {
+		b.Run(protocol, func(b *testing.B) {
+			clientConn, serverConn := pipes()
+			counted := &countingConn{ReadWriteCloser: clientConn}
+			server, err := NewServer(benchmarkPlatform())
+			if err != nil {
+				b.Fatal(err)
+			}
+			go server.ServeProtocol(protocol, serverConn)
+			client, err := NewClient(protocol, counted, DefaultTimeouts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer client.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := call(client); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.Logf("%d bytes per response", atomic.LoadInt64(&counted.read)/int64(b.N))
+		})
+	}
+}
+
+func BenchmarkListServices(b *testing.B) {
+	benchmarkProtocol(b, func(p remote.Platform) error {
+		_, err := p.ListServices(context.Background(), "")
+		return err
+	})
+}
+
+func BenchmarkExport(b *testing.B) {
+	benchmarkProtocol(b, func(p remote.Platform) error {
+		_, err := p.Export(context.Background())
+		return err
+	})
+}
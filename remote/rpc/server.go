@@ -1,13 +1,14 @@
 package rpc
 
 import (
+	"context"
 	"io"
 	"net/rpc"
-	"net/rpc/jsonrpc"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -21,72 +22,304 @@ type Server struct {
 // platform.
 func NewServer(p remote.Platform) (*Server, error) {
 	server := rpc.NewServer()
-	if err := server.Register(&RPCServer{p}); err != nil {
+	if err := server.Register(&RPCServer{p: p}); err != nil {
 		return nil, err
 	}
 	return &Server{server: server}, nil
 }
 
+// ServeConn serves requests on the connection, which speaks JSON-RPC
+// (i.e., V6 or V7 of the protocol, without options).
 func (c *Server) ServeConn(conn io.ReadWriteCloser) {
-	c.server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	c.serve(newServerCodec(conn))
 }
 
+// ServeProtocol serves requests on the connection, speaking the
+// protocol given, as agreed with the service.
+func (c *Server) ServeProtocol(name string, conn io.ReadWriteCloser) error {
+	p, err := parseProtocol(name)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn = p.wrap(conn)
+	var enc serverEncoding = newJSONServerEncoding(conn)
+	if p.gob {
+		enc = newGobServerEncoding(conn)
+	}
+	c.serve(newServerCodecWithEncoding(conn, enc))
+	return nil
+}
+
+// serve reads requests from the connection, and serves each with a
+// codec of its own, so that its method is given the metadata it came
+// with (see Call).
+func (c *Server) serve(codec *serverCodec) {
+	defer codec.Close()
+	for {
+		req, err := codec.readRequest()
+		if err != nil {
+			return
+		}
+		go c.server.ServeRequest(req)
+	}
+}
+
+// Call is what each RPCServer method is given in place of its
+// argument. net/rpc has no way of giving a method anything but its
+// argument, so the request's codec puts the context for the call
+// here, carrying the metadata sent with the request, along with the
+// means to read the argument itself.
+type Call struct {
+	ctx context.Context
+	req *requestCodec
+}
+
+// args reads the argument sent with the call into x, or discards it
+// if x is nil. Each method reads its argument before doing anything
+// else, since (with gob) the next request on the connection can't be
+// read until it has been.
+func (c *Call) args(x interface{}) error {
+	return c.req.req.params(x)
+}
+
+// RPCServer adapts a platform to net/rpc. Since net/rpc has no way of
+// passing a cancellation along from the client, each method is called
+// with a context that has no deadline, and carries only the metadata
+// sent with the request.
 type RPCServer struct {
 	p remote.Platform
 }
 
-func (p *RPCServer) Ping(_ struct{}, _ *struct{}) error {
-	return p.p.Ping()
+// answer passes along the error from a method. net/rpc only sends
+// the message, so the rest (e.g., the help for the error) is given to
+// the codec, to send alongside it in the answer to the call.
+func (p *RPCServer) answer(call *Call, err error) error {
+	if err != nil {
+		call.req.server.setApplicationError(call.req.seq, remote.ApplicationErrorFrom(err))
+	}
+	return err
+}
+
+func (p *RPCServer) Ping(call *Call, _ *struct{}) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	return p.answer(call, p.p.Ping(call.ctx))
 }
 
-func (p *RPCServer) Version(_ struct{}, resp *string) error {
-	v, err := p.p.Version()
+func (p *RPCServer) Version(call *Call, resp *string) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.Version(call.ctx)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) Export(_ struct{}, resp *[]byte) error {
-	v, err := p.p.Export()
+func (p *RPCServer) Export(call *Call, resp *[]byte) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.Export(call.ctx)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) ListServices(namespace string, resp *[]flux.ServiceStatus) error {
-	v, err := p.p.ListServices(namespace)
+func (p *RPCServer) ListServices(call *Call, resp *[]flux.ServiceStatus) error {
+	var namespace string
+	if err := call.args(&namespace); err != nil {
+		return err
+	}
+	v, err := p.p.ListServices(call.ctx, namespace)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) ListImages(spec update.ServiceSpec, resp *[]flux.ImageStatus) error {
-	v, err := p.p.ListImages(spec)
+func (p *RPCServer) ListImages(call *Call, resp *[]flux.ImageStatus) error {
+	var spec update.ServiceSpec
+	if err := call.args(&spec); err != nil {
+		return err
+	}
+	v, err := p.p.ListImages(call.ctx, spec)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) UpdateManifests(spec update.Spec, resp *job.ID) error {
-	v, err := p.p.UpdateManifests(spec)
+func (p *RPCServer) UpdateManifests(call *Call, resp *job.ID) error {
+	var spec update.Spec
+	if err := call.args(&spec); err != nil {
+		return err
+	}
+	v, err := p.p.UpdateManifests(call.ctx, spec)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) SyncNotify(_ struct{}, _ *struct{}) error {
-	return p.p.SyncNotify()
+func (p *RPCServer) SyncNotify(call *Call, _ *struct{}) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	return p.answer(call, p.p.SyncNotify(call.ctx))
 }
 
-func (p *RPCServer) JobStatus(jobID job.ID, resp *job.Status) error {
-	v, err := p.p.JobStatus(jobID)
+func (p *RPCServer) JobStatus(call *Call, resp *job.Status) error {
+	var jobID job.ID
+	if err := call.args(&jobID); err != nil {
+		return err
+	}
+	v, err := p.p.JobStatus(call.ctx, jobID)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) SyncStatus(cursor string, resp *[]string) error {
-	v, err := p.p.SyncStatus(cursor)
+func (p *RPCServer) SyncStatus(call *Call, resp *[]string) error {
+	var cursor string
+	if err := call.args(&cursor); err != nil {
+		return err
+	}
+	v, err := p.p.SyncStatus(call.ctx, cursor)
 	*resp = v
-	return err
+	return p.answer(call, err)
 }
 
-func (p *RPCServer) GitRepoConfig(regenerate bool, resp *flux.GitConfig) error {
-	v, err := p.p.GitRepoConfig(regenerate)
+func (p *RPCServer) GitRepoConfig(call *Call, resp *flux.GitConfig) error {
+	var regenerate bool
+	if err := call.args(&regenerate); err != nil {
+		return err
+	}
+	v, err := p.p.GitRepoConfig(call.ctx, regenerate)
 	*resp = v
-	return err
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) JobLog(call *Call, resp *job.Log) error {
+	var id job.ID
+	if err := call.args(&id); err != nil {
+		return err
+	}
+	v, err := p.p.JobLog(call.ctx, id)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) WaitJobStatus(call *Call, resp *job.Status) error {
+	var req job.WaitRequest
+	if err := call.args(&req); err != nil {
+		return err
+	}
+	v, err := p.p.WaitJobStatus(call.ctx, req)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ClusterConfig(call *Call, resp *flux.ClusterConfig) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.ClusterConfig(call.ctx)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) EvaluateImage(call *Call, resp *update.Result) error {
+	var image flux.ImageID
+	if err := call.args(&image); err != nil {
+		return err
+	}
+	v, err := p.p.EvaluateImage(call.ctx, image)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ServiceTopology(call *Call, resp *[]flux.ServiceTopology) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.ServiceTopology(call.ctx)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ExportChunk(call *Call, resp *remote.ExportChunk) error {
+	var req remote.ExportChunkRequest
+	if err := call.args(&req); err != nil {
+		return err
+	}
+	v, err := p.p.ExportChunk(call.ctx, req)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ResetGitToRemote(call *Call, resp *string) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.ResetGitToRemote(call.ctx)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ExportAt(call *Call, resp *[]byte) error {
+	var ref string
+	if err := call.args(&ref); err != nil {
+		return err
+	}
+	v, err := p.p.ExportAt(call.ctx, ref)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) InjectFault(call *Call, _ *struct{}) error {
+	var spec remote.FaultSpec
+	if err := call.args(&spec); err != nil {
+		return err
+	}
+	return p.answer(call, p.p.InjectFault(call.ctx, spec))
+}
+
+func (p *RPCServer) SyncHealth(call *Call, resp *remote.SyncHealth) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.SyncHealth(call.ctx)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ListResources(call *Call, resp *[]flux.ResourceStatus) error {
+	if err := call.args(nil); err != nil {
+		return err
+	}
+	v, err := p.p.ListResources(call.ctx)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) SyncWait(call *Call, resp *remote.SyncReport) error {
+	var req remote.SyncWaitRequest
+	if err := call.args(&req); err != nil {
+		return err
+	}
+	v, err := p.p.SyncWait(call.ctx, req)
+	*resp = v
+	return p.answer(call, err)
+}
+
+func (p *RPCServer) ApplyConfig(call *Call, _ *struct{}) error {
+	var config service.DaemonConfig
+	if err := call.args(&config); err != nil {
+		return err
+	}
+	return p.answer(call, p.p.ApplyConfig(call.ctx, config))
+}
+
+func (p *RPCServer) PlanRelease(call *Call, resp *update.Result) error {
+	var spec update.ReleaseSpec
+	if err := call.args(&spec); err != nil {
+		return err
+	}
+	v, err := p.p.PlanRelease(call.ctx, spec)
+	*resp = v
+	return p.answer(call, err)
 }
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Timeouts says how long to wait for a daemon to answer each method,
+// so that a daemon that's hung doesn't hold up callers indefinitely.
+type Timeouts struct {
+	// Default is for the methods not given in Methods
+	Default time.Duration
+	// Methods has the timeout for each method, by name, e.g.,
+	// "ListImages"
+	Methods map[string]time.Duration
+}
+
+// DefaultTimeouts are sensible for a daemon that's working normally.
+// The methods that go to the image registry or do git operations
+// get longer.
+var DefaultTimeouts = Timeouts{
+	Default: 30 * time.Second,
+	Methods: map[string]time.Duration{
+		"Ping":             10 * time.Second,
+		"Version":          10 * time.Second,
+		"ListImages":       2 * time.Minute,
+		"EvaluateImage":    2 * time.Minute,
+		"Export":           2 * time.Minute,
+		"ExportChunk":      time.Minute,
+		"SyncStatus":       time.Minute,
+		"ResetGitToRemote": time.Minute,
+		"ExportAt":         2 * time.Minute,
+	},
+}
+
+// For gives the timeout for the method given, which may be qualified
+// with the name of the RPC service (e.g., "RPCServer.ListImages").
+func (t Timeouts) For(method string) time.Duration {
+	if i := strings.LastIndex(method, "."); i >= 0 {
+		method = method[i+1:]
+	}
+	if d, ok := t.Methods[method]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// ParseTimeouts reads timeouts as given on the command line, on top
+// of DefaultTimeouts. Each entry is either "method=duration", for a
+// single method, or just "duration", to replace the default.
+func ParseTimeouts(flags []string) (Timeouts, error) {
+	t := Timeouts{Default: DefaultTimeouts.Default, Methods: map[string]time.Duration{}}
+	for method, d := range DefaultTimeouts.Methods {
+		t.Methods[method] = d
+	}
+	for _, flag := range flags {
+		method, value := "", flag
+		if i := strings.Index(flag, "="); i >= 0 {
+			method, value = flag[:i], flag[i+1:]
+			if method == "" {
+				return Timeouts{}, errors.Errorf("no method name in %q", flag)
+			}
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Timeouts{}, errors.Errorf("timeout in %q must be a positive duration, e.g., 30s", flag)
+		}
+		if method == "" {
+			t.Default = d
+		} else {
+			t.Methods[method] = d
+		}
+	}
+	return t, nil
+}
@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"context"
 	"errors"
 	"sync"
 
@@ -88,7 +89,7 @@ func (s *StandaloneMessageBus) Ping(inst service.InstanceID) error {
 	s.RUnlock()
 
 	if ok {
-		return p.Ping()
+		return p.Ping(context.Background())
 	}
 	return flux.Missing{
 		BaseError: &flux.BaseError{
@@ -113,7 +114,7 @@ func (s *StandaloneMessageBus) Version(inst service.InstanceID) (string, error)
 	s.RUnlock()
 
 	if ok {
-		return p.Version()
+		return p.Version(context.Background())
 	}
 	return "", errNotSubscribed
 }
@@ -134,94 +135,220 @@ func (p *removeablePlatform) closeWithError(err error) {
 	}
 }
 
-func (p *removeablePlatform) Ping() (err error) {
+func (p *removeablePlatform) Ping(ctx context.Context) (err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.Ping()
+	return p.remote.Ping(ctx)
 }
 
-func (p *removeablePlatform) Version() (v string, err error) {
+func (p *removeablePlatform) Version(ctx context.Context) (v string, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.Version()
+	return p.remote.Version(ctx)
 }
 
-func (p *removeablePlatform) Export() (config []byte, err error) {
+func (p *removeablePlatform) Export(ctx context.Context) (config []byte, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.Export()
+	return p.remote.Export(ctx)
 }
 
-func (p *removeablePlatform) ListServices(namespace string) (_ []flux.ServiceStatus, err error) {
+func (p *removeablePlatform) ListServices(ctx context.Context, namespace string) (_ []flux.ServiceStatus, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.ListServices(namespace)
+	return p.remote.ListServices(ctx, namespace)
 }
 
-func (p *removeablePlatform) ListImages(spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
+func (p *removeablePlatform) ListImages(ctx context.Context, spec update.ServiceSpec) (_ []flux.ImageStatus, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.ListImages(spec)
+	return p.remote.ListImages(ctx, spec)
 }
 
-func (p *removeablePlatform) UpdateManifests(u update.Spec) (_ job.ID, err error) {
+func (p *removeablePlatform) UpdateManifests(ctx context.Context, u update.Spec) (_ job.ID, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.UpdateManifests(u)
+	return p.remote.UpdateManifests(ctx, u)
 }
 
-func (p *removeablePlatform) SyncNotify() (err error) {
+func (p *removeablePlatform) SyncNotify(ctx context.Context) (err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.SyncNotify()
+	return p.remote.SyncNotify(ctx)
 }
 
-func (p *removeablePlatform) JobStatus(id job.ID) (_ job.Status, err error) {
+func (p *removeablePlatform) JobStatus(ctx context.Context, id job.ID) (_ job.Status, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.JobStatus(id)
+	return p.remote.JobStatus(ctx, id)
 }
 
-func (p *removeablePlatform) SyncStatus(ref string) (revs []string, err error) {
+func (p *removeablePlatform) SyncStatus(ctx context.Context, ref string) (revs []string, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.SyncStatus(ref)
+	return p.remote.SyncStatus(ctx, ref)
 }
 
-func (p *removeablePlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig, err error) {
+func (p *removeablePlatform) GitRepoConfig(ctx context.Context, regenerate bool) (_ flux.GitConfig, err error) {
 	defer func() {
 		if _, ok := err.(FatalError); ok {
 			p.closeWithError(err)
 		}
 	}()
-	return p.remote.GitRepoConfig(regenerate)
+	return p.remote.GitRepoConfig(ctx, regenerate)
+}
+
+func (p *removeablePlatform) JobLog(ctx context.Context, id job.ID) (_ job.Log, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.JobLog(ctx, id)
+}
+
+func (p *removeablePlatform) WaitJobStatus(ctx context.Context, req job.WaitRequest) (_ job.Status, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.WaitJobStatus(ctx, req)
+}
+
+func (p *removeablePlatform) ClusterConfig(ctx context.Context) (_ flux.ClusterConfig, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ClusterConfig(ctx)
+}
+
+func (p *removeablePlatform) EvaluateImage(ctx context.Context, image flux.ImageID) (_ update.Result, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.EvaluateImage(ctx, image)
+}
+
+func (p *removeablePlatform) ServiceTopology(ctx context.Context) (_ []flux.ServiceTopology, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ServiceTopology(ctx)
+}
+
+func (p *removeablePlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (_ ExportChunk, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ExportChunk(ctx, req)
+}
+
+func (p *removeablePlatform) ResetGitToRemote(ctx context.Context) (_ string, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ResetGitToRemote(ctx)
+}
+
+func (p *removeablePlatform) ExportAt(ctx context.Context, ref string) (_ []byte, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ExportAt(ctx, ref)
+}
+
+func (p *removeablePlatform) InjectFault(ctx context.Context, spec FaultSpec) (err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.InjectFault(ctx, spec)
+}
+
+func (p *removeablePlatform) SyncHealth(ctx context.Context) (_ SyncHealth, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.SyncHealth(ctx)
+}
+
+func (p *removeablePlatform) ListResources(ctx context.Context) (_ []flux.ResourceStatus, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ListResources(ctx)
+}
+
+func (p *removeablePlatform) SyncWait(ctx context.Context, req SyncWaitRequest) (_ SyncReport, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.SyncWait(ctx, req)
+}
+
+func (p *removeablePlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) (err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.ApplyConfig(ctx, config)
+}
+
+func (p *removeablePlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (_ update.Result, err error) {
+	defer func() {
+		if _, ok := err.(FatalError); ok {
+			p.closeWithError(err)
+		}
+	}()
+	return p.remote.PlanRelease(ctx, spec)
 }
 
 // disconnectedPlatform is a stub implementation used when the
@@ -229,43 +356,99 @@ func (p *removeablePlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig, e
 
 type disconnectedPlatform struct{}
 
-func (p disconnectedPlatform) Ping() error {
+func (p disconnectedPlatform) Ping(ctx context.Context) error {
 	return errNotSubscribed
 }
 
-func (p disconnectedPlatform) Version() (string, error) {
+func (p disconnectedPlatform) Version(ctx context.Context) (string, error) {
 	return "", errNotSubscribed
 }
 
-func (p disconnectedPlatform) Export() ([]byte, error) {
+func (p disconnectedPlatform) Export(ctx context.Context) ([]byte, error) {
 	return nil, errNotSubscribed
 }
 
-func (p disconnectedPlatform) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+func (p disconnectedPlatform) ListServices(ctx context.Context, namespace string) ([]flux.ServiceStatus, error) {
 	return nil, errNotSubscribed
 }
 
-func (p disconnectedPlatform) ListImages(update.ServiceSpec) ([]flux.ImageStatus, error) {
+func (p disconnectedPlatform) ListImages(context.Context, update.ServiceSpec) ([]flux.ImageStatus, error) {
 	return nil, errNotSubscribed
 }
 
-func (p disconnectedPlatform) UpdateManifests(update.Spec) (job.ID, error) {
+func (p disconnectedPlatform) UpdateManifests(context.Context, update.Spec) (job.ID, error) {
 	var id job.ID
 	return id, errNotSubscribed
 }
 
-func (p disconnectedPlatform) SyncNotify() error {
+func (p disconnectedPlatform) SyncNotify(ctx context.Context) error {
 	return errNotSubscribed
 }
 
-func (p disconnectedPlatform) JobStatus(id job.ID) (job.Status, error) {
+func (p disconnectedPlatform) JobStatus(ctx context.Context, id job.ID) (job.Status, error) {
 	return job.Status{}, errNotSubscribed
 }
 
-func (p disconnectedPlatform) SyncStatus(string) ([]string, error) {
+func (p disconnectedPlatform) SyncStatus(context.Context, string) ([]string, error) {
 	return nil, errNotSubscribed
 }
 
-func (p disconnectedPlatform) GitRepoConfig(bool) (flux.GitConfig, error) {
+func (p disconnectedPlatform) GitRepoConfig(context.Context, bool) (flux.GitConfig, error) {
 	return flux.GitConfig{}, errNotSubscribed
 }
+
+func (p disconnectedPlatform) JobLog(context.Context, job.ID) (job.Log, error) {
+	return job.Log{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) WaitJobStatus(context.Context, job.WaitRequest) (job.Status, error) {
+	return job.Status{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ClusterConfig(ctx context.Context) (flux.ClusterConfig, error) {
+	return flux.ClusterConfig{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) EvaluateImage(context.Context, flux.ImageID) (update.Result, error) {
+	return nil, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ServiceTopology(ctx context.Context) ([]flux.ServiceTopology, error) {
+	return nil, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ExportChunk(ctx context.Context, req ExportChunkRequest) (ExportChunk, error) {
+	return ExportChunk{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ResetGitToRemote(ctx context.Context) (string, error) {
+	return "", errNotSubscribed
+}
+
+func (p disconnectedPlatform) ExportAt(ctx context.Context, ref string) ([]byte, error) {
+	return nil, errNotSubscribed
+}
+
+func (p disconnectedPlatform) InjectFault(ctx context.Context, spec FaultSpec) error {
+	return errNotSubscribed
+}
+
+func (p disconnectedPlatform) SyncHealth(ctx context.Context) (SyncHealth, error) {
+	return SyncHealth{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ListResources(ctx context.Context) ([]flux.ResourceStatus, error) {
+	return nil, errNotSubscribed
+}
+
+func (p disconnectedPlatform) SyncWait(ctx context.Context, req SyncWaitRequest) (SyncReport, error) {
+	return SyncReport{}, errNotSubscribed
+}
+
+func (p disconnectedPlatform) ApplyConfig(ctx context.Context, config service.DaemonConfig) error {
+	return errNotSubscribed
+}
+
+func (p disconnectedPlatform) PlanRelease(ctx context.Context, spec update.ReleaseSpec) (update.Result, error) {
+	return nil, errNotSubscribed
+}
@@ -0,0 +1,50 @@
+package remote
+
+import (
+	"time"
+)
+
+// SyncObjective says how fresh the cluster should be kept: the last
+// successful sync should be no older than Freshness, for at least the
+// Target fraction (e.g., 0.99) of the time.
+type SyncObjective struct {
+	Freshness time.Duration `json:"freshness"`
+	Target    float64       `json:"target"`
+}
+
+// SyncHealth is how well the daemon has kept the cluster in sync with
+// the git repo over a rolling window.
+type SyncHealth struct {
+	Window      time.Duration `json:"window"`
+	Attempts    int           `json:"attempts"`
+	Successes   int           `json:"successes"`
+	LastSuccess time.Time     `json:"lastSuccess,omitempty"`
+	// SinceLastSuccess is how long ago LastSuccess was, at the time
+	// of asking; or, if there hasn't been a successful sync, how long
+	// the daemon has been running.
+	SinceLastSuccess time.Duration `json:"sinceLastSuccess"`
+	// The rest is only given if there's an objective.
+	Objective *SyncObjective `json:"objective,omitempty"`
+	// StaleTime is how much of the window the cluster has been out of
+	// sync for longer than the objective allows.
+	StaleTime time.Duration `json:"staleTime,omitempty"`
+	// BudgetRemaining is the fraction of the time the cluster is
+	// allowed to be stale in the window that is left; zero or less
+	// means the budget is exhausted.
+	BudgetRemaining float64 `json:"budgetRemaining,omitempty"`
+}
+
+// SuccessRate is the fraction of sync attempts in the window that
+// succeeded; with no attempts, it's zero.
+func (h SyncHealth) SuccessRate() float64 {
+	if h.Attempts == 0 {
+		return 0
+	}
+	return float64(h.Successes) / float64(h.Attempts)
+}
+
+// BudgetExhausted says whether the cluster has been stale for longer
+// than the objective allows.
+func (h SyncHealth) BudgetExhausted() bool {
+	return h.Objective != nil && h.BudgetRemaining <= 0
+}
@@ -0,0 +1,57 @@
+package remote
+
+import (
+	"time"
+)
+
+// SyncWaitRequest asks for a report of a full sync. Since is when the
+// sync was asked for, as given back in an earlier report that wasn't
+// Done; if it's zero, this is a fresh request, and the daemon will
+// sync straight away.
+type SyncWaitRequest struct {
+	Since time.Time `json:"since,omitempty"`
+}
+
+// ResourceError is a resource that failed to apply, and why.
+type ResourceError struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// SyncReport is the outcome of a full sync of the repo to the
+// cluster. If Done is false, the daemon stopped waiting for the sync
+// to finish before it did; ask again with Since set to Requested.
+type SyncReport struct {
+	// Requested is when the sync was asked for, by the daemon's clock
+	Requested time.Time     `json:"requested"`
+	Done      bool          `json:"done"`
+	Revision  string        `json:"revision,omitempty"`
+	Started   time.Time     `json:"started,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	// Applied are the resources that changed in the repo since the
+	// previous sync (or all of them, if that's not known), and
+	// Unchanged those that were applied again without having
+	// changed. Ignored resources aren't applied at all.
+	Applied   []string        `json:"applied,omitempty"`
+	Unchanged []string        `json:"unchanged,omitempty"`
+	Ignored   []string        `json:"ignored,omitempty"`
+	Failed    []ResourceError `json:"failed,omitempty"`
+	// Pruned are the resources deleted from the cluster because
+	// they've gone from the repo; or, if PruneDryRun, those that
+	// would have been.
+	Pruned      []string `json:"pruned,omitempty"`
+	PruneDryRun bool     `json:"pruneDryRun,omitempty"`
+	// Drifted are the resources flux applied that have gone from
+	// the repo, but are still in the cluster and weren't pruned.
+	// Kinds excluded from syncing are never counted.
+	Drifted []string `json:"drifted,omitempty"`
+	// Error is given if the sync failed as a whole, e.g., because
+	// the repo couldn't be read.
+	Error string `json:"error,omitempty"`
+}
+
+// Converged says whether the sync reported finished, and everything
+// in it was applied.
+func (r SyncReport) Converged() bool {
+	return r.Done && r.Error == "" && len(r.Failed) == 0
+}
@@ -0,0 +1,39 @@
+package remote
+
+import (
+	"context"
+
+	"github.com/weaveworks/flux/job"
+)
+
+// WatchJob sends each change in the status of a job to updates, until
+// the job finishes, or the context is cancelled. The platform does
+// the waiting for changes, so this doesn't poll.
+func WatchJob(ctx context.Context, p Platform, id job.ID, updates chan<- job.Status) error {
+	var last job.StatusString
+	for {
+		status, err := p.WaitJobStatus(ctx, job.WaitRequest{ID: id, Last: last})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if status.StatusString != last {
+			select {
+			case updates <- status:
+			case <-ctx.Done():
+				return nil
+			}
+			last = status.StatusString
+		}
+		if last.Terminal() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
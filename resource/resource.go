@@ -1,6 +1,8 @@
 package resource
 
 import (
+	"strings"
+
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/policy"
 )
@@ -13,3 +15,18 @@ type Resource interface {
 	Source() string                                  // where did this come from (informational)
 	Bytes() []byte                                   // the definition, for sending to platform.Sync
 }
+
+// SplitID takes apart a resource ID, e.g., "Deployment
+// default/helloworld", into its kind, namespace and name. Resources
+// that don't belong to a namespace (namespaces themselves, for one)
+// have IDs without one, e.g., "Namespace dev".
+func SplitID(id string) (kind, namespace, name string) {
+	kind, name = id, ""
+	if i := strings.Index(id, " "); i >= 0 {
+		kind, name = id[:i], id[i+1:]
+	}
+	if i := strings.Index(name, "/"); i >= 0 {
+		namespace, name = name[:i], name[i+1:]
+	}
+	return kind, namespace, name
+}
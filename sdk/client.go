@@ -0,0 +1,177 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/history"
+	transport "github.com/weaveworks/flux/http"
+	fluxclient "github.com/weaveworks/flux/http/client"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+// Client is for asking flux about the services it runs, and for
+// releasing to them and changing their policies. Releases and policy
+// updates are done by a job, whose ID is returned; JobStatus reports
+// how the job is getting on.
+//
+// Methods may be added to Client, so it's not meant to be implemented
+// outside this package, except by embedding it.
+type Client interface {
+	// ListServices gives the services in the namespace given, or in
+	// all namespaces if it's empty.
+	ListServices(ctx context.Context, namespace string) ([]Service, error)
+	// ListImages gives the containers of the service given (or of
+	// all services, with AllServices), with the images available for
+	// each.
+	ListImages(ctx context.Context, service string) ([]Service, error)
+	Release(context.Context, ReleaseSpec, Cause) (jobID string, err error)
+	UpdatePolicies(context.Context, []PolicyUpdate, Cause) (jobID string, err error)
+	JobStatus(ctx context.Context, jobID string) (JobStatus, error)
+	// SyncNotify asks for the cluster to be synced with the git repo
+	// straight away.
+	SyncNotify(context.Context) error
+	// SyncStatus gives the commits between the revision given and
+	// the head of the git repo that are yet to be applied to the
+	// cluster.
+	SyncStatus(ctx context.Context, revision string) ([]string, error)
+	// History gives the events for the service given (or all
+	// services, with AllServices) before the time given, newest
+	// first. A limit of -1 means no limit.
+	History(ctx context.Context, service string, before time.Time, limit int64) ([]Event, error)
+	// WatchEvents sends each event to the channel given as it's
+	// logged, until the context is cancelled or the connection is
+	// lost.
+	WatchEvents(ctx context.Context, events chan<- Event) error
+	// Export gives the definitions of everything running in the
+	// cluster, as YAML.
+	Export(context.Context) ([]byte, error)
+}
+
+// NewClient gives a Client for the flux API at the URL given (e.g.,
+// "https://cloud.weave.works/api/flux", or a daemon's
+// "http://localhost:3030/api/flux"), authenticating with the token
+// given, if it's not empty.
+func NewClient(url, token string) Client {
+	return ClientFor(fluxclient.New(http.DefaultClient, transport.NewAPIRouter(), url, flux.Token(token)), "")
+}
+
+// ClientFor gives a Client that uses the API given for the instance
+// given; e.g., for a program that runs the flux service itself.
+func ClientFor(api api.ClientService, inst service.InstanceID) Client {
+	return &client{api: api, inst: inst}
+}
+
+type client struct {
+	api  api.ClientService
+	inst service.InstanceID
+}
+
+func (c *client) ListServices(ctx context.Context, namespace string) ([]Service, error) {
+	statuses, err := c.api.ListServices(ctx, c.inst, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var services []Service
+	for _, s := range statuses {
+		services = append(services, fromServiceStatus(s))
+	}
+	return services, nil
+}
+
+func (c *client) ListImages(ctx context.Context, s string) ([]Service, error) {
+	spec, err := update.ParseServiceSpec(s)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := c.api.ListImages(ctx, c.inst, spec)
+	if err != nil {
+		return nil, err
+	}
+	var services []Service
+	for _, s := range statuses {
+		services = append(services, Service{ID: s.ID.String(), Containers: fromContainers(s.Containers)})
+	}
+	return services, nil
+}
+
+func (c *client) Release(ctx context.Context, s ReleaseSpec, cause Cause) (string, error) {
+	spec, err := s.toReleaseSpec()
+	if err != nil {
+		return "", err
+	}
+	id, err := c.api.UpdateImages(ctx, c.inst, spec, cause.toCause())
+	return string(id), err
+}
+
+func (c *client) UpdatePolicies(ctx context.Context, us []PolicyUpdate, cause Cause) (string, error) {
+	updates, err := toPolicyUpdates(us)
+	if err != nil {
+		return "", err
+	}
+	id, err := c.api.UpdatePolicies(ctx, c.inst, updates, cause.toCause(), false)
+	return string(id), err
+}
+
+func (c *client) JobStatus(ctx context.Context, jobID string) (JobStatus, error) {
+	status, err := c.api.JobStatus(ctx, c.inst, job.ID(jobID))
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return fromJobStatus(status), nil
+}
+
+func (c *client) SyncNotify(ctx context.Context) error {
+	return c.api.SyncNotify(ctx, c.inst)
+}
+
+func (c *client) SyncStatus(ctx context.Context, revision string) ([]string, error) {
+	return c.api.SyncStatus(ctx, c.inst, revision)
+}
+
+func (c *client) History(ctx context.Context, s string, before time.Time, limit int64) ([]Event, error) {
+	spec, err := update.ParseServiceSpec(s)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.api.History(ctx, c.inst, spec, before, limit, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, e := range entries {
+		events = append(events, fromEntry(e))
+	}
+	return events, nil
+}
+
+func (c *client) WatchEvents(ctx context.Context, events chan<- Event) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := make(chan history.Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.api.WatchEvents(ctx, c.inst, received)
+	}()
+	for {
+		select {
+		case e := <-received:
+			select {
+			case events <- fromEvent(e):
+			case <-ctx.Done():
+				return <-errc
+			}
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+func (c *client) Export(ctx context.Context) ([]byte, error) {
+	return c.api.Export(ctx, c.inst)
+}
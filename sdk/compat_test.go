@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/history"
+	fluxclient "github.com/weaveworks/flux/http/client"
+	"github.com/weaveworks/flux/service"
+)
+
+// These pin the SDK as it is for programs using it. If one of them no
+// longer compiles, a change has broken those programs; rather than
+// change what's here, put back what was taken away.
+
+// The methods of Client, as of the first version. More may be added.
+var _ interface {
+	ListServices(ctx context.Context, namespace string) ([]Service, error)
+	ListImages(ctx context.Context, service string) ([]Service, error)
+	Release(context.Context, ReleaseSpec, Cause) (string, error)
+	UpdatePolicies(context.Context, []PolicyUpdate, Cause) (string, error)
+	JobStatus(ctx context.Context, jobID string) (JobStatus, error)
+	SyncNotify(context.Context) error
+	SyncStatus(ctx context.Context, revision string) ([]string, error)
+	History(ctx context.Context, service string, before time.Time, limit int64) ([]Event, error)
+	WatchEvents(ctx context.Context, events chan<- Event) error
+	Export(context.Context) ([]byte, error)
+} = Client(nil)
+
+// Upstream must stay implementable by programs, so it can't gain
+// methods either.
+type upstreamV1 interface {
+	LogEvent(Event) error
+}
+
+var _ Upstream = upstreamV1(nil)
+var _ upstreamV1 = Upstream(nil)
+
+// The constructors, with their signatures.
+var (
+	_ func(url, token string) Client                     = NewClient
+	_ func(api.ClientService, service.InstanceID) Client = ClientFor
+	_ func(Upstream) history.EventWriter                 = EventWriter
+	_ func(history.EventWriter) Upstream                 = UpstreamFor
+)
+
+// The fields of the types, as of the first version. More may be
+// added, so these are checked by setting each field, rather than by
+// conversion.
+var (
+	_ = Service{ID: "", Containers: []Container{}, Status: "", Automated: false, Locked: false}
+	_ = Container{Name: "", Image: "", Available: []string{}}
+	_ = ReleaseSpec{Services: []string{}, Image: "", Exclude: []string{}, DryRun: false}
+	_ = PolicyUpdate{Service: "", Add: map[string]string{}, Remove: []string{}}
+	_ = Cause{User: "", Message: ""}
+	_ = JobStatus{Status: "", Error: "", Revision: ""}
+	_ = Event{ID: int64(0), ServiceIDs: []string{}, Type: "", StartedAt: time.Time{}, EndedAt: time.Time{}, LogLevel: "", Message: ""}
+	_ = JobStatus{}.Done
+)
+
+// The flux client satisfies what the SDK needs of the API.
+var _ api.ClientService = &fluxclient.Client{}
+
+// The values of the constants are part of the API too, since they're
+// sent over the wire.
+func TestConstants(t *testing.T) {
+	for got, expected := range map[string]string{
+		EventRelease:     "release",
+		EventAutoRelease: "autorelease",
+		EventSync:        "sync",
+		EventCommit:      "commit",
+		JobQueued:        "queued",
+		JobRunning:       "running",
+		JobSucceeded:     "succeeded",
+		JobFailed:        "failed",
+		AllServices:      "<all>",
+		LatestImages:     "<all latest>",
+	} {
+		if got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	}
+}
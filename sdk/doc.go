@@ -0,0 +1,18 @@
+/*
+Package sdk is for programs that use flux from Go, e.g., a portal
+that lists services and releases to them, or a service that keeps
+the events of its daemons.
+
+The rest of the packages in this repo are flux's own, and change as
+flux does, without notice. This package is kept stable: its types are
+its own, rather than those of the packages it uses, and are changed
+only by adding to them; and the interfaces here don't lose methods,
+or have their methods changed. That these promises are kept is
+checked when the package is compiled and tested (see compat_test.go),
+so that a change to flux that would break a program using this
+package breaks flux's build instead.
+
+Client is for talking to the flux service (or a standalone daemon),
+and Upstream for receiving the events a daemon logs.
+*/
+package sdk
@@ -0,0 +1,119 @@
+package sdk_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/api"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/sdk"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+func ExampleNewClient() {
+	client := sdk.NewClient("http://localhost:3030/api/flux", "")
+	services, err := client.ListServices(context.Background(), "default")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, s := range services {
+		fmt.Println(s.ID, s.Status)
+	}
+}
+
+// fakeAPI stands in for the flux service in the examples; the
+// methods it doesn't have panic if called.
+type fakeAPI struct {
+	api.ClientService
+	released update.ReleaseSpec
+}
+
+func (f *fakeAPI) ListServices(ctx context.Context, _ service.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:v1")
+	return []flux.ServiceStatus{{
+		ID:         flux.MakeServiceID(namespace, "helloworld"),
+		Containers: []flux.Container{{Name: "greeter", Current: flux.Image{ID: image}}},
+		Status:     "ready",
+		Automated:  true,
+	}}, nil
+}
+
+func (f *fakeAPI) UpdateImages(ctx context.Context, _ service.InstanceID, spec update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+	f.released = spec
+	return job.ID("job-1"), nil
+}
+
+func (f *fakeAPI) JobStatus(ctx context.Context, _ service.InstanceID, id job.ID) (job.Status, error) {
+	return job.Status{
+		StatusString: job.StatusSucceeded,
+		Result:       history.CommitEventMetadata{Revision: "8c5f2e9a"},
+	}, nil
+}
+
+func ExampleClientFor() {
+	client := sdk.ClientFor(&fakeAPI{}, "")
+	services, err := client.ListServices(context.Background(), "default")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, s := range services {
+		fmt.Println(s.ID, s.Status, s.Automated)
+		for _, c := range s.Containers {
+			fmt.Println(" ", c.Name, c.Image)
+		}
+	}
+	// Output:
+	// default/helloworld ready true
+	//   greeter quay.io/weaveworks/helloworld:v1
+}
+
+func ExampleClient_Release() {
+	ctx := context.Background()
+	client := sdk.ClientFor(&fakeAPI{}, "")
+	jobID, err := client.Release(ctx, sdk.ReleaseSpec{
+		Services: []string{"default/helloworld"},
+		Image:    "quay.io/weaveworks/helloworld:v2",
+	}, sdk.Cause{User: "portal", Message: "Release v2"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for {
+		status, err := client.JobStatus(ctx, jobID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if status.Done() {
+			fmt.Println(status.Status, status.Revision)
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	// Output: succeeded 8c5f2e9a
+}
+
+type printer struct{}
+
+func (printer) LogEvent(e sdk.Event) error {
+	fmt.Println(e.Type, e.ServiceIDs, e.Message)
+	return nil
+}
+
+func ExampleEventWriter() {
+	// The events given to the EventWriter (e.g., by a daemon) are
+	// passed to the Upstream
+	w := sdk.EventWriter(printer{})
+	w.LogEvent(history.Event{
+		ServiceIDs: []flux.ServiceID{"default/helloworld"},
+		Type:       history.EventLock,
+		LogLevel:   history.LogLevelInfo,
+	})
+	// Output: lock [default/helloworld] Locked: default/helloworld
+}
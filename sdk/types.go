@@ -0,0 +1,256 @@
+package sdk
+
+import (
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// The types of event, as given in Event.Type. There may be others,
+// from newer versions of flux.
+const (
+	EventCommit       = history.EventCommit
+	EventSync         = history.EventSync
+	EventRelease      = history.EventRelease
+	EventAutoRelease  = history.EventAutoRelease
+	EventAutomate     = history.EventAutomate
+	EventDeautomate   = history.EventDeautomate
+	EventLock         = history.EventLock
+	EventUnlock       = history.EventUnlock
+	EventPolicyChange = history.EventPolicyChange
+	EventFailure      = history.EventFailure
+)
+
+// The states of a job, as given in JobStatus.Status.
+const (
+	JobQueued    = string(job.StatusQueued)
+	JobRunning   = string(job.StatusRunning)
+	JobSucceeded = string(job.StatusSucceeded)
+	JobFailed    = string(job.StatusFailed)
+)
+
+// AllServices and LatestImages can be given in a ReleaseSpec, to
+// release to every service, or to release the newest image for each
+// container, respectively.
+const (
+	AllServices  = string(update.ServiceSpecAll)
+	LatestImages = string(update.ImageSpecLatest)
+)
+
+// Service is a service running in the cluster. IDs of services are
+// given as "namespace/name".
+type Service struct {
+	ID         string
+	Containers []Container
+	Status     string
+	Automated  bool
+	Locked     bool
+}
+
+// Container is a container in the pods of a service, and the image
+// it's running. Available, if asked for, lists the images that could
+// be released to it, newest first.
+type Container struct {
+	Name      string
+	Image     string
+	Available []string
+}
+
+// ReleaseSpec says which images to release to which services. Images
+// is an image with a tag (e.g., "quay.io/weaveworks/helloworld:v2"),
+// or LatestImages; Services are service IDs, or AllServices.
+// Containers, if given, limits the release to those containers, each
+// given as "namespace/service:container". A dry run reports what
+// would be released, without releasing it.
+type ReleaseSpec struct {
+	Services   []string
+	Image      string
+	Exclude    []string
+	Containers []string
+	DryRun     bool
+}
+
+// PolicyUpdate adds policies to a service, or removes them; e.g.,
+// adding "automated" (with the value "true") automates the service.
+type PolicyUpdate struct {
+	Service string
+	Add     map[string]string
+	Remove  []string
+}
+
+// Cause says who asked for a change, and why; it's used in the
+// commit message, and the history.
+type Cause struct {
+	User    string
+	Message string
+}
+
+// JobStatus is the state of a job queued by a release or policy
+// update. Revision is the commit made by a job that succeeded, if it
+// made one.
+type JobStatus struct {
+	Status   string
+	Error    string
+	Revision string
+}
+
+// Done says whether the job has finished, one way or the other.
+func (s JobStatus) Done() bool {
+	return s.Status == JobSucceeded || s.Status == JobFailed
+}
+
+// Event is something that happened to services; e.g., a release, or
+// a sync. Message summarises it.
+type Event struct {
+	ID         int64
+	ServiceIDs []string
+	Type       string
+	StartedAt  time.Time
+	EndedAt    time.Time
+	LogLevel   string
+	Message    string
+}
+
+func fromServiceStatus(s flux.ServiceStatus) Service {
+	return Service{
+		ID:         s.ID.String(),
+		Containers: fromContainers(s.Containers),
+		Status:     s.Status,
+		Automated:  s.Automated,
+		Locked:     s.Locked,
+	}
+}
+
+func fromContainers(cs []flux.Container) []Container {
+	var result []Container
+	for _, c := range cs {
+		container := Container{Name: c.Name, Image: c.Current.ID.String()}
+		for _, a := range c.Available {
+			container.Available = append(container.Available, a.ID.String())
+		}
+		result = append(result, container)
+	}
+	return result
+}
+
+func fromEvent(e history.Event) Event {
+	event := Event{
+		ID:        int64(e.ID),
+		Type:      e.Type,
+		StartedAt: e.StartedAt,
+		EndedAt:   e.EndedAt,
+		LogLevel:  e.LogLevel,
+		Message:   summary(e),
+	}
+	for _, id := range e.ServiceIDs {
+		event.ServiceIDs = append(event.ServiceIDs, id.String())
+	}
+	return event
+}
+
+// summary gives the message for an event; or nothing, if it can't be
+// summarised, since that relies on the event having the metadata for
+// its type.
+func summary(e history.Event) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	return e.String()
+}
+
+func fromEntry(e history.Entry) Event {
+	if e.Event != nil {
+		return fromEvent(*e.Event)
+	}
+	var stamp time.Time
+	if e.Stamp != nil {
+		stamp = *e.Stamp
+	}
+	return Event{Type: e.Type, StartedAt: stamp, EndedAt: stamp, Message: e.Data}
+}
+
+func fromJobStatus(s job.Status) JobStatus {
+	return JobStatus{
+		Status:   string(s.StatusString),
+		Error:    s.Err,
+		Revision: s.Result.Revision,
+	}
+}
+
+func (s ReleaseSpec) toReleaseSpec() (update.ReleaseSpec, error) {
+	spec := update.ReleaseSpec{Kind: update.ReleaseKindExecute}
+	if s.DryRun {
+		spec.Kind = update.ReleaseKindPlan
+	}
+	for _, service := range s.Services {
+		ss, err := update.ParseServiceSpec(service)
+		if err != nil {
+			return spec, err
+		}
+		spec.ServiceSpecs = append(spec.ServiceSpecs, ss)
+	}
+	image, err := update.ParseImageSpec(s.Image)
+	if err != nil {
+		return spec, err
+	}
+	spec.ImageSpec = image
+	for _, exclude := range s.Exclude {
+		id, err := flux.ParseServiceID(exclude)
+		if err != nil {
+			return spec, err
+		}
+		spec.Excludes = append(spec.Excludes, id)
+	}
+	for _, container := range s.Containers {
+		c, err := update.ParseContainerSpec(container)
+		if err != nil {
+			return spec, err
+		}
+		spec.Containers = append(spec.Containers, c)
+	}
+	return spec, nil
+}
+
+func toPolicyUpdates(us []PolicyUpdate) (policy.Updates, error) {
+	updates := policy.Updates{}
+	for _, u := range us {
+		id, err := flux.ParseServiceID(u.Service)
+		if err != nil {
+			return nil, err
+		}
+		var up policy.Update
+		for p, v := range u.Add {
+			up.Add = up.Add.Set(policy.Policy(p), v)
+		}
+		for _, p := range u.Remove {
+			up.Remove = up.Remove.Add(policy.Policy(p))
+		}
+		updates[id] = up
+	}
+	return updates, updates.Validate()
+}
+
+func (c Cause) toCause() update.Cause {
+	return update.Cause{User: c.User, Message: c.Message}
+}
+
+func (e Event) toEvent() history.Event {
+	event := history.Event{
+		ID:        history.EventID(e.ID),
+		Type:      e.Type,
+		StartedAt: e.StartedAt,
+		EndedAt:   e.EndedAt,
+		LogLevel:  e.LogLevel,
+		Message:   e.Message,
+	}
+	for _, id := range e.ServiceIDs {
+		event.ServiceIDs = append(event.ServiceIDs, flux.ServiceID(id))
+	}
+	return event
+}
@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"github.com/weaveworks/flux/history"
+)
+
+// Upstream receives the events logged by a daemon; e.g., to keep
+// them, or to send notifications about them. It's what a daemon's
+// events are given to, in place of the flux service.
+type Upstream interface {
+	LogEvent(Event) error
+}
+
+// EventWriter gives the upstream given as the history.EventWriter
+// that a daemon logs its events to (see daemon.Daemon.EventWriter).
+func EventWriter(u Upstream) history.EventWriter {
+	return eventWriter{u}
+}
+
+type eventWriter struct {
+	upstream Upstream
+}
+
+func (w eventWriter) LogEvent(e history.Event) error {
+	return w.upstream.LogEvent(fromEvent(e))
+}
+
+// UpstreamFor gives the history.EventWriter given (e.g., a client of
+// the flux service) as an Upstream.
+func UpstreamFor(w history.EventWriter) Upstream {
+	return upstream{w}
+}
+
+type upstream struct {
+	writer history.EventWriter
+}
+
+func (u upstream) LogEvent(e Event) error {
+	return u.writer.LogEvent(e.toEvent())
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+)
+
+// InjectFault asks the instance's daemon to simulate a failure for a
+// while, so that the alerting for it can be tried out. It's for
+// staging instances, and only works for those with the feature
+// switched on.
+func (s *Server) InjectFault(ctx context.Context, instID service.InstanceID, spec remote.FaultSpec) error {
+	if err := s.requireFeature(instID, service.FeatureFaultInjection); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return errors.Wrapf(err, "getting instance")
+	}
+	if err := inst.Platform.InjectFault(ctx, spec); err != nil {
+		return errors.Wrapf(err, "injecting %s fault", spec.Kind)
+	}
+	s.logger.Log("method", "InjectFault", "instanceID", instID, "kind", spec.Kind, "for", spec.For)
+	return nil
+}
@@ -1,15 +1,20 @@
 package server
 
 import (
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	fluxmetrics "github.com/weaveworks/flux/metrics"
 )
 
 var (
-	connectedDaemons = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+	connectedDaemons = fluxmetrics.NewGauge(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "fluxsvc",
 		Name:      "connected_daemons_count",
 		Help:      "Gauge of the current number of connected daemons",
-	}, []string{})
+	})
+	reconciledConnections = fluxmetrics.NewCounter(fluxmetrics.Opts{
+		Namespace: "flux",
+		Subsystem: "fluxsvc",
+		Name:      "reconciled_connections_total",
+		Help:      "Count of connection records found to be out of date, by whether the daemon reconnected or the record expired",
+	}, "outcome")
 )
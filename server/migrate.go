@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	transport "github.com/weaveworks/flux/http"
+	fluxclient "github.com/weaveworks/flux/http/client"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
+)
+
+// How often a connected daemon's instance is checked to see if it
+// has been migrated elsewhere.
+const migrationCheckInterval = 10 * time.Second
+
+var ErrInstanceMigrated = &flux.BaseError{
+	Help: `Instance migrated to another service
+
+The instance has been migrated to another service, so the daemon has
+been disconnected; when it reconnects it will be redirected to the
+other service.
+`,
+	Err: errors.New("instance migrated to another service"),
+}
+
+func (s *Server) ExportInstance(ctx context.Context, instID service.InstanceID) (instance.Migration, error) {
+	helper, err := s.instancer.Get(instID)
+	if err != nil {
+		return instance.Migration{}, errors.Wrapf(err, "getting instance")
+	}
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return instance.Migration{}, errors.Wrapf(err, "getting config")
+	}
+	// This is about this service, rather than the instance
+	config.Connection = instance.Connection{}
+	config.MigratedTo = ""
+
+	events, err := helper.AllEvents(time.Now().UTC(), -1, time.Unix(0, 0))
+	if err != nil {
+		return instance.Migration{}, errors.Wrap(err, "fetching all history events")
+	}
+	return instance.Migration{
+		ID:     instID,
+		Config: config,
+		Events: events,
+	}, nil
+}
+
+// ImportInstance takes the configuration and history from a
+// migration, and gives it to the instance given. The configuration
+// replaces what the instance had, while the history is added to.
+func (s *Server) ImportInstance(ctx context.Context, instID service.InstanceID, m instance.Migration) error {
+	helper, err := s.instancer.Get(instID)
+	if err != nil {
+		return errors.Wrapf(err, "getting instance")
+	}
+	if err := s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		config.Settings = m.Config.Settings
+		config.WebhookSecrets = m.Config.WebhookSecrets
+		config.DaemonTokens = m.Config.DaemonTokens
+		config.MigratedTo = ""
+		return config, nil
+	}); err != nil {
+		return errors.Wrapf(err, "importing config")
+	}
+
+	// Oldest first, so that they get IDs in the same order as they
+	// had before. NB these don't go through s.LogEvent, since
+	// they've already been notified.
+	for i := len(m.Events) - 1; i >= 0; i-- {
+		e := m.Events[i]
+		e.ID = 0
+		if err := helper.LogEvent(e); err != nil {
+			return errors.Wrapf(err, "importing event %d", m.Events[i].ID)
+		}
+	}
+	s.logger.Log("method", "ImportInstance", "instanceID", instID, "from", m.ID, "events", len(m.Events))
+	return nil
+}
+
+// MigrateInstance exports the instance, imports it into the service
+// given, then marks it as having moved there. Any daemon connected
+// for the instance will be disconnected, and redirected to the other
+// service when it reconnects.
+func (s *Server) MigrateInstance(ctx context.Context, instID service.InstanceID, target service.MigrationTarget) error {
+	if err := s.requireFeature(instID, service.FeatureMigration); err != nil {
+		return err
+	}
+	if target.URL == "" {
+		return errors.New("no URL given for migration target")
+	}
+	// Since this makes requests to the service the instance is
+	// migrated to, only those it's been told about are allowed
+	adminURL, ok := s.migrationTargets[strings.TrimSuffix(target.URL, "/")]
+	if !ok {
+		return errors.Errorf("%s is not a service instances can be migrated to", target.URL)
+	}
+	m, err := s.ExportInstance(ctx, instID)
+	if err != nil {
+		return err
+	}
+	to := fluxclient.New(http.DefaultClient, transport.NewAdminRouter(), adminURL, target.Token)
+	if err := to.ImportInstance(ctx, service.InstanceID(""), m); err != nil {
+		return errors.Wrapf(err, "importing instance to %s", target.URL)
+	}
+	return s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		config.MigratedTo = target.URL
+		return config, nil
+	})
+}
+
+func (s *Server) MigratedTo(instID service.InstanceID) (string, error) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting config")
+	}
+	return config.MigratedTo, nil
+}
+
+// watchMigration closes the channel returned if the instance is
+// migrated before stop is closed.
+func (s *Server) watchMigration(instID service.InstanceID, stop <-chan struct{}) <-chan struct{} {
+	migrated := make(chan struct{})
+	go func() {
+		t := time.NewTicker(migrationCheckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if to, err := s.MigratedTo(instID); err == nil && to != "" {
+					close(migrated)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return migrated
+}
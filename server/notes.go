@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/update"
+)
+
+func errJobNotSucceeded(jobID job.ID, status job.StatusString) error {
+	return flux.Missing{&flux.BaseError{
+		Help: `No release notes for job
+
+Release notes can only be composed for a job that has finished
+successfully. Please wait for the job to finish (or check why it
+failed) and try again.
+`,
+		Err: errors.Errorf("job %s has status %q", jobID, status),
+	}}
+}
+
+// ReleaseNotes composes the notes for the release done by the job
+// given. The images involved are looked up via the daemon, so that
+// the commits they were built from can be included.
+func (s *Server) ReleaseNotes(ctx context.Context, instID service.InstanceID, jobID job.ID) (update.ReleaseNotes, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return update.ReleaseNotes{}, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	status, err := inst.Platform.JobStatus(ctx, jobID)
+	if err != nil {
+		return update.ReleaseNotes{}, errors.Wrap(err, "getting job status")
+	}
+	if status.StatusString != job.StatusSucceeded {
+		return update.ReleaseNotes{}, errJobNotSucceeded(jobID, status.StatusString)
+	}
+
+	var cause update.Cause
+	if status.Result.Spec != nil {
+		cause = status.Result.Spec.Cause
+	}
+
+	// Only the services that were updated need their images looked
+	// up; and the images are only for decoration, so it's not fatal
+	// if they can't be had.
+	images := map[string]flux.Image{}
+	for _, id := range status.Result.Result.ServiceIDs() {
+		if len(status.Result.Result[flux.ServiceID(id)].PerContainer) == 0 {
+			continue
+		}
+		statuses, err := inst.Platform.ListImages(ctx, update.ServiceSpec(id))
+		if err != nil {
+			s.logger.Log("method", "ReleaseNotes", "service", id, "err", err)
+			continue
+		}
+		for _, st := range statuses {
+			for _, container := range st.Containers {
+				for _, img := range append(container.Available, container.Current) {
+					images[img.ID.String()] = img
+				}
+			}
+		}
+	}
+
+	notes, err := update.NewReleaseNotes(status.Result.Revision, cause, status.Result.Result, func(id flux.ImageID) (flux.Image, bool) {
+		img, ok := images[id.String()]
+		return img, ok
+	})
+	if err != nil {
+		return update.ReleaseNotes{}, err
+	}
+
+	config, err := inst.Config.Get()
+	if err != nil {
+		return update.ReleaseNotes{}, errors.Wrap(err, "getting config")
+	}
+	if tmpl := config.Settings.Slack.ReleaseNotesTemplate; tmpl != "" {
+		if notes.Text, err = notes.Render(tmpl); err != nil {
+			return update.ReleaseNotes{}, errors.Wrap(err, "rendering release notes with configured template")
+		}
+	}
+	return notes, nil
+}
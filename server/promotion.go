@@ -0,0 +1,531 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/integrations/prometheus"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
+	"github.com/weaveworks/flux/update"
+)
+
+const (
+	// How often the promotions started from a connected daemon's
+	// instance are moved along.
+	promotionCheckInterval = 30 * time.Second
+	// How long a stage has to release and roll out before the
+	// promotion is given up on.
+	promotionRolloutTimeout = 30 * time.Minute
+	// How many finished promotions are kept, so they can be looked at
+	// afterwards.
+	maxFinishedPromotions = 20
+	// The status a platform gives a service when it's running what
+	// it's meant to be running.
+	serviceStatusReady = "ready"
+)
+
+var ErrNoPromotion = flux.Missing{&flux.BaseError{
+	Help: `No such promotion
+
+There is no promotion with the ID given. You can see the promotions
+started from this instance by GETting /v6/promotions.
+`,
+	Err: errors.New("no such promotion"),
+}}
+
+func promotionNotAccepted(from, to service.InstanceID) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Help: `Promotion not accepted by instance
+
+A promotion can only release to another instance if that instance
+accepts promotions from the one the promotion is started from. To
+allow it, add the instance to the "promotions.acceptFrom" list in the
+config of the instance being released to.
+`,
+		Err: errors.Errorf("instance %s does not accept promotions from %s", to, from),
+	}}
+}
+
+// Promote starts a promotion of a release through the stages given,
+// which will be moved along while the instance's daemon is connected.
+// It returns the ID of the promotion.
+func (s *Server) Promote(ctx context.Context, instID service.InstanceID, spec service.PromotionSpec, cause update.Cause) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", flux.UserConfigProblem{&flux.BaseError{
+			Help: `Invalid promotion
+
+The promotion given can't be carried out; the error says why. A
+promotion must release a particular image (not "<all latest>") to
+particular services, and have at least two stages.
+`,
+			Err: err,
+		}}
+	}
+	p := service.NewPromotion(instID, spec, cause, time.Now().UTC())
+	for _, stage := range p.Spec.Stages {
+		if err := s.checkAcceptsPromotion(instID, stage.Instance); err != nil {
+			return "", err
+		}
+	}
+	if err := s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		config.Promotions = trimPromotions(append(config.Promotions, p))
+		return config, nil
+	}); err != nil {
+		return "", errors.Wrap(err, "storing promotion")
+	}
+	s.logPromotion(instID, p)
+	return p.ID, nil
+}
+
+// ListPromotions gives the promotions started from an instance,
+// including those recently finished, oldest first.
+func (s *Server) ListPromotions(ctx context.Context, instID service.InstanceID) ([]service.Promotion, error) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get config")
+	}
+	promotions := config.Promotions
+	if promotions == nil {
+		promotions = []service.Promotion{}
+	}
+	return promotions, nil
+}
+
+// CancelPromotion stops a promotion from going any further. It
+// doesn't undo releases already made. Cancelling a promotion that has
+// finished is not an error, but doesn't change it.
+func (s *Server) CancelPromotion(ctx context.Context, instID service.InstanceID, id string) error {
+	var cancelled *service.Promotion
+	if err := s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		for i := range config.Promotions {
+			p := &config.Promotions[i]
+			if p.ID != id {
+				continue
+			}
+			if !p.State.Terminal() {
+				p.State = service.PromotionCancelled
+				p.Updated = time.Now().UTC()
+				cancelled = p
+			}
+			return config, nil
+		}
+		return config, ErrNoPromotion
+	}); err != nil {
+		return err
+	}
+	if cancelled != nil {
+		s.logPromotion(instID, *cancelled)
+	}
+	return nil
+}
+
+// checkAcceptsPromotion says whether an instance may release to
+// another as part of a promotion. An instance can always release to
+// itself.
+func (s *Server) checkAcceptsPromotion(from, to service.InstanceID) error {
+	if from == to {
+		return nil
+	}
+	config, err := s.config.GetConfig(to)
+	if err != nil {
+		return errors.Wrapf(err, "getting config for %s", to)
+	}
+	if !config.Settings.Promotions.Accepts(from) {
+		return promotionNotAccepted(from, to)
+	}
+	return nil
+}
+
+// trimPromotions drops the oldest finished promotions, so that only
+// so many are kept.
+func trimPromotions(promotions []service.Promotion) []service.Promotion {
+	var finished int
+	for _, p := range promotions {
+		if p.State.Terminal() {
+			finished++
+		}
+	}
+	var kept []service.Promotion
+	for _, p := range promotions {
+		if p.State.Terminal() && finished > maxFinishedPromotions {
+			finished--
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// drivePromotions moves along the promotions started from an
+// instance, until stop is closed.
+func (s *Server) drivePromotions(instID service.InstanceID, stop <-chan struct{}) {
+	t := time.NewTicker(promotionCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.stepPromotions(instID)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *Server) stepPromotions(instID service.InstanceID) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		s.logger.Log("method", "stepPromotions", "instance", instID, "err", err)
+		return
+	}
+	for _, p := range config.Promotions {
+		if p.State.Terminal() {
+			continue
+		}
+		now := time.Now().UTC()
+		ctx, cancel := context.WithTimeout(context.Background(), promotionCheckInterval)
+		next := s.stepPromotion(ctx, instID, p, now)
+		cancel()
+		if reflect.DeepEqual(next, p) {
+			continue
+		}
+		next.Updated = now
+		saved, err := s.savePromotion(instID, next)
+		if err != nil {
+			s.logger.Log("method", "stepPromotions", "instance", instID, "promotion", p.ID, "err", err)
+			continue
+		}
+		if saved && (next.State != p.State || next.Stage != p.Stage) {
+			s.logPromotion(instID, next)
+		}
+	}
+}
+
+// savePromotion puts the promotion given in place of the one with the
+// same ID, unless it has finished (i.e., been cancelled) in the
+// meantime. It says whether it was saved.
+func (s *Server) savePromotion(instID service.InstanceID, p service.Promotion) (bool, error) {
+	var saved bool
+	err := s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		for i := range config.Promotions {
+			if config.Promotions[i].ID != p.ID {
+				continue
+			}
+			if !config.Promotions[i].State.Terminal() {
+				config.Promotions[i] = p
+				config.Promotions = trimPromotions(config.Promotions)
+				saved = true
+			}
+			break
+		}
+		return config, nil
+	})
+	return saved, err
+}
+
+// stepPromotion works out what a promotion should do next, doing it
+// if that means submitting a release, and gives the promotion as it
+// is afterwards.
+func (s *Server) stepPromotion(ctx context.Context, from service.InstanceID, p service.Promotion, now time.Time) service.Promotion {
+	stage := p.CurrentStage()
+	switch p.State {
+	case service.PromotionReleasing:
+		if p.JobID == "" {
+			// The config of the instance may have changed since the
+			// promotion was started
+			if err := s.checkAcceptsPromotion(from, stage.Instance); err != nil {
+				return failPromotion(p, err)
+			}
+			jobID, err := s.UpdateImages(ctx, stage.Instance, p.Spec.Release, p.Cause)
+			if err != nil {
+				return retryPromotion(p, err, now)
+			}
+			p.JobID = string(jobID)
+			p.Error = ""
+			return p
+		}
+		status, err := s.JobStatus(ctx, stage.Instance, job.ID(p.JobID))
+		if err != nil {
+			return retryPromotion(p, err, now)
+		}
+		switch status.StatusString {
+		case job.StatusFailed:
+			return failPromotion(p, errors.New(status.Err))
+		case job.StatusSucceeded:
+			if msg := status.Result.Result.Error(); msg != "" {
+				return failPromotion(p, errors.New(msg))
+			}
+			p.Revision = status.Result.Revision
+			p.Result = status.Result.Result
+			p.State = service.PromotionRollingOut
+			p.Error = ""
+			return p
+		}
+		return retryPromotion(p, nil, now)
+
+	case service.PromotionRollingOut:
+		healthy, err := s.promotionHealthy(ctx, stage.Instance, p.Result)
+		if err != nil {
+			return retryPromotion(p, err, now)
+		}
+		if !healthy {
+			return retryPromotion(p, nil, now)
+		}
+		// Validated when the promotion was started
+		soak, _ := stage.SoakDuration()
+		p.State = service.PromotionSoaking
+		p.SoakUntil = now.Add(soak)
+		p.Error = ""
+		return p
+
+	case service.PromotionSoaking, service.PromotionPaused:
+		healthy, err := s.promotionHealthy(ctx, stage.Instance, p.Result)
+		if err != nil {
+			// Keep soaking; the stage only finishes once it's been
+			// seen to be healthy
+			p.Error = err.Error()
+			return p
+		}
+		if !healthy {
+			return failPromotion(p, errors.Errorf("services released to %s became unhealthy while soaking", stage.Instance))
+		}
+		p.Error = ""
+		if stage.Analysis != nil {
+			result := analyseCanary(ctx, *stage.Analysis, now)
+			p.Analysis = &result
+			switch result.Action {
+			case service.CanaryRollback:
+				p.State = service.PromotionRollingBack
+				p.StageStarted = now
+				return p
+			case service.CanaryPause:
+				p.State = service.PromotionPaused
+				return p
+			}
+			p.State = service.PromotionSoaking
+		}
+		if now.Before(p.SoakUntil) {
+			return p
+		}
+		if p.Stage == len(p.Spec.Stages)-1 {
+			p.State = service.PromotionSucceeded
+			return p
+		}
+		p.Stage++
+		p.State = service.PromotionReleasing
+		p.StageStarted = now
+		p.JobID = ""
+		p.Revision = ""
+		p.Result = nil
+		p.SoakUntil = time.Time{}
+		p.Analysis = nil
+		return p
+
+	case service.PromotionRollingBack:
+		if p.RollbackJobID == "" {
+			cause := p.Cause
+			cause.Message = fmt.Sprintf("Roll back stage %d of promotion %s, after canary analysis failed", p.Stage+1, p.ID)
+			steps := rollbackSteps(p.Result, cause)
+			if len(steps) == 0 {
+				return failPromotion(p, errors.Errorf("canary analysis failed (%s), and there is nothing to roll back", failedChecks(p.Analysis)))
+			}
+			jobID, err := s.UpdateBatch(ctx, stage.Instance, steps, cause)
+			if err != nil {
+				return retryPromotion(p, err, now)
+			}
+			p.RollbackJobID = string(jobID)
+			p.Error = ""
+			return p
+		}
+		status, err := s.JobStatus(ctx, stage.Instance, job.ID(p.RollbackJobID))
+		if err != nil {
+			return retryPromotion(p, err, now)
+		}
+		switch status.StatusString {
+		case job.StatusFailed:
+			return failPromotion(p, errors.Errorf("canary analysis failed (%s), and rolling back failed: %s", failedChecks(p.Analysis), status.Err))
+		case job.StatusSucceeded:
+			return failPromotion(p, errors.Errorf("canary analysis failed (%s), so the stage was rolled back", failedChecks(p.Analysis)))
+		}
+		return retryPromotion(p, nil, now)
+	}
+	return p
+}
+
+// analyseCanary runs the checks of a canary analysis against
+// Prometheus, and says what the promotion should do about them.
+func analyseCanary(ctx context.Context, analysis service.CanaryAnalysis, now time.Time) service.CanaryResult {
+	client := prometheus.NewClient(analysis.Prometheus)
+	result := service.CanaryResult{At: now}
+	for _, check := range analysis.Checks {
+		value, err := client.Query(ctx, check.Query, now)
+		result.Checks = append(result.Checks, check.Result(value, err))
+	}
+	result.Action = analysis.Verdict(result.Checks)
+	return result
+}
+
+// failedChecks names the checks that didn't pass, for messages.
+func failedChecks(result *service.CanaryResult) string {
+	if result == nil {
+		return "no result"
+	}
+	var names []string
+	for _, c := range result.Checks {
+		if !c.Passed {
+			names = append(names, c.Name)
+		}
+	}
+	return "failed checks: " + strings.Join(names, ", ")
+}
+
+// rollbackSteps gives the releases that put back the images a
+// stage's release replaced, one for each image.
+func rollbackSteps(result update.Result, cause update.Cause) update.BatchSpec {
+	var images []flux.ImageID
+	services := map[string][]update.ServiceSpec{}
+	for _, id := range result.ServiceIDs() {
+		res := result[flux.ServiceID(id)]
+		if res.Status != update.ReleaseStatusSuccess {
+			continue
+		}
+		for _, c := range res.PerContainer {
+			image := c.Current.String()
+			specs, seen := services[image]
+			if !seen {
+				images = append(images, c.Current)
+			}
+			if len(specs) > 0 && specs[len(specs)-1] == update.ServiceSpec(id) {
+				continue
+			}
+			services[image] = append(specs, update.ServiceSpec(id))
+		}
+	}
+	var steps update.BatchSpec
+	for _, image := range images {
+		steps = append(steps, update.Spec{
+			Type:  update.Images,
+			Cause: cause,
+			Spec: update.ReleaseSpec{
+				ServiceSpecs: services[image.String()],
+				ImageSpec:    update.ImageSpecFromID(image),
+				Kind:         update.ReleaseKindExecute,
+			},
+		})
+	}
+	return steps
+}
+
+// retryPromotion notes the error, if there is one, and leaves the
+// promotion to be tried again next time; unless the stage has run out
+// of time to roll out, in which case the promotion fails.
+func retryPromotion(p service.Promotion, err error, now time.Time) service.Promotion {
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if now.Sub(p.StageStarted) > promotionRolloutTimeout {
+		msg := "timed out waiting for release to roll out"
+		if p.Error != "" {
+			msg += "; last error: " + p.Error
+		}
+		return failPromotion(p, errors.New(msg))
+	}
+	return p
+}
+
+func failPromotion(p service.Promotion, err error) service.Promotion {
+	p.State = service.PromotionFailed
+	p.Error = err.Error()
+	return p
+}
+
+// promotionHealthy says whether each service that was released is
+// ready and running the images it was released with.
+func (s *Server) promotionHealthy(ctx context.Context, instID service.InstanceID, result update.Result) (bool, error) {
+	services, err := s.ListServices(ctx, instID, "")
+	if err != nil {
+		return false, errors.Wrapf(err, "listing services in %s", instID)
+	}
+	running := map[flux.ServiceID]flux.ServiceStatus{}
+	for _, svc := range services {
+		running[svc.ID] = svc
+	}
+	for id, res := range result {
+		if res.Status != update.ReleaseStatusSuccess {
+			continue
+		}
+		svc, ok := running[id]
+		if !ok || svc.Status != serviceStatusReady {
+			return false, nil
+		}
+		for _, c := range res.PerContainer {
+			if !runningImage(svc.Containers, c.Container, c.Target) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func runningImage(containers []flux.Container, name string, image flux.ImageID) bool {
+	for _, c := range containers {
+		if c.Name == name {
+			return c.Current.ID.String() == image.String()
+		}
+	}
+	return false
+}
+
+// logPromotion records the state of a promotion in the history of
+// the instance it was started from and, if it's another, the instance
+// at the current stage.
+func (s *Server) logPromotion(from service.InstanceID, p service.Promotion) {
+	stage := p.CurrentStage()
+	var serviceIDs []flux.ServiceID
+	for _, spec := range p.Spec.Release.ServiceSpecs {
+		if id, err := spec.AsID(); err == nil {
+			serviceIDs = append(serviceIDs, id)
+		}
+	}
+	logLevel := history.LogLevelInfo
+	if p.State == service.PromotionFailed {
+		logLevel = history.LogLevelError
+	}
+	e := history.Event{
+		ServiceIDs: serviceIDs,
+		Type:       history.EventPromotion,
+		StartedAt:  p.Updated,
+		EndedAt:    p.Updated,
+		LogLevel:   logLevel,
+		Metadata: &history.PromotionEventMetadata{
+			ID:       p.ID,
+			From:     from,
+			Instance: stage.Instance,
+			Stage:    p.Stage,
+			Stages:   len(p.Spec.Stages),
+			State:    p.State,
+			Spec:     p.Spec.Release,
+			Cause:    p.Cause,
+			Revision: p.Revision,
+			Analysis: p.Analysis,
+			Error:    p.Error,
+		},
+	}
+	instIDs := []service.InstanceID{from}
+	if stage.Instance != from {
+		instIDs = append(instIDs, stage.Instance)
+	}
+	for _, instID := range instIDs {
+		if err := s.LogEvent(instID, e); err != nil {
+			s.logger.Log("method", "logPromotion", "instance", instID, "promotion", p.ID, "err", err)
+		}
+	}
+}
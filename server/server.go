@@ -1,6 +1,11 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -12,6 +17,7 @@ import (
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/notifications"
 	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/redact"
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/service/instance"
@@ -19,6 +25,47 @@ import (
 	"github.com/weaveworks/flux/update"
 )
 
+// How often a connected daemon is pinged, to check it's still there.
+const heartbeatInterval = 30 * time.Second
+
+// How many pings in a row a daemon can fail to answer before its
+// connection is given up on.
+const maxMissedHeartbeats = 3
+
+// How often the time of a connected daemon's last heartbeat is
+// written to its instance's config. This is less often than the
+// pings, so that a connected daemon doesn't cost a store write every
+// time it's pinged.
+const heartbeatRecordInterval = 2 * time.Minute
+
+// How long after its last recorded heartbeat a daemon that isn't
+// connected is taken to be reconnecting, rather than gone. Daemons
+// lose their connection whenever the service restarts, so this needs
+// to allow for them to notice and connect again, as well as for the
+// heartbeat being recorded only every so often.
+const reconnectGrace = heartbeatRecordInterval + 2*heartbeatInterval
+
+var ErrNoWebhookSecret = flux.Missing{&flux.BaseError{
+	Help: `No secret for webhook
+
+There is no secret for the webhook named. You can create one with
+the API, by POSTing to /v6/webhooks/{hook}/secret; the secret is
+returned in the response, once only.
+`,
+	Err: errors.New("no secret for webhook"),
+}}
+
+var ErrHeartbeatsMissed = &flux.BaseError{
+	Help: `Daemon stopped answering
+
+The daemon didn't answer several pings in a row, so its connection has
+been given up on. If it's still running, it will connect again.
+`,
+	Err: errors.New("daemon missed heartbeats"),
+}
+
+var ErrUnknownDaemonToken = errors.New("not a token issued to the daemon of the instance")
+
 type Server struct {
 	version     string
 	instancer   instance.Instancer
@@ -27,6 +74,14 @@ type Server struct {
 	logger      log.Logger
 	maxPlatform chan struct{} // semaphore for concurrent calls to the platform
 	connected   int32
+	events      *history.Broadcaster
+	rollout     service.FeatureRollout
+	redact      *redact.Rules // applied to events before they're kept or sent on
+	started     time.Time
+	pingEvery   time.Duration // heartbeatInterval, except in tests
+	// the services instances may be migrated to: the base URL
+	// daemons are redirected to, and the base URL of its admin API
+	migrationTargets map[string]string
 }
 
 func New(
@@ -34,7 +89,9 @@ func New(
 	instancer instance.Instancer,
 	config instance.DB,
 	messageBus remote.MessageBus,
+	rollout service.FeatureRollout,
 	logger log.Logger,
+	rules *redact.Rules,
 ) *Server {
 	connectedDaemons.Set(0)
 	return &Server{
@@ -44,10 +101,48 @@ func New(
 		messageBus:  messageBus,
 		logger:      logger,
 		maxPlatform: make(chan struct{}, 8),
+		events:      history.NewBroadcaster(),
+		rollout:     rollout,
+		redact:      rules,
+		started:     time.Now(),
+		pingEvery:   heartbeatInterval,
+	}
+}
+
+// AllowMigrationTo lets instances be migrated to the services given,
+// as a map of the base URL daemons will be redirected to, to the base
+// URL of the service's admin API (where the instance is imported).
+// Without any, instances can't be migrated.
+func (s *Server) AllowMigrationTo(targets map[string]string) {
+	s.migrationTargets = map[string]string{}
+	for u, admin := range targets {
+		s.migrationTargets[strings.TrimSuffix(u, "/")] = admin
+	}
+}
+
+// features gives the features switched on for an instance.
+func (s *Server) features(instID service.InstanceID) (flux.Features, error) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting config")
+	}
+	return s.rollout.Features(instID, config.Settings), nil
+}
+
+// requireFeature returns an error if the feature isn't switched on
+// for the instance.
+func (s *Server) requireFeature(instID service.InstanceID, name string) error {
+	features, err := s.features(instID)
+	if err != nil {
+		return err
 	}
+	if !features.Enabled(name) {
+		return service.FeatureNotEnabled(name)
+	}
+	return nil
 }
 
-func (s *Server) Status(instID service.InstanceID) (res service.Status, err error) {
+func (s *Server) Status(ctx context.Context, instID service.InstanceID) (res service.Status, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return res, errors.Wrapf(err, "getting instance")
@@ -59,23 +154,37 @@ func (s *Server) Status(instID service.InstanceID) (res service.Status, err erro
 	if err != nil {
 		return res, err
 	}
+	res.Features = s.rollout.Features(instID, config.Settings).Names()
 
 	res.Fluxd.Last = config.Connection.Last
+	res.Fluxd.LastHeartbeat = config.Connection.Heartbeat
+	res.Fluxd.Version = config.Connection.Version
+	res.Fluxd.ConfigVersion = config.Settings.DaemonConfig().Version
 	// DOn't bother trying to get information from the daemon if we
 	// haven't recorded it as connected
 	if config.Connection.Connected {
 		res.Fluxd.Connected = true
-		res.Fluxd.Version, err = inst.Platform.Version()
+		res.Fluxd.Version, err = inst.Platform.Version(ctx)
 		if err != nil {
 			return res, err
 		}
 
-		res.Git.Config, err = inst.Platform.GitRepoConfig(false)
+		res.Git.Config, err = inst.Platform.GitRepoConfig(ctx, false)
 		if err != nil {
 			return res, err
 		}
+		res.Git.Diverged = res.Git.Config.Diverged
+
+		// A daemon too old to answer this doesn't exclude anything,
+		// so it's fine to carry on without.
+		if clusterConfig, err := inst.Platform.ClusterConfig(ctx); err == nil {
+			res.Fluxd.ExcludedKinds = clusterConfig.ExcludeKinds
+			res.Fluxd.Features = clusterConfig.Features
+			res.Fluxd.AppliedConfigVersion = clusterConfig.ConfigVersion
+			res.Fluxd.ConfigError = clusterConfig.ConfigError
+		}
 
-		_, err = inst.Platform.SyncStatus("HEAD")
+		_, err = inst.Platform.SyncStatus(ctx, "HEAD")
 		if err != nil {
 			res.Git.Error = err.Error()
 		} else {
@@ -86,73 +195,205 @@ func (s *Server) Status(instID service.InstanceID) (res service.Status, err erro
 	return res, nil
 }
 
-func (s *Server) ListServices(instID service.InstanceID, namespace string) (res []flux.ServiceStatus, err error) {
+// Check verifies, in turn, that the daemon for the instance is
+// connected and that it is able to do its job. A failing check is
+// reported in the result rather than as an error; an error means the
+// checks could not be run at all.
+func (s *Server) Check(ctx context.Context, instID service.InstanceID) (res service.CheckReport, err error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return res, errors.Wrapf(err, "getting instance")
+	}
+
+	res.Run(service.CheckConnected, func() (string, error) {
+		return "", s.IsDaemonConnected(instID)
+	})
+	remote.CheckPlatform(ctx, inst.Platform, &res)
+	return res, nil
+}
+
+func (s *Server) ListServices(ctx context.Context, instID service.InstanceID, namespace string) (res []flux.ServiceStatus, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting instance")
 	}
 
-	services, err := inst.Platform.ListServices(namespace)
+	services, err := inst.Platform.ListServices(ctx, namespace)
 	if err != nil {
 		return nil, errors.Wrap(err, "getting services from platform")
 	}
 	return services, nil
 }
 
-func (s *Server) ListImages(instID service.InstanceID, spec update.ServiceSpec) (res []flux.ImageStatus, err error) {
+func (s *Server) ListImages(ctx context.Context, instID service.InstanceID, spec update.ServiceSpec) (res []flux.ImageStatus, err error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.ListImages(ctx, spec)
+}
+
+func (s *Server) EvaluateImage(ctx context.Context, instID service.InstanceID, image flux.ImageID) (update.Result, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.EvaluateImage(ctx, image)
+}
+
+func (s *Server) ServiceTopology(ctx context.Context, instID service.InstanceID) ([]flux.ServiceTopology, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.ServiceTopology(ctx)
+}
+
+func (s *Server) SyncHealth(ctx context.Context, instID service.InstanceID) (remote.SyncHealth, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return remote.SyncHealth{}, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.SyncHealth(ctx)
+}
+
+func (s *Server) ListResources(ctx context.Context, instID service.InstanceID) ([]flux.ResourceStatus, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.ListResources(ctx)
+}
+
+func (s *Server) SyncWait(ctx context.Context, instID service.InstanceID, req remote.SyncWaitRequest) (remote.SyncReport, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return remote.SyncReport{}, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.SyncWait(ctx, req)
+}
+
+func (s *Server) UpdateImages(ctx context.Context, instID service.InstanceID, spec update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.UpdateManifests(ctx, update.Spec{Type: update.Images, Cause: cause, Spec: spec})
+}
+
+func (s *Server) PlanRelease(ctx context.Context, instID service.InstanceID, spec update.ReleaseSpec) (update.Result, error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting instance "+string(instID))
 	}
-	return inst.Platform.ListImages(spec)
+	return inst.Platform.PlanRelease(ctx, spec)
+}
+
+func (s *Server) UpdatePolicies(ctx context.Context, instID service.InstanceID, updates policy.Updates, cause update.Cause, dryRun bool) (job.ID, error) {
+	if err := updates.Validate(); err != nil {
+		return "", err
+	}
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	specType := update.Policy
+	if dryRun {
+		specType = update.PolicyDryRun
+	}
+	return inst.Platform.UpdateManifests(ctx, update.Spec{Type: specType, Cause: cause, Spec: updates})
+}
+
+func (s *Server) ValidatePolicies(ctx context.Context, instID service.InstanceID, updates policy.Updates) error {
+	return updates.Validate()
 }
 
-func (s *Server) UpdateImages(instID service.InstanceID, spec update.ReleaseSpec, cause update.Cause) (job.ID, error) {
+func (s *Server) UpdateBatch(ctx context.Context, instID service.InstanceID, steps update.BatchSpec, cause update.Cause) (job.ID, error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return "", errors.Wrapf(err, "getting instance "+string(instID))
 	}
-	return inst.Platform.UpdateManifests(update.Spec{Type: update.Images, Cause: cause, Spec: spec})
+	return inst.Platform.UpdateManifests(ctx, update.Spec{Type: update.Batch, Cause: cause, Spec: steps})
 }
 
-func (s *Server) UpdatePolicies(instID service.InstanceID, updates policy.Updates, cause update.Cause) (job.ID, error) {
+func (s *Server) AddManifests(ctx context.Context, instID service.InstanceID, spec update.AddSpec, cause update.Cause) (job.ID, error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return "", errors.Wrapf(err, "getting instance "+string(instID))
 	}
+	return inst.Platform.UpdateManifests(ctx, update.Spec{Type: update.Add, Cause: cause, Spec: spec})
+}
 
-	return inst.Platform.UpdateManifests(update.Spec{Type: update.Policy, Cause: cause, Spec: updates})
+func (s *Server) Rollback(ctx context.Context, instID service.InstanceID, spec update.RollbackSpec, cause update.Cause) (job.ID, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.UpdateManifests(ctx, update.Spec{Type: update.Rollback, Cause: cause, Spec: spec})
 }
 
-func (s *Server) SyncNotify(instID service.InstanceID) (err error) {
+func (s *Server) SyncNotify(ctx context.Context, instID service.InstanceID) (err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return errors.Wrapf(err, "getting instance "+string(instID))
 	}
-	return inst.Platform.SyncNotify()
+	return inst.Platform.SyncNotify(ctx)
+}
+
+func (s *Server) ResetGitToRemote(ctx context.Context, instID service.InstanceID) (string, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+	return inst.Platform.ResetGitToRemote(ctx)
 }
 
-func (s *Server) JobStatus(instID service.InstanceID, jobID job.ID) (res job.Status, err error) {
+func (s *Server) JobStatus(ctx context.Context, instID service.InstanceID, jobID job.ID) (res job.Status, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return job.Status{}, errors.Wrapf(err, "getting instance "+string(instID))
 	}
 
-	return inst.Platform.JobStatus(jobID)
+	return inst.Platform.JobStatus(ctx, jobID)
 }
 
-func (s *Server) SyncStatus(instID service.InstanceID, ref string) (res []string, err error) {
+func (s *Server) JobLog(ctx context.Context, instID service.InstanceID, jobID job.ID) (job.Log, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return job.Log{}, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return inst.Platform.JobLog(ctx, jobID)
+}
+
+// WatchJob sends the status of a job to updates each time it
+// changes, until the job finishes or the context is cancelled.
+func (s *Server) WatchJob(ctx context.Context, instID service.InstanceID, jobID job.ID, updates chan<- job.Status) error {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return remote.WatchJob(ctx, inst.Platform, jobID, updates)
+}
+
+func (s *Server) SyncStatus(ctx context.Context, instID service.InstanceID, ref string) (res []string, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting instance "+string(instID))
 	}
 
-	return inst.Platform.SyncStatus(ref)
+	return inst.Platform.SyncStatus(ctx, ref)
 }
 
 // LogEvent receives events from fluxd and pushes events to the history
 // db and a slack notification
 func (s *Server) LogEvent(instID service.InstanceID, e history.Event) error {
+	e, err := s.redact.Event(e)
+	if err != nil {
+		return errors.Wrap(err, "redacting event")
+	}
 	s.logger.Log("method", "LogEvent", "instance", instID, "event", e)
 	helper, err := s.instancer.Get(instID)
 	if err != nil {
@@ -164,6 +405,7 @@ func (s *Server) LogEvent(instID service.InstanceID, e history.Event) error {
 	if err != nil {
 		return errors.Wrapf(err, "logging event")
 	}
+	s.events.Publish(instID, e)
 
 	cfg, err := helper.Config.Get()
 	if err != nil {
@@ -176,7 +418,31 @@ func (s *Server) LogEvent(instID service.InstanceID, e history.Event) error {
 	return nil
 }
 
-func (s *Server) History(inst service.InstanceID, spec update.ServiceSpec, before time.Time, limit int64, after time.Time) (res []history.Entry, err error) {
+// WatchEvents sends each event logged for the instance to events,
+// from now until the context is cancelled. Only events logged via
+// this server are seen.
+func (s *Server) WatchEvents(ctx context.Context, instID service.InstanceID, events chan<- history.Event) error {
+	if _, err := s.instancer.Get(instID); err != nil {
+		return errors.Wrapf(err, "getting instance")
+	}
+
+	watch, cancel := s.events.Watch(instID)
+	defer cancel()
+	for {
+		select {
+		case e := <-watch:
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *Server) History(ctx context.Context, inst service.InstanceID, spec update.ServiceSpec, before time.Time, limit int64, after time.Time) (res []history.Entry, err error) {
 	helper, err := s.instancer.Get(inst)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting instance")
@@ -213,7 +479,67 @@ func (s *Server) History(inst service.InstanceID, spec update.ServiceSpec, befor
 	return res, nil
 }
 
-func (s *Server) GetConfig(instID service.InstanceID, fingerprint string) (service.InstanceConfig, error) {
+// DeployedImages reports the image that was running in each of the
+// service's containers at the time given, going by the history of
+// releases.
+func (s *Server) DeployedImages(ctx context.Context, inst service.InstanceID, id flux.ServiceID, at time.Time) ([]history.DeployedImage, error) {
+	if err := s.requireFeature(inst, service.FeatureDeployedImages); err != nil {
+		return nil, err
+	}
+	helper, err := s.instancer.Get(inst)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance")
+	}
+
+	// The history isn't indexed by the images deployed, so this has to
+	// look at everything up to the time in question.
+	events, err := helper.EventsForService(id, at.Add(time.Nanosecond), -1, time.Unix(0, 0))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching history events for %s", id)
+	}
+	return history.DeployedImages(events, id, at), nil
+}
+
+// How many events are read from the history at a time, and at most,
+// when summarising services. A service that hasn't been released or
+// synced in the most recent summaryMaxEvents events will be
+// summarised without.
+const (
+	summaryPageSize  = 500
+	summaryMaxEvents = 10000
+)
+
+// ServiceSummaries gives, for every service, its most recent release
+// and sync, and whether it's automated or locked; i.e., what's needed
+// to show a list of services, without asking for the history of each.
+func (s *Server) ServiceSummaries(ctx context.Context, instID service.InstanceID) ([]history.ServiceSummary, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance")
+	}
+	services, err := inst.Platform.ListServices(ctx, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting services from the daemon")
+	}
+
+	summarizer := history.NewSummarizer(services)
+	before := time.Now().UTC()
+	for read := 0; read < summaryMaxEvents && !summarizer.Done(); {
+		events, err := inst.AllEvents(before, summaryPageSize, time.Unix(0, 0))
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching history events")
+		}
+		summarizer.Add(events)
+		if len(events) < summaryPageSize {
+			break
+		}
+		read += len(events)
+		before = events[len(events)-1].StartedAt
+	}
+	return summarizer.Summaries(), nil
+}
+
+func (s *Server) GetConfig(ctx context.Context, instID service.InstanceID, fingerprint string) (service.InstanceConfig, error) {
 	fullConfig, err := s.config.GetConfig(instID)
 	if err != nil {
 		return service.InstanceConfig{}, err
@@ -221,14 +547,18 @@ func (s *Server) GetConfig(instID service.InstanceID, fingerprint string) (servi
 
 	config := service.InstanceConfig(fullConfig.Settings)
 
-	return config, nil
+	return config.HideSecrets(), nil
 }
 
-func (s *Server) SetConfig(instID service.InstanceID, updates service.InstanceConfig) error {
-	return s.config.UpdateConfig(instID, applyConfigUpdates(updates))
+func (s *Server) SetConfig(ctx context.Context, instID service.InstanceID, updates service.InstanceConfig) error {
+	if err := s.config.UpdateConfig(instID, applyConfigUpdates(updates)); err != nil {
+		return err
+	}
+	go s.pushConfig(instID)
+	return nil
 }
 
-func (s *Server) PatchConfig(instID service.InstanceID, patch service.ConfigPatch) error {
+func (s *Server) PatchConfig(ctx context.Context, instID service.InstanceID, patch service.ConfigPatch) error {
 	fullConfig, err := s.config.GetConfig(instID)
 	if err != nil {
 		return errors.Wrap(err, "unable to get config")
@@ -239,23 +569,204 @@ func (s *Server) PatchConfig(instID service.InstanceID, patch service.ConfigPatc
 		return errors.Wrap(err, "unable to apply patch")
 	}
 
-	return s.config.UpdateConfig(instID, applyConfigUpdates(patchedConfig))
+	if err := s.config.UpdateConfig(instID, applyConfigUpdates(patchedConfig)); err != nil {
+		return err
+	}
+	go s.pushConfig(instID)
+	return nil
 }
 
+// applyConfigUpdates replaces the settings with those given, keeping
+// the secrets that were hidden when they were read.
 func applyConfigUpdates(updates service.InstanceConfig) instance.UpdateFunc {
 	return func(config instance.Config) (instance.Config, error) {
-		config.Settings = updates
+		updates := updates.RestoreSecrets(config.Settings)
+		if err := updates.Validate(); err != nil {
+			return config, flux.UserConfigProblem{&flux.BaseError{
+				Help: `Invalid instance config
+
+The config can't be used as it is; the error says why. The sync
+interval is a duration (e.g., "5m"), and namespace policies are
+checked as policies given to a service would be. Each registry needs
+a host (e.g., "quay.io"), a username, and a secret, naming where the
+daemon finds the password; e.g., the name of a file in the directory
+given to the daemon as --registry-secrets-dir.
+`,
+				Err: err,
+			}}
+		}
+		return config.WithSettings(updates, time.Now().UTC()), nil
+	}
+}
+
+// pushConfig gives the daemon's part of the instance's config to its
+// daemon, if it's connected and that part has changed. A daemon that
+// isn't connected is given it when it connects.
+func (s *Server) pushConfig(instID service.InstanceID) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		s.logger.Log("method", "pushConfig", "instance", instID, "err", err)
+		return
+	}
+	daemonConfig := config.Settings.DaemonConfig()
+	var previous string
+	if len(config.Recycled) > 0 {
+		previous = config.Recycled[0].Config.DaemonConfig().Version
+	}
+	if daemonConfig.Version == previous {
+		return
+	}
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		s.logger.Log("method", "pushConfig", "instance", instID, "err", err)
+		return
+	}
+	s.applyConfig(instID, inst.Platform, daemonConfig)
+}
+
+// applyConfig gives the daemon the config, and logs whether it was
+// applied.
+func (s *Server) applyConfig(instID service.InstanceID, platform remote.Platform, config service.DaemonConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+	if err := platform.ApplyConfig(ctx, config); err != nil {
+		s.logger.Log("method", "applyConfig", "instance", instID, "version", config.Version, "err", err)
+		return
+	}
+	s.logger.Log("method", "applyConfig", "instance", instID, "version", config.Version, "applied", true)
+}
+
+// ListRecycledConfigs gives the versions of the config that have
+// been replaced, most recent first.
+func (s *Server) ListRecycledConfigs(ctx context.Context, instID service.InstanceID) ([]service.RecycledConfig, error) {
+	fullConfig, err := s.config.GetConfig(instID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get config")
+	}
+	recycled := []service.RecycledConfig{}
+	for _, r := range fullConfig.Recycled {
+		recycled = append(recycled, service.RecycledConfig{Stamp: r.Stamp, Config: r.Config.HideSecrets()})
+	}
+	return recycled, nil
+}
+
+// RestoreConfig puts back the config at the index given in the
+// recycle bin; what it replaces is recycled in turn.
+func (s *Server) RestoreConfig(ctx context.Context, instID service.InstanceID, index int) error {
+	if err := s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		return config.RestoreSettings(index, time.Now().UTC())
+	}); err != nil {
+		return err
+	}
+	go s.pushConfig(instID)
+	return nil
+}
+
+// ListWebhookSecrets gives the webhook secrets for an instance,
+// without the secrets themselves.
+func (s *Server) ListWebhookSecrets(ctx context.Context, instID service.InstanceID) ([]service.WebhookSecret, error) {
+	fullConfig, err := s.config.GetConfig(instID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get config")
+	}
+	var hooks []string
+	for hook := range fullConfig.WebhookSecrets {
+		hooks = append(hooks, hook)
+	}
+	sort.Strings(hooks)
+	secrets := []service.WebhookSecret{}
+	for _, hook := range hooks {
+		secrets = append(secrets, fullConfig.WebhookSecrets[hook].Redacted())
+	}
+	return secrets, nil
+}
+
+// CreateWebhookSecret makes a new secret for a webhook, replacing any
+// secret it had before. This is the only time the secret is returned.
+func (s *Server) CreateWebhookSecret(ctx context.Context, instID service.InstanceID, hook string) (service.WebhookSecret, error) {
+	secret, err := service.NewWebhookSecret(hook)
+	if err != nil {
+		return service.WebhookSecret{}, errors.Wrap(err, "generating secret")
+	}
+	err = s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		if config.WebhookSecrets == nil {
+			config.WebhookSecrets = map[string]service.WebhookSecret{}
+		}
+		config.WebhookSecrets[hook] = secret
 		return config, nil
+	})
+	if err != nil {
+		return service.WebhookSecret{}, errors.Wrap(err, "storing secret")
 	}
+	return secret, nil
+}
+
+func (s *Server) DeleteWebhookSecret(ctx context.Context, instID service.InstanceID, hook string) error {
+	return s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		if _, ok := config.WebhookSecrets[hook]; !ok {
+			return config, ErrNoWebhookSecret
+		}
+		delete(config.WebhookSecrets, hook)
+		return config, nil
+	})
 }
 
-func (s *Server) PublicSSHKey(instID service.InstanceID, regenerate bool) (ssh.PublicKey, error) {
+// WebhookSecret gives the secret for validating requests to the
+// webhook named. This is for webhook receivers, rather than clients.
+func (s *Server) WebhookSecret(instID service.InstanceID, hook string) (string, error) {
+	fullConfig, err := s.config.GetConfig(instID)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get config")
+	}
+	secret, ok := fullConfig.WebhookSecrets[hook]
+	if !ok {
+		return "", ErrNoWebhookSecret
+	}
+	return secret.Secret, nil
+}
+
+func (s *Server) IssueDaemonToken(ctx context.Context, instID service.InstanceID) (service.DaemonToken, error) {
+	token, err := service.NewDaemonToken()
+	if err != nil {
+		return service.DaemonToken{}, errors.Wrap(err, "generating token")
+	}
+	err = s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		tokens := append([]service.DaemonToken{token}, config.DaemonTokens...)
+		if len(tokens) > service.DaemonTokensKept {
+			tokens = tokens[:service.DaemonTokensKept]
+		}
+		config.DaemonTokens = tokens
+		return config, nil
+	})
+	if err != nil {
+		return service.DaemonToken{}, errors.Wrap(err, "storing token")
+	}
+	return token, nil
+}
+
+// ValidateDaemonToken returns an error unless the token is one of
+// those issued to the daemon of the instance. Like WebhookSecret,
+// this is for authenticating requests, rather than for clients.
+func (s *Server) ValidateDaemonToken(instID service.InstanceID, token string) error {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return errors.Wrap(err, "unable to get config")
+	}
+	for _, t := range config.DaemonTokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return ErrUnknownDaemonToken
+}
+
+func (s *Server) PublicSSHKey(ctx context.Context, instID service.InstanceID, regenerate bool) (ssh.PublicKey, error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return ssh.PublicKey{}, errors.Wrapf(err, "getting instance "+string(instID))
 	}
 
-	gitRepoConfig, err := inst.Platform.GitRepoConfig(regenerate)
+	gitRepoConfig, err := inst.Platform.GitRepoConfig(ctx, regenerate)
 	if err != nil {
 		return ssh.PublicKey{}, err
 	}
@@ -285,6 +796,12 @@ func (s *Server) RegisterDaemon(instID service.InstanceID, platform remote.Platf
 	}()
 	connectedDaemons.Set(float64(atomic.AddInt32(&s.connected, 1)))
 
+	// A record of being connected from before the service started
+	// is one the service didn't get to update when it stopped
+	if config, err := s.config.GetConfig(instID); err == nil && config.Connection.Connected && config.Connection.Last.Before(s.started) {
+		reconciledConnections.With("outcome", "reconnected").Add(1)
+	}
+
 	// Record the time of connection in the "config"
 	now := time.Now()
 	s.config.UpdateConfig(instID, setConnectionTime(now))
@@ -294,9 +811,28 @@ func (s *Server) RegisterDaemon(instID service.InstanceID, platform remote.Platf
 	// closed. NB we cannot in general expect there to be a
 	// configuration record for this instance; it may be connecting
 	// before there is configuration supplied.
-	done := make(chan error)
+	done := make(chan error, 1)
 	s.messageBus.Subscribe(instID, s.instrumentPlatform(instID, platform), done)
-	err = <-done
+
+	// If the instance is migrated while the daemon is connected, we
+	// let go of it, so that it reconnects and is redirected.
+	stop := make(chan struct{})
+	defer close(stop)
+	// Promotions started from the instance are moved along while
+	// it's connected.
+	go s.drivePromotions(instID, stop)
+	// A connection can be left half-open (e.g., by a load balancer)
+	// without anything noticing, until it's used; so use it.
+	missed := s.heartbeat(instID, now, stop)
+	go s.recordVersion(instID, now, platform)
+	go s.sendConfig(instID, platform)
+	select {
+	case err = <-done:
+	case <-s.watchMigration(instID, stop):
+		err = ErrInstanceMigrated
+	case <-missed:
+		err = ErrHeartbeatsMissed
+	}
 	return err
 }
 
@@ -304,6 +840,93 @@ func setConnectionTime(t time.Time) instance.UpdateFunc {
 	return func(config instance.Config) (instance.Config, error) {
 		config.Connection.Last = t
 		config.Connection.Connected = true
+		config.Connection.Heartbeat = t
+		return config, nil
+	}
+}
+
+// heartbeat pings the daemon for an instance every so often, until
+// stop is closed, recording (every heartbeatRecordInterval) the time
+// it last answered. A ping that fails outright makes the message bus
+// let go of the connection, which ends the registration; but one
+// that times out doesn't, since the daemon may just be busy. So if
+// the daemon misses maxMissedHeartbeats pings in a row, the channel
+// returned is closed, to say the connection should be given up on.
+func (s *Server) heartbeat(instID service.InstanceID, t0 time.Time, stop <-chan struct{}) <-chan struct{} {
+	missed := make(chan struct{})
+	go func() {
+		t := time.NewTicker(s.pingEvery)
+		defer t.Stop()
+		recorded, misses := t0, 0
+		for {
+			select {
+			case <-t.C:
+				if err := s.messageBus.Ping(instID); err != nil {
+					s.logger.Log("method", "heartbeat", "instance", instID, "err", err)
+					if misses++; misses >= maxMissedHeartbeats {
+						close(missed)
+						return
+					}
+					continue
+				}
+				misses = 0
+				if now := time.Now(); now.Sub(recorded) >= heartbeatRecordInterval {
+					s.config.UpdateConfig(instID, setHeartbeatIf(t0, now))
+					recorded = now
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return missed
+}
+
+// recordVersion asks a newly connected daemon for its version, and
+// records it with the connection, so that it can be reported while the
+// daemon is away.
+func (s *Server) recordVersion(instID service.InstanceID, t0 time.Time, platform remote.Platform) {
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+	version, err := platform.Version(ctx)
+	if err != nil {
+		s.logger.Log("method", "recordVersion", "instance", instID, "err", err)
+		return
+	}
+	s.config.UpdateConfig(instID, func(config instance.Config) (instance.Config, error) {
+		if config.Connection.Last.Equal(t0) {
+			config.Connection.Version = version
+		}
+		return config, nil
+	})
+}
+
+// sendConfig gives a daemon that has just connected its part of the
+// instance's config, unless it reports having applied that version
+// already.
+func (s *Server) sendConfig(instID service.InstanceID, platform remote.Platform) {
+	config, err := s.config.GetConfig(instID)
+	if err != nil {
+		return
+	}
+	daemonConfig := config.Settings.DaemonConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+	// A daemon too old to report a version reports none, which
+	// is what it's due if there's no config for it.
+	if clusterConfig, err := platform.ClusterConfig(ctx); err == nil && clusterConfig.ConfigVersion == daemonConfig.Version {
+		return
+	}
+	s.applyConfig(instID, platform, daemonConfig)
+}
+
+// Like setDisconnectedIf, only record the heartbeat if it's for the
+// connection you think it is.
+func setHeartbeatIf(t0, t time.Time) instance.UpdateFunc {
+	return func(config instance.Config) (instance.Config, error) {
+		if config.Connection.Last.Equal(t0) {
+			config.Connection.Heartbeat = t
+		}
 		return config, nil
 	}
 }
@@ -320,13 +943,13 @@ func setDisconnectedIf(t0 time.Time) instance.UpdateFunc {
 	}
 }
 
-func (s *Server) Export(instID service.InstanceID) (res []byte, err error) {
+func (s *Server) Export(ctx context.Context, instID service.InstanceID) (res []byte, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
 		return res, errors.Wrapf(err, "getting instance")
 	}
 
-	res, err = inst.Platform.Export()
+	res, err = inst.Platform.Export(ctx)
 	if err != nil {
 		return res, errors.Wrapf(err, "exporting %s", instID)
 	}
@@ -334,6 +957,31 @@ func (s *Server) Export(instID service.InstanceID) (res []byte, err error) {
 	return res, nil
 }
 
+func (s *Server) ExportTo(ctx context.Context, instID service.InstanceID, w io.Writer) error {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return errors.Wrapf(err, "getting instance")
+	}
+
+	if err := remote.ExportTo(ctx, inst.Platform, w); err != nil {
+		return errors.Wrapf(err, "exporting %s", instID)
+	}
+	return nil
+}
+
+func (s *Server) ExportAt(ctx context.Context, instID service.InstanceID, ref string) ([]byte, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance")
+	}
+
+	res, err := inst.Platform.ExportAt(ctx, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "exporting %s at %s", instID, ref)
+	}
+	return res, nil
+}
+
 func (s *Server) instrumentPlatform(instID service.InstanceID, p remote.Platform) remote.Platform {
 	return &remote.ErrorLoggingPlatform{
 		remote.Instrument(p),
@@ -341,6 +989,28 @@ func (s *Server) instrumentPlatform(instID service.InstanceID, p remote.Platform
 	}
 }
 
+// IsDaemonConnected returns nil if the daemon for the instance is
+// connected. If it's not, but was seen only a moment ago, it's
+// assumed to be on its way back and DaemonReconnecting is returned.
 func (s *Server) IsDaemonConnected(instID service.InstanceID) error {
-	return s.messageBus.Ping(instID)
+	err := s.messageBus.Ping(instID)
+	if err == nil {
+		return nil
+	}
+	config, cerr := s.config.GetConfig(instID)
+	if cerr != nil || !config.Connection.Connected {
+		return err
+	}
+	if lastSeen := config.Connection.Heartbeat; time.Since(lastSeen) < reconnectGrace {
+		return service.DaemonReconnecting{
+			LastSeen: lastSeen,
+			Version:  config.Connection.Version,
+		}
+	}
+	// The daemon has been gone too long to be coming back, so the
+	// record of it being connected is out of date (e.g., because
+	// the service stopped without recording the disconnection)
+	s.config.UpdateConfig(instID, setDisconnectedIf(config.Connection.Last))
+	reconciledConnections.With("outcome", "expired").Add(1)
+	return err
 }
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/service"
+	"github.com/weaveworks/flux/service/instance"
+)
+
+const testInstance = service.InstanceID("test-instance")
+
+// mockDB keeps instance configs in memory.
+type mockDB struct {
+	sync.Mutex
+	configs map[service.InstanceID]instance.Config
+}
+
+func newMockDB() *mockDB {
+	return &mockDB{configs: map[service.InstanceID]instance.Config{}}
+}
+
+func (db *mockDB) UpdateConfig(inst service.InstanceID, update instance.UpdateFunc) error {
+	db.Lock()
+	defer db.Unlock()
+	config, err := update(db.configs[inst])
+	if err != nil {
+		return err
+	}
+	db.configs[inst] = config
+	return nil
+}
+
+func (db *mockDB) GetConfig(inst service.InstanceID) (instance.Config, error) {
+	db.Lock()
+	defer db.Unlock()
+	return db.configs[inst], nil
+}
+
+// mockBus answers pings with pingErr, and keeps hold of the channel
+// for ending a subscription.
+type mockBus struct {
+	sync.Mutex
+	pingErr error
+	pings   int
+	done    chan<- error
+}
+
+func (b *mockBus) Connect(inst service.InstanceID) (remote.Platform, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (b *mockBus) Subscribe(inst service.InstanceID, p remote.Platform, done chan<- error) {
+	b.Lock()
+	b.done = done
+	b.Unlock()
+}
+
+func (b *mockBus) Ping(inst service.InstanceID) error {
+	b.Lock()
+	defer b.Unlock()
+	b.pings++
+	return b.pingErr
+}
+
+func (b *mockBus) pinged() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.pings
+}
+
+func (b *mockBus) disconnect(err error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.done != nil {
+		b.done <- err
+	}
+}
+
+func newTestServer(db instance.DB, bus remote.MessageBus) *Server {
+	return New("test", &instance.MockInstancer{}, db, bus, service.FeatureRollout{}, log.NewNopLogger(), nil)
+}
+
+// register connects a daemon, answering with a channel that gets the
+// error RegisterDaemon returns.
+func register(s *Server) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.RegisterDaemon(testInstance, &remote.MockPlatform{VersionAnswer: "1.2.3"})
+	}()
+	return errc
+}
+
+func reconciled(t *testing.T, outcome string) float64 {
+	families, err := stdprometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "flux_fluxsvc_reconciled_connections_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "outcome" && l.GetValue() == outcome {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestRegisterDaemonGivesUpAfterMissedHeartbeats(t *testing.T) {
+	bus := &mockBus{pingErr: errors.New("timed out")}
+	db := newMockDB()
+	s := newTestServer(db, bus)
+	s.pingEvery = 10 * time.Millisecond
+
+	select {
+	case err := <-register(s):
+		if err != ErrHeartbeatsMissed {
+			t.Fatalf("expected ErrHeartbeatsMissed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the registration to be given up on")
+	}
+	if n := bus.pinged(); n < maxMissedHeartbeats {
+		t.Errorf("expected at least %d pings, got %d", maxMissedHeartbeats, n)
+	}
+	if config, _ := db.GetConfig(testInstance); config.Connection.Connected {
+		t.Error("expected the connection to be recorded as ended")
+	}
+}
+
+func TestHeartbeatsRecordedOnlyEverySoOften(t *testing.T) {
+	bus := &mockBus{}
+	db := newMockDB()
+	s := newTestServer(db, bus)
+	s.pingEvery = 10 * time.Millisecond
+
+	errc := register(s)
+	for bus.pinged() < 5 {
+		time.Sleep(s.pingEvery)
+	}
+	config, _ := db.GetConfig(testInstance)
+	if !config.Connection.Connected {
+		t.Fatal("expected the daemon to be recorded as connected")
+	}
+	// Well within heartbeatRecordInterval, so the heartbeat recorded
+	// is still the one from connecting
+	if !config.Connection.Heartbeat.Equal(config.Connection.Last) {
+		t.Errorf("expected heartbeat to be left at connection time %s, got %s", config.Connection.Last, config.Connection.Heartbeat)
+	}
+
+	bus.disconnect(nil)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetHeartbeatIf(t *testing.T) {
+	t0 := time.Now()
+	t1 := t0.Add(time.Minute)
+	config := instance.Config{}
+	config.Connection.Last, config.Connection.Heartbeat = t0, t0
+
+	// The heartbeat of another connection is ignored
+	got, _ := setHeartbeatIf(t0.Add(-time.Hour), t1)(config)
+	if !got.Connection.Heartbeat.Equal(t0) {
+		t.Errorf("expected heartbeat of another connection to be ignored, got %s", got.Connection.Heartbeat)
+	}
+	got, _ = setHeartbeatIf(t0, t1)(config)
+	if !got.Connection.Heartbeat.Equal(t1) {
+		t.Errorf("expected heartbeat %s, got %s", t1, got.Connection.Heartbeat)
+	}
+}
+
+func TestIsDaemonConnected(t *testing.T) {
+	bus := &mockBus{pingErr: errors.New("not connected")}
+	db := newMockDB()
+	s := newTestServer(db, bus)
+
+	// No record of a connection
+	if err := s.IsDaemonConnected(testInstance); err != bus.pingErr {
+		t.Errorf("expected %v, got %v", bus.pingErr, err)
+	}
+
+	// Seen recently, so probably reconnecting
+	now := time.Now()
+	lastSeen := now.Add(-time.Minute)
+	db.UpdateConfig(testInstance, func(config instance.Config) (instance.Config, error) {
+		config.Connection = instance.Connection{
+			Last:      now.Add(-time.Hour),
+			Connected: true,
+			Heartbeat: lastSeen,
+			Version:   "1.2.3",
+		}
+		return config, nil
+	})
+	err := s.IsDaemonConnected(testInstance)
+	if reconnecting, ok := err.(service.DaemonReconnecting); !ok {
+		t.Errorf("expected DaemonReconnecting, got %v", err)
+	} else if !reconnecting.LastSeen.Equal(lastSeen) || reconnecting.Version != "1.2.3" {
+		t.Errorf("expected last seen %s with version 1.2.3, got %+v", lastSeen, reconnecting)
+	}
+
+	// Not seen for longer than it takes to reconnect, so the record
+	// of the connection is out of date
+	expired := reconciled(t, "expired")
+	db.UpdateConfig(testInstance, func(config instance.Config) (instance.Config, error) {
+		config.Connection.Heartbeat = now.Add(-reconnectGrace - time.Second)
+		return config, nil
+	})
+	if err := s.IsDaemonConnected(testInstance); err != bus.pingErr {
+		t.Errorf("expected %v, got %v", bus.pingErr, err)
+	}
+	if config, _ := db.GetConfig(testInstance); config.Connection.Connected {
+		t.Error("expected the out of date connection to be cleared")
+	}
+	if got := reconciled(t, "expired"); got != expired+1 {
+		t.Errorf("expected expired connections to be counted, got %v (was %v)", got, expired)
+	}
+
+	// Connected
+	bus.pingErr = nil
+	if err := s.IsDaemonConnected(testInstance); err != nil {
+		t.Errorf("expected connected, got %v", err)
+	}
+}
+
+func TestRegisterDaemonReconcilesConnection(t *testing.T) {
+	bus := &mockBus{}
+	db := newMockDB()
+	s := newTestServer(db, bus)
+
+	// A connection recorded before the service started, which it
+	// didn't get to clear
+	db.UpdateConfig(testInstance, func(config instance.Config) (instance.Config, error) {
+		config.Connection.Last = s.started.Add(-time.Hour)
+		config.Connection.Connected = true
+		return config, nil
+	})
+	reconnected := reconciled(t, "reconnected")
+
+	errc := register(s)
+	for {
+		if config, _ := db.GetConfig(testInstance); config.Connection.Version == "1.2.3" {
+			if !config.Connection.Last.After(s.started) {
+				t.Errorf("expected the connection to be recorded afresh, got %s", config.Connection.Last)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := reconciled(t, "reconnected"); got != reconnected+1 {
+		t.Errorf("expected reconnection to be counted, got %v (was %v)", got, reconnected)
+	}
+
+	bus.disconnect(nil)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := db.GetConfig(testInstance); config.Connection.Connected {
+		t.Error("expected the connection to be recorded as ended")
+	}
+}
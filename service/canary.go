@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CanaryAnalysis is a set of checks, run as Prometheus queries while
+// a stage of a promotion soaks, that decide whether the promotion
+// goes on to the next stage, pauses, or rolls the stage back.
+type CanaryAnalysis struct {
+	// The base URL of the Prometheus API to query, e.g.,
+	// "http://prometheus.monitoring:9090". It has to be reachable
+	// from the service.
+	Prometheus string        `json:"prometheus"`
+	Checks     []CanaryCheck `json:"checks"`
+}
+
+// CanaryCheck is a query giving a single value, and the thresholds
+// the value has to stay within.
+type CanaryCheck struct {
+	Name  string   `json:"name"`
+	Query string   `json:"query"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	// What to do when the value is outside the thresholds; if
+	// empty, CanaryPause
+	OnFailure CanaryAction `json:"onFailure,omitempty"`
+}
+
+type CanaryAction string
+
+const (
+	// All checks passed, so the stage can finish once it has soaked
+	CanaryProceed CanaryAction = "proceed"
+	// The stage is held, soaked or not, until the checks pass
+	CanaryPause CanaryAction = "pause"
+	// The images the stage replaced are released again, and the
+	// promotion fails
+	CanaryRollback CanaryAction = "rollback"
+)
+
+// CanaryCheckResult is the outcome of one check. A check that couldn't
+// be run (e.g., because the query gave no data) hasn't passed, but
+// only ever pauses the promotion.
+type CanaryCheckResult struct {
+	Name   string   `json:"name"`
+	Value  *float64 `json:"value,omitempty"`
+	Passed bool     `json:"passed"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// CanaryResult is the outcome of running all the checks at once.
+type CanaryResult struct {
+	At     time.Time           `json:"at"`
+	Action CanaryAction        `json:"action"`
+	Checks []CanaryCheckResult `json:"checks"`
+}
+
+// Validate checks that there is a Prometheus to query, and that each
+// check has a query, at least one threshold, and a known action.
+func (a CanaryAnalysis) Validate() error {
+	u, err := url.Parse(a.Prometheus)
+	if err != nil {
+		return errors.Wrap(err, "parsing Prometheus URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("Prometheus URL must be an http or https URL, not %q", a.Prometheus)
+	}
+	if len(a.Checks) == 0 {
+		return errors.New("canary analysis has no checks")
+	}
+	for i, c := range a.Checks {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		switch {
+		case c.Query == "":
+			return errors.Errorf("check %s has no query", name)
+		case c.Min == nil && c.Max == nil:
+			return errors.Errorf("check %s has neither a min nor a max", name)
+		case c.Min != nil && c.Max != nil && *c.Min > *c.Max:
+			return errors.Errorf("check %s has a min greater than its max", name)
+		}
+		switch c.OnFailure {
+		case "", CanaryPause, CanaryRollback:
+		default:
+			return errors.Errorf("check %s: on failure, a promotion can %q or %q, not %q", name, CanaryPause, CanaryRollback, c.OnFailure)
+		}
+	}
+	return nil
+}
+
+// Result gives the outcome of the check, given the value of the query
+// or the error from running it.
+func (c CanaryCheck) Result(value float64, err error) CanaryCheckResult {
+	result := CanaryCheckResult{Name: c.Name}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Value = &value
+	result.Passed = (c.Min == nil || value >= *c.Min) && (c.Max == nil || value <= *c.Max)
+	return result
+}
+
+// Verdict gives what to do, given the result of each check (in the
+// same order as the checks): roll back if any check that says to has
+// failed, otherwise pause if any check hasn't passed, otherwise
+// proceed.
+func (a CanaryAnalysis) Verdict(results []CanaryCheckResult) CanaryAction {
+	action := CanaryProceed
+	for i, r := range results {
+		if r.Passed {
+			continue
+		}
+		if r.Error == "" && i < len(a.Checks) && a.Checks[i].OnFailure == CanaryRollback {
+			return CanaryRollback
+		}
+		action = CanaryPause
+	}
+	return action
+}
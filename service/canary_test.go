@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func canaryAnalysis() CanaryAnalysis {
+	return CanaryAnalysis{
+		Prometheus: "http://prometheus.monitoring:9090",
+		Checks: []CanaryCheck{
+			{Name: "error rate", Query: `sum(rate(errors[5m]))`, Max: float(0.01), OnFailure: CanaryRollback},
+			{Name: "throughput", Query: `sum(rate(requests[5m]))`, Min: float(10)},
+		},
+	}
+}
+
+func TestCanaryAnalysisValidate(t *testing.T) {
+	if err := canaryAnalysis().Validate(); err != nil {
+		t.Fatalf("expected analysis to be valid, got %v", err)
+	}
+
+	for name, change := range map[string]func(*CanaryAnalysis){
+		"no URL":         func(a *CanaryAnalysis) { a.Prometheus = "" },
+		"not http":       func(a *CanaryAnalysis) { a.Prometheus = "ftp://prometheus" },
+		"no checks":      func(a *CanaryAnalysis) { a.Checks = nil },
+		"no query":       func(a *CanaryAnalysis) { a.Checks[0].Query = "" },
+		"no thresholds":  func(a *CanaryAnalysis) { a.Checks[0].Max = nil },
+		"min over max":   func(a *CanaryAnalysis) { a.Checks[1].Max = float(5) },
+		"unknown action": func(a *CanaryAnalysis) { a.Checks[1].OnFailure = "panic" },
+		"proceed action": func(a *CanaryAnalysis) { a.Checks[1].OnFailure = CanaryProceed },
+	} {
+		analysis := canaryAnalysis()
+		change(&analysis)
+		if err := analysis.Validate(); err == nil {
+			t.Errorf("%s: expected analysis to be invalid", name)
+		}
+	}
+
+	spec := promotionSpec()
+	spec.Stages[0].Analysis = &CanaryAnalysis{}
+	if err := spec.Validate(); err == nil {
+		t.Error("expected spec with invalid analysis to be invalid")
+	}
+}
+
+func TestCanaryCheckResult(t *testing.T) {
+	check := CanaryCheck{Name: "latency", Min: float(1), Max: float(2)}
+	for _, c := range []struct {
+		value  float64
+		passed bool
+	}{
+		{0.5, false},
+		{1, true},
+		{1.5, true},
+		{2, true},
+		{2.5, false},
+	} {
+		r := check.Result(c.value, nil)
+		if r.Passed != c.passed || r.Value == nil || *r.Value != c.value {
+			t.Errorf("value %v: expected passed=%v, got %+v", c.value, c.passed, r)
+		}
+	}
+
+	r := check.Result(0, errors.New("no data"))
+	if r.Passed || r.Value != nil || r.Error != "no data" {
+		t.Errorf("expected error result, got %+v", r)
+	}
+}
+
+func TestCanaryAnalysisVerdict(t *testing.T) {
+	analysis := canaryAnalysis()
+	pass := CanaryCheckResult{Passed: true}
+	fail := CanaryCheckResult{}
+	broken := CanaryCheckResult{Error: "no data"}
+
+	for name, c := range map[string]struct {
+		results []CanaryCheckResult
+		action  CanaryAction
+	}{
+		"all passed":         {[]CanaryCheckResult{pass, pass}, CanaryProceed},
+		"pausing check":      {[]CanaryCheckResult{pass, fail}, CanaryPause},
+		"rollback check":     {[]CanaryCheckResult{fail, pass}, CanaryRollback},
+		"both failed":        {[]CanaryCheckResult{fail, fail}, CanaryRollback},
+		"rollback check err": {[]CanaryCheckResult{broken, pass}, CanaryPause},
+	} {
+		if action := analysis.Verdict(c.results); action != c.action {
+			t.Errorf("%s: expected %q, got %q", name, c.action, action)
+		}
+	}
+}
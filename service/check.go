@@ -0,0 +1,60 @@
+package service
+
+// CheckStatus is the outcome of a single step in a health check.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckFail CheckStatus = "fail"
+	CheckSkip CheckStatus = "skip"
+)
+
+// The names of the individual checks, in the order they're run.
+const (
+	CheckToken     = "token"
+	CheckConnected = "connected"
+	CheckVersion   = "version"
+	CheckGit       = "git"
+	CheckRegistry  = "registry"
+)
+
+type CheckResult struct {
+	Name   string      `json:"name" yaml:"name"`
+	Status CheckStatus `json:"status" yaml:"status"`
+	Detail string      `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Error  string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// CheckReport records the result of checking each link in the chain
+// from client to daemon, and from the daemon to the git repo and
+// image registries. Later links depend on earlier ones, so once a
+// check has failed, the remainder are skipped.
+type CheckReport struct {
+	Checks []CheckResult `json:"checks" yaml:"checks"`
+}
+
+// Run runs the check given, unless a previous check has failed, and
+// records the result. The check returns a detail for the report
+// (e.g., a version), or an error.
+func (r *CheckReport) Run(name string, check func() (string, error)) {
+	if !r.OK() {
+		r.Checks = append(r.Checks, CheckResult{Name: name, Status: CheckSkip})
+		return
+	}
+	detail, err := check()
+	if err != nil {
+		r.Checks = append(r.Checks, CheckResult{Name: name, Status: CheckFail, Detail: detail, Error: err.Error()})
+		return
+	}
+	r.Checks = append(r.Checks, CheckResult{Name: name, Status: CheckPass, Detail: detail})
+}
+
+// OK returns true if no check has failed.
+func (r CheckReport) OK() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFail {
+			return false
+		}
+	}
+	return true
+}
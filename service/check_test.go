@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReport_SkipsAfterFailure(t *testing.T) {
+	var report CheckReport
+	report.Run(CheckConnected, func() (string, error) {
+		return "", nil
+	})
+	report.Run(CheckVersion, func() (string, error) {
+		return "1.0.0", errors.New("too old")
+	})
+	report.Run(CheckGit, func() (string, error) {
+		t.Fatal("expected check after failure to be skipped")
+		return "", nil
+	})
+
+	if report.OK() {
+		t.Error("expected report to be not OK after a failed check")
+	}
+
+	expected := []CheckStatus{CheckPass, CheckFail, CheckSkip}
+	if len(report.Checks) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(report.Checks))
+	}
+	for i, status := range expected {
+		if report.Checks[i].Status != status {
+			t.Errorf("check %q: expected status %q, got %q", report.Checks[i].Name, status, report.Checks[i].Status)
+		}
+	}
+	if report.Checks[1].Detail != "1.0.0" || report.Checks[1].Error != "too old" {
+		t.Errorf("expected failed check to record detail and error, got %+v", report.Checks[1])
+	}
+}
@@ -1,21 +1,247 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/policy"
 )
 
 type NotifierConfig struct {
 	HookURL         string `json:"hookURL" yaml:"hookURL"`
 	Username        string `json:"username" yaml:"username"`
 	ReleaseTemplate string `json:"releaseTemplate" yaml:"releaseTemplate"`
+	// Channel, if set, is used in place of the channel the hook
+	// posts to by default
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	// Channels maps channel names, as given in the notify-channel
+	// annotation on a service, to the hook URL to use for them.
+	// Channels that aren't mentioned here get the default hook URL,
+	// told to post to the channel instead.
+	Channels map[string]string `json:"channels,omitempty" yaml:"channels,omitempty"`
 	// NotifyEvents should be a list of e.g. ["release", "sync"]. default, if
 	// unset, is ["release"].
 	// TODO Implement this.
 	NotifyEvents []string `json:"notifyEvents,omitempty" yaml:"notifyEvents,omitempty"`
+	// ReleaseNotes says whether to attach the notes for a release
+	// to its notification.
+	ReleaseNotes bool `json:"releaseNotes,omitempty" yaml:"releaseNotes,omitempty"`
+	// ReleaseNotesTemplate, if set, is used in place of the default
+	// template to render release notes.
+	ReleaseNotesTemplate string `json:"releaseNotesTemplate,omitempty" yaml:"releaseNotesTemplate,omitempty"`
 }
 
 type InstanceConfig struct {
 	Slack NotifierConfig `json:"slack" yaml:"slack"`
+	// Feature flags; these override whether a feature has been
+	// rolled out to the instance
+	Features map[string]bool `json:"features,omitempty" yaml:"features,omitempty"`
+	// Which instances may promote releases to this one
+	Promotions PromotionConfig `json:"promotions,omitempty" yaml:"promotions,omitempty"`
+	// Credentials for image registries, which the daemon uses in
+	// place of any it has for the same host
+	Registries []RegistryConfig `json:"registries,omitempty" yaml:"registries,omitempty"`
+	// How often the daemon pulls from the repo and syncs, given as
+	// e.g., "5m"; if empty, the daemon uses its --git-poll-interval
+	SyncInterval string `json:"syncInterval,omitempty" yaml:"syncInterval,omitempty"`
+	// Policies that apply to every service in a namespace; if given,
+	// these are used in place of the daemon's --namespace-policies
+	NamespacePolicies policy.NamespaceDefaults `json:"namespacePolicies,omitempty" yaml:"namespacePolicies,omitempty"`
+	// Kinds of resource (e.g., "Secret") that the daemon neither
+	// syncs nor exports, nor counts as drift; if given, these are
+	// used in place of the daemon's --k8s-exclude-kinds
+	ExcludeKinds []string `json:"excludeKinds,omitempty" yaml:"excludeKinds,omitempty"`
+}
+
+// DaemonConfig is the part of the instance config that the daemon
+// puts into effect. It's given to the daemon whenever it changes, and
+// the daemon reports the Version it has applied.
+type DaemonConfig struct {
+	Version           string                   `json:"version"`
+	SyncInterval      string                   `json:"syncInterval,omitempty"`
+	NamespacePolicies policy.NamespaceDefaults `json:"namespacePolicies,omitempty"`
+	Registries        []RegistryConfig         `json:"registries,omitempty"`
+	ExcludeKinds      []string                 `json:"excludeKinds,omitempty"`
+}
+
+// DaemonConfig gives the part of the config for the daemon. Its
+// version is derived from the contents, so that it changes only when
+// what the daemon is given changes; the version of an empty
+// DaemonConfig is empty.
+func (uic InstanceConfig) DaemonConfig() DaemonConfig {
+	c := DaemonConfig{
+		SyncInterval:      uic.SyncInterval,
+		NamespacePolicies: uic.NamespacePolicies,
+		Registries:        uic.Registries,
+		ExcludeKinds:      uic.ExcludeKinds,
+	}
+	if c.SyncInterval == "" && len(c.NamespacePolicies) == 0 && len(c.Registries) == 0 && len(c.ExcludeKinds) == 0 {
+		return c
+	}
+	// Maps are marshalled with their keys sorted, so this is stable
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		panic(err) // there's nothing in DaemonConfig that can't be marshalled
+	}
+	sum := sha256.Sum256(bytes)
+	c.Version = hex.EncodeToString(sum[:8])
+	return c
+}
+
+// RegistryConfig gives the credentials for an image registry. The
+// password isn't kept in the config; Secret refers to where the
+// daemon can find it (e.g., the name of a file in the directory given
+// to the daemon as --registry-secrets-dir).
+type RegistryConfig struct {
+	Host     string `json:"host" yaml:"host"`
+	Username string `json:"username" yaml:"username"`
+	Secret   string `json:"secret" yaml:"secret"`
+}
+
+// HiddenSecret stands in for a reference to a secret, in config given
+// to clients.
+const HiddenSecret = "<hidden>"
+
+// Validate checks that the sync interval (if given) is a positive
+// duration, that the namespace policies are valid, that each
+// registry is given a host (only once), a username, and a reference
+// to a secret, and that each kind excluded is a single word.
+func (uic InstanceConfig) Validate() error {
+	if uic.SyncInterval != "" {
+		interval, err := time.ParseDuration(uic.SyncInterval)
+		if err != nil {
+			return errors.Wrap(err, "sync interval")
+		}
+		if interval <= 0 {
+			return errors.Errorf("sync interval must be positive, not %s", uic.SyncInterval)
+		}
+	}
+	if err := uic.NamespacePolicies.Validate(); err != nil {
+		return errors.Wrap(err, "namespace policies")
+	}
+	hosts := map[string]bool{}
+	for _, r := range uic.Registries {
+		switch {
+		case r.Host == "" || strings.ContainsAny(r.Host, "/ "):
+			return errors.Errorf("registry host must be a hostname (and optionally port), not %q", r.Host)
+		case hosts[r.Host]:
+			return errors.Errorf("registry %s is given more than once", r.Host)
+		case r.Username == "":
+			return errors.Errorf("registry %s has no username", r.Host)
+		case r.Secret == "":
+			return errors.Errorf("registry %s has no secret", r.Host)
+		}
+		hosts[r.Host] = true
+	}
+	for _, kind := range uic.ExcludeKinds {
+		if kind == "" || strings.ContainsAny(kind, ", /") {
+			return errors.Errorf("excluded kind must be the name of a kind of resource (e.g., Secret), not %q", kind)
+		}
+	}
+	return nil
+}
+
+// HideSecrets gives the config with references to secrets replaced by
+// HiddenSecret. The secrets themselves aren't in the config, but
+// where they are kept is no business of clients.
+func (uic InstanceConfig) HideSecrets() InstanceConfig {
+	if uic.Registries == nil {
+		return uic
+	}
+	registries := make([]RegistryConfig, len(uic.Registries))
+	for i, r := range uic.Registries {
+		r.Secret = HiddenSecret
+		registries[i] = r
+	}
+	uic.Registries = registries
+	return uic
+}
+
+// RestoreSecrets gives the config with each HiddenSecret put back as
+// it is in prev, for the same registry host; so that config read
+// with the secrets hidden can be changed and written back, without
+// having to give the secrets again.
+func (uic InstanceConfig) RestoreSecrets(prev InstanceConfig) InstanceConfig {
+	if uic.Registries == nil {
+		return uic
+	}
+	secrets := map[string]string{}
+	for _, r := range prev.Registries {
+		secrets[r.Host] = r.Secret
+	}
+	registries := make([]RegistryConfig, len(uic.Registries))
+	for i, r := range uic.Registries {
+		if r.Secret == HiddenSecret {
+			r.Secret = secrets[r.Host]
+		}
+		registries[i] = r
+	}
+	uic.Registries = registries
+	return uic
+}
+
+// RecycledConfig is a version of the instance config that was
+// replaced, kept so that it can be restored if the replacement turns
+// out to be a mistake.
+type RecycledConfig struct {
+	Stamp  time.Time      `json:"stamp"`
+	Config InstanceConfig `json:"config"`
+}
+
+// RemovedSections reports the parts of the config that have a value
+// in uic, but not in next, given as e.g., "slack.hookURL". Only the
+// top two levels are looked at; below that, things are reported as
+// changed as a whole.
+func (uic InstanceConfig) RemovedSections(next InstanceConfig) ([]string, error) {
+	before, err := uic.toUntypedConfig()
+	if err != nil {
+		return nil, err
+	}
+	after, err := next.toUntypedConfig()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for key, value := range before {
+		if isEmptyValue(value) {
+			continue
+		}
+		if isEmptyValue(after[key]) {
+			removed = append(removed, key)
+			continue
+		}
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		nextSection, _ := after[key].(map[string]interface{})
+		for subkey, subvalue := range section {
+			if !isEmptyValue(subvalue) && isEmptyValue(nextSection[subkey]) {
+				removed = append(removed, strings.Join([]string{key, subkey}, "."))
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	}
+	return reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface())
 }
 
 type untypedConfig map[string]interface{}
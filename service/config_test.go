@@ -2,13 +2,16 @@ package service
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
+
+	"github.com/weaveworks/flux/policy"
 )
 
 func TestConfig_Patch(t *testing.T) {
 
 	uic := InstanceConfig{
-		NotifierConfig{
+		Slack: NotifierConfig{
 			HookURL: "existingurl",
 		},
 	}
@@ -33,3 +36,130 @@ func TestConfig_Patch(t *testing.T) {
 		t.Fatalf("slack hookURL not patched: %v", puic.Slack.HookURL)
 	}
 }
+
+func TestConfig_RemovedSections(t *testing.T) {
+	before := InstanceConfig{
+		Slack: NotifierConfig{
+			HookURL:  "https://hooks.slack.com/services/T0000/B0000/XXXX",
+			Username: "flux",
+		},
+		Features: map[string]bool{"migration": true},
+	}
+
+	removed, err := before.RemovedSections(InstanceConfig{Slack: NotifierConfig{Username: "flux"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"features", "slack.hookURL"}) {
+		t.Errorf("expected features and slack.hookURL to be reported removed, got %v", removed)
+	}
+
+	removed, err = before.RemovedSections(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("expected nothing reported removed, got %v", removed)
+	}
+}
+
+func TestConfig_Secrets(t *testing.T) {
+	uic := InstanceConfig{
+		Registries: []RegistryConfig{
+			{Host: "quay.io", Username: "robot", Secret: "quay-password"},
+		},
+	}
+	if err := uic.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := uic.HideSecrets()
+	if hidden.Registries[0].Secret != HiddenSecret {
+		t.Errorf("expected secret to be hidden, got %q", hidden.Registries[0].Secret)
+	}
+	if uic.Registries[0].Secret != "quay-password" {
+		t.Error("expected hiding secrets to leave the original config alone")
+	}
+
+	// Writing back what was read keeps the secret, and a new
+	// registry has to be given one
+	next := hidden
+	next.Registries = append(next.Registries, RegistryConfig{Host: "gcr.io", Username: "_json_key", Secret: HiddenSecret})
+	restored := next.RestoreSecrets(uic)
+	if restored.Registries[0].Secret != "quay-password" {
+		t.Errorf("expected secret to be restored, got %q", restored.Registries[0].Secret)
+	}
+	if err := restored.Validate(); err == nil {
+		t.Error("expected a registry without a secret not to validate")
+	}
+
+	for _, invalid := range []RegistryConfig{
+		{Host: "https://quay.io/v1", Username: "robot", Secret: "quay-password"},
+		{Host: "quay.io", Secret: "quay-password"},
+	} {
+		if err := (InstanceConfig{Registries: []RegistryConfig{invalid}}).Validate(); err == nil {
+			t.Errorf("expected %+v not to validate", invalid)
+		}
+	}
+	twice := InstanceConfig{Registries: append(uic.Registries, uic.Registries...)}
+	if err := twice.Validate(); err == nil {
+		t.Error("expected a registry given twice not to validate")
+	}
+}
+
+func TestConfig_DaemonConfig(t *testing.T) {
+	if v := (InstanceConfig{Features: map[string]bool{"migration": true}}).DaemonConfig().Version; v != "" {
+		t.Errorf("expected config with nothing for the daemon to have no version, got %q", v)
+	}
+
+	uic := InstanceConfig{
+		SyncInterval: "1m",
+		NamespacePolicies: policy.NamespaceDefaults{
+			"dev":     policy.Set{policy.Automated: "true"},
+			"staging": policy.Set{policy.Prune: "true"},
+		},
+	}
+	if err := uic.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	version := uic.DaemonConfig().Version
+	if version == "" {
+		t.Fatal("expected config for the daemon to have a version")
+	}
+	for i := 0; i < 10; i++ {
+		if v := uic.DaemonConfig().Version; v != version {
+			t.Fatalf("expected version to be stable, got %q then %q", version, v)
+		}
+	}
+
+	notifiers := uic
+	notifiers.Slack.HookURL = "https://hooks.slack.com/services/T0000/B0000/XXXX"
+	if v := notifiers.DaemonConfig().Version; v != version {
+		t.Errorf("expected version not to change with the rest of the config, got %q", v)
+	}
+	slower := uic
+	slower.SyncInterval = "10m"
+	if v := slower.DaemonConfig().Version; v == version {
+		t.Error("expected version to change with the sync interval")
+	}
+	excluding := uic
+	excluding.ExcludeKinds = []string{"Secret"}
+	if err := excluding.Validate(); err != nil {
+		t.Error(err)
+	}
+	if v := excluding.DaemonConfig().Version; v == version {
+		t.Error("expected version to change with the kinds excluded")
+	}
+
+	for _, invalid := range []InstanceConfig{
+		{SyncInterval: "often"},
+		{SyncInterval: "-1m"},
+		{NamespacePolicies: policy.NamespaceDefaults{"dev": policy.Set{policy.Automated: "yes"}}},
+		{ExcludeKinds: []string{""}},
+		{ExcludeKinds: []string{"Secret,ConfigMap"}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected %+v not to validate", invalid)
+		}
+	}
+}
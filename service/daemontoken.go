@@ -0,0 +1,34 @@
+package service
+
+import (
+	"time"
+)
+
+// DaemonToken is a credential issued to the daemon of an instance.
+// When the service checks daemon tokens, it accepts them only on the
+// routes daemons use (connecting, and reporting events), and accepts
+// nothing else there; so a daemon token can't be used to call the
+// API, nor a user's token to pose as the daemon.
+type DaemonToken struct {
+	Token   string    `json:"token"`
+	Created time.Time `json:"created"`
+}
+
+// DaemonTokensKept is the most daemon tokens an instance has at
+// once. Issuing a token leaves the one before it working, so the
+// daemon can be given the new token before the old one stops working.
+const DaemonTokensKept = 2
+
+const daemonTokenBytes = 32
+
+// NewDaemonToken makes a fresh, random daemon token.
+func NewDaemonToken() (DaemonToken, error) {
+	token, err := randomHex(daemonTokenBytes)
+	if err != nil {
+		return DaemonToken{}, err
+	}
+	return DaemonToken{
+		Token:   token,
+		Created: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+)
+
+// Features of the service that can be switched on and off per
+// instance.
+const (
+	// Reporting the images deployed at a point in time; this reads
+	// the whole history for a service, so could be expensive
+	FeatureDeployedImages = "deployed-images"
+	// Moving an instance to another service
+	FeatureMigration = "migration"
+	// Making the daemon simulate failures, to test alerting; this is
+	// only meant for staging instances
+	FeatureFaultInjection = "fault-injection"
+)
+
+// FeatureRollout says, for each feature, the percentage of instances
+// that have it switched on by default. Which instances those are is
+// decided by hashing the instance ID, so an instance gets the same
+// answer every time, and an instance that has a feature at some
+// percentage keeps it as the percentage goes up.
+type FeatureRollout map[string]int
+
+// ParseFeatureRollout reads a rollout as given on the command line,
+// with each entry either "name" (meaning all instances) or
+// "name=percentage".
+func ParseFeatureRollout(flags []string) (FeatureRollout, error) {
+	r := FeatureRollout{}
+	for _, flag := range flags {
+		name, percent := flag, 100
+		if i := strings.Index(flag, "="); i >= 0 {
+			var err error
+			name = flag[:i]
+			if percent, err = strconv.Atoi(flag[i+1:]); err != nil || percent < 0 || percent > 100 {
+				return nil, errors.Errorf("rollout for feature %q must be a percentage between 0 and 100", name)
+			}
+		}
+		if name == "" {
+			return nil, errors.Errorf("no feature name in %q", flag)
+		}
+		r[name] = percent
+	}
+	return r, nil
+}
+
+// Features gives the features switched on for an instance: those
+// rolled out to it, unless they're switched off in its config, and
+// any switched on in its config.
+func (r FeatureRollout) Features(inst InstanceID, config InstanceConfig) flux.Features {
+	f := flux.Features{}
+	for name, percent := range r {
+		if rolloutBucket(inst, name) < percent {
+			f[name] = true
+		}
+	}
+	for name, on := range config.Features {
+		f[name] = on
+	}
+	return f
+}
+
+// rolloutBucket puts the instance in one of a hundred buckets for the
+// feature given. Including the feature name means that it's not
+// always the same instances that get new features first.
+func rolloutBucket(inst InstanceID, feature string) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%s", feature, inst)
+	return int(h.Sum32() % 100)
+}
+
+// FeatureNotEnabled is returned when an instance tries to use a
+// feature that isn't switched on for it.
+func FeatureNotEnabled(name string) error {
+	return flux.UserConfigProblem{&flux.BaseError{
+		Help: fmt.Sprintf(`Feature %q is not enabled
+
+This feature is experimental, and not (yet) switched on for your
+instance. You can switch it on by setting it in the instance config,
+e.g., with
+
+    {"features": {%q: true}}
+
+as a patch to the config.
+`, name, name),
+		Err: errors.Errorf("feature %q not enabled", name),
+	}}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFeatureRollout(t *testing.T) {
+	rollout, err := ParseFeatureRollout([]string{"everywhere", "nowhere=0", "some=30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var some int
+	for i := 0; i < 1000; i++ {
+		inst := InstanceID(fmt.Sprintf("instance-%d", i))
+		f := rollout.Features(inst, InstanceConfig{})
+		if !f.Enabled("everywhere") || f.Enabled("nowhere") {
+			t.Fatalf("%s: expected 100%% and 0%% features to be on and off respectively, got %v", inst, f)
+		}
+		if f.Enabled("some") {
+			some++
+			// It must be the same answer every time
+			if !rollout.Features(inst, InstanceConfig{}).Enabled("some") {
+				t.Fatalf("%s: expected rollout to be deterministic", inst)
+			}
+		}
+	}
+	if some < 200 || some > 400 {
+		t.Errorf("expected about 30%% of instances to get feature, got %d in 1000", some)
+	}
+
+	// Config overrides the rollout, either way
+	f := rollout.Features("instance-0", InstanceConfig{
+		Features: map[string]bool{"everywhere": false, "nowhere": true, "unknown": true},
+	})
+	if f.Enabled("everywhere") || !f.Enabled("nowhere") || !f.Enabled("unknown") {
+		t.Errorf("expected config to override rollout, got %v", f)
+	}
+}
+
+func TestParseFeatureRollout_Invalid(t *testing.T) {
+	for _, flags := range [][]string{{"=50"}, {"thing=101"}, {"thing=lots"}} {
+		if _, err := ParseFeatureRollout(flags); err == nil {
+			t.Errorf("expected error parsing %v", flags)
+		}
+	}
+}
@@ -1,19 +1,82 @@
 package instance
 
 import (
+	"reflect"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/service"
 )
 
+// How many replaced versions of the settings are kept, to be
+// restored if need be.
+const RecycleBinSize = 5
+
 type Connection struct {
 	Last      time.Time `json:"last"`
 	Connected bool      `json:"connected"`
+	// The last time the connected daemon answered a ping
+	Heartbeat time.Time `json:"heartbeat,omitempty"`
+	// The version the daemon gave when it connected
+	Version string `json:"version,omitempty"`
 }
 
 type Config struct {
 	Settings   service.InstanceConfig `json:"settings"`
 	Connection Connection             `json:"connection"`
+	// Kept out of Settings, since those are shown to users
+	WebhookSecrets map[string]service.WebhookSecret `json:"webhookSecrets,omitempty"`
+	// The tokens the daemon may present, newest first
+	DaemonTokens []service.DaemonToken `json:"daemonTokens,omitempty"`
+	// The base URL of the service the instance has been migrated
+	// to, if it has been; daemons connecting here are sent there.
+	MigratedTo string `json:"migratedTo,omitempty"`
+	// Settings that have been replaced, most recent first
+	Recycled []service.RecycledConfig `json:"recycled,omitempty"`
+	// Promotions started from this instance, oldest first
+	Promotions []service.Promotion `json:"promotions,omitempty"`
+}
+
+// WithSettings gives the config with its settings replaced by those
+// given. What was replaced goes in the recycle bin, unless it's the
+// same as the replacement (or empty).
+func (c Config) WithSettings(settings service.InstanceConfig, now time.Time) Config {
+	if !reflect.DeepEqual(c.Settings, settings) && !reflect.DeepEqual(c.Settings, service.InstanceConfig{}) {
+		recycled := service.RecycledConfig{Stamp: now, Config: c.Settings}
+		c.Recycled = append([]service.RecycledConfig{recycled}, c.Recycled...)
+		if len(c.Recycled) > RecycleBinSize {
+			c.Recycled = c.Recycled[:RecycleBinSize]
+		}
+	}
+	c.Settings = settings
+	return c
+}
+
+// RestoreSettings brings back the settings at the index given in the
+// recycle bin. The settings they replace are themselves recycled, so
+// a restore can be undone in the same way.
+func (c Config) RestoreSettings(index int, now time.Time) (Config, error) {
+	if index < 0 || index >= len(c.Recycled) {
+		return c, ErrNoRecycledConfig(index)
+	}
+	restore := c.Recycled[index].Config
+	c.Recycled = append(append([]service.RecycledConfig{}, c.Recycled[:index]...), c.Recycled[index+1:]...)
+	return c.WithSettings(restore, now), nil
+}
+
+func ErrNoRecycledConfig(index int) error {
+	return flux.Missing{&flux.BaseError{
+		Help: `No such config in the recycle bin
+
+There is no config at the position given in the recycle bin. The
+configs in the recycle bin can be listed with the API, with GET on
+/v6/config/recycled; the most recently replaced config is at
+position 0.
+`,
+		Err: errors.Errorf("no recycled config at index %d", index),
+	}}
 }
 
 type UpdateFunc func(config Config) (Config, error)
@@ -0,0 +1,50 @@
+package instance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/service"
+)
+
+func configWithHook(hook string) service.InstanceConfig {
+	return service.InstanceConfig{Slack: service.NotifierConfig{HookURL: hook}}
+}
+
+func TestConfigRecycling(t *testing.T) {
+	now := time.Now().UTC()
+	var c Config
+	for i := 0; i < RecycleBinSize+2; i++ {
+		c = c.WithSettings(configWithHook(fmt.Sprintf("hook-%d", i)), now)
+	}
+	if len(c.Recycled) != RecycleBinSize {
+		t.Fatalf("expected recycle bin to be limited to %d, got %d", RecycleBinSize, len(c.Recycled))
+	}
+	if hook := c.Recycled[0].Config.Slack.HookURL; hook != "hook-5" {
+		t.Errorf("expected most recently replaced config first, got %q", hook)
+	}
+
+	// Setting the same thing again shouldn't recycle anything
+	same := c.WithSettings(c.Settings, now)
+	if len(same.Recycled) != RecycleBinSize || same.Recycled[0].Config.Slack.HookURL != "hook-5" {
+		t.Errorf("expected unchanged settings not to be recycled")
+	}
+
+	restored, err := c.RestoreSettings(1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Settings.Slack.HookURL != "hook-4" {
+		t.Errorf("expected restored settings, got %q", restored.Settings.Slack.HookURL)
+	}
+	if restored.Recycled[0].Config.Slack.HookURL != "hook-6" {
+		t.Errorf("expected the settings replaced by the restore to be recycled, got %q", restored.Recycled[0].Config.Slack.HookURL)
+	}
+
+	_, err = c.RestoreSettings(RecycleBinSize, now)
+	if _, ok := err.(flux.Missing); !ok {
+		t.Errorf("expected missing error for index out of range, got %v", err)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
 	"github.com/weaveworks/flux/service"
 )
@@ -16,20 +14,18 @@ const (
 )
 
 var (
-	releaseHelperDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	releaseHelperDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "fluxsvc",
 		Name:      "release_helper_duration_seconds",
 		Help:      "Duration in seconds of a variety of release helper methods.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess})
-	requestDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+	}, fluxmetrics.LabelMethod, fluxmetrics.LabelSuccess)
+	requestDuration = fluxmetrics.NewHistogram(fluxmetrics.Opts{
 		Namespace: "flux",
 		Subsystem: "instance",
 		Name:      "request_duration_seconds",
 		Help:      "Request duration in seconds.",
-		Buckets:   stdprometheus.DefBuckets,
-	}, []string{LabelMethod, LabelSuccess})
+	}, LabelMethod, LabelSuccess)
 )
 
 type instrumentedDB struct {
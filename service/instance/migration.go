@@ -0,0 +1,17 @@
+package instance
+
+import (
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/service"
+)
+
+// Migration is everything the service knows about an instance, so
+// that it can be moved to another service.
+type Migration struct {
+	// The ID the instance had in the service it was exported
+	// from. It may be known by a different ID where it's imported.
+	ID     service.InstanceID `json:"id"`
+	Config Config             `json:"config"`
+	// In descending timestamp order, as for history.EventReader
+	Events []history.Event `json:"events"`
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/guid"
+	"github.com/weaveworks/flux/update"
+)
+
+// PromotionSpec says what to release, and to which instances in
+// turn: e.g., to staging, then, once it has rolled out there and
+// soaked for an hour, to production.
+type PromotionSpec struct {
+	Release update.ReleaseSpec `json:"release"`
+	Stages  []PromotionStage   `json:"stages"`
+}
+
+// PromotionStage is an instance to release to, and how long the
+// release must stay healthy there before going on to the next stage.
+type PromotionStage struct {
+	// The instance to release to; if empty, the instance the
+	// promotion was started from.
+	Instance InstanceID `json:"instance,omitempty"`
+	// How long to wait after the release has rolled out, e.g.,
+	// "1h". Not needed for the last stage.
+	Soak string `json:"soak,omitempty"`
+	// Checks to run while soaking, which can hold the stage or roll
+	// it back
+	Analysis *CanaryAnalysis `json:"analysis,omitempty"`
+}
+
+// SoakDuration gives the soak time of the stage, which is zero if
+// none was given.
+func (s PromotionStage) SoakDuration() (time.Duration, error) {
+	if s.Soak == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s.Soak)
+}
+
+// Validate checks that the promotion can be carried out the same way
+// at each stage; that is, that it releases a particular image (rather
+// than whatever's latest), for real.
+func (s PromotionSpec) Validate() error {
+	if len(s.Stages) < 2 {
+		return errors.New("a promotion needs at least two stages")
+	}
+	if s.Release.ImageSpec == update.ImageSpecLatest || s.Release.ImageSpec == "" {
+		return errors.New("a promotion must release a particular image")
+	}
+	if _, err := s.Release.ImageSpec.AsID(); err != nil {
+		return errors.Wrap(err, "parsing image")
+	}
+	if s.Release.Kind != update.ReleaseKindExecute {
+		return errors.Errorf("a promotion must be a release of kind %q", update.ReleaseKindExecute)
+	}
+	if len(s.Release.ServiceSpecs) == 0 {
+		return errors.New("a promotion must release to at least one service")
+	}
+	for i, stage := range s.Stages {
+		d, err := stage.SoakDuration()
+		if err != nil {
+			return errors.Wrapf(err, "stage %d", i+1)
+		}
+		if d < 0 {
+			return errors.Errorf("stage %d: soak time must not be negative", i+1)
+		}
+		if stage.Analysis != nil {
+			if err := stage.Analysis.Validate(); err != nil {
+				return errors.Wrapf(err, "stage %d", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+type PromotionState string
+
+const (
+	// The release for the stage is yet to be made, or is being made
+	PromotionReleasing PromotionState = "releasing"
+	// The release has been committed, and is waiting to be running
+	PromotionRollingOut PromotionState = "rolling-out"
+	// The release is running, and has to stay healthy until the soak
+	// time is up
+	PromotionSoaking PromotionState = "soaking"
+	// The release is running, but canary analysis has held the stage
+	// until its checks pass
+	PromotionPaused PromotionState = "paused"
+	// Canary analysis has failed, and the images the stage replaced
+	// are being released again
+	PromotionRollingBack PromotionState = "rolling-back"
+	PromotionSucceeded   PromotionState = "succeeded"
+	PromotionFailed      PromotionState = "failed"
+	PromotionCancelled   PromotionState = "cancelled"
+)
+
+// Terminal is true of the states a promotion doesn't leave.
+func (s PromotionState) Terminal() bool {
+	return s == PromotionSucceeded || s == PromotionFailed || s == PromotionCancelled
+}
+
+// Promotion is a promotion underway (or finished), as kept with the
+// config of the instance it was started from.
+type Promotion struct {
+	ID    string         `json:"id"`
+	Spec  PromotionSpec  `json:"spec"`
+	Cause update.Cause   `json:"cause"`
+	State PromotionState `json:"state"`
+	// The index of the stage the promotion is at
+	Stage int `json:"stage"`
+	// When the current stage was started
+	StageStarted time.Time `json:"stageStarted"`
+	// The release job for the current stage, once it's been
+	// submitted, and the revision it committed, once it has
+	JobID    string `json:"jobID,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// The services the current stage's release changed
+	Result update.Result `json:"result,omitempty"`
+	// When soaking, the time the stage will be done
+	SoakUntil time.Time `json:"soakUntil,omitempty"`
+	// The last canary analysis of the current stage, if it has one
+	Analysis *CanaryResult `json:"analysis,omitempty"`
+	// When rolling back, the job releasing the replaced images again
+	RollbackJobID string `json:"rollbackJobID,omitempty"`
+	// Why the promotion failed, or the last thing to go wrong with a
+	// step that will be retried
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+}
+
+// NewPromotion makes a promotion ready to start at the first stage.
+// The stage instances are filled in, so that "this instance" means
+// the one it was started from.
+func NewPromotion(inst InstanceID, spec PromotionSpec, cause update.Cause, now time.Time) Promotion {
+	var stages []PromotionStage
+	for _, stage := range spec.Stages {
+		if stage.Instance == "" {
+			stage.Instance = inst
+		}
+		stages = append(stages, stage)
+	}
+	spec.Stages = stages
+	return Promotion{
+		ID:           guid.New(),
+		Spec:         spec,
+		Cause:        cause,
+		State:        PromotionReleasing,
+		StageStarted: now,
+		Started:      now,
+		Updated:      now,
+	}
+}
+
+// CurrentStage gives the stage the promotion is at.
+func (p Promotion) CurrentStage() PromotionStage {
+	return p.Spec.Stages[p.Stage]
+}
+
+// PromotionConfig is an instance's settings for promotions.
+type PromotionConfig struct {
+	// The instances that may promote releases to this one
+	AcceptFrom []InstanceID `json:"acceptFrom,omitempty" yaml:"acceptFrom,omitempty"`
+}
+
+// Accepts says whether an instance may promote releases to the
+// instance with this config.
+func (c PromotionConfig) Accepts(from InstanceID) bool {
+	for _, inst := range c.AcceptFrom {
+		if inst == from {
+			return true
+		}
+	}
+	return false
+}
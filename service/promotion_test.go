@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux/update"
+)
+
+func promotionSpec() PromotionSpec {
+	return PromotionSpec{
+		Release: update.ReleaseSpec{
+			ServiceSpecs: []update.ServiceSpec{"default/helloworld"},
+			ImageSpec:    "quay.io/weaveworks/helloworld:v2",
+			Kind:         update.ReleaseKindExecute,
+		},
+		Stages: []PromotionStage{
+			{Soak: "1h"},
+			{Instance: "prod"},
+		},
+	}
+}
+
+func TestPromotionSpecValidate(t *testing.T) {
+	if err := promotionSpec().Validate(); err != nil {
+		t.Fatalf("expected spec to be valid, got %v", err)
+	}
+
+	for name, change := range map[string]func(*PromotionSpec){
+		"one stage":     func(s *PromotionSpec) { s.Stages = s.Stages[:1] },
+		"latest images": func(s *PromotionSpec) { s.Release.ImageSpec = update.ImageSpecLatest },
+		"plan":          func(s *PromotionSpec) { s.Release.Kind = update.ReleaseKindPlan },
+		"no services":   func(s *PromotionSpec) { s.Release.ServiceSpecs = nil },
+		"bad soak":      func(s *PromotionSpec) { s.Stages[0].Soak = "a while" },
+		"negative soak": func(s *PromotionSpec) { s.Stages[0].Soak = "-1h" },
+	} {
+		spec := promotionSpec()
+		change(&spec)
+		if err := spec.Validate(); err == nil {
+			t.Errorf("%s: expected spec to be invalid", name)
+		}
+	}
+}
+
+func TestNewPromotion(t *testing.T) {
+	now := time.Now()
+	p := NewPromotion("staging", promotionSpec(), update.Cause{User: "alice"}, now)
+	if p.ID == "" || p.State != PromotionReleasing || p.Stage != 0 || !p.StageStarted.Equal(now) {
+		t.Errorf("expected promotion ready to start, got %+v", p)
+	}
+	if inst := p.CurrentStage().Instance; inst != "staging" {
+		t.Errorf("expected first stage to be the instance it was started from, got %q", inst)
+	}
+	if inst := p.Spec.Stages[1].Instance; inst != "prod" {
+		t.Errorf("expected second stage to be left as given, got %q", inst)
+	}
+}
+
+func TestPromotionConfigAccepts(t *testing.T) {
+	c := PromotionConfig{AcceptFrom: []InstanceID{"staging"}}
+	if !c.Accepts("staging") {
+		t.Error("expected promotions from staging to be accepted")
+	}
+	if c.Accepts("dev") {
+		t.Error("expected promotions from dev not to be accepted")
+	}
+}
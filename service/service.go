@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/weaveworks/flux"
@@ -12,11 +13,21 @@ const NoInstanceID InstanceID = "<no InstanceID>"
 
 const InstanceIDHeaderKey = "X-Scope-OrgID"
 
+// The headers from which the ID of a request, and the user making it,
+// are taken (if they're there), to be passed along to the daemon. An
+// Authenticator, or a proxy in front of the service, can set the user.
+const (
+	RequestIDHeaderKey = "X-Request-Id"
+	UserHeaderKey      = "X-Flux-User"
+)
+
 // TODO: How similar should this be to the `get-config` result?
 type Status struct {
 	Fluxsvc FluxsvcStatus `json:"fluxsvc" yaml:"fluxsvc"`
 	Fluxd   FluxdStatus   `json:"fluxd" yaml:"fluxd"`
 	Git     GitStatus     `json:"git" yaml:"git"`
+	// The service features switched on for the instance
+	Features []string `json:"features,omitempty" yaml:"features,omitempty"`
 }
 
 type FluxsvcStatus struct {
@@ -26,11 +37,50 @@ type FluxsvcStatus struct {
 type FluxdStatus struct {
 	Connected bool      `json:"connected" yaml:"connected"`
 	Last      time.Time `json:"last,omitempty" yaml:"last,omitempty"`
-	Version   string    `json:"version,omitempty" yaml:"version,omitempty"`
+	// The last time the daemon was recorded as alive, while connected
+	// (this is recorded every couple of minutes, not at every ping)
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitempty" yaml:"lastHeartbeat,omitempty"`
+	Version       string    `json:"version,omitempty" yaml:"version,omitempty"`
+	// Kinds of resource that fluxd leaves alone
+	ExcludedKinds []string `json:"excludedKinds,omitempty" yaml:"excludedKinds,omitempty"`
+	// Features switched on in fluxd
+	Features []string `json:"features,omitempty" yaml:"features,omitempty"`
+	// The version of the instance config fluxd should have applied,
+	// the version it reports having applied, and why it couldn't
+	// apply anything given since
+	ConfigVersion        string `json:"configVersion,omitempty" yaml:"configVersion,omitempty"`
+	AppliedConfigVersion string `json:"appliedConfigVersion,omitempty" yaml:"appliedConfigVersion,omitempty"`
+	ConfigError          string `json:"configError,omitempty" yaml:"configError,omitempty"`
+	// Not connected, but seen recently enough that it's probably on
+	// its way back (e.g., after the service has restarted)
+	Reconnecting bool `json:"reconnecting,omitempty" yaml:"reconnecting,omitempty"`
+}
+
+// DaemonReconnecting is given in answer to whether a daemon is
+// connected, when it isn't, but was seen so recently that it's
+// likely to be reconnecting.
+type DaemonReconnecting struct {
+	LastSeen time.Time
+	Version  string
+}
+
+func (e DaemonReconnecting) Error() string {
+	return fmt.Sprintf("daemon is not connected, but was last seen at %s so is probably reconnecting", e.LastSeen.Format(time.RFC3339))
 }
 
 type GitStatus struct {
 	Configured bool           `json:"configured" yaml:"configured"`
 	Error      string         `json:"error,omitempty" yaml:"error,omitempty"`
 	Config     flux.GitConfig `json:"config"`
+	// Diverged is set when the daemon has seen the branch rewritten
+	// upstream, and won't commit or sync until it's reset.
+	Diverged *flux.GitDivergence `json:"diverged,omitempty" yaml:"diverged,omitempty"`
+}
+
+// MigrationTarget says where to migrate an instance to: the base URL
+// of the service's API (which must be one the service is allowed to
+// migrate to), and the token for the admin API there.
+type MigrationTarget struct {
+	URL   string     `json:"url"`
+	Token flux.Token `json:"token"`
 }
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// WebhookSecret is the shared secret used to validate requests to a
+// webhook receiver (e.g., for git pushes). The secret itself is only
+// given out when it's created; after that, only the fact of it.
+type WebhookSecret struct {
+	Hook    string    `json:"hook"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+const webhookSecretBytes = 32
+
+// NewWebhookSecret makes a fresh, random secret for the hook named.
+func NewWebhookSecret(hook string) (WebhookSecret, error) {
+	secret, err := randomHex(webhookSecretBytes)
+	if err != nil {
+		return WebhookSecret{}, err
+	}
+	return WebhookSecret{
+		Hook:    hook,
+		Secret:  secret,
+		Created: time.Now().UTC(),
+	}, nil
+}
+
+// randomHex gives the number of random bytes asked for, in hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Redacted gives the secret without the secret part, for listing.
+func (s WebhookSecret) Redacted() WebhookSecret {
+	s.Secret = ""
+	return s
+}
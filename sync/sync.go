@@ -1,63 +1,238 @@
 package sync
 
 import (
+	"sort"
+
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/history"
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 )
 
-// Synchronise the cluster to the files in a directory
-func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus cluster.Cluster, deletes bool, logger log.Logger) error {
+// Pruning says which resources that are in the cluster, but have
+// gone from the repo, are deleted when syncing. Only resources marked
+// as applied by flux are ever deleted. With All, every such resource
+// is; otherwise, only those in namespaces with the prune policy,
+// either in their defaults or as an annotation on the namespace
+// itself. Resources with the ignore or protected policy are never
+// deleted. With DryRun, what would be deleted is reported, but left
+// alone.
+//
+// With PathScoped, only some of the files in the repo are looked at
+// (e.g., with --git-include); since a resource missing from those may
+// well be defined in another, nothing is deleted.
+type Pruning struct {
+	All        bool
+	Defaults   *policy.SharedDefaults
+	DryRun     bool
+	PathScoped bool
+}
+
+// Result is what a sync did, besides applying the resources in the
+// repo. Rollouts are the resources with the rollout-on-config-change
+// policy whose config changed; Pruned are the resources deleted
+// because they've gone from the repo (or, with a dry run, those that
+// would have been). Drifted are the resources flux applied that have
+// gone from the repo, but are left in the cluster, e.g., because
+// their namespace isn't pruned; so the cluster has drifted from what
+// the repo says. Kinds excluded from the cluster's export are never
+// counted as drift.
+type Result struct {
+	Rollouts []history.ConfigRollout
+	Pruned   []string
+	Drifted  []string
+}
+
+// Synchronise the cluster to the files in a directory, deleting
+// what's gone from them as prune says. Resources with the
+// rollout-on-config-change policy are given a checksum of the config
+// they refer to; those for which it changed are returned, so that the
+// pods being replaced can be reported.
+func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus cluster.Cluster, prune Pruning, logger log.Logger) (Result, error) {
 	// Get a map of resources defined in the cluster
 	clusterBytes, err := clus.Export()
 	if err != nil {
-		return errors.Wrap(err, "exporting resource defs from cluster")
+		return Result{}, errors.Wrap(err, "exporting resource defs from cluster")
 	}
 	clusterResources, err := m.ParseManifests(clusterBytes)
 	if err != nil {
-		return errors.Wrap(err, "parsing exported resources")
+		return Result{}, errors.Wrap(err, "parsing exported resources")
 	}
 
-	// Everything that's in the cluster but not in the repo, delete;
-	// everything that's in the repo, apply. This is an approximation
-	// to figuring out what's changed, and applying that. We're
-	// relying on Kubernetes to decide for each application if it is a
-	// no-op.
+	// Everything that's in the cluster but not in the repo, delete
+	// (where it's to be pruned); everything that's in the repo,
+	// apply. This is an approximation to figuring out what's changed,
+	// and applying that. We're relying on Kubernetes to decide for
+	// each application if it is a no-op.
 	var sync cluster.SyncDef
 
-	if deletes {
-		for id, res := range clusterResources {
-			if res.Policy().Contains(policy.Ignore) {
-				logger.Log("resource", res.ResourceID(), "ignore", "delete")
-				continue
-			}
-			if _, ok := repoResources[id]; !ok {
-				sync.Actions = append(sync.Actions, cluster.SyncAction{
-					ResourceID: id,
-					Delete:     res.Bytes(),
-				})
-			}
+	var pruned []string
+	for _, id := range prune.prunable(m, repoResources, clusterResources, logger) {
+		pruned = append(pruned, id)
+		if prune.DryRun {
+			logger.Log("resource", id, "prune", "dry-run")
+			continue
 		}
+		sync.Actions = append(sync.Actions, cluster.SyncAction{
+			ResourceID: id,
+			Delete:     clusterResources[id].Bytes(),
+		})
 	}
 
+	drifted := prune.drifted(m, repoResources, clusterResources, pruned)
+	for _, id := range drifted {
+		logger.Log("resource", id, "drift", "not in repo")
+	}
+
+	var rollouts []history.ConfigRollout
 	for id, res := range repoResources {
 		if res.Policy().Contains(policy.Ignore) {
 			logger.Log("resource", res.ResourceID(), "ignore", "apply")
 			continue
 		}
-		if cres, ok := clusterResources[id]; ok {
-			if cres.Policy().Contains(policy.Ignore) {
-				logger.Log("resource", res.ResourceID(), "ignore", "apply")
-				continue
+		cres, inCluster := clusterResources[id]
+		if inCluster && cres.Policy().Contains(policy.Ignore) {
+			logger.Log("resource", res.ResourceID(), "ignore", "apply")
+			continue
+		}
+
+		def := res.Bytes()
+		if res.Policy().Contains(policy.RolloutOnConfigChange) {
+			withChecksum, checksum, err := m.WithConfigChecksum(res, repoResources)
+			switch {
+			case err != nil:
+				// Better to apply it without than not at all
+				logger.Log("resource", res.ResourceID(), "err", errors.Wrap(err, "adding config checksum"))
+			case checksum != "":
+				def = withChecksum
+				if inCluster {
+					if old := m.AppliedConfigChecksum(cres); old != checksum {
+						rollouts = append(rollouts, history.ConfigRollout{ResourceID: id, Old: old, New: checksum})
+					}
+				}
 			}
 		}
+		if marked, err := m.MarkApplied(def); err != nil {
+			// Without the mark it won't ever be pruned, which
+			// is better than not applying it
+			logger.Log("resource", res.ResourceID(), "err", errors.Wrap(err, "marking as applied"))
+		} else {
+			def = marked
+		}
 		sync.Actions = append(sync.Actions, cluster.SyncAction{
 			ResourceID: id,
-			Apply:      res.Bytes(),
+			Apply:      def,
 		})
 	}
-	return clus.Sync(sync)
+
+	err = clus.Sync(sync)
+	if syncErr, ok := err.(cluster.SyncError); ok {
+		// Those that failed to apply won't have been rolled out,
+		// and those that failed to delete are still there
+		var applied []history.ConfigRollout
+		for _, r := range rollouts {
+			if _, failed := syncErr[r.ResourceID]; !failed {
+				applied = append(applied, r)
+			}
+		}
+		rollouts = applied
+		if !prune.DryRun {
+			var deleted []string
+			for _, id := range pruned {
+				if _, failed := syncErr[id]; !failed {
+					deleted = append(deleted, id)
+				}
+			}
+			pruned = deleted
+		}
+	} else if err != nil {
+		rollouts = nil
+		if !prune.DryRun {
+			pruned = nil
+		}
+	}
+	return Result{Rollouts: rollouts, Pruned: pruned, Drifted: drifted}, err
+}
+
+// prunable gives the IDs of the resources in the cluster that are to
+// be deleted because they've gone from the repo, in order.
+func (p Pruning) prunable(m cluster.Manifests, repoResources, clusterResources map[string]resource.Resource, logger log.Logger) []string {
+	// An empty repo is much more likely to be a mistake (e.g., the
+	// wrong path) than a wish to delete everything
+	if len(repoResources) == 0 || p.PathScoped {
+		return nil
+	}
+	var ids []string
+	for id, res := range clusterResources {
+		if _, ok := repoResources[id]; ok {
+			continue
+		}
+		// Whatever flux didn't apply isn't flux's to delete
+		if !m.IsMarkedApplied(res) {
+			continue
+		}
+		if !p.All {
+			kind, namespace, _ := resource.SplitID(id)
+			if namespace == "" || kind == "Namespace" || !p.namespacePruned(namespace, repoResources, clusterResources) {
+				continue
+			}
+		}
+		if res.Policy().Contains(policy.Ignore) {
+			logger.Log("resource", id, "ignore", "delete")
+			continue
+		}
+		if res.Policy().Contains(policy.Protected) {
+			logger.Log("resource", id, "protected", "delete")
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// drifted gives the IDs of the resources in the cluster that flux
+// applied, and that have gone from the repo, but aren't among those
+// pruned, in order. As with pruning, when the repo is empty or only
+// some of it is looked at, it can't be told what's gone from it.
+func (p Pruning) drifted(m cluster.Manifests, repoResources, clusterResources map[string]resource.Resource, pruned []string) []string {
+	if len(repoResources) == 0 || p.PathScoped {
+		return nil
+	}
+	isPruned := map[string]bool{}
+	for _, id := range pruned {
+		isPruned[id] = true
+	}
+	var ids []string
+	for id, res := range clusterResources {
+		if _, ok := repoResources[id]; ok || isPruned[id] {
+			continue
+		}
+		if !m.IsMarkedApplied(res) || res.Policy().Contains(policy.Ignore) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// namespacePruned says whether the namespace given has the prune
+// policy; either in its defaults, or on the namespace as it's defined
+// in the repo or, failing that, as it is in the cluster.
+func (p Pruning) namespacePruned(namespace string, repoResources, clusterResources map[string]resource.Resource) bool {
+	if p.Defaults.Get()[namespace].Contains(policy.Prune) {
+		return true
+	}
+	id := "Namespace " + namespace
+	if ns, ok := repoResources[id]; ok {
+		return ns.Policy().Contains(policy.Prune)
+	}
+	if ns, ok := clusterResources[id]; ok {
+		return ns.Policy().Contains(policy.Prune)
+	}
+	return false
 }
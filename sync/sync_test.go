@@ -18,6 +18,7 @@ import (
 	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/git/gittest"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 )
 
@@ -38,7 +39,7 @@ func TestSync(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := Sync(manifests, resources, clus, true, log.NewNopLogger()); err != nil {
+	if _, err := Sync(manifests, resources, clus, Pruning{All: true}, log.NewNopLogger()); err != nil {
 		t.Fatal(err)
 	}
 	checkClusterMatchesFiles(t, manifests, clus, checkout.ManifestDir())
@@ -57,12 +58,157 @@ func TestSync(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := Sync(manifests, resources, clus, true, log.NewNopLogger()); err != nil {
+	if _, err := Sync(manifests, resources, clus, Pruning{All: true}, log.NewNopLogger()); err != nil {
 		t.Fatal(err)
 	}
 	checkClusterMatchesFiles(t, manifests, clus, checkout.ManifestDir())
 }
 
+func TestSyncPrune(t *testing.T) {
+	manifests := &kubernetes.Manifests{}
+	def := func(kind, namespace, name string, annotations ...string) []byte {
+		meta := "  name: " + name + "\n"
+		if namespace != "" {
+			meta += "  namespace: " + namespace + "\n"
+		}
+		if len(annotations) > 0 {
+			meta += "  annotations:\n"
+			for _, a := range annotations {
+				meta += "    flux.weave.works/" + a + ": \"true\"\n"
+			}
+		}
+		return []byte("apiVersion: v1\nkind: " + kind + "\nmetadata:\n" + meta)
+	}
+	inRepo := map[string][]byte{
+		"Namespace staging":        def("Namespace", "", "staging", "prune"),
+		"Namespace dev":            def("Namespace", "", "dev"),
+		"Namespace prod":           def("Namespace", "", "prod"),
+		"Deployment staging/app":   def("Deployment", "staging", "app"),
+		"Deployment dev/app":       def("Deployment", "dev", "app"),
+		"Deployment prod/app":      def("Deployment", "prod", "app"),
+		"Deployment annotated/app": def("Deployment", "annotated", "app"),
+	}
+	onlyInCluster := map[string][]byte{
+		"Deployment staging/gone":    def("Deployment", "staging", "gone"),
+		"Deployment staging/kept":    def("Deployment", "staging", "kept", "protected"),
+		"Deployment staging/ignored": def("Deployment", "staging", "ignored", "ignore"),
+		"Deployment dev/gone":        def("Deployment", "dev", "gone"),
+		"Deployment prod/gone":       def("Deployment", "prod", "gone"),
+		// This namespace has the policy in the cluster, though it's
+		// not in the repo
+		"Namespace annotated":       def("Namespace", "", "annotated", "prune"),
+		"Deployment annotated/gone": def("Deployment", "annotated", "gone"),
+		// Namespaces aren't pruned, since that would take everything
+		// in them too
+		"Namespace leftover": def("Namespace", "", "leftover"),
+	}
+	// These weren't applied by flux, so are never pruned
+	notApplied := map[string][]byte{
+		"Deployment staging/manual": def("Deployment", "staging", "manual"),
+		"Deployment dev/manual":     def("Deployment", "dev", "manual"),
+	}
+	marked := func(def []byte) []byte {
+		def, err := manifests.MarkApplied(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return def
+	}
+	setup := func() (*syncCluster, map[string]resource.Resource) {
+		clus := &syncCluster{&cluster.Mock{}, map[string][]byte{}}
+		var repo [][]byte
+		for id, def := range inRepo {
+			repo = append(repo, def)
+			clus.resources[id] = marked(def)
+		}
+		for id, def := range onlyInCluster {
+			clus.resources[id] = marked(def)
+		}
+		for id, def := range notApplied {
+			clus.resources[id] = def
+		}
+		resources, err := manifests.ParseManifests(bytes.Join(repo, []byte("\n---\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return clus, resources
+	}
+
+	prune := Pruning{Defaults: policy.NewSharedDefaults(policy.NamespaceDefaults{"dev": policy.Set{policy.Prune: "true"}})}
+	expected := []string{"Deployment annotated/gone", "Deployment dev/gone", "Deployment staging/gone"}
+
+	clus, resources := setup()
+	prune.DryRun = true
+	result, err := Sync(manifests, resources, clus, prune, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Pruned, expected) {
+		t.Errorf("dry run: expected %v to be pruned, got %v", expected, result.Pruned)
+	}
+	for _, id := range expected {
+		if _, ok := clus.resources[id]; !ok {
+			t.Errorf("dry run: expected %s not to be deleted", id)
+		}
+	}
+
+	clus, resources = setup()
+	prune.DryRun = false
+	result, err = Sync(manifests, resources, clus, prune, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Pruned, expected) {
+		t.Errorf("expected %v to be pruned, got %v", expected, result.Pruned)
+	}
+	for _, id := range expected {
+		if _, ok := clus.resources[id]; ok {
+			t.Errorf("expected %s to be deleted", id)
+		}
+	}
+	for _, id := range []string{"Deployment staging/kept", "Deployment staging/ignored", "Deployment prod/gone", "Namespace leftover", "Deployment staging/manual", "Deployment dev/manual"} {
+		if _, ok := clus.resources[id]; !ok {
+			t.Errorf("expected %s not to be deleted", id)
+		}
+	}
+	// What flux applied, and is left behind, has drifted from the
+	// repo; what's ignored, or wasn't applied by flux, hasn't
+	expectedDrift := []string{"Deployment prod/gone", "Deployment staging/kept", "Namespace annotated", "Namespace leftover"}
+	if !reflect.DeepEqual(result.Drifted, expectedDrift) {
+		t.Errorf("expected %v to have drifted, got %v", expectedDrift, result.Drifted)
+	}
+
+	// Kinds the cluster leaves out of its export are never counted
+	// as drift
+	clus, resources = setup()
+	prune.DryRun = true
+	result, err = Sync(manifests, resources, excludingCluster{clus, cluster.KindFilter{"Namespace"}}, prune, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDrift = []string{"Deployment annotated/gone", "Deployment prod/gone", "Deployment staging/kept"}
+	if !reflect.DeepEqual(result.Drifted, expectedDrift) {
+		t.Errorf("excluding namespaces: expected %v to have drifted, got %v", expectedDrift, result.Drifted)
+	}
+	prune.DryRun = false
+
+	// When only some of the repo is looked at, nothing is pruned
+	clus, resources = setup()
+	prune.PathScoped = true
+	result, err = Sync(manifests, resources, clus, prune, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pruned) != 0 || len(result.Drifted) != 0 {
+		t.Errorf("path scoped: expected nothing to be pruned or to have drifted, got %v and %v", result.Pruned, result.Drifted)
+	}
+	for id := range onlyInCluster {
+		if _, ok := clus.resources[id]; !ok {
+			t.Errorf("path scoped: expected %s not to be deleted", id)
+		}
+	}
+}
+
 // ---
 
 var gitconf git.Config = git.Config{
@@ -132,6 +278,23 @@ func (p *syncCluster) Export() ([]byte, error) {
 	return bytes.Join(configs, []byte("\n---\n")), nil
 }
 
+// A cluster that leaves the kinds excluded out of its export, as the
+// Kubernetes cluster does.
+type excludingCluster struct {
+	*syncCluster
+	exclude cluster.KindFilter
+}
+
+func (p excludingCluster) Export() ([]byte, error) {
+	var configs [][]byte
+	for id, config := range p.resources {
+		if kind, _, _ := resource.SplitID(id); !p.exclude.Excludes(kind) {
+			configs = append(configs, config)
+		}
+	}
+	return bytes.Join(configs, []byte("\n---\n")), nil
+}
+
 func resourcesToStrings(resources map[string]resource.Resource) map[string]string {
 	res := map[string]string{}
 	for k, r := range resources {
@@ -156,7 +319,15 @@ func checkClusterMatchesFiles(t *testing.T, m cluster.Manifests, c cluster.Clust
 		t.Fatal(err)
 	}
 
+	// What's applied has the mark added
 	expected := resourcesToStrings(files)
+	for id, def := range expected {
+		marked, err := m.MarkApplied([]byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[id] = string(marked)
+	}
 	got := resourcesToStrings(resources)
 
 	if !reflect.DeepEqual(expected, got) {
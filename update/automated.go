@@ -120,11 +120,17 @@ func (a *Automated) calculateImageUpdates(rc ReleaseContext, candidates []*Servi
 				if err != nil {
 					return nil, err
 				}
+				var warning string
+				u.ManifestBytes, warning, err = rc.Manifests().CheckPullPolicy(u.ManifestBytes, container.Name, change.ImageID)
+				if err != nil {
+					return nil, err
+				}
 
 				containerUpdates = append(containerUpdates, ContainerUpdate{
 					Container: container.Name,
 					Current:   currentImageID,
 					Target:    change.ImageID,
+					Warning:   warning,
 				})
 			}
 		}
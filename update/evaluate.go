@@ -0,0 +1,162 @@
+package update
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+)
+
+const (
+	NotAutomated          = "not automated"
+	TagNotMatched         = "tag does not match filter"
+	Paused                = "automation paused"
+	ContainerNotAutomated = "container not automated"
+	ContainerLocked       = "container locked"
+	NotLatest             = "another image would be released"
+	OutsideWindow         = "outside automation window"
+)
+
+// TagPattern gives the pattern that tags must match for automation to
+// update the container given, as set by a `tag.<container>` policy;
+// or policy.TagAll if there's no such policy.
+func TagPattern(services policy.ServiceMap, service flux.ServiceID, container string) policy.Pattern {
+	return services[service].TagPattern(container)
+}
+
+// LatestFunc picks the image automation would release from those
+// available for a repository; ImageMap.LatestImage is one, and a
+// daemon with a TagOrderer has another.
+type LatestFunc func(images ImageMap, repo string, pattern policy.Pattern) (*flux.Image, error)
+
+// EvaluateImage works out what automation would do if the image given
+// were to appear as the newest in its repository, alongside the
+// images available. Each service running a container from that
+// repository gets a result, saying which containers would be updated,
+// or why the service would be left alone. Services not using the
+// repository at all are left out. The image automation would release
+// is picked by latest (or, if that's nil, ImageMap.LatestImage), and
+// services with an automation window are only released to if the
+// window contains now, just as for a real automated release.
+func EvaluateImage(image flux.ImageID, services []cluster.Service, available ImageMap, automated, locked, paused policy.ServiceMap, latest LatestFunc, now time.Time) Result {
+	result := Result{}
+	repo := image.Repository()
+	if latest == nil {
+		latest = func(images ImageMap, repo string, pattern policy.Pattern) (*flux.Image, error) {
+			return images.LatestImage(repo, pattern), nil
+		}
+	}
+	// The images available with the hypothetical image put first,
+	// i.e., as the most recent, so that it's judged against the
+	// others in the same way as a real image would be
+	images := ImageMap{repo: []flux.Image{{ID: image, CreatedAt: now}}}
+	for _, i := range available[repo] {
+		if i.ID != image {
+			images[repo] = append(images[repo], i)
+		}
+	}
+
+	for _, service := range services {
+		var containers []cluster.Container
+		for _, container := range withoutIgnoredContainers(service, automated[service.ID]).ContainersOrNil() {
+			current, err := flux.ParseImageID(container.Image)
+			if err != nil || current.Repository() != repo {
+				continue
+			}
+			containers = append(containers, container)
+		}
+		if len(containers) == 0 {
+			continue
+		}
+
+		switch {
+		case !automated.Contains(service.ID):
+			result[service.ID] = ServiceResult{
+				Status: ReleaseStatusIgnored,
+				Error:  NotAutomated,
+			}
+			continue
+		case locked.Contains(service.ID):
+			result[service.ID] = ServiceResult{
+				Status: ReleaseStatusSkipped,
+				Error:  Locked,
+			}
+			continue
+		case paused.Contains(service.ID):
+			result[service.ID] = ServiceResult{
+				Status: ReleaseStatusSkipped,
+				Error:  Paused,
+			}
+			continue
+		}
+
+		var updates []ContainerUpdate
+		reason := ImageUpToDate
+		var failed error
+		for _, container := range containers {
+			current, _ := flux.ParseImageID(container.Image)
+			policies := automated[service.ID]
+			pattern := TagPattern(automated, service.ID, container.Name)
+			_, _, tag := image.Components()
+			switch {
+			case policies.ContainerLocked(container.Name):
+				reason = ContainerLocked
+				continue
+			case !policies.ContainerAutomated(container.Name):
+				reason = ContainerNotAutomated
+				continue
+			case !pattern.Matches(tag):
+				reason = TagNotMatched
+				continue
+			case current == image:
+				// already running it; nothing to do
+				continue
+			}
+			chosen, err := latest(images, repo, pattern)
+			switch {
+			case err != nil:
+				failed = err
+			case chosen == nil || chosen.ID != image:
+				reason = NotLatest
+			default:
+				updates = append(updates, ContainerUpdate{
+					Container: container.Name,
+					Current:   current,
+					Target:    image,
+				})
+			}
+		}
+
+		window, hasWindow := automated[service.ID].AutomationWindow()
+		switch {
+		case failed != nil:
+			result[service.ID] = ServiceResult{
+				Status: ReleaseStatusFailed,
+				Error:  failed.Error(),
+			}
+		case len(updates) > 0 && hasWindow && !window.Contains(now):
+			reason := fmt.Sprintf("%s %s", OutsideWindow, window)
+			if opens := window.NextOpen(now); !opens.IsZero() {
+				reason = fmt.Sprintf("%s (until %s)", reason, opens.Format(time.RFC3339))
+			}
+			result[service.ID] = ServiceResult{
+				Status:       ReleaseStatusSkipped,
+				Error:        reason,
+				PerContainer: updates,
+			}
+		case len(updates) > 0:
+			result[service.ID] = ServiceResult{
+				Status:       ReleaseStatusSuccess,
+				PerContainer: updates,
+			}
+		default:
+			result[service.ID] = ServiceResult{
+				Status: ReleaseStatusSkipped,
+				Error:  reason,
+			}
+		}
+	}
+	return result
+}
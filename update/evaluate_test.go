@@ -0,0 +1,144 @@
+package update
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+)
+
+func service(id, container, image string) cluster.Service {
+	return cluster.Service{
+		ID: flux.ServiceID(id),
+		Containers: cluster.ContainersOrExcuse{
+			Containers: []cluster.Container{{Name: container, Image: image}},
+		},
+	}
+}
+
+func TestEvaluateImage(t *testing.T) {
+	image, err := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:master-a000001")
+
+	services := []cluster.Service{
+		service("default/automated", "hello", current.String()),
+		service("default/manual", "hello", current.String()),
+		service("default/locked", "hello", current.String()),
+		service("default/paused", "hello", current.String()),
+		service("default/filtered", "hello", current.String()),
+		service("default/uptodate", "hello", image.String()),
+		service("default/optedout", "hello", current.String()),
+		service("default/lockedcontainer", "hello", current.String()),
+		service("default/ignoredcontainer", "hello", current.String()),
+		service("default/other", "sidecar", "quay.io/weaveworks/sidecar:master-a000001"),
+		service("default/windowed", "hello", current.String()),
+	}
+	automated := policy.ServiceMap{
+		"default/automated":       policy.Set{policy.Automated: "true"},
+		"default/locked":          policy.Set{policy.Automated: "true"},
+		"default/paused":          policy.Set{policy.Automated: "true"},
+		"default/filtered":        policy.Set{policy.Automated: "true", "tag.hello": "glob:v*"},
+		"default/uptodate":        policy.Set{policy.Automated: "true"},
+		"default/other":           policy.Set{policy.Automated: "true"},
+		"default/optedout":        policy.Set{policy.Automated: "true", "automated.hello": "false"},
+		"default/lockedcontainer": policy.Set{policy.Automated: "true", "locked.hello": "true"},
+		// An ignored container is as good as not there
+		"default/ignoredcontainer": policy.Set{policy.Automated: "true", policy.IgnoreContainers: "istio-proxy,hello"},
+		"default/windowed":         policy.Set{policy.Automated: "true", policy.AutomateWindow: "Mon-Fri 09:00-17:00 UTC"},
+	}
+	locked := policy.ServiceMap{
+		"default/locked": policy.Set{policy.Locked: "true"},
+	}
+	paused := policy.ServiceMap{
+		"default/paused": policy.Set{policy.Paused: "2017-06-01T12:00:00Z"},
+	}
+
+	expected := Result{
+		"default/automated": ServiceResult{
+			Status: ReleaseStatusSuccess,
+			PerContainer: []ContainerUpdate{
+				{Container: "hello", Current: current, Target: image},
+			},
+		},
+		"default/manual":          ServiceResult{Status: ReleaseStatusIgnored, Error: NotAutomated},
+		"default/locked":          ServiceResult{Status: ReleaseStatusSkipped, Error: Locked},
+		"default/paused":          ServiceResult{Status: ReleaseStatusSkipped, Error: Paused},
+		"default/filtered":        ServiceResult{Status: ReleaseStatusSkipped, Error: TagNotMatched},
+		"default/uptodate":        ServiceResult{Status: ReleaseStatusSkipped, Error: ImageUpToDate},
+		"default/optedout":        ServiceResult{Status: ReleaseStatusSkipped, Error: ContainerNotAutomated},
+		"default/lockedcontainer": ServiceResult{Status: ReleaseStatusSkipped, Error: ContainerLocked},
+		"default/windowed": ServiceResult{
+			Status: ReleaseStatusSkipped,
+			Error:  "outside automation window Mon-Fri 09:00-17:00 UTC (until 2017-06-05T09:00:00Z)",
+			PerContainer: []ContainerUpdate{
+				{Container: "hello", Current: current, Target: image},
+			},
+		},
+	}
+
+	// A Sunday, so outside the window
+	now := time.Date(2017, 6, 4, 12, 0, 0, 0, time.UTC)
+	result := EvaluateImage(image, services, nil, automated, locked, paused, nil, now)
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected:\n%#v\ngot:\n%#v", expected, result)
+	}
+}
+
+func TestEvaluateImageChoosesLatest(t *testing.T) {
+	image, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:v1.1.0")
+	current, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:v1.0.0")
+	newer, _ := flux.ParseImageID("quay.io/weaveworks/helloworld:v1.2.0")
+	available := ImageMap{image.Repository(): []flux.Image{{ID: newer}, {ID: current}}}
+	services := []cluster.Service{service("default/helloworld", "hello", current.String())}
+	now := time.Now()
+
+	for _, example := range []struct {
+		name     string
+		policies policy.Set
+		latest   LatestFunc
+		expected ServiceResult
+	}{
+		{
+			name:     "most recent",
+			policies: policy.Set{policy.Automated: "true"},
+			expected: ServiceResult{
+				Status:       ReleaseStatusSuccess,
+				PerContainer: []ContainerUpdate{{Container: "hello", Current: current, Target: image}},
+			},
+		},
+		{
+			name:     "semver",
+			policies: policy.Set{policy.Automated: "true", "tag.hello": "semver:^1"},
+			expected: ServiceResult{Status: ReleaseStatusSkipped, Error: NotLatest},
+		},
+		{
+			name:     "ordered elsewhere",
+			policies: policy.Set{policy.Automated: "true"},
+			latest: func(images ImageMap, repo string, pattern policy.Pattern) (*flux.Image, error) {
+				return &flux.Image{ID: newer}, nil
+			},
+			expected: ServiceResult{Status: ReleaseStatusSkipped, Error: NotLatest},
+		},
+		{
+			name:     "ordering failed",
+			policies: policy.Set{policy.Automated: "true"},
+			latest: func(images ImageMap, repo string, pattern policy.Pattern) (*flux.Image, error) {
+				return nil, errors.New("orderer unavailable")
+			},
+			expected: ServiceResult{Status: ReleaseStatusFailed, Error: "orderer unavailable"},
+		},
+	} {
+		automated := policy.ServiceMap{"default/helloworld": example.policies}
+		result := EvaluateImage(image, services, available, automated, nil, nil, example.latest, now)
+		if got := result["default/helloworld"]; !reflect.DeepEqual(example.expected, got) {
+			t.Errorf("%s: expected:\n%#v\ngot:\n%#v", example.name, example.expected, got)
+		}
+	}
+}
@@ -3,15 +3,19 @@ package update
 import "github.com/weaveworks/flux"
 
 const (
-	Locked          = "locked"
-	NotIncluded     = "not included"
-	Excluded        = "excluded"
-	DifferentImage  = "a different image"
-	NotInCluster    = "not running in cluster"
-	NotInRepo       = "not found in repository"
-	ImageNotFound   = "cannot find one or more images"
-	ImageUpToDate   = "image(s) up to date"
-	DoesNotUseImage = "does not use image(s)"
+	Locked            = "locked"
+	NotIncluded       = "not included"
+	Excluded          = "excluded"
+	DifferentImage    = "a different image"
+	NotInCluster      = "not running in cluster"
+	NotInRepo         = "not found in repository"
+	ImageNotFound     = "cannot find one or more images"
+	ImageUpToDate     = "image(s) up to date"
+	DoesNotUseImage   = "does not use image(s)"
+	NoEarlierImage    = "no earlier image found"
+	NotAtRevision     = "not defined at revision"
+	SameAtRevision    = "unchanged since revision"
+	ContainerNotFound = "container not found"
 )
 
 type SpecificImageFilter struct {
@@ -2,34 +2,120 @@ package update
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/pkg/errors"
-	glob "github.com/ryanuber/go-glob"
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/registry"
 )
 
 type ImageMap map[string][]flux.Image
 
-// LatestImage returns the latest releasable image for a repository for
-// which the tag matches a given pattern. A releasable image is one that
-// is not tagged "latest". (Assumes the available images are in
-// descending order of latestness.) If no such image exists, returns nil,
-// and the caller can decide whether that's an error or not.
-func (m ImageMap) LatestImage(repo, tagGlob string) *flux.Image {
+// LatestImage returns the latest image for a repository for which the
+// tag matches a given pattern. Unless the pattern says which tags are
+// newer (e.g., by semver precedence), that's the most recent; the
+// available images are assumed to be in descending order of
+// latestness. If no such image exists, returns nil, and the caller
+// can decide whether that's an error or not.
+func (m ImageMap) LatestImage(repo string, pattern policy.Pattern) *flux.Image {
+	var latest *flux.Image
+	var latestTag string
 	for _, image := range m[repo] {
 		_, _, tag := image.ID.Components()
-		// Ignore latest if and only if it's not what the user wants.
-		if !strings.EqualFold(tagGlob, "latest") && strings.EqualFold(tag, "latest") {
+		if !pattern.Matches(tag) {
 			continue
 		}
-		if glob.Glob(tagGlob, tag) {
-			return &image
+		if latest == nil || pattern.Newer(tag, latestTag) {
+			image := image
+			latest, latestTag = &image, tag
 		}
 	}
-	return nil
+	return latest
+}
+
+// LatestImageOrdered is like LatestImage, except that the tags of the
+// releasable images are ranked by the orderer given, rather than
+// assumed to be in order already. If the orderer ranks none of them,
+// returns nil.
+func (m ImageMap) LatestImageOrdered(orderer registry.TagOrderer, repo string, pattern policy.Pattern) (*flux.Image, error) {
+	byTag := map[string]flux.Image{}
+	var tags []string
+	for _, image := range m[repo] {
+		if _, _, tag := image.ID.Components(); pattern.Matches(tag) {
+			byTag[tag] = image
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	ranked, err := orderer.OrderTags(repo, tags)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ordering tags of %s", repo)
+	}
+	// Anything the orderer makes up is ignored
+	for _, tag := range ranked {
+		if image, ok := byTag[tag]; ok {
+			return &image, nil
+		}
+	}
+	return nil, nil
+}
+
+// WithoutIgnoredContainers gives the services with the containers
+// each ignores (by its IgnoreContainers policy) left out, so that
+// they're neither looked at nor released.
+func WithoutIgnoredContainers(services []cluster.Service, policies policy.ServiceMap) []cluster.Service {
+	result := make([]cluster.Service, len(services))
+	for i, service := range services {
+		result[i] = withoutIgnoredContainers(service, policies[service.ID])
+	}
+	return result
+}
+
+func withoutIgnoredContainers(service cluster.Service, policies policy.Set) cluster.Service {
+	if !policies.Contains(policy.IgnoreContainers) {
+		return service
+	}
+	var containers []cluster.Container
+	for _, container := range service.ContainersOrNil() {
+		if !policies.ContainerIgnored(container.Name) {
+			containers = append(containers, container)
+		}
+	}
+	service.Containers.Containers = containers
+	return service
+}
+
+// onlyContainers leaves only the containers named in the service.
+// If one of the names isn't a container of the service, it's given
+// back, and the service is left as it was. A service that couldn't
+// give its containers is left as it is, so the reason can be
+// reported.
+func onlyContainers(service cluster.Service, names []string) (cluster.Service, string) {
+	containers, err := service.ContainersOrError()
+	if err != nil {
+		return service, ""
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var picked []cluster.Container
+	for _, container := range containers {
+		if wanted[container.Name] {
+			picked = append(picked, container)
+			delete(wanted, container.Name)
+		}
+	}
+	for _, name := range names {
+		if wanted[name] {
+			return service, name
+		}
+	}
+	service.Containers.Containers = picked
+	return service, ""
 }
 
 // CollectUpdateImages is a convenient shim to
@@ -74,29 +160,18 @@ func CollectAvailableImages(reg registry.Registry, services []cluster.Service) (
 	return images, nil
 }
 
-// Create a map of images. It will check that each image exists.
+// Create a map of images. It will check that each image exists. The
+// image given for an alias tag is the one with the concrete tag it
+// resolves to, as the registry says.
 func exactImages(reg registry.Registry, images []flux.ImageID) (ImageMap, error) {
 	m := ImageMap{}
 	for _, id := range images {
 		// We must check that the exact images requested actually exist. Otherwise we risk pushing invalid images to git.
-		exist, err := imageExists(reg, id)
+		image, err := reg.GetImage(id)
 		if err != nil {
-			return m, errors.Wrap(flux.ErrInvalidImageID, err.Error())
-		}
-		if !exist {
-			return m, errors.Wrap(flux.ErrInvalidImageID, fmt.Sprintf("image %q does not exist", id))
+			return m, errors.Wrap(flux.ErrInvalidImageID, fmt.Sprintf("image %q does not exist (%s)", id, err))
 		}
-		m[id.Repository()] = []flux.Image{{ID: id}}
+		m[id.Repository()] = []flux.Image{{ID: image.ID}}
 	}
 	return m, nil
 }
-
-// Checks whether the given image exists in the repository.
-// Return true if exist, false otherwise
-func imageExists(reg registry.Registry, imageID flux.ImageID) (bool, error) {
-	_, err := reg.GetImage(imageID)
-	if err != nil {
-		return false, nil
-	}
-	return true, nil
-}
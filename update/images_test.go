@@ -0,0 +1,80 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+)
+
+// reverseOrderer ranks tags in the opposite order to that given, and
+// leaves out any it's told to.
+type reverseOrderer struct {
+	drop   string
+	called []string
+}
+
+func (o *reverseOrderer) OrderTags(repo string, tags []string) ([]string, error) {
+	o.called = tags
+	var ranked []string
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i] != o.drop {
+			ranked = append(ranked, tags[i])
+		}
+	}
+	return append(ranked, "made-up"), nil
+}
+
+func TestLatestImageOrdered(t *testing.T) {
+	repo := "quay.io/weaveworks/helloworld"
+	var images []flux.Image
+	for _, tag := range []string{"latest", "release-2", "master-3", "release-1"} {
+		id, _ := flux.ParseImageID(repo + ":" + tag)
+		images = append(images, flux.Image{ID: id})
+	}
+	m := ImageMap{repo: images}
+
+	orderer := &reverseOrderer{}
+	latest, err := m.LatestImageOrdered(orderer, repo, policy.NewPattern("release-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.ID.Tag != "release-1" {
+		t.Errorf("expected release-1, got %v", latest)
+	}
+	if len(orderer.called) != 2 {
+		t.Errorf("expected only the tags matching the pattern to be ranked, got %v", orderer.called)
+	}
+
+	orderer = &reverseOrderer{drop: "release-1"}
+	if latest, _ = m.LatestImageOrdered(orderer, repo, policy.NewPattern("release-1")); latest != nil {
+		t.Errorf("expected no image when the orderer drops the only candidate, got %v", latest)
+	}
+}
+
+func TestLatestImageSemver(t *testing.T) {
+	repo := "quay.io/weaveworks/helloworld"
+	var images []flux.Image
+	// Most recently made first, which isn't the order of versions
+	for _, tag := range []string{"1.2.1", "master-a000003", "1.3.0", "1.10.0", "2.0.0", "latest"} {
+		id, _ := flux.ParseImageID(repo + ":" + tag)
+		images = append(images, flux.Image{ID: id})
+	}
+	m := ImageMap{repo: images}
+
+	for pattern, expected := range map[string]string{
+		"semver:^1.2": "1.10.0",
+		"semver:~1.2": "1.2.1",
+		"semver:*":    "2.0.0",
+		"*":           "1.2.1",
+		"semver:^3":   "",
+	} {
+		latest := m.LatestImage(repo, policy.NewPattern(pattern))
+		switch {
+		case expected == "" && latest != nil:
+			t.Errorf("%s: expected no image, got %s", pattern, latest.ID)
+		case expected != "" && (latest == nil || latest.ID.Tag != expected):
+			t.Errorf("%s: expected %s, got %v", pattern, expected, latest)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package update
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/weaveworks/flux"
+)
+
+// ImageSpecError says why an image spec couldn't be parsed and, if
+// there's an obvious fix, what was probably meant. It doubles as a
+// flux.HelpfulError, so it can be shown to users as it is.
+type ImageSpecError struct {
+	Spec       string
+	Problem    string
+	Suggestion string
+}
+
+func (e *ImageSpecError) Error() string {
+	return fmt.Sprintf("invalid image spec %q: %s", e.Spec, e.Problem)
+}
+
+func (e *ImageSpecError) Base() *flux.BaseError {
+	help := fmt.Sprintf(`Invalid image %q
+
+The image given (%s) could not be understood: %s.
+`, e.Spec, e.Spec, e.Problem)
+	if e.Suggestion != "" {
+		help += fmt.Sprintf("\nDid you mean %q?\n", e.Suggestion)
+	}
+	help += `
+Images are given as [host[:port]/][namespace/]name, followed by a tag
+(":tag") and/or a digest ("@sha256:..."), e.g.,
+
+    quay.io/weaveworks/helloworld:master-a000001
+    localhost:5000/helloworld@sha256:` + strings.Repeat("0", 64) + `
+`
+	return &flux.BaseError{Help: help, Err: e}
+}
+
+var (
+	// Path components of a repository name, per the Docker
+	// distribution reference grammar
+	imageNameComponentRE = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+	imageTagRE           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	imageDigestRE        = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+	sha256DigestRE       = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// parseImageSpecID parses an image as given by a user. This is more
+// particular than flux.ParseImageID, which has to cope with whatever
+// is found running in the cluster.
+func parseImageSpecID(s string) (flux.ImageID, error) {
+	fail := func(problem, suggestion string) (flux.ImageID, error) {
+		return flux.ImageID{}, &ImageSpecError{Spec: s, Problem: problem, Suggestion: suggestion}
+	}
+	if strings.TrimSpace(s) == "" {
+		return fail("no image given", "")
+	}
+	if trimmed := strings.TrimSpace(s); trimmed != s {
+		return fail("it has surrounding whitespace", trimmed)
+	}
+
+	name, digest := s, ""
+	if i := strings.Index(s, "@"); i >= 0 {
+		name, digest = s[:i], s[i+1:]
+	}
+	tagged := strings.LastIndex(name, ":") > strings.LastIndex(name, "/")
+	if tagged && strings.HasSuffix(name, ":") {
+		return fail("the tag is blank", name+"latest")
+	}
+	if !tagged && digest == "" {
+		return fail("there is no tag (to use latest, say so explicitly)", s+":latest")
+	}
+
+	id, err := flux.ParseImageID(s)
+	switch {
+	case err == flux.ErrBlankImageID:
+		return fail("the image name is blank", "")
+	case err != nil:
+		return fail("the name is not of the form described below", "")
+	}
+
+	if digest != "" {
+		if strings.HasPrefix(digest, "sha256:") && !sha256DigestRE.MatchString(digest) {
+			return fail("a sha256 digest should be 64 lowercase hex digits", suggestLower(s, digest))
+		}
+		if !imageDigestRE.MatchString(digest) {
+			return fail(fmt.Sprintf("%q is not a valid digest", digest), "")
+		}
+	}
+	if tagged && !imageTagRE.MatchString(id.Tag) {
+		return fail(fmt.Sprintf("%q is not a valid tag", id.Tag), "")
+	}
+	for _, component := range strings.Split(id.NamespaceImage(), "/") {
+		if imageNameComponentRE.MatchString(component) {
+			continue
+		}
+		if lower := strings.ToLower(component); imageNameComponentRE.MatchString(lower) {
+			return fail("image names must be lowercase", strings.Replace(s, component, lower, 1))
+		}
+		return fail(fmt.Sprintf("%q is not a valid image name", component), "")
+	}
+	return id, nil
+}
+
+func suggestLower(s, part string) string {
+	lower := strings.ToLower(part)
+	if lower == part || !sha256DigestRE.MatchString(lower) {
+		return ""
+	}
+	return strings.Replace(s, part, lower, 1)
+}
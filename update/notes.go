@@ -0,0 +1,129 @@
+package update
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/weaveworks/flux"
+)
+
+// Image labels that say where an image was built from. Both the
+// label-schema.org convention and its successor from the Open
+// Container Initiative are looked for.
+var (
+	revisionLabels = []string{"org.opencontainers.image.revision", "org.label-schema.vcs-ref"}
+	sourceLabels   = []string{"org.opencontainers.image.source", "org.label-schema.vcs-url"}
+)
+
+// ReleaseNotes describe what changed in a release, for people rather
+// than for machines: which images went from which tag to which, the
+// commits the images were built from (if the images are labelled
+// with them), and why the release was made.
+type ReleaseNotes struct {
+	Revision string         `json:"revision,omitempty"`
+	Cause    Cause          `json:"cause"`
+	Services []ServiceNotes `json:"services"`
+	// Text is the notes rendered with ReleaseNotesTemplate
+	Text string `json:"text"`
+}
+
+type ServiceNotes struct {
+	ID         flux.ServiceID   `json:"id"`
+	Containers []ContainerNotes `json:"containers"`
+}
+
+type ContainerNotes struct {
+	Container  string `json:"container"`
+	Repository string `json:"repository"`
+	From       string `json:"from"` // tag or digest
+	To         string `json:"to"`
+	// Taken from the image labels, if present
+	FromRevision string `json:"fromRevision,omitempty"`
+	ToRevision   string `json:"toRevision,omitempty"`
+	Source       string `json:"source,omitempty"`
+}
+
+// ReleaseNotesTemplate is used to render release notes as text.
+const ReleaseNotesTemplate = `{{with .Cause}}{{if .Message}}{{.Message}}{{if .User}} ({{.User}}){{end}}
+{{else if .User}}Released by {{.User}}
+{{end}}{{end}}{{with .Revision}}Commit {{short .}}
+{{end}}{{range .Services}}
+{{.ID}}
+{{range .Containers}}  {{.Container}}: {{.Repository}} {{.From}} -> {{.To}}
+{{if .ToRevision}}    source: {{with .Source}}{{.}} {{end}}{{if .FromRevision}}{{short .FromRevision}}..{{end}}{{short .ToRevision}}
+{{end}}{{end}}{{end}}`
+
+// NewReleaseNotes composes the notes for a release from its result.
+// Only containers that were updated are included. The images
+// function is used to look up each image, so that its labels can be
+// consulted; it may return false if it doesn't know the image.
+func NewReleaseNotes(revision string, cause Cause, result Result, images func(flux.ImageID) (flux.Image, bool)) (ReleaseNotes, error) {
+	notes := ReleaseNotes{
+		Revision: revision,
+		Cause:    cause,
+	}
+	for _, serviceID := range result.ServiceIDs() {
+		id := flux.ServiceID(serviceID)
+		res := result[id]
+		if res.Status != ReleaseStatusSuccess || len(res.PerContainer) == 0 {
+			continue
+		}
+		service := ServiceNotes{ID: id}
+		for _, update := range res.PerContainer {
+			container := ContainerNotes{
+				Container:  update.Container,
+				Repository: update.Target.Repository(),
+				From:       update.Current.Reference(),
+				To:         update.Target.Reference(),
+			}
+			if img, ok := images(update.Current); ok {
+				container.FromRevision = firstLabel(img.Labels, revisionLabels)
+			}
+			if img, ok := images(update.Target); ok {
+				container.ToRevision = firstLabel(img.Labels, revisionLabels)
+				container.Source = firstLabel(img.Labels, sourceLabels)
+			}
+			service.Containers = append(service.Containers, container)
+		}
+		notes.Services = append(notes.Services, service)
+	}
+
+	text, err := notes.Render(ReleaseNotesTemplate)
+	if err != nil {
+		return ReleaseNotes{}, err
+	}
+	notes.Text = text
+	return notes, nil
+}
+
+// Render executes the template given with the release notes.
+func (n ReleaseNotes) Render(tmplStr string) (string, error) {
+	tmpl, err := template.New("release-notes").Funcs(template.FuncMap{
+		"short": shortRevision,
+	}).Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func firstLabel(labels map[string]string, names []string) string {
+	for _, name := range names {
+		if v := labels[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func shortRevision(rev string) string {
+	if len(rev) <= 7 {
+		return rev
+	}
+	return rev[:7]
+}
@@ -0,0 +1,81 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	current := flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"}
+	target := current.WithNewTag("master-a000002")
+	result := Result{
+		flux.ServiceID("default/helloworld"): ServiceResult{
+			Status: ReleaseStatusSuccess,
+			PerContainer: []ContainerUpdate{
+				{Container: "helloworld", Current: current, Target: target},
+			},
+		},
+		flux.ServiceID("default/skipped"): ServiceResult{
+			Status: ReleaseStatusSkipped,
+		},
+	}
+	images := map[flux.ImageID]flux.Image{
+		current: {ID: current, Labels: map[string]string{
+			"org.label-schema.vcs-ref": "a000001a000001a000001",
+		}},
+		target: {ID: target, Labels: map[string]string{
+			"org.opencontainers.image.revision": "a000002a000002a000002",
+			"org.label-schema.vcs-ref":          "ignored",
+			"org.label-schema.vcs-url":          "https://github.com/weaveworks/helloworld",
+		}},
+	}
+
+	notes, err := NewReleaseNotes("abcdef0123456", Cause{User: "test-user", Message: "fixing things"}, result, func(id flux.ImageID) (flux.Image, bool) {
+		img, ok := images[id]
+		return img, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes.Services) != 1 || len(notes.Services[0].Containers) != 1 {
+		t.Fatalf("expected notes for one container of one service, got %#v", notes.Services)
+	}
+	container := notes.Services[0].Containers[0]
+	if container.FromRevision != "a000001a000001a000001" || container.ToRevision != "a000002a000002a000002" {
+		t.Errorf("expected revisions from image labels, got %q..%q", container.FromRevision, container.ToRevision)
+	}
+
+	expected := `fixing things (test-user)
+Commit abcdef0
+
+default/helloworld
+  helloworld: quay.io/weaveworks/helloworld master-a000001 -> master-a000002
+    source: https://github.com/weaveworks/helloworld a000001..a000002`
+	if notes.Text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, notes.Text)
+	}
+}
+
+func TestReleaseNotesWithoutLabels(t *testing.T) {
+	current := flux.ImageID{Image: "helloworld", Tag: "1"}
+	result := Result{
+		flux.ServiceID("default/helloworld"): ServiceResult{
+			Status: ReleaseStatusSuccess,
+			PerContainer: []ContainerUpdate{
+				{Container: "helloworld", Current: current, Target: current.WithNewTag("2")},
+			},
+		},
+	}
+	notes, err := NewReleaseNotes("", Cause{}, result, func(flux.ImageID) (flux.Image, bool) {
+		return flux.Image{}, false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `default/helloworld
+  helloworld: helloworld 1 -> 2`
+	if notes.Text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, notes.Text)
+	}
+}
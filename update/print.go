@@ -6,6 +6,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
 )
 
 func PrintResults(out io.Writer, results Result, verbose bool) {
@@ -25,7 +26,17 @@ func PrintResults(out io.Writer, results Result, verbose bool) {
 			extraLines = append(extraLines, result.Error)
 		}
 		for _, update := range result.PerContainer {
-			extraLines = append(extraLines, fmt.Sprintf("%s: %s -> %s", update.Container, update.Current.FullID(), update.Target.Tag))
+			target := update.Target.Tag
+			if update.Alias != "" {
+				target = fmt.Sprintf("%s (%s)", target, update.Alias)
+			}
+			extraLines = append(extraLines, fmt.Sprintf("%s: %s -> %s", update.Container, update.Current.FullID(), target))
+			if update.Warning != "" {
+				extraLines = append(extraLines, fmt.Sprintf("%s: %s", update.Container, update.Warning))
+			}
+		}
+		if result.Impact != nil {
+			extraLines = append(extraLines, describeImpact(*result.Impact))
 		}
 
 		var inline string
@@ -39,4 +50,27 @@ func PrintResults(out io.Writer, results Result, verbose bool) {
 		}
 	}
 	w.Flush()
+
+	// Diffs (from dry runs) won't fit in the table, so they go after
+	for _, serviceID := range results.ServiceIDs() {
+		if diff := results[flux.ServiceID(serviceID)].Diff; diff != "" {
+			fmt.Fprintf(out, "\n%s", diff)
+		}
+	}
+}
+
+// describeImpact says, in a line, what releasing a service will do
+// to its pods.
+func describeImpact(r cluster.Rollout) string {
+	s := fmt.Sprintf("replaces %d pod", r.Pods)
+	if r.Pods != 1 {
+		s += "s"
+	}
+	if r.Strategy != "" {
+		s += " by " + r.Strategy
+	}
+	if r.DisruptionBudget {
+		return s + ", within a disruption budget"
+	}
+	return s + ", with no disruption budget"
 }
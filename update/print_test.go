@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
 )
 
 func TestPrintResults(t *testing.T) {
@@ -23,8 +24,8 @@ func TestPrintResults(t *testing.T) {
 					PerContainer: []ContainerUpdate{
 						{
 							Container: "helloworld",
-							Current:   flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000002"},
-							Target:    flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000001"},
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
 						},
 					},
 				},
@@ -44,8 +45,8 @@ default/helloworld  success  helloworld: quay.io/weaveworks/helloworld:master-a0
 					PerContainer: []ContainerUpdate{
 						{
 							Container: "helloworld",
-							Current:   flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000002"},
-							Target:    flux.ImageID{"quay.io", "weaveworks", "helloworld", "master-a000001"},
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
 						},
 					},
 				},
@@ -57,6 +58,49 @@ default/helloworld  success  test error
 `,
 		},
 
+		{
+			name: "With the impact, from a dry run",
+			result: Result{
+				flux.ServiceID("default/helloworld"): ServiceResult{
+					Status: ReleaseStatusSuccess,
+					PerContainer: []ContainerUpdate{
+						{
+							Container: "helloworld",
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
+						},
+					},
+					Impact: &cluster.Rollout{Pods: 3, Strategy: "RollingUpdate", DisruptionBudget: true},
+				},
+			},
+			expected: `
+SERVICE             STATUS   UPDATES
+default/helloworld  success  helloworld: quay.io/weaveworks/helloworld:master-a000002 -> master-a000001
+                             replaces 3 pods by RollingUpdate, within a disruption budget
+`,
+		},
+
+		{
+			name: "Resolved from an alias tag",
+			result: Result{
+				flux.ServiceID("default/helloworld"): ServiceResult{
+					Status: ReleaseStatusSuccess,
+					PerContainer: []ContainerUpdate{
+						{
+							Container: "helloworld",
+							Current:   flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000002"},
+							Target:    flux.ImageID{Host: "quay.io", Namespace: "weaveworks", Image: "helloworld", Tag: "master-a000001"},
+							Alias:     "stable",
+						},
+					},
+				},
+			},
+			expected: `
+SERVICE             STATUS   UPDATES
+default/helloworld  success  helloworld: quay.io/weaveworks/helloworld:master-a000002 -> master-a000001 (stable)
+`,
+		},
+
 		{
 			name: "Service results should be sorted",
 			result: Result{
@@ -71,6 +115,23 @@ a         success
 b         success  
 c         success  
 d         success  
+`,
+		},
+
+		{
+			name: "Diffs go after the table",
+			result: Result{
+				flux.ServiceID("default/helloworld"): ServiceResult{
+					Status: ReleaseStatusSuccess,
+					Diff:   "--- a/helloworld.yaml\n+++ b/helloworld.yaml\n",
+				},
+			},
+			expected: `
+SERVICE             STATUS   UPDATES
+default/helloworld  success  
+
+--- a/helloworld.yaml
++++ b/helloworld.yaml
 `,
 		},
 	} {
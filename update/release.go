@@ -58,6 +58,10 @@ type ReleaseSpec struct {
 	ImageSpec    ImageSpec
 	Kind         ReleaseKind
 	Excludes     []flux.ServiceID
+	// Containers picks out containers of the services to release;
+	// a service with any containers picked out has only those
+	// considered, the rest have all of theirs.
+	Containers []ContainerSpec `json:",omitempty"`
 }
 
 // ReleaseType gives a one-word description of the release, mainly
@@ -71,7 +75,18 @@ func (s ReleaseSpec) ReleaseType() ReleaseType {
 	}
 }
 
+// Validate checks that containers are only picked out for services
+// being released.
+func (s ReleaseSpec) Validate() error {
+	_, err := s.selectedContainers()
+	return err
+}
+
 func (s ReleaseSpec) CalculateRelease(rc ReleaseContext, logger log.Logger) ([]*ServiceUpdate, Result, error) {
+	selected, err := s.selectedContainers()
+	if err != nil {
+		return nil, nil, err
+	}
 	results := Result{}
 	timer := NewStageTimer("select_services")
 	updates, err := s.selectServices(rc, results)
@@ -82,11 +97,22 @@ func (s ReleaseSpec) CalculateRelease(rc ReleaseContext, logger log.Logger) ([]*
 	s.markSkipped(results)
 
 	timer = NewStageTimer("lookup_images")
-	updates, err = s.calculateImageUpdates(rc, updates, results, logger)
+	updates, err = s.calculateImageUpdates(rc, updates, selected, results, logger)
 	timer.ObserveDuration()
 	if err != nil {
 		return nil, nil, err
 	}
+
+	// A plan is looked at before deciding whether to go ahead, so
+	// say what going ahead would disturb
+	if s.Kind == ReleaseKindPlan {
+		for _, u := range updates {
+			result := results[u.ServiceID]
+			rollout := u.Service.Rollout
+			result.Impact = &rollout
+			results[u.ServiceID] = result
+		}
+	}
 	return updates, results, nil
 }
 
@@ -96,10 +122,23 @@ func (s ReleaseSpec) ReleaseKind() ReleaseKind {
 
 func (s ReleaseSpec) CommitMessage() string {
 	image := strings.Trim(s.ImageSpec.String(), "<>")
+	// Services with containers picked out are named by those
 	var services []string
+	picked := map[flux.ServiceID]bool{}
+	for _, c := range s.Containers {
+		if id, _, err := c.Parts(); err == nil {
+			picked[id] = true
+		}
+	}
 	for _, spec := range s.ServiceSpecs {
+		if id, err := spec.AsID(); err == nil && picked[id] {
+			continue
+		}
 		services = append(services, strings.Trim(spec.String(), "<>"))
 	}
+	for _, c := range s.Containers {
+		services = append(services, c.String())
+	}
 	return fmt.Sprintf("Release %s to %s", image, strings.Join(services, ", "))
 }
 
@@ -163,6 +202,36 @@ func (s ReleaseSpec) filters(rc ReleaseContext) ([]ServiceFilter, error) {
 	return filtList, nil
 }
 
+// selectedContainers gives the containers picked out for each
+// service.
+func (s ReleaseSpec) selectedContainers() (map[flux.ServiceID][]string, error) {
+	if len(s.Containers) == 0 {
+		return nil, nil
+	}
+	released := map[flux.ServiceID]bool{}
+	for _, spec := range s.ServiceSpecs {
+		if spec == ServiceSpecAll {
+			released = nil
+			break
+		}
+		if id, err := spec.AsID(); err == nil {
+			released[id] = true
+		}
+	}
+	selected := map[flux.ServiceID][]string{}
+	for _, c := range s.Containers {
+		id, container, err := c.Parts()
+		if err != nil {
+			return nil, err
+		}
+		if released != nil && !released[id] {
+			return nil, errors.Errorf("container %s is picked out, but service %s is not being released", container, id)
+		}
+		selected[id] = append(selected[id], container)
+	}
+	return selected, nil
+}
+
 func (s ReleaseSpec) markSkipped(results Result) {
 	for _, v := range s.ServiceSpecs {
 		if v == ServiceSpecAll {
@@ -187,11 +256,35 @@ func (s ReleaseSpec) markSkipped(results Result) {
 // however we do want to see if we *can* do the replacements, because
 // if not, it indicates there's likely some problem with the running
 // system vs the definitions given in the repo.)
-func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*ServiceUpdate, results Result, logger log.Logger) ([]*ServiceUpdate, error) {
+func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*ServiceUpdate, selected map[flux.ServiceID][]string, results Result, logger log.Logger) ([]*ServiceUpdate, error) {
+	// Containers a service ignores, or that weren't picked out when
+	// others were, are never released, so are left out from the
+	// start
+	ignoring, err := rc.ServicesWithPolicy(policy.IgnoreContainers)
+	if err != nil {
+		return nil, err
+	}
+	var considered []*ServiceUpdate
+	for _, u := range candidates {
+		if names, ok := selected[u.ServiceID]; ok {
+			service, missing := onlyContainers(u.Service, names)
+			if missing != "" {
+				results[u.ServiceID] = ServiceResult{
+					Status: ReleaseStatusFailed,
+					Error:  fmt.Sprintf("%s: %q", ContainerNotFound, missing),
+				}
+				continue
+			}
+			u.Service = service
+		}
+		u.Service = withoutIgnoredContainers(u.Service, ignoring[u.ServiceID])
+		considered = append(considered, u)
+	}
+	candidates = considered
+
 	// Compile an `ImageMap` of all relevant images
 	var images ImageMap
-	var repo string
-	var err error
+	var repo, alias string
 
 	switch s.ImageSpec {
 	case ImageSpecLatest:
@@ -202,6 +295,9 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 		if err == nil {
 			repo = image.Repository()
 			images, err = exactImages(rc.Registry(), []flux.ImageID{image})
+			if err == nil && images[repo][0].ID != image {
+				alias = image.Tag
+			}
 		}
 	}
 
@@ -209,6 +305,50 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 		return nil, err
 	}
 
+	// Policies for individual containers are looked up by the
+	// container's name, once per name.
+	containerPolicies := map[policy.Policy]policy.ServiceMap{}
+	servicesWithPolicy := func(p policy.Policy) (policy.ServiceMap, error) {
+		services, ok := containerPolicies[p]
+		if !ok {
+			var err error
+			services, err = rc.ServicesWithPolicy(p)
+			if err != nil {
+				return nil, err
+			}
+			containerPolicies[p] = services
+		}
+		return services, nil
+	}
+	// When releasing the latest images, each container keeps to its
+	// tag filter, if it has one.
+	tagPattern := func(id flux.ServiceID, container string) (policy.Pattern, error) {
+		if s.ImageSpec != ImageSpecLatest {
+			return policy.TagAll, nil
+		}
+		services, err := servicesWithPolicy(policy.Policy(policy.TagPrefix + container))
+		if err != nil {
+			return nil, err
+		}
+		if policies, ok := services[id]; ok {
+			return policies.TagPattern(container), nil
+		}
+		services, err = servicesWithPolicy(policy.TagAllContainers)
+		if err != nil {
+			return nil, err
+		}
+		return services[id].TagPattern(container), nil
+	}
+	// A container locked by itself is left alone, though the rest of
+	// the service may be released.
+	containerLocked := func(id flux.ServiceID, container string) (bool, error) {
+		services, err := servicesWithPolicy(policy.Policy(policy.LockedPrefix + container))
+		if err != nil {
+			return false, err
+		}
+		return services[id].ContainerLocked(container), nil
+	}
+
 	// Look through all the services' containers to see which have an
 	// image that could be updated.
 	var updates []*ServiceUpdate
@@ -227,6 +367,7 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 		// for the purpose of filtering the output.
 		ignoredOrSkipped := ReleaseStatusIgnored
 		var containerUpdates []ContainerUpdate
+		var lockedOut bool
 
 		for _, container := range containers {
 			currentImageID, err := flux.ParseImageID(container.Image)
@@ -236,7 +377,11 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 				return nil, err
 			}
 
-			latestImage := images.LatestImage(currentImageID.Repository(), "*")
+			pattern, err := tagPattern(u.ServiceID, container.Name)
+			if err != nil {
+				return nil, err
+			}
+			latestImage := images.LatestImage(currentImageID.Repository(), pattern)
 			if latestImage == nil {
 				if currentImageID.Repository() != repo {
 					ignoredOrSkipped = ReleaseStatusIgnored
@@ -251,15 +396,31 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 				continue
 			}
 
+			locked, err := containerLocked(u.ServiceID, container.Name)
+			if err != nil {
+				return nil, err
+			}
+			if locked {
+				lockedOut = true
+				continue
+			}
+
 			u.ManifestBytes, err = rc.Manifests().UpdateDefinition(u.ManifestBytes, container.Name, latestImage.ID)
 			if err != nil {
 				return nil, err
 			}
+			var warning string
+			u.ManifestBytes, warning, err = rc.Manifests().CheckPullPolicy(u.ManifestBytes, container.Name, latestImage.ID)
+			if err != nil {
+				return nil, err
+			}
 
 			containerUpdates = append(containerUpdates, ContainerUpdate{
 				Container: container.Name,
 				Current:   currentImageID,
 				Target:    latestImage.ID,
+				Warning:   warning,
+				Alias:     alias,
 			})
 		}
 
@@ -271,6 +432,11 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 				Status:       ReleaseStatusSuccess,
 				PerContainer: containerUpdates,
 			}
+		case lockedOut:
+			results[u.ServiceID] = ServiceResult{
+				Status: ReleaseStatusSkipped,
+				Error:  Locked,
+			}
 		case ignoredOrSkipped == ReleaseStatusSkipped:
 			results[u.ServiceID] = ServiceResult{
 				Status: ReleaseStatusSkipped,
@@ -313,23 +479,52 @@ func (s ServiceSpec) String() string {
 	return string(s)
 }
 
+// ContainerSpec picks out a container of a service, as
+// "namespace/service:container".
+type ContainerSpec string
+
+func ParseContainerSpec(s string) (ContainerSpec, error) {
+	spec := ContainerSpec(s)
+	if _, _, err := spec.Parts(); err != nil {
+		return "", err
+	}
+	return spec, nil
+}
+
+// Parts gives the service and the name of the container.
+func (s ContainerSpec) Parts() (flux.ServiceID, string, error) {
+	colon := strings.LastIndex(string(s), ":")
+	if colon < 0 || colon == len(s)-1 {
+		return "", "", errors.Errorf("invalid container spec %q; expected <namespace>/<service>:<container>", string(s))
+	}
+	id, err := flux.ParseServiceID(string(s[:colon]))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid container spec %q", string(s))
+	}
+	return id, string(s[colon+1:]), nil
+}
+
+func (s ContainerSpec) String() string {
+	return string(s)
+}
+
 // ImageSpec is an ImageID, or "<all latest>" (update all containers
 // to the latest available), or "<no updates>" (do not update any
 // images)
 type ImageSpec string
 
+// ParseImageSpec accepts "<all latest>", or an image with an
+// explicit tag or digest (or both), which may be from a registry with
+// a port. Problems are reported as an *ImageSpecError.
 func ParseImageSpec(s string) (ImageSpec, error) {
 	if s == string(ImageSpecLatest) {
 		return ImageSpec(s), nil
 	}
-
-	parts := strings.Split(s, ":")
-	if len(parts) != 2 || parts[1] == "" {
-		return "", errors.Wrap(flux.ErrInvalidImageID, "blank tag (if you want latest, explicitly state the tag :latest)")
+	id, err := parseImageSpecID(s)
+	if err != nil {
+		return "", err
 	}
-
-	id, err := flux.ParseImageID(s)
-	return ImageSpec(id.String()), err
+	return ImageSpec(id.String()), nil
 }
 
 func (s ImageSpec) String() string {
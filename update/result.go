@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
 )
 
 type ServiceUpdateStatus string
@@ -68,6 +69,8 @@ type ServiceResult struct {
 	Status       ServiceUpdateStatus // summary of what happened, e.g., "incomplete", "ignored", "success"
 	Error        string              `json:",omitempty"` // error if there was one finding the service (e.g., it doesn't exist in repo)
 	PerContainer []ContainerUpdate   // what happened with each container
+	Diff         string              `json:",omitempty"` // for a dry run, the change that would have been committed
+	Impact       *cluster.Rollout    `json:",omitempty"` // for a dry run, the pods that releasing the service would replace
 }
 
 func (fr ServiceResult) Msg(id flux.ServiceID) string {
@@ -78,4 +81,6 @@ type ContainerUpdate struct {
 	Container string
 	Current   flux.ImageID
 	Target    flux.ImageID
+	Warning   string `json:",omitempty"` // e.g., about the image pull policy
+	Alias     string `json:",omitempty"` // the alias tag asked for, if Target was resolved from one
 }
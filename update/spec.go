@@ -3,7 +3,10 @@ package update
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/policy"
 )
 
@@ -11,12 +14,33 @@ const (
 	Images = "image"
 	Policy = "policy"
 	Auto   = "auto"
+	// PolicyDryRun is a policy update that reports what it would
+	// change in the manifests, without committing anything. It's a
+	// type of its own, rather than a flag, so that a daemon that
+	// doesn't know about dry runs will refuse it.
+	PolicyDryRun = "policy-dry-run"
+	// Batch is an ordered list of image and policy updates, applied
+	// together as one commit, or not at all.
+	Batch = "batch"
+	// Add puts the manifests for new resources in the repo, where
+	// the repo layout says they go.
+	Add = "add"
+	// Switch points a service at the other of a blue/green pair of
+	// workloads, once automation has released to it.
+	Switch = "switch"
+	// Rollback puts services back as they were before, either to
+	// the images they were last released from, or to how they were
+	// defined at an earlier commit.
+	Rollback = "rollback"
 )
 
 // How did this update get triggered?
 type Cause struct {
 	Message string
 	User    string
+	// The ID of the API request that asked for the update, so it
+	// can be traced through the logs
+	RequestID string `json:",omitempty"`
 }
 
 // A tagged union for all (both) kinds of update. The type is just so
@@ -40,7 +64,7 @@ func (spec *Spec) UnmarshalJSON(in []byte) error {
 	spec.Type = wire.Type
 	spec.Cause = wire.Cause
 	switch wire.Type {
-	case Policy:
+	case Policy, PolicyDryRun:
 		var update policy.Updates
 		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
 			return err
@@ -58,8 +82,172 @@ func (spec *Spec) UnmarshalJSON(in []byte) error {
 			return err
 		}
 		spec.Spec = update
+	case Batch:
+		var update BatchSpec
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
+	case Add:
+		var update AddSpec
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
+	case Switch:
+		var update SwitchSpec
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
+	case Rollback:
+		var update RollbackSpec
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
 	default:
 		return errors.New("unknown spec type: " + wire.Type)
 	}
 	return nil
 }
+
+// BatchSpec is the steps of a batch update. Each step is a spec of
+// its own; its cause is used only for its part of the commit message.
+type BatchSpec []Spec
+
+// Validate checks that each step is something that can be done as
+// part of a batch: that is, a policy update, or a release that's
+// meant to be executed (rather than planned).
+func (b BatchSpec) Validate() error {
+	if len(b) == 0 {
+		return errors.New("batch has no steps")
+	}
+	for i, step := range b {
+		switch s := step.Spec.(type) {
+		case policy.Updates:
+			if step.Type != Policy {
+				return fmt.Errorf("step %d: %q updates can't be part of a batch", i+1, step.Type)
+			}
+			if err := s.Validate(); err != nil {
+				return fmt.Errorf("step %d: %s", i+1, err)
+			}
+		case ReleaseSpec:
+			if s.Kind != ReleaseKindExecute {
+				return fmt.Errorf("step %d: releases in a batch must be of kind %q", i+1, ReleaseKindExecute)
+			}
+		default:
+			return fmt.Errorf("step %d: %q updates can't be part of a batch", i+1, step.Type)
+		}
+	}
+	return nil
+}
+
+// AddSpec is the definition of one or more new resources, as a
+// (multidoc) YAML manifest; each resource is written to a file of its
+// own, according to the repo layout.
+type AddSpec struct {
+	Definition string `json:"definition"`
+}
+
+func (a AddSpec) Validate() error {
+	if strings.TrimSpace(a.Definition) == "" {
+		return errors.New("no resources given to add")
+	}
+	return nil
+}
+
+// SwitchSpec says to change which of a blue/green pair of workloads a
+// service selects, from one to the other. Images are those the
+// workload switched to was checked to be running, and Release is the
+// revision of the commit that released them to it, if known; these
+// are there so that the release and the switch can be told apart as
+// one in the history.
+type SwitchSpec struct {
+	Service flux.ServiceID `json:"service"`
+	From    flux.ServiceID `json:"from"`
+	To      flux.ServiceID `json:"to"`
+	Images  []flux.ImageID `json:"images,omitempty"`
+	Release string         `json:"release,omitempty"`
+}
+
+func (s SwitchSpec) Validate() error {
+	if s.Service == "" || s.To == "" {
+		return errors.New("switch needs a service, and a workload to switch to")
+	}
+	if s.From == s.To {
+		return fmt.Errorf("cannot switch %s from %s to itself", s.Service, s.To)
+	}
+	return nil
+}
+
+// RollbackSpec says to put services back as they were. With no
+// revision, each container goes back to the image it had before the
+// most recent release (by flux) that changed it; with a revision, the
+// manifest for each service goes back to how it was at that commit,
+// whatever else has changed since. Either way, the rollback is a new
+// commit, and locked services are left alone, as with a release.
+type RollbackSpec struct {
+	ServiceSpecs []ServiceSpec `json:"serviceSpecs"`
+	Revision     string        `json:"revision,omitempty"`
+	Kind         ReleaseKind   `json:"kind"`
+}
+
+func (r RollbackSpec) Validate() error {
+	if len(r.ServiceSpecs) == 0 {
+		return errors.New("no services given to roll back")
+	}
+	for _, s := range r.ServiceSpecs {
+		if _, err := ParseServiceSpec(string(s)); err != nil {
+			return err
+		}
+		if s == ServiceSpecAll && r.Revision == "" {
+			return errors.New("all services can only be rolled back to a revision, not to their previous images")
+		}
+	}
+	if _, err := ParseReleaseKind(string(r.Kind)); err != nil {
+		return fmt.Errorf("%s %q", err, r.Kind)
+	}
+	return nil
+}
+
+// Filters gives the filters selecting the services to roll back.
+func (r RollbackSpec) Filters(rc ReleaseContext) ([]ServiceFilter, error) {
+	var ids []flux.ServiceID
+	for _, s := range r.ServiceSpecs {
+		if s == ServiceSpecAll {
+			ids = nil
+			break
+		}
+		id, err := s.AsID()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	var filters []ServiceFilter
+	if len(ids) > 0 {
+		filters = append(filters, &IncludeFilter{ids})
+	}
+	locked, err := rc.ServicesWithPolicy(policy.Locked)
+	if err != nil {
+		return nil, err
+	}
+	return append(filters, &LockedFilter{locked.ToSlice()}), nil
+}
+
+// MarkSkipped records the services asked for that weren't found.
+func (r RollbackSpec) MarkSkipped(results Result) {
+	ReleaseSpec{ServiceSpecs: r.ServiceSpecs}.markSkipped(results)
+}
+
+func (r RollbackSpec) CommitMessage() string {
+	var services []string
+	for _, spec := range r.ServiceSpecs {
+		services = append(services, strings.Trim(spec.String(), "<>"))
+	}
+	if r.Revision == "" {
+		return fmt.Sprintf("Roll back %s to previous images", strings.Join(services, ", "))
+	}
+	return fmt.Sprintf("Roll back %s to %s", strings.Join(services, ", "), r.Revision)
+}
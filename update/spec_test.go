@@ -1,6 +1,14 @@
 package update
 
-import "testing"
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+)
 
 func TestParseImageSpec(t *testing.T) {
 	parseSpec(t, "valid/image:tag", false)
@@ -10,6 +18,39 @@ func TestParseImageSpec(t *testing.T) {
 	parseSpec(t, "image", true)
 	parseSpec(t, string(ImageSpecLatest), false)
 	parseSpec(t, "<invalid spec>", true)
+	parseSpec(t, "registry:5000/repo/image:tag", false)
+	parseSpec(t, "registry:5000/repo/image@sha256:"+digest, false)
+	parseSpec(t, "image:tag@sha256:"+digest, false)
+	parseSpec(t, "registry:5000/repo/image", true)
+	parseSpec(t, "image@sha256:abc", true)
+	parseSpec(t, "Image:tag", true)
+}
+
+var digest = strings.Repeat("0123456789abcdef", 4)
+
+func TestParseImageSpecSuggestions(t *testing.T) {
+	for spec, suggestion := range map[string]string{
+		"image":                            "image:latest",
+		"image:":                           "image:latest",
+		"registry:5000/repo/image":         "registry:5000/repo/image:latest",
+		"quay.io/weaveworks/HelloWorld:v1": "quay.io/weaveworks/helloworld:v1",
+		" image:tag":                       "image:tag",
+		"image@sha256:" + strings.ToUpper(digest): "image@sha256:" + digest,
+		"image@sha256:abc":                        "",
+	} {
+		_, err := ParseImageSpec(spec)
+		specErr, ok := err.(*ImageSpecError)
+		if !ok {
+			t.Errorf("%q: expected *ImageSpecError, got %#v", spec, err)
+			continue
+		}
+		if specErr.Suggestion != suggestion {
+			t.Errorf("%q: expected suggestion %q, got %q", spec, suggestion, specErr.Suggestion)
+		}
+		if specErr.Base().Help == "" {
+			t.Errorf("%q: expected help text", spec)
+		}
+	}
 }
 
 func parseSpec(t *testing.T, image string, expectError bool) {
@@ -22,3 +63,150 @@ func parseSpec(t *testing.T, image string, expectError bool) {
 		t.Fatalf("Expected string spec %q but got %q", image, string(spec))
 	}
 }
+
+func TestBatchSpecRoundTrip(t *testing.T) {
+	batch := Spec{
+		Type: Batch,
+		Spec: BatchSpec{
+			{Type: Policy, Spec: policy.Updates{"default/helloworld": {Remove: policy.Set{policy.Locked: "true"}}}},
+			{Type: Images, Spec: ReleaseSpec{ServiceSpecs: []ServiceSpec{"default/helloworld"}, ImageSpec: ImageSpecLatest, Kind: ReleaseKindExecute}},
+		},
+	}
+	bytes, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(bytes, &spec); err != nil {
+		t.Fatal(err)
+	}
+	steps, ok := spec.Spec.(BatchSpec)
+	if !ok || len(steps) != 2 {
+		t.Fatalf("expected batch of two steps, got %#v", spec.Spec)
+	}
+	if _, ok := steps[0].Spec.(policy.Updates); !ok {
+		t.Errorf("expected first step to be policy updates, got %#v", steps[0].Spec)
+	}
+	if _, ok := steps[1].Spec.(ReleaseSpec); !ok {
+		t.Errorf("expected second step to be a release, got %#v", steps[1].Spec)
+	}
+	if err := steps.Validate(); err != nil {
+		t.Errorf("expected batch to be valid, got %s", err)
+	}
+}
+
+func TestBatchSpecValidate(t *testing.T) {
+	for _, b := range []BatchSpec{
+		nil,
+		{{Type: PolicyDryRun, Spec: policy.Updates{}}},
+		{{Type: Images, Spec: ReleaseSpec{Kind: ReleaseKindPlan}}},
+		{{Type: Batch, Spec: BatchSpec{}}},
+		{{Type: Policy, Spec: policy.Updates{
+			flux.MakeServiceID("default", "helloworld"): policy.Update{
+				Add: policy.Set{policy.Policy(policy.TagPrefix + "greeter"): "regex:("},
+			},
+		}}},
+	} {
+		if err := b.Validate(); err == nil {
+			t.Errorf("expected error for batch %#v", b)
+		}
+	}
+}
+
+func TestAddSpecRoundTrip(t *testing.T) {
+	add := Spec{
+		Type: Add,
+		Spec: AddSpec{Definition: "apiVersion: v1\nkind: Service\nmetadata:\n  name: helloworld\n"},
+	}
+	bytes, err := json.Marshal(add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(bytes, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Spec != add.Spec {
+		t.Errorf("expected %#v, got %#v", add.Spec, spec.Spec)
+	}
+	if err := (AddSpec{Definition: "\n"}).Validate(); err == nil {
+		t.Error("expected error for an empty definition")
+	}
+}
+
+func TestRollbackSpec(t *testing.T) {
+	rollback := Spec{
+		Type: Rollback,
+		Spec: RollbackSpec{
+			ServiceSpecs: []ServiceSpec{"default/helloworld"},
+			Revision:     "abc123",
+			Kind:         ReleaseKindExecute,
+		},
+	}
+	bytes, err := json.Marshal(rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(bytes, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(spec.Spec, rollback.Spec) {
+		t.Errorf("expected %#v, got %#v", rollback.Spec, spec.Spec)
+	}
+
+	for _, r := range []RollbackSpec{
+		{ServiceSpecs: []ServiceSpec{"default/helloworld"}, Kind: ReleaseKindExecute},
+		{ServiceSpecs: []ServiceSpec{ServiceSpecAll}, Revision: "abc123", Kind: ReleaseKindPlan},
+	} {
+		if err := r.Validate(); err != nil {
+			t.Errorf("expected %#v to be valid, got %v", r, err)
+		}
+	}
+	for _, r := range []RollbackSpec{
+		{Kind: ReleaseKindExecute},
+		{ServiceSpecs: []ServiceSpec{"helloworld"}, Kind: ReleaseKindExecute},
+		{ServiceSpecs: []ServiceSpec{ServiceSpecAll}, Kind: ReleaseKindExecute},
+		{ServiceSpecs: []ServiceSpec{"default/helloworld"}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected error for rollback %#v", r)
+		}
+	}
+}
+
+func TestContainerSpec(t *testing.T) {
+	for _, s := range []string{"default/foo", "default/foo:", "foo:app", ":app"} {
+		if _, err := ParseContainerSpec(s); err == nil {
+			t.Errorf("expected %q to be an invalid container spec", s)
+		}
+	}
+	spec, err := ParseContainerSpec("default/foo:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, container, _ := spec.Parts()
+	if id != flux.ServiceID("default/foo") || container != "app" {
+		t.Errorf("expected default/foo and app, got %s and %s", id, container)
+	}
+
+	release := ReleaseSpec{
+		ServiceSpecs: []ServiceSpec{"default/foo"},
+		ImageSpec:    ImageSpecLatest,
+		Containers:   []ContainerSpec{spec},
+	}
+	if err := release.Validate(); err != nil {
+		t.Error(err)
+	}
+	if msg := release.CommitMessage(); msg != "Release all latest to default/foo:app" {
+		t.Errorf("unexpected commit message %q", msg)
+	}
+	release.ServiceSpecs = []ServiceSpec{"default/bar"}
+	if err := release.Validate(); err == nil {
+		t.Error("expected picking out a container of a service not being released to be invalid")
+	}
+	release.ServiceSpecs = []ServiceSpec{ServiceSpecAll}
+	if err := release.Validate(); err != nil {
+		t.Error(err)
+	}
+}